@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"awesomeProject/pkg/alerts"
+	"awesomeProject/pkg/archive"
+	"awesomeProject/pkg/attach"
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/backup"
+	"awesomeProject/pkg/broker"
+	"awesomeProject/pkg/bus"
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/delivery"
+	"awesomeProject/pkg/devices"
+	"awesomeProject/pkg/eventlog"
+	"awesomeProject/pkg/fairness"
+	"awesomeProject/pkg/features"
+	"awesomeProject/pkg/httpapi"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/notify"
+	"awesomeProject/pkg/outbound"
+	"awesomeProject/pkg/pgstore"
+	"awesomeProject/pkg/printer"
+	"awesomeProject/pkg/privacy"
+	"awesomeProject/pkg/redisqueue"
+	"awesomeProject/pkg/reports"
+	"awesomeProject/pkg/wal"
+)
+
+// Config holds the server settings
+type Config struct {
+	Addr          string          `json:"addr"`
+	Server        ServerConfig    `json:"server"`
+	HTTP          httpapi.Config  `json:"http"`
+	Manager       manager.Config  `json:"manager"`
+	Notifications notify.Config   `json:"notifications"`
+	Delivery      delivery.Config `json:"delivery"`
+	Outbound      outbound.Config `json:"outbound"` // Shared by notifications and delivery
+	Printer       printer.Config  `json:"printer"`
+	Alerts        alerts.Config   `json:"alerts"`
+	Reports       reports.Config  `json:"reports"`
+	Bus           bus.Config      `json:"bus"`
+	Broker        broker.Config   `json:"broker"`
+	EventLog      eventlog.Config `json:"eventLog"`
+	Queue         QueueConfig     `json:"queue"`
+	IDs           IDConfig        `json:"ids"`
+	Archive       archive.Config  `json:"archive"`
+	Audit         audit.Config    `json:"audit"`
+	Backup        backup.Config   `json:"backup"`
+	Attachments   attach.Config   `json:"attachments"`
+	Fairness      fairness.Config `json:"fairness"`
+	Devices       devices.Config  `json:"devices"`
+	Privacy       privacy.Config  `json:"privacy"`
+	Features      features.Config `json:"features"` // Turn optional behaviors off, also at runtime
+
+	// Restore names a backup to put back before starting, or latest; set by
+	// the -restore flag only
+	Restore string `json:"-"`
+}
+
+// ServerConfig bounds how long a connection may take over each part of a
+// request, so slow or idle clients cannot hold connections open; zero
+// leaves that part unbounded. The event streams, order waits and GraphQL
+// subscriptions lift the read and write timeouts once they start.
+type ServerConfig struct {
+	ReadHeaderTimeout config.Duration `json:"readHeaderTimeout"`
+	ReadTimeout       config.Duration `json:"readTimeout"`  // The whole request, body included
+	WriteTimeout      config.Duration `json:"writeTimeout"` // From the end of the request to the end of the response; longer than any route's timeout
+	IdleTimeout       config.Duration `json:"idleTimeout"`  // Between requests on a kept-alive connection
+}
+
+// DefaultServerConfig returns the timeouts used when nothing is configured:
+// the write timeout outlasts the longest route timeout, the exports'
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		ReadHeaderTimeout: config.Duration(5 * time.Second),
+		ReadTimeout:       config.Duration(time.Minute),
+		WriteTimeout:      config.Duration(3 * time.Minute),
+		IdleTimeout:       config.Duration(2 * time.Minute),
+	}
+}
+
+// Validate checks that no timeout is negative
+func (c ServerConfig) Validate() error {
+	if c.ReadHeaderTimeout < 0 || c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0 {
+		return errors.New("server timeouts must not be negative")
+	}
+	return nil
+}
+
+// QueueConfig selects where waiting orders are kept
+type QueueConfig struct {
+	Backend  string            `json:"backend"` // "memory" (default), "redis" or "postgres"
+	Redis    redisqueue.Config `json:"redis"`
+	Postgres pgstore.Config    `json:"postgres"`
+
+	// WAL keeps the changes to the memory queue in a write-ahead log, so
+	// the orders waiting survive a crash
+	WAL wal.Config `json:"wal"`
+}
+
+// IDConfig selects how order IDs are allocated
+type IDConfig struct {
+	Format string `json:"format"` // "sequential" (default), "uuid" or "ulid"
+
+	// SequenceFile keeps the sequential counter in a file, so IDs carry on
+	// across restarts without an event log to replay
+	SequenceFile string `json:"sequenceFile"`
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		Addr:          ":8080",
+		Server:        DefaultServerConfig(),
+		HTTP:          httpapi.DefaultConfig(),
+		Manager:       manager.DefaultConfig(),
+		Notifications: notify.DefaultConfig(),
+		Delivery:      delivery.DefaultConfig(),
+		Outbound:      outbound.DefaultConfig(),
+		Printer:       printer.DefaultConfig(),
+		Alerts:        alerts.DefaultConfig(),
+		Reports:       reports.DefaultConfig(),
+		Bus:           bus.DefaultConfig(),
+		Broker:        broker.DefaultConfig(),
+		Queue:         QueueConfig{Backend: "memory", Redis: redisqueue.DefaultConfig(), Postgres: pgstore.DefaultConfig(), WAL: wal.DefaultConfig()},
+		IDs:           IDConfig{Format: manager.IDSequential},
+		Archive:       archive.DefaultConfig(),
+		Audit:         audit.DefaultConfig(),
+		Backup:        backup.DefaultConfig(),
+		Attachments:   attach.DefaultConfig(),
+		Fairness:      fairness.DefaultConfig(),
+		Privacy:       privacy.DefaultConfig(),
+	}
+}
+
+// LoadConfig reads an optional JSON config file and applies command-line overrides
+func LoadConfig(args []string) (Config, error) {
+	cfg := DefaultConfig()
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	path := fs.String("config", "", "path to a JSON config file")
+	addr := fs.String("addr", "", "listen address (overrides config)")
+	legacy := fs.Bool("legacy", cfg.HTTP.LegacyRoutes, "serve legacy plain-text endpoints (overrides config)")
+	fs.StringVar(&cfg.Restore, "restore", "", "put back the named backup, or latest, before starting")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *path != "" {
+		data, err := os.ReadFile(*path)
+		if err != nil {
+			return cfg, fmt.Errorf("read config: %w", err)
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("parse config %s: %w", *path, err)
+		}
+	}
+
+	if err := cfg.Server.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Manager.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Delivery.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Features.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Printer.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Alerts.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Reports.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Privacy.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Bus.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Broker.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Attachments.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Fairness.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.HTTP.Timeouts.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.HTTP.Announcements.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.HTTP.Channels.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.HTTP.Branding.Validate(); err != nil {
+		return cfg, err
+	}
+	switch cfg.Queue.Backend {
+	case "memory", "redis":
+	case "postgres":
+		if err := cfg.Queue.Postgres.Validate(); err != nil {
+			return cfg, err
+		}
+		if cfg.Queue.Postgres.Restore && cfg.EventLog.Path != "" {
+			return cfg, errors.New("queue.postgres.restore cannot be used with an event log, which is replayed instead")
+		}
+	default:
+		return cfg, fmt.Errorf("queue backend must be memory, redis or postgres, got %q", cfg.Queue.Backend)
+	}
+	if err := cfg.Queue.WAL.Validate(); err != nil {
+		return cfg, err
+	}
+	if cfg.Queue.WAL.Path != "" && cfg.Queue.Backend != "memory" {
+		return cfg, fmt.Errorf("queue.wal cannot be used with the %s queue, which keeps the orders itself", cfg.Queue.Backend)
+	}
+	if _, err := manager.NewIDGenerator(cfg.IDs.Format); err != nil {
+		return cfg, err
+	}
+	if cfg.IDs.SequenceFile != "" {
+		switch {
+		case cfg.IDs.Format != "" && cfg.IDs.Format != manager.IDSequential:
+			return cfg, fmt.Errorf("ids.sequenceFile needs sequential IDs, not %s", cfg.IDs.Format)
+		case cfg.Queue.Backend == "redis" || cfg.Queue.Backend == "postgres":
+			return cfg, fmt.Errorf("ids.sequenceFile cannot be used with the %s queue, which counts IDs itself", cfg.Queue.Backend)
+		}
+	}
+
+	if cfg.Restore != "" && cfg.Backup.Dir == "" {
+		return cfg, errors.New("-restore needs backup.dir")
+	}
+
+	// Flags given explicitly win over the file
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Addr = *addr
+		case "legacy":
+			cfg.HTTP.LegacyRoutes = *legacy
+		}
+	})
+	return cfg, nil
+}
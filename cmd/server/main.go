@@ -0,0 +1,322 @@
+// Command server runs the restaurant token service.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"awesomeProject/pkg/alerts"
+	"awesomeProject/pkg/archive"
+	"awesomeProject/pkg/attach"
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/backup"
+	"awesomeProject/pkg/broker"
+	"awesomeProject/pkg/bus"
+	"awesomeProject/pkg/delivery"
+	"awesomeProject/pkg/devices"
+	"awesomeProject/pkg/eventlog"
+	"awesomeProject/pkg/fairness"
+	"awesomeProject/pkg/features"
+	"awesomeProject/pkg/httpapi"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/notify"
+	"awesomeProject/pkg/outbound"
+	"awesomeProject/pkg/pgstore"
+	"awesomeProject/pkg/printer"
+	"awesomeProject/pkg/privacy"
+	"awesomeProject/pkg/redisqueue"
+	"awesomeProject/pkg/reports"
+	"awesomeProject/pkg/tracing"
+	"awesomeProject/pkg/wal"
+)
+
+// shutdownTimeout bounds how long in-flight requests get to finish
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	cfg, err := LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var managerOpts []manager.Option
+	if cfg.Queue.Backend == "redis" {
+		q, err := redisqueue.Open(cfg.Queue.Redis)
+		if err != nil {
+			log.Fatalf("queue: %v", err)
+		}
+		defer q.Close()
+		managerOpts = append(managerOpts, manager.WithQueue(q))
+		log.Printf("sharing the order queue through redis at %s", cfg.Queue.Redis.Addr)
+	}
+	// The other places orders are kept, which the purger scrubs too
+	var copies []privacy.Store
+	var store *pgstore.Store
+	if cfg.Queue.Backend == "postgres" {
+		if store, err = pgstore.Open(cfg.Queue.Postgres); err != nil {
+			log.Fatalf("queue: %v", err)
+		}
+		defer store.Close()
+		managerOpts = append(managerOpts, manager.WithQueue(store))
+		copies = append(copies, store)
+		log.Printf("keeping orders in postgres")
+	}
+
+	ids, err := manager.NewIDGenerator(cfg.IDs.Format)
+	if err == nil && cfg.IDs.SequenceFile != "" {
+		ids, err = manager.OpenSequenceFile(cfg.IDs.SequenceFile)
+	}
+	if err != nil {
+		log.Fatalf("ids: %v", err)
+	}
+	if ids != nil {
+		managerOpts = append(managerOpts, manager.WithIDGenerator(ids))
+	}
+
+	flags, err := features.Open(cfg.Features)
+	if err != nil {
+		log.Fatalf("features: %v", err)
+	}
+	managerOpts = append(managerOpts, manager.WithFeatures(flags))
+
+	var history reports.History
+	var archived *archive.Store
+	if cfg.Archive.Dir != "" {
+		if archived, err = archive.Open(cfg.Archive); err != nil {
+			log.Fatalf("archive: %v", err)
+		}
+		managerOpts = append(managerOpts, manager.WithArchiver(archived))
+		history = archived
+	}
+
+	// Before the event log is opened, as restoring may replace it
+	var restored *manager.Snapshot
+	if cfg.Restore != "" {
+		if restored, err = backup.Restore(cfg.Backup.Dir, cfg.Restore, cfg.EventLog.Path); err != nil {
+			log.Fatalf("restore: %v", err)
+		}
+		log.Printf("restored backup %s from %s", cfg.Restore, cfg.Backup.Dir)
+	}
+
+	om := manager.New(cfg.Manager, managerOpts...)
+	// Consumers that do slow work take events through the bus
+	relay := bus.New(cfg.Bus)
+	relay.Attach(om)
+	waits := fairness.New(cfg.Fairness)
+	waits.Attach(om)
+	opts := []httpapi.Option{httpapi.WithFairness(waits), httpapi.WithFeatures(flags)}
+
+	var events *eventlog.Log
+	if cfg.EventLog.Path != "" {
+		var records []eventlog.Record
+		events, records, err = eventlog.Open(cfg.EventLog)
+		if err != nil {
+			log.Fatalf("event log: %v", err)
+		}
+		defer events.Close()
+		tokens, err := eventlog.Rebuild(records)
+		if err == nil {
+			err = om.Restore(tokens)
+		}
+		if err != nil {
+			log.Fatalf("replay event log: %v", err)
+		}
+		log.Printf("replayed %d events for %d orders from %s", len(records), len(tokens), cfg.EventLog.Path)
+		events.Attach(om)
+		opts = append(opts, httpapi.WithEventLog(events))
+	}
+	if store != nil {
+		if cfg.Queue.Postgres.Restore {
+			tokens, err := store.Load(ctx)
+			if err == nil {
+				err = om.Restore(tokens)
+			}
+			if err != nil {
+				log.Fatalf("restore from postgres: %v", err)
+			}
+			log.Printf("restored %d orders from postgres", len(tokens))
+		}
+		if err := store.SyncMenu(ctx, cfg.Manager); err != nil {
+			log.Fatalf("postgres menu: %v", err)
+		}
+		store.Attach(relay)
+		opts = append(opts, httpapi.WithReadinessCheck("postgres", store.Check))
+	}
+	// After the event log, whose waiting orders the write-ahead log brings
+	// up to date
+	if cfg.Queue.WAL.Path != "" {
+		journal, err := wal.Open(cfg.Queue.WAL)
+		if err != nil {
+			log.Fatalf("wal: %v", err)
+		}
+		defer journal.Close()
+		copies = append(copies, journal)
+		rb, err := journal.Attach(om)
+		if err != nil {
+			log.Fatalf("replay wal: %v", err)
+		}
+		log.Printf("replayed %d waiting orders from %s; requeued %v, dropped %v", rb.Queued, cfg.Queue.WAL.Path, rb.Restored, rb.Dropped)
+		opts = append(opts, httpapi.WithReadinessCheck("wal", journal.Check))
+	}
+	if restored != nil {
+		if err := om.RestoreSnapshot(restored); err != nil {
+			log.Fatalf("restore: %v", err)
+		}
+	}
+
+	var trail *audit.Log
+	if cfg.Audit.Path != "" {
+		if trail, err = audit.Open(cfg.Audit); err != nil {
+			log.Fatalf("audit log: %v", err)
+		}
+		defer trail.Close()
+		opts = append(opts, httpapi.WithAuditLog(trail))
+	}
+
+	go om.Run(ctx)
+
+	if cfg.Backup.Dir != "" {
+		backups, err := backup.New(cfg.Backup, om, events)
+		if err != nil {
+			log.Fatalf("backup: %v", err)
+		}
+		go backups.Run(ctx)
+		opts = append(opts, httpapi.WithBackups(backups))
+	}
+
+	if cfg.Attachments.Enabled() {
+		files, err := attach.New(cfg.Attachments)
+		if err != nil {
+			log.Fatalf("attachments: %v", err)
+		}
+		opts = append(opts, httpapi.WithAttachments(files))
+	}
+
+	if cfg.Devices.Enabled() {
+		reg, err := devices.Open(cfg.Devices)
+		if err != nil {
+			log.Fatalf("devices: %v", err)
+		}
+		opts = append(opts, httpapi.WithDevices(reg))
+	}
+
+	out := outbound.New(cfg.Outbound)
+	opts = append(opts, httpapi.WithOutbound(out))
+	if cfg.Notifications.SMS != nil || cfg.Notifications.Push != nil {
+		n := notify.New(cfg.Notifications, out.HTTPClient(0))
+		n.Attach(relay)
+		defer n.Close()
+	}
+	if len(cfg.Delivery.Platforms) > 0 {
+		d, err := delivery.New(cfg.Delivery, out.HTTPClient(1), flags)
+		if err != nil {
+			log.Fatalf("delivery: %v", err)
+		}
+		d.Attach(relay)
+		defer d.Close()
+		copies = append(copies, d)
+	}
+	if len(cfg.Printer.Printers) > 0 {
+		p := printer.New(cfg.Printer)
+		p.Attach(relay)
+		defer p.Close()
+	}
+	if cfg.Broker.Kind != "" {
+		pub, err := broker.New(cfg.Broker)
+		if err != nil {
+			log.Fatalf("broker: %v", err)
+		}
+		pub.Attach(relay)
+		defer func() {
+			if err := pub.Close(); err != nil {
+				log.Printf("broker: %v", err)
+			}
+		}()
+		copies = append(copies, pub)
+		log.Printf("publishing events to %s", cfg.Broker.Kind)
+	}
+
+	purger := privacy.New(cfg.Privacy, om, archived, events, trail, copies...)
+	go purger.Run(ctx)
+	opts = append(opts, httpapi.WithPrivacy(purger))
+	if len(cfg.Alerts.Rules) > 0 {
+		var sms notify.Provider
+		if cfg.Notifications.SMS != nil {
+			sms = notify.NewSMSProvider(*cfg.Notifications.SMS, out.HTTPClient(0))
+		}
+		a := alerts.New(cfg.Alerts, om, sms, out.HTTPClient(0))
+		go a.Run(ctx)
+		defer a.Close()
+		opts = append(opts, httpapi.WithAlerts(a))
+	}
+	if len(cfg.Reports.Schedules) > 0 {
+		var mail notify.Provider
+		if cfg.Notifications.Email != nil {
+			mail = notify.NewEmailProvider(*cfg.Notifications.Email)
+		}
+		sched, err := reports.New(cfg.Reports, om, history, mail, out.HTTPClient(0))
+		if err != nil {
+			log.Fatalf("reports: %v", err)
+		}
+		go sched.Run(ctx)
+	}
+	// Deferred after the consumers, so the bus drains before they close
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := relay.Close(ctx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+
+	tcfg, traced, err := tracing.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("tracing: %v", err)
+	}
+	if traced {
+		tracer := tracing.New(tcfg)
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := tracer.Shutdown(ctx); err != nil {
+				log.Printf("tracing: %v", err)
+			}
+		}()
+		opts = append(opts, httpapi.WithTracer(tracer))
+		log.Printf("exporting traces to %s", tcfg.Endpoint)
+	}
+
+	api := httpapi.New(om, cfg.HTTP, opts...)
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           api,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+	}
+	srv.RegisterOnShutdown(api.CloseStreams)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+
+	fmt.Printf("Server starting at http://localhost%s\n", cfg.Addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"awesomeProject/pkg/client"
+	"awesomeProject/pkg/queue"
+)
+
+// newFlags returns a flag set for a subcommand that reports errors to stderr
+func newFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("tokenctl "+name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+func runAdd(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	fs := newFlags("add")
+	var o client.NewOrder
+	fs.StringVar(&o.Item, "item", "", "item name (required)")
+	fs.IntVar(&o.Priority, "priority", 0, "priority, lower is prepared first")
+	fs.IntVar(&o.Quantity, "quantity", 0, "quantity (default 1)")
+	fs.StringVar(&o.Notes, "notes", "", "notes for the kitchen")
+	fs.Func("flags", "comma-separated allergy and dietary flags, such as nuts,vegan", func(v string) error {
+		o.Flags = strings.Split(v, ",")
+		return nil
+	})
+	fs.StringVar(&o.Station, "station", "", "kitchen station")
+	fs.StringVar(&o.OrderType, "type", "", "dine_in, takeaway or delivery")
+	fs.IntVar(&o.Table, "table", 0, "table number for dine-in")
+	fs.StringVar(&o.Group, "group", "", "table or check linking the order to others")
+	fs.BoolVar(&o.NotifyGroup, "notify-group", false, "announce when every order of the group is ready")
+	fs.IntVar(&o.Course, "course", 0, "course within the group, held until fired if after the current one (default 1)")
+	fs.BoolFunc("paid", "the order is already paid", func(string) error {
+		o.Payment = queue.PaymentPaid
+		return nil
+	})
+	readyIn := fs.Duration("ready-in", 0, "pre-order: ready this long from now")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if o.Item == "" {
+		fmt.Fprintln(os.Stderr, "tokenctl add: -item is required")
+		fs.Usage()
+		return errUsage
+	}
+	if *readyIn > 0 {
+		o.ReadyAt = time.Now().Add(*readyIn)
+	}
+	t, err := c.AddOrder(ctx, o)
+	if err != nil {
+		return err
+	}
+	printOrders(out, []*queue.Token{t})
+	return nil
+}
+
+func runNext(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	fs := newFlags("next")
+	station := fs.String("station", "", "only this station's orders")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var t *queue.Token
+	var err error
+	if stationSet(fs) {
+		t, err = c.PrepareNextAt(ctx, *station)
+	} else {
+		t, err = c.PrepareNext(ctx)
+	}
+	if client.IsNotFound(err) {
+		fmt.Fprintln(out, "nothing to prepare")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	printOrders(out, []*queue.Token{t})
+	return nil
+}
+
+// runClaim starts a cook on an order: the given one, or the next one
+func runClaim(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	fs := newFlags("claim")
+	station := fs.String("station", "", "only this station's orders")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		if stationSet(fs) {
+			fmt.Fprintln(os.Stderr, "tokenctl claim: give an order ID or -station, not both")
+			return errUsage
+		}
+		return orderCommand((*client.Client).ClaimOrder)(ctx, c, out, fs.Args())
+	}
+	var t *queue.Token
+	var err error
+	if stationSet(fs) {
+		t, err = c.ClaimNextAt(ctx, *station)
+	} else {
+		t, err = c.ClaimNext(ctx)
+	}
+	if client.IsNotFound(err) {
+		fmt.Fprintln(out, "nothing to claim")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	printOrders(out, []*queue.Token{t})
+	return nil
+}
+
+func runList(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	fs := newFlags("list")
+	var f client.ListFilter
+	fs.StringVar(&f.Status, "status", "", "only orders with this status")
+	fs.StringVar(&f.Item, "item", "", "only items containing this text")
+	fs.StringVar(&f.OrderType, "type", "", "only this order type")
+	fs.IntVar(&f.Table, "table", 0, "only this table")
+	fs.StringVar(&f.Group, "group", "", "only orders of this table or check")
+	fs.Func("flag", "only orders with these comma-separated flags; allergy matches any allergy flag", func(v string) error {
+		f.Flags = strings.Split(v, ",")
+		return nil
+	})
+	fs.StringVar(&f.Sort, "sort", "", "id, priority, timestamp or item; prefix - for descending")
+	fs.IntVar(&f.Limit, "limit", 0, "at most this many orders")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	list, err := c.ListOrders(ctx, f)
+	if err != nil {
+		return err
+	}
+	printOrders(out, list.Orders)
+	if list.Total > len(list.Orders) {
+		fmt.Fprintf(out, "(%d of %d orders)\n", len(list.Orders), list.Total)
+	}
+	return nil
+}
+
+// orderCommand builds a command taking one order ID
+func orderCommand(call func(*client.Client, context.Context, string) (*queue.Token, error)) func(context.Context, *client.Client, io.Writer, []string) error {
+	return func(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "tokenctl: expected one order ID")
+			return errUsage
+		}
+		t, err := call(c, ctx, args[0])
+		if err != nil {
+			return err
+		}
+		printOrders(out, []*queue.Token{t})
+		return nil
+	}
+}
+
+// runPickup hands over a prepared order, with the pickup code from the
+// receipt when one is given
+func runPickup(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	if len(args) == 2 {
+		t, err := c.PickUpOrderWithCode(ctx, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		printOrders(out, []*queue.Token{t})
+		return nil
+	}
+	return orderCommand((*client.Client).PickUpOrder)(ctx, c, out, args)
+}
+
+// runRush moves an order to the front of the queue, on behalf of -by or the
+// logged-in user
+func runRush(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	fs := newFlags("rush")
+	by := fs.String("by", os.Getenv("USER"), "who is rushing the order")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *by == "" {
+		fmt.Fprintln(os.Stderr, "tokenctl rush: -by is required")
+		return errUsage
+	}
+	rush := func(c *client.Client, ctx context.Context, id string) (*queue.Token, error) {
+		return c.RushOrder(ctx, id, *by)
+	}
+	return orderCommand(rush)(ctx, c, out, fs.Args())
+}
+
+// runFire lets the next course held for a table or check into the kitchen
+func runFire(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "tokenctl fire: expected one group")
+		return errUsage
+	}
+	orders, err := c.FireCourse(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	printOrders(out, orders)
+	return nil
+}
+
+// runWatch keeps a live view of the queue, redrawn on every order event, or
+// with -log prints the events as they happen
+func runWatch(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	fs := newFlags("watch")
+	station := fs.String("station", "", "only this station's orders")
+	logEvents := fs.Bool("log", false, "print events instead of the live view")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var filter client.WatchFilter
+	if stationSet(fs) {
+		filter.Station = station
+	}
+	events, err := c.WatchOrders(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	redraw := func() error {
+		list, err := c.ListOrders(ctx, client.ListFilter{Status: queue.StatusPreparing, Sort: "priority"})
+		if err != nil {
+			return err
+		}
+		orders := list.Orders
+		if filter.Station != nil {
+			orders = orders[:0]
+			for _, t := range list.Orders {
+				if t.Station == *filter.Station {
+					orders = append(orders, t)
+				}
+			}
+		}
+		// Clear the screen and move the cursor home
+		fmt.Fprint(out, "\033[H\033[2J")
+		fmt.Fprintf(out, "%d waiting, updated %s\n\n", len(orders), time.Now().Format(time.TimeOnly))
+		printOrders(out, orders)
+		return nil
+	}
+	if !*logEvents {
+		if err := redraw(); err != nil {
+			return err
+		}
+	}
+
+	// Waiting times in the view go stale without events
+	refresh := time.NewTicker(30 * time.Second)
+	defer refresh.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return ctx.Err()
+			}
+			if *logEvents {
+				fmt.Fprintf(out, "%s  %-10s #%s %s\n", e.At.Local().Format(time.TimeOnly), e.Type, e.Token.ID, e.Token.Item)
+				continue
+			}
+		case <-refresh.C:
+			if *logEvents {
+				continue
+			}
+		}
+		if err := redraw(); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, "tokenctl:", err)
+		}
+	}
+}
+
+// stationSet reports whether -station was given, so an empty value can
+// select orders without a station
+func stationSet(fs *flag.FlagSet) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) { set = set || f.Name == "station" })
+	return set
+}
+
+// flagList shows t's flags, marked with ! when one is an allergy
+func flagList(t *queue.Token) string {
+	s := strings.Join(t.Flags, ",")
+	if t.HasFlag(queue.FlagAllergy) {
+		s = "!" + s
+	}
+	return s
+}
+
+// printOrders writes tokens as an aligned table
+func printOrders(out io.Writer, tokens []*queue.Token) {
+	if len(tokens) == 0 {
+		fmt.Fprintln(out, "no orders")
+		return
+	}
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNO\tCODE\tITEM\tQTY\tPRI\tSTATUS\tSTATION\tWAITING\tFLAGS")
+	now := time.Now()
+	for _, t := range tokens {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\n", t.ID, t.Number, t.PickupCode, t.Item, t.Quantity, t.Priority,
+			t.Status, t.Station, now.Sub(t.Timestamp).Round(time.Second), flagList(t))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,116 @@
+// Command tokenctl manages orders on a running token server from a terminal.
+//
+//	tokenctl [-server URL] add -item pizza -priority 2
+//	tokenctl next [-station grill]
+//	tokenctl claim [-station grill] [ID]
+//	tokenctl list [-status preparing]
+//	tokenctl get|prepare|cancel|restore ID
+//	tokenctl pickup ID [CODE]
+//	tokenctl rush [-by NAME] ID
+//	tokenctl watch [-station grill] [-log]
+//	tokenctl --tui [-station grill]
+//	tokenctl simulate -rate 2 -duration 5m
+//
+// The server defaults to $TOKENCTL_SERVER, then http://localhost:8080.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"awesomeProject/pkg/client"
+)
+
+// defaultServer is used when neither -server nor $TOKENCTL_SERVER is set
+const defaultServer = "http://localhost:8080"
+
+// command is one tokenctl subcommand
+type command struct {
+	usage string
+	run   func(ctx context.Context, c *client.Client, out io.Writer, args []string) error
+}
+
+var commands = map[string]command{
+	"add":      {"add -item NAME -priority N [flags]", runAdd},
+	"next":     {"next [-station NAME]", runNext},
+	"claim":    {"claim [-station NAME] [ID]", runClaim},
+	"list":     {"list [-status S] [-item TEXT] [-limit N] [flags]", runList},
+	"get":      {"get ID", orderCommand((*client.Client).GetOrder)},
+	"prepare":  {"prepare ID", orderCommand((*client.Client).PrepareOrder)},
+	"cancel":   {"cancel ID", orderCommand((*client.Client).CancelOrder)},
+	"restore":  {"restore ID", orderCommand((*client.Client).RestoreOrder)},
+	"pickup":   {"pickup ID [CODE]", runPickup},
+	"rush":     {"rush [-by NAME] ID", runRush},
+	"fire":     {"fire GROUP", runFire},
+	"watch":    {"watch [-station NAME] [-log]", runWatch},
+	"tui":      {"tui [-station NAME]   (or tokenctl --tui)", runTUI},
+	"simulate": {"simulate [-rate N] [-prepare-rate N] [-duration D] [-priorities P:W,...] [flags]", runSimulate},
+}
+
+// errUsage reports bad arguments; the usage has already been printed
+var errUsage = errors.New("usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("tokenctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	server := fs.String("server", envOr("TOKENCTL_SERVER", defaultServer), "token server base URL")
+	tui := fs.Bool("tui", false, "run the interactive kitchen view, as the tui command")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: tokenctl [-server URL] COMMAND [flags]\n\ncommands:")
+		for _, name := range []string{"add", "next", "claim", "list", "get", "prepare", "cancel", "restore", "pickup", "rush", "fire", "watch", "tui", "simulate"} {
+			fmt.Fprintln(stderr, "  tokenctl "+commands[name].usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	args = fs.Args()
+	if *tui {
+		args = append([]string{"tui"}, args...)
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "tokenctl: unknown command %q\n", args[0])
+		fs.Usage()
+		return 2
+	}
+
+	c, err := client.New(*server)
+	if err != nil {
+		fmt.Fprintln(stderr, "tokenctl:", err)
+		return 2
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch err := cmd.run(ctx, c, stdout, args[1:]); {
+	case err == nil, errors.Is(err, context.Canceled):
+		return 0
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		return 2
+	default:
+		fmt.Fprintln(stderr, "tokenctl:", err)
+		return 1
+	}
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/client"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// maxInFlight bounds the orders the simulator has placed but not yet heard back about
+const maxInFlight = 64
+
+// simulation places synthetic orders against a live server and records how
+// it copes
+type simulation struct {
+	c        *client.Client
+	rate     float64 // Order arrivals per second
+	prepRate float64 // Prepares per second; zero leaves the kitchen to people
+	items    []string
+	stations []string
+	weights  []priorityWeight
+
+	mu        sync.Mutex
+	placed    map[string]time.Time // Orders this run placed, by ID
+	early     map[string]time.Time // Prepared before AddOrder returned, by ID
+	latencies []time.Duration      // Of AddOrder calls
+	waits     []time.Duration      // From placing to prepared
+	depths    []int                // Sampled preparing orders
+	rejected  map[string]int       // Failed AddOrder calls by reason
+	prepared  int
+}
+
+type priorityWeight struct {
+	priority int
+	weight   float64
+}
+
+func runSimulate(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	fs := newFlags("simulate")
+	rate := fs.Float64("rate", 1, "orders placed per second, on average")
+	prepRate := fs.Float64("prepare-rate", 1, "orders prepared per second, on average; 0 leaves preparing to the kitchen")
+	duration := fs.Duration("duration", time.Minute, "how long to place orders")
+	priorities := fs.String("priorities", "0:1,1:2,2:4,3:3", "priority:weight pairs orders are drawn from")
+	items := fs.String("items", "burger,pizza,salad,soup,coffee", "comma-separated items, drawn evenly")
+	stations := fs.String("stations", "", "comma-separated stations, drawn evenly; empty for the default")
+	seed := fs.Uint64("seed", 0, "random seed, for repeatable runs; 0 picks one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	weights, err := parseWeights(*priorities)
+	if err != nil || *rate <= 0 || *prepRate < 0 || *duration <= 0 {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "tokenctl simulate:", err)
+		} else {
+			fmt.Fprintln(os.Stderr, "tokenctl simulate: -rate and -duration must be positive and -prepare-rate not negative")
+		}
+		fs.Usage()
+		return errUsage
+	}
+	if *seed == 0 {
+		*seed = rand.Uint64()
+	}
+	sim := &simulation{
+		c: c, rate: *rate, prepRate: *prepRate, weights: weights,
+		items:    splitList(*items),
+		stations: splitList(*stations),
+		placed:   make(map[string]time.Time),
+		early:    make(map[string]time.Time),
+		rejected: make(map[string]int),
+	}
+	if len(sim.items) == 0 {
+		sim.items = []string{"item"}
+	}
+	if len(sim.stations) == 0 {
+		sim.stations = []string{""}
+	}
+
+	// Prepared events give the wait of every simulated order, whoever prepares it
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	defer stopWatch()
+	events, err := c.WatchOrders(watchCtx, client.WatchFilter{})
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sim.watch(events)
+	}()
+
+	runCtx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+	fmt.Fprintf(out, "simulating %.2g orders/s for %s (seed %d)\n", *rate, *duration, *seed)
+	start := time.Now()
+	var workers sync.WaitGroup
+	workers.Add(3)
+	go func() { defer workers.Done(); sim.arrive(runCtx, rand.New(rand.NewPCG(*seed, 1))) }()
+	go func() { defer workers.Done(); sim.prepare(runCtx, rand.New(rand.NewPCG(*seed, 2))) }()
+	go func() { defer workers.Done(); sim.sample(runCtx) }()
+	workers.Wait()
+	elapsed := time.Since(start)
+
+	// Give prepared events already sent a moment to arrive
+	time.Sleep(200 * time.Millisecond)
+	stopWatch()
+	wg.Wait()
+	sim.report(out, elapsed)
+	return ctx.Err()
+}
+
+// arrive places orders as a Poisson process until ctx ends
+func (sim *simulation) arrive(ctx context.Context, rng *rand.Rand) {
+	slots := make(chan struct{}, maxInFlight)
+	var inFlight sync.WaitGroup
+	defer inFlight.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(rng.ExpFloat64() / sim.rate * float64(time.Second))):
+		}
+		o := client.NewOrder{
+			Item:     sim.items[rng.IntN(len(sim.items))],
+			Station:  sim.stations[rng.IntN(len(sim.stations))],
+			Priority: sim.pickPriority(rng),
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			sim.reject("client backlog")
+			continue
+		}
+		inFlight.Add(1)
+		go func() {
+			defer inFlight.Done()
+			defer func() { <-slots }()
+			sent := time.Now()
+			// Orders already sent are let finish after the run ends
+			t, err := sim.c.AddOrder(context.WithoutCancel(ctx), o)
+			sim.mu.Lock()
+			defer sim.mu.Unlock()
+			sim.latencies = append(sim.latencies, time.Since(sent))
+			if err != nil {
+				sim.rejected[rejectReason(err)]++
+				return
+			}
+			if at, ok := sim.early[t.ID]; ok {
+				sim.preparedAt(t.Timestamp, at)
+				delete(sim.early, t.ID)
+				return
+			}
+			sim.placed[t.ID] = t.Timestamp
+		}()
+	}
+}
+
+// prepare plays the kitchen, preparing orders as a Poisson process
+func (sim *simulation) prepare(ctx context.Context, rng *rand.Rand) {
+	if sim.prepRate == 0 {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(rng.ExpFloat64() / sim.prepRate * float64(time.Second))):
+		}
+		if _, err := sim.c.PrepareNext(ctx); err != nil && !client.IsNotFound(err) && ctx.Err() == nil {
+			sim.reject("prepare failed")
+		}
+	}
+}
+
+// sample records the queue depth every second
+func (sim *simulation) sample(ctx context.Context) {
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		list, err := sim.c.ListOrders(ctx, client.ListFilter{Status: queue.StatusPreparing, Limit: 1})
+		if err != nil {
+			continue
+		}
+		sim.mu.Lock()
+		sim.depths = append(sim.depths, list.Total)
+		sim.mu.Unlock()
+	}
+}
+
+// watch records the wait of each simulated order as it is prepared
+func (sim *simulation) watch(events <-chan client.Event) {
+	for e := range events {
+		if e.Type != manager.EventPrepared || e.Token.PreparedAt == nil {
+			continue
+		}
+		sim.mu.Lock()
+		if placed, ok := sim.placed[e.Token.ID]; ok {
+			sim.preparedAt(placed, *e.Token.PreparedAt)
+			delete(sim.placed, e.Token.ID)
+		} else {
+			// The event may beat the AddOrder response; stale entries from
+			// other clients' orders are harmless
+			sim.early[e.Token.ID] = *e.Token.PreparedAt
+		}
+		sim.mu.Unlock()
+	}
+}
+
+// preparedAt records one simulated order's wait; sim.mu is held
+func (sim *simulation) preparedAt(placed, prepared time.Time) {
+	sim.waits = append(sim.waits, prepared.Sub(placed))
+	sim.prepared++
+}
+
+func (sim *simulation) reject(reason string) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.rejected[reason]++
+}
+
+func (sim *simulation) pickPriority(rng *rand.Rand) int {
+	var total float64
+	for _, w := range sim.weights {
+		total += w.weight
+	}
+	x := rng.Float64() * total
+	for _, w := range sim.weights {
+		if x < w.weight {
+			return w.priority
+		}
+		x -= w.weight
+	}
+	return sim.weights[len(sim.weights)-1].priority
+}
+
+// report prints throughput, queue depth and wait percentiles
+func (sim *simulation) report(out io.Writer, elapsed time.Duration) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	secs := elapsed.Seconds()
+	placed := len(sim.latencies) - sumValues(sim.rejected)
+	fmt.Fprintf(out, "\nran %s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(out, "placed     %d (%.2f/s)\n", placed, float64(placed)/secs)
+	fmt.Fprintf(out, "prepared   %d (%.2f/s), %d still waiting\n", sim.prepared, float64(sim.prepared)/secs, len(sim.placed))
+	if len(sim.rejected) > 0 {
+		reasons := make([]string, 0, len(sim.rejected))
+		for r, n := range sim.rejected {
+			reasons = append(reasons, fmt.Sprintf("%s %d", r, n))
+		}
+		slices.Sort(reasons)
+		fmt.Fprintf(out, "failed     %s\n", strings.Join(reasons, ", "))
+	}
+	if len(sim.depths) > 0 {
+		fmt.Fprintf(out, "depth      mean %.1f  max %d  last %d\n", mean(sim.depths), slices.Max(sim.depths), sim.depths[len(sim.depths)-1])
+	}
+	printPercentiles(out, "wait", sim.waits)
+	printPercentiles(out, "add call", sim.latencies)
+}
+
+func printPercentiles(out io.Writer, label string, ds []time.Duration) {
+	if len(ds) == 0 {
+		fmt.Fprintf(out, "%-10s no samples\n", label)
+		return
+	}
+	slices.Sort(ds)
+	at := func(p float64) time.Duration {
+		return ds[min(len(ds)-1, int(p*float64(len(ds))))].Round(time.Millisecond)
+	}
+	fmt.Fprintf(out, "%-10s p50 %s  p90 %s  p99 %s  max %s\n", label, at(0.5), at(0.9), at(0.99), ds[len(ds)-1].Round(time.Millisecond))
+}
+
+// rejectReason names why the server refused an order
+func rejectReason(err error) string {
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		return "network error"
+	}
+	switch apiErr.StatusCode {
+	case http.StatusServiceUnavailable:
+		return "station full"
+	case http.StatusTooManyRequests:
+		return "rate limited"
+	case http.StatusConflict:
+		return "duplicate"
+	}
+	return strconv.Itoa(apiErr.StatusCode)
+}
+
+// parseWeights reads priority:weight pairs
+func parseWeights(s string) ([]priorityWeight, error) {
+	var weights []priorityWeight
+	for _, pair := range splitList(s) {
+		p, w, ok := strings.Cut(pair, ":")
+		priority, err1 := strconv.Atoi(p)
+		weight, err2 := strconv.ParseFloat(w, 64)
+		if !ok || err1 != nil || err2 != nil || weight < 0 {
+			return nil, fmt.Errorf("priority weight %q: want priority:weight, such as 2:0.5", pair)
+		}
+		weights = append(weights, priorityWeight{priority, weight})
+	}
+	if len(weights) == 0 {
+		return nil, errors.New("-priorities lists no priorities")
+	}
+	return weights, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func sumValues(m map[string]int) int {
+	n := 0
+	for _, v := range m {
+		n += v
+	}
+	return n
+}
+
+func mean(xs []int) float64 {
+	sum := 0
+	for _, x := range xs {
+		sum += x
+	}
+	return float64(sum) / float64(len(xs))
+}
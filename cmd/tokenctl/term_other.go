@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// rawMode is not implemented here; keys take effect once Enter is pressed
+func rawMode(fd int) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"awesomeProject/pkg/client"
+	"awesomeProject/pkg/queue"
+)
+
+// tuiKeys is the key help shown under the queue
+const tuiKeys = "↑/k ↓/j select   n prepare next   p prepare selected   c cancel   +/- raise/lower priority   r reload   q quit"
+
+// tui is the interactive kitchen view: the waiting orders, kept current by
+// the event stream, with keys acting on the selected one
+type tui struct {
+	c       *client.Client
+	out     io.Writer
+	station *string // Only this station's orders, nil for all
+
+	orders   []*queue.Token
+	selected int    // Index into orders
+	message  string // Result of the last action
+	confirm  func(context.Context) error
+}
+
+// runTUI takes over the terminal until q is pressed or the context ends
+func runTUI(ctx context.Context, c *client.Client, out io.Writer, args []string) error {
+	fs := newFlags("tui")
+	station := fs.String("station", "", "only this station's orders")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ui := &tui{c: c, out: out}
+	if stationSet(fs) {
+		ui.station = station
+	}
+
+	events, err := c.WatchOrders(ctx, client.WatchFilter{Station: ui.station})
+	if err != nil {
+		return err
+	}
+	restore, err := rawMode(int(os.Stdin.Fd()))
+	if err != nil {
+		// Not a terminal; keys are read a line at a time
+		restore = func() {}
+	}
+	defer restore()
+	defer fmt.Fprint(out, "\033[?25h\n") // Show the cursor again
+	fmt.Fprint(out, "\033[?25l")
+
+	ui.reload(ctx)
+	ui.draw()
+	keys := readKeys(os.Stdin)
+	refresh := time.NewTicker(30 * time.Second)
+	defer refresh.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-events:
+			if !ok {
+				return ctx.Err()
+			}
+			ui.reload(ctx)
+		case <-refresh.C:
+			ui.reload(ctx)
+		case key, ok := <-keys:
+			if !ok || (key == "q" && ui.confirm == nil) {
+				return nil
+			}
+			ui.handle(ctx, key)
+		}
+		ui.draw()
+	}
+}
+
+// handle applies one key press
+func (ui *tui) handle(ctx context.Context, key string) {
+	if ui.confirm != nil {
+		action := ui.confirm
+		ui.confirm, ui.message = nil, ""
+		if key == "y" {
+			ui.report(action(ctx))
+			ui.reload(ctx)
+		}
+		return
+	}
+
+	sel := ui.current()
+	switch key {
+	case "up", "k":
+		ui.selected = max(ui.selected-1, 0)
+	case "down", "j":
+		ui.selected = min(ui.selected+1, max(len(ui.orders)-1, 0))
+	case "r":
+		ui.message = ""
+		ui.reload(ctx)
+	case "n":
+		var t *queue.Token
+		var err error
+		if ui.station != nil {
+			t, err = ui.c.PrepareNextAt(ctx, *ui.station)
+		} else {
+			t, err = ui.c.PrepareNext(ctx)
+		}
+		if err == nil {
+			ui.message = fmt.Sprintf("prepared #%d %s", t.Number, t.Item)
+		} else if client.IsNotFound(err) {
+			ui.message = "nothing to prepare"
+		} else {
+			ui.report(err)
+		}
+		ui.reload(ctx)
+	case "p":
+		if sel == nil {
+			return
+		}
+		if t, err := ui.c.PrepareOrder(ctx, sel.ID); err == nil {
+			ui.message = fmt.Sprintf("prepared #%d %s", t.Number, t.Item)
+		} else {
+			ui.report(err)
+		}
+		ui.reload(ctx)
+	case "c":
+		if sel == nil {
+			return
+		}
+		ui.message = fmt.Sprintf("cancel #%d %s? (y/n)", sel.Number, sel.Item)
+		ui.confirm = func(ctx context.Context) error {
+			// Only the order as it was shown, not one changed since
+			_, err := ui.c.CancelOrderVersion(ctx, sel.ID, sel.Version)
+			return err
+		}
+	case "+", "-":
+		if sel == nil {
+			return
+		}
+		// Lower numbers are prepared first, so + moves towards 0
+		priority := sel.Priority + 1
+		if key == "+" {
+			priority = max(sel.Priority-1, 0)
+		}
+		_, err := ui.c.ModifyOrder(ctx, sel.ID, client.OrderChanges{Priority: &priority, Version: sel.Version})
+		if err == nil {
+			ui.message = fmt.Sprintf("#%d priority %d", sel.Number, priority)
+		}
+		ui.report(err)
+		ui.reload(ctx)
+	}
+}
+
+func (ui *tui) report(err error) {
+	if err != nil {
+		ui.message = "error: " + err.Error()
+	}
+}
+
+// current returns the selected order, or nil when the queue is empty
+func (ui *tui) current() *queue.Token {
+	if ui.selected < len(ui.orders) {
+		return ui.orders[ui.selected]
+	}
+	return nil
+}
+
+// reload fetches the waiting orders, keeping the same order selected
+func (ui *tui) reload(ctx context.Context) {
+	list, err := ui.c.ListOrders(ctx, client.ListFilter{Status: queue.StatusPreparing, Sort: "priority"})
+	if err != nil {
+		ui.report(err)
+		return
+	}
+	prev := ui.current()
+	ui.orders = list.Orders[:0]
+	for _, t := range list.Orders {
+		if ui.station == nil || t.Station == *ui.station {
+			ui.orders = append(ui.orders, t)
+		}
+	}
+	ui.selected = min(ui.selected, max(len(ui.orders)-1, 0))
+	if prev != nil {
+		for i, t := range ui.orders {
+			if t.ID == prev.ID {
+				ui.selected = i
+			}
+		}
+	}
+}
+
+// draw repaints the whole screen
+func (ui *tui) draw() {
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	title := "all stations"
+	if ui.station != nil {
+		title = "station " + *ui.station
+	}
+	fmt.Fprintf(&b, "Kitchen queue: %s, %d waiting, %s\n\n", title, len(ui.orders), time.Now().Format(time.TimeOnly))
+
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tNO\tITEM\tQTY\tPRI\tSTATION\tWAITING\tREADY IN\tFLAGS\tNOTES")
+	now := time.Now()
+	for i, t := range ui.orders {
+		marker := " "
+		if i == ui.selected {
+			marker = ">"
+		}
+		readyIn := "-"
+		if t.EstimatedReadyAt != nil {
+			readyIn = fmt.Sprintf("%dm", int(math.Ceil(t.EstimatedReadyAt.Sub(now).Minutes())))
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%d\t%s\t%dm\t%s\t%s\t%s\n", marker, t.Number, t.Item, t.Quantity, t.Priority,
+			t.Station, int(now.Sub(t.Timestamp).Minutes()), readyIn, flagList(t), t.Notes)
+	}
+	tw.Flush()
+	if len(ui.orders) == 0 {
+		b.WriteString("  no orders waiting\n")
+	}
+	fmt.Fprintf(&b, "\n%s\n%s\n", tuiKeys, ui.message)
+	io.WriteString(ui.out, b.String())
+}
+
+// readKeys delivers key presses from r, naming the arrow keys "up" and "down"
+func readKeys(r io.Reader) <-chan string {
+	keys := make(chan string)
+	go func() {
+		defer close(keys)
+		br := bufio.NewReader(r)
+		for {
+			b, err := br.ReadByte()
+			if err != nil {
+				return
+			}
+			key := string(b)
+			if b == '\033' {
+				// Arrow keys arrive as ESC [ A and ESC [ B
+				seq := make([]byte, 2)
+				if _, err := io.ReadFull(br, seq); err != nil {
+					return
+				}
+				switch string(seq) {
+				case "[A":
+					key = "up"
+				case "[B":
+					key = "down"
+				default:
+					continue
+				}
+			}
+			if key == "\n" || key == "\r" {
+				continue
+			}
+			keys <- key
+		}
+	}()
+	return keys
+}
@@ -0,0 +1,417 @@
+// Package alerts warns staff when the kitchen falls behind: too many orders
+// waiting, an order waiting too long, or too many prepared orders left
+// uncollected. A station paused for broken equipment is alerted too.
+//
+// Rules are checked on a schedule against the manager's listing. An alert
+// fires when its rule is first broken and resolves when the rule holds
+// again; both are posted to a webhook and texted to staff phones, and the
+// alerts firing are shown on the kitchen display.
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/notify"
+	"awesomeProject/pkg/queue"
+)
+
+// Metrics a rule can watch
+const (
+	MetricQueueDepth    = "queue_depth"    // Orders waiting to be prepared
+	MetricOldestWaiting = "oldest_waiting" // Seconds the longest waiting order has waited
+	MetricUncollected   = "uncollected"    // Prepared orders not yet picked up
+)
+
+// Metrics lists every metric
+var Metrics = []string{MetricQueueDepth, MetricOldestWaiting, MetricUncollected}
+
+// MetricStationPaused is the metric of the alert each paused station fires,
+// with no rule needed; its value is the seconds it has been paused
+const MetricStationPaused = "station_paused"
+
+// SignatureHeader carries the webhook body's HMAC-SHA256 as "sha256=<hex>"
+const SignatureHeader = "X-Signature"
+
+// Rule is one threshold to watch
+type Rule struct {
+	Name    string `json:"name"`    // Shown in notifications; defaults to the metric and station
+	Metric  string `json:"metric"`  // One of the metrics
+	Station string `json:"station"` // Only this station's orders; empty for every station
+
+	// Above is the count queue_depth and uncollected fire beyond, and Age
+	// the wait oldest_waiting fires beyond
+	Above int             `json:"above"`
+	Age   config.Duration `json:"age"`
+}
+
+// name returns the rule's name, or one made from its metric and station
+func (r Rule) name() string {
+	switch {
+	case r.Name != "":
+		return r.Name
+	case r.Station != "":
+		return r.Metric + ":" + r.Station
+	}
+	return r.Metric
+}
+
+// threshold returns the value the rule fires beyond, in the metric's unit
+func (r Rule) threshold() int {
+	if r.Metric == MetricOldestWaiting {
+		return int(time.Duration(r.Age).Seconds())
+	}
+	return r.Above
+}
+
+// Config sets the rules and where alerts are sent; with no rules nothing is
+// checked
+type Config struct {
+	Rules    []Rule          `json:"rules"`
+	Interval config.Duration `json:"interval"` // Between checks
+
+	// Webhook is posted a JSON body for each alert firing and resolving,
+	// signed with Secret when it is set
+	Webhook string `json:"webhook"`
+	Secret  string `json:"secret"`
+
+	// Phones are staff numbers texted through the notifications SMS
+	// gateway, when one is configured
+	Phones []string `json:"phones"`
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{Interval: config.Duration(30 * time.Second)}
+}
+
+// Validate checks the rules
+func (c Config) Validate() error {
+	names := make(map[string]bool)
+	for i, r := range c.Rules {
+		switch {
+		case !slices.Contains(Metrics, r.Metric):
+			return fmt.Errorf("alert rule %d: unknown metric %q, want one of %v", i+1, r.Metric, Metrics)
+		case r.Metric == MetricOldestWaiting && r.Age <= 0:
+			return fmt.Errorf("alert rule %s: oldest_waiting needs a positive age", r.name())
+		case r.Above < 0:
+			return fmt.Errorf("alert rule %s: above must not be negative", r.name())
+		case names[r.name()]:
+			return fmt.Errorf("alert rule %s: name used twice", r.name())
+		}
+		names[r.name()] = true
+	}
+	if len(c.Rules) > 0 && c.Interval <= 0 {
+		return fmt.Errorf("alert interval must be positive")
+	}
+	return nil
+}
+
+// Alert is a rule that is broken
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Metric    string    `json:"metric"`
+	Station   string    `json:"station,omitempty"`
+	Value     int       `json:"value"`     // The metric now: a count, or seconds for oldest_waiting and station_paused
+	Threshold int       `json:"threshold"` // The value the rule fires beyond
+	Message   string    `json:"message"`
+	Since     time.Time `json:"since"`
+}
+
+// Webhook events
+const (
+	EventFiring   = "alert.firing"
+	EventResolved = "alert.resolved"
+)
+
+// notice is one alert change to send
+type notice struct {
+	Event string `json:"event"`
+	Alert
+	At time.Time `json:"at"`
+}
+
+// queueSize bounds the notices waiting to be sent
+const queueSize = 64
+
+// sendTimeout bounds each webhook post and text
+const sendTimeout = 30 * time.Second
+
+// Alerts checks the rules against a manager
+type Alerts struct {
+	cfg    Config
+	om     *manager.OrderManager
+	sms    notify.Provider // Optional; texts Phones
+	client *http.Client
+
+	mu     sync.Mutex
+	active map[string]*Alert // By rule name
+
+	notices chan notice
+	done    chan struct{}
+}
+
+// New checks cfg's rules against om, texting through sms when it is not nil
+// and posting the webhook through hc, or http.DefaultClient when it is nil.
+// Call Run to check on schedule and Close to stop.
+func New(cfg Config, om *manager.OrderManager, sms notify.Provider, hc *http.Client) *Alerts {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	a := &Alerts{
+		cfg:     cfg,
+		om:      om,
+		sms:     sms,
+		client:  hc,
+		active:  make(map[string]*Alert),
+		notices: make(chan notice, queueSize),
+		done:    make(chan struct{}),
+	}
+	go a.send()
+	return a
+}
+
+// Run checks the rules every Interval until ctx is cancelled
+func (a *Alerts) Run(ctx context.Context) {
+	if len(a.cfg.Rules) == 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(a.cfg.Interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := a.Check(ctx, now); err != nil {
+				log.Printf("alerts: %v", err)
+			}
+		}
+	}
+}
+
+// Close stops sending and waits for queued notices to go out
+func (a *Alerts) Close() {
+	close(a.notices)
+	<-a.done
+}
+
+// Active returns the alerts firing, in rule order, then those of paused
+// stations by station
+func (a *Alerts) Active() []Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := []Alert{}
+	for _, r := range a.cfg.Rules {
+		if alert, ok := a.active[r.name()]; ok {
+			list = append(list, *alert)
+		}
+	}
+	var paused []Alert
+	for _, alert := range a.active {
+		if alert.Metric == MetricStationPaused {
+			paused = append(paused, *alert)
+		}
+	}
+	slices.SortFunc(paused, func(x, y Alert) int { return strings.Compare(x.Station, y.Station) })
+	return append(list, paused...)
+}
+
+// Check evaluates every rule as of now, sending a notice for each alert that
+// starts firing or resolves
+func (a *Alerts) Check(ctx context.Context, now time.Time) error {
+	waiting, prepared, err := a.om.ListOrders(ctx)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, r := range a.cfg.Rules {
+		value := measure(r, waiting, prepared, now)
+		name := r.name()
+		alert, firing := a.active[name]
+		switch {
+		case value > r.threshold() && firing:
+			alert.Value = value
+			alert.Message = message(r, value)
+		case value > r.threshold():
+			alert = &Alert{Rule: name, Metric: r.Metric, Station: r.Station, Value: value, Threshold: r.threshold(),
+				Message: message(r, value), Since: now}
+			a.active[name] = alert
+			a.notify(notice{Event: EventFiring, Alert: *alert, At: now})
+		case firing:
+			delete(a.active, name)
+			alert.Value = value
+			alert.Message = message(r, value)
+			a.notify(notice{Event: EventResolved, Alert: *alert, At: now})
+		}
+	}
+	a.checkStations(now)
+	return nil
+}
+
+// checkStations fires an alert for each station paused and resolves those
+// of stations resumed; a.mu must be held
+func (a *Alerts) checkStations(now time.Time) {
+	paused := make(map[string]bool)
+	for _, p := range a.om.PausedStations() {
+		name := MetricStationPaused + ":" + p.Station
+		paused[name] = true
+		value := int(now.Sub(p.Since).Seconds())
+		msg := "station paused"
+		if p.Reason != "" {
+			msg += ": " + p.Reason
+		}
+		if p.Station != "" {
+			msg = p.Station + ": " + msg
+		}
+		if alert, firing := a.active[name]; firing {
+			alert.Value, alert.Message = value, msg
+			continue
+		}
+		alert := &Alert{Rule: name, Metric: MetricStationPaused, Station: p.Station, Value: value, Message: msg, Since: p.Since}
+		a.active[name] = alert
+		a.notify(notice{Event: EventFiring, Alert: *alert, At: now})
+	}
+	for name, alert := range a.active {
+		if alert.Metric == MetricStationPaused && !paused[name] {
+			delete(a.active, name)
+			alert.Value = int(now.Sub(alert.Since).Seconds())
+			a.notify(notice{Event: EventResolved, Alert: *alert, At: now})
+		}
+	}
+}
+
+// measure works out r's metric from the listing
+func measure(r Rule, waiting, prepared []*queue.Token, now time.Time) int {
+	at := func(t *queue.Token) bool { return r.Station == "" || t.Station == r.Station }
+	n := 0
+	switch r.Metric {
+	case MetricQueueDepth:
+		for _, t := range waiting {
+			if at(t) {
+				n++
+			}
+		}
+	case MetricUncollected:
+		for _, t := range prepared {
+			if at(t) {
+				n++
+			}
+		}
+	case MetricOldestWaiting:
+		for _, t := range waiting {
+			if !at(t) {
+				continue
+			}
+			// A pre-order counts from its release
+			since := t.Timestamp
+			if t.ReleaseAt != nil && t.ReleaseAt.After(since) {
+				since = *t.ReleaseAt
+			}
+			n = max(n, int(now.Sub(since).Seconds()))
+		}
+	}
+	return n
+}
+
+// message describes the metric's value against the rule
+func message(r Rule, value int) string {
+	var msg string
+	switch r.Metric {
+	case MetricQueueDepth:
+		msg = fmt.Sprintf("%d orders waiting, above %d", value, r.Above)
+	case MetricUncollected:
+		msg = fmt.Sprintf("%d prepared orders not picked up, above %d", value, r.Above)
+	case MetricOldestWaiting:
+		msg = fmt.Sprintf("oldest order waiting %s, above %s",
+			(time.Duration(value) * time.Second).String(), time.Duration(r.Age).String())
+	}
+	if r.Station != "" {
+		msg = r.Station + ": " + msg
+	}
+	return msg
+}
+
+// notify queues n for sending without holding up the check
+func (a *Alerts) notify(n notice) {
+	log.Printf("alerts: %s %s: %s", n.Event, n.Rule, n.Message)
+	if a.cfg.Webhook == "" && (a.sms == nil || len(a.cfg.Phones) == 0) {
+		return
+	}
+	select {
+	case a.notices <- n:
+	default:
+		log.Printf("alerts: queue full, dropping %s notice for %s", n.Event, n.Rule)
+	}
+}
+
+func (a *Alerts) send() {
+	defer close(a.done)
+	for n := range a.notices {
+		if a.cfg.Webhook != "" {
+			if err := a.post(n); err != nil {
+				log.Printf("alerts: webhook for %s: %v", n.Rule, err)
+			}
+		}
+		if a.sms == nil {
+			continue
+		}
+		subject := "Kitchen alert"
+		if n.Event == EventResolved {
+			subject = "Kitchen alert resolved"
+		}
+		for _, phone := range a.cfg.Phones {
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			if err := a.sms.Send(ctx, notify.Message{To: phone, Subject: subject, Body: subject + ": " + n.Message}); err != nil {
+				log.Printf("alerts: text for %s: %v", n.Rule, err)
+			}
+			cancel()
+		}
+	}
+}
+
+// post sends n to the webhook
+func (a *Alerts) post(n notice) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.cfg.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(a.cfg.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,161 @@
+package alerts
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/notify"
+)
+
+// texts records the messages sent through it
+type texts struct {
+	mu   sync.Mutex
+	sent []notify.Message
+}
+
+func (t *texts) Name() string { return "test" }
+
+func (t *texts) Send(_ context.Context, m notify.Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sent = append(t.sent, m)
+	return nil
+}
+
+func TestAlerts(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var events []notice
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(body)
+		if r.Header.Get(SignatureHeader) != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("bad signature %q", r.Header.Get(SignatureHeader))
+		}
+		var n notice
+		if err := json.Unmarshal(body, &n); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		events = append(events, n)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	cfg := DefaultConfig()
+	cfg.Rules = []Rule{
+		{Metric: MetricQueueDepth, Above: 2},
+		{Name: "slow grill", Metric: MetricOldestWaiting, Station: "grill", Age: config.Duration(10 * time.Minute)},
+		{Metric: MetricUncollected, Above: 0},
+	}
+	cfg.Webhook, cfg.Secret, cfg.Phones = hook.URL, "s3cret", []string{"+15550100"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	om := manager.New(manager.DefaultConfig())
+	sms := &texts{}
+	a := New(cfg, om, sms, nil)
+
+	for _, item := range []string{"tea", "soup", "cake"} {
+		if _, err := om.PlaceOrder(ctx, manager.NewOrder{Item: item, Priority: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := om.PlaceOrder(ctx, manager.NewOrder{Item: "steak", Priority: 2, Station: "grill"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Check(ctx, time.Now().Add(11*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	active := a.Active()
+	if len(active) != 2 || active[0].Rule != MetricQueueDepth || active[0].Value != 4 || active[1].Rule != "slow grill" {
+		t.Fatalf("active = %+v", active)
+	}
+	if !strings.HasPrefix(active[1].Message, "grill: oldest order waiting") {
+		t.Errorf("message = %q", active[1].Message)
+	}
+
+	for range 2 {
+		if _, err := om.PrepareOrder(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Check(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	active = a.Active()
+	if len(active) != 1 || active[0].Rule != MetricUncollected || active[0].Value != 2 {
+		t.Fatalf("active after preparing = %+v", active)
+	}
+
+	a.Close()
+	var got []string
+	for _, n := range events {
+		got = append(got, n.Event+" "+n.Rule)
+	}
+	want := "alert.firing queue_depth,alert.firing slow grill,alert.resolved queue_depth,alert.resolved slow grill,alert.firing uncollected"
+	if strings.Join(got, ",") != want {
+		t.Errorf("webhook got %v, want %s", got, want)
+	}
+	if len(sms.sent) != 5 || sms.sent[0].To != "+15550100" {
+		t.Errorf("texts = %+v", sms.sent)
+	}
+}
+
+func TestStationPaused(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.Rules = []Rule{{Metric: MetricQueueDepth, Above: 5}}
+	om := manager.New(manager.DefaultConfig())
+	a := New(cfg, om, nil, nil)
+	defer a.Close()
+
+	p, _, err := om.PauseStation(ctx, "fryer", "fryer broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Check(ctx, p.Since.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	active := a.Active()
+	if len(active) != 1 || active[0].Metric != MetricStationPaused || active[0].Station != "fryer" ||
+		active[0].Value != 60 || active[0].Message != "fryer: station paused: fryer broken" {
+		t.Fatalf("active = %+v", active)
+	}
+	if _, err := om.ResumeStation(ctx, "fryer"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Check(ctx, p.Since.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if active := a.Active(); len(active) != 0 {
+		t.Errorf("active after resuming = %+v", active)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, cfg := range []Config{
+		{Interval: 1, Rules: []Rule{{Metric: "heat"}}},
+		{Interval: 1, Rules: []Rule{{Metric: MetricOldestWaiting}}},
+		{Interval: 1, Rules: []Rule{{Metric: MetricQueueDepth, Above: -1}}},
+		{Interval: 1, Rules: []Rule{{Metric: MetricQueueDepth}, {Metric: MetricQueueDepth, Above: 5}}},
+		{Rules: []Rule{{Metric: MetricQueueDepth}}},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+// Package analytics summarises order activity for owners: volume by hour and
+// day, preparation times, item popularity, revenue, the channels orders come
+// through and how often wait-time targets are met.
+package analytics
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"awesomeProject/pkg/money"
+	"awesomeProject/pkg/queue"
+)
+
+// Bucket counts orders placed in one hour of the day or on one date
+type Bucket struct {
+	Key    string `json:"key"` // "00".."23" for hours, "2006-01-02" for days
+	Orders int    `json:"orders"`
+}
+
+// ItemCount is the number of orders and units sold for one item, and how
+// many of its orders came back to be remade
+type ItemCount struct {
+	Item     string `json:"item"`
+	Orders   int    `json:"orders"`
+	Quantity int    `json:"quantity"`
+	Remakes  int    `json:"remakes"`
+}
+
+// SLAClass is how well the orders of one priority met its wait-time target.
+// Orders count once they are prepared or have missed the target.
+type SLAClass struct {
+	Priority   int     `json:"priority"`
+	Orders     int     `json:"orders"`
+	Met        int     `json:"met"`
+	Breached   int     `json:"breached"`
+	Attainment float64 `json:"attainment"` // Percentage met
+}
+
+// ReasonCount is the number of orders voided or remade for one reason code
+type ReasonCount struct {
+	Reason string `json:"reason"`
+	Orders int    `json:"orders"`
+}
+
+// Revenue adds up the totals of the orders charged in one currency, in its
+// minor units. Cancelled and voided orders are left out, and refunds counted
+// apart.
+type Revenue struct {
+	Currency string `json:"currency"`
+	Orders   int    `json:"orders"`
+	Subtotal int64  `json:"subtotal"`
+	Tax      int64  `json:"tax"`
+	Total    int64  `json:"total"`
+	Refunded int64  `json:"refunded"` // Totals of the voided orders refunded
+}
+
+// ChannelCount is how many orders came through one channel, how they went
+// and what they took
+type ChannelCount struct {
+	Channel        string    `json:"channel"` // Empty for orders placed without one
+	Orders         int       `json:"orders"`
+	Share          float64   `json:"share"` // Percentage of all orders
+	Cancelled      int       `json:"cancelled"`
+	AvgPrepSeconds float64   `json:"avgPrepSeconds"`
+	Revenue        []Revenue `json:"revenue"` // By currency, for priced orders
+}
+
+// Report holds the statistics for orders placed within [From, To)
+type Report struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Orders    int       `json:"orders"`
+	Pending   int       `json:"pending"`
+	Prepared  int       `json:"prepared"` // Every order that finished preparing
+	PickedUp  int       `json:"pickedUp"`
+	Expired   int       `json:"expired"`
+	Cancelled int       `json:"cancelled"`
+	Voided    int       `json:"voided"`   // Voided orders are not counted as picked up, expired or cancelled
+	Refunded  int       `json:"refunded"` // Voided orders whose payment was refunded
+
+	// Remakes are orders placed to remake one that came back. They are
+	// counted only here and in RemakeReasons and the items' Remakes, not
+	// as orders or in the times and buckets.
+	Remakes int `json:"remakes"`
+
+	// Time from order to prepared, over prepared orders
+	AvgPrepSeconds float64 `json:"avgPrepSeconds"`
+	P95PrepSeconds float64 `json:"p95PrepSeconds"`
+
+	ByHour   []Bucket    `json:"byHour"`   // Hour of day, for spotting peaks
+	ByDay    []Bucket    `json:"byDay"`    // Calendar day
+	PeakHour string      `json:"peakHour"` // Busiest hour of day, empty without orders
+	Items    []ItemCount `json:"items"`    // Most ordered first
+	SLA      []SLAClass  `json:"sla"`      // By priority, for priorities with a target
+	Revenue  []Revenue   `json:"revenue"`  // By currency, for priced orders
+
+	Channels []ChannelCount `json:"channels"` // Where orders were placed, most orders first
+
+	VoidReasons   []ReasonCount `json:"voidReasons"`   // Most used first
+	RemakeReasons []ReasonCount `json:"remakeReasons"` // Most used first
+}
+
+// Compute builds a Report from the tokens placed within [from, to),
+// bucketing times in loc
+func Compute(tokens []*queue.Token, from, to time.Time, loc *time.Location) Report {
+	r := Report{From: from, To: to, ByHour: make([]Bucket, 24)}
+	for h := range r.ByHour {
+		r.ByHour[h].Key = twoDigits(h)
+	}
+
+	days := make(map[string]int)
+	items := make(map[string]*ItemCount)
+	var prep []float64
+	sla := make(map[int]*SLAClass)
+	reasons := make(map[string]int)
+	remakes := make(map[string]int)
+	revenue := make(map[string]*Revenue)
+	type channelTally struct {
+		ChannelCount
+		prep    []float64
+		revenue map[string]*Revenue
+	}
+	channels := make(map[string]*channelTally)
+	item := func(name string) *ItemCount {
+		ic, ok := items[name]
+		if !ok {
+			ic = &ItemCount{Item: name}
+			items[name] = ic
+		}
+		return ic
+	}
+
+	for _, t := range tokens {
+		if t.Timestamp.Before(from) || !t.Timestamp.Before(to) {
+			continue
+		}
+		if t.RemakeOf != "" {
+			r.Remakes++
+			remakes[t.RemakeReason]++
+			item(t.Item).Remakes++
+			continue
+		}
+		r.Orders++
+		ch, ok := channels[t.Channel]
+		if !ok {
+			ch = &channelTally{ChannelCount: ChannelCount{Channel: t.Channel}, revenue: make(map[string]*Revenue)}
+			channels[t.Channel] = ch
+		}
+		ch.Orders++
+		switch t.Status {
+		case queue.StatusCancelled:
+			r.Cancelled++
+			ch.Cancelled++
+		case queue.StatusScheduled, queue.StatusAwaitingPayment, queue.StatusWaitlisted, queue.StatusBlocked, queue.StatusOnHold, queue.StatusPreparing, queue.StatusInProgress:
+			r.Pending++
+		case queue.StatusPickedUp:
+			r.PickedUp++
+		case queue.StatusExpired:
+			r.Expired++
+		case queue.StatusVoided:
+			r.Voided++
+			if t.Void != nil {
+				reasons[t.Void.Reason]++
+				if t.Void.Refund {
+					r.Refunded++
+				}
+			}
+		}
+		if t.PreparedAt != nil {
+			r.Prepared++
+			prep = append(prep, t.PreparedAt.Sub(t.Timestamp).Seconds())
+			ch.prep = append(ch.prep, t.PreparedAt.Sub(t.Timestamp).Seconds())
+		}
+
+		local := t.Timestamp.In(loc)
+		r.ByHour[local.Hour()].Orders++
+		days[local.Format("2006-01-02")]++
+
+		ic := item(t.Item)
+		ic.Orders++
+		ic.Quantity += t.Quantity
+
+		addRevenue(revenue, t)
+		addRevenue(ch.revenue, t)
+
+		if t.SLA != nil {
+			met := t.SLA.Met(t)
+			if !met && t.SLA.BreachedAt == nil {
+				continue // Not due yet, or cancelled before it was
+			}
+			c, ok := sla[t.SLA.Priority]
+			if !ok {
+				c = &SLAClass{Priority: t.SLA.Priority}
+				sla[t.SLA.Priority] = c
+			}
+			c.Orders++
+			if met {
+				c.Met++
+			} else {
+				c.Breached++
+			}
+		}
+	}
+
+	r.AvgPrepSeconds, r.P95PrepSeconds = mean(prep), percentile(prep, 95)
+
+	peak := -1
+	for h, b := range r.ByHour {
+		if b.Orders > 0 && (peak < 0 || b.Orders > r.ByHour[peak].Orders) {
+			peak = h
+		}
+	}
+	if peak >= 0 {
+		r.PeakHour = r.ByHour[peak].Key
+	}
+
+	r.ByDay = make([]Bucket, 0, len(days))
+	for day, n := range days {
+		r.ByDay = append(r.ByDay, Bucket{Key: day, Orders: n})
+	}
+	sort.Slice(r.ByDay, func(i, j int) bool { return r.ByDay[i].Key < r.ByDay[j].Key })
+
+	r.Items = make([]ItemCount, 0, len(items))
+	for _, ic := range items {
+		r.Items = append(r.Items, *ic)
+	}
+	sort.Slice(r.Items, func(i, j int) bool {
+		if r.Items[i].Orders != r.Items[j].Orders {
+			return r.Items[i].Orders > r.Items[j].Orders
+		}
+		return r.Items[i].Item < r.Items[j].Item
+	})
+
+	r.SLA = make([]SLAClass, 0, len(sla))
+	for _, c := range sla {
+		c.Attainment = math.Round(float64(c.Met)/float64(c.Orders)*1000) / 10
+		r.SLA = append(r.SLA, *c)
+	}
+	sort.Slice(r.SLA, func(i, j int) bool { return r.SLA[i].Priority < r.SLA[j].Priority })
+
+	r.Revenue = revenueList(revenue)
+
+	r.Channels = make([]ChannelCount, 0, len(channels))
+	for _, ch := range channels {
+		ch.Share = math.Round(float64(ch.Orders)/float64(r.Orders)*1000) / 10
+		ch.AvgPrepSeconds = mean(ch.prep)
+		ch.Revenue = revenueList(ch.revenue)
+		r.Channels = append(r.Channels, ch.ChannelCount)
+	}
+	sort.Slice(r.Channels, func(i, j int) bool {
+		if r.Channels[i].Orders != r.Channels[j].Orders {
+			return r.Channels[i].Orders > r.Channels[j].Orders
+		}
+		return r.Channels[i].Channel < r.Channels[j].Channel
+	})
+
+	r.VoidReasons = reasonCounts(reasons)
+	r.RemakeReasons = reasonCounts(remakes)
+	return r
+}
+
+// addRevenue adds t's total to the revenue of its currency, if it is priced
+func addRevenue(revenue map[string]*Revenue, t *queue.Token) {
+	if t.Total == nil {
+		return
+	}
+	rv, ok := revenue[t.Total.Currency]
+	if !ok {
+		rv = &Revenue{Currency: t.Total.Currency}
+		revenue[t.Total.Currency] = rv
+	}
+	switch {
+	case t.Status == queue.StatusVoided:
+		if t.Void != nil && t.Void.Refund {
+			rv.Refunded += t.Total.Total
+		}
+	case t.Status != queue.StatusCancelled:
+		rv.Orders++
+		rv.Subtotal += t.Total.Subtotal
+		rv.Tax += t.Total.Tax
+		rv.Total += t.Total.Total
+	}
+}
+
+// revenueList lists the revenue of each currency, by currency code
+func revenueList(revenue map[string]*Revenue) []Revenue {
+	list := make([]Revenue, 0, len(revenue))
+	for _, rv := range revenue {
+		list = append(list, *rv)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Currency < list[j].Currency })
+	return list
+}
+
+// reasonCounts lists the counts of each reason, most used first
+func reasonCounts(reasons map[string]int) []ReasonCount {
+	counts := make([]ReasonCount, 0, len(reasons))
+	for reason, n := range reasons {
+		counts = append(counts, ReasonCount{Reason: reason, Orders: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Orders != counts[j].Orders {
+			return counts[i].Orders > counts[j].Orders
+		}
+		return counts[i].Reason < counts[j].Reason
+	})
+	return counts
+}
+
+// WriteCSV writes r as metric,key,value rows
+func WriteCSV(w io.Writer, r Report) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{
+		{"metric", "key", "value"},
+		{"range", "from", r.From.Format(time.RFC3339)},
+		{"range", "to", r.To.Format(time.RFC3339)},
+		{"orders", "total", strconv.Itoa(r.Orders)},
+		{"orders", "pending", strconv.Itoa(r.Pending)},
+		{"orders", "prepared", strconv.Itoa(r.Prepared)},
+		{"orders", "picked_up", strconv.Itoa(r.PickedUp)},
+		{"orders", "expired", strconv.Itoa(r.Expired)},
+		{"orders", "cancelled", strconv.Itoa(r.Cancelled)},
+		{"orders", "voided", strconv.Itoa(r.Voided)},
+		{"orders", "refunded", strconv.Itoa(r.Refunded)},
+		{"orders", "remakes", strconv.Itoa(r.Remakes)},
+		{"prep_seconds", "avg", formatFloat(r.AvgPrepSeconds)},
+		{"prep_seconds", "p95", formatFloat(r.P95PrepSeconds)},
+		{"peak_hour", "", r.PeakHour},
+	}
+	for _, b := range r.ByHour {
+		rows = append(rows, []string{"hour", b.Key, strconv.Itoa(b.Orders)})
+	}
+	for _, b := range r.ByDay {
+		rows = append(rows, []string{"day", b.Key, strconv.Itoa(b.Orders)})
+	}
+	for _, ic := range r.Items {
+		rows = append(rows,
+			[]string{"item_orders", ic.Item, strconv.Itoa(ic.Orders)},
+			[]string{"item_quantity", ic.Item, strconv.Itoa(ic.Quantity)},
+			[]string{"item_remakes", ic.Item, strconv.Itoa(ic.Remakes)},
+		)
+	}
+	for _, c := range r.SLA {
+		p := strconv.Itoa(c.Priority)
+		rows = append(rows,
+			[]string{"sla_met", p, strconv.Itoa(c.Met)},
+			[]string{"sla_breached", p, strconv.Itoa(c.Breached)},
+			[]string{"sla_attainment", p, formatFloat(c.Attainment)},
+		)
+	}
+	for _, rv := range r.Revenue {
+		rows = append(rows,
+			[]string{"revenue_orders", rv.Currency, strconv.Itoa(rv.Orders)},
+			[]string{"revenue_subtotal", rv.Currency, money.Decimal(rv.Subtotal, rv.Currency)},
+			[]string{"revenue_tax", rv.Currency, money.Decimal(rv.Tax, rv.Currency)},
+			[]string{"revenue_total", rv.Currency, money.Decimal(rv.Total, rv.Currency)},
+			[]string{"revenue_refunded", rv.Currency, money.Decimal(rv.Refunded, rv.Currency)},
+		)
+	}
+	for _, ch := range r.Channels {
+		rows = append(rows,
+			[]string{"channel_orders", ch.Channel, strconv.Itoa(ch.Orders)},
+			[]string{"channel_share", ch.Channel, formatFloat(ch.Share)},
+			[]string{"channel_cancelled", ch.Channel, strconv.Itoa(ch.Cancelled)},
+			[]string{"channel_avg_prep_seconds", ch.Channel, formatFloat(ch.AvgPrepSeconds)},
+		)
+		for _, rv := range ch.Revenue {
+			rows = append(rows, []string{"channel_revenue_total", ch.Channel + ":" + rv.Currency, money.Decimal(rv.Total, rv.Currency)})
+		}
+	}
+	for _, rc := range r.VoidReasons {
+		rows = append(rows, []string{"void_reason", rc.Reason, strconv.Itoa(rc.Orders)})
+	}
+	for _, rc := range r.RemakeReasons {
+		rows = append(rows, []string{"remake_reason", rc.Reason, strconv.Itoa(rc.Orders)})
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// percentile returns the nearest-rank p-th percentile of xs
+func percentile(xs []float64, p float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func twoDigits(n int) string {
+	if n < 10 {
+		return "0" + strconv.Itoa(n)
+	}
+	return strconv.Itoa(n)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 1, 64)
+}
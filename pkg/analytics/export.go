@@ -0,0 +1,116 @@
+package analytics
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"awesomeProject/pkg/money"
+	"awesomeProject/pkg/queue"
+)
+
+// ExportHeader lists the columns written by WriteOrdersCSV
+var ExportHeader = []string{
+	"id", "item", "quantity", "priority", "status",
+	"ordered_at", "prepared_at", "picked_up_at", "expired_at", "cancelled_at",
+	"preparing_seconds", "notes", "order_type", "table", "void_reason", "voided_at", "refunded",
+	"remake_of", "remake_reason", "imported_from",
+	"currency", "subtotal", "tax", "total", "channel",
+}
+
+// flushEvery is how many rows are buffered before flushing to the client
+const flushEvery = 100
+
+// WriteOrdersCSV writes one row per token, flushing as it goes so large
+// exports stream instead of being held in memory. It stops with ctx's error
+// once ctx is done, such as when the client goes away.
+func WriteOrdersCSV(ctx context.Context, w io.Writer, tokens []*queue.Token) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(ExportHeader); err != nil {
+		return err
+	}
+	for i, t := range tokens {
+		if err := cw.Write(exportRow(t)); err != nil {
+			return err
+		}
+		if i%flushEvery == flushEvery-1 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportRow(t *queue.Token) []string {
+	preparing := ""
+	if end := finishedAt(t); end != nil {
+		preparing = formatFloat(end.Sub(t.Timestamp).Seconds())
+	}
+	table := ""
+	if t.Table != 0 {
+		table = strconv.Itoa(t.Table)
+	}
+	var reason, refunded string
+	var voidedAt *time.Time
+	if t.Void != nil {
+		reason, voidedAt, refunded = t.Void.Reason, &t.Void.At, strconv.FormatBool(t.Void.Refund)
+	}
+	// Amounts in whole units, as spreadsheets sum them
+	var currency, subtotal, tax, total string
+	if t.Total != nil {
+		currency = t.Total.Currency
+		subtotal = money.Decimal(t.Total.Subtotal, currency)
+		tax = money.Decimal(t.Total.Tax, currency)
+		total = money.Decimal(t.Total.Total, currency)
+	}
+	return []string{
+		t.ID,
+		t.Item,
+		strconv.Itoa(t.Quantity),
+		strconv.Itoa(t.Priority),
+		t.Status,
+		t.Timestamp.Format(time.RFC3339),
+		formatTime(t.PreparedAt),
+		formatTime(t.PickedUpAt),
+		formatTime(t.ExpiredAt),
+		formatTime(t.CancelledAt),
+		preparing,
+		t.Notes,
+		t.OrderType,
+		table,
+		reason,
+		formatTime(voidedAt),
+		refunded,
+		t.RemakeOf,
+		t.RemakeReason,
+		t.ImportedFrom,
+		currency,
+		subtotal,
+		tax,
+		total,
+		t.Channel,
+	}
+}
+
+// finishedAt is when the token left the queue, or nil if it is still waiting
+func finishedAt(t *queue.Token) *time.Time {
+	if t.PreparedAt != nil {
+		return t.PreparedAt
+	}
+	return t.CancelledAt
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
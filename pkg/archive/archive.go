@@ -0,0 +1,172 @@
+// Package archive stores the orders of each closed business day on disk: one
+// JSON line per order plus the day's summary report. They are read back for
+// reports spanning several days.
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/analytics"
+	"awesomeProject/pkg/fault"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// Config selects the archive directory; an empty Dir disables archiving
+type Config struct {
+	Dir string `json:"dir"`
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{Dir: "archive"}
+}
+
+// Store writes closed days to a directory. It implements manager.Archiver.
+type Store struct {
+	dir string
+}
+
+// Open creates the archive directory if needed
+func Open(cfg Config) (*Store, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: cfg.Dir}, nil
+}
+
+// Summary is the report for a closed day
+func Summary(day manager.DayClose) analytics.Report {
+	return analytics.Compute(day.Orders, day.From, day.To, time.Local)
+}
+
+// Archive writes day's orders to <close time>-orders.jsonl and its summary
+// to <close time>-summary.json, named after the close time
+func (s *Store) Archive(day manager.DayClose) error {
+	stamp := day.To.Local().Format("20060102-150405")
+
+	var orders bytes.Buffer
+	enc := json.NewEncoder(&orders)
+	for _, t := range day.Orders {
+		if err := enc.Encode(t); err != nil {
+			return err
+		}
+	}
+	if err := s.write(stamp+"-orders.jsonl", orders.Bytes()); err != nil {
+		return err
+	}
+
+	summary, err := json.MarshalIndent(Summary(day), "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.write(stamp+"-summary.json", append(summary, '\n'))
+}
+
+// Orders returns the archived orders placed within [from, to), from the
+// days closed since from
+func (s *Store) Orders(from, to time.Time) ([]*queue.Token, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var orders []*queue.Token
+	for _, e := range entries {
+		stamp, ok := strings.CutSuffix(e.Name(), "-orders.jsonl")
+		if !ok {
+			continue
+		}
+		closed, err := time.ParseInLocation("20060102-150405", stamp, time.Local)
+		if err != nil || closed.Before(from) {
+			continue // Not ours, or every order in it is too old
+		}
+		f, err := os.Open(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(f)
+		for {
+			var t queue.Token
+			if err := dec.Decode(&t); err == io.EOF {
+				break
+			} else if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("archive %s: %w", e.Name(), err)
+			}
+			if !t.Timestamp.Before(from) && t.Timestamp.Before(to) {
+				orders = append(orders, &t)
+			}
+		}
+		f.Close()
+	}
+	return orders, nil
+}
+
+// Scrub erases the customer's personal data, as Token.Scrub does, from the
+// archived orders match picks, rewriting the days that held any. It
+// returns the IDs of those orders. The summaries hold no personal data.
+func (s *Store) Scrub(match func(*queue.Token) bool) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), "-orders.jsonl") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return ids, err
+		}
+		var out bytes.Buffer
+		enc := json.NewEncoder(&out)
+		dec := json.NewDecoder(bytes.NewReader(data))
+		changed := false
+		for {
+			var t queue.Token
+			if err := dec.Decode(&t); err == io.EOF {
+				break
+			} else if err != nil {
+				return ids, fmt.Errorf("archive %s: %w", e.Name(), err)
+			}
+			if match(&t) && t.Scrub() {
+				ids = append(ids, t.ID)
+				changed = true
+			}
+			if err := enc.Encode(&t); err != nil {
+				return ids, err
+			}
+		}
+		if changed {
+			if err := s.write(e.Name(), out.Bytes()); err != nil {
+				return ids, err
+			}
+		}
+	}
+	return ids, nil
+}
+
+// write replaces name with data, so a crash never leaves a partial file
+func (s *Store) write(name string, data []byte) error {
+	if err := fault.Write(fault.StoreArchive); err != nil {
+		return fmt.Errorf("archive %s: %w", name, err)
+	}
+	path := filepath.Join(s.dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("archive %s: %w", name, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("archive %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Package attach stores the files attached to orders, such as a photo of a
+// custom cake design, on local disk or in an S3-compatible bucket. The
+// orders record what is attached to them; this package only keeps the
+// bytes, under the order's ID and the attachment's.
+package attach
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"slices"
+	"strings"
+)
+
+// ErrNotFound is returned for files the store does not hold
+var ErrNotFound = errors.New("attachment file not found")
+
+// Config selects where attachments are kept and what is accepted. Set Dir or
+// S3 to enable attachments.
+type Config struct {
+	Dir string    `json:"dir"` // Directory files are kept under
+	S3  *S3Config `json:"s3"`  // Bucket files are kept in, instead of Dir
+
+	MaxSize     int64    `json:"maxSize"`     // Bytes per file
+	MaxPerOrder int      `json:"maxPerOrder"` // Files per order
+	Types       []string `json:"types"`       // Content types accepted, as detected from the file itself
+}
+
+// DefaultConfig returns the settings used when nothing is configured:
+// attachments off, and images and PDFs of up to 5 MiB once they are on
+func DefaultConfig() Config {
+	return Config{
+		MaxSize:     5 << 20,
+		MaxPerOrder: 5,
+		Types:       []string{"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf"},
+	}
+}
+
+// Enabled reports whether a store is configured
+func (c Config) Enabled() bool {
+	return c.Dir != "" || c.S3 != nil
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	switch {
+	case c.Dir != "" && c.S3 != nil:
+		return errors.New("attachments: set dir or s3, not both")
+	case c.MaxSize <= 0 || c.MaxPerOrder <= 0:
+		return errors.New("attachments: maxSize and maxPerOrder must be positive")
+	case len(c.Types) == 0:
+		return errors.New("attachments: types must list at least one content type")
+	}
+	for _, t := range c.Types {
+		if _, _, err := mime.ParseMediaType(t); err != nil {
+			return fmt.Errorf("attachments: content type %q: %w", t, err)
+		}
+	}
+	if c.S3 != nil {
+		return c.S3.validate()
+	}
+	return nil
+}
+
+// backend keeps files by key
+type backend interface {
+	put(ctx context.Context, key, contentType string, data []byte) error
+	get(ctx context.Context, key string) (io.ReadCloser, error)
+	delete(ctx context.Context, key string) error
+}
+
+// Store keeps attachment files
+type Store struct {
+	cfg Config
+	b   backend
+}
+
+// New returns the store cfg selects, which must be enabled and valid
+func New(cfg Config) (*Store, error) {
+	s := &Store{cfg: cfg}
+	switch {
+	case cfg.S3 != nil:
+		s.b = newS3(*cfg.S3)
+	case cfg.Dir != "":
+		b, err := newDisk(cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		s.b = b
+	default:
+		return nil, errors.New("attachments: no dir or s3 configured")
+	}
+	return s, nil
+}
+
+// MaxSize is the largest file accepted, in bytes
+func (s *Store) MaxSize() int64 { return s.cfg.MaxSize }
+
+// MaxPerOrder is how many files an order may have
+func (s *Store) MaxPerOrder() int { return s.cfg.MaxPerOrder }
+
+// Accepts reports whether files of contentType may be attached
+func (s *Store) Accepts(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && slices.Contains(s.cfg.Types, mt)
+}
+
+// Put stores data as an attachment of the order
+func (s *Store) Put(ctx context.Context, orderID, attachmentID, contentType string, data []byte) error {
+	key, err := fileKey(orderID, attachmentID)
+	if err != nil {
+		return err
+	}
+	return s.b.put(ctx, key, contentType, data)
+}
+
+// Open returns the contents of an attachment, or ErrNotFound
+func (s *Store) Open(ctx context.Context, orderID, attachmentID string) (io.ReadCloser, error) {
+	key, err := fileKey(orderID, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	return s.b.get(ctx, key)
+}
+
+// Delete removes an attachment; removing one already gone is not an error
+func (s *Store) Delete(ctx context.Context, orderID, attachmentID string) error {
+	key, err := fileKey(orderID, attachmentID)
+	if err != nil {
+		return err
+	}
+	return s.b.delete(ctx, key)
+}
+
+// fileKey is where an attachment is kept, refusing IDs that could reach
+// outside the order's own directory or prefix
+func fileKey(orderID, attachmentID string) (string, error) {
+	for _, id := range []string{orderID, attachmentID} {
+		if id == "" || strings.ContainsFunc(id, func(r rune) bool {
+			return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-' || r == '_')
+		}) {
+			return "", fmt.Errorf("attachments: invalid ID %q", id)
+		}
+	}
+	return orderID + "/" + attachmentID, nil
+}
@@ -0,0 +1,69 @@
+package attach
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// disk keeps each file at dir/<order>/<attachment>
+type disk struct {
+	dir string
+}
+
+func newDisk(dir string) (*disk, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("attachments: %w", err)
+	}
+	return &disk{dir: dir}, nil
+}
+
+// put writes the file beside its final name and renames it into place, so
+// a crash never leaves half a file to be served
+func (d *disk) put(_ context.Context, key, _ string, data []byte) error {
+	path := filepath.Join(d.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("attachments: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("attachments: %w", err)
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("attachments: write %s: %w", key, err)
+	}
+	return nil
+}
+
+func (d *disk) get(_ context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(d.dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (d *disk) delete(_ context.Context, key string) error {
+	path := filepath.Join(d.dir, filepath.FromSlash(key))
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("attachments: %w", err)
+	}
+	// The order's directory goes with its last file
+	os.Remove(filepath.Dir(path))
+	return nil
+}
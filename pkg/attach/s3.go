@@ -0,0 +1,204 @@
+package attach
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/config"
+)
+
+// S3Config locates a bucket on Amazon S3 or a compatible service such as
+// MinIO. Objects are addressed path-style, as endpoint/bucket/key, which
+// every such service accepts.
+type S3Config struct {
+	Endpoint  string          `json:"endpoint"` // Such as https://s3.eu-west-1.amazonaws.com or http://minio:9000
+	Region    string          `json:"region"`
+	Bucket    string          `json:"bucket"`
+	Prefix    string          `json:"prefix"` // Put before every key, such as "attachments/"
+	AccessKey string          `json:"accessKey"`
+	SecretKey string          `json:"secretKey"`
+	Timeout   config.Duration `json:"timeout"` // Per request; default 30s
+}
+
+func (c S3Config) validate() error {
+	u, err := url.Parse(c.Endpoint)
+	switch {
+	case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		return fmt.Errorf("attachments: s3 endpoint %q must be an http or https URL", c.Endpoint)
+	case c.Bucket == "":
+		return errors.New("attachments: s3 bucket is required")
+	case c.AccessKey == "" || c.SecretKey == "":
+		return errors.New("attachments: s3 accessKey and secretKey are required")
+	case c.Timeout < 0:
+		return errors.New("attachments: s3 timeout must not be negative")
+	}
+	return nil
+}
+
+// s3 keeps files as objects in a bucket, signing each request with AWS
+// Signature Version 4
+type s3 struct {
+	cfg S3Config
+	hc  *http.Client
+	now func() time.Time
+}
+
+func newS3(cfg S3Config) *s3 {
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	timeout := time.Duration(cfg.Timeout)
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &s3{cfg: cfg, hc: &http.Client{Timeout: timeout}, now: time.Now}
+}
+
+func (s *s3) put(ctx context.Context, key, contentType string, data []byte) error {
+	res, err := s.do(ctx, http.MethodPut, key, contentType, data)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (s *s3) get(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := s.do(ctx, http.MethodGet, key, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (s *s3) delete(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, key, "", nil)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if res != nil {
+		res.Body.Close()
+	}
+	return nil
+}
+
+// do sends a signed request for the object at key, returning the response
+// when it succeeded
+func (s *s3) do(ctx context.Context, method, key, contentType string, body []byte) (*http.Response, error) {
+	u := s.cfg.Endpoint + "/" + uriEncode(s.cfg.Bucket, false) + "/" + uriEncode(s.cfg.Prefix+key, false)
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, body)
+	res, err := s.hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("attachments: s3 %s: %w", method, err)
+	}
+	if res.StatusCode/100 == 2 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	detail, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return nil, fmt.Errorf("attachments: s3 %s %s: %s: %s", method, key, res.Status, bytes.TrimSpace(detail))
+}
+
+// sign adds the date, payload hash and Authorization headers, signing every
+// header set on req
+func (s *s3) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	stamp, day := now.Format("20060102T150405Z"), now.Format("20060102")
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	request := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonical.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(request))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), day)
+	for _, part := range []string{s.cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signed, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode percent-encodes s as SigV4 wants it: every byte but the
+// unreserved characters, and slashes too when encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery sorts and encodes the query parameters
+func canonicalQuery(q url.Values) string {
+	var pairs []string
+	for name, values := range q {
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
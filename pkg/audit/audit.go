@@ -0,0 +1,296 @@
+// Package audit records who did what to which order through the API: each
+// staff action, the person or key behind it and where it came from. Entries
+// are appended to a JSON-lines file and the most recent are kept in memory
+// for querying.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/fault"
+)
+
+// Actions
+const (
+	ActionPrepare   = "prepare"
+	ActionClaim     = "claim"
+	ActionModify    = "modify"
+	ActionRush      = "rush"
+	ActionCancel    = "cancel"
+	ActionRecover   = "recover"
+	ActionUnprepare = "unprepare"
+	ActionPickUp    = "pickup"
+	ActionPayment   = "payment"
+	ActionDayClose  = "day_close"
+	ActionRestore   = "restore_snapshot"
+	ActionBackup    = "backup"
+
+	ActionUnavailable = "item_unavailable"
+	ActionAvailable   = "item_available"
+	ActionFire        = "fire"
+	ActionPause       = "pause_ordering"
+	ActionResume      = "resume_ordering"
+	ActionRequeue     = "requeue"
+	ActionVoid        = "void"
+	ActionRefire      = "refire"
+	ActionDevice      = "device" // Device registered, set up, given a new key or removed
+	ActionImport      = "import" // Menu or past orders imported from a file
+
+	ActionPauseStation  = "pause_station"
+	ActionResumeStation = "resume_station"
+	ActionSetNumber     = "set_number"    // Next daily token number set by an admin
+	ActionForceStatus   = "force_status"  // Order moved to a status outside the usual transitions
+	ActionRebuildQueue  = "rebuild_queue" // The waiting queue rebuilt from the orders held
+	ActionPurge         = "privacy_purge" // A customer's personal data erased
+	ActionBranding      = "branding"      // The logo uploaded or removed
+	ActionFeature       = "feature"       // A feature flag set or reset
+)
+
+// Actions lists every action
+var Actions = []string{
+	ActionPrepare, ActionClaim, ActionModify, ActionRush, ActionCancel, ActionRecover, ActionUnprepare,
+	ActionPickUp, ActionPayment, ActionDayClose, ActionRestore, ActionBackup, ActionUnavailable, ActionAvailable,
+	ActionFire, ActionPause, ActionResume, ActionRequeue, ActionVoid, ActionRefire, ActionDevice,
+	ActionImport, ActionPauseStation, ActionResumeStation, ActionSetNumber, ActionForceStatus, ActionRebuildQueue,
+	ActionPurge, ActionBranding, ActionFeature,
+}
+
+// Config selects the audit file; an empty Path disables auditing
+type Config struct {
+	Path   string `json:"path"`
+	Retain int    `json:"retain"` // Entries kept in memory for Query
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{Retain: 10000}
+}
+
+// Entry is one recorded action
+type Entry struct {
+	Seq     uint64            `json:"seq"`
+	At      time.Time         `json:"at"`
+	Actor   string            `json:"actor"` // Staff name given, or the key or address the request came from
+	Action  string            `json:"action"`
+	OrderID string            `json:"orderId,omitempty"`
+	Detail  map[string]string `json:"detail,omitempty"` // The parameters of the action
+	IP      string            `json:"ip"`
+	APIKey  string            `json:"apiKey,omitempty"` // Fingerprint of the API key used, never the key
+}
+
+// Filter selects entries for Query. Zero fields do not filter.
+type Filter struct {
+	Actor  string
+	Action string
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+func (f Filter) match(e Entry) bool {
+	switch {
+	case f.Actor != "" && e.Actor != f.Actor:
+		return false
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case !f.From.IsZero() && e.At.Before(f.From):
+		return false
+	case !f.To.IsZero() && e.At.After(f.To):
+		return false
+	}
+	return true
+}
+
+// Log records entries to a file and answers queries over the recent ones
+type Log struct {
+	cfg     Config
+	mu      sync.Mutex
+	f       *os.File
+	seq     uint64
+	entries []Entry // The last cfg.Retain, oldest first
+}
+
+// Open opens the audit file at cfg.Path for appending, reading back the
+// entries it already holds
+func Open(cfg Config) (*Log, error) {
+	if cfg.Retain <= 0 {
+		cfg.Retain = DefaultConfig().Retain
+	}
+	l := &Log{cfg: cfg}
+	if err := l.load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	f, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	l.f = f
+	return l, nil
+}
+
+func (l *Log) load() error {
+	f, err := os.Open(l.cfg.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return fmt.Errorf("audit log line %d: %w", line, err)
+		}
+		l.seq = e.Seq
+		l.keep(e)
+	}
+	return sc.Err()
+}
+
+// keep adds e to the entries in memory, dropping the oldest past Retain; mu
+// must be held
+func (l *Log) keep(e Entry) {
+	if len(l.entries) >= l.cfg.Retain {
+		l.entries = append(l.entries[:0], l.entries[len(l.entries)-l.cfg.Retain+1:]...)
+	}
+	l.entries = append(l.entries, e)
+}
+
+// Record numbers e, stamps it when At is unset and appends it
+func (l *Log) Record(e Entry) error {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	e.At = e.At.UTC()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.Seq = l.seq + 1
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := fault.Write(fault.StoreAudit); err != nil {
+		return fmt.Errorf("append audit entry %d: %w", e.Seq, err)
+	}
+	if _, err := l.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("append audit entry %d: %w", e.Seq, err)
+	}
+	l.seq = e.Seq
+	l.keep(e)
+	return nil
+}
+
+// Query returns one page of the entries in memory matching f, newest first,
+// and the total number of matches
+func (l *Log) Query(f Filter) ([]Entry, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var page []Entry
+	total := 0
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		e := l.entries[i]
+		if !f.match(e) {
+			continue
+		}
+		if total >= f.Offset && (f.Limit <= 0 || len(page) < f.Limit) {
+			page = append(page, e)
+		}
+		total++
+	}
+	return page, total
+}
+
+// personal are the Detail keys that may hold a customer's personal data
+var personal = []string{"phone", "deviceToken", "notes", "client"}
+
+// scrub removes the personal parameters from e, reporting whether it had any
+func scrub(e *Entry) bool {
+	found := false
+	for _, key := range personal {
+		if _, ok := e.Detail[key]; ok {
+			delete(e.Detail, key)
+			found = true
+		}
+	}
+	return found
+}
+
+// Scrub removes the customer's personal data from the parameters of every
+// entry match picks, in the file and in memory, returning how many had any.
+// The rest of each entry stays, so the log still shows who did what to
+// which order.
+func (l *Log) Scrub(match func(Entry) bool) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	data, err := os.ReadFile(l.cfg.Path)
+	if err != nil {
+		return 0, err
+	}
+	var out bytes.Buffer
+	changed := 0
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return 0, fmt.Errorf("audit log line %d: %w", line, err)
+		}
+		if !match(e) || !scrub(&e) {
+			out.Write(sc.Bytes())
+			out.WriteByte('\n')
+			continue
+		}
+		changed++
+		b, err := json.Marshal(e)
+		if err != nil {
+			return 0, err
+		}
+		out.Write(append(b, '\n'))
+	}
+	if err := sc.Err(); err != nil || changed == 0 {
+		return 0, err
+	}
+
+	tmp := l.cfg.Path + ".tmp"
+	if err := os.WriteFile(tmp, out.Bytes(), 0o600); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, l.cfg.Path); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	f, err := os.OpenFile(l.cfg.Path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	l.f.Close()
+	l.f = f
+	for i := range l.entries {
+		if match(l.entries[i]) {
+			scrub(&l.entries[i])
+		}
+	}
+	return changed, nil
+}
+
+// Close closes the file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
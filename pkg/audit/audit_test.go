@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReopen(t *testing.T) {
+	cfg := Config{Path: filepath.Join(t.TempDir(), "audit.jsonl"), Retain: 3}
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i, action := range []string{ActionPrepare, ActionRush, ActionCancel, ActionPrepare} {
+		if err := l.Record(Entry{Actor: "ana", Action: action, At: start.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The oldest entry is only in the file
+	if l, err = Open(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := l.Record(Entry{Actor: "bo", Action: ActionClaim}); err != nil {
+		t.Fatal(err)
+	}
+	entries, total := l.Query(Filter{})
+	if total != 3 || entries[0].Seq != 5 || entries[2].Seq != 3 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	entries, total = l.Query(Filter{Actor: "ana", From: start.Add(2 * time.Minute), Limit: 1})
+	if total != 2 || len(entries) != 1 || entries[0].Seq != 4 {
+		t.Fatalf("filtered = %+v (%d)", entries, total)
+	}
+}
+
+func TestScrub(t *testing.T) {
+	cfg := Config{Path: filepath.Join(t.TempDir(), "audit.jsonl")}
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for _, id := range []string{"1", "2"} {
+		if err := l.Record(Entry{Actor: "ana", Action: ActionModify, OrderID: id, Detail: map[string]string{"notes": "for Sam", "quantity": "2"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := l.Scrub(func(e Entry) bool { return e.OrderID == "1" })
+	if err != nil || n != 1 {
+		t.Fatalf("scrub = %d, %v", n, err)
+	}
+	if err := l.Record(Entry{Actor: "bo", Action: ActionPurge}); err != nil {
+		t.Fatal(err)
+	}
+	check := func(l *Log) {
+		t.Helper()
+		entries, _ := l.Query(Filter{Action: ActionModify})
+		if len(entries) != 2 || entries[1].Detail["notes"] != "" || entries[1].Detail["quantity"] != "2" || entries[0].Detail["notes"] != "for Sam" {
+			t.Errorf("entries = %+v", entries)
+		}
+	}
+	check(l)
+
+	// The file is rewritten and still appended to
+	reopened, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	check(reopened)
+	if _, total := reopened.Query(Filter{}); total != 3 {
+		t.Errorf("%d entries after reopening", total)
+	}
+}
@@ -0,0 +1,307 @@
+// Package backup takes regular copies of the service's state so a lost or
+// corrupted disk does not take the day's orders with it.
+//
+// Each backup is a manager snapshot, which restores the running state on any
+// storage, and when an event log is kept a consistent copy of it too, which
+// replaces the log on restore so history survives. Backups are named after
+// the time they were taken and pruned by count and age.
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/eventlog"
+	"awesomeProject/pkg/manager"
+)
+
+// Config selects the backup directory and schedule; an empty Dir disables
+// backups
+type Config struct {
+	Dir      string          `json:"dir"`
+	Interval config.Duration `json:"interval"` // Between scheduled backups; zero only backs up on request
+
+	// Keep bounds the backups kept, newest first, and MaxAge removes those
+	// older; zero leaves either unbounded
+	Keep   int             `json:"keep"`
+	MaxAge config.Duration `json:"maxAge"`
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		Interval: config.Duration(time.Hour),
+		Keep:     48,
+	}
+}
+
+// Latest names the newest backup to Find and Restore
+const Latest = "latest"
+
+// File name parts: a backup named backup-20060102-150405 is the snapshot
+// backup-20060102-150405.snapshot.json and, when the log was kept,
+// backup-20060102-150405.events.jsonl
+const (
+	prefix      = "backup-"
+	stampLayout = "20060102-150405"
+	snapshotExt = ".snapshot.json"
+	eventsExt   = ".events.jsonl"
+	partialExt  = ".partial"
+	asideExt    = ".before-restore-" // Followed by the restore time
+)
+
+// Backup describes one backup on disk
+type Backup struct {
+	Name     string    `json:"name"`
+	TakenAt  time.Time `json:"takenAt"`
+	EventLog bool      `json:"eventLog"` // An event log copy was taken with the snapshot
+	Size     int64     `json:"size"`     // Bytes on disk
+}
+
+// Backups takes, lists and prunes the backups in a directory
+type Backups struct {
+	cfg    Config
+	om     *manager.OrderManager
+	events *eventlog.Log // Optional; copied with each snapshot
+	mu     sync.Mutex    // Serializes Take
+}
+
+// New creates the backup directory if needed. events may be nil when no
+// event log is kept. Call Run to back up on schedule.
+func New(cfg Config, om *manager.OrderManager, events *eventlog.Log) (*Backups, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Backups{cfg: cfg, om: om, events: events}, nil
+}
+
+// Run takes a backup every Interval until ctx is cancelled
+func (b *Backups) Run(ctx context.Context) {
+	if b.cfg.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(b.cfg.Interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if bk, err := b.Take(ctx); err != nil {
+				log.Printf("backup: %v", err)
+			} else {
+				log.Printf("backup: took %s", bk.Name)
+			}
+		}
+	}
+}
+
+// Take backs up now and prunes old backups
+func (b *Backups) Take(ctx context.Context) (Backup, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	snap, err := b.om.Snapshot(ctx)
+	if err != nil {
+		return Backup{}, fmt.Errorf("snapshot: %w", err)
+	}
+	// The log is copied after the snapshot, so it holds every change the
+	// snapshot does
+	bk := Backup{Name: prefix + snap.TakenAt.UTC().Format(stampLayout), TakenAt: snap.TakenAt}
+	if b.events != nil {
+		var logCopy bytes.Buffer
+		if err := b.events.CopyTo(&logCopy); err != nil {
+			return Backup{}, fmt.Errorf("copy event log: %w", err)
+		}
+		if err := b.write(bk.Name+eventsExt, logCopy.Bytes()); err != nil {
+			return Backup{}, err
+		}
+		bk.EventLog = true
+		bk.Size += int64(logCopy.Len())
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return Backup{}, err
+	}
+	// The snapshot goes last: a backup exists once it does
+	if err := b.write(bk.Name+snapshotExt, data); err != nil {
+		return Backup{}, err
+	}
+	bk.Size += int64(len(data))
+	if err := b.prune(time.Now()); err != nil {
+		return bk, fmt.Errorf("prune: %w", err)
+	}
+	return bk, nil
+}
+
+// write stores a file under the directory, synced and renamed into place so
+// a crash leaves no half-written backup
+func (b *Backups) write(name string, data []byte) error {
+	path := filepath.Join(b.cfg.Dir, name)
+	tmp := path + partialExt
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// prune removes the backups beyond Keep or older than MaxAge; mu must be held
+func (b *Backups) prune(now time.Time) error {
+	backups, err := List(b.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, bk := range backups {
+		tooMany := b.cfg.Keep > 0 && i >= b.cfg.Keep
+		tooOld := b.cfg.MaxAge > 0 && now.Sub(bk.TakenAt) > time.Duration(b.cfg.MaxAge)
+		if !tooMany && !tooOld {
+			continue
+		}
+		base := filepath.Join(b.cfg.Dir, bk.Name)
+		// The snapshot goes first, so a part left behind is not listed
+		for _, path := range []string{base + snapshotExt, base + eventsExt} {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// List returns the backups in dir, newest first
+func (b *Backups) List() ([]Backup, error) {
+	return List(b.cfg.Dir)
+}
+
+// List returns the backups in dir, newest first
+func List(dir string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []Backup
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), snapshotExt)
+		if !ok || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		at, err := time.Parse(stampLayout, strings.TrimPrefix(name, prefix))
+		if err != nil {
+			continue
+		}
+		bk := Backup{Name: name, TakenAt: at}
+		for _, ext := range []string{snapshotExt, eventsExt} {
+			if info, err := os.Stat(filepath.Join(dir, name+ext)); err == nil {
+				bk.Size += info.Size()
+				bk.EventLog = bk.EventLog || ext == eventsExt
+			}
+		}
+		backups = append(backups, bk)
+	}
+	slices.SortFunc(backups, func(a, b Backup) int { return b.TakenAt.Compare(a.TakenAt) })
+	return backups, nil
+}
+
+// Find returns the backup in dir called name, or the newest for Latest
+func Find(dir, name string) (Backup, error) {
+	backups, err := List(dir)
+	if err != nil {
+		return Backup{}, err
+	}
+	for _, bk := range backups {
+		if name == Latest || bk.Name == name {
+			return bk, nil
+		}
+	}
+	return Backup{}, fmt.Errorf("no backup %q in %s", name, dir)
+}
+
+// Restore puts back the backup in dir called name, or the newest for
+// Latest, before the server loads its state.
+//
+// With an event log at eventLogPath the backup's copy replaces it, the
+// current log being renamed aside, and the server's usual replay restores
+// the orders; Restore returns a nil snapshot then. Without one it returns the
+// backup's snapshot for the caller to load with RestoreSnapshot.
+func Restore(dir, name, eventLogPath string) (*manager.Snapshot, error) {
+	bk, err := Find(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Join(dir, bk.Name)
+	if eventLogPath == "" {
+		data, err := os.ReadFile(base + snapshotExt)
+		if err != nil {
+			return nil, err
+		}
+		var snap manager.Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return nil, fmt.Errorf("backup %s: %w", bk.Name, err)
+		}
+		return &snap, nil
+	}
+	if !bk.EventLog {
+		return nil, fmt.Errorf("backup %s holds no event log to replace %s with", bk.Name, eventLogPath)
+	}
+	if err := copyFile(base+eventsExt, eventLogPath+partialExt); err != nil {
+		return nil, err
+	}
+	aside := eventLogPath + asideExt + time.Now().UTC().Format(stampLayout)
+	if err := os.Rename(eventLogPath, aside); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := os.Rename(eventLogPath+partialExt, eventLogPath); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// copyFile copies src to a new file dst, synced
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
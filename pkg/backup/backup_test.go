@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"awesomeProject/pkg/eventlog"
+	"awesomeProject/pkg/manager"
+)
+
+func TestTakeAndRestore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "events.jsonl")
+	events, _, err := eventlog.Open(eventlog.Config{Path: logPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	om := manager.New(manager.DefaultConfig())
+	events.Attach(om)
+	for _, item := range []string{"soup", "tea"} {
+		if _, err := om.AddOrder(ctx, item, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Older backups beyond Keep are pruned
+	for _, name := range []string{"backup-20200101-000000", "backup-20200102-000000"} {
+		if err := os.WriteFile(filepath.Join(dir, name+snapshotExt), []byte("{}"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := New(Config{Dir: dir, Keep: 2}, om, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bk, err := b.Take(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backups, err := b.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || backups[0].Name != bk.Name || !backups[0].EventLog || backups[1].Name != "backup-20200102-000000" {
+		t.Fatalf("backups = %+v", backups)
+	}
+
+	// Changes after the backup are lost on restore
+	if _, err := om.AddOrder(ctx, "pie", 1); err != nil {
+		t.Fatal(err)
+	}
+	events.Close()
+
+	snap, err := Restore(dir, Latest, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Waiting) != 2 {
+		t.Fatalf("snapshot holds %d waiting orders", len(snap.Waiting))
+	}
+
+	if snap, err = Restore(dir, bk.Name, logPath); err != nil || snap != nil {
+		t.Fatalf("restore = %v, %v", snap, err)
+	}
+	records, err := eventlog.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := eventlog.Rebuild(records)
+	if err != nil || len(tokens) != 2 {
+		t.Fatalf("restored log holds %d orders, %v", len(tokens), err)
+	}
+	// The log replaced is kept aside
+	aside, _ := filepath.Glob(logPath + asideExt + "*")
+	if len(aside) != 1 {
+		t.Fatalf("set aside = %v", aside)
+	}
+	if records, err = eventlog.ReadFile(aside[0]); err != nil || len(records) != 3 {
+		t.Fatalf("set aside log holds %d records, %v", len(records), err)
+	}
+
+	if _, err := Restore(dir, "backup-20200102-000000", logPath); err == nil {
+		t.Error("restored a backup without an event log over the event log")
+	}
+	if _, err := Restore(dir, "backup-19990101-000000", ""); err == nil {
+		t.Error("restored a missing backup")
+	}
+}
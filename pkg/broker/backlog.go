@@ -0,0 +1,268 @@
+package broker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"awesomeProject/pkg/queue"
+)
+
+// errSpillFull is returned for events beyond the spill limit
+var errSpillFull = errors.New("spill full")
+
+// backlog holds the records waiting for the broker, oldest first: one JSON
+// line each in a file, with the offset of the oldest unsent line in a file
+// beside it, or in memory when there is no path. Only the sending goroutine
+// uses it.
+type backlog struct {
+	max int64
+
+	f       *os.File // Nil in memory
+	offPath string
+	off     int64 // Of the oldest unsent line
+	end     int64 // Of the end of the file
+	count   int
+	next    []byte // Line peeked and not yet popped
+
+	mem  [][]byte
+	size int64 // Bytes held in memory
+}
+
+// openBacklog opens the spill file at path, picking up where the last
+// process left it, or returns a backlog kept in memory when path is empty
+func openBacklog(path string, max int64) (*backlog, error) {
+	b := &backlog{max: max}
+	if path == "" {
+		return b, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("broker: open spill file: %w", err)
+	}
+	b.f, b.offPath = f, path+".offset"
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	b.end = info.Size()
+	if data, err := os.ReadFile(b.offPath); err == nil {
+		b.off, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	}
+	if b.off < 0 || b.off > b.end {
+		b.off = 0
+	}
+	// Count what is left, cutting off a line left unfinished by a crash so
+	// the next one starts afresh
+	r := bufio.NewReader(io.NewSectionReader(f, b.off, b.end-b.off))
+	complete := b.off
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("broker: read spill file: %w", err)
+		}
+		complete += int64(len(line))
+		b.count++
+	}
+	if complete < b.end {
+		if err := f.Truncate(complete); err != nil {
+			f.Close()
+			return nil, err
+		}
+		b.end = complete
+	}
+	return b, nil
+}
+
+func (b *backlog) len() int {
+	if b.f == nil {
+		return len(b.mem)
+	}
+	return b.count
+}
+
+// push adds r as the newest record
+func (b *backlog) push(r record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	held := b.size
+	if b.f != nil {
+		held = b.end - b.off
+	}
+	if b.max > 0 && held+int64(len(line)) > b.max {
+		return errSpillFull
+	}
+	if b.f == nil {
+		b.mem = append(b.mem, line)
+		b.size += int64(len(line))
+		return nil
+	}
+	n, err := b.f.Write(line)
+	b.end += int64(n)
+	if err != nil {
+		return fmt.Errorf("spill: %w", err)
+	}
+	b.count++
+	return nil
+}
+
+// peek returns the oldest record, or false when there is none
+func (b *backlog) peek() (record, bool, error) {
+	if b.len() == 0 {
+		return record{}, false, nil
+	}
+	if b.f == nil {
+		b.next = b.mem[0]
+	} else if b.next == nil {
+		line, err := bufio.NewReader(io.NewSectionReader(b.f, b.off, b.end-b.off)).ReadBytes('\n')
+		if err != nil {
+			return record{}, false, err
+		}
+		b.next = line
+	}
+	var r record
+	if err := json.Unmarshal(b.next, &r); err != nil {
+		// Dropped, so the events behind it still go out
+		off := b.off
+		if perr := b.pop(); perr != nil {
+			return record{}, false, perr
+		}
+		return record{}, false, fmt.Errorf("dropped unreadable event at offset %d: %w", off, err)
+	}
+	return r, true, nil
+}
+
+// pop drops the record last peeked, once it has been sent
+func (b *backlog) pop() error {
+	if b.f == nil {
+		b.size -= int64(len(b.mem[0]))
+		b.mem[0] = nil
+		b.mem = b.mem[1:]
+		return nil
+	}
+	b.off += int64(len(b.next))
+	b.next = nil
+	b.count--
+	if b.count == 0 {
+		return b.reset()
+	}
+	return os.WriteFile(b.offPath, []byte(strconv.FormatInt(b.off, 10)), 0o644)
+}
+
+// reset empties the spill file once everything in it has been sent
+func (b *backlog) reset() error {
+	if err := b.f.Truncate(0); err != nil {
+		return fmt.Errorf("truncate spill file: %w", err)
+	}
+	b.off, b.end, b.next = 0, 0, nil
+	if err := os.Remove(b.offPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// scrub erases the personal data of the orders match picks from the records
+// waiting, reporting how many it changed. The spill file is rewritten from
+// the oldest unsent line on, its offset file removed first so a crash
+// partway through sends events again rather than skipping them.
+func (b *backlog) scrub(match func(*queue.Token) bool) (int, error) {
+	if b.f == nil {
+		n := 0
+		for i, line := range b.mem {
+			if scrubbed, ok := scrubLine(line, match); ok {
+				b.size += int64(len(scrubbed) - len(line))
+				b.mem[i] = scrubbed
+				n++
+			}
+		}
+		return n, nil
+	}
+	data := make([]byte, b.end-b.off)
+	if _, err := b.f.ReadAt(data, b.off); err != nil {
+		return 0, fmt.Errorf("read spill file: %w", err)
+	}
+	var buf bytes.Buffer
+	n := 0
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n') + 1
+		if end == 0 {
+			end = len(data)
+		}
+		line := data[:end]
+		data = data[end:]
+		if scrubbed, ok := scrubLine(line, match); ok {
+			line = scrubbed
+			n++
+		}
+		buf.Write(line)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	path := b.f.Name()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("scrub spill file: %w", err)
+	}
+	if err := os.Remove(b.offPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		os.Remove(tmp)
+		return 0, err
+	}
+	b.off, b.next = 0, nil
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("scrub spill file: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("reopen spill file: %w", err)
+	}
+	b.f.Close()
+	b.f, b.end = f, int64(buf.Len())
+	return n, nil
+}
+
+// scrubLine returns line with the personal data of the order its message
+// carries erased, or false when match does not pick it or it held none.
+// Unreadable lines are left for peek to drop.
+func scrubLine(line []byte, match func(*queue.Token) bool) ([]byte, bool) {
+	var r record
+	var m Message
+	if json.Unmarshal(line, &r) != nil || json.Unmarshal(r.Body, &m) != nil ||
+		m.Order == nil || !match(m.Order) || !m.Order.Scrub() {
+		return nil, false
+	}
+	body, err := json.Marshal(m)
+	if err != nil {
+		return nil, false
+	}
+	r.Body = body
+	out, err := json.Marshal(r)
+	if err != nil {
+		return nil, false
+	}
+	return append(out, '\n'), true
+}
+
+func (b *backlog) close() error {
+	if b.f == nil {
+		return nil
+	}
+	return b.f.Close()
+}
@@ -0,0 +1,351 @@
+// Package broker publishes order lifecycle events to a message broker, NATS
+// JetStream or Kafka, for the rest of the business's event-driven systems.
+//
+// Delivery is at least once. An event is only let go once the broker has
+// acknowledged it; while the broker is unreachable events are spilled to a
+// local file, and sent from it in order when the broker is back, before any
+// newer ones. A crash between a send and its acknowledgement, or between the
+// acknowledgement and the spill file moving on, sends an event again, so
+// consumers drop duplicates by the event's ID, as JetStream does itself.
+package broker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// Brokers
+const (
+	KindNATS  = "nats"
+	KindKafka = "kafka"
+)
+
+// Config selects the broker and the events sent to it
+type Config struct {
+	Kind    string   `json:"kind"`    // "nats" or "kafka"; empty publishes nothing
+	URL     string   `json:"url"`     // NATS servers, comma-separated
+	Brokers []string `json:"brokers"` // Kafka bootstrap brokers, as host:port
+
+	// Topic is the NATS subject or Kafka topic, with {type} replaced by the
+	// event type. NATS subjects must be bound to a JetStream stream, which
+	// acknowledges what it stores.
+	Topic string   `json:"topic"`
+	Types []string `json:"types"` // Event types published; empty for every type
+
+	Timeout config.Duration `json:"timeout"` // For each send to be acknowledged
+	Retry   config.Duration `json:"retry"`   // Between attempts while the broker is unreachable
+
+	// SpillPath is the file events wait in while the broker is unreachable,
+	// kept across restarts; with none they wait in memory. MaxSpill bounds
+	// it in bytes; events beyond it are dropped.
+	SpillPath string `json:"spillPath"`
+	MaxSpill  int64  `json:"maxSpill"`
+
+	Buffer int `json:"buffer"` // Events waiting to be sent before the publisher waits
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		Topic:    "orders.{type}",
+		Timeout:  config.Duration(5 * time.Second),
+		Retry:    config.Duration(5 * time.Second),
+		MaxSpill: 64 << 20,
+		Buffer:   1024,
+	}
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	switch c.Kind {
+	case "":
+		return nil
+	case KindNATS:
+		if c.URL == "" {
+			return errors.New("broker: nats needs a url")
+		}
+	case KindKafka:
+		if len(c.Brokers) == 0 {
+			return errors.New("broker: kafka needs brokers")
+		}
+	default:
+		return fmt.Errorf("broker: kind must be %s or %s, got %q", KindNATS, KindKafka, c.Kind)
+	}
+	switch {
+	case c.Topic == "":
+		return errors.New("broker: topic is required")
+	case c.Timeout <= 0 || c.Retry <= 0:
+		return errors.New("broker: timeout and retry must be positive")
+	case c.MaxSpill < 0:
+		return errors.New("broker: maxSpill must not be negative")
+	case c.Buffer < 1:
+		return errors.New("broker: buffer must be at least 1")
+	}
+	return nil
+}
+
+// Message is the body published for each event
+type Message struct {
+	ID    string       `json:"id"` // Unique to the event, for dropping duplicates
+	Type  string       `json:"type"`
+	At    time.Time    `json:"at"`
+	Order *queue.Token `json:"order"`
+}
+
+// record is one event ready to send, as it is spilled
+type record struct {
+	ID    string          `json:"id"`
+	Topic string          `json:"topic"`
+	Key   string          `json:"key"` // The order ID, so Kafka keeps an order's events on one partition
+	Type  string          `json:"type"`
+	Body  json.RawMessage `json:"body"`
+}
+
+// sink sends records to a broker, returning once the broker has
+// acknowledged them
+type sink interface {
+	send(ctx context.Context, r record) error
+	close() error
+}
+
+// Publisher sends events to the broker
+type Publisher struct {
+	cfg     Config
+	sink    sink
+	backlog *backlog
+	spilled atomic.Int64 // The backlog's length, for other goroutines
+
+	mu      sync.RWMutex
+	closed  bool
+	records chan record
+	scrubs  chan scrub
+	done    chan struct{}
+}
+
+// scrub asks the sending goroutine, which owns the backlog, to erase the
+// personal data of the orders match picks from it
+type scrub struct {
+	match func(*queue.Token) bool
+	reply chan scrubbed
+}
+
+type scrubbed struct {
+	n   int
+	err error
+}
+
+// New connects to the broker cfg names, opens the spill file and starts
+// sending. The broker need not be reachable yet. Attach the publisher to
+// events and Close it to stop.
+func New(cfg Config) (*Publisher, error) {
+	var s sink
+	var err error
+	switch cfg.Kind {
+	case KindNATS:
+		s, err = dialNATS(cfg)
+	case KindKafka:
+		s = newKafka(cfg)
+	default:
+		return nil, fmt.Errorf("broker: kind must be %s or %s, got %q", KindNATS, KindKafka, cfg.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newPublisher(cfg, s)
+}
+
+func newPublisher(cfg Config, s sink) (*Publisher, error) {
+	b, err := openBacklog(cfg.SpillPath, cfg.MaxSpill)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	if n := b.len(); n > 0 {
+		log.Printf("broker: %d events spilled before the last stop, sending them first", n)
+	}
+	p := &Publisher{
+		cfg:     cfg,
+		sink:    s,
+		backlog: b,
+		records: make(chan record, cfg.Buffer),
+		scrubs:  make(chan scrub),
+		done:    make(chan struct{}),
+	}
+	p.spilled.Store(int64(b.len()))
+	go p.run()
+	return p, nil
+}
+
+// Attach subscribes the publisher to the manager's events, or better a bus
+// relaying them, as the publisher waits for room while the broker is slow
+func (p *Publisher) Attach(src manager.Publisher) {
+	src.Subscribe(p.handle)
+}
+
+func (p *Publisher) handle(e manager.Event) {
+	if e.Token == nil || len(p.cfg.Types) > 0 && !slices.Contains(p.cfg.Types, e.Type) {
+		return
+	}
+	r, err := p.record(e)
+	if err != nil {
+		log.Printf("broker: %s event for %s: %v", e.Type, e.Token.ID, err)
+		return
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.closed {
+		p.records <- r
+	}
+}
+
+// record makes the record sent for e
+func (p *Publisher) record(e manager.Event) (record, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return record{}, err
+	}
+	m := Message{ID: hex.EncodeToString(id), Type: e.Type, At: e.At, Order: e.Token}
+	body, err := json.Marshal(m)
+	if err != nil {
+		return record{}, err
+	}
+	topic := strings.ReplaceAll(p.cfg.Topic, "{type}", e.Type)
+	return record{ID: m.ID, Topic: topic, Key: e.Token.ID, Type: e.Type, Body: body}, nil
+}
+
+// run sends records as they come, going through the backlog whenever it
+// holds any so that events keep their order
+func (p *Publisher) run() {
+	defer close(p.done)
+	for {
+		if p.backlog.len() > 0 {
+			if p.drain() {
+				continue
+			}
+			// Still unreachable: spill what comes in until the next try
+			wait := time.After(time.Duration(p.cfg.Retry))
+			for waiting := true; waiting; {
+				select {
+				case r, ok := <-p.records:
+					if !ok {
+						return
+					}
+					p.spill(r)
+				case s := <-p.scrubs:
+					p.scrub(s)
+				case <-wait:
+					waiting = false
+				}
+			}
+			continue
+		}
+		select {
+		case s := <-p.scrubs:
+			p.scrub(s)
+		case r, ok := <-p.records:
+			if !ok {
+				return
+			}
+			if err := p.send(r); err != nil {
+				log.Printf("broker: %s unreachable, spilling events until it is back: %v", p.cfg.Kind, err)
+				p.spill(r)
+			}
+		}
+	}
+}
+
+// drain sends the backlog oldest first, reporting whether it emptied it
+func (p *Publisher) drain() bool {
+	for {
+		r, ok, err := p.backlog.peek()
+		if err != nil {
+			p.spilled.Store(int64(p.backlog.len()))
+			log.Printf("broker: read spilled events: %v", err)
+			return false
+		}
+		if !ok {
+			log.Printf("broker: %s is back, spilled events sent", p.cfg.Kind)
+			return true
+		}
+		if err := p.send(r); err != nil {
+			return false
+		}
+		err = p.backlog.pop()
+		p.spilled.Store(int64(p.backlog.len()))
+		if err != nil {
+			log.Printf("broker: %v", err)
+			return false
+		}
+	}
+}
+
+func (p *Publisher) send(r record) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cfg.Timeout))
+	defer cancel()
+	return p.sink.send(ctx, r)
+}
+
+func (p *Publisher) spill(r record) {
+	if err := p.backlog.push(r); err != nil {
+		log.Printf("broker: dropping %s event for %s: %v", r.Type, r.Key, err)
+	}
+	p.spilled.Store(int64(p.backlog.len()))
+}
+
+func (p *Publisher) scrub(s scrub) {
+	n, err := p.backlog.scrub(s.match)
+	s.reply <- scrubbed{n, err}
+}
+
+// Scrub erases the personal data of the orders match picks from the events
+// waiting for the broker, spilled or in memory, reporting how many it
+// changed. Events already taken for sending go out as they are.
+func (p *Publisher) Scrub(match func(*queue.Token) bool) (int, error) {
+	s := scrub{match: match, reply: make(chan scrubbed, 1)}
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return 0, errors.New("broker: publisher closed")
+	}
+	p.scrubs <- s
+	p.mu.RUnlock()
+	res := <-s.reply
+	if res.err != nil {
+		return res.n, fmt.Errorf("broker: %w", res.err)
+	}
+	return res.n, nil
+}
+
+// Pending reports how many events are waiting for the broker
+func (p *Publisher) Pending() int {
+	return len(p.records) + int(p.spilled.Load())
+}
+
+// Close stops taking events, sends those waiting, or spills them when the
+// broker is unreachable, and disconnects. Events waiting in memory without
+// a spill file are lost.
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	close(p.records)
+	p.mu.Unlock()
+	<-p.done
+	if n := p.backlog.len(); n > 0 && p.cfg.SpillPath == "" {
+		log.Printf("broker: %d unsent events lost", n)
+	}
+	return errors.Join(p.sink.close(), p.backlog.close())
+}
@@ -0,0 +1,243 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// fakeSink records what it is sent, failing while down
+type fakeSink struct {
+	mu   sync.Mutex
+	down bool
+	sent []record
+}
+
+func (s *fakeSink) send(_ context.Context, r record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("connection refused")
+	}
+	s.sent = append(s.sent, r)
+	return nil
+}
+
+func (s *fakeSink) close() error { return nil }
+
+func (s *fakeSink) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+// keys returns the order IDs sent, in order
+func (s *fakeSink) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for _, r := range s.sent {
+		keys = append(keys, r.Key)
+	}
+	return keys
+}
+
+func testConfig(t *testing.T) Config {
+	cfg := DefaultConfig()
+	cfg.Kind = KindNATS
+	cfg.URL = "nats://localhost:4222"
+	cfg.Retry = config.Duration(5 * time.Millisecond)
+	cfg.SpillPath = filepath.Join(t.TempDir(), "spill.jsonl")
+	return cfg
+}
+
+func publish(p *Publisher, typ string, ids ...string) {
+	for _, id := range ids {
+		p.handle(manager.Event{Type: typ, Token: &queue.Token{ID: id, Item: "tea"}, At: time.Now()})
+	}
+}
+
+// waitFor polls until ok holds
+func waitFor(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); !ok(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Types = []string{manager.EventCreated, manager.EventPrepared}
+	sink := &fakeSink{}
+	p, err := newPublisher(cfg, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(p, manager.EventCreated, "1")
+	publish(p, manager.EventModified, "1")
+	publish(p, manager.EventPrepared, "1")
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.sent) != 2 || sink.sent[0].Topic != "orders.created" || sink.sent[1].Topic != "orders.prepared" {
+		t.Fatalf("sent = %+v", sink.sent)
+	}
+	var m Message
+	if err := json.Unmarshal(sink.sent[0].Body, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.ID == "" || m.ID != sink.sent[0].ID || m.ID == sink.sent[1].ID || m.Type != manager.EventCreated || m.Order.ID != "1" {
+		t.Errorf("message = %+v", m)
+	}
+}
+
+func TestSpill(t *testing.T) {
+	cfg := testConfig(t)
+	sink := &fakeSink{down: true}
+	p, err := newPublisher(cfg, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(p, manager.EventCreated, "1", "2", "3")
+	waitFor(t, "the events to spill", func() bool { return p.spilled.Load() == 3 })
+
+	sink.setDown(false)
+	publish(p, manager.EventCreated, "4")
+	waitFor(t, "the spill to drain", func() bool { return len(sink.keys()) == 4 })
+	if got := sink.keys(); got[0] != "1" || got[3] != "4" {
+		t.Errorf("sent %v, want the spilled events first, in order", got)
+	}
+	if info, err := os.Stat(cfg.SpillPath); err != nil || info.Size() != 0 {
+		t.Errorf("spill file after draining: %v, %v", info, err)
+	}
+	p.Close()
+}
+
+func TestSpillSurvivesRestart(t *testing.T) {
+	cfg := testConfig(t)
+	sink := &fakeSink{down: true}
+	p, err := newPublisher(cfg, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(p, manager.EventCreated, "1", "2", "3")
+	waitFor(t, "the events to spill", func() bool { return p.spilled.Load() == 3 })
+	// The first is sent before the next stop
+	sink.setDown(false)
+	waitFor(t, "a send", func() bool { return len(sink.keys()) > 0 })
+	sink.setDown(true)
+	p.Close()
+
+	// A crash part way through a write
+	f, _ := os.OpenFile(cfg.SpillPath, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"id":"cut`)
+	f.Close()
+
+	sink.setDown(false)
+	p, err = newPublisher(cfg, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(p, manager.EventCreated, "4")
+	p.Close()
+	got := sink.keys()
+	if len(got) < 4 || got[len(got)-1] != "4" || got[len(got)-2] != "3" {
+		t.Errorf("sent %v, want the rest of the spill after a restart, then the new event", got)
+	}
+}
+
+func TestSpillLimit(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.SpillPath = ""
+	cfg.MaxSpill = 600
+	sink := &fakeSink{down: true}
+	p, err := newPublisher(cfg, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(p, manager.EventCreated, "1", "2", "3", "4", "5")
+	waitFor(t, "the events to be taken", func() bool { return len(p.records) == 0 })
+	if n := p.spilled.Load(); n == 0 || n == 5 {
+		t.Errorf("%d events held in memory, want the limit to drop some", n)
+	}
+	p.Close()
+}
+
+func TestValidate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("disabled: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.Kind = KindKafka
+	if err := cfg.Validate(); err == nil {
+		t.Error("kafka without brokers: no error")
+	}
+	cfg.Brokers = []string{"localhost:9092"}
+	if err := cfg.Validate(); err != nil {
+		t.Error(err)
+	}
+	cfg.Kind = "rabbitmq"
+	if err := cfg.Validate(); err == nil {
+		t.Error("unknown kind: no error")
+	}
+}
+
+func TestScrub(t *testing.T) {
+	for _, spill := range []bool{true, false} {
+		cfg := testConfig(t)
+		if !spill {
+			cfg.SpillPath = ""
+		}
+		sink := &fakeSink{down: true}
+		p, err := newPublisher(cfg, sink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tok := range []*queue.Token{
+			{ID: "1", Item: "tea", Phone: "+15550100"},
+			{ID: "2", Item: "tea", Phone: "+15550199"},
+			{ID: "3", Item: "soup", Phone: "+15550100", Notes: "for Ann"},
+		} {
+			p.handle(manager.Event{Type: manager.EventCreated, Token: tok, At: time.Now()})
+		}
+		waitFor(t, "the events to spill", func() bool { return p.spilled.Load() == 3 })
+
+		n, err := p.Scrub(func(t *queue.Token) bool { return t.Phone == "+15550100" })
+		if err != nil || n != 2 {
+			t.Fatalf("spill %v: scrub = %d, %v", spill, n, err)
+		}
+		if spill {
+			if data, _ := os.ReadFile(cfg.SpillPath); strings.Contains(string(data), "+15550100") || strings.Contains(string(data), "for Ann") {
+				t.Errorf("spill file after scrub = %s", data)
+			}
+		}
+		sink.setDown(false)
+		waitFor(t, "the spill to drain", func() bool { return len(sink.keys()) == 3 })
+		p.Close()
+		for _, r := range sink.sent {
+			var m Message
+			if err := json.Unmarshal(r.Body, &m); err != nil {
+				t.Fatal(err)
+			}
+			if want := map[string]string{"2": "+15550199"}[r.Key]; m.Order.Phone != want || m.Order.Notes != "" {
+				t.Errorf("spill %v: sent %+v", spill, m.Order)
+			}
+		}
+		if got := sink.keys(); got[0] != "1" || got[2] != "3" {
+			t.Errorf("spill %v: sent %v, want the scrubbed events in order", spill, got)
+		}
+	}
+}
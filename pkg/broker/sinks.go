@@ -0,0 +1,81 @@
+package broker
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/segmentio/kafka-go"
+)
+
+// natsSink publishes to JetStream, which acknowledges each message once its
+// stream has stored it and drops repeats of a message ID
+type natsSink struct {
+	nc *nats.Conn
+	js jetstream.JetStream
+}
+
+// dialNATS connects to cfg.URL, retrying in the background when the servers
+// cannot be reached yet
+func dialNATS(cfg Config) (*natsSink, error) {
+	nc, err := nats.Connect(cfg.URL,
+		nats.Name("restaurant-token"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(time.Duration(cfg.Retry)),
+		nats.ReconnectBufSize(-1), // Fail sends while disconnected, so they spill
+	)
+	if err != nil {
+		return nil, err
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return &natsSink{nc: nc, js: js}, nil
+}
+
+func (s *natsSink) send(ctx context.Context, r record) error {
+	_, err := s.js.Publish(ctx, r.Topic, r.Body, jetstream.WithMsgID(r.ID))
+	return err
+}
+
+func (s *natsSink) close() error {
+	s.nc.Close()
+	return nil
+}
+
+// kafkaSink writes to Kafka, waiting for every in-sync replica to
+// acknowledge each message
+type kafkaSink struct {
+	w *kafka.Writer
+}
+
+func newKafka(cfg Config) *kafkaSink {
+	return &kafkaSink{w: &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		MaxAttempts:  1, // The publisher retries, spilling meanwhile
+		BatchSize:    1,
+		WriteTimeout: time.Duration(cfg.Timeout),
+	}}
+}
+
+func (s *kafkaSink) send(ctx context.Context, r record) error {
+	return s.w.WriteMessages(ctx, kafka.Message{
+		Topic: r.Topic,
+		Key:   []byte(r.Key),
+		Value: r.Body,
+		Headers: []kafka.Header{
+			{Key: "event-id", Value: []byte(r.ID)},
+			{Key: "event-type", Value: []byte(r.Type)},
+		},
+	})
+}
+
+func (s *kafkaSink) close() error {
+	return s.w.Close()
+}
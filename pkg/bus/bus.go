@@ -0,0 +1,261 @@
+// Package bus relays the order manager's events to the subsystems that react
+// to them (notifications, delivery platforms, printers, storage), each
+// through its own buffer and goroutine, so a slow consumer neither holds up
+// the manager nor the other consumers, and new consumers are added without
+// touching the manager.
+//
+// Every subscriber sees the events it takes in the order they happened. When
+// its buffer is full the manager waits for room, as it would for a listener
+// run inline, unless the subscription is lossy, when the event is dropped
+// for that subscriber and counted. Close stops taking events and waits for
+// the buffered ones to be handled.
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"awesomeProject/pkg/manager"
+)
+
+// Config sets the default buffering
+type Config struct {
+	Buffer int `json:"buffer"` // Events held for each subscriber
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{Buffer: 256}
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	if c.Buffer < 1 {
+		return errors.New("bus: buffer must be at least 1")
+	}
+	return nil
+}
+
+// Bus fans events out to its subscribers
+type Bus struct {
+	cfg Config
+
+	mu     sync.RWMutex
+	subs   []*Subscription
+	closed bool
+}
+
+var _ manager.Publisher = (*Bus)(nil)
+
+// New returns a bus with no subscribers; Attach it to a manager to relay
+// its events
+func New(cfg Config) *Bus {
+	return &Bus{cfg: cfg}
+}
+
+// Attach relays om's events to the bus
+func (b *Bus) Attach(om *manager.OrderManager) {
+	om.Subscribe(b.Publish)
+}
+
+// Option configures a subscription
+type Option func(*Subscription)
+
+// Types limits a subscription to events of the given types
+func Types(types ...string) Option {
+	return func(s *Subscription) { s.types = types }
+}
+
+// Buffer sets how many events a subscription holds, in place of the bus's
+// default
+func Buffer(n int) Option {
+	return func(s *Subscription) { s.ch = make(chan manager.Event, max(n, 1)) }
+}
+
+// Lossy drops events for the subscription while its buffer is full instead
+// of making the publisher wait, for consumers that can miss some, such as
+// live displays
+func Lossy() Option {
+	return func(s *Subscription) { s.lossy = true }
+}
+
+// Subscription is one consumer of the bus
+type Subscription struct {
+	name  string
+	l     manager.Listener
+	types []string
+	lossy bool
+
+	ch   chan manager.Event
+	stop chan struct{} // Closed to drain the buffer and finish
+	once sync.Once
+	done chan struct{}
+	bus  *Bus
+
+	delivered, dropped atomic.Uint64
+}
+
+// Subscribe calls l with every event, in its own goroutine, with the bus's
+// default buffer. It makes the bus a manager.Publisher, so consumers attach
+// to it as they would to the manager.
+func (b *Bus) Subscribe(l manager.Listener) {
+	b.Add("", l)
+}
+
+// Add subscribes l under name, which identifies it in logs and Stats. A bus
+// that has been closed delivers nothing to it.
+func (b *Bus) Add(name string, l manager.Listener, opts ...Option) *Subscription {
+	s := &Subscription{name: name, l: l, stop: make(chan struct{}), done: make(chan struct{}), bus: b}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.ch == nil {
+		s.ch = make(chan manager.Event, b.cfg.Buffer)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s.name == "" {
+		s.name = fmt.Sprintf("subscriber %d", len(b.subs)+1)
+	}
+	if b.closed {
+		close(s.stop)
+		close(s.done)
+		return s
+	}
+	b.subs = append(b.subs, s)
+	go s.run()
+	return s
+}
+
+// Publish hands e to every subscriber taking its type. It waits while a
+// subscriber that is not lossy has a full buffer.
+func (b *Bus) Publish(e manager.Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	for _, s := range b.subs {
+		if len(s.types) > 0 && !slices.Contains(s.types, e.Type) {
+			continue
+		}
+		if s.lossy {
+			select {
+			case s.ch <- e:
+			default:
+				if n := s.dropped.Add(1); n == 1 || n%100 == 0 {
+					log.Printf("bus: %s is behind, %d events dropped", s.name, n)
+				}
+			}
+			continue
+		}
+		select {
+		case s.ch <- e:
+		case <-s.stop:
+		}
+	}
+}
+
+// run delivers the subscription's events until it is stopped, then handles
+// what is left in its buffer
+func (s *Subscription) run() {
+	defer close(s.done)
+	for {
+		select {
+		case e := <-s.ch:
+			s.deliver(e)
+		case <-s.stop:
+			for {
+				select {
+				case e := <-s.ch:
+					s.deliver(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver calls the listener, keeping a panic in it from taking down the
+// other subscribers
+func (s *Subscription) deliver(e manager.Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("bus: %s panicked on a %s event: %v", s.name, e.Type, r)
+		}
+	}()
+	s.l(e)
+	s.delivered.Add(1)
+}
+
+// Unsubscribe stops taking events for s; those already buffered are still
+// handled
+func (s *Subscription) Unsubscribe() {
+	// Stopped first, so a publisher waiting on s lets go of the lock
+	s.halt()
+	b := s.bus
+	b.mu.Lock()
+	b.subs = slices.DeleteFunc(b.subs, func(o *Subscription) bool { return o == s })
+	b.mu.Unlock()
+}
+
+// halt tells s to drain its buffer and finish
+func (s *Subscription) halt() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+// Stats is a subscriber's delivery counts
+type Stats struct {
+	Name      string `json:"name"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+	Pending   int    `json:"pending"` // Buffered and not yet handled
+}
+
+// Stats returns the counts of every subscriber, in the order they subscribed
+func (b *Bus) Stats() []Stats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	stats := make([]Stats, len(b.subs))
+	for i, s := range b.subs {
+		stats[i] = Stats{Name: s.name, Delivered: s.delivered.Load(), Dropped: s.dropped.Load(), Pending: len(s.ch)}
+	}
+	return stats
+}
+
+// Close stops taking events and waits until every subscriber has handled
+// those buffered for it, or ctx is done
+func (b *Bus) Close(ctx context.Context) error {
+	// Stopped before taking the lock, so publishers waiting on a full
+	// buffer let go of it
+	b.mu.RLock()
+	for _, s := range b.subs {
+		s.halt()
+	}
+	b.mu.RUnlock()
+	b.mu.Lock()
+	b.closed = true
+	subs := b.subs
+	for _, s := range subs {
+		s.halt() // Any added meanwhile
+	}
+	b.mu.Unlock()
+	for _, s := range subs {
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			pending := 0
+			for _, s := range subs {
+				pending += len(s.ch)
+			}
+			return fmt.Errorf("bus: %d events not handled: %w", pending, ctx.Err())
+		}
+	}
+	return nil
+}
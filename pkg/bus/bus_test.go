@@ -0,0 +1,148 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func event(typ, id string) manager.Event {
+	return manager.Event{Type: typ, Token: &queue.Token{ID: id}, At: time.Now()}
+}
+
+// recorder collects the IDs of the events a subscriber is given
+type recorder struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (r *recorder) handle(e manager.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids = append(r.ids, e.Token.ID)
+}
+
+func (r *recorder) got() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.ids...)
+}
+
+func TestDelivery(t *testing.T) {
+	b := New(DefaultConfig())
+	var all, prepared recorder
+	b.Subscribe(all.handle)
+	b.Add("prepared", prepared.handle, Types(manager.EventPrepared))
+	b.Add("panics", func(manager.Event) { panic("boom") })
+
+	for i, typ := range []string{manager.EventCreated, manager.EventPrepared, manager.EventCreated, manager.EventPrepared} {
+		b.Publish(event(typ, string(rune('a'+i))))
+	}
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := all.got(); len(got) != 4 || got[0] != "a" || got[3] != "d" {
+		t.Errorf("every event: %v", got)
+	}
+	if got := prepared.got(); len(got) != 2 || got[0] != "b" || got[1] != "d" {
+		t.Errorf("prepared events: %v", got)
+	}
+	stats := b.Stats()
+	if len(stats) != 3 || stats[0].Name != "subscriber 1" || stats[0].Delivered != 4 || stats[2].Delivered != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	b.Publish(event(manager.EventCreated, "late"))
+	if got := all.got(); len(got) != 4 {
+		t.Errorf("delivered after close: %v", got)
+	}
+}
+
+func TestBackpressure(t *testing.T) {
+	b := New(Config{Buffer: 1})
+	release := make(chan struct{})
+	var slow, lossy recorder
+	dropping := b.Add("lossy", func(e manager.Event) { <-release; lossy.handle(e) }, Lossy())
+	b.Add("slow", func(e manager.Event) { <-release; slow.handle(e) })
+
+	// With one event in each handler and one buffered, a third is dropped
+	// for the lossy subscriber and has to wait for the slow one
+	published := make(chan struct{})
+	go func() {
+		for _, id := range []string{"a", "b", "c"} {
+			b.Publish(event(manager.EventCreated, id))
+		}
+		close(published)
+	}()
+	select {
+	case <-published:
+		t.Fatal("publisher did not wait for the slow subscriber")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got := dropping.dropped.Load(); got == 0 {
+		t.Error("lossy subscriber dropped nothing")
+	}
+	close(release)
+	<-published
+
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := slow.got(); len(got) != 3 {
+		t.Errorf("slow subscriber handled %v, want every event", got)
+	}
+	if got := lossy.got(); len(got) == 3 {
+		t.Errorf("lossy subscriber handled %v, want some dropped", got)
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	b := New(DefaultConfig())
+	stuck := make(chan struct{})
+	defer close(stuck)
+	b.Add("stuck", func(manager.Event) { <-stuck })
+	b.Publish(event(manager.EventCreated, "a"))
+	b.Publish(event(manager.EventCreated, "b"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("close with a stuck subscriber: %v", err)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	b := New(DefaultConfig())
+	var r recorder
+	s := b.Add("", r.handle)
+	b.Publish(event(manager.EventCreated, "a"))
+	s.Unsubscribe()
+	<-s.done
+	b.Publish(event(manager.EventCreated, "b"))
+	if got := r.got(); len(got) != 1 || got[0] != "a" {
+		t.Errorf("handled %v, want the event before unsubscribing", got)
+	}
+	if len(b.Stats()) != 0 {
+		t.Errorf("stats after unsubscribing = %+v", b.Stats())
+	}
+}
+
+func TestAttach(t *testing.T) {
+	om := manager.New(manager.DefaultConfig())
+	b := New(DefaultConfig())
+	b.Attach(om)
+	var r recorder
+	b.Add("", r.handle, Types(manager.EventCreated))
+	if _, err := om.AddOrder(context.Background(), "tea", 1); err != nil {
+		t.Fatal(err)
+	}
+	b.Close(context.Background())
+	if got := r.got(); len(got) != 1 {
+		t.Errorf("created events = %v", got)
+	}
+}
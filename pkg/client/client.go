@@ -0,0 +1,227 @@
+// Package client is a Go client for the order service's v1 JSON API.
+//
+// Requests are retried with exponential backoff when the server is rate
+// limiting, overloaded or briefly unreachable. Requests that change state are
+// only retried when the server refused them (429 or 503), never after a
+// network error that may have hidden a success, so an order is not placed
+// twice.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/queue"
+	"awesomeProject/pkg/tracing"
+	"awesomeProject/pkg/validate"
+)
+
+// Defaults for the retry policy
+const (
+	DefaultRetries   = 3
+	DefaultRetryWait = 200 * time.Millisecond
+	maxRetryWait     = 10 * time.Second
+)
+
+// Client calls one order service
+type Client struct {
+	base      *url.URL
+	http      *http.Client
+	retries   int
+	retryWait time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc instead of http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithRetries sets how many times a failed request is retried and the wait
+// before the first retry, which doubles on each further attempt. Zero
+// retries disables retrying.
+func WithRetries(n int, wait time.Duration) Option {
+	return func(c *Client) { c.retries, c.retryWait = n, wait }
+}
+
+// New returns a client for the service at baseURL, such as
+// "http://localhost:8080"
+func New(baseURL string, opts ...Option) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parse base URL: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("base URL %q must be http or https", baseURL)
+	}
+	c := &Client{
+		base:      base,
+		http:      http.DefaultClient,
+		retries:   DefaultRetries,
+		retryWait: DefaultRetryWait,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// APIError is a response the service answered with an error status
+type APIError struct {
+	StatusCode int
+	Message    string
+	Code       string                // Stable code to branch on, such as order_not_found; see the API spec
+	Fields     []validate.FieldError // Which inputs were rejected, for 400 and 422
+	RetryAfter time.Duration         // Suggested wait, for 429 and 503
+	Existing   *queue.Token          // The earlier order, when AddOrder was refused as a duplicate
+	Current    *queue.Token          // The order as it is now, when changed against an older version
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("order service: %d %s", e.StatusCode, e.Message)
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf("; %s %s", f.Field, f.Message)
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is a 404: no such order, or nothing to prepare
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a 409: the order's state does not allow
+// the operation
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// do sends a request with the given query and decodes a successful JSON
+// response into out, retrying as described in the package comment
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, out any) error {
+	u := *c.base
+	u.Path += path
+	u.RawQuery = query.Encode()
+	return c.send(ctx, method, u.String(), nil, out)
+}
+
+// doJSON is do with the input sent as a JSON body, which keeps it out of
+// URLs and access logs
+func (c *Client) doJSON(ctx context.Context, method, path string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+	return c.send(ctx, method, c.base.String()+path, body, out)
+}
+
+func (c *Client) send(ctx context.Context, method, target string, body []byte, out any) error {
+	idempotent := method == http.MethodGet
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		var wait time.Duration
+		retry, wait, err = c.try(ctx, method, target, body, out, idempotent)
+		if !retry || attempt >= c.retries {
+			return err
+		}
+		wait = max(wait, c.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// try makes one attempt, reporting whether it may be retried and any wait
+// the server asked for
+func (c *Client) try(ctx context.Context, method, target string, body []byte, out any, idempotent bool) (bool, time.Duration, error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, rd)
+	if err != nil {
+		return false, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	tracing.Inject(ctx, req.Header) // Joins the caller's trace, if any
+	res, err := c.http.Do(req)
+	if err != nil {
+		var netErr net.Error
+		return idempotent && ctx.Err() == nil && errors.As(err, &netErr), 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		apiErr := readError(res)
+		switch res.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true, apiErr.RetryAfter, apiErr
+		case http.StatusBadGateway, http.StatusGatewayTimeout:
+			return idempotent, 0, apiErr
+		}
+		return false, 0, apiErr
+	}
+	if out == nil {
+		io.Copy(io.Discard, res.Body)
+		return false, 0, nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return false, 0, fmt.Errorf("decode response: %w", err)
+	}
+	return false, 0, nil
+}
+
+// readError decodes an error response
+func readError(res *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+	var body struct {
+		Error    string                `json:"error"`
+		Code     string                `json:"code"`
+		Fields   []validate.FieldError `json:"fields"`
+		Existing *queue.Token          `json:"existing"`
+		Current  *queue.Token          `json:"current"`
+	}
+	if json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&body) == nil && body.Error != "" {
+		apiErr.Message, apiErr.Code, apiErr.Fields = body.Error, body.Code, body.Fields
+		apiErr.Existing, apiErr.Current = body.Existing, body.Current
+	}
+	if secs, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && secs > 0 {
+		apiErr.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return apiErr
+}
+
+// backoff is the wait before retry attempt+1, with jitter so clients that
+// failed together do not retry together
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := maxRetryWait
+	if attempt < 16 {
+		wait = min(c.retryWait<<attempt, maxRetryWait)
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/httpapi"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	cfg := httpapi.DefaultConfig()
+	cfg.RateLimits = httpapi.RateLimitConfig{}
+	srv := httptest.NewServer(httpapi.New(manager.New(manager.DefaultConfig()), cfg))
+	t.Cleanup(srv.Close)
+	c, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestOrders(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	soup, err := c.AddOrder(ctx, NewOrder{Item: "soup", Priority: 2, Table: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if soup.Table != 4 || soup.OrderType != queue.OrderDineIn {
+		t.Fatalf("placed %+v", soup)
+	}
+	if _, err := c.AddOrder(ctx, NewOrder{Item: "tea", Priority: 1}); err != nil {
+		t.Fatal(err)
+	}
+	var apiErr *APIError
+	if _, err := c.AddOrder(ctx, NewOrder{Item: "cake", Priority: -1}); err == nil || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity || len(apiErr.Fields) == 0 {
+		t.Fatalf("invalid priority err = %v", err)
+	}
+
+	next, err := c.PrepareNext(ctx)
+	if err != nil || next.Item != "tea" {
+		t.Fatalf("PrepareNext = %+v, %v", next, err)
+	}
+	list, err := c.ListOrders(ctx, ListFilter{Status: queue.StatusPreparing})
+	if err != nil || list.Total != 1 || list.Orders[0].ID != soup.ID {
+		t.Fatalf("ListOrders = %+v, %v", list, err)
+	}
+	notes := "no croutons"
+	if _, err := c.ModifyOrder(ctx, soup.ID, OrderChanges{Notes: &notes, Version: soup.Version}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CancelOrderVersion(ctx, soup.ID, soup.Version); !IsConflict(err) || !errors.As(err, &apiErr) || apiErr.Code != "stale_version" || apiErr.Current.Notes != notes {
+		t.Fatalf("cancel of an outdated version err = %v", err)
+	}
+	if _, err := c.CancelOrder(ctx, soup.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PrepareNext(ctx); !IsNotFound(err) {
+		t.Fatalf("PrepareNext on an empty queue err = %v", err)
+	}
+	if _, err := c.CancelOrder(ctx, soup.ID); !IsConflict(err) {
+		t.Fatalf("cancel twice err = %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"station \"grill\" is full"}`))
+			return
+		}
+		w.Write([]byte(`{"id":"7"}`))
+	}))
+	defer srv.Close()
+
+	c, _ := New(srv.URL, WithRetries(3, time.Millisecond))
+	tok, err := c.AddOrder(context.Background(), NewOrder{Item: "steak"})
+	if err != nil || tok.ID != "7" || calls.Load() != 3 {
+		t.Fatalf("AddOrder = %+v, %v after %d calls", tok, err, calls.Load())
+	}
+
+	calls.Store(0)
+	c, _ = New(srv.URL, WithRetries(0, 0))
+	if _, err := c.AddOrder(context.Background(), NewOrder{Item: "steak"}); err == nil || calls.Load() != 1 {
+		t.Fatalf("without retries: err = %v after %d calls", err, calls.Load())
+	}
+}
+
+func TestWatchOrders(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	grill := "grill"
+	events, err := c.WatchOrders(ctx, WatchFilter{Station: &grill})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AddOrder(ctx, NewOrder{Item: "tea", Priority: 1, Station: "bar"})
+	c.AddOrder(ctx, NewOrder{Item: "steak", Priority: 1, Station: "grill"})
+
+	select {
+	case e := <-events:
+		if e.Type != manager.EventCreated || e.Token.Item != "steak" {
+			t.Fatalf("event = %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+	cancel()
+	for range events {
+	}
+}
@@ -0,0 +1,350 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// NewOrder describes an order to place. Zero fields are left to the server's
+// defaults.
+type NewOrder struct {
+	Item        string
+	Priority    int
+	Quantity    int
+	Notes       string
+	Flags       []string // Allergy and dietary flags from the queue package
+	Station     string
+	OrderType   string // One of the queue order types
+	Channel     string // One of the queue channels; the server derives it when empty
+	Table       int
+	Payment     string    // queue.PaymentUnpaid or queue.PaymentPaid
+	ReadyAt     time.Time // Pre-order ready time
+	Phone       string
+	DeviceToken string
+	Platform    string // Delivery platform the order came through
+	ExternalID  string // The platform's ID for the order
+	Group       string // Table or check linking the order to others
+	NotifyGroup bool   // Announce when the whole group is ready
+	Course      int    // Course within the group; later ones wait for FireCourse
+}
+
+// orderBody is the JSON body for AddOrder
+type orderBody struct {
+	Item        string   `json:"item"`
+	Priority    int      `json:"priority"`
+	Quantity    int      `json:"quantity,omitempty"`
+	Notes       string   `json:"notes,omitempty"`
+	Flags       []string `json:"flags,omitempty"`
+	Station     string   `json:"station,omitempty"`
+	OrderType   string   `json:"orderType,omitempty"`
+	Channel     string   `json:"channel,omitempty"`
+	Table       int      `json:"table,omitempty"`
+	Payment     string   `json:"payment,omitempty"`
+	ReadyAt     string   `json:"readyAt,omitempty"`
+	Phone       string   `json:"phone,omitempty"`
+	DeviceToken string   `json:"deviceToken,omitempty"`
+	Platform    string   `json:"platform,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	Group       string   `json:"group,omitempty"`
+	NotifyGroup bool     `json:"notifyGroup,omitempty"`
+	Course      int      `json:"course,omitempty"`
+}
+
+func (o NewOrder) body() orderBody {
+	b := orderBody{
+		Item: o.Item, Priority: o.Priority, Quantity: o.Quantity, Notes: o.Notes, Flags: o.Flags,
+		Station: o.Station, OrderType: o.OrderType, Channel: o.Channel, Table: o.Table, Payment: o.Payment,
+		Phone: o.Phone, DeviceToken: o.DeviceToken, Platform: o.Platform, ExternalID: o.ExternalID,
+		Group: o.Group, NotifyGroup: o.NotifyGroup, Course: o.Course,
+	}
+	if !o.ReadyAt.IsZero() {
+		b.ReadyAt = o.ReadyAt.Format(time.RFC3339)
+	}
+	return b
+}
+
+// ListFilter selects orders for ListOrders. Zero fields do not filter.
+type ListFilter struct {
+	Status      string
+	From, To    time.Time
+	Item        string // Case-insensitive substring
+	Table       int
+	Group       string
+	OrderType   string
+	Channel     string
+	Payment     string
+	Flags       []string // Orders carrying all of these; queue.FlagAllergy matches any allergy flag
+	MinPriority *int
+	MaxPriority *int
+	Sort        string // id, priority, timestamp or item, prefixed with - for descending
+	Limit       int    // Server default when zero
+	Offset      int
+}
+
+func (f ListFilter) query() url.Values {
+	q := url.Values{}
+	set := func(name, v string) {
+		if v != "" {
+			q.Set(name, v)
+		}
+	}
+	set("status", f.Status)
+	set("item", f.Item)
+	set("group", f.Group)
+	set("orderType", f.OrderType)
+	set("channel", f.Channel)
+	set("payment", f.Payment)
+	set("sort", f.Sort)
+	set("flag", strings.Join(f.Flags, ","))
+	if !f.From.IsZero() {
+		q.Set("from", f.From.Format(time.RFC3339Nano))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.Format(time.RFC3339Nano))
+	}
+	for name, v := range map[string]int{"table": f.Table, "limit": f.Limit, "offset": f.Offset} {
+		if v != 0 {
+			q.Set(name, strconv.Itoa(v))
+		}
+	}
+	if f.MinPriority != nil {
+		q.Set("minPriority", strconv.Itoa(*f.MinPriority))
+	}
+	if f.MaxPriority != nil {
+		q.Set("maxPriority", strconv.Itoa(*f.MaxPriority))
+	}
+	return q
+}
+
+// OrderList is a page of ListOrders results
+type OrderList struct {
+	Orders []*queue.Token `json:"orders"`
+	Total  int            `json:"total"` // Matching orders before limit and offset
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+// AddOrder places an order. The returned token is waitlisted rather than
+// preparing when its station was full.
+func (c *Client) AddOrder(ctx context.Context, o NewOrder) (*queue.Token, error) {
+	var t queue.Token
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/orders", o.body(), &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// PrepareNext marks the next order in the queue as prepared. It returns an
+// error for which IsNotFound is true when the queue is empty.
+func (c *Client) PrepareNext(ctx context.Context) (*queue.Token, error) {
+	return c.prepareNext(ctx, nil)
+}
+
+// PrepareNextAt is PrepareNext for one station's orders
+func (c *Client) PrepareNextAt(ctx context.Context, station string) (*queue.Token, error) {
+	return c.prepareNext(ctx, url.Values{"station": {station}})
+}
+
+func (c *Client) prepareNext(ctx context.Context, q url.Values) (*queue.Token, error) {
+	var t queue.Token
+	if err := c.do(ctx, http.MethodPost, "/v1/orders/next", q, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ClaimNext starts a cook on the next order the server chooses and returns
+// it in progress. A station with its maximum orders in progress answers
+// 409 Conflict.
+func (c *Client) ClaimNext(ctx context.Context) (*queue.Token, error) {
+	return c.claimNext(ctx, nil)
+}
+
+// ClaimNextAt is ClaimNext for one station's orders
+func (c *Client) ClaimNextAt(ctx context.Context, station string) (*queue.Token, error) {
+	return c.claimNext(ctx, url.Values{"station": {station}})
+}
+
+func (c *Client) claimNext(ctx context.Context, q url.Values) (*queue.Token, error) {
+	var t queue.Token
+	if err := c.do(ctx, http.MethodPost, "/v1/orders/claim", q, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ClaimOrder starts a cook on a particular waiting order
+func (c *Client) ClaimOrder(ctx context.Context, id string) (*queue.Token, error) {
+	return c.orderAction(ctx, http.MethodPost, id, "/claim")
+}
+
+// PrepareOrder marks a particular waiting or in-progress order as prepared,
+// out of queue order
+func (c *Client) PrepareOrder(ctx context.Context, id string) (*queue.Token, error) {
+	return c.orderAction(ctx, http.MethodPost, id, "/prepare")
+}
+
+// ListOrders returns the orders matching f
+func (c *Client) ListOrders(ctx context.Context, f ListFilter) (*OrderList, error) {
+	var list OrderList
+	if err := c.do(ctx, http.MethodGet, "/v1/orders", f.query(), &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// SearchOrders returns the orders holding every word of text in their item,
+// notes or customer details, narrowed by f
+func (c *Client) SearchOrders(ctx context.Context, text string, f ListFilter) (*OrderList, error) {
+	q := f.query()
+	q.Set("q", text)
+	var list OrderList
+	if err := c.do(ctx, http.MethodGet, "/v1/search", q, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// MarkUnavailable marks item run out and returns the orders flagged for it
+func (c *Client) MarkUnavailable(ctx context.Context, item string) ([]*queue.Token, error) {
+	return c.availability(ctx, http.MethodPut, item)
+}
+
+// MarkAvailable marks item back and returns the orders cleared, those
+// parked for it queued again
+func (c *Client) MarkAvailable(ctx context.Context, item string) ([]*queue.Token, error) {
+	return c.availability(ctx, http.MethodDelete, item)
+}
+
+// UnavailableItems lists the items marked unavailable
+func (c *Client) UnavailableItems(ctx context.Context) ([]string, error) {
+	var out struct {
+		Items []string `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/unavailable", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Items, nil
+}
+
+// FireCourse lets the next course held for group into the kitchen and
+// returns the orders fired
+func (c *Client) FireCourse(ctx context.Context, group string) ([]*queue.Token, error) {
+	var out struct {
+		Orders []*queue.Token `json:"orders"`
+	}
+	if err := c.send(ctx, http.MethodPost, c.base.String()+"/v1/groups/"+url.PathEscape(group)+"/fire", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Orders, nil
+}
+
+func (c *Client) availability(ctx context.Context, method, item string) ([]*queue.Token, error) {
+	var out struct {
+		Orders []*queue.Token `json:"orders"`
+	}
+	if err := c.send(ctx, method, c.base.String()+"/v1/unavailable/"+url.PathEscape(item), nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Orders, nil
+}
+
+// GetOrder returns one order
+func (c *Client) GetOrder(ctx context.Context, id string) (*queue.Token, error) {
+	return c.orderAction(ctx, http.MethodGet, id, "")
+}
+
+// CancelOrder cancels an order that has not been prepared yet
+func (c *Client) CancelOrder(ctx context.Context, id string) (*queue.Token, error) {
+	return c.orderAction(ctx, http.MethodPost, id, "/cancel")
+}
+
+// CancelOrderVersion cancels an order only while it is still at version,
+// as a token read earlier carries it. An order changed since fails with a
+// conflict whose APIError holds the order as it is now.
+func (c *Client) CancelOrderVersion(ctx context.Context, id, version string) (*queue.Token, error) {
+	var t queue.Token
+	q := url.Values{"version": {version}}
+	if err := c.do(ctx, http.MethodPost, "/v1/orders/"+url.PathEscape(id)+"/cancel", q, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RestoreOrder recovers a recently cancelled order
+func (c *Client) RestoreOrder(ctx context.Context, id string) (*queue.Token, error) {
+	return c.orderAction(ctx, http.MethodPost, id, "/restore")
+}
+
+// OrderChanges lists the fields ModifyOrder sets; nil fields are kept
+type OrderChanges struct {
+	Item     *string
+	Quantity *int
+	Notes    *string
+	Flags    *[]string // An empty list clears the flags
+	Priority *int
+
+	// Version, when set, applies the changes only while the order is still
+	// at that version, as a token read earlier carries it
+	Version string
+}
+
+// ModifyOrder changes an order that has not been prepared yet
+func (c *Client) ModifyOrder(ctx context.Context, id string, ch OrderChanges) (*queue.Token, error) {
+	body := struct {
+		Item     *string   `json:"item,omitempty"`
+		Quantity *int      `json:"quantity,omitempty"`
+		Notes    *string   `json:"notes,omitempty"`
+		Flags    *[]string `json:"flags,omitempty"`
+		Priority *int      `json:"priority,omitempty"`
+		Version  string    `json:"version,omitempty"`
+	}{ch.Item, ch.Quantity, ch.Notes, ch.Flags, ch.Priority, ch.Version}
+	var t queue.Token
+	if err := c.doJSON(ctx, http.MethodPatch, "/v1/orders/"+url.PathEscape(id), body, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// PickUpOrder marks a prepared order as picked up
+func (c *Client) PickUpOrder(ctx context.Context, id string) (*queue.Token, error) {
+	return c.orderAction(ctx, http.MethodPost, id, "/pickup")
+}
+
+// PickUpOrderWithCode marks a prepared order as picked up on presentation of
+// its pickup code
+func (c *Client) PickUpOrderWithCode(ctx context.Context, id string, code string) (*queue.Token, error) {
+	var t queue.Token
+	body := struct {
+		Code string `json:"code"`
+	}{code}
+	if err := c.doJSON(ctx, http.MethodPost, "/v1/orders/"+url.PathEscape(id)+"/pickup", body, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RushOrder moves an order to the front of the queue, recording by as the
+// person who rushed it
+func (c *Client) RushOrder(ctx context.Context, id string, by string) (*queue.Token, error) {
+	var t queue.Token
+	q := url.Values{"by": {by}}
+	if err := c.do(ctx, http.MethodPost, "/v1/orders/"+url.PathEscape(id)+"/rush", q, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (c *Client) orderAction(ctx context.Context, method string, id string, action string) (*queue.Token, error) {
+	var t queue.Token
+	if err := c.do(ctx, method, "/v1/orders/"+url.PathEscape(id)+action, nil, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// Event is a change to an order, as sent by the event stream
+type Event struct {
+	Type  string       `json:"type"`
+	Token *queue.Token `json:"token"`
+	At    time.Time    `json:"at"`
+}
+
+// WatchFilter narrows the events WatchOrders delivers
+type WatchFilter struct {
+	Station *string // Only this station's events; nil for all
+}
+
+// WatchOrders streams order events until ctx is cancelled, then closes the
+// channel. Dropped connections are re-established with backoff; events
+// that happen while disconnected are missed, so callers that need a full
+// picture should reload it with ListOrders after a gap. The first connection
+// is made before WatchOrders returns, and its failure is returned.
+func (c *Client) WatchOrders(ctx context.Context, f WatchFilter) (<-chan Event, error) {
+	u := *c.base
+	u.Path += "/v1/events"
+	if f.Station != nil {
+		u.RawQuery = url.Values{"station": {*f.Station}}.Encode()
+	}
+	target := u.String()
+
+	res, err := c.openStream(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		for attempt := 0; ; {
+			if res != nil {
+				attempt = 0
+				readEvents(ctx, res, events)
+				res.Body.Close()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(c.backoff(attempt)):
+			}
+			attempt++
+			if res, err = c.openStream(ctx, target); err != nil && ctx.Err() == nil {
+				log.Printf("client: reconnect event stream: %v", err)
+			}
+		}
+	}()
+	return events, nil
+}
+
+// openStream connects to the event stream
+func (c *Client) openStream(ctx context.Context, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, readError(res)
+	}
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		res.Body.Close()
+		return nil, fmt.Errorf("event stream answered with %q", ct)
+	}
+	return res, nil
+}
+
+// readEvents decodes server-sent events from res until it ends or ctx is
+// cancelled
+func readEvents(ctx context.Context, res *http.Response, events chan<- Event) {
+	sc := bufio.NewScanner(res.Body)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	var data strings.Builder
+	for sc.Scan() {
+		line := sc.Text()
+		if v, ok := strings.CutPrefix(line, "data:"); ok {
+			data.WriteString(strings.TrimPrefix(v, " "))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			// Comments, event names (also in the data) and other fields
+			continue
+		}
+		var e Event
+		err := json.Unmarshal([]byte(data.String()), &e)
+		data.Reset()
+		if err != nil {
+			log.Printf("client: decode event: %v", err)
+			continue
+		}
+		select {
+		case events <- e:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
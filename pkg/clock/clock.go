@@ -0,0 +1,107 @@
+// Package clock tells the time for code whose behaviour depends on it, such
+// as tie-breaking by order time and expiring orders, so tests can run it on a
+// Fake clock that only moves when told to.
+package clock
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits for it to pass
+type Clock interface {
+	Now() time.Time
+
+	// After returns a channel that receives the time once d has passed
+	After(d time.Duration) <-chan time.Time
+}
+
+// System is the real clock
+var System Clock = system{}
+
+type system struct{}
+
+func (system) Now() time.Time                         { return time.Now() }
+func (system) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Fake is a Clock that stands still until Advance or Set moves it. It is
+// safe for concurrent use.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter // By due time
+}
+
+// waiter is a channel After handed out, to be sent the time at due
+type waiter struct {
+	due time.Time
+	ch  chan time.Time
+}
+
+// NewFake returns a Fake clock reading t
+func NewFake(t time.Time) *Fake {
+	return &Fake{now: t}
+}
+
+// Now returns the time the clock was last moved to
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After returns a channel that receives the time once the clock has been
+// moved on by d. With d zero or less it receives it at once.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	w := waiter{due: f.now.Add(d), ch: ch}
+	i, _ := slices.BinarySearchFunc(f.waiters, w.due, func(w waiter, due time.Time) int {
+		if w.due.After(due) {
+			return 1
+		}
+		return -1 // After those due at the same time, so they fire in order
+	})
+	f.waiters = slices.Insert(f.waiters, i, w)
+	return ch
+}
+
+// Advance moves the clock on by d, firing the waits that come due
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.set(f.now.Add(d))
+}
+
+// Set moves the clock to t, firing the waits that come due. Moving it back
+// fires none.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.set(t)
+}
+
+// set implements Advance and Set; mu must be held
+func (f *Fake) set(t time.Time) {
+	f.now = t
+	n := 0
+	for n < len(f.waiters) && !f.waiters[n].due.After(t) {
+		f.waiters[n].ch <- t
+		n++
+	}
+	f.waiters = slices.Delete(f.waiters, 0, n)
+}
+
+// Waiting reports how many waits have yet to come due, so tests can tell
+// when the code under test has started waiting
+func (f *Fake) Waiting() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
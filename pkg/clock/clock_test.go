@@ -0,0 +1,66 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	if !c.Now().Equal(start) {
+		t.Fatalf("Now = %v", c.Now())
+	}
+	later := c.After(2 * time.Minute)
+	soon := c.After(time.Minute)
+	now := c.After(0)
+	select {
+	case <-now:
+	default:
+		t.Error("zero wait did not fire at once")
+	}
+	if c.Waiting() != 2 {
+		t.Fatalf("waiting = %d", c.Waiting())
+	}
+
+	c.Advance(90 * time.Second)
+	select {
+	case at := <-soon:
+		if !at.Equal(start.Add(90 * time.Second)) {
+			t.Errorf("fired with %v", at)
+		}
+	default:
+		t.Fatal("due wait did not fire")
+	}
+	select {
+	case <-later:
+		t.Fatal("wait fired early")
+	default:
+	}
+
+	c.Set(start)
+	if c.Waiting() != 1 || !c.Now().Equal(start) {
+		t.Fatalf("after moving back: waiting %d, now %v", c.Waiting(), c.Now())
+	}
+	c.Set(start.Add(time.Hour))
+	select {
+	case <-later:
+	default:
+		t.Error("wait did not fire once due")
+	}
+	if c.Waiting() != 0 {
+		t.Errorf("waiting = %d", c.Waiting())
+	}
+}
+
+func TestSystem(t *testing.T) {
+	before := time.Now()
+	if now := System.Now(); now.Before(before) || time.Since(now) > time.Minute {
+		t.Errorf("Now = %v", now)
+	}
+	select {
+	case <-System.After(time.Millisecond):
+	case <-time.After(10 * time.Second):
+		t.Error("After did not fire")
+	}
+}
@@ -0,0 +1,21 @@
+// Package config holds helpers shared by the packages' configuration structs.
+package config
+
+import "time"
+
+// Duration is a time.Duration that reads from and writes to JSON as a
+// string such as "10s" or "15m"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
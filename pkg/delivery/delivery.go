@@ -0,0 +1,400 @@
+// Package delivery tells third-party delivery platforms when the orders they
+// sent are ready for their couriers.
+//
+// Orders placed with a platform and the platform's own order ID are handed,
+// once prepared, to the adapter configured for that platform. Failed
+// deliveries are retried with backoff; those that still fail, or fail in a
+// way retrying cannot fix, are written to a dead-letter file for an operator
+// to follow up.
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/features"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/outbound"
+	"awesomeProject/pkg/queue"
+)
+
+// Notice tells a platform that one of its orders is ready
+type Notice struct {
+	Platform   string    `json:"platform"`
+	ExternalID string    `json:"externalId"` // The platform's ID for the order
+	OrderID    string    `json:"orderId"`    // Ours
+	Number     int       `json:"number"`     // Daily token number called at the counter
+	Item       string    `json:"item"`
+	Quantity   int       `json:"quantity"`
+	PickupCode string    `json:"pickupCode,omitempty"`
+	ReadyAt    time.Time `json:"readyAt"`
+}
+
+// order returns the order n tells of, as far as n records it
+func (n Notice) order() *queue.Token {
+	return &queue.Token{
+		ID:         n.OrderID,
+		Platform:   n.Platform,
+		ExternalID: n.ExternalID,
+		Number:     n.Number,
+		Item:       n.Item,
+		Quantity:   n.Quantity,
+		PickupCode: n.PickupCode,
+		PreparedAt: &n.ReadyAt,
+	}
+}
+
+// Adapter delivers notices to one platform
+type Adapter interface {
+	Name() string
+	OrderReady(ctx context.Context, n Notice) error
+}
+
+// StatusError is returned by adapters for an unsuccessful HTTP response
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Code, http.StatusText(e.Code), e.Body)
+}
+
+// Permanent reports whether retrying cannot help: the platform rejected
+// the request itself rather than failing or throttling it
+func (e *StatusError) Permanent() bool {
+	return e.Code/100 == 4 && e.Code != 408 && e.Code != 429
+}
+
+// Config sets up the platforms and how deliveries are retried
+type Config struct {
+	Platforms []PlatformConfig `json:"platforms"`
+	Timeout   config.Duration  `json:"timeout"` // Per attempt
+
+	// MaxAttempts bounds the attempts per notice, the first included.
+	// Retries wait RetryBackoff, doubling each time up to MaxBackoff, less
+	// up to half at random.
+	MaxAttempts  int             `json:"maxAttempts"`
+	RetryBackoff config.Duration `json:"retryBackoff"`
+	MaxBackoff   config.Duration `json:"maxBackoff"`
+
+	// DeadLetterPath is a JSON-lines file receiving notices that could not
+	// be delivered; they are only logged when it is empty
+	DeadLetterPath string `json:"deadLetterPath"`
+}
+
+// PlatformConfig configures the adapter for one platform
+type PlatformConfig struct {
+	Name string `json:"name"` // As orders give it in their platform field
+	Type string `json:"type"` // "webhook" or "rest"
+	URL  string `json:"url"`  // Webhook target, or the REST API's base URL
+
+	Secret string `json:"secret"` // webhook: HMAC-SHA256 key signing each body
+	APIKey string `json:"apiKey"` // rest: bearer token
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		Timeout:      config.Duration(10 * time.Second),
+		MaxAttempts:  5,
+		RetryBackoff: config.Duration(2 * time.Second),
+		MaxBackoff:   config.Duration(time.Minute),
+	}
+}
+
+// Validate checks the platforms
+func (c Config) Validate() error {
+	seen := make(map[string]bool)
+	for _, p := range c.Platforms {
+		if p.Name == "" || p.URL == "" {
+			return errors.New("delivery platforms need a name and url")
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("delivery platform %q is configured twice", p.Name)
+		}
+		seen[p.Name] = true
+		if _, err := newAdapter(p, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newAdapter(p PlatformConfig, hc *http.Client) (Adapter, error) {
+	switch p.Type {
+	case "webhook":
+		return NewWebhook(p, hc), nil
+	case "rest":
+		return NewREST(p, hc), nil
+	}
+	return nil, fmt.Errorf("delivery platform %q: type must be webhook or rest, got %q", p.Name, p.Type)
+}
+
+// queueSize bounds the notices waiting for delivery
+const queueSize = 256
+
+// DeadLetter is a notice given up on, as the dead-letter file records it
+type DeadLetter struct {
+	Notice   Notice    `json:"notice"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	At       time.Time `json:"at"`
+}
+
+type job struct {
+	adapter  Adapter
+	notice   Notice
+	attempts int
+}
+
+// Dispatcher sends a notice to the platform of every prepared order that
+// came through one
+type Dispatcher struct {
+	cfg      Config
+	adapters map[string]Adapter
+	flags    *features.Set // Notices are only sent while the webhooks flag is on
+	jobs     chan *job
+	done     chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	retries map[*job]*time.Timer // Waiting to go back on jobs
+	dead    *os.File
+}
+
+// New builds a Dispatcher from cfg, which must be valid, sending through hc
+// or http.DefaultClient when it is nil. The dispatcher retries on its own
+// schedule, so hc should make a single attempt per request. Orders prepared
+// while flags has the webhooks flag off are not sent; a nil flags sends
+// them all. Call Attach to start receiving events and Close to stop.
+func New(cfg Config, hc *http.Client, flags *features.Set) (*Dispatcher, error) {
+	d := &Dispatcher{
+		cfg:      cfg,
+		adapters: make(map[string]Adapter),
+		flags:    flags,
+		jobs:     make(chan *job, queueSize),
+		done:     make(chan struct{}),
+		retries:  make(map[*job]*time.Timer),
+	}
+	for _, p := range cfg.Platforms {
+		a, err := newAdapter(p, hc)
+		if err != nil {
+			return nil, err
+		}
+		d.adapters[p.Name] = a
+	}
+	if cfg.DeadLetterPath != "" {
+		f, err := os.OpenFile(cfg.DeadLetterPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("open dead letters: %w", err)
+		}
+		d.dead = f
+	}
+	go d.run()
+	return d, nil
+}
+
+// Attach subscribes the dispatcher to the manager's events, or a bus relaying them
+func (d *Dispatcher) Attach(p manager.Publisher) {
+	p.Subscribe(d.handle)
+}
+
+// Close stops accepting notices and waits for queued ones to be sent.
+// Notices waiting to be retried are dead-lettered.
+func (d *Dispatcher) Close() error {
+	d.mu.Lock()
+	d.closed = true
+	for j, timer := range d.retries {
+		if timer.Stop() {
+			d.deadLetter(j, errors.New("shut down before the retry"))
+		}
+	}
+	d.retries = nil
+	d.mu.Unlock()
+	close(d.jobs)
+	<-d.done
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dead == nil {
+		return nil
+	}
+	err := d.dead.Close()
+	d.dead = nil
+	return err
+}
+
+func (d *Dispatcher) handle(e manager.Event) {
+	t := e.Token
+	if e.Type != manager.EventPrepared || t.Platform == "" || !d.flags.Enabled(features.Webhooks) {
+		return
+	}
+	a, ok := d.adapters[t.Platform]
+	if !ok {
+		log.Printf("delivery: order %s came through %q, which is not configured", t.ID, t.Platform)
+		return
+	}
+	d.enqueue(&job{adapter: a, notice: notice(t)})
+}
+
+func notice(t *queue.Token) Notice {
+	n := Notice{
+		Platform:   t.Platform,
+		ExternalID: t.ExternalID,
+		OrderID:    t.ID,
+		Number:     t.Number,
+		Item:       t.Item,
+		Quantity:   t.Quantity,
+		PickupCode: t.PickupCode,
+	}
+	if t.PreparedAt != nil {
+		n.ReadyAt = *t.PreparedAt
+	}
+	return n
+}
+
+// enqueue hands a job to the sender without blocking the manager
+func (d *Dispatcher) enqueue(j *job) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		d.deadLetter(j, errors.New("shut down"))
+		return
+	}
+	select {
+	case d.jobs <- j:
+	default:
+		d.deadLetter(j, errors.New("delivery queue full"))
+	}
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	for j := range d.jobs {
+		d.attempt(j)
+	}
+}
+
+// attempt delivers j once and, if that fails, schedules a retry or gives up
+func (d *Dispatcher) attempt(j *job) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(d.cfg.Timeout))
+	err := j.adapter.OrderReady(ctx, j.notice)
+	cancel()
+	if err == nil {
+		return
+	}
+	j.attempts++
+	var status *StatusError
+	if errors.As(err, &status) && status.Permanent() || j.attempts >= d.cfg.MaxAttempts {
+		d.mu.Lock()
+		d.deadLetter(j, err)
+		d.mu.Unlock()
+		return
+	}
+	wait := d.backoff(j.attempts)
+	log.Printf("delivery: %s notice for order %s failed, retrying in %s: %v", j.adapter.Name(), j.notice.OrderID, wait, err)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		d.deadLetter(j, err)
+		return
+	}
+	d.retries[j] = time.AfterFunc(wait, func() {
+		d.mu.Lock()
+		if d.retries == nil {
+			// Closed while this was firing
+			d.deadLetter(j, err)
+			d.mu.Unlock()
+			return
+		}
+		delete(d.retries, j)
+		d.mu.Unlock()
+		d.enqueue(j)
+	})
+}
+
+// backoff is the wait before the retry following attempt number n
+func (d *Dispatcher) backoff(n int) time.Duration {
+	return outbound.Backoff(time.Duration(d.cfg.RetryBackoff), time.Duration(d.cfg.MaxBackoff), n)
+}
+
+// Scrub clears the pickup code, and the platform's error, which may echo the
+// order back, from the dead letters of the orders match picks, rewriting the
+// file if any held either. It reports how many it changed. A match only
+// sees the order fields a notice keeps.
+func (d *Dispatcher) Scrub(match func(*queue.Token) bool) (int, error) {
+	if d.cfg.DeadLetterPath == "" {
+		return 0, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, err := os.ReadFile(d.cfg.DeadLetterPath)
+	if err != nil {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	n := 0
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n') + 1
+		if end == 0 {
+			end = len(data)
+		}
+		line := data[:end]
+		data = data[end:]
+		var dl DeadLetter
+		if json.Unmarshal(line, &dl) == nil && (dl.Notice.PickupCode != "" || dl.Error != "") && match(dl.Notice.order()) {
+			dl.Notice.PickupCode, dl.Error = "", ""
+			if line, err = json.Marshal(dl); err != nil {
+				return 0, err
+			}
+			line = append(line, '\n')
+			n++
+		}
+		buf.Write(line)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	tmp := d.cfg.DeadLetterPath + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("scrub dead letters: %w", err)
+	}
+	if err := os.Rename(tmp, d.cfg.DeadLetterPath); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("scrub dead letters: %w", err)
+	}
+	if d.dead == nil {
+		return n, nil
+	}
+	f, err := os.OpenFile(d.cfg.DeadLetterPath, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return n, fmt.Errorf("reopen dead letters: %w", err)
+	}
+	d.dead.Close()
+	d.dead = f
+	return n, nil
+}
+
+// deadLetter records a notice given up on; mu must be held
+func (d *Dispatcher) deadLetter(j *job, err error) {
+	log.Printf("delivery: giving up on %s notice for order %s after %d attempts: %v", j.adapter.Name(), j.notice.OrderID, j.attempts, err)
+	if d.dead == nil {
+		return
+	}
+	data, _ := json.Marshal(DeadLetter{Notice: j.notice, Attempts: j.attempts, Error: err.Error(), At: time.Now()})
+	if _, werr := d.dead.Write(append(data, '\n')); werr != nil {
+		log.Printf("delivery: write dead letter: %v", werr)
+	}
+}
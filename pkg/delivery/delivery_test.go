@@ -0,0 +1,206 @@
+package delivery
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// platform records the requests it gets and answers each with the next of
+// its statuses, then 200
+type platform struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+	got      chan struct{}
+}
+
+func (p *platform) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	p.mu.Lock()
+	p.requests = append(p.requests, r)
+	p.bodies = append(p.bodies, body)
+	status := http.StatusOK
+	if len(p.statuses) > 0 {
+		status, p.statuses = p.statuses[0], p.statuses[1:]
+	}
+	p.mu.Unlock()
+	w.WriteHeader(status)
+	p.got <- struct{}{}
+}
+
+func (p *platform) wait(t *testing.T, n int) {
+	t.Helper()
+	for range n {
+		select {
+		case <-p.got:
+		case <-time.After(2 * time.Second):
+			t.Fatal("no request")
+		}
+	}
+}
+
+func setup(t *testing.T, typ string, statuses ...int) (*platform, *manager.OrderManager, *Dispatcher, Config) {
+	t.Helper()
+	p := &platform{statuses: statuses, got: make(chan struct{}, 10)}
+	srv := httptest.NewServer(p)
+	t.Cleanup(srv.Close)
+	cfg := DefaultConfig()
+	cfg.Platforms = []PlatformConfig{{Name: "eats", Type: typ, URL: srv.URL, Secret: "s3cret", APIKey: "key"}}
+	cfg.MaxAttempts = 3
+	cfg.RetryBackoff = config.Duration(time.Millisecond)
+	cfg.DeadLetterPath = filepath.Join(t.TempDir(), "dead.jsonl")
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	d, err := New(cfg, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	om := manager.New(manager.DefaultConfig())
+	d.Attach(om)
+	return p, om, d, cfg
+}
+
+func readyOrder(t *testing.T, om *manager.OrderManager, platform string) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := om.PlaceOrder(ctx, manager.NewOrder{Item: "ramen", Platform: platform, ExternalID: "EXT-42"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := om.PrepareOrder(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func deadLetters(t *testing.T, path string) []DeadLetter {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var out []DeadLetter
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var dl DeadLetter
+		if err := json.Unmarshal(sc.Bytes(), &dl); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, dl)
+	}
+	return out
+}
+
+func TestWebhookRetries(t *testing.T) {
+	p, om, d, cfg := setup(t, "webhook", http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	readyOrder(t, om, "eats")
+	readyOrder(t, om, "") // Not a delivery order
+	p.wait(t, 3)
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.requests) != 3 {
+		t.Fatalf("%d requests, want 3", len(p.requests))
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(p.bodies[2])
+	if got := p.requests[2].Header.Get(SignatureHeader); got != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("signature = %q", got)
+	}
+	var body struct {
+		Event string `json:"event"`
+		Notice
+	}
+	if err := json.Unmarshal(p.bodies[2], &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Event != "order.ready" || body.ExternalID != "EXT-42" || body.OrderID != "1" || body.Number != 1 || body.ReadyAt.IsZero() {
+		t.Errorf("body = %+v", body)
+	}
+	if dead := deadLetters(t, cfg.DeadLetterPath); len(dead) != 0 {
+		t.Errorf("dead letters = %+v", dead)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	// Rejected requests are not retried
+	p, om, d, cfg := setup(t, "rest", http.StatusNotFound)
+	readyOrder(t, om, "eats")
+	p.wait(t, 1)
+	// Failures are retried MaxAttempts times in all
+	p.mu.Lock()
+	p.statuses = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+	p.mu.Unlock()
+	readyOrder(t, om, "eats")
+	p.wait(t, 3)
+	d.Close()
+
+	r := p.requests[0]
+	if r.URL.Path != "/orders/EXT-42/status" || r.Header.Get("Authorization") != "Bearer key" || r.Header.Get("Idempotency-Key") == "" {
+		t.Errorf("request = %s %s %v", r.Method, r.URL, r.Header)
+	}
+	dead := deadLetters(t, cfg.DeadLetterPath)
+	if len(dead) != 2 || dead[0].Attempts != 1 || dead[0].Notice.OrderID != "1" || dead[1].Attempts != 3 || dead[1].Notice.OrderID != "2" {
+		t.Fatalf("dead letters = %+v", dead)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, platforms := range [][]PlatformConfig{
+		{{Name: "eats", Type: "fax", URL: "http://x"}},
+		{{Name: "eats", Type: "rest"}},
+		{{Name: "eats", Type: "rest", URL: "http://x"}, {Name: "eats", Type: "webhook", URL: "http://y"}},
+	} {
+		if err := (Config{Platforms: platforms}).Validate(); err == nil {
+			t.Errorf("%+v accepted", platforms)
+		}
+	}
+}
+
+func TestScrubDeadLetters(t *testing.T) {
+	p, om, d, cfg := setup(t, "rest", http.StatusNotFound, http.StatusNotFound, http.StatusNotFound)
+	readyOrder(t, om, "eats")
+	readyOrder(t, om, "eats")
+	p.wait(t, 2)
+	waitDead := func(n int) {
+		for deadline := time.Now().Add(2 * time.Second); len(deadLetters(t, cfg.DeadLetterPath)) < n; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %d dead letters", n)
+			}
+		}
+	}
+	waitDead(2)
+
+	n, err := d.Scrub(func(t *queue.Token) bool { return t.ID == "1" })
+	if err != nil || n != 1 {
+		t.Fatalf("scrub = %d, %v", n, err)
+	}
+	// Still appended to after the rewrite
+	readyOrder(t, om, "eats")
+	p.wait(t, 1)
+	waitDead(3)
+	d.Close()
+
+	dead := deadLetters(t, cfg.DeadLetterPath)
+	if len(dead) != 3 || dead[0].Notice.OrderID != "1" || dead[0].Notice.PickupCode != "" || dead[0].Error != "" ||
+		dead[1].Notice.PickupCode == "" || dead[1].Error == "" || dead[2].Notice.OrderID != "3" {
+		t.Errorf("dead letters = %+v", dead)
+	}
+}
@@ -0,0 +1,70 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// SignatureHeader carries the webhook body's HMAC-SHA256 as "sha256=<hex>",
+// as the payment webhook expects it
+const SignatureHeader = "X-Signature"
+
+// Webhook posts each notice as JSON to a URL, for platforms or middleware
+// that accept a generic callback
+type Webhook struct {
+	cfg    PlatformConfig
+	client *http.Client
+}
+
+// NewWebhook sends through hc, or http.DefaultClient when it is nil
+func NewWebhook(cfg PlatformConfig, hc *http.Client) *Webhook {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Webhook{cfg: cfg, client: hc}
+}
+
+func (w *Webhook) Name() string { return w.cfg.Name }
+
+func (w *Webhook) OrderReady(ctx context.Context, n Notice) error {
+	body, err := json.Marshal(struct {
+		Event string `json:"event"`
+		Notice
+	}{"order.ready", n})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.cfg.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.cfg.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return do(w.client, req)
+}
+
+// do sends req and returns a *StatusError for any non-2xx response
+func do(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{Code: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,319 @@
+// Package devices keeps the registry of the kiosks, kitchen displays and
+// printers that talk to the server. Each has an ID, a role and an API key of
+// its own, and a configuration it fetches as it starts, so setting up a new
+// screen takes only its ID and key.
+package devices
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// Device roles
+const (
+	RoleKiosk   = "kiosk"   // Customers place orders at it
+	RoleKDS     = "kds"     // Kitchen display
+	RolePrinter = "printer" // Ticket or receipt printer
+)
+
+// Roles lists every device role
+var Roles = []string{RoleKiosk, RoleKDS, RolePrinter}
+
+var (
+	ErrNotFound = errors.New("device not found")
+	ErrExists   = errors.New("a device with that id is already registered")
+	ErrRole     = errors.New("unknown device role")
+	ErrFilter   = errors.New("unknown order type or status in device filters")
+)
+
+// Config selects the registry file; an empty Path disables the registry
+type Config struct {
+	Path string `json:"path"`
+}
+
+// Enabled reports whether a registry is configured
+func (c Config) Enabled() bool {
+	return c.Path != ""
+}
+
+// Settings is what a device is set up with when it starts
+type Settings struct {
+	Station string  `json:"station,omitempty"` // Station the device places orders for or serves
+	Locale  string  `json:"locale,omitempty"`  // Language of its text; empty for the server's default
+	Filters Filters `json:"filters"`
+}
+
+// Filters limit the orders a display shows. Empty fields do not filter.
+type Filters struct {
+	Stations   []string `json:"stations,omitempty"`   // "" for the default station
+	OrderTypes []string `json:"orderTypes,omitempty"` // Queue order types
+	Statuses   []string `json:"statuses,omitempty"`   // Token statuses
+}
+
+func (f Filters) validate() error {
+	for _, ot := range f.OrderTypes {
+		if !slices.Contains(queue.OrderTypes, ot) {
+			return ErrFilter
+		}
+	}
+	for _, st := range f.Statuses {
+		if !slices.Contains(queue.Statuses, st) {
+			return ErrFilter
+		}
+	}
+	return nil
+}
+
+// Device is a registered device. Its key is never kept, only a hash of it;
+// KeyPrefix tells staff which key a device was given.
+type Device struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	Role      string     `json:"role"`
+	Settings  Settings   `json:"config"`
+	KeyPrefix string     `json:"keyPrefix"`
+	CreatedAt time.Time  `json:"createdAt"`
+	KeyAt     time.Time  `json:"keyAt"`              // When its current key was issued
+	LastSeen  *time.Time `json:"lastSeen,omitempty"` // Last request made with its key
+}
+
+// record is a device as the registry file holds it
+type record struct {
+	Device
+	KeyHash string `json:"keyHash"`
+}
+
+// keyPrefixLength is how much of a key KeyPrefix keeps
+const keyPrefixLength = 8
+
+// Registry holds the registered devices, kept in a JSON file that is
+// rewritten on each change. Last seen times are only written with the next
+// change. It is safe for concurrent use.
+type Registry struct {
+	path string
+
+	mu      sync.Mutex
+	devices map[string]*record
+	byKey   map[string]string // Key hash to device ID
+}
+
+// Open loads the registry cfg selects, starting an empty one when its file
+// does not exist yet
+func Open(cfg Config) (*Registry, error) {
+	reg := &Registry{path: cfg.Path, devices: make(map[string]*record), byKey: make(map[string]string)}
+	data, err := os.ReadFile(cfg.Path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return reg, nil
+	case err != nil:
+		return nil, err
+	}
+	var records []*record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("devices: %s: %w", cfg.Path, err)
+	}
+	for _, rec := range records {
+		reg.devices[rec.ID] = rec
+		reg.byKey[rec.KeyHash] = rec.ID
+	}
+	return reg, nil
+}
+
+// Register adds a device and returns it with its API key, which is shown
+// only this once
+func (reg *Registry) Register(id, name, role string, s Settings) (Device, string, error) {
+	if !slices.Contains(Roles, role) {
+		return Device{}, "", ErrRole
+	}
+	if err := s.Filters.validate(); err != nil {
+		return Device{}, "", err
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, ok := reg.devices[id]; ok {
+		return Device{}, "", ErrExists
+	}
+	now := time.Now()
+	rec := &record{Device: Device{ID: id, Name: name, Role: role, Settings: s, CreatedAt: now}}
+	key := reg.issueKey(rec, now)
+	reg.devices[id] = rec
+	if err := reg.save(); err != nil {
+		delete(reg.devices, id)
+		delete(reg.byKey, rec.KeyHash)
+		return Device{}, "", err
+	}
+	return rec.copy(), key, nil
+}
+
+// Get returns the device with the given ID
+func (reg *Registry) Get(id string) (Device, error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	rec, ok := reg.devices[id]
+	if !ok {
+		return Device{}, ErrNotFound
+	}
+	return rec.copy(), nil
+}
+
+// List returns every device, by ID
+func (reg *Registry) List() []Device {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	list := make([]Device, 0, len(reg.devices))
+	for _, rec := range reg.devices {
+		list = append(list, rec.copy())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Configure replaces a device's settings
+func (reg *Registry) Configure(id string, s Settings) (Device, error) {
+	if err := s.Filters.validate(); err != nil {
+		return Device{}, err
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	rec, ok := reg.devices[id]
+	if !ok {
+		return Device{}, ErrNotFound
+	}
+	prior := rec.Settings
+	rec.Settings = s
+	if err := reg.save(); err != nil {
+		rec.Settings = prior
+		return Device{}, err
+	}
+	return rec.copy(), nil
+}
+
+// RotateKey gives a device a new API key, returned only this once; the old
+// key stops working
+func (reg *Registry) RotateKey(id string) (Device, string, error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	rec, ok := reg.devices[id]
+	if !ok {
+		return Device{}, "", ErrNotFound
+	}
+	prior := *rec
+	delete(reg.byKey, rec.KeyHash)
+	key := reg.issueKey(rec, time.Now())
+	if err := reg.save(); err != nil {
+		delete(reg.byKey, rec.KeyHash)
+		*rec = prior
+		reg.byKey[rec.KeyHash] = id
+		return Device{}, "", err
+	}
+	return rec.copy(), key, nil
+}
+
+// Remove deletes a device; its key stops working
+func (reg *Registry) Remove(id string) error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	rec, ok := reg.devices[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(reg.devices, id)
+	delete(reg.byKey, rec.KeyHash)
+	if err := reg.save(); err != nil {
+		reg.devices[id] = rec
+		reg.byKey[rec.KeyHash] = id
+		return err
+	}
+	return nil
+}
+
+// Authenticate returns the device key belongs to, marking it seen
+func (reg *Registry) Authenticate(key string) (Device, bool) {
+	if key == "" {
+		return Device{}, false
+	}
+	hash := hashKey(key)
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	id, ok := reg.byKey[hash]
+	if !ok {
+		return Device{}, false
+	}
+	rec := reg.devices[id]
+	now := time.Now()
+	rec.LastSeen = &now
+	return rec.copy(), true
+}
+
+// issueKey makes a new key for rec and indexes it; mu must be held
+func (reg *Registry) issueKey(rec *record, now time.Time) string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	key := hex.EncodeToString(b)
+	rec.KeyHash, rec.KeyPrefix, rec.KeyAt = hashKey(key), key[:keyPrefixLength], now
+	reg.byKey[rec.KeyHash] = rec.ID
+	return key
+}
+
+// save rewrites the registry file; mu must be held. It writes and renames,
+// so a crash leaves the old registry or the new one.
+func (reg *Registry) save() error {
+	records := make([]*record, 0, len(reg.devices))
+	for _, rec := range reg.devices {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(reg.path), filepath.Base(reg.path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), reg.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (rec *record) copy() Device {
+	d := rec.Device
+	d.Settings.Filters = Filters{
+		Stations:   slices.Clone(rec.Settings.Filters.Stations),
+		OrderTypes: slices.Clone(rec.Settings.Filters.OrderTypes),
+		Statuses:   slices.Clone(rec.Settings.Filters.Statuses),
+	}
+	if rec.LastSeen != nil {
+		seen := *rec.LastSeen
+		d.LastSeen = &seen
+	}
+	return d
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
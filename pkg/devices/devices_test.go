@@ -0,0 +1,72 @@
+package devices
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	cfg := Config{Path: filepath.Join(t.TempDir(), "devices.json")}
+	reg, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kds := Settings{Station: "grill", Locale: "es", Filters: Filters{Stations: []string{"grill"}, OrderTypes: []string{"dine_in"}}}
+	d, key, err := reg.Register("kds-1", "Grill screen", RoleKDS, kds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Role != RoleKDS || d.Settings.Station != "grill" || len(key) != 48 || d.KeyPrefix != key[:8] {
+		t.Fatalf("registered %+v with key %q", d, key)
+	}
+	if _, _, err := reg.Register("kds-1", "", RoleKDS, Settings{}); !errors.Is(err, ErrExists) {
+		t.Errorf("second register = %v", err)
+	}
+	if _, _, err := reg.Register("pos", "", "till", Settings{}); !errors.Is(err, ErrRole) {
+		t.Errorf("unknown role = %v", err)
+	}
+	if _, _, err := reg.Register("pos", "", RoleKiosk, Settings{Filters: Filters{Statuses: []string{"lost"}}}); !errors.Is(err, ErrFilter) {
+		t.Errorf("unknown status = %v", err)
+	}
+
+	got, ok := reg.Authenticate(key)
+	if !ok || got.ID != "kds-1" || got.LastSeen == nil {
+		t.Fatalf("authenticate = %+v, %v", got, ok)
+	}
+	if _, ok := reg.Authenticate("nope"); ok {
+		t.Error("unknown key accepted")
+	}
+	if _, err := reg.Configure("kds-1", Settings{Station: "fryer"}); err != nil {
+		t.Fatal(err)
+	}
+	_, newKey, err := reg.RotateKey("kds-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reg.Authenticate(key); ok {
+		t.Error("old key still accepted")
+	}
+
+	// The registry survives a restart
+	reg, err = Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok = reg.Authenticate(newKey)
+	if !ok || got.Settings.Station != "fryer" || got.Name != "Grill screen" {
+		t.Fatalf("after reopening = %+v, %v", got, ok)
+	}
+	if list := reg.List(); len(list) != 1 {
+		t.Errorf("list = %+v", list)
+	}
+	if err := reg.Remove("kds-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reg.Authenticate(newKey); ok {
+		t.Error("removed device's key accepted")
+	}
+	if _, err := reg.Get("kds-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get removed = %v", err)
+	}
+}
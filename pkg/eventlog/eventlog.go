@@ -0,0 +1,518 @@
+// Package eventlog persists order events to an append-only, hash-chained
+// file. Each record carries the hash of the one before it, so editing or
+// removing a record breaks the chain and is detected by Read. Replaying the
+// log rebuilds the manager's state.
+package eventlog
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/fault"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// Config selects the log file; an empty Path disables the log
+type Config struct {
+	Path string `json:"path"`
+	Sync bool   `json:"sync"` // fsync after every record
+}
+
+// TypeScrubbed is the type of the record Scrub appends, whose Token holds a
+// ScrubNote rather than a token
+const TypeScrubbed = "scrubbed"
+
+// Record is one line of the log
+type Record struct {
+	Seq      uint64          `json:"seq"`
+	Type     string          `json:"type"`
+	At       time.Time       `json:"at"`
+	Token    json.RawMessage `json:"token"`              // The token as it was after the change
+	Digest   string          `json:"digest,omitempty"`   // SHA-256 of Token as written; the hash covers this, not Token
+	Kept     string          `json:"kept,omitempty"`     // SHA-256 of Token as Scrub would leave it; the hash covers this too
+	Scrubbed bool            `json:"scrubbed,omitempty"` // Token has had personal data erased since
+	Prev     string          `json:"prev"`               // Hash of the previous record
+	Hash     string          `json:"hash"`
+}
+
+// ScrubNote is what a TypeScrubbed record says about the scrub
+type ScrubNote struct {
+	Seqs []uint64 `json:"seqs"` // The records scrubbed
+	Head string   `json:"head"` // Hash of the last record before the scrub
+
+	// Rechained is the first record whose hash was worked out afresh, for
+	// records from before digests, whose hash covers the token itself
+	Rechained uint64 `json:"rechained,omitempty"`
+}
+
+// sum computes the record's chained hash
+func (r *Record) sum() string {
+	h := sha256.New()
+	io.WriteString(h, strconv.FormatUint(r.Seq, 10))
+	io.WriteString(h, "|"+r.Type+"|"+r.At.UTC().Format(time.RFC3339Nano)+"|")
+	if r.Digest != "" {
+		io.WriteString(h, r.Digest)
+	} else {
+		h.Write(r.Token)
+	}
+	if r.Kept != "" {
+		io.WriteString(h, "|"+r.Kept)
+	}
+	io.WriteString(h, "|"+r.Prev)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// verify reports whether r follows prev and holds what was written, or what
+// a scrub left of it
+func (r *Record) verify(prev string) bool {
+	switch {
+	case r.Prev != prev || r.sum() != r.Hash:
+		return false
+	case r.Digest == "":
+		return !r.Scrubbed
+	case !r.Scrubbed:
+		return digest(r.Token) == r.Digest
+	case r.Kept != "":
+		// A scrubbed token no longer matches its digest, but what is left
+		// of it still matches the digest of what a scrub would leave
+		return digest(r.Token) == r.Kept
+	}
+	// Written before records kept that digest, so all a scrubbed token can
+	// be held to is having nothing left to erase
+	t := new(queue.Token)
+	return json.Unmarshal(r.Token, t) == nil && !t.Scrub()
+}
+
+// digest returns the hex SHA-256 of b
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// kept returns the digest of token with its personal data erased, as Scrub
+// leaves it
+func kept(token []byte) (string, error) {
+	t := new(queue.Token)
+	if err := json.Unmarshal(token, t); err != nil {
+		return "", err
+	}
+	t.Scrub()
+	b, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return digest(b), nil
+}
+
+// ErrTampered reports a record whose hash does not match its contents or its
+// predecessor, or one marked scrubbed that no scrub record names
+var ErrTampered = errors.New("event log hash chain broken")
+
+// Log appends events to a file and indexes them by order ID
+type Log struct {
+	cfg  Config
+	mu   sync.Mutex
+	f    *os.File
+	seq  uint64
+	last string
+	byID map[string][]Record
+	err  error // Last failed append, cleared by the next success
+
+	scrubMu sync.Mutex // Held by Scrub, which holds mu only to swap files
+}
+
+// Open verifies the existing log at cfg.Path, if any, and opens it for
+// appending. The records read are returned for replay.
+func Open(cfg Config) (*Log, []Record, error) {
+	records, err := ReadFile(cfg.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+	f, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	l := &Log{cfg: cfg, f: f, byID: make(map[string][]Record)}
+	for _, r := range records {
+		index(l.byID, r)
+	}
+	if n := len(records); n > 0 {
+		l.seq, l.last = records[n-1].Seq, records[n-1].Hash
+	}
+	return l, records, nil
+}
+
+// ReadFile reads and verifies every record in the log at path
+func ReadFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read decodes records from r, checking the hash chain as it goes
+func Read(r io.Reader) ([]Record, error) {
+	return read(r, "")
+}
+
+// read decodes records from r that follow on from the record hashed prev
+func read(r io.Reader, prev string) ([]Record, error) {
+	var records []Record
+	unnamed := make(map[uint64]bool) // Scrubbed records no scrub record has named yet
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return records, fmt.Errorf("event log line %d: %w", line, err)
+		}
+		if !rec.verify(prev) {
+			return records, fmt.Errorf("%w at line %d (seq %d)", ErrTampered, line, rec.Seq)
+		}
+		if rec.Scrubbed {
+			unnamed[rec.Seq] = true
+		}
+		if rec.Type == TypeScrubbed {
+			var note ScrubNote
+			if err := json.Unmarshal(rec.Token, &note); err != nil {
+				return records, fmt.Errorf("event log line %d: %w", line, err)
+			}
+			for _, seq := range note.Seqs {
+				delete(unnamed, seq)
+			}
+		}
+		prev = rec.Hash
+		records = append(records, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return records, err
+	}
+	for _, rec := range records {
+		if unnamed[rec.Seq] {
+			return records, fmt.Errorf("%w: seq %d scrubbed with no scrub record", ErrTampered, rec.Seq)
+		}
+	}
+	return records, nil
+}
+
+// Attach subscribes the log to om's events. Records are written as events
+// happen so the file order matches the order of changes.
+func (l *Log) Attach(om *manager.OrderManager) {
+	om.Subscribe(func(e manager.Event) {
+		if err := l.Append(e); err != nil {
+			log.Printf("eventlog: %v", err)
+		}
+	})
+}
+
+// Append writes e as the next record
+func (l *Log) Append(e manager.Event) error {
+	token, err := json.Marshal(e.Token)
+	if err != nil {
+		return err
+	}
+	rest, err := kept(token)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec := Record{Seq: l.seq + 1, Type: e.Type, At: e.At.UTC(), Token: token, Digest: digest(token), Kept: rest, Prev: l.last}
+	rec.Hash = rec.sum()
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := fault.Write(fault.StoreEventLog); err != nil {
+		l.err = fmt.Errorf("append seq %d: %w", rec.Seq, err)
+		return l.err
+	}
+	if _, err := l.f.Write(append(line, '\n')); err != nil {
+		l.err = fmt.Errorf("append seq %d: %w", rec.Seq, err)
+		return l.err
+	}
+	if l.cfg.Sync {
+		if err := l.f.Sync(); err != nil {
+			l.err = fmt.Errorf("sync seq %d: %w", rec.Seq, err)
+			return l.err
+		}
+	}
+	l.seq, l.last, l.err = rec.Seq, rec.Hash, nil
+	index(l.byID, rec)
+	return nil
+}
+
+// Check reports whether the log is still writable: it fails if the file has
+// gone or the last append failed
+func (l *Log) Check() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	_, err := l.f.Stat()
+	return err
+}
+
+// index records rec in byID under its order ID
+func index(byID map[string][]Record, rec Record) {
+	var ref struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if json.Unmarshal(rec.Token, &ref) != nil {
+		return
+	}
+	// Records from before IDs were strings hold numbers
+	id := string(ref.ID)
+	if json.Unmarshal(ref.ID, &id) != nil && !json.Valid(ref.ID) {
+		return
+	}
+	byID[id] = append(byID[id], rec)
+}
+
+// History returns every record for one order, oldest first
+func (l *Log) History(id string) []Record {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Record(nil), l.byID[id]...)
+}
+
+// Scrub erases the customer's personal data, as Token.Scrub does, from the
+// tokens of every record match picks, and appends a TypeScrubbed record
+// naming them. Hashes cover each token's digest rather than the token, so
+// the chain is left as it was and the log still verifies; only records from
+// before digests have their hashes worked out afresh. The records so far are
+// scrubbed into a new file while appends go on, and the file is swapped once
+// the records appended meanwhile are copied over. It returns the number of
+// records changed.
+func (l *Log) Scrub(match func(*queue.Token) bool) (int, error) {
+	l.scrubMu.Lock()
+	defer l.scrubMu.Unlock()
+	l.mu.Lock()
+	fi, err := l.f.Stat()
+	head := l.last
+	l.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Open(l.cfg.Path)
+	if err != nil {
+		return 0, err
+	}
+	records, err := Read(io.LimitReader(f, fi.Size()))
+	f.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	note, prev := ScrubNote{Head: head}, ""
+	for i := range records {
+		rec := &records[i]
+		if rec.Type != TypeScrubbed {
+			t := new(queue.Token)
+			if err := json.Unmarshal(rec.Token, t); err != nil {
+				return 0, fmt.Errorf("seq %d: %w", rec.Seq, err)
+			}
+			if match(t) && t.Scrub() {
+				if rec.Digest == "" {
+					rec.Digest = digest(rec.Token)
+					if note.Rechained == 0 {
+						note.Rechained = rec.Seq
+					}
+				}
+				if rec.Token, err = json.Marshal(t); err != nil {
+					return 0, err
+				}
+				rec.Scrubbed = true
+				note.Seqs = append(note.Seqs, rec.Seq)
+			}
+		}
+		if note.Rechained != 0 {
+			rechain(rec, prev)
+		}
+		prev = rec.Hash
+	}
+	if len(note.Seqs) == 0 {
+		return 0, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.cfg.Path), filepath.Base(l.cfg.Path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(tmp)
+	if err := writeRecords(w, records); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	byID := make(map[string][]Record)
+	for _, r := range records {
+		index(byID, r)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.swap(tmp, w, fi.Size(), prev, note, byID); err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return len(note.Seqs), nil
+}
+
+// swap finishes the scrubbed file tmp, whose records end with the one hashed
+// prev: it copies over the records appended to the log past size, adds the
+// scrub record and takes tmp's place. mu must be held.
+func (l *Log) swap(tmp *os.File, w *bufio.Writer, size int64, prev string, note ScrubNote, byID map[string][]Record) error {
+	defer tmp.Close()
+	f, err := os.Open(l.cfg.Path)
+	if err != nil {
+		return err
+	}
+	_, err = f.Seek(size, io.SeekStart)
+	var tail []Record
+	if err == nil {
+		tail, err = read(f, note.Head)
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+	for i := range tail {
+		if note.Rechained != 0 {
+			rechain(&tail[i], prev)
+		}
+		prev = tail[i].Hash
+	}
+	body, err := json.Marshal(note)
+	if err != nil {
+		return err
+	}
+	mark := Record{Seq: l.seq + 1, Type: TypeScrubbed, At: time.Now().UTC(), Token: body, Digest: digest(body), Prev: prev}
+	mark.Hash = mark.sum()
+	tail = append(tail, mark)
+	if err := writeRecords(w, tail); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), l.cfg.Path); err != nil {
+		return err
+	}
+	if f, err = os.OpenFile(l.cfg.Path, os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		l.err = fmt.Errorf("reopen after scrub: %w", err)
+		return l.err
+	}
+	l.f.Close()
+	l.f = f
+	for _, r := range tail {
+		index(byID, r)
+	}
+	l.byID, l.seq, l.last = byID, mark.Seq, mark.Hash
+	return nil
+}
+
+// rechain moves rec onto a digest and hashes it afresh to follow prev
+func rechain(rec *Record, prev string) {
+	if rec.Digest == "" {
+		rec.Digest = digest(rec.Token)
+	}
+	rec.Prev = prev
+	rec.Hash = rec.sum()
+}
+
+// writeRecords writes records to w a line each
+func writeRecords(w *bufio.Writer, records []Record) error {
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err == nil {
+			_, err = w.Write(append(line, '\n'))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyTo writes the whole log to w, holding appends off meanwhile so the
+// copy ends on a complete record
+func (l *Log) CopyTo(w io.Writer) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.Open(l.cfg.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Close flushes and closes the file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.f.Sync(); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+// Rebuild returns the latest state of every order described by records
+func Rebuild(records []Record) ([]*queue.Token, error) {
+	latest := make(map[string]*queue.Token)
+	var order []string
+	for _, rec := range records {
+		t := new(queue.Token)
+		if err := json.Unmarshal(rec.Token, t); err != nil {
+			return nil, fmt.Errorf("seq %d: %w", rec.Seq, err)
+		}
+		if rec.Type == TypeScrubbed {
+			continue
+		}
+		if rec.Type == manager.EventArchived {
+			// Taken out of memory by a day close
+			delete(latest, t.ID)
+			continue
+		}
+		if _, seen := latest[t.ID]; !seen {
+			order = append(order, t.ID)
+		}
+		latest[t.ID] = t
+	}
+	tokens := make([]*queue.Token, 0, len(latest))
+	for _, id := range order {
+		if t, ok := latest[id]; ok {
+			tokens = append(tokens, t)
+			delete(latest, id)
+		}
+	}
+	return tokens, nil
+}
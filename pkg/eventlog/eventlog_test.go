@@ -0,0 +1,284 @@
+package eventlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+var start = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// appendAll appends an event for each token, a minute apart
+func appendAll(t *testing.T, l *Log, tokens ...*queue.Token) {
+	t.Helper()
+	for i, tok := range tokens {
+		if err := l.Append(manager.Event{Type: manager.EventCreated, At: start.Add(time.Duration(i) * time.Minute), Token: tok}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	l, records, err := Open(Config{Path: path, Sync: true})
+	if err != nil || len(records) != 0 {
+		t.Fatalf("Open = %v, %v", records, err)
+	}
+	appendAll(t, l, &queue.Token{ID: "1", Item: "cake"}, &queue.Token{ID: "2", Item: "tea"})
+	if err := l.Check(); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	l, records, err = Open(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if len(records) != 2 || records[0].Seq != 1 || records[1].Prev != records[0].Hash {
+		t.Fatalf("reopened = %+v", records)
+	}
+	if h := l.History("2"); len(h) != 1 || h[0].Seq != 2 {
+		t.Errorf("history = %+v", h)
+	}
+
+	// Appends carry on from the last record read
+	appendAll(t, l, &queue.Token{ID: "1", Item: "cake", Status: queue.StatusPrepared})
+	records, err = ReadFile(path)
+	if err != nil || len(records) != 3 || records[2].Seq != 3 {
+		t.Fatalf("after reopening = %+v, %v", records, err)
+	}
+	if h := l.History("1"); len(h) != 2 {
+		t.Errorf("history = %+v", h)
+	}
+}
+
+func TestTampered(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.jsonl")
+	l, _, err := Open(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, &queue.Token{ID: "1", Item: "cake"}, &queue.Token{ID: "2", Item: "tea"}, &queue.Token{ID: "3", Item: "pie"})
+	l.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))[:3]
+
+	for _, tc := range []struct {
+		name  string
+		lines [][]byte
+	}{
+		{"edited", [][]byte{lines[0], bytes.Replace(lines[1], []byte("tea"), []byte("rum"), 1), lines[2]}},
+		{"reordered", [][]byte{lines[0], lines[2], lines[1]}},
+		{"truncated", [][]byte{lines[1], lines[2]}},
+		{"cut out", [][]byte{lines[0], lines[2]}},
+	} {
+		records, err := Read(bytes.NewReader(bytes.Join(tc.lines, nil)))
+		if !errors.Is(err, ErrTampered) {
+			t.Errorf("%s: Read = %v, want ErrTampered", tc.name, err)
+		}
+		// Everything before the break is still returned
+		if tc.name == "edited" && len(records) != 1 {
+			t.Errorf("%s: read %d records before the break", tc.name, len(records))
+		}
+	}
+
+	// A log that does not verify is not opened
+	edited := filepath.Join(dir, "edited.jsonl")
+	os.WriteFile(edited, bytes.Replace(data, []byte("pie"), []byte("tart"), 1), 0o644)
+	if _, _, err := Open(Config{Path: edited}); !errors.Is(err, ErrTampered) {
+		t.Errorf("Open = %v, want ErrTampered", err)
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	var records []Record
+	for _, e := range []struct {
+		typ   string
+		token string
+	}{
+		{manager.EventCreated, `{"id":"1","item":"cake","status":"preparing"}`},
+		{manager.EventCreated, `{"id":2,"item":"tea","status":"preparing"}`}, // IDs were once numbers
+		{manager.EventCreated, `{"id":"3","item":"pie","status":"preparing"}`},
+		{manager.EventPrepared, `{"id":"1","item":"cake","status":"prepared"}`},
+		{manager.EventArchived, `{"id":"3","item":"pie","status":"prepared"}`},
+		{TypeScrubbed, `{"seqs":[2],"head":"x"}`},
+	} {
+		records = append(records, Record{Seq: uint64(len(records) + 1), Type: e.typ, Token: json.RawMessage(e.token)})
+	}
+	tokens, err := Rebuild(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || tokens[0].ID != "1" || tokens[0].Status != queue.StatusPrepared || tokens[1].ID != "2" {
+		t.Fatalf("rebuilt = %+v", tokens)
+	}
+	if _, err := Rebuild([]Record{{Seq: 1, Token: json.RawMessage(`{"id":[]}`)}}); err == nil {
+		t.Error("rebuilt from a token that does not decode")
+	}
+}
+
+func TestScrubKeepsChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	l, _, err := Open(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	appendAll(t, l,
+		&queue.Token{ID: "1", Item: "cake", Phone: "+15550100", Notes: "for Sam"},
+		&queue.Token{ID: "2", Item: "tea", Phone: "+15550199", Notes: "for Jo"},
+		&queue.Token{ID: "1", Item: "cake", Phone: "+15550100", Status: queue.StatusPrepared},
+	)
+	before, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := l.Scrub(func(tok *queue.Token) bool { return tok.ID == "1" })
+	if err != nil || n != 2 {
+		t.Fatalf("Scrub = %d, %v", n, err)
+	}
+	after, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("log no longer verifies: %v", err)
+	}
+	if len(after) != len(before)+1 {
+		t.Fatalf("%d records after scrub, want %d", len(after), len(before)+1)
+	}
+	for i, rec := range before {
+		if after[i].Hash != rec.Hash || after[i].Scrubbed != (i != 1) {
+			t.Errorf("record %d = %+v, was %+v", i, after[i], rec)
+		}
+	}
+	mark := after[len(after)-1]
+	var note ScrubNote
+	if err := json.Unmarshal(mark.Token, &note); err != nil || mark.Type != TypeScrubbed {
+		t.Fatalf("last record = %+v, %v", mark, err)
+	}
+	if !slices.Equal(note.Seqs, []uint64{1, 3}) || note.Head != before[2].Hash || note.Rechained != 0 {
+		t.Errorf("note = %+v", note)
+	}
+	data, _ := os.ReadFile(path)
+	if bytes.Contains(data, []byte("+15550100")) || bytes.Contains(data, []byte("for Sam")) || !bytes.Contains(data, []byte("for Jo")) {
+		t.Errorf("log = %s", data)
+	}
+	if h := l.History("1"); len(h) != 2 || !h[0].Scrubbed {
+		t.Errorf("history = %+v", h)
+	}
+	if tokens, err := Rebuild(after); err != nil || len(tokens) != 2 {
+		t.Errorf("Rebuild = %v, %v", tokens, err)
+	}
+
+	// Appends carry on from the scrub record
+	appendAll(t, l, &queue.Token{ID: "3", Item: "pie"})
+	if records, err := ReadFile(path); err != nil || records[len(records)-1].Prev != mark.Hash {
+		t.Errorf("after appending: %v", err)
+	}
+
+	// Nothing left to erase
+	if n, err := l.Scrub(func(tok *queue.Token) bool { return tok.ID == "1" }); err != nil || n != 0 {
+		t.Errorf("second Scrub = %d, %v", n, err)
+	}
+}
+
+func TestScrubMarkedWithoutNote(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	l, _, err := Open(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, &queue.Token{ID: "1", Item: "cake", Phone: "+15550100"}, &queue.Token{ID: "2", Item: "tea"})
+	l.Close()
+
+	// Passing an edit off as a scrub needs the scrub record too
+	records, _ := ReadFile(path)
+	records[0].Token, records[0].Scrubbed = json.RawMessage(`{"id":"1","item":"pie"}`), true
+	var buf bytes.Buffer
+	for _, rec := range records {
+		line, _ := json.Marshal(rec)
+		buf.Write(append(line, '\n'))
+	}
+	if _, err := Read(&buf); !errors.Is(err, ErrTampered) {
+		t.Errorf("Read = %v, want ErrTampered", err)
+	}
+}
+
+func TestScrubbedStillVerified(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	l, _, err := Open(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, &queue.Token{ID: "1", Item: "cake", Quantity: 2, Phone: "+15550100"}, &queue.Token{ID: "2", Item: "tea"})
+	if _, err := l.Scrub(func(tok *queue.Token) bool { return tok.ID == "1" }); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// What a scrub leaves of a record is still covered by its hash
+	for _, edit := range [][2]string{{`"item":"cake"`, `"item":"pie"`}, {`"quantity":2`, `"quantity":9`}} {
+		edited := bytes.Replace(data, []byte(edit[0]), []byte(edit[1]), 1)
+		if bytes.Equal(edited, data) {
+			t.Fatalf("nothing to edit: %s", data)
+		}
+		if _, err := Read(bytes.NewReader(edited)); !errors.Is(err, ErrTampered) {
+			t.Errorf("%s: Read = %v, want ErrTampered", edit[1], err)
+		}
+	}
+}
+
+func TestScrubRechainsOldRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	// Records from before digests hash the token itself
+	var buf bytes.Buffer
+	prev := ""
+	for i, tok := range []string{`{"id":1,"item":"cake","phone":"+15550100"}`, `{"id":2,"item":"tea"}`} {
+		rec := Record{Seq: uint64(i + 1), Type: manager.EventCreated, At: start, Token: json.RawMessage(tok), Prev: prev}
+		rec.Hash = rec.sum()
+		prev = rec.Hash
+		line, _ := json.Marshal(rec)
+		buf.Write(append(line, '\n'))
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, _, err := Open(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if n, err := l.Scrub(func(tok *queue.Token) bool { return tok.ID == "1" }); err != nil || n != 1 {
+		t.Fatalf("Scrub = %d, %v", n, err)
+	}
+	records, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("log no longer verifies: %v", err)
+	}
+	var note ScrubNote
+	json.Unmarshal(records[len(records)-1].Token, &note)
+	if note.Rechained != 1 || note.Head != prev {
+		t.Errorf("note = %+v", note)
+	}
+	if records[1].Digest == "" || records[1].Hash == prev {
+		t.Errorf("record after the scrubbed one = %+v", records[1])
+	}
+}
@@ -0,0 +1,251 @@
+// Package fairness measures how evenly the queue treats each priority: a
+// histogram of how long the orders of each priority waited to be prepared,
+// kept up as they are, and a report of whether any priority is starving,
+// its oldest waiting order held long past what it should wait.
+package fairness
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// Config sets the histogram buckets and when a priority counts as starving
+type Config struct {
+	// Buckets are the histogram's upper bounds, shortest first
+	Buckets []config.Duration `json:"buckets"`
+
+	// StarveAfter is how long an order may wait before its priority counts
+	// as starving. Zero turns starvation reports off.
+	StarveAfter config.Duration `json:"starveAfter"`
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	var buckets []config.Duration
+	for _, d := range []time.Duration{
+		30 * time.Second, time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute,
+		15 * time.Minute, 20 * time.Minute, 30 * time.Minute, 45 * time.Minute, time.Hour,
+	} {
+		buckets = append(buckets, config.Duration(d))
+	}
+	return Config{Buckets: buckets, StarveAfter: config.Duration(30 * time.Minute)}
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	if len(c.Buckets) == 0 {
+		return errors.New("fairness: buckets must list at least one bound")
+	}
+	for i, b := range c.Buckets {
+		if b <= 0 || (i > 0 && b <= c.Buckets[i-1]) {
+			return errors.New("fairness: buckets must be positive and increasing")
+		}
+	}
+	if c.StarveAfter < 0 {
+		return errors.New("fairness: starveAfter must not be negative")
+	}
+	return nil
+}
+
+// histogram counts waits of one priority
+type histogram struct {
+	counts []uint64 // Per bucket, not cumulative; the last is beyond every bound
+	count  uint64
+	sum    float64 // Seconds
+	max    float64
+}
+
+// Tracker keeps the wait histograms. It is safe for concurrent use.
+type Tracker struct {
+	cfg    Config
+	bounds []float64 // Seconds
+
+	mu      sync.Mutex
+	classes map[int]*histogram
+	since   time.Time
+}
+
+// New returns a Tracker for cfg, which must be valid
+func New(cfg Config) *Tracker {
+	t := &Tracker{cfg: cfg, classes: make(map[int]*histogram), since: time.Now()}
+	for _, b := range cfg.Buckets {
+		t.bounds = append(t.bounds, time.Duration(b).Seconds())
+	}
+	return t
+}
+
+// Attach records the wait of every order p reports prepared
+func (t *Tracker) Attach(p manager.Publisher) {
+	p.Subscribe(func(e manager.Event) {
+		if e.Type == manager.EventPrepared && e.Token.PreparedAt != nil {
+			t.Observe(e.Token.Priority, e.Token.PreparedAt.Sub(queuedAt(e.Token)))
+		}
+	})
+}
+
+// queuedAt is when token started waiting in the queue: when it was placed,
+// or released for a pre-order
+func queuedAt(token *queue.Token) time.Time {
+	if token.ReleaseAt != nil && token.ReleaseAt.After(token.Timestamp) {
+		return *token.ReleaseAt
+	}
+	return token.Timestamp
+}
+
+// Observe records that an order of the given priority waited d
+func (t *Tracker) Observe(priority int, d time.Duration) {
+	secs := max(d.Seconds(), 0)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.classes[priority]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(t.bounds)+1)}
+		t.classes[priority] = h
+	}
+	i, _ := slices.BinarySearch(t.bounds, secs)
+	h.counts[i]++
+	h.count++
+	h.sum += secs
+	h.max = max(h.max, secs)
+}
+
+// Bucket is the number of waits no longer than LE seconds. The last bucket
+// of a class has LE +Inf, which JSON cannot hold; it is left out and the
+// class's Prepared stands for it.
+type Bucket struct {
+	LE    float64 `json:"le"`
+	Count uint64  `json:"count"` // Cumulative, as Prometheus counts them
+}
+
+// Class is how one priority has been treated
+type Class struct {
+	Priority    int      `json:"priority"`
+	Prepared    uint64   `json:"prepared"`
+	SumSeconds  float64  `json:"sumSeconds"`
+	MeanSeconds float64  `json:"meanSeconds"`
+	P50Seconds  float64  `json:"p50Seconds"`
+	P95Seconds  float64  `json:"p95Seconds"`
+	MaxSeconds  float64  `json:"maxSeconds"`
+	Buckets     []Bucket `json:"buckets"`
+
+	// Waiting are the orders of the priority in the queue now, and
+	// OldestWaitSeconds how long the first of them has waited
+	Waiting           int     `json:"waiting"`
+	OldestWaitSeconds float64 `json:"oldestWaitSeconds"`
+
+	// Starving is set while the oldest has waited longer than StarveAfter
+	Starving bool `json:"starving"`
+}
+
+// Report describes the waits recorded since Since, by priority with the
+// most urgent first
+type Report struct {
+	Since    time.Time `json:"since"`
+	Classes  []Class   `json:"classes"`
+	Starving []int     `json:"starving"` // Priorities starving now
+
+	// Spread is how many times longer the slowest priority's median wait
+	// is than the quickest's: 1 when all wait alike. Zero until two
+	// priorities have prepared orders.
+	Spread float64 `json:"spread"`
+}
+
+// Report builds a report from the waits recorded and the orders waiting in
+// the queue at now
+func (t *Tracker) Report(waiting []*queue.Token, now time.Time) Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	classes := make(map[int]*Class)
+	class := func(p int) *Class {
+		c, ok := classes[p]
+		if !ok {
+			c = &Class{Priority: p}
+			classes[p] = c
+		}
+		return c
+	}
+	for p, h := range t.classes {
+		c := class(p)
+		c.Prepared, c.SumSeconds = h.count, h.sum
+		c.MeanSeconds = round(h.sum / float64(h.count))
+		c.P50Seconds = round(t.quantile(h, 0.5))
+		c.P95Seconds = round(t.quantile(h, 0.95))
+		c.MaxSeconds = round(h.max)
+		var cum uint64
+		for i, le := range t.bounds {
+			cum += h.counts[i]
+			c.Buckets = append(c.Buckets, Bucket{LE: le, Count: cum})
+		}
+	}
+	for _, token := range waiting {
+		if token.Status != queue.StatusPreparing {
+			continue
+		}
+		c := class(token.Priority)
+		c.Waiting++
+		c.OldestWaitSeconds = max(c.OldestWaitSeconds, round(now.Sub(queuedAt(token)).Seconds()))
+	}
+
+	r := Report{Since: t.since, Classes: make([]Class, 0, len(classes)), Starving: []int{}}
+	starveAfter := time.Duration(t.cfg.StarveAfter).Seconds()
+	for _, c := range classes {
+		if c.Buckets == nil {
+			c.Buckets = []Bucket{}
+		}
+		c.Starving = starveAfter > 0 && c.OldestWaitSeconds > starveAfter
+		r.Classes = append(r.Classes, *c)
+	}
+	sort.Slice(r.Classes, func(i, j int) bool { return r.Classes[i].Priority < r.Classes[j].Priority })
+
+	quickest, slowest := math.Inf(1), 0.0
+	prepared := 0
+	for _, c := range r.Classes {
+		if c.Starving {
+			r.Starving = append(r.Starving, c.Priority)
+		}
+		if c.Prepared > 0 {
+			prepared++
+			quickest, slowest = min(quickest, c.P50Seconds), max(slowest, c.P50Seconds)
+		}
+	}
+	if prepared > 1 && quickest > 0 {
+		r.Spread = round(slowest / quickest)
+	}
+	return r
+}
+
+// quantile estimates the q-th quantile of h by interpolating within the
+// bucket it falls in, as Prometheus's histogram_quantile does; waits beyond
+// the last bound are taken as the longest seen
+func (t *Tracker) quantile(h *histogram, q float64) float64 {
+	rank := q * float64(h.count)
+	var cum uint64
+	for i, n := range h.counts {
+		if n == 0 || float64(cum+n) < rank {
+			cum += n
+			continue
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = t.bounds[i-1]
+		}
+		upper := h.max
+		if i < len(t.bounds) {
+			upper = min(t.bounds[i], h.max)
+		}
+		return lower + (upper-lower)*(rank-float64(cum))/float64(n)
+	}
+	return h.max
+}
+
+func round(f float64) float64 {
+	return math.Round(f*10) / 10
+}
@@ -0,0 +1,97 @@
+package fairness
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/clock"
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestReport(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC))
+	om := manager.New(manager.DefaultConfig(), manager.WithClock(clk))
+	tr := New(DefaultConfig())
+	tr.Attach(om)
+
+	place := func(priority int) *queue.Token {
+		t.Helper()
+		tok, err := om.PlaceOrder(ctx, manager.NewOrder{Item: "tea", Priority: priority})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	for range 4 {
+		place(0)
+	}
+	low := place(5)
+	for i := range 4 {
+		clk.Advance(time.Duration(i+1) * 20 * time.Second)
+		if _, err := om.PrepareOrder(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	place(5)
+	clk.Advance(40 * time.Minute)
+
+	waiting, _, err := om.ListOrders(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := tr.Report(waiting, clk.Now())
+	if len(r.Classes) != 2 {
+		t.Fatalf("classes = %+v", r.Classes)
+	}
+	urgent, slow := r.Classes[0], r.Classes[1]
+	if urgent.Priority != 0 || urgent.Prepared != 4 || urgent.Waiting != 0 || urgent.MaxSeconds != 200 || urgent.Starving {
+		t.Errorf("priority 0 = %+v", urgent)
+	}
+	if urgent.Buckets[0].Count != 1 || urgent.Buckets[len(urgent.Buckets)-1].Count != 4 {
+		t.Errorf("buckets = %+v", urgent.Buckets)
+	}
+	if urgent.P50Seconds < 60 || urgent.P50Seconds > 120 {
+		t.Errorf("p50 = %v", urgent.P50Seconds)
+	}
+	wantOldest := clk.Now().Sub(low.Timestamp).Seconds()
+	if slow.Priority != 5 || slow.Prepared != 0 || slow.Waiting != 2 || slow.OldestWaitSeconds != wantOldest || !slow.Starving {
+		t.Errorf("priority 5 = %+v", slow)
+	}
+	if len(r.Starving) != 1 || r.Starving[0] != 5 || r.Spread != 0 {
+		t.Errorf("starving %v, spread %v", r.Starving, r.Spread)
+	}
+
+	var out strings.Builder
+	if err := WritePrometheus(&out, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE orders_wait_seconds histogram\n",
+		`orders_wait_seconds_bucket{priority="0",le="30"} 1` + "\n",
+		`orders_wait_seconds_bucket{priority="0",le="+Inf"} 4` + "\n",
+		`orders_wait_seconds_count{priority="0"} 4` + "\n",
+		`orders_waiting{priority="5"} 2` + "\n",
+		`orders_priority_starving{priority="5"} 1` + "\n",
+		"orders_wait_spread 0\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("missing %q in\n%s", want, out.String())
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	bad := DefaultConfig()
+	bad.Buckets = []config.Duration{config.Duration(time.Minute), config.Duration(time.Second)}
+	if err := bad.Validate(); err == nil {
+		t.Error("decreasing buckets accepted")
+	}
+}
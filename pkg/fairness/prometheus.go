@@ -0,0 +1,54 @@
+package fairness
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+// WritePrometheus writes r in the Prometheus text exposition format
+func WritePrometheus(w io.Writer, r Report) error {
+	bw := bufio.NewWriter(w)
+	family := func(name, typ, help string) {
+		bw.WriteString("# HELP " + name + " " + help + "\n# TYPE " + name + " " + typ + "\n")
+	}
+	sample := func(name string, priority int, le string, v float64) {
+		bw.WriteString(name + `{priority="` + strconv.Itoa(priority) + `"`)
+		if le != "" {
+			bw.WriteString(`,le="` + le + `"`)
+		}
+		bw.WriteString("} " + strconv.FormatFloat(v, 'g', -1, 64) + "\n")
+	}
+
+	family("orders_wait_seconds", "histogram", "Time prepared orders waited in the queue, by priority.")
+	for _, c := range r.Classes {
+		if c.Prepared == 0 {
+			continue
+		}
+		for _, b := range c.Buckets {
+			sample("orders_wait_seconds_bucket", c.Priority, strconv.FormatFloat(b.LE, 'g', -1, 64), float64(b.Count))
+		}
+		sample("orders_wait_seconds_bucket", c.Priority, "+Inf", float64(c.Prepared))
+		sample("orders_wait_seconds_sum", c.Priority, "", c.SumSeconds)
+		sample("orders_wait_seconds_count", c.Priority, "", float64(c.Prepared))
+	}
+	family("orders_waiting", "gauge", "Orders waiting in the queue, by priority.")
+	for _, c := range r.Classes {
+		sample("orders_waiting", c.Priority, "", float64(c.Waiting))
+	}
+	family("orders_oldest_wait_seconds", "gauge", "How long the oldest waiting order of each priority has waited.")
+	for _, c := range r.Classes {
+		sample("orders_oldest_wait_seconds", c.Priority, "", c.OldestWaitSeconds)
+	}
+	family("orders_priority_starving", "gauge", "1 while a priority's oldest waiting order has waited longer than starveAfter.")
+	for _, c := range r.Classes {
+		v := 0.0
+		if c.Starving {
+			v = 1
+		}
+		sample("orders_priority_starving", c.Priority, "", v)
+	}
+	family("orders_wait_spread", "gauge", "Slowest priority's median wait over the quickest's.")
+	bw.WriteString("orders_wait_spread " + strconv.FormatFloat(r.Spread, 'g', -1, 64) + "\n")
+	return bw.Flush()
+}
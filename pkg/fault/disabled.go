@@ -0,0 +1,7 @@
+//go:build !faults
+
+package fault
+
+// Enabled reports whether faults can be injected: only in builds with the
+// faults tag
+const Enabled = false
@@ -0,0 +1,160 @@
+// Package fault injects failures for testing how the server degrades: failed
+// storage writes, slow request handlers and dropped order events. It is
+// compiled in only with the faults build tag:
+//
+//	go build -tags faults ./cmd/server
+//
+// Without the tag Enabled is false, Set refuses and every hook does nothing,
+// so the checks the rest of the server makes are compiled away. The
+// settings are process-wide, as the hooks sit deep in the storage code.
+package fault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/config"
+)
+
+// Stores whose writes can be failed, as passed to Write
+const (
+	StoreQueue    = "queue"    // The manager's in-memory waiting queue
+	StoreEventLog = "eventlog" // Event log appends
+	StoreArchive  = "archive"  // Closed days written to the archive
+	StoreAudit    = "audit"    // Audit log entries
+	StoreWAL      = "wal"      // Queue write-ahead log appends
+)
+
+// Stores lists every store Write is called for
+var Stores = []string{StoreQueue, StoreEventLog, StoreArchive, StoreAudit, StoreWAL}
+
+// ErrInjected is returned by the writes Write fails
+var ErrInjected = errors.New("injected storage failure")
+
+// ErrDisabled is returned by Set in builds without the faults tag
+var ErrDisabled = errors.New("fault injection is not built in; build with -tags faults")
+
+// Settings choose which faults to inject. Rates are the chance, from 0 to 1,
+// that each write, request or event is hit. The zero Settings inject
+// nothing.
+type Settings struct {
+	// WriteFailureRate fails writes to the stores listed in Stores, or to
+	// every store when it is empty
+	WriteFailureRate float64  `json:"writeFailureRate"`
+	Stores           []string `json:"stores,omitempty"`
+
+	// SlowRate holds requests for Delay before their handler runs, inside
+	// the route's timeout
+	SlowRate float64         `json:"slowRate"`
+	Delay    config.Duration `json:"delay"`
+
+	// DropRate keeps events from each listener the manager delivers them to
+	DropRate float64 `json:"dropRate"`
+}
+
+// Validate checks the settings
+func (s Settings) Validate() error {
+	for _, rate := range []float64{s.WriteFailureRate, s.SlowRate, s.DropRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("fault rates must be between 0 and 1")
+		}
+	}
+	if s.Delay < 0 {
+		return fmt.Errorf("fault delay must not be negative")
+	}
+	for _, store := range s.Stores {
+		if !slices.Contains(Stores, store) {
+			return fmt.Errorf("unknown store %q, want one of %v", store, Stores)
+		}
+	}
+	return nil
+}
+
+var (
+	mu      sync.RWMutex
+	current Settings
+)
+
+// Set replaces the faults injected from now on
+func Set(s Settings) error {
+	if !Enabled {
+		return ErrDisabled
+	}
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	current = s
+	current.Stores = slices.Clone(s.Stores)
+	return nil
+}
+
+// Current returns the faults being injected
+func Current() Settings {
+	mu.RLock()
+	defer mu.RUnlock()
+	s := current
+	s.Stores = slices.Clone(current.Stores)
+	return s
+}
+
+// Reset stops injecting faults
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	current = Settings{}
+}
+
+// hit reports whether an injection at rate happens this time
+func hit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// Write returns ErrInjected when a write to store is to fail
+func Write(store string) error {
+	if !Enabled {
+		return nil
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if (len(current.Stores) == 0 || slices.Contains(current.Stores, store)) && hit(current.WriteFailureRate) {
+		return fmt.Errorf("%s: %w", store, ErrInjected)
+	}
+	return nil
+}
+
+// Slow holds a request for the configured delay when it is to be slow, or
+// until ctx is done
+func Slow(ctx context.Context) {
+	if !Enabled {
+		return
+	}
+	mu.RLock()
+	delay, slow := time.Duration(current.Delay), hit(current.SlowRate)
+	mu.RUnlock()
+	if !slow || delay <= 0 {
+		return
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// Drop reports whether an event is to be kept from a listener
+func Drop() bool {
+	if !Enabled {
+		return false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	return hit(current.DropRate)
+}
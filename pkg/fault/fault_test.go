@@ -0,0 +1,59 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/config"
+)
+
+func TestFaults(t *testing.T) {
+	defer Reset()
+	if !Enabled {
+		// Without the faults tag nothing is injected, whatever is asked
+		if err := Set(Settings{WriteFailureRate: 1, DropRate: 1}); !errors.Is(err, ErrDisabled) {
+			t.Errorf("set = %v", err)
+		}
+		if err := Write(StoreQueue); err != nil || Drop() {
+			t.Errorf("write = %v, drop = %v", err, Drop())
+		}
+		return
+	}
+
+	if err := Set(Settings{WriteFailureRate: 2}); err == nil {
+		t.Error("rate over 1 accepted")
+	}
+	if err := Set(Settings{WriteFailureRate: 1, Stores: []string{"disk"}}); err == nil {
+		t.Error("unknown store accepted")
+	}
+	if err := Set(Settings{WriteFailureRate: 1, Stores: []string{StoreEventLog}, DropRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(StoreEventLog); !errors.Is(err, ErrInjected) {
+		t.Errorf("event log write = %v", err)
+	}
+	if err := Write(StoreQueue); err != nil {
+		t.Errorf("queue write = %v", err)
+	}
+	if !Drop() {
+		t.Error("event kept")
+	}
+
+	if err := Set(Settings{SlowRate: 1, Delay: config.Duration(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	Slow(ctx)
+	if held := time.Since(start); held < 20*time.Millisecond || held > time.Second {
+		t.Errorf("held %v, want until the context is done", held)
+	}
+
+	Reset()
+	if err := Write(StoreEventLog); err != nil || Drop() {
+		t.Errorf("after reset write = %v, drop = %v", err, Drop())
+	}
+}
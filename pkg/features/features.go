@@ -0,0 +1,242 @@
+// Package features keeps the flags that turn the optional subsystems on and
+// off while the server runs, so a behavior can be rolled out, or taken back
+// when it misbehaves, without a restart. Every flag starts as configured, on
+// unless the config says otherwise; flags set at runtime are kept in a JSON
+// file and outlive restarts until they are reset. A server keeps one
+// restaurant's orders, so each flag is set for the whole server; there are
+// no tenants to set flags for.
+package features
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/clock"
+)
+
+// Flags
+const (
+	KDS        = "kds"        // The kitchen display page and board
+	Aging      = "aging"      // Flagging kitchen display orders that have waited long
+	AutoExpiry = "autoExpiry" // Expiring prepared orders nobody collects
+	Webhooks   = "webhooks"   // Telling delivery platforms their orders are ready
+)
+
+// Names lists every flag
+var Names = []string{KDS, Aging, AutoExpiry, Webhooks}
+
+var descriptions = map[string]string{
+	KDS:        "Serve the kitchen display page and its board",
+	Aging:      "Flag kitchen display orders as warn or late by how long they have waited",
+	AutoExpiry: "Expire prepared orders not collected within the manager's preparedTTL",
+	Webhooks:   "Tell delivery platforms when their orders are ready",
+}
+
+// ErrUnknown reports a flag name that is not one of Names
+var ErrUnknown = errors.New("unknown feature flag")
+
+// Config gives the flags' starting values and where changes are kept
+type Config struct {
+	// Flags turns flags on or off by name; those not listed are on
+	Flags map[string]bool `json:"flags"`
+
+	// Path keeps the flags set at runtime; empty keeps them until the
+	// server stops
+	Path string `json:"path"`
+}
+
+// Validate checks that every flag named is known
+func (c Config) Validate() error {
+	for name := range c.Flags {
+		if !slices.Contains(Names, name) {
+			return fmt.Errorf("%w %q, want one of %s", ErrUnknown, name, strings.Join(Names, ", "))
+		}
+	}
+	return nil
+}
+
+// Flag is a flag as it stands
+type Flag struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Enabled     bool       `json:"enabled"`
+	Default     bool       `json:"default"`             // As configured, which Reset goes back to
+	ChangedBy   string     `json:"changedBy,omitempty"` // Who last set it at runtime
+	ChangedAt   *time.Time `json:"changedAt,omitempty"`
+}
+
+// override is a flag set at runtime, as the file holds it
+type override struct {
+	Enabled   bool      `json:"enabled"`
+	ChangedBy string    `json:"changedBy,omitempty"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// Set holds the flags. A nil Set has every flag on. It is safe for
+// concurrent use.
+type Set struct {
+	path     string
+	defaults map[string]bool
+	clock    clock.Clock // Tells when flags are set; clock.System unless WithClock is given
+
+	mu        sync.RWMutex
+	overrides map[string]override
+}
+
+// Option configures a Set
+type Option func(*Set)
+
+// WithClock makes the set tell when flags are changed by c, such as the
+// manager's clock or a clock.Fake in tests
+func WithClock(c clock.Clock) Option {
+	return func(s *Set) { s.clock = c }
+}
+
+// Open returns the flags cfg configures, with those set at runtime loaded
+// from its file when there is one
+func Open(cfg Config, opts ...Option) (*Set, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	s := &Set{path: cfg.Path, defaults: make(map[string]bool, len(Names)), clock: clock.System, overrides: make(map[string]override)}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for _, name := range Names {
+		on, ok := cfg.Flags[name]
+		s.defaults[name] = on || !ok
+	}
+	if cfg.Path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(cfg.Path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return s, nil
+	case err != nil:
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.overrides); err != nil {
+		return nil, fmt.Errorf("features: %s: %w", cfg.Path, err)
+	}
+	// Flags since retired are forgotten
+	for name := range s.overrides {
+		if !slices.Contains(Names, name) {
+			delete(s.overrides, name)
+		}
+	}
+	return s, nil
+}
+
+// Enabled reports whether the flag name is on
+func (s *Set) Enabled(name string) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if o, ok := s.overrides[name]; ok {
+		return o.Enabled
+	}
+	on, ok := s.defaults[name]
+	return on || !ok
+}
+
+// List returns every flag, by name
+func (s *Set) List() []Flag {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]Flag, 0, len(Names))
+	for _, name := range Names {
+		list = append(list, s.flag(name))
+	}
+	slices.SortFunc(list, func(a, b Flag) int { return strings.Compare(a.Name, b.Name) })
+	return list
+}
+
+// Set turns the flag name on or off until it is reset, noting who did
+func (s *Set) Set(name string, on bool, by string) (Flag, error) {
+	if !slices.Contains(Names, name) {
+		return Flag{}, ErrUnknown
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prior, had := s.overrides[name]
+	s.overrides[name] = override{Enabled: on, ChangedBy: by, ChangedAt: s.clock.Now()}
+	if err := s.save(); err != nil {
+		if had {
+			s.overrides[name] = prior
+		} else {
+			delete(s.overrides, name)
+		}
+		return Flag{}, err
+	}
+	return s.flag(name), nil
+}
+
+// Reset puts the flag name back as configured
+func (s *Set) Reset(name string) (Flag, error) {
+	if !slices.Contains(Names, name) {
+		return Flag{}, ErrUnknown
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prior, had := s.overrides[name]
+	if !had {
+		return s.flag(name), nil
+	}
+	delete(s.overrides, name)
+	if err := s.save(); err != nil {
+		s.overrides[name] = prior
+		return Flag{}, err
+	}
+	return s.flag(name), nil
+}
+
+// flag returns name as it stands; mu must be held
+func (s *Set) flag(name string) Flag {
+	f := Flag{Name: name, Description: descriptions[name], Default: s.defaults[name]}
+	f.Enabled = f.Default
+	if o, ok := s.overrides[name]; ok {
+		at := o.ChangedAt
+		f.Enabled, f.ChangedBy, f.ChangedAt = o.Enabled, o.ChangedBy, &at
+	}
+	return f
+}
+
+// save rewrites the file, if any; mu must be held. It writes and renames,
+// so a crash leaves the old flags or the new ones.
+func (s *Set) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.overrides, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package features
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/clock"
+)
+
+func TestFlagsOutliveRestarts(t *testing.T) {
+	cfg := Config{Flags: map[string]bool{AutoExpiry: false}, Path: filepath.Join(t.TempDir(), "features.json")}
+	s, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Enabled(KDS) || s.Enabled(AutoExpiry) {
+		t.Fatalf("configured: kds %v, autoExpiry %v", s.Enabled(KDS), s.Enabled(AutoExpiry))
+	}
+	if _, err := s.Set(KDS, false, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Set(AutoExpiry, true, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Set("fax", true, "ana"); !errors.Is(err, ErrUnknown) {
+		t.Errorf("set an unknown flag = %v", err)
+	}
+
+	again, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Enabled(KDS) || !again.Enabled(AutoExpiry) {
+		t.Fatalf("reopened: kds %v, autoExpiry %v", again.Enabled(KDS), again.Enabled(AutoExpiry))
+	}
+	f, err := again.Reset(AutoExpiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Enabled || f.Default || f.ChangedBy != "" {
+		t.Errorf("reset = %+v", f)
+	}
+	if reopened, _ := Open(cfg); reopened.Enabled(AutoExpiry) || reopened.Enabled(KDS) {
+		t.Errorf("reset not kept")
+	}
+}
+
+func TestChangedAtByClock(t *testing.T) {
+	at := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	s, err := Open(Config{}, WithClock(clock.NewFake(at)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := s.Set(Aging, false, "ana")
+	if err != nil || f.ChangedAt == nil || !f.ChangedAt.Equal(at) {
+		t.Errorf("set = %+v, %v", f, err)
+	}
+}
+
+func TestNilSetEnablesAll(t *testing.T) {
+	var s *Set
+	for _, name := range Names {
+		if !s.Enabled(name) {
+			t.Errorf("%s off", name)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := (Config{Flags: map[string]bool{"fax": true}}).Validate(); !errors.Is(err, ErrUnknown) {
+		t.Errorf("unknown flag = %v", err)
+	}
+	if err := (Config{Flags: map[string]bool{KDS: false}}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
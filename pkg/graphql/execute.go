@@ -0,0 +1,547 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// object is a response object, keeping its fields in the order they were
+// selected in
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// executor runs one operation, collecting the errors of its fields
+type executor struct {
+	schema *Schema
+	doc    *Document
+	vars   map[string]any // Coerced variables; those not given are absent
+	errs   []*Error
+}
+
+// Execute runs the query or mutation operationName of doc, or its only
+// operation when operationName is empty, with the variables given. A
+// mutation's fields are run one after another, in order. Subscriptions are
+// started with Subscribe instead.
+func (s *Schema) Execute(ctx context.Context, doc *Document, operationName string, variables map[string]any) *Response {
+	e, op, failed := s.prepare(doc, operationName, variables)
+	if failed != nil {
+		return failed
+	}
+	var root *Object
+	switch op.kind {
+	case Query:
+		root = s.query
+	case Mutation:
+		root = s.mutation
+	default:
+		return &Response{Errors: []*Error{{Message: "subscriptions are started with Subscribe", Locations: []Location{op.loc}}}}
+	}
+	data, ok := e.selectionSet(ctx, root, nil, op.selections, nil)
+	return e.response(data, ok)
+}
+
+// Subscribe starts the subscription operationName of doc, or its only
+// operation when operationName is empty, with the variables given. Each
+// event of its root field's Subscribe function is resolved and sent on the
+// channel returned as a response of its own. The channel is closed when
+// the events end or ctx is done. When the subscription cannot be started,
+// the response saying why is returned instead.
+func (s *Schema) Subscribe(ctx context.Context, doc *Document, operationName string, variables map[string]any) (<-chan *Response, *Response) {
+	e, op, failed := s.prepare(doc, operationName, variables)
+	if failed != nil {
+		return nil, failed
+	}
+	if op.kind != Subscription {
+		return nil, &Response{Errors: []*Error{{Message: op.kind + " operations are run with Execute", Locations: []Location{op.loc}}}}
+	}
+	groups := e.collect(s.subscription, op.selections)
+	if len(groups) != 1 || groups[0].fields[0].name == "__typename" {
+		return nil, &Response{Errors: []*Error{{Message: "a subscription must select exactly one top-level field", Locations: []Location{op.loc}}}}
+	}
+	g := groups[0]
+	f := g.fields[0]
+	fd := s.subscription.field(f.name)
+	path := []any{g.key}
+	args, err := e.args(fd, f)
+	var events <-chan any
+	if err == nil {
+		events, err = fd.Subscribe(Params{Context: ctx, Args: args})
+	}
+	if err != nil {
+		e.fail(err, f, path)
+		return nil, &Response{Errors: e.errs}
+	}
+
+	out := make(chan *Response)
+	go func() {
+		defer close(out)
+		for {
+			var ev any
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-events:
+				if !ok {
+					return
+				}
+				ev = v
+			}
+			run := &executor{schema: s, doc: doc, vars: e.vars}
+			v, err := ev, error(nil)
+			if fd.Resolve != nil {
+				v, err = fd.Resolve(Params{Context: ctx, Source: ev, Args: args})
+			}
+			value, ok := run.finish(ctx, fd, g, v, err, path)
+			select {
+			case out <- run.response(object{{g.key, value}}, ok):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// prepare validates doc, picks the operation to run and coerces its
+// variables, or returns the response reporting why it cannot be run
+func (s *Schema) prepare(doc *Document, operationName string, variables map[string]any) (*executor, *operation, *Response) {
+	if errs := s.validate(doc); len(errs) > 0 {
+		return nil, nil, &Response{Errors: errs}
+	}
+	op, err := doc.operation(operationName)
+	if err != nil {
+		return nil, nil, &Response{Errors: []*Error{err}}
+	}
+	e := &executor{schema: s, doc: doc, vars: make(map[string]any)}
+	var errs []*Error
+	for _, def := range op.vars {
+		t := s.typeOf(def.typ)
+		raw, given := variables[def.name]
+		if !given && def.defValue != nil {
+			raw, given = literal(def.defValue, e.vars)
+		}
+		if !given {
+			if _, ok := t.(*NonNull); ok {
+				errs = append(errs, &Error{Message: fmt.Sprintf("variable $%s of type %s is required", def.name, t), Locations: []Location{def.loc}})
+			}
+			continue
+		}
+		v, err := coerce(t, raw)
+		if err != nil {
+			errs = append(errs, &Error{Message: fmt.Sprintf("variable $%s: %v", def.name, err), Locations: []Location{def.loc}})
+			continue
+		}
+		e.vars[def.name] = v
+	}
+	if len(errs) > 0 {
+		return nil, nil, &Response{Errors: errs}
+	}
+	return e, op, nil
+}
+
+func (e *executor) response(data object, ok bool) *Response {
+	r := &Response{Data: data, Errors: e.errs}
+	if !ok {
+		r.Data = null
+	}
+	return r
+}
+
+// group is the fields selected under one response key, merged
+type group struct {
+	key    string
+	fields []*field
+}
+
+// collect gathers the fields sels select on obj by response key, in the
+// order they are first selected, leaving out those skipped by directives
+func (e *executor) collect(obj *Object, sels []selection) []*group {
+	var groups []*group
+	byKey := make(map[string]*group)
+	spreadOnce := make(map[string]bool)
+	var walk func([]selection)
+	walk = func(sels []selection) {
+		for _, sel := range sels {
+			switch sel := sel.(type) {
+			case *field:
+				if !e.included(sel.directives) {
+					continue
+				}
+				g, ok := byKey[sel.key()]
+				if !ok {
+					g = &group{key: sel.key()}
+					byKey[g.key] = g
+					groups = append(groups, g)
+				}
+				g.fields = append(g.fields, sel)
+			case *inlineFragment:
+				if e.included(sel.directives) && (sel.on == "" || sel.on == obj.Name) {
+					walk(sel.selections)
+				}
+			case *spread:
+				if !e.included(sel.directives) || spreadOnce[sel.name] {
+					continue
+				}
+				spreadOnce[sel.name] = true
+				if f := e.doc.fragments[sel.name]; f.on == obj.Name {
+					walk(f.selections)
+				}
+			}
+		}
+	}
+	walk(sels)
+	return groups
+}
+
+// included applies @skip and @include
+func (e *executor) included(ds []*directive) bool {
+	for _, d := range ds {
+		cond := false
+		for _, a := range d.args {
+			if a.name == "if" {
+				v, _ := literal(a.val, e.vars)
+				cond, _ = v.(bool)
+			}
+		}
+		if d.name == "skip" && cond || d.name == "include" && !cond {
+			return false
+		}
+	}
+	return true
+}
+
+// selectionSet resolves the fields sels select on obj, whose value is src.
+// It reports false when a non-null field came out null, which nulls the
+// object itself.
+func (e *executor) selectionSet(ctx context.Context, obj *Object, src any, sels []selection, path []any) (object, bool) {
+	groups := e.collect(obj, sels)
+	out := make(object, 0, len(groups))
+	ok := true
+	for _, g := range groups {
+		v, fine := e.field(ctx, obj, src, g, appendPath(path, g.key))
+		ok = ok && fine
+		out = append(out, member{g.key, v})
+	}
+	if !ok {
+		return nil, false
+	}
+	return out, true
+}
+
+func (e *executor) field(ctx context.Context, obj *Object, src any, g *group, path []any) (any, bool) {
+	f := g.fields[0]
+	if f.name == "__typename" {
+		return obj.Name, true
+	}
+	fd := obj.field(f.name)
+	args, err := e.args(fd, f)
+	if err != nil {
+		return e.finish(ctx, fd, g, nil, err, path)
+	}
+	var v any
+	if fd.Resolve != nil {
+		v, err = fd.Resolve(Params{Context: ctx, Source: src, Args: args})
+	} else if m, ok := src.(map[string]any); ok {
+		v = m[fd.Name]
+	}
+	return e.finish(ctx, fd, g, v, err, path)
+}
+
+// finish completes the value a field resolved to, or records the error it
+// failed with
+func (e *executor) finish(ctx context.Context, fd *Field, g *group, v any, err error, path []any) (any, bool) {
+	if err != nil {
+		e.fail(err, g.fields[0], path)
+		_, nonNull := fd.Type.(*NonNull)
+		return nil, !nonNull
+	}
+	return e.complete(ctx, fd.Type, g.fields, v, path)
+}
+
+// args coerces the arguments given to a field, adding the defaults of
+// those not given
+func (e *executor) args(fd *Field, f *field) (map[string]any, error) {
+	args := make(map[string]any, len(fd.Args))
+	for _, a := range fd.Args {
+		var v any
+		given := false
+		for _, arg := range f.args {
+			if arg.name == a.Name {
+				v, given = literal(arg.val, e.vars)
+			}
+		}
+		if !given {
+			if a.Default != nil {
+				args[a.Name] = a.Default
+			} else if _, ok := a.Type.(*NonNull); ok {
+				return nil, fmt.Errorf("argument %q of type %s is required", a.Name, a.Type)
+			}
+			continue
+		}
+		x, err := coerce(a.Type, v)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %v", a.Name, err)
+		}
+		args[a.Name] = x
+	}
+	return args, nil
+}
+
+// complete converts the value a field resolved to into its response
+// value of type t. It reports false when the value is null in a non-null
+// position, which nulls the parent.
+func (e *executor) complete(ctx context.Context, t Type, fields []*field, v any, path []any) (any, bool) {
+	if nn, ok := t.(*NonNull); ok {
+		if isNil(v) {
+			e.fail(fmt.Errorf("non-null field of type %s resolved to null", t), fields[0], path)
+			return nil, false
+		}
+		out, ok := e.complete(ctx, nn.Of, fields, v, path)
+		return out, ok && out != nil
+	}
+	if isNil(v) {
+		return nil, true
+	}
+	switch t := t.(type) {
+	case *List:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.fail(fmt.Errorf("field of type %s resolved to %T, not a list", t, v), fields[0], path)
+			return nil, true
+		}
+		out := make([]any, rv.Len())
+		ok := true
+		for i := range out {
+			item, fine := e.complete(ctx, t.Of, fields, rv.Index(i).Interface(), appendPath(path, i))
+			ok = ok && fine
+			out[i] = item
+		}
+		if !ok {
+			return nil, true
+		}
+		return out, true
+	case *Object:
+		src, err := jsonMap(v)
+		if err != nil {
+			e.fail(err, fields[0], path)
+			return nil, true
+		}
+		var sels []selection
+		for _, f := range fields {
+			sels = append(sels, f.selections...)
+		}
+		out, ok := e.selectionSet(ctx, t, src, sels, path)
+		if !ok {
+			return nil, true
+		}
+		return out, true
+	case *Enum:
+		if s, ok := v.(string); ok && t.has(s) {
+			return s, true
+		}
+		e.fail(fmt.Errorf("%v is not a value of %s", v, t.Name), fields[0], path)
+		return nil, true
+	case *Scalar:
+		if out, ok := t.Serialize(v); ok {
+			return out, true
+		}
+		e.fail(fmt.Errorf("%T value cannot be serialized as %s", v, t.Name), fields[0], path)
+		return nil, true
+	}
+	return nil, true
+}
+
+// fail records err for the field f at path
+func (e *executor) fail(err error, f *field, path []any) {
+	out := &Error{Message: err.Error(), err: err}
+	var given *Error
+	if errors.As(err, &given) {
+		c := *given
+		out = &c
+	}
+	out.Locations = []Location{f.loc}
+	out.Path = path
+	e.errs = append(e.errs, out)
+}
+
+// appendPath returns path with elem added, leaving path itself as it is
+func appendPath(path []any, elem any) []any {
+	return append(path[:len(path):len(path)], elem)
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// jsonMap returns the JSON object v encodes to, which fields without a
+// Resolve function read their values from
+func jsonMap(v any) (map[string]any, error) {
+	if m, ok := v.(map[string]any); ok {
+		return m, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("%T value is not an object", v)
+	}
+	return m, nil
+}
+
+// unknownValue stands for a variable's value while a document is checked,
+// before any are given
+type unknownValue struct{}
+
+// enumLiteral is an enum value written in a query
+type enumLiteral string
+
+// literal converts a value written in a query to the form of a variable's
+// decoded JSON, substituting variables from vars. A variable that was not
+// given is reported as absent, or as null inside a list. With vars nil,
+// variables are unknownValue.
+func literal(v *value, vars map[string]any) (any, bool) {
+	switch v.kind {
+	case valVariable:
+		if vars == nil {
+			return unknownValue{}, true
+		}
+		x, ok := vars[v.raw]
+		return x, ok
+	case valInt, valFloat:
+		return json.Number(v.raw), true
+	case valString:
+		return v.raw, true
+	case valBoolean:
+		return v.raw == "true", true
+	case valEnum:
+		return enumLiteral(v.raw), true
+	case valList:
+		list := make([]any, len(v.list))
+		for i, item := range v.list {
+			list[i], _ = literal(item, vars)
+		}
+		return list, true
+	case valObject:
+		m := make(map[string]any, len(v.fields))
+		for _, f := range v.fields {
+			if x, ok := literal(f.val, vars); ok {
+				m[f.name] = x
+			}
+		}
+		return m, true
+	}
+	return nil, true
+}
+
+// coerce converts an input value to type t, as resolvers are passed it
+func coerce(t Type, v any) (any, error) {
+	if _, ok := v.(unknownValue); ok {
+		return v, nil
+	}
+	if nn, ok := t.(*NonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("must not be null, want %s", t)
+		}
+		return coerce(nn.Of, v)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch t := t.(type) {
+	case *List:
+		list, ok := v.([]any)
+		if !ok {
+			item, err := coerce(t.Of, v)
+			if err != nil {
+				return nil, err
+			}
+			return []any{item}, nil
+		}
+		out := make([]any, len(list))
+		for i, item := range list {
+			x, err := coerce(t.Of, item)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %v", i, err)
+			}
+			out[i] = x
+		}
+		return out, nil
+	case *Enum:
+		var s string
+		switch v := v.(type) {
+		case enumLiteral:
+			s = string(v)
+		case string:
+			s = v
+		}
+		if !t.has(s) {
+			return nil, fmt.Errorf("%s is not a value of %s", show(v), t.Name)
+		}
+		return s, nil
+	case *Scalar:
+		if _, ok := v.(enumLiteral); !ok {
+			if x, ok := t.Parse(v); ok {
+				return x, nil
+			}
+		}
+		return nil, fmt.Errorf("%s is not a valid %s", show(v), t.Name)
+	}
+	return nil, fmt.Errorf("%s is not a valid %s", show(v), t)
+}
+
+// show writes an input value for an error message
+func show(v any) string {
+	if e, ok := v.(enumLiteral); ok {
+		return string(e)
+	}
+	if _, ok := v.(map[string]any); ok {
+		return "an input object"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
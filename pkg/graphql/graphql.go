@@ -0,0 +1,416 @@
+// Package graphql serves a GraphQL schema defined in Go: it parses and
+// validates query documents and executes their queries, mutations and
+// subscriptions against resolver functions.
+//
+// It implements the executable part of the language: operations with
+// variables, aliases, fragments, inline fragments and the @skip and
+// @include directives. Types are scalars, enums, objects, lists and
+// non-null; there are no interfaces, unions or input objects. Introspection
+// is limited to __typename, and the schema is published as SDL instead.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Type is a GraphQL type: a *Scalar, *Enum, *Object, *List or *NonNull
+type Type interface {
+	// String returns the type as it is written in a query, such as [Order!]!
+	String() string
+}
+
+// Scalar is a leaf type
+type Scalar struct {
+	Name        string
+	Description string
+
+	// Serialize converts a resolved value to its JSON form, reporting
+	// false when the value is not of this type
+	Serialize func(v any) (any, bool)
+
+	// Parse converts an input value, as decoded from a variable's JSON or
+	// taken from a literal, to the value resolvers are passed, reporting
+	// false when it is not valid. Literal numbers come as json.Number.
+	Parse func(v any) (any, bool)
+}
+
+func (s *Scalar) String() string { return s.Name }
+
+// Enum is a leaf type of a fixed set of names, resolved and passed as strings
+type Enum struct {
+	Name        string
+	Description string
+	Values      []string
+}
+
+func (e *Enum) String() string { return e.Name }
+
+func (e *Enum) has(v string) bool {
+	for _, name := range e.Values {
+		if name == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Object is a type with fields
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+}
+
+func (o *Object) String() string { return o.Name }
+
+func (o *Object) field(name string) *Field {
+	for _, f := range o.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// List is a list of values of one type
+type List struct {
+	Of Type
+}
+
+func (l *List) String() string { return "[" + l.Of.String() + "]" }
+
+// NonNull is a type whose values are never null
+type NonNull struct {
+	Of Type
+}
+
+func (n *NonNull) String() string { return n.Of.String() + "!" }
+
+// ListOf returns the list type of t
+func ListOf(t Type) *List { return &List{Of: t} }
+
+// NonNullOf returns the non-null type of t
+func NonNullOf(t Type) *NonNull { return &NonNull{Of: t} }
+
+// Field is a field of an object type
+type Field struct {
+	Name        string
+	Description string
+	Type        Type
+	Args        []*Arg
+
+	// Resolve returns the field's value. Without it the value is the
+	// property of the same name of the parent value: objects are resolved
+	// to their JSON encoding.
+	Resolve func(p Params) (any, error)
+
+	// Subscribe starts a subscription for a root field of the subscription
+	// type, returning the channel its events are sent on. The channel is
+	// closed when the subscription ends, at the latest once the context is
+	// done. Each event is the source value Resolve is called with, or the
+	// field's value without Resolve.
+	Subscribe func(p Params) (<-chan any, error)
+}
+
+// Arg is an argument of a field
+type Arg struct {
+	Name        string
+	Description string
+	Type        Type // A scalar or enum type, or a list or non-null one of those
+	Default     any  // Passed when the argument is not given, if not nil
+}
+
+// Params are what a resolver is called with
+type Params struct {
+	Context context.Context
+	Source  any            // Value of the parent object
+	Args    map[string]any // Arguments given or defaulted; absent ones are left out
+}
+
+// Schema is the set of types a server exposes, rooted at its query,
+// mutation and subscription types
+type Schema struct {
+	query        *Object
+	mutation     *Object // Optional
+	subscription *Object // Optional
+	types        map[string]Type
+}
+
+// NewSchema returns the schema of the root types given, checking its types.
+// mutation and subscription may be nil.
+func NewSchema(query, mutation, subscription *Object) (*Schema, error) {
+	if query == nil {
+		return nil, errors.New("graphql: a schema needs a query type")
+	}
+	s := &Schema{query: query, mutation: mutation, subscription: subscription, types: make(map[string]Type)}
+	for _, t := range []Type{Int, Float, String, Boolean, ID} {
+		s.types[t.String()] = t
+	}
+	for _, root := range []*Object{query, mutation, subscription} {
+		if root == nil {
+			continue
+		}
+		if err := s.add(root); err != nil {
+			return nil, err
+		}
+	}
+	if subscription != nil {
+		for _, f := range subscription.Fields {
+			if f.Subscribe == nil {
+				return nil, fmt.Errorf("graphql: subscription field %s has no Subscribe function", f.Name)
+			}
+		}
+	}
+	return s, nil
+}
+
+// add records t and the types it refers to
+func (s *Schema) add(t Type) error {
+	switch t := t.(type) {
+	case *List:
+		return s.add(t.Of)
+	case *NonNull:
+		if _, ok := t.Of.(*NonNull); ok {
+			return fmt.Errorf("graphql: %s is non-null twice", t)
+		}
+		return s.add(t.Of)
+	case nil:
+		return errors.New("graphql: a field or argument has no type")
+	}
+	name := t.String()
+	if !validName(name) || len(name) > 1 && name[:2] == "__" {
+		return fmt.Errorf("graphql: invalid type name %q", name)
+	}
+	if seen, ok := s.types[name]; ok {
+		if seen != t {
+			return fmt.Errorf("graphql: two types are named %s", name)
+		}
+		return nil
+	}
+	s.types[name] = t
+	switch t := t.(type) {
+	case *Scalar:
+		if t.Serialize == nil || t.Parse == nil {
+			return fmt.Errorf("graphql: scalar %s needs Serialize and Parse functions", name)
+		}
+	case *Enum:
+		for _, v := range t.Values {
+			if !validName(v) || v == "true" || v == "false" || v == "null" {
+				return fmt.Errorf("graphql: invalid value %q of enum %s", v, name)
+			}
+		}
+	case *Object:
+		if len(t.Fields) == 0 {
+			return fmt.Errorf("graphql: object %s has no fields", name)
+		}
+		for i, f := range t.Fields {
+			if !validName(f.Name) || t.field(f.Name) != t.Fields[i] {
+				return fmt.Errorf("graphql: invalid or repeated field %q of %s", f.Name, name)
+			}
+			if err := s.add(f.Type); err != nil {
+				return err
+			}
+			for _, a := range f.Args {
+				if !validName(a.Name) || !isInput(a.Type) {
+					return fmt.Errorf("graphql: invalid argument %q of %s.%s", a.Name, name, f.Name)
+				}
+				if err := s.add(a.Type); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("graphql: unsupported type %T", t)
+	}
+	return nil
+}
+
+// isInput reports whether t may type arguments and variables
+func isInput(t Type) bool {
+	switch t := unwrap(t).(type) {
+	case *Scalar, *Enum:
+		return true
+	case *Object:
+		return false
+	default:
+		return t != nil
+	}
+}
+
+// unwrap returns the named type of t, inside any lists and non-nulls
+func unwrap(t Type) Type {
+	for {
+		switch w := t.(type) {
+		case *List:
+			t = w.Of
+		case *NonNull:
+			t = w.Of
+		default:
+			return t
+		}
+	}
+}
+
+// Request is a GraphQL request as clients send it over HTTP
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is the result of an operation. Data is nil when the operation
+// could not be run at all.
+type Response struct {
+	Data   any      `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// null stands for data that was nulled by an error, which is still sent
+var null = json.RawMessage("null")
+
+// Error is an error in a request or in resolving one of its fields.
+// Resolvers may return one to set its message and extensions; its
+// locations and path are filled in where it happened.
+type Error struct {
+	Message    string         `json:"message"`
+	Locations  []Location     `json:"locations,omitempty"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+
+	err error // What a resolver returned, when it was not an *Error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// The built-in scalars
+var (
+	Int = &Scalar{
+		Name:        "Int",
+		Description: "A signed 32-bit integer",
+		Serialize:   func(v any) (any, bool) { return toInt(v) },
+		Parse:       func(v any) (any, bool) { return toInt(v) },
+	}
+	Float = &Scalar{
+		Name:        "Float",
+		Description: "A double-precision floating-point number",
+		Serialize:   func(v any) (any, bool) { return toFloat(v) },
+		Parse:       func(v any) (any, bool) { return toFloat(v) },
+	}
+	String = &Scalar{
+		Name:        "String",
+		Description: "UTF-8 text",
+		Serialize: func(v any) (any, bool) {
+			switch v := v.(type) {
+			case string:
+				return v, true
+			case fmt.Stringer:
+				return v.String(), true
+			}
+			return nil, false
+		},
+		Parse: func(v any) (any, bool) {
+			s, ok := v.(string)
+			return s, ok
+		},
+	}
+	Boolean = &Scalar{
+		Name:        "Boolean",
+		Description: "true or false",
+		Serialize: func(v any) (any, bool) {
+			b, ok := v.(bool)
+			return b, ok
+		},
+		Parse: func(v any) (any, bool) {
+			b, ok := v.(bool)
+			return b, ok
+		},
+	}
+	ID = &Scalar{
+		Name:        "ID",
+		Description: "An opaque identifier, serialized as a string",
+		Serialize:   toID,
+		Parse:       toID,
+	}
+)
+
+func toInt(v any) (any, bool) {
+	var n int64
+	switch v := v.(type) {
+	case int:
+		n = int64(v)
+	case int32:
+		n = int64(v)
+	case int64:
+		n = v
+	case json.Number:
+		i, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		n = i
+	case float64:
+		if v != math.Trunc(v) || math.Abs(v) > math.MaxInt32 {
+			return nil, false
+		}
+		n = int64(v)
+	default:
+		return nil, false
+	}
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		return nil, false
+	}
+	return int(n), true
+}
+
+func toFloat(v any) (any, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, !math.IsInf(v, 0) && !math.IsNaN(v)
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return nil, false
+}
+
+func toID(v any) (any, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case int, int64:
+		return fmt.Sprint(v), true
+	case json.Number:
+		if _, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return string(v), true
+		}
+	}
+	return nil, false
+}
+
+// sortedNames returns the names of the schema's types, sorted
+func (s *Schema) sortedNames() []string {
+	names := make([]string, 0, len(s.types))
+	for name := range s.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
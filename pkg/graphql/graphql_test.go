@@ -0,0 +1,297 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testDish struct {
+	Name  string   `json:"name"`
+	Price float64  `json:"price"`
+	Tags  []string `json:"tags,omitempty"`
+	Kind  string   `json:"kind"`
+}
+
+func testSchema(t *testing.T) *Schema {
+	t.Helper()
+	kind := &Enum{Name: "Kind", Values: []string{"starter", "main"}}
+	dish := &Object{Name: "Dish", Description: "Something on the menu", Fields: []*Field{
+		{Name: "name", Type: NonNullOf(String)},
+		{Name: "price", Type: Float},
+		{Name: "tags", Type: ListOf(NonNullOf(String))},
+		{Name: "kind", Type: kind},
+		{Name: "broken", Type: NonNullOf(String), Resolve: func(Params) (any, error) {
+			return nil, errors.New("out of stock")
+		}},
+	}}
+	menu := []testDish{{Name: "soup", Price: 4.5, Kind: "starter"}, {Name: "stew", Price: 9, Tags: []string{"hot"}, Kind: "main"}}
+	query := &Object{Name: "Query", Fields: []*Field{
+		{Name: "dishes", Type: NonNullOf(ListOf(NonNullOf(dish))), Args: []*Arg{
+			{Name: "kind", Type: kind},
+			{Name: "limit", Type: Int, Default: 10, Description: "At most this many"},
+		}, Resolve: func(p Params) (any, error) {
+			var out []testDish
+			for _, d := range menu {
+				if k, ok := p.Args["kind"]; ok && k != d.Kind {
+					continue
+				}
+				if len(out) < p.Args["limit"].(int) {
+					out = append(out, d)
+				}
+			}
+			return out, nil
+		}},
+		{Name: "dish", Type: dish, Args: []*Arg{{Name: "name", Type: NonNullOf(String)}}, Resolve: func(p Params) (any, error) {
+			for _, d := range menu {
+				if d.Name == p.Args["name"] {
+					return d, nil
+				}
+			}
+			return nil, nil
+		}},
+	}}
+	var log []string
+	mutation := &Object{Name: "Mutation", Fields: []*Field{
+		{Name: "order", Type: NonNullOf(String), Args: []*Arg{{Name: "dish", Type: NonNullOf(String)}}, Resolve: func(p Params) (any, error) {
+			if p.Args["dish"] == "nothing" {
+				return nil, &Error{Message: "no such dish", Extensions: map[string]any{"status": 404}}
+			}
+			log = append(log, p.Args["dish"].(string))
+			return strings.Join(log, ","), nil
+		}},
+	}}
+	subscription := &Object{Name: "Subscription", Fields: []*Field{
+		{Name: "ticks", Type: NonNullOf(Int), Args: []*Arg{{Name: "count", Type: NonNullOf(Int)}}, Subscribe: func(p Params) (<-chan any, error) {
+			n := p.Args["count"].(int)
+			if n < 0 {
+				return nil, errors.New("count must not be negative")
+			}
+			c := make(chan any)
+			go func() {
+				defer close(c)
+				for i := range n {
+					select {
+					case c <- i:
+					case <-p.Context.Done():
+						return
+					}
+				}
+			}()
+			return c, nil
+		}, Resolve: func(p Params) (any, error) {
+			return p.Source.(int) * 10, nil
+		}},
+	}}
+	s, err := NewSchema(query, mutation, subscription)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// run executes query and returns the response as JSON
+func run(t *testing.T, s *Schema, query string, vars map[string]any) string {
+	t.Helper()
+	var resp *Response
+	doc, err := Parse(query)
+	if err != nil {
+		resp = &Response{Errors: []*Error{err.(*Error)}}
+	} else {
+		resp = s.Execute(context.Background(), doc, "", vars)
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestExecute(t *testing.T) {
+	s := testSchema(t)
+	for _, tc := range []struct {
+		name, query string
+		vars        map[string]any
+		want        string
+	}{
+		{"fields in order", `{ dishes { price name } }`, nil,
+			`{"data":{"dishes":[{"price":4.5,"name":"soup"},{"price":9,"name":"stew"}]}}`},
+		{"aliases and arguments", `{ a: dish(name: "stew") { tags kind } b: dish(name: "none") { name } }`, nil,
+			`{"data":{"a":{"tags":["hot"],"kind":"main"},"b":null}}`},
+		{"enum argument and default", `{ dishes(kind: starter) { name } }`, nil,
+			`{"data":{"dishes":[{"name":"soup"}]}}`},
+		{"variables", `query Q($k: Kind, $n: Int = 1) { dishes(kind: $k, limit: $n) { name } }`, map[string]any{"k": "main"},
+			`{"data":{"dishes":[{"name":"stew"}]}}`},
+		{"fragments and directives", `query($more: Boolean!) { dishes { ...Basic ... @include(if: $more) { price } kind @skip(if: true) } }
+			fragment Basic on Dish { name __typename }`, map[string]any{"more": true},
+			`{"data":{"dishes":[{"name":"soup","__typename":"Dish","price":4.5},{"name":"stew","__typename":"Dish","price":9}]}}`},
+		{"merged selections", `{ dish(name: "soup") { name } dish(name: "soup") { price } }`, nil,
+			`{"data":{"dish":{"name":"soup","price":4.5}}}`},
+		{"null propagates to the nearest nullable field", `{ dish(name: "soup") { name broken } }`, nil,
+			`{"data":{"dish":null},"errors":[{"message":"out of stock","locations":[{"line":1,"column":29}],"path":["dish","broken"]}]}`},
+		{"null propagates to the root", `{ dishes { broken } }`, nil,
+			`{"data":null,"errors":[{"message":"out of stock","locations":[{"line":1,"column":12}],"path":["dishes",0,"broken"]},` +
+				`{"message":"out of stock","locations":[{"line":1,"column":12}],"path":["dishes",1,"broken"]}]}`},
+		{"syntax error", `{ dishes { name `, nil,
+			`{"errors":[{"message":"syntax error: unexpected end of query, want a name","locations":[{"line":1,"column":17}]}]}`},
+		{"unknown field", `{ dishes { name calories } }`, nil,
+			`{"errors":[{"message":"unknown field \"calories\" on type Dish","locations":[{"line":1,"column":17}]}]}`},
+		{"missing argument", `{ dish { name } }`, nil,
+			`{"errors":[{"message":"argument \"name\" of type String! is required","locations":[{"line":1,"column":3}]}]}`},
+		{"bad argument", `{ dishes(kind: dessert) { name } }`, nil,
+			`{"errors":[{"message":"argument \"kind\": dessert is not a value of Kind","locations":[{"line":1,"column":16}]}]}`},
+		{"leaf with subfields", `{ dishes { name { x } } }`, nil,
+			`{"errors":[{"message":"field \"name\" of type String! has no subfields","locations":[{"line":1,"column":12}]}]}`},
+		{"variable of the wrong type", `query($n: String) { dishes(limit: $n) { name } }`, nil,
+			`{"errors":[{"message":"variable $n of type String cannot be used where Int is expected","locations":[{"line":1,"column":35}]}]}`},
+		{"required variable", `query($k: Kind!) { dishes(kind: $k) { name } }`, nil,
+			`{"errors":[{"message":"variable $k of type Kind! is required","locations":[{"line":1,"column":7}]}]}`},
+		{"invalid variable", `query($n: Int) { dishes(limit: $n) { name } }`, map[string]any{"n": "ten"},
+			`{"errors":[{"message":"variable $n: \"ten\" is not a valid Int","locations":[{"line":1,"column":7}]}]}`},
+		{"fragment cycle", `{ dishes { ...A } } fragment A on Dish { name ...A }`, nil,
+			`{"errors":[{"message":"fragment \"A\" spreads itself","locations":[{"line":1,"column":47}]}]}`},
+	} {
+		if got := run(t, s, tc.query, tc.vars); got != tc.want {
+			t.Errorf("%s:\n got %s\nwant %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMutationsRunInOrder(t *testing.T) {
+	s := testSchema(t)
+	got := run(t, s, `mutation { a: order(dish: "soup") b: order(dish: "nothing") c: order(dish: "stew") }`, nil)
+	want := `{"data":null,"errors":[{"message":"no such dish","locations":[{"line":1,"column":35}],"path":["b"],"extensions":{"status":404}}]}`
+	if got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+	if got := run(t, s, `mutation { order(dish: "tea") }`, nil); got != `{"data":{"order":"soup,stew,tea"}}` {
+		t.Errorf("after = %s", got)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	s := testSchema(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	doc, err := Parse(`subscription { t: ticks(count: 3) }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, failed := s.Subscribe(ctx, doc, "", nil)
+	if failed != nil {
+		t.Fatalf("subscribe: %+v", failed.Errors)
+	}
+	var got []string
+	for resp := range events {
+		data, _ := json.Marshal(resp)
+		got = append(got, string(data))
+	}
+	if want := `{"data":{"t":0}} {"data":{"t":10}} {"data":{"t":20}}`; strings.Join(got, " ") != want {
+		t.Errorf("events = %s", got)
+	}
+
+	for query, want := range map[string]string{
+		`subscription { ticks(count: -1) }`:                      "count must not be negative",
+		`subscription { a: ticks(count: 1) b: ticks(count: 1) }`: "a subscription must select exactly one top-level field",
+		`{ dishes { name } }`:                                    "query operations are run with Execute",
+	} {
+		doc, err := Parse(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, failed := s.Subscribe(ctx, doc, "", nil); failed == nil || failed.Errors[0].Message != want {
+			t.Errorf("%s: failed = %+v", query, failed)
+		}
+	}
+	if resp := s.Execute(ctx, doc, "", nil); resp.Errors[0].Message != "subscriptions are started with Subscribe" {
+		t.Errorf("executed subscription = %+v", resp.Errors[0])
+	}
+}
+
+func TestOperationName(t *testing.T) {
+	doc, err := Parse(`query A { dishes { name } } mutation B { order(dish: "soup") }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind, err := doc.Operation("B"); kind != Mutation || err != nil {
+		t.Errorf("Operation(B) = %q, %v", kind, err)
+	}
+	if _, err := doc.Operation(""); err == nil {
+		t.Error("Operation picked one of several")
+	}
+	if _, err := doc.Operation("C"); err == nil {
+		t.Error("Operation found an unknown one")
+	}
+}
+
+func TestNewSchemaChecksTypes(t *testing.T) {
+	dish := &Object{Name: "Dish", Fields: []*Field{{Name: "name", Type: String}}}
+	for name, query := range map[string]*Object{
+		"no fields":        {Name: "Query"},
+		"repeated field":   {Name: "Query", Fields: []*Field{{Name: "a", Type: Int}, {Name: "a", Type: Int}}},
+		"object argument":  {Name: "Query", Fields: []*Field{{Name: "a", Type: Int, Args: []*Arg{{Name: "d", Type: dish}}}}},
+		"duplicate names":  {Name: "Query", Fields: []*Field{{Name: "a", Type: dish}, {Name: "b", Type: &Object{Name: "Dish", Fields: dish.Fields}}}},
+		"missing type":     {Name: "Query", Fields: []*Field{{Name: "a"}}},
+		"invalid enum":     {Name: "Query", Fields: []*Field{{Name: "a", Type: &Enum{Name: "E", Values: []string{"true"}}}}},
+		"invalid name":     {Name: "Query", Fields: []*Field{{Name: "a-b", Type: Int}}},
+		"reserved name":    {Name: "__Query", Fields: []*Field{{Name: "a", Type: Int}}},
+		"double non-null":  {Name: "Query", Fields: []*Field{{Name: "a", Type: NonNullOf(NonNullOf(Int))}}},
+		"scalar functions": {Name: "Query", Fields: []*Field{{Name: "a", Type: &Scalar{Name: "S"}}}},
+	} {
+		if _, err := NewSchema(query, nil, nil); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	sub := &Object{Name: "Subscription", Fields: []*Field{{Name: "a", Type: Int}}}
+	if _, err := NewSchema(&Object{Name: "Query", Fields: dish.Fields}, nil, sub); err == nil {
+		t.Error("subscription field without Subscribe accepted")
+	}
+}
+
+func TestSDL(t *testing.T) {
+	got := testSchema(t).SDL()
+	for _, want := range []string{
+		"type Query {\n  dishes(\n    kind: Kind\n    \"At most this many\"\n    limit: Int = 10\n  ): [Dish!]!\n  dish(name: String!): Dish\n}",
+		"type Mutation {\n  order(dish: String!): String!\n}",
+		"\"Something on the menu\"\ntype Dish {\n  name: String!\n",
+		"enum Kind {\n  starter\n  main\n}",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("SDL lacks\n%s\nin\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "scalar Int") {
+		t.Error("SDL declares a built-in scalar")
+	}
+	if strings.Index(got, "type Subscription") > strings.Index(got, "type Dish") {
+		t.Error("root types are not first")
+	}
+}
+
+func TestLexer(t *testing.T) {
+	for src, want := range map[string]string{
+		`"a\"b\u00e9\n"`:                       "a\"bé\n",
+		"\"\"\"\n    one\n      two\n  \"\"\"": "one\n  two",
+		`"""say \""" twice"""`:                 `say """ twice`,
+	} {
+		tok, err := newLexer(src).next()
+		if err != nil || tok.kind != tokString || tok.val != want {
+			t.Errorf("%s = %q, %v", src, tok.val, err)
+		}
+	}
+	for _, src := range []string{"007", "1.", "1e", "12abc", `"open`, "..", `"\q"`, "%"} {
+		if _, err := newLexer(src).next(); err == nil {
+			t.Errorf("%s lexed", src)
+		}
+	}
+	l := newLexer("# comment\n, -1.5e3 \t$x")
+	for _, want := range []token{{kind: tokFloat, val: "-1.5e3"}, {kind: tokPunct, val: "$"}, {kind: tokName, val: "x"}, {kind: tokEOF}} {
+		tok, err := l.next()
+		if err != nil || tok.kind != want.kind || tok.val != want.val {
+			t.Errorf("token = %+v, %v, want %+v", tok, err, want)
+		}
+	}
+}
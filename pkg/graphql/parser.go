@@ -0,0 +1,446 @@
+package graphql
+
+import "fmt"
+
+// Operation types
+const (
+	Query        = "query"
+	Mutation     = "mutation"
+	Subscription = "subscription"
+)
+
+// Document is a parsed query document: its operations and fragments
+type Document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind       string
+	name       string
+	vars       []*varDef
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+type varDef struct {
+	name     string
+	typ      *typeRef
+	defValue *value // nil without a default
+	loc      Location
+}
+
+// typeRef is a type as written in a variable definition
+type typeRef struct {
+	name    string   // Named type, empty for a list
+	elem    *typeRef // List element type
+	nonNull bool
+	loc     Location
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// selection is a field, fragment spread or inline fragment
+type selection interface {
+	location() Location
+}
+
+type field struct {
+	alias      string // Empty without one
+	name       string
+	args       []*argument
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+func (f *field) location() Location { return f.loc }
+
+// key is the name the field's value is returned under
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type spread struct {
+	name       string
+	directives []*directive
+	loc        Location
+}
+
+func (s *spread) location() Location { return s.loc }
+
+type inlineFragment struct {
+	on         string // Type condition, empty without one
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+func (f *inlineFragment) location() Location { return f.loc }
+
+type fragment struct {
+	name       string
+	on         string
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+type argument struct {
+	name string
+	val  *value
+	loc  Location
+}
+
+type directive struct {
+	name string
+	args []*argument
+	loc  Location
+}
+
+type valueKind int
+
+const (
+	valVariable valueKind = iota
+	valInt
+	valFloat
+	valString
+	valBoolean
+	valNull
+	valEnum
+	valList
+	valObject
+)
+
+// value is an input value as written: raw holds scalars, enum values and
+// variable names as written, except strings, which are decoded
+type value struct {
+	kind   valueKind
+	raw    string
+	list   []*value
+	fields []*argument
+	loc    Location
+}
+
+// parser is a recursive descent parser over the lexer's tokens. The first
+// error stops it: every method does nothing once err is set.
+type parser struct {
+	lex *lexer
+	tok token
+	err *Error
+}
+
+// Parse parses a query document of operations and fragments
+func Parse(query string) (*Document, error) {
+	p := &parser{lex: newLexer(query)}
+	p.advance()
+	doc := &Document{fragments: make(map[string]*fragment)}
+	for p.err == nil && p.tok.kind != tokEOF {
+		switch {
+		case p.peek("{"):
+			op := &operation{kind: Query, loc: p.tok.loc}
+			op.selections = p.selectionSet()
+			doc.operations = append(doc.operations, op)
+		case p.tok.kind == tokName && (p.tok.val == Query || p.tok.val == Mutation || p.tok.val == Subscription):
+			doc.operations = append(doc.operations, p.operation())
+		case p.tok.kind == tokName && p.tok.val == "fragment":
+			f := p.fragment()
+			if p.err != nil {
+				break
+			}
+			if _, ok := doc.fragments[f.name]; ok {
+				return nil, &Error{Message: fmt.Sprintf("there can be only one fragment named %q", f.name), Locations: []Location{f.loc}}
+			}
+			doc.fragments[f.name] = f
+		default:
+			p.unexpected("an operation or fragment")
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if len(doc.operations) == 0 {
+		return nil, &Error{Message: "the document has no operations"}
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lex.next()
+}
+
+// peek reports whether the current token is the punctuator punct
+func (p *parser) peek(punct string) bool {
+	return p.err == nil && p.tok.kind == tokPunct && p.tok.val == punct
+}
+
+// skip passes over the punctuator punct, reporting whether it was there
+func (p *parser) skip(punct string) bool {
+	if p.peek(punct) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(punct string) {
+	if !p.skip(punct) {
+		p.unexpected(`"` + punct + `"`)
+	}
+}
+
+func (p *parser) unexpected(want string) {
+	if p.err == nil {
+		p.err = &Error{Message: fmt.Sprintf("syntax error: unexpected %s, want %s", p.tok, want), Locations: []Location{p.tok.loc}}
+	}
+}
+
+func (p *parser) name() string {
+	if p.err != nil {
+		return ""
+	}
+	if p.tok.kind != tokName {
+		p.unexpected("a name")
+		return ""
+	}
+	name := p.tok.val
+	p.advance()
+	return name
+}
+
+// keyword passes over the name kw
+func (p *parser) keyword(kw string) {
+	if p.err == nil && (p.tok.kind != tokName || p.tok.val != kw) {
+		p.unexpected(`"` + kw + `"`)
+		return
+	}
+	p.advance()
+}
+
+func (p *parser) operation() *operation {
+	op := &operation{kind: p.tok.val, loc: p.tok.loc}
+	p.advance()
+	if p.err == nil && p.tok.kind == tokName {
+		op.name = p.name()
+	}
+	if p.skip("(") {
+		for p.err == nil && !p.skip(")") {
+			op.vars = append(op.vars, p.varDef())
+		}
+	}
+	op.directives = p.directives(true)
+	op.selections = p.selectionSet()
+	return op
+}
+
+func (p *parser) varDef() *varDef {
+	v := &varDef{loc: p.tok.loc}
+	p.expect("$")
+	v.name = p.name()
+	p.expect(":")
+	v.typ = p.typeRef()
+	if p.skip("=") {
+		v.defValue = p.value(true)
+	}
+	p.directives(true)
+	return v
+}
+
+func (p *parser) typeRef() *typeRef {
+	t := &typeRef{loc: p.tok.loc}
+	if p.skip("[") {
+		t.elem = p.typeRef()
+		p.expect("]")
+	} else {
+		t.name = p.name()
+	}
+	t.nonNull = p.skip("!")
+	return t
+}
+
+func (p *parser) fragment() *fragment {
+	f := &fragment{loc: p.tok.loc}
+	p.keyword("fragment")
+	if p.err == nil && p.tok.kind == tokName && p.tok.val == "on" {
+		p.unexpected("a fragment name")
+	}
+	f.name = p.name()
+	p.keyword("on")
+	f.on = p.name()
+	f.directives = p.directives(false)
+	f.selections = p.selectionSet()
+	return f
+}
+
+func (p *parser) selectionSet() []selection {
+	p.expect("{")
+	var sels []selection
+	for p.err == nil && !p.skip("}") {
+		sels = append(sels, p.selection())
+	}
+	if p.err == nil && len(sels) == 0 {
+		p.unexpected("a selection")
+	}
+	return sels
+}
+
+func (p *parser) selection() selection {
+	loc := p.tok.loc
+	if !p.skip("...") {
+		return p.field()
+	}
+	if p.err == nil && p.tok.kind == tokName && p.tok.val != "on" {
+		s := &spread{loc: loc}
+		s.name = p.name()
+		s.directives = p.directives(false)
+		return s
+	}
+	f := &inlineFragment{loc: loc}
+	if p.err == nil && p.tok.kind == tokName {
+		p.keyword("on")
+		f.on = p.name()
+	}
+	f.directives = p.directives(false)
+	f.selections = p.selectionSet()
+	return f
+}
+
+func (p *parser) field() *field {
+	f := &field{loc: p.tok.loc}
+	f.name = p.name()
+	if p.skip(":") {
+		f.alias, f.name = f.name, p.name()
+	}
+	f.args = p.arguments(false)
+	f.directives = p.directives(false)
+	if p.peek("{") {
+		f.selections = p.selectionSet()
+	}
+	return f
+}
+
+func (p *parser) arguments(constant bool) []*argument {
+	if !p.skip("(") {
+		return nil
+	}
+	var args []*argument
+	for p.err == nil && !p.skip(")") {
+		a := &argument{loc: p.tok.loc}
+		a.name = p.name()
+		p.expect(":")
+		a.val = p.value(constant)
+		args = append(args, a)
+	}
+	return args
+}
+
+func (p *parser) directives(constant bool) []*directive {
+	var ds []*directive
+	for p.err == nil && p.peek("@") {
+		d := &directive{loc: p.tok.loc}
+		p.advance()
+		d.name = p.name()
+		d.args = p.arguments(constant)
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+// value parses an input value; constant ones, such as variable defaults,
+// may not use variables
+func (p *parser) value(constant bool) *value {
+	v := &value{loc: p.tok.loc, raw: p.tok.val}
+	if p.err != nil {
+		return v
+	}
+	switch p.tok.kind {
+	case tokInt:
+		v.kind = valInt
+	case tokFloat:
+		v.kind = valFloat
+	case tokString:
+		v.kind = valString
+	case tokName:
+		switch p.tok.val {
+		case "true", "false":
+			v.kind = valBoolean
+		case "null":
+			v.kind = valNull
+		default:
+			v.kind = valEnum
+		}
+	case tokPunct:
+		switch {
+		case p.tok.val == "$" && !constant:
+			p.advance()
+			v.kind, v.raw = valVariable, p.name()
+			return v
+		case p.tok.val == "[":
+			p.advance()
+			v.kind = valList
+			for p.err == nil && !p.skip("]") {
+				v.list = append(v.list, p.value(constant))
+			}
+			return v
+		case p.tok.val == "{":
+			p.advance()
+			v.kind = valObject
+			for p.err == nil && !p.skip("}") {
+				f := &argument{loc: p.tok.loc}
+				f.name = p.name()
+				p.expect(":")
+				f.val = p.value(constant)
+				v.fields = append(v.fields, f)
+			}
+			return v
+		}
+		p.unexpected("a value")
+		return v
+	default:
+		p.unexpected("a value")
+		return v
+	}
+	p.advance()
+	return v
+}
+
+// Operation returns the type of the operation name, or of the only
+// operation when name is empty: Query, Mutation or Subscription
+func (d *Document) Operation(name string) (string, error) {
+	op, err := d.operation(name)
+	if err != nil {
+		return "", err
+	}
+	return op.kind, nil
+}
+
+func (d *Document) operation(name string) (*operation, *Error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, &Error{Message: "the document has several operations, name the one to run"}
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("unknown operation %q", name)}
+}
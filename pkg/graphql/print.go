@@ -0,0 +1,142 @@
+package graphql
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SDL returns the schema in the schema definition language, for clients to
+// check their queries against and generate code from: the root types
+// first, then the others by name
+func (s *Schema) SDL() string {
+	var b strings.Builder
+	roots := []*Object{s.query, s.mutation, s.subscription}
+	if s.query.Name != "Query" || s.mutation != nil && s.mutation.Name != "Mutation" ||
+		s.subscription != nil && s.subscription.Name != "Subscription" {
+		b.WriteString("schema {\n")
+		for i, op := range []string{Query, Mutation, Subscription} {
+			if roots[i] != nil {
+				fmt.Fprintf(&b, "  %s: %s\n", op, roots[i].Name)
+			}
+		}
+		b.WriteString("}\n\n")
+	}
+	written := make(map[string]bool)
+	for _, name := range []string{"Int", "Float", "String", "Boolean", "ID"} {
+		written[name] = true
+	}
+	for _, root := range roots {
+		if root != nil {
+			writeType(&b, root)
+			written[root.Name] = true
+		}
+	}
+	for _, name := range s.sortedNames() {
+		if !written[name] {
+			writeType(&b, s.types[name])
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func writeType(b *strings.Builder, t Type) {
+	switch t := t.(type) {
+	case *Scalar:
+		writeDescription(b, t.Description, "")
+		fmt.Fprintf(b, "scalar %s\n", t.Name)
+	case *Enum:
+		writeDescription(b, t.Description, "")
+		fmt.Fprintf(b, "enum %s {\n", t.Name)
+		for _, v := range t.Values {
+			fmt.Fprintf(b, "  %s\n", v)
+		}
+		b.WriteString("}\n")
+	case *Object:
+		writeDescription(b, t.Description, "")
+		fmt.Fprintf(b, "type %s {\n", t.Name)
+		for _, f := range t.Fields {
+			writeDescription(b, f.Description, "  ")
+			b.WriteString("  " + f.Name)
+			writeArgs(b, f.Args)
+			fmt.Fprintf(b, ": %s\n", f.Type)
+		}
+		b.WriteString("}\n")
+	}
+	b.WriteString("\n")
+}
+
+// writeArgs writes a field's arguments on its line, or one to a line when
+// any of them is described
+func writeArgs(b *strings.Builder, args []*Arg) {
+	if len(args) == 0 {
+		return
+	}
+	described := false
+	for _, a := range args {
+		described = described || a.Description != ""
+	}
+	b.WriteString("(")
+	for i, a := range args {
+		switch {
+		case described:
+			b.WriteString("\n")
+			writeDescription(b, a.Description, "    ")
+			b.WriteString("    ")
+		case i > 0:
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(b, "%s: %s", a.Name, a.Type)
+		if a.Default != nil {
+			b.WriteString(" = " + printValue(a.Type, a.Default))
+		}
+	}
+	if described {
+		b.WriteString("\n  ")
+	}
+	b.WriteString(")")
+}
+
+func writeDescription(b *strings.Builder, desc, indent string) {
+	if desc == "" {
+		return
+	}
+	if !strings.ContainsAny(desc, "\n\"\\") {
+		fmt.Fprintf(b, "%s\"%s\"\n", indent, desc)
+		return
+	}
+	fmt.Fprintf(b, "%s\"\"\"\n", indent)
+	for _, line := range strings.Split(desc, "\n") {
+		fmt.Fprintf(b, "%s%s\n", indent, strings.ReplaceAll(line, `"""`, `\"""`))
+	}
+	fmt.Fprintf(b, "%s\"\"\"\n", indent)
+}
+
+// printValue writes v, a default of type t, as a literal
+func printValue(t Type, v any) string {
+	if nn, ok := t.(*NonNull); ok {
+		t = nn.Of
+	}
+	if l, ok := t.(*List); ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return printValue(l.Of, v)
+		}
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = printValue(l.Of, rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	switch v := v.(type) {
+	case string:
+		if _, ok := t.(*Enum); ok {
+			return v
+		}
+		return strconv.Quote(v)
+	case nil:
+		return "null"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,330 @@
+package graphql
+
+import "fmt"
+
+// validator checks a document against the schema before any of it is run
+type validator struct {
+	schema    *Schema
+	doc       *Document
+	errs      []*Error
+	seen      map[string]bool // Messages already reported, as fragments are checked at each spread
+	defined   map[string]*varDef
+	used      map[string]bool // Variables the operation being checked uses
+	spreading map[string]bool // Fragments being checked, against cycles
+	spread    map[string]bool // Fragments used by any operation
+}
+
+func (s *Schema) validate(doc *Document) []*Error {
+	v := &validator{schema: s, doc: doc, seen: make(map[string]bool), spread: make(map[string]bool)}
+	names := make(map[string]bool)
+	for _, op := range doc.operations {
+		switch {
+		case op.name == "" && len(doc.operations) > 1:
+			v.errorf(op.loc, "an anonymous operation must be the only one in the document")
+		case op.name != "" && names[op.name]:
+			v.errorf(op.loc, "there can be only one operation named %q", op.name)
+		}
+		names[op.name] = true
+		v.operation(op)
+	}
+	for name, f := range doc.fragments {
+		if !v.spread[name] {
+			v.errorf(f.loc, "fragment %q is never used", name)
+		}
+	}
+	return v.errs
+}
+
+func (v *validator) errorf(loc Location, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if v.seen[msg] {
+		return
+	}
+	v.seen[msg] = true
+	v.errs = append(v.errs, &Error{Message: msg, Locations: []Location{loc}})
+}
+
+func (v *validator) operation(op *operation) {
+	var root *Object
+	switch op.kind {
+	case Query:
+		root = v.schema.query
+	case Mutation:
+		root = v.schema.mutation
+	case Subscription:
+		root = v.schema.subscription
+	}
+	if root == nil {
+		v.errorf(op.loc, "the schema has no %s operations", op.kind)
+		return
+	}
+	v.defined = make(map[string]*varDef)
+	v.used = make(map[string]bool)
+	v.spreading = make(map[string]bool)
+	for _, def := range op.vars {
+		if v.defined[def.name] != nil {
+			v.errorf(def.loc, "there can be only one variable named $%s", def.name)
+			continue
+		}
+		v.defined[def.name] = def
+		t := v.schema.typeOf(def.typ)
+		if t == nil || !isInput(t) {
+			v.errorf(def.typ.loc, "variable $%s has unknown or non-input type %s", def.name, def.typ)
+			continue
+		}
+		if def.defValue != nil {
+			x, _ := literal(def.defValue, map[string]any{})
+			if _, err := coerce(t, x); err != nil {
+				v.errorf(def.defValue.loc, "default value of $%s: %v", def.name, err)
+			}
+		}
+	}
+	for _, d := range op.directives {
+		v.errorf(d.loc, "directive @%s may not be used on operations", d.name)
+	}
+	v.selections(root, op.selections)
+	if op.kind == Subscription && v.rootFields(op.selections) != 1 {
+		v.errorf(op.loc, "a subscription must select exactly one top-level field")
+	}
+	for _, def := range op.vars {
+		if !v.used[def.name] {
+			v.errorf(def.loc, "variable $%s is never used", def.name)
+		}
+	}
+}
+
+// rootFields counts the response keys sels select, whatever the directives
+func (v *validator) rootFields(sels []selection) int {
+	keys := make(map[string]bool)
+	spreadOnce := make(map[string]bool)
+	var walk func([]selection)
+	walk = func(sels []selection) {
+		for _, sel := range sels {
+			switch sel := sel.(type) {
+			case *field:
+				keys[sel.key()] = true
+			case *inlineFragment:
+				walk(sel.selections)
+			case *spread:
+				if f := v.doc.fragments[sel.name]; f != nil && !spreadOnce[sel.name] {
+					spreadOnce[sel.name] = true
+					walk(f.selections)
+				}
+			}
+		}
+	}
+	walk(sels)
+	return len(keys)
+}
+
+// selections checks the selections of sels on parent
+func (v *validator) selections(parent *Object, sels []selection) {
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case *field:
+			v.directives(sel.directives)
+			v.field(parent, sel)
+		case *inlineFragment:
+			v.directives(sel.directives)
+			if sel.on != "" && !v.condition(sel.on, parent, sel.loc) {
+				continue
+			}
+			v.selections(parent, sel.selections)
+		case *spread:
+			v.directives(sel.directives)
+			f := v.doc.fragments[sel.name]
+			if f == nil {
+				v.errorf(sel.loc, "unknown fragment %q", sel.name)
+				continue
+			}
+			v.spread[sel.name] = true
+			if v.spreading[sel.name] {
+				v.errorf(sel.loc, "fragment %q spreads itself", sel.name)
+				continue
+			}
+			if !v.condition(f.on, parent, sel.loc) {
+				continue
+			}
+			for _, d := range f.directives {
+				v.errorf(d.loc, "directive @%s may not be used on fragment definitions", d.name)
+			}
+			v.spreading[sel.name] = true
+			v.selections(parent, f.selections)
+			delete(v.spreading, sel.name)
+		}
+	}
+}
+
+// condition checks that a fragment on the type named on may be spread in
+// parent, which is only parent itself as there are no abstract types
+func (v *validator) condition(on string, parent *Object, loc Location) bool {
+	t, ok := v.schema.types[on]
+	if !ok {
+		v.errorf(loc, "unknown type %s", on)
+		return false
+	}
+	if _, ok := t.(*Object); !ok {
+		v.errorf(loc, "fragments must be on object types, not %s", on)
+		return false
+	}
+	if t != parent {
+		v.errorf(loc, "a fragment on %s cannot be spread in %s", on, parent.Name)
+		return false
+	}
+	return true
+}
+
+func (v *validator) field(parent *Object, f *field) {
+	if f.name == "__typename" {
+		if len(f.args) > 0 || len(f.selections) > 0 {
+			v.errorf(f.loc, "__typename takes no arguments or subfields")
+		}
+		return
+	}
+	fd := parent.field(f.name)
+	if fd == nil {
+		v.errorf(f.loc, "unknown field %q on type %s", f.name, parent.Name)
+		return
+	}
+	given := make(map[string]bool)
+	for _, a := range f.args {
+		var ad *Arg
+		for _, x := range fd.Args {
+			if x.Name == a.name {
+				ad = x
+			}
+		}
+		switch {
+		case ad == nil:
+			v.errorf(a.loc, "unknown argument %q of field %s.%s", a.name, parent.Name, fd.Name)
+		case given[a.name]:
+			v.errorf(a.loc, "there can be only one argument named %q", a.name)
+		default:
+			v.value(ad.Type, a.val, ad.Default != nil, "argument "+quote(a.name))
+		}
+		given[a.name] = true
+	}
+	for _, ad := range fd.Args {
+		if _, ok := ad.Type.(*NonNull); ok && ad.Default == nil && !given[ad.Name] {
+			v.errorf(f.loc, "argument %q of type %s is required", ad.Name, ad.Type)
+		}
+	}
+	obj, isObj := unwrap(fd.Type).(*Object)
+	switch {
+	case isObj && len(f.selections) == 0:
+		v.errorf(f.loc, "field %q of type %s must select subfields", f.name, fd.Type)
+	case !isObj && len(f.selections) > 0:
+		v.errorf(f.loc, "field %q of type %s has no subfields", f.name, fd.Type)
+	case isObj:
+		v.selections(obj, f.selections)
+	}
+}
+
+// directives checks that only @skip and @include are used, given an if
+// argument
+func (v *validator) directives(ds []*directive) {
+	for _, d := range ds {
+		if d.name != "skip" && d.name != "include" {
+			v.errorf(d.loc, "unknown directive @%s", d.name)
+			continue
+		}
+		if len(d.args) != 1 || d.args[0].name != "if" {
+			v.errorf(d.loc, "directive @%s takes one argument, if", d.name)
+			continue
+		}
+		v.value(NonNullOf(Boolean), d.args[0].val, false, "argument \"if\"")
+	}
+}
+
+// value checks an input value for a position of type t, which has a
+// default if hasDefault is set: that its variables are defined and of a
+// type that fits there, and that the rest of it is valid
+func (v *validator) value(t Type, val *value, hasDefault bool, what string) {
+	v.variables(t, val, hasDefault)
+	x, _ := literal(val, nil)
+	if _, err := coerce(t, x); err != nil {
+		v.errorf(val.loc, "%s: %v", what, err)
+	}
+}
+
+func (v *validator) variables(t Type, val *value, hasDefault bool) {
+	switch val.kind {
+	case valVariable:
+		v.used[val.raw] = true
+		def := v.defined[val.raw]
+		if def == nil {
+			v.errorf(val.loc, "variable $%s is not defined", val.raw)
+			return
+		}
+		vt := v.schema.typeOf(def.typ)
+		if vt == nil || t == nil {
+			return // Reported with the definition, or where t is unknown
+		}
+		if nn, ok := t.(*NonNull); ok && (hasDefault || def.defValue != nil) && fits(vt, nn.Of) {
+			return
+		}
+		if !fits(vt, t) {
+			v.errorf(val.loc, "variable $%s of type %s cannot be used where %s is expected", val.raw, vt, t)
+		}
+	case valList:
+		item := t
+		if nn, ok := item.(*NonNull); ok {
+			item = nn.Of
+		}
+		if l, ok := item.(*List); ok {
+			item = l.Of
+		}
+		for _, x := range val.list {
+			v.variables(item, x, false)
+		}
+	case valObject:
+		for _, f := range val.fields {
+			v.variables(nil, f.val, false)
+		}
+	}
+}
+
+// fits reports whether a variable of type vt may be used where pos is
+// expected
+func fits(vt, pos Type) bool {
+	if pn, ok := pos.(*NonNull); ok {
+		vn, ok := vt.(*NonNull)
+		return ok && fits(vn.Of, pn.Of)
+	}
+	if vn, ok := vt.(*NonNull); ok {
+		return fits(vn.Of, pos)
+	}
+	if pl, ok := pos.(*List); ok {
+		vl, ok := vt.(*List)
+		return ok && fits(vl.Of, pl.Of)
+	}
+	if _, ok := vt.(*List); ok {
+		return false
+	}
+	return vt == pos
+}
+
+// typeOf resolves a type written in a query, or returns nil for an unknown
+// one
+func (s *Schema) typeOf(ref *typeRef) Type {
+	var t Type
+	if ref.elem != nil {
+		elem := s.typeOf(ref.elem)
+		if elem == nil {
+			return nil
+		}
+		t = ListOf(elem)
+	} else if named, ok := s.types[ref.name]; ok {
+		t = named
+	} else {
+		return nil
+	}
+	if ref.nonNull {
+		t = NonNullOf(t)
+	}
+	return t
+}
+
+func quote(s string) string {
+	return `"` + s + `"`
+}
@@ -0,0 +1,188 @@
+package httpapi
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/backup"
+	"awesomeProject/pkg/fault"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/outbound"
+)
+
+// maxSnapshotBody bounds the snapshots accepted for restore
+const maxSnapshotBody = 64 << 20
+
+// AdminConfig configures the admin endpoints. They are only served when
+// Token is set, and each request must carry it as a bearer token.
+type AdminConfig struct {
+	Token string `json:"token"`
+
+	// Managers gives each manager's bearer token, by name, for the actions
+	// only managers may take, such as voids. The admin token is accepted
+	// for them too.
+	Managers map[string]string `json:"managers"`
+}
+
+// registerAdminRoutes mounts the state snapshot endpoints
+func (s *Server) registerAdminRoutes() {
+	if s.cfg.Admin.Token == "" {
+		return
+	}
+	s.handle("GET /v1/admin/snapshot", s.admin(s.snapshotV1))
+	s.handle("GET /v1/admin/streams", s.admin(s.streamsV1))
+	s.handle("PUT /v1/admin/snapshot", s.admin(s.restoreSnapshotV1))
+	if s.backups != nil {
+		s.handle("GET /v1/admin/backups", s.admin(s.listBackupsV1))
+		s.handle("POST /v1/admin/backups", s.admin(s.takeBackupV1))
+	}
+	if s.outbound != nil {
+		s.handle("GET /v1/admin/outbound", s.admin(s.outboundV1))
+	}
+	if s.recorder != nil {
+		s.handle("GET /v1/admin/recorder", s.admin(s.dumpRecorderV1))
+		s.handle("DELETE /v1/admin/recorder", s.admin(s.clearRecorderV1))
+	}
+	if fault.Enabled {
+		s.handle("GET /v1/admin/faults", s.admin(s.faultsV1))
+		s.handle("PUT /v1/admin/faults", s.admin(s.setFaultsV1))
+		s.handle("DELETE /v1/admin/faults", s.admin(s.clearFaultsV1))
+	}
+}
+
+// WithOutbound serves the destination counts and breaker states of c under
+// the admin endpoints
+func WithOutbound(c *outbound.Client) Option {
+	return func(s *Server) { s.outbound = c }
+}
+
+// WithBackups serves the backups b takes under the admin endpoints
+func WithBackups(b *backup.Backups) Option {
+	return func(s *Server) { s.backups = b }
+}
+
+// admin rejects requests without the admin token
+func (s *Server) admin(h http.HandlerFunc) http.HandlerFunc {
+	want := []byte("Bearer " + s.cfg.Admin.Token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeError(w, r, http.StatusUnauthorized, "admin token required")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// managerOnly rejects requests without a manager's token or the admin token,
+// passing h the manager's name. For the admin token it is the StaffHeader,
+// or "admin" without one.
+func (s *Server) managerOnly(h func(w http.ResponseWriter, r *http.Request, by string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		by := ""
+		for name, token := range s.cfg.Admin.Managers {
+			if token != "" && subtle.ConstantTimeCompare(got, []byte("Bearer "+token)) == 1 {
+				by = name
+				// The audit log names whoever the token belongs to
+				r.Header.Set(StaffHeader, name)
+			}
+		}
+		if by == "" && s.cfg.Admin.Token != "" && subtle.ConstantTimeCompare(got, []byte("Bearer "+s.cfg.Admin.Token)) == 1 {
+			by = cmp.Or(truncate(strings.TrimSpace(r.Header.Get(StaffHeader)), maxActor), "admin")
+		}
+		if by == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="manager"`)
+			writeError(w, r, http.StatusUnauthorized, "manager token required")
+			return
+		}
+		h(w, r, by)
+	}
+}
+
+// snapshotV1 downloads the manager's full state
+func (s *Server) snapshotV1(w http.ResponseWriter, r *http.Request) {
+	span := opSpan(r, "Snapshot")
+	snap, err := s.om.Snapshot(r.Context())
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="snapshot-%s.json"`, snap.TakenAt.Format("20060102-150405")))
+	writeJSON(w, http.StatusOK, snap)
+}
+
+// restoreSnapshotV1 replaces the manager's state with an uploaded snapshot
+func (s *Server) restoreSnapshotV1(w http.ResponseWriter, r *http.Request) {
+	var snap manager.Snapshot
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSnapshotBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&snap); err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, "snapshot too large")
+			return
+		}
+		writeErrorf(w, r, http.StatusBadRequest, "invalid snapshot: %s", strings.TrimPrefix(err.Error(), "json: "))
+		return
+	}
+	span := opSpan(r, "RestoreSnapshot")
+	err := s.om.RestoreSnapshot(&snap)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionRestore, "", "", map[string]string{"takenAt": snap.TakenAt.Format(time.RFC3339)})
+	writeJSON(w, http.StatusOK, restoredBody{
+		TakenAt: snap.TakenAt,
+		Orders:  len(snap.Waiting) + len(snap.Scheduled) + len(snap.Waitlist) + len(snap.Unpaid) + len(snap.InProgress) + len(snap.Prepared) + len(snap.Closed),
+	})
+}
+
+// listBackupsV1 lists the backups on disk, newest first
+func (s *Server) listBackupsV1(w http.ResponseWriter, r *http.Request) {
+	backups, err := s.backups.List()
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	if backups == nil {
+		backups = []backup.Backup{}
+	}
+	writeJSON(w, http.StatusOK, backups)
+}
+
+// takeBackupV1 backs up now, outside the schedule
+func (s *Server) takeBackupV1(w http.ResponseWriter, r *http.Request) {
+	if _, ok := input(w, r); !ok {
+		return
+	}
+	bk, err := s.backups.Take(r.Context())
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionBackup, "", "", map[string]string{"name": bk.Name})
+	writeJSON(w, http.StatusCreated, bk)
+}
+
+// restoredBody reports a restored snapshot
+type restoredBody struct {
+	TakenAt time.Time `json:"takenAt"`
+	Orders  int       `json:"orders"`
+}
+
+// outboundV1 reports the calls made to each outside service and the state of
+// its circuit breaker
+func (s *Server) outboundV1(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.outbound.Stats())
+}
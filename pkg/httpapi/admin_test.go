@@ -0,0 +1,104 @@
+package httpapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/backup"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestSnapshotRoutes(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Token = "t0ken"
+	src := New(manager.New(manager.DefaultConfig()), cfg)
+	do(t, src, http.MethodPost, "/v1/orders?item=soup&priority=2")
+	do(t, src, http.MethodPost, "/v1/orders?item=tea&priority=1")
+
+	admin := func(s *Server, method, token string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/admin/snapshot", body)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := admin(src, http.MethodGet, "", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("no token = %d", rec.Code)
+	}
+	if rec := admin(src, http.MethodGet, "wrong", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token = %d", rec.Code)
+	}
+	rec := admin(src, http.MethodGet, "t0ken", nil)
+	var snap manager.Snapshot
+	decode(t, rec, &snap)
+	if rec.Code != http.StatusOK || len(snap.Waiting) != 2 || snap.Waiting[0].Item != "tea" {
+		t.Fatalf("snapshot = %d %s", rec.Code, rec.Body)
+	}
+
+	dst := New(manager.New(manager.DefaultConfig()), cfg)
+	if rec := admin(dst, http.MethodPut, "t0ken", strings.NewReader(`{"version":1,"waiting":[{"id":1}]`)); rec.Code != http.StatusBadRequest {
+		t.Fatalf("truncated snapshot = %d %s", rec.Code, rec.Body)
+	}
+	if rec := admin(dst, http.MethodPut, "t0ken", strings.NewReader(`{"version":1,"waiting":[{"id":1,"status":"lost"}]}`)); rec.Code != http.StatusBadRequest {
+		t.Fatalf("inconsistent snapshot = %d %s", rec.Code, rec.Body)
+	}
+	if rec := admin(dst, http.MethodPut, "t0ken", strings.NewReader(rec.Body.String())); rec.Code != http.StatusOK {
+		t.Fatalf("restore = %d %s", rec.Code, rec.Body)
+	}
+	rec = do(t, dst, http.MethodPost, "/v1/orders/next")
+	var next queue.Token
+	decode(t, rec, &next)
+	if next.Item != "tea" {
+		t.Fatalf("next after restore = %+v", next)
+	}
+
+	// Without a token the routes are not served at all
+	if rec := do(t, newTestServer(t), http.MethodGet, "/v1/admin/snapshot"); rec.Code != http.StatusNotFound {
+		t.Fatalf("unconfigured = %d", rec.Code)
+	}
+}
+
+func TestBackupRoutes(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Admin.Token = "t0ken"
+	om := manager.New(manager.DefaultConfig())
+	backups, err := backup.New(backup.Config{Dir: t.TempDir()}, om, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(om, cfg, WithBackups(backups))
+	admin := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/admin/backups", nil)
+		req.Header.Set("Authorization", "Bearer t0ken")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := admin(http.MethodGet)
+	var list []backup.Backup
+	decode(t, rec, &list)
+	if rec.Code != http.StatusOK || len(list) != 0 {
+		t.Fatalf("list = %d %s", rec.Code, rec.Body)
+	}
+	rec = admin(http.MethodPost)
+	var bk backup.Backup
+	decode(t, rec, &bk)
+	if rec.Code != http.StatusCreated || bk.Name == "" || bk.EventLog {
+		t.Fatalf("take = %d %s", rec.Code, rec.Body)
+	}
+	decode(t, admin(http.MethodGet), &list)
+	if len(list) != 1 || list[0].Name != bk.Name {
+		t.Fatalf("list = %+v", list)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/admin/backups"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("no token = %d", rec.Code)
+	}
+}
@@ -0,0 +1,27 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"awesomeProject/pkg/alerts"
+)
+
+// WithAlerts serves the alerts a checks and shows them on the kitchen display
+func WithAlerts(a *alerts.Alerts) Option {
+	return func(s *Server) { s.alerts = a }
+}
+
+// registerAlertRoutes mounts the alerts firing, when alerts are checked
+func (s *Server) registerAlertRoutes() {
+	if s.alerts == nil {
+		return
+	}
+	s.handle("GET /v1/alerts", s.alertsV1)
+}
+
+// alertsV1 lists the alert rules broken now
+func (s *Server) alertsV1(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		Alerts []alerts.Alert `json:"alerts"`
+	}{s.alerts.Active()})
+}
@@ -0,0 +1,37 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/alerts"
+	"awesomeProject/pkg/manager"
+)
+
+func TestAlertsOnBoard(t *testing.T) {
+	om := manager.New(manager.DefaultConfig())
+	acfg := alerts.DefaultConfig()
+	acfg.Rules = []alerts.Rule{{Metric: alerts.MetricQueueDepth, Above: 0}}
+	a := alerts.New(acfg, om, nil, nil)
+	defer a.Close()
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(om, cfg, WithAlerts(a))
+
+	do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1")
+	if err := a.Check(context.Background(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	var list struct{ Alerts []alerts.Alert }
+	decode(t, do(t, s, http.MethodGet, "/v1/alerts"), &list)
+	if len(list.Alerts) != 1 || list.Alerts[0].Value != 1 {
+		t.Fatalf("alerts = %+v", list.Alerts)
+	}
+	var board struct{ Alerts []alerts.Alert }
+	decode(t, do(t, s, http.MethodGet, "/v1/kds"), &board)
+	if len(board.Alerts) != 1 || board.Alerts[0].Metric != alerts.MetricQueueDepth {
+		t.Fatalf("board alerts = %+v", board.Alerts)
+	}
+}
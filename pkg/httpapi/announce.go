@@ -0,0 +1,116 @@
+package httpapi
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"awesomeProject/pkg/i18n"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// defaultAnnouncement is what is said for a prepared order in languages
+// without a template of their own, translated through the catalogs
+const defaultAnnouncement = "Token {{.Number}}, your order is ready"
+
+// AnnouncementConfig sets what the lobby announcements say
+type AnnouncementConfig struct {
+	// Templates maps a language, en or one with a catalog, to the
+	// text/template spoken when an order is prepared, executed with the
+	// order's fields: .Number, .Item, .Quantity, .Table, .Station and so on
+	Templates map[string]string `json:"templates"`
+}
+
+// Validate checks that every template parses
+func (c AnnouncementConfig) Validate() error {
+	for lang, text := range c.Templates {
+		if _, err := template.New(lang).Parse(text); err != nil {
+			return fmt.Errorf("announcement template for %s: %w", lang, err)
+		}
+	}
+	return nil
+}
+
+// announcement is one line for the lobby audio to speak
+type announcement struct {
+	Text    string    `json:"text"`
+	Lang    string    `json:"lang"`
+	OrderID string    `json:"orderId,omitempty"` // Not for public callers under Roles.Redact
+	Number  int       `json:"number"`
+	At      time.Time `json:"at"`
+}
+
+// announcer renders announcements in each language
+type announcer struct {
+	templates map[string]*template.Template
+}
+
+// newAnnouncer parses the configured templates and the default in every
+// catalog language. A configured template that does not parse is logged
+// and the default used instead.
+func newAnnouncer(cfg AnnouncementConfig) *announcer {
+	a := &announcer{templates: make(map[string]*template.Template)}
+	for _, lang := range append(i18n.Languages(), "en") {
+		a.templates[lang] = template.Must(template.New(lang).Parse(i18n.T(lang, defaultAnnouncement)))
+	}
+	for lang, text := range cfg.Templates {
+		t, err := template.New(lang).Parse(text)
+		if err != nil {
+			log.Printf("announcement template for %s: %v", lang, err)
+			continue
+		}
+		a.templates[lang] = t
+	}
+	return a
+}
+
+// announce renders what is said for token in lang, falling back to English
+func (a *announcer) announce(token *queue.Token, lang string, at time.Time) (announcement, error) {
+	t, ok := a.templates[lang]
+	if !ok {
+		lang, t = "en", a.templates["en"]
+	}
+	var text strings.Builder
+	if err := t.Execute(&text, token); err != nil {
+		return announcement{}, err
+	}
+	return announcement{Text: text.String(), Lang: lang, OrderID: token.ID, Number: token.Number, At: at}, nil
+}
+
+// registerAnnouncementRoutes mounts the announcement feed
+func (s *Server) registerAnnouncementRoutes() {
+	s.announce = newAnnouncer(s.cfg.Announcements)
+	s.handleStream("GET /v1/announcements", s.announcementStream)
+}
+
+// announcementStream sends a ready-to-speak announcement, as a server-sent
+// event named announcement, for each order prepared, in the request's
+// language. With station set only that station's orders are announced.
+// Public callers under Roles.Redact get text rendered without the
+// customer's personal data, and no order ID.
+func (s *Server) announcementStream(w http.ResponseWriter, r *http.Request) {
+	lang := language(w, r)
+	public := s.publicView(w, r)
+	s.serveStream(w, r, func(e manager.Event) (string, any) {
+		if e.Type != manager.EventPrepared {
+			return "", nil
+		}
+		token := e.Token
+		if public {
+			token = withoutPersonal(token)
+		}
+		a, err := s.announce.announce(token, lang, e.At)
+		if err != nil {
+			log.Printf("announce order %s: %v", e.Token.ID, err)
+			return "", nil
+		}
+		if public {
+			a.OrderID = ""
+		}
+		return "announcement", a
+	})
+}
@@ -0,0 +1,63 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/manager"
+)
+
+func TestAnnouncementStream(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Announcements.Templates = map[string]string{"en": "Number {{.Number}}, {{.Item}} at the counter"}
+	s := New(manager.New(manager.DefaultConfig()), cfg)
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close) // After the streams are closed
+
+	listen := func(query string) *bufio.Scanner {
+		res, err := http.Get(srv.URL + "/v1/announcements" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { res.Body.Close() })
+		lines := bufio.NewScanner(res.Body)
+		lines.Scan() // Connected
+		return lines
+	}
+	en, es := listen(""), listen("?lang=es")
+
+	for _, target := range []string{"/v1/orders?item=tea&priority=1", "/v1/orders/next"} {
+		if res, err := http.Post(srv.URL+target, "", nil); err != nil || res.StatusCode/100 != 2 {
+			t.Fatalf("%s: %v %v", target, res.Status, err)
+		}
+	}
+	next := func(lines *bufio.Scanner) announcement {
+		var event string
+		for lines.Scan() {
+			line := lines.Text()
+			if v, ok := strings.CutPrefix(line, "event: "); ok {
+				event = v
+			}
+			if v, ok := strings.CutPrefix(line, "data: "); ok {
+				var a announcement
+				if event != "announcement" || json.Unmarshal([]byte(v), &a) != nil {
+					t.Fatalf("event %s: %s", event, v)
+				}
+				return a
+			}
+		}
+		t.Fatal("stream ended")
+		return announcement{}
+	}
+	if a := next(en); a.Text != "Number 1, tea at the counter" || a.Lang != "en" || a.Number != 1 {
+		t.Errorf("en announcement = %+v", a)
+	}
+	if a := next(es); a.Text != "Turno 1, su pedido está listo" || a.Lang != "es" {
+		t.Errorf("es announcement = %+v", a)
+	}
+}
@@ -0,0 +1,258 @@
+package httpapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"awesomeProject/pkg/attach"
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/queue"
+)
+
+// maxAttachmentName bounds the file names kept for attachments, in runes
+const maxAttachmentName = 100
+
+// multipartOverhead is room in an upload's body for the multipart framing
+// around the file
+const multipartOverhead = 64 << 10
+
+// WithAttachments lets files be attached to orders, kept in st
+func WithAttachments(st *attach.Store) Option {
+	return func(s *Server) { s.attachments = st }
+}
+
+// registerAttachmentRoutes mounts the attachment endpoints, when a store is
+// configured
+func (s *Server) registerAttachmentRoutes() {
+	if s.attachments == nil {
+		return
+	}
+	s.handle("POST /v1/orders/{id}/attachments", s.kitchen(s.attachV1))
+	s.handle("GET /v1/orders/{id}/attachments", s.kitchen(s.attachmentsV1))
+	s.handle("GET /v1/orders/{id}/attachments/{attachment}", s.kitchen(s.attachmentV1))
+	s.handle("DELETE /v1/orders/{id}/attachments/{attachment}", s.kitchen(s.detachV1))
+}
+
+// attachV1 attaches the file uploaded in the file field of a multipart form.
+// Its type is detected from its contents, whatever the client claims.
+func (s *Server) attachV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	token, err := s.om.GetOrder(r.Context(), id)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	if len(token.Attachments) >= s.attachments.MaxPerOrder() {
+		writeErrorf(w, r, http.StatusConflict, "order already has %d attachments", len(token.Attachments))
+		return
+	}
+
+	name, data, ok := upload(w, r, s.attachments.MaxSize())
+	if !ok {
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if !s.attachments.Accepts(contentType) {
+		mt, _, _ := mime.ParseMediaType(contentType)
+		writeErrorf(w, r, http.StatusUnsupportedMediaType, "files of type %s cannot be attached", mt)
+		return
+	}
+	a := queue.Attachment{ID: newAttachmentID(), Name: name, ContentType: contentType, Size: int64(len(data)), At: time.Now()}
+	if err := s.attachments.Put(r.Context(), id, a.ID, contentType, data); err != nil {
+		log.Printf("attach to order %s: %v", id, err)
+		writeError(w, r, http.StatusInternalServerError, "could not store the attachment")
+		return
+	}
+	span := opSpan(r, "AddAttachment")
+	_, err = s.om.AddAttachment(r.Context(), id, a)
+	span.Finish(err)
+	if err != nil {
+		if derr := s.attachments.Delete(r.Context(), id, a.ID); derr != nil {
+			log.Printf("attach to order %s: %v", id, derr)
+		}
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionModify, id, "", map[string]string{"attachment": a.Name})
+	w.Header().Set("Location", attachmentURL(id, a.ID))
+	writeJSON(w, http.StatusCreated, a)
+}
+
+// upload reads the file part of a multipart body of at most limit bytes,
+// writing the error and returning false when there is none or it is too big
+func upload(w http.ResponseWriter, r *http.Request, limit int64) (string, []byte, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, limit+multipartOverhead)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "upload the file as multipart/form-data")
+		return "", nil, false
+	}
+	for {
+		part, err := mr.NextPart()
+		var tooBig *http.MaxBytesError
+		switch {
+		case errors.Is(err, io.EOF):
+			writeError(w, r, http.StatusBadRequest, "upload the file in a field named file")
+			return "", nil, false
+		case errors.As(err, &tooBig):
+			writeErrorf(w, r, http.StatusRequestEntityTooLarge, "files must be at most %d bytes", limit)
+			return "", nil, false
+		case err != nil:
+			writeErrorf(w, r, http.StatusBadRequest, "invalid multipart body: %v", err)
+			return "", nil, false
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(part, limit+1))
+		part.Close()
+		switch {
+		case errors.As(err, &tooBig), err == nil && int64(len(data)) > limit:
+			writeErrorf(w, r, http.StatusRequestEntityTooLarge, "files must be at most %d bytes", limit)
+			return "", nil, false
+		case err != nil:
+			writeErrorf(w, r, http.StatusBadRequest, "invalid multipart body: %v", err)
+			return "", nil, false
+		case len(data) == 0:
+			writeError(w, r, http.StatusBadRequest, "the file is empty")
+			return "", nil, false
+		}
+		return attachmentName(part.FileName()), data, true
+	}
+}
+
+// attachmentName keeps the last element of an uploaded file's name, without
+// control characters and no longer than maxAttachmentName
+func attachmentName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name))
+	if runes := []rune(name); len(runes) > maxAttachmentName {
+		name = string(runes[:maxAttachmentName])
+	}
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+func newAttachmentID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// attachmentURL is where an attachment is served
+func attachmentURL(orderID, attachmentID string) string {
+	return "/v1/orders/" + orderID + "/attachments/" + attachmentID
+}
+
+// attachmentsV1 lists an order's attachments
+func (s *Server) attachmentsV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	token, err := s.om.GetOrder(r.Context(), id)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Attachments []queue.Attachment `json:"attachments"`
+	}{append([]queue.Attachment{}, token.Attachments...)})
+}
+
+// attachmentV1 serves an attachment's file. It is never sniffed or run as a
+// page, whatever it holds.
+func (s *Server) attachmentV1(w http.ResponseWriter, r *http.Request) {
+	id, a, ok := s.findAttachment(w, r)
+	if !ok {
+		return
+	}
+	f, err := s.attachments.Open(r.Context(), id, a.ID)
+	if errors.Is(err, attach.ErrNotFound) {
+		writeError(w, r, http.StatusNotFound, "attachment not found")
+		return
+	}
+	if err != nil {
+		log.Printf("attachment %s of order %s: %v", a.ID, id, err)
+		writeError(w, r, http.StatusInternalServerError, "could not read the attachment")
+		return
+	}
+	defer f.Close()
+	h := w.Header()
+	h.Set("Content-Type", a.ContentType)
+	h.Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	h.Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": a.Name}))
+	h.Set("Content-Security-Policy", "sandbox")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Cache-Control", "private, max-age=86400, immutable")
+	if _, err := io.Copy(w, f); err != nil {
+		log.Printf("send attachment %s of order %s: %v", a.ID, id, err)
+	}
+}
+
+// detachV1 removes an attachment from an order that has not been closed
+func (s *Server) detachV1(w http.ResponseWriter, r *http.Request) {
+	id, a, ok := s.findAttachment(w, r)
+	if !ok {
+		return
+	}
+	span := opSpan(r, "RemoveAttachment")
+	_, err := s.om.RemoveAttachment(r.Context(), id, a.ID)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	if err := s.attachments.Delete(r.Context(), id, a.ID); err != nil {
+		// The order no longer lists it, so it is only taking up room
+		log.Printf("delete attachment %s of order %s: %v", a.ID, id, err)
+	}
+	s.record(r, audit.ActionModify, id, "", map[string]string{"removedAttachment": a.Name})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findAttachment returns the attachment named in the path, writing the
+// error and returning false when the order has no such attachment
+func (s *Server) findAttachment(w http.ResponseWriter, r *http.Request) (string, queue.Attachment, bool) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return "", queue.Attachment{}, false
+	}
+	token, err := s.om.GetOrder(r.Context(), id)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return "", queue.Attachment{}, false
+	}
+	want := r.PathValue("attachment")
+	i := slices.IndexFunc(token.Attachments, func(a queue.Attachment) bool { return a.ID == want })
+	if i < 0 {
+		writeError(w, r, http.StatusNotFound, "attachment not found")
+		return "", queue.Attachment{}, false
+	}
+	return id, token.Attachments[i], true
+}
@@ -0,0 +1,105 @@
+package httpapi
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/attach"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// pngHeader is enough of a PNG file for its type to be detected
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func uploadFile(t *testing.T, h http.Handler, target, name string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAttachments(t *testing.T) {
+	files := attach.DefaultConfig()
+	files.Dir = t.TempDir()
+	files.MaxSize = 1 << 10
+	files.MaxPerOrder = 2
+	store, err := attach.New(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(manager.New(manager.DefaultConfig()), cfg, WithAttachments(store))
+
+	var order queue.Token
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=cake&priority=1"), &order)
+	target := "/v1/orders/" + order.ID + "/attachments"
+
+	rec := uploadFile(t, s, target, `C:\photos\design.png`, pngHeader)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("upload = %d %s", rec.Code, rec.Body)
+	}
+	var a queue.Attachment
+	decode(t, rec, &a)
+	if a.Name != "design.png" || a.ContentType != "image/png" || a.Size != int64(len(pngHeader)) {
+		t.Errorf("attachment = %+v", a)
+	}
+	if loc := rec.Header().Get("Location"); loc != target+"/"+a.ID {
+		t.Errorf("Location = %q", loc)
+	}
+
+	var list struct{ Attachments []queue.Attachment }
+	decode(t, do(t, s, http.MethodGet, target), &list)
+	if len(list.Attachments) != 1 || list.Attachments[0].ID != a.ID {
+		t.Errorf("list = %+v", list)
+	}
+	rec = do(t, s, http.MethodGet, target+"/"+a.ID)
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), pngHeader) {
+		t.Fatalf("download = %d %q", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Content-Type") != "image/png" || rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("download headers = %v", rec.Header())
+	}
+
+	if rec := uploadFile(t, s, target, "page.png", []byte("<html><script>alert(1)</script>")); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("html upload = %d", rec.Code)
+	}
+	big := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{0}, 2<<10)...)
+	if rec := uploadFile(t, s, target, "big.png", big); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversize upload = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, target); rec.Code != http.StatusBadRequest {
+		t.Errorf("upload without a body = %d", rec.Code)
+	}
+	uploadFile(t, s, target, "second.png", pngHeader)
+	if rec := uploadFile(t, s, target, "third.png", pngHeader); rec.Code != http.StatusConflict {
+		t.Errorf("upload past the limit = %d", rec.Code)
+	}
+
+	if rec := do(t, s, http.MethodDelete, target+"/"+a.ID); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodGet, target+"/"+a.ID); rec.Code != http.StatusNotFound {
+		t.Errorf("download after delete = %d", rec.Code)
+	}
+	var got queue.Token
+	decode(t, do(t, s, http.MethodGet, "/v1/orders/"+order.ID), &got)
+	if len(got.Attachments) != 1 || strings.Contains(got.Attachments[0].Name, "design") {
+		t.Errorf("attachments after delete = %+v", got.Attachments)
+	}
+}
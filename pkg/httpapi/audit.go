@@ -0,0 +1,165 @@
+package httpapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/validate"
+)
+
+// StaffHeader names the staff member making a request, for the audit log
+const StaffHeader = "X-Staff"
+
+// maxActor bounds the staff names recorded
+const maxActor = 100
+
+// WithAuditLog records staff actions to l and serves them at /v1/audit
+func WithAuditLog(l *audit.Log) Option {
+	return func(s *Server) { s.audit = l }
+}
+
+// registerAuditRoutes mounts the audit query, behind the admin token when
+// one is set
+func (s *Server) registerAuditRoutes() {
+	if s.audit == nil {
+		return
+	}
+	h := s.auditV1
+	if s.cfg.Admin.Token != "" {
+		h = s.admin(h)
+	}
+	s.handle("GET /v1/audit", h)
+}
+
+// auditList is the JSON payload for audit queries
+type auditList struct {
+	Entries []audit.Entry `json:"entries"`
+	Total   int           `json:"total"`
+	Limit   int           `json:"limit"`
+	Offset  int           `json:"offset"`
+}
+
+// auditV1 lists recorded actions newest first, filtered by actor, action,
+// from and to (RFC 3339) and paged by limit and offset
+func (s *Server) auditV1(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var errs validate.Errors
+	f := audit.Filter{
+		Actor:  q.Get("actor"),
+		Action: q.Get("action"),
+		From:   timeParam(q, "from", &errs),
+		To:     timeParam(q, "to", &errs),
+		Limit:  manager.DefaultListLimit,
+	}
+	if f.Action != "" && !slices.Contains(audit.Actions, f.Action) {
+		errs.Add("action", "must be one of %s", strings.Join(audit.Actions, ", "))
+	}
+	if n := intParam(q, "limit", &errs); n != nil {
+		if *n < 1 || *n > manager.MaxListLimit {
+			errs.Add("limit", "must be between 1 and %d", manager.MaxListLimit)
+		}
+		f.Limit = *n
+	}
+	if n := intParam(q, "offset", &errs); n != nil {
+		if *n < 0 {
+			errs.Add("offset", "must not be negative")
+		}
+		f.Offset = *n
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	entries, total := s.audit.Query(f)
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+	writeJSON(w, http.StatusOK, auditList{Entries: entries, Total: total, Limit: f.Limit, Offset: f.Offset})
+}
+
+// record adds a successful staff action to the audit log, when there is one.
+// by names the actor when the request sent no StaffHeader, and otherwise a
+// registered device's key names its device; detail holds the action's
+// parameters.
+func (s *Server) record(r *http.Request, action, orderID, by string, detail map[string]string) {
+	if s.audit == nil {
+		return
+	}
+	e := audit.Entry{
+		At:      time.Now(),
+		Action:  action,
+		OrderID: orderID,
+		Detail:  detail,
+		IP:      remoteIP(r),
+	}
+	var device string
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		e.APIKey = hex.EncodeToString(sum[:8])
+		if s.devices != nil {
+			if d, ok := s.devices.Authenticate(key); ok {
+				device = d.ID
+			}
+		}
+	}
+	switch staff := strings.TrimSpace(r.Header.Get(StaffHeader)); {
+	case staff != "":
+		e.Actor = truncate(staff, maxActor)
+	case by != "":
+		e.Actor = truncate(by, maxActor)
+	case device != "":
+		e.Actor = "device:" + device
+	case e.APIKey != "":
+		e.Actor = "key:" + e.APIKey
+	default:
+		e.Actor = "ip:" + e.IP
+	}
+	if err := s.audit.Record(e); err != nil {
+		log.Printf("audit: %v", err)
+	}
+}
+
+// params collects the named parameters given in q, for audit details
+func params(q url.Values, names ...string) map[string]string {
+	var detail map[string]string
+	for _, name := range names {
+		if !q.Has(name) {
+			continue
+		}
+		if detail == nil {
+			detail = make(map[string]string)
+		}
+		detail[name] = strings.Join(q[name], ",")
+	}
+	return detail
+}
+
+// remoteIP returns the address of the connection a request came in on
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// truncate cuts s to at most n bytes, on a rune boundary
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
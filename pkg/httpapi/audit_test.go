@@ -0,0 +1,90 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/manager"
+)
+
+func openAudit(t *testing.T) *audit.Log {
+	t.Helper()
+	cfg := audit.DefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := audit.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestAuditV1(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(manager.New(manager.DefaultConfig()), cfg, WithAuditLog(openAudit(t)))
+	staff := func(method, target, name, key string) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		if name != "" {
+			req.Header.Set(StaffHeader, name)
+		}
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s = %d %s", method, target, rec.Code, rec.Body)
+		}
+	}
+	for range 3 {
+		do(t, s, http.MethodPost, "/v1/orders?item=soup&priority=3")
+	}
+	staff(http.MethodPatch, "/v1/orders/1?priority=1&lang=es", "ana", "")
+	staff(http.MethodPost, "/v1/orders/2/rush?by=bo", "", "")
+	staff(http.MethodPost, "/v1/orders/next", "ana", "")
+	staff(http.MethodPost, "/v1/orders/3/cancel", "", "k3y")
+	// Failed actions are not recorded
+	if rec := do(t, s, http.MethodPost, "/v1/orders/3/cancel"); rec.Code != http.StatusConflict {
+		t.Fatalf("second cancel = %d", rec.Code)
+	}
+
+	tests := []struct {
+		target string
+		want   string // action:order:actor of each entry
+		total  int
+	}{
+		{"/v1/audit", "[cancel:3:key:a49b1287870c10a7 prepare:2:ana rush:2:bo modify:1:ana]", 4},
+		{"/v1/audit?actor=ana", "[prepare:2:ana modify:1:ana]", 2},
+		{"/v1/audit?action=rush", "[rush:2:bo]", 1},
+		{"/v1/audit?limit=1&offset=1", "[prepare:2:ana]", 4},
+		{"/v1/audit?to=2000-01-01T00:00:00Z", "[]", 0},
+	}
+	for _, tt := range tests {
+		rec := do(t, s, http.MethodGet, tt.target)
+		var list auditList
+		decode(t, rec, &list)
+		var got []string
+		for _, e := range list.Entries {
+			got = append(got, e.Action+":"+e.OrderID+":"+e.Actor)
+		}
+		if fmt.Sprint(got) != tt.want || list.Total != tt.total {
+			t.Errorf("%s = %v (%d), want %s (%d)", tt.target, got, list.Total, tt.want, tt.total)
+		}
+	}
+
+	rec := do(t, s, http.MethodGet, "/v1/audit?action=modify")
+	var list auditList
+	decode(t, rec, &list)
+	if e := list.Entries[0]; e.Detail["priority"] != "1" || len(e.Detail) != 1 || e.IP == "" || e.APIKey != "" {
+		t.Errorf("modify entry = %+v", e)
+	}
+	if rec := do(t, s, http.MethodGet, "/v1/audit?action=bake"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unknown action = %d", rec.Code)
+	}
+}
@@ -0,0 +1,88 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/queue"
+	"awesomeProject/pkg/validate"
+)
+
+// registerAvailabilityRoutes mounts marking menu items run out and back
+func (s *Server) registerAvailabilityRoutes() {
+	s.handle("GET /v1/unavailable", s.listUnavailableV1)
+	s.handle("PUT /v1/unavailable/{item}", s.markUnavailableV1)
+	s.handle("DELETE /v1/unavailable/{item}", s.markAvailableV1)
+}
+
+// availabilityChange is the JSON payload for marking an item: the orders
+// flagged or cleared by it
+type availabilityChange struct {
+	Item   string `json:"item"`
+	Orders any    `json:"orders"` // As views gives them
+}
+
+// listUnavailableV1 lists the items marked unavailable
+func (s *Server) listUnavailableV1(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string][]string{"items": s.om.UnavailableItems()})
+}
+
+// markUnavailableV1 marks an item run out, flagging the orders for it and,
+// when the manager blocks unavailable orders, parking them
+func (s *Server) markUnavailableV1(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.itemParam(w, r)
+	if !ok {
+		return
+	}
+	span := opSpan(r, "MarkUnavailable")
+	orders, err := s.om.MarkUnavailable(r.Context(), item)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionUnavailable, "", "", map[string]string{"item": item})
+	writeJSON(w, http.StatusOK, availabilityChange{Item: item, Orders: s.views(w, r, orders)})
+}
+
+// markAvailableV1 marks an item back, clearing its orders and queuing those
+// parked for it again
+func (s *Server) markAvailableV1(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.itemParam(w, r)
+	if !ok {
+		return
+	}
+	span := opSpan(r, "MarkAvailable")
+	orders, err := s.om.MarkAvailable(r.Context(), item)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionAvailable, "", "", map[string]string{"item": item})
+	writeJSON(w, http.StatusOK, availabilityChange{Item: item, Orders: s.views(w, r, orders)})
+}
+
+// itemParam reads and checks the item path parameter, writing the error
+// response when it is invalid
+func (s *Server) itemParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if _, ok := input(w, r); !ok {
+		return "", false
+	}
+	item := r.PathValue("item")
+	var errs validate.Errors
+	s.cfg.Validation.Item(&errs, item)
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return "", false
+	}
+	return item, true
+}
+
+// nonNil returns orders, or an empty list for none so it encodes as []
+func nonNil(orders []*queue.Token) []*queue.Token {
+	if orders == nil {
+		return []*queue.Token{}
+	}
+	return orders
+}
@@ -0,0 +1,46 @@
+package httpapi
+
+import (
+	"net/http"
+	"testing"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestAvailabilityV1(t *testing.T) {
+	mcfg := manager.DefaultConfig()
+	mcfg.BlockUnavailable = true
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(manager.New(mcfg), cfg)
+	do(t, s, http.MethodPost, "/v1/orders?item=oat+latte&priority=1")
+	do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1")
+
+	rec := do(t, s, http.MethodPut, "/v1/unavailable/Oat%20Latte")
+	var change struct{ Orders []*queue.Token }
+	decode(t, rec, &change)
+	if rec.Code != http.StatusOK || len(change.Orders) != 1 || change.Orders[0].Status != queue.StatusBlocked {
+		t.Fatalf("mark unavailable = %d %+v", rec.Code, change)
+	}
+	var items struct{ Items []string }
+	decode(t, do(t, s, http.MethodGet, "/v1/unavailable"), &items)
+	if len(items.Items) != 1 || items.Items[0] != "oat latte" {
+		t.Errorf("items = %v", items.Items)
+	}
+	var list orderList
+	decode(t, do(t, s, http.MethodGet, "/v1/orders?status=blocked"), &list)
+	if list.Total != 1 {
+		t.Errorf("%d blocked orders, want 1", list.Total)
+	}
+
+	rec = do(t, s, http.MethodDelete, "/v1/unavailable/oat%20latte")
+	var back struct{ Orders []*queue.Token }
+	decode(t, rec, &back)
+	if rec.Code != http.StatusOK || len(back.Orders) != 1 || back.Orders[0].Status != queue.StatusPreparing || back.Orders[0].Unavailable {
+		t.Fatalf("mark available = %d %+v", rec.Code, back)
+	}
+	if rec := do(t, s, http.MethodPut, "/v1/unavailable/%20"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("blank item = %d", rec.Code)
+	}
+}
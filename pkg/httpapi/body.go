@@ -0,0 +1,121 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+
+	"awesomeProject/pkg/validate"
+)
+
+// maxInputBody bounds the JSON bodies of mutating requests
+const maxInputBody = 1 << 20
+
+// input returns a mutating request's parameters: its query string, with the
+// fields of a JSON body, when it has one, taking the place of query
+// parameters of the same name. fields lists the names the endpoint accepts in
+// a body. Values may be strings, numbers, booleans or arrays of those, an
+// array standing for a repeated parameter; null leaves a field out. Bodies
+// with other fields or values, trailing data or more than maxInputBody bytes
+// are rejected: input writes the error and returns false.
+func input(w http.ResponseWriter, r *http.Request, fields ...string) (url.Values, bool) {
+	q := r.URL.Query()
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+		return q, true
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxInputBody))
+	dec.UseNumber()
+	var body map[string]any
+	err := dec.Decode(&body)
+	if err == nil {
+		if _, next := dec.Token(); next != io.EOF {
+			err = errors.New("unexpected data after the JSON object")
+		}
+	}
+	switch {
+	case errors.Is(err, io.EOF):
+		return q, true // Empty body
+	case err != nil:
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+			return nil, false
+		}
+		writeErrorf(w, r, http.StatusBadRequest, "invalid JSON body: %v", err)
+		return nil, false
+	}
+
+	names := make([]string, 0, len(body))
+	for name := range body {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs validate.Errors
+	for _, name := range names {
+		if !slices.Contains(fields, name) {
+			errs.Malformed(name, "unknown field")
+			continue
+		}
+		values, ok := bodyValues(body[name])
+		if !ok {
+			errs.Malformed(name, "must be a string, number, boolean or array of those")
+			continue
+		}
+		if values == nil {
+			q.Del(name)
+			continue
+		}
+		q[name] = values
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return nil, false
+	}
+	return q, true
+}
+
+// bodyValues converts a decoded JSON value to parameter values, nil for null
+func bodyValues(v any) ([]string, bool) {
+	if list, ok := v.([]any); ok {
+		if len(list) == 0 {
+			return []string{""}, true // Present but empty, as flags= is
+		}
+		values := make([]string, len(list))
+		for i, item := range list {
+			s, ok := scalar(item)
+			if !ok || item == nil {
+				return nil, false
+			}
+			values[i] = s
+		}
+		return values, true
+	}
+	if v == nil {
+		return nil, true
+	}
+	s, ok := scalar(v)
+	if !ok {
+		return nil, false
+	}
+	return []string{s}, true
+}
+
+func scalar(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case nil:
+		return "", true
+	}
+	return "", false
+}
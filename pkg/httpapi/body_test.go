@@ -0,0 +1,74 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/queue"
+)
+
+func doJSON(t *testing.T, h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestJSONBody(t *testing.T) {
+	s := newTestServer(t)
+
+	// Body fields take the place of query parameters
+	rec := doJSON(t, s, http.MethodPost, "/v1/orders?item=soup",
+		`{"item": "pizza", "priority": 2, "quantity": 3, "flags": ["vegan", "nuts"], "notes": null}`)
+	var tok queue.Token
+	decode(t, rec, &tok)
+	if rec.Code != http.StatusCreated || tok.Item != "pizza" || tok.Priority != 2 || tok.Quantity != 3 ||
+		!slices.Equal(tok.Flags, []string{"nuts", "vegan"}) {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body)
+	}
+
+	rec = doJSON(t, s, http.MethodPatch, "/v1/orders/1", `{"flags": [], "notes": "extra cheese"}`)
+	tok = queue.Token{}
+	decode(t, rec, &tok)
+	if rec.Code != http.StatusOK || len(tok.Flags) != 0 || tok.Notes != "extra cheese" {
+		t.Fatalf("modify = %d %+v", rec.Code, tok)
+	}
+
+	// Endpoints without parameters accept an empty object or no body
+	if rec := doJSON(t, s, http.MethodPost, "/v1/orders/1/cancel", `{}`); rec.Code != http.StatusOK {
+		t.Fatalf("cancel = %d %s", rec.Code, rec.Body)
+	}
+	if rec := doJSON(t, s, http.MethodPost, "/v1/orders/1/restore", ``); rec.Code != http.StatusOK {
+		t.Fatalf("restore = %d %s", rec.Code, rec.Body)
+	}
+
+	for _, tt := range []struct {
+		name, body string
+		status     int
+		field      string
+	}{
+		{"unknown field", `{"item": "pizza", "priority": 1, "colour": "red"}`, http.StatusBadRequest, "colour"},
+		{"object value", `{"item": {"name": "pizza"}, "priority": 1}`, http.StatusBadRequest, "item"},
+		{"trailing data", `{"item": "pizza", "priority": 1} {}`, http.StatusBadRequest, ""},
+		{"not an object", `["pizza"]`, http.StatusBadRequest, ""},
+		{"too large", `{"notes": "` + strings.Repeat("x", maxInputBody) + `"}`, http.StatusRequestEntityTooLarge, ""},
+		{"malformed value", `{"item": "pizza", "priority": "high"}`, http.StatusBadRequest, "priority"},
+		{"validation", `{"item": "", "priority": 1}`, http.StatusUnprocessableEntity, "item"},
+	} {
+		rec := doJSON(t, s, http.MethodPost, "/v1/orders", tt.body)
+		var body errorBody
+		decode(t, rec, &body)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d (%+v)", tt.name, rec.Code, tt.status, body)
+			continue
+		}
+		if tt.field != "" && (len(body.Fields) != 1 || body.Fields[0].Field != tt.field) {
+			t.Errorf("%s: fields = %+v", tt.name, body.Fields)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package httpapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/audit"
+)
+
+// BrandingConfig puts the restaurant's name, logo and colors on the pages
+// served: the kitchen display, the customer status page and HTML tickets
+type BrandingConfig struct {
+	Name string `json:"name"` // Shown in page titles and headers
+
+	// Logo is the image file shown in page headers and served at
+	// /brand/logo. Admins may upload a new one, which replaces the file;
+	// empty shows none and refuses uploads.
+	Logo string `json:"logo"`
+
+	// Colors override each page's own; those left empty keep them
+	Colors Palette `json:"colors"`
+}
+
+// Palette is a set of CSS colors in #rgb or #rrggbb form
+type Palette struct {
+	Primary    string `json:"primary"`    // Headers and the ready banner
+	Accent     string `json:"accent"`     // Highlights, such as the next order on the display
+	Background string `json:"background"` // Page background
+	Text       string `json:"text"`       // Body text
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Validate checks that every color given is a hex color
+func (c BrandingConfig) Validate() error {
+	p := c.Colors
+	for _, color := range []struct{ name, value string }{
+		{"primary", p.Primary}, {"accent", p.Accent}, {"background", p.Background}, {"text", p.Text},
+	} {
+		if color.value != "" && !hexColor.MatchString(color.value) {
+			return fmt.Errorf("branding color %s must be #rgb or #rrggbb, not %q", color.name, color.value)
+		}
+	}
+	return nil
+}
+
+// maxLogoSize is the largest logo that may be uploaded
+const maxLogoSize = 1 << 20
+
+// logoTypes are the image types accepted as the logo. SVG is not, as it may
+// carry script.
+var logoTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+//go:embed brand.html
+var brandHTML string
+
+// page parses the HTML template of a page along with the brand templates it
+// includes for its colors and header
+func page(name, text string) *template.Template {
+	return template.Must(template.Must(template.New(name).Parse(text)).Parse(brandHTML))
+}
+
+// brand is what pages are given to show the branding
+type brand struct {
+	Name   string
+	Logo   string // The logo's URL, versioned by its contents; empty without one
+	Colors Palette
+}
+
+// branding holds the logo in memory, as it is on every page load, and
+// replaces the file when a new one is uploaded
+type branding struct {
+	cfg         BrandingConfig
+	mu          sync.RWMutex
+	logo        []byte
+	contentType string
+	version     string // Hash of the logo, for its URL and ETag
+	modified    time.Time
+}
+
+// newBranding loads the configured logo. One not yet uploaded is no error;
+// one that cannot be read is logged and left out.
+func newBranding(cfg BrandingConfig) *branding {
+	b := &branding{cfg: cfg}
+	if cfg.Logo == "" {
+		return b
+	}
+	data, err := os.ReadFile(cfg.Logo)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		log.Printf("branding: %v", err)
+	default:
+		modified := time.Now()
+		if fi, err := os.Stat(cfg.Logo); err == nil {
+			modified = fi.ModTime()
+		}
+		b.set(data, modified)
+	}
+	return b
+}
+
+// set makes data the logo; mu must be held for writing when others may be
+// reading
+func (b *branding) set(data []byte, modified time.Time) {
+	sum := sha256.Sum256(data)
+	b.logo, b.contentType, b.version, b.modified = data, http.DetectContentType(data), hex.EncodeToString(sum[:8]), modified
+}
+
+// page returns the branding for a page to show
+func (b *branding) page() brand {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	br := brand{Name: b.cfg.Name, Colors: b.cfg.Colors}
+	if b.logo != nil {
+		br.Logo = "/brand/logo?v=" + b.version
+	}
+	return br
+}
+
+// store writes data over the logo file, then serves it
+func (b *branding) store(data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f, err := os.CreateTemp(filepath.Dir(b.cfg.Logo), ".logo-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, b.cfg.Logo)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	b.set(data, time.Now())
+	return nil
+}
+
+// remove deletes the logo file, so pages show none
+func (b *branding) remove() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := os.Remove(b.cfg.Logo); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	b.logo, b.contentType, b.version = nil, "", ""
+	return nil
+}
+
+// brandingView is the branding as served by the API
+type brandingView struct {
+	Name   string  `json:"name,omitempty"`
+	Logo   string  `json:"logo,omitempty"` // URL of the logo, when there is one
+	Colors Palette `json:"colors"`
+}
+
+// registerBrandingRoutes serves the branding and the logo, and lets admins
+// upload a new logo when a logo file is configured
+func (s *Server) registerBrandingRoutes() {
+	s.handle("GET /v1/branding", s.brandingV1)
+	s.handle("GET /brand/logo", s.logo)
+	if s.cfg.Admin.Token != "" && s.cfg.Branding.Logo != "" {
+		s.handle("PUT /v1/admin/branding/logo", s.admin(s.uploadLogoV1))
+		s.handle("DELETE /v1/admin/branding/logo", s.admin(s.deleteLogoV1))
+	}
+}
+
+// brandingV1 returns the name, logo URL and colors pages are shown with,
+// for clients that draw their own
+func (s *Server) brandingV1(w http.ResponseWriter, r *http.Request) {
+	br := s.branding.page()
+	writeJSON(w, http.StatusOK, brandingView{Name: br.Name, Logo: br.Logo, Colors: br.Colors})
+}
+
+// logo serves the logo. Its URL carries the version of its contents, so
+// browsers may keep it until the next upload changes the URL.
+func (s *Server) logo(w http.ResponseWriter, r *http.Request) {
+	b := s.branding
+	b.mu.RLock()
+	data, contentType, version, modified := b.logo, b.contentType, b.version, b.modified
+	b.mu.RUnlock()
+	if data == nil {
+		writeError(w, r, http.StatusNotFound, "no logo")
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("ETag", `"`+version+`"`)
+	if r.URL.Query().Get("v") == version {
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, "", modified, bytes.NewReader(data))
+}
+
+// uploadLogoV1 replaces the logo with the image uploaded in the file field
+// of a multipart form
+func (s *Server) uploadLogoV1(w http.ResponseWriter, r *http.Request) {
+	_, data, ok := upload(w, r, maxLogoSize)
+	if !ok {
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if !slices.Contains(logoTypes, contentType) {
+		writeErrorf(w, r, http.StatusUnsupportedMediaType, "the logo must be a PNG, JPEG, GIF or WebP image, not %s", contentType)
+		return
+	}
+	if err := s.branding.store(data); err != nil {
+		log.Printf("branding: store logo: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "could not store the logo")
+		return
+	}
+	s.record(r, audit.ActionBranding, "", "", map[string]string{"logo": contentType})
+	s.brandingV1(w, r)
+}
+
+// deleteLogoV1 removes the logo
+func (s *Server) deleteLogoV1(w http.ResponseWriter, r *http.Request) {
+	if err := s.branding.remove(); err != nil {
+		log.Printf("branding: remove logo: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "could not remove the logo")
+		return
+	}
+	s.record(r, audit.ActionBranding, "", "", map[string]string{"logo": "removed"})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,138 @@
+package httpapi
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func uploadLogo(t *testing.T, h http.Handler, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "logo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPut, "/v1/admin/branding/logo", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBranding(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Token = "token"
+	cfg.Branding = BrandingConfig{
+		Name:   "Café <Rosa>",
+		Logo:   filepath.Join(t.TempDir(), "logo.png"),
+		Colors: Palette{Primary: "#7a1f1f", Accent: "#fc0"},
+	}
+	s := New(manager.New(manager.DefaultConfig()), cfg)
+
+	var view brandingView
+	decode(t, do(t, s, http.MethodGet, "/v1/branding"), &view)
+	if view.Name != cfg.Branding.Name || view.Logo != "" || view.Colors != cfg.Branding.Colors {
+		t.Errorf("branding = %+v", view)
+	}
+	if rec := do(t, s, http.MethodGet, "/brand/logo"); rec.Code != http.StatusNotFound {
+		t.Errorf("logo before upload = %d", rec.Code)
+	}
+
+	rec := do(t, s, http.MethodGet, "/kds")
+	page := rec.Body.String()
+	for _, want := range []string{"--brand-primary: #7a1f1f;", "--brand-accent: #fc0;", "Café &lt;Rosa&gt;", "var(--brand-background, #111)"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("kds page lacks %q", want)
+		}
+	}
+	if strings.Contains(page, "--brand-text:") || strings.Contains(page, "/brand/logo") {
+		t.Error("kds page sets colors or a logo not configured")
+	}
+
+	if rec := uploadLogo(t, s, []byte("<svg><script>alert(1)</script></svg>")); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("svg upload = %d", rec.Code)
+	}
+	if rec := uploadLogo(t, s, bytes.Repeat([]byte{0}, maxLogoSize+1)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large upload = %d", rec.Code)
+	}
+	rec = uploadLogo(t, s, pngHeader)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload = %d %s", rec.Code, rec.Body)
+	}
+	decode(t, rec, &view)
+	if !strings.HasPrefix(view.Logo, "/brand/logo?v=") {
+		t.Fatalf("logo = %q", view.Logo)
+	}
+	if data, err := os.ReadFile(cfg.Branding.Logo); err != nil || !bytes.Equal(data, pngHeader) {
+		t.Errorf("logo file = %q, %v", data, err)
+	}
+
+	rec = do(t, s, http.MethodGet, view.Logo)
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), pngHeader) || rec.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("logo = %d %v", rec.Code, rec.Header())
+	}
+	if cc := rec.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Errorf("versioned Cache-Control = %q", cc)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/brand/logo", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	cached := httptest.NewRecorder()
+	s.ServeHTTP(cached, req)
+	if cached.Code != http.StatusNotModified || cached.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("revalidated logo = %d %v", cached.Code, cached.Header())
+	}
+
+	var order queue.Token
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1"), &order)
+	ticket := do(t, s, http.MethodGet, "/v1/orders/"+order.ID+"/ticket?format=html").Body.String()
+	if !strings.Contains(ticket, `<img src="`+view.Logo+`"`) || !strings.Contains(ticket, "Café &lt;Rosa&gt;") {
+		t.Errorf("ticket lacks the branding:\n%s", ticket)
+	}
+
+	// A server started later loads the uploaded logo
+	again := New(manager.New(manager.DefaultConfig()), cfg)
+	var reloaded brandingView
+	decode(t, do(t, again, http.MethodGet, "/v1/branding"), &reloaded)
+	if reloaded.Logo != view.Logo {
+		t.Errorf("reloaded logo = %q, want %q", reloaded.Logo, view.Logo)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/v1/admin/branding/logo", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete = %d", rec.Code)
+	}
+	if _, err := os.Stat(cfg.Branding.Logo); !os.IsNotExist(err) {
+		t.Errorf("logo file after delete: %v", err)
+	}
+	if rec := do(t, s, http.MethodGet, "/brand/logo"); rec.Code != http.StatusNotFound {
+		t.Errorf("logo after delete = %d", rec.Code)
+	}
+}
+
+func TestBrandingValidate(t *testing.T) {
+	for _, color := range []string{"red", "#12345", "#1234567", "#gggggg", "#fff;}"} {
+		if err := (BrandingConfig{Colors: Palette{Text: color}}).Validate(); err == nil {
+			t.Errorf("color %q accepted", color)
+		}
+	}
+	if err := (BrandingConfig{Colors: Palette{Primary: "#abc", Background: "#A0B1C2"}}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,55 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"awesomeProject/pkg/devices"
+	"awesomeProject/pkg/queue"
+)
+
+// ChannelConfig tells which channel an order came through when its client
+// does not say. Orders from registered kiosks came through the kiosk
+// channel and those naming a delivery platform through the delivery
+// channel; the rest are known by the bearer token they are placed with.
+type ChannelConfig struct {
+	// Tokens gives the bearer token of each channel's clients, such as the
+	// counter till or the website, by channel
+	Tokens map[string]string `json:"tokens"`
+
+	// Default is the channel of orders nothing else places; empty leaves
+	// them unknown
+	Default string `json:"default"`
+}
+
+// Validate checks that every channel named is one of the queue channels
+func (c ChannelConfig) Validate() error {
+	for channel := range c.Tokens {
+		if !queue.ValidChannel(channel) {
+			return fmt.Errorf("unknown channel %q, want one of %s", channel, strings.Join(queue.Channels, ", "))
+		}
+	}
+	if c.Default != "" && !queue.ValidChannel(c.Default) {
+		return fmt.Errorf("unknown default channel %q, want one of %s", c.Default, strings.Join(queue.Channels, ", "))
+	}
+	return nil
+}
+
+// channel returns the channel an order placed by r came through, as far as
+// its credentials tell
+func (s *Server) channel(r *http.Request) string {
+	if s.devices != nil {
+		if d, ok := s.devices.Authenticate(r.Header.Get("X-API-Key")); ok && d.Role == devices.RoleKiosk {
+			return queue.ChannelKiosk
+		}
+	}
+	got := []byte(r.Header.Get("Authorization"))
+	for channel, token := range s.cfg.Channels.Tokens {
+		if token != "" && subtle.ConstantTimeCompare(got, []byte("Bearer "+token)) == 1 {
+			return channel
+		}
+	}
+	return s.cfg.Channels.Default
+}
@@ -0,0 +1,93 @@
+package httpapi
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"awesomeProject/pkg/analytics"
+	"awesomeProject/pkg/devices"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestOrderChannels(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Channels = ChannelConfig{Tokens: map[string]string{queue.ChannelCounter: "t1ll"}, Default: queue.ChannelWeb}
+	reg, err := devices.Open(devices.Config{Path: t.TempDir() + "/devices.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, kioskKey, err := reg.Register("kiosk-1", "", devices.RoleKiosk, devices.Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(manager.New(manager.DefaultConfig()), cfg, WithDevices(reg))
+
+	place := func(target, header, value string) queue.Token {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("%s = %d %s", target, rec.Code, rec.Body)
+		}
+		var token queue.Token
+		decode(t, rec, &token)
+		return token
+	}
+	for _, tc := range []struct {
+		target, header, value, want string
+	}{
+		{"/v1/orders?item=tea&priority=1&channel=phone", "X-API-Key", kioskKey, queue.ChannelPhone},
+		{"/v1/orders?item=tea&priority=1", "X-API-Key", kioskKey, queue.ChannelKiosk},
+		{"/v1/orders?item=soup&priority=1", "Authorization", "Bearer t1ll", queue.ChannelCounter},
+		{"/v1/orders?item=soup&priority=1&platform=eats&externalId=E1", "", "", queue.ChannelDelivery},
+		{"/v1/orders?item=tea&priority=1", "Authorization", "Bearer wrong", queue.ChannelWeb},
+	} {
+		if got := place(tc.target, tc.header, tc.value).Channel; got != tc.want {
+			t.Errorf("%s with %s %q: channel %q, want %q", tc.target, tc.header, tc.value, got, tc.want)
+		}
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1&channel=fax"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unknown channel = %d", rec.Code)
+	}
+
+	var list orderList
+	decode(t, do(t, s, http.MethodGet, "/v1/orders?channel=kiosk"), &list)
+	if list.Total != 1 || list.Orders[0].Channel != queue.ChannelKiosk {
+		t.Errorf("kiosk orders = %+v", list)
+	}
+
+	var stats analytics.Report
+	decode(t, do(t, s, http.MethodGet, "/v1/stats"), &stats)
+	if len(stats.Channels) != 5 || stats.Channels[0].Channel != queue.ChannelCounter || stats.Channels[0].Orders != 1 || stats.Channels[0].Share != 20 {
+		t.Errorf("channels = %+v", stats.Channels)
+	}
+
+	rec := do(t, s, http.MethodGet, "/v1/export?channel=counter")
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0][len(rows[0])-1] != "channel" || rows[1][len(rows[1])-1] != queue.ChannelCounter {
+		t.Errorf("export = %q", rows)
+	}
+}
+
+func TestChannelConfigValidate(t *testing.T) {
+	if err := (ChannelConfig{Tokens: map[string]string{"fax": "x"}}).Validate(); err == nil {
+		t.Error("unknown channel token accepted")
+	}
+	if err := (ChannelConfig{Default: "fax"}).Validate(); err == nil {
+		t.Error("unknown default channel accepted")
+	}
+	if err := (ChannelConfig{Tokens: map[string]string{queue.ChannelCounter: "x"}, Default: queue.ChannelWeb}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
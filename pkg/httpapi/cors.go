@@ -0,0 +1,103 @@
+package httpapi
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/config"
+)
+
+// CORSConfig controls cross-origin access for browser frontends such as
+// kiosks served from another origin. CORS is off while AllowedOrigins is empty.
+type CORSConfig struct {
+	AllowedOrigins   []string        `json:"allowedOrigins"` // Exact origins such as "https://kiosk.example.com", or "*" for any
+	AllowedMethods   []string        `json:"allowedMethods"`
+	AllowedHeaders   []string        `json:"allowedHeaders"`
+	ExposedHeaders   []string        `json:"exposedHeaders"` // Response headers scripts may read
+	AllowCredentials bool            `json:"allowCredentials"`
+	MaxAge           config.Duration `json:"maxAge"` // How long browsers may cache a preflight
+}
+
+// DefaultCORSConfig returns CORS disabled, with the methods and headers the
+// API uses ready for when origins are configured
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPatch},
+		AllowedHeaders: []string{"Content-Type", "X-API-Key", "If-Match"},
+		ExposedHeaders: []string{"Retry-After", "ETag"},
+		MaxAge:         config.Duration(10 * time.Minute),
+	}
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, or ""
+// when it is not allowed
+func (c CORSConfig) allowOrigin(origin string) string {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" && !c.AllowCredentials {
+			return "*"
+		}
+		if o == "*" || strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// cors answers preflight requests for every route and adds the CORS headers
+// to actual requests from allowed origins
+func cors(c CORSConfig, next http.Handler) http.Handler {
+	if len(c.AllowedOrigins) == 0 {
+		return next
+	}
+	methods := strings.Join(c.AllowedMethods, ", ")
+	headers := strings.Join(c.AllowedHeaders, ", ")
+	exposed := strings.Join(c.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(time.Duration(c.MaxAge).Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		allowed := c.allowOrigin(origin)
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			method := r.Header.Get("Access-Control-Request-Method")
+			if allowed == "" || !slices.Contains(c.AllowedMethods, method) {
+				writeError(w, r, http.StatusForbidden, "cross-origin request not allowed")
+				return
+			}
+			h.Set("Access-Control-Allow-Origin", allowed)
+			h.Set("Access-Control-Allow-Methods", methods)
+			if headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			}
+			if c.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			h.Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed != "" {
+			h.Set("Access-Control-Allow-Origin", allowed)
+			if exposed != "" {
+				h.Set("Access-Control-Expose-Headers", exposed)
+			}
+			if c.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,80 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"awesomeProject/pkg/manager"
+)
+
+func newCORSServer(t *testing.T, origins ...string) *Server {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.CORS.AllowedOrigins = origins
+	return New(manager.New(manager.DefaultConfig()), cfg)
+}
+
+func corsRequest(t *testing.T, h http.Handler, method, target, origin, requestMethod string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Origin", origin)
+	if requestMethod != "" {
+		req.Header.Set("Access-Control-Request-Method", requestMethod)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSPreflight(t *testing.T) {
+	s := newCORSServer(t, "https://kiosk.example.com")
+
+	for _, target := range []string{"/v1/orders", "/v1/orders/1/cancel", "/addOrder"} {
+		rec := corsRequest(t, s, http.MethodOptions, target, "https://kiosk.example.com", http.MethodPost)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("preflight %s status = %d, want 204", target, rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://kiosk.example.com" {
+			t.Fatalf("preflight %s Allow-Origin = %q", target, got)
+		}
+		if rec.Header().Get("Access-Control-Allow-Methods") == "" || rec.Header().Get("Access-Control-Max-Age") != "600" {
+			t.Fatalf("preflight %s headers = %v", target, rec.Header())
+		}
+	}
+
+	if rec := corsRequest(t, s, http.MethodOptions, "/v1/orders", "https://evil.example.com", http.MethodPost); rec.Code != http.StatusForbidden {
+		t.Fatalf("preflight from unknown origin status = %d, want 403", rec.Code)
+	}
+	if rec := corsRequest(t, s, http.MethodOptions, "/v1/orders", "https://kiosk.example.com", http.MethodDelete); rec.Code != http.StatusForbidden {
+		t.Fatalf("preflight for disallowed method status = %d, want 403", rec.Code)
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	s := newCORSServer(t, "*")
+
+	rec := corsRequest(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1", "https://kiosk.example.com", "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("Allow-Origin = %q, want *", got)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "Retry-After, ETag" {
+		t.Fatalf("Expose-Headers = %q", got)
+	}
+
+	if rec := do(t, s, http.MethodGet, "/v1/orders"); rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("CORS headers on a same-origin request")
+	}
+}
+
+func TestCORSDisabledByDefault(t *testing.T) {
+	s := newTestServer(t)
+	rec := corsRequest(t, s, http.MethodOptions, "/v1/orders", "https://kiosk.example.com", http.MethodPost)
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("preflight without CORS configured = %d %v", rec.Code, rec.Header())
+	}
+}
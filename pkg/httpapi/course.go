@@ -0,0 +1,46 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/validate"
+)
+
+// registerCourseRoutes mounts firing the held courses of a table or check
+func (s *Server) registerCourseRoutes() {
+	s.handle("POST /v1/groups/{group}/fire", s.fireCourseV1)
+}
+
+// firedCourse is the JSON payload for a fired course: the orders let into
+// the kitchen
+type firedCourse struct {
+	Group  string `json:"group"`
+	Course int    `json:"course"`
+	Orders any    `json:"orders"` // As views gives them
+}
+
+// fireCourseV1 releases the next course held for a group into the queue
+func (s *Server) fireCourseV1(w http.ResponseWriter, r *http.Request) {
+	if _, ok := input(w, r); !ok {
+		return
+	}
+	group := r.PathValue("group")
+	var errs validate.Errors
+	s.cfg.Validation.Group(&errs, group, true)
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	span := opSpan(r, "FireCourse")
+	orders, err := s.om.FireCourse(r.Context(), group)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	course := orders[0].Course
+	s.record(r, audit.ActionFire, "", "", map[string]string{"group": group, "course": strconv.Itoa(course)})
+	writeJSON(w, http.StatusOK, firedCourse{Group: group, Course: course, Orders: s.views(w, r, orders)})
+}
@@ -0,0 +1,38 @@
+package httpapi
+
+import (
+	"net/http"
+	"testing"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestFireCourseV1(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(manager.New(manager.DefaultConfig()), cfg)
+	do(t, s, http.MethodPost, "/v1/orders?item=soup&priority=1&group=t1")
+	rec := do(t, s, http.MethodPost, "/v1/orders?item=steak&priority=1&group=t1&course=2")
+	var main queue.Token
+	decode(t, rec, &main)
+	if rec.Code != http.StatusCreated || main.Status != queue.StatusOnHold || main.Course != 2 {
+		t.Fatalf("main = %d %+v", rec.Code, main)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders?item=cake&priority=1&course=2"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("course without group = %d", rec.Code)
+	}
+
+	rec = do(t, s, http.MethodPost, "/v1/groups/t1/fire")
+	var fired struct {
+		Course int
+		Orders []*queue.Token
+	}
+	decode(t, rec, &fired)
+	if rec.Code != http.StatusOK || fired.Course != 2 || len(fired.Orders) != 1 || fired.Orders[0].Status != queue.StatusPreparing {
+		t.Fatalf("fire = %d %+v", rec.Code, fired)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/groups/t1/fire"); rec.Code != http.StatusConflict {
+		t.Errorf("fire again = %d", rec.Code)
+	}
+}
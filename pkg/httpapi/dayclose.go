@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"awesomeProject/pkg/analytics"
+	"awesomeProject/pkg/archive"
+	"awesomeProject/pkg/audit"
+)
+
+// dayCloseBody reports a day close
+type dayCloseBody struct {
+	From     time.Time        `json:"from"`
+	To       time.Time        `json:"to"`
+	Archived int              `json:"archived"`
+	Summary  analytics.Report `json:"summary"`
+}
+
+// registerDayCloseRoutes mounts the manual day close
+func (s *Server) registerDayCloseRoutes() {
+	s.handle("POST /v1/day/close", s.closeDayV1)
+}
+
+// closeDayV1 archives the day's finished orders and restarts token numbers
+func (s *Server) closeDayV1(w http.ResponseWriter, r *http.Request) {
+	if _, ok := input(w, r); !ok {
+		return
+	}
+	span := opSpan(r, "CloseDay")
+	day, err := s.om.CloseDay(r.Context())
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionDayClose, "", "", map[string]string{"archived": strconv.Itoa(len(day.Orders))})
+	writeJSON(w, http.StatusOK, dayCloseBody{
+		From:     day.From,
+		To:       day.To,
+		Archived: len(day.Orders),
+		Summary:  archive.Summary(*day),
+	})
+}
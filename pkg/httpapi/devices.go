@@ -0,0 +1,220 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/devices"
+	"awesomeProject/pkg/i18n"
+	"awesomeProject/pkg/queue"
+	"awesomeProject/pkg/validate"
+)
+
+// WithDevices serves the device registry reg: its devices fetch their
+// configuration with their own keys, and admins register and set them up
+func WithDevices(reg *devices.Registry) Option {
+	return func(s *Server) { s.devices = reg }
+}
+
+// registerDeviceRoutes mounts device configuration, and the registry for
+// admins, when there is a registry
+func (s *Server) registerDeviceRoutes() {
+	if s.devices == nil {
+		return
+	}
+	s.handle("GET /v1/devices/{id}/config", s.deviceConfigV1)
+	if s.cfg.Admin.Token == "" {
+		return
+	}
+	s.handle("GET /v1/admin/devices", s.admin(s.listDevicesV1))
+	s.handle("POST /v1/admin/devices", s.admin(s.registerDeviceV1))
+	s.handle("GET /v1/admin/devices/{id}", s.admin(s.getDeviceV1))
+	s.handle("PUT /v1/admin/devices/{id}/config", s.admin(s.configureDeviceV1))
+	s.handle("POST /v1/admin/devices/{id}/key", s.admin(s.rotateDeviceKeyV1))
+	s.handle("DELETE /v1/admin/devices/{id}", s.admin(s.removeDeviceV1))
+}
+
+// deviceConfig is what a device fetches as it starts
+type deviceConfig struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	Role string `json:"role"`
+	devices.Settings
+}
+
+// deviceWithKey is a device just given a key, which is shown only this once
+type deviceWithKey struct {
+	Device devices.Device `json:"device"`
+	Key    string         `json:"key"`
+}
+
+// deviceConfigV1 returns a device's configuration. The request must carry
+// that device's key in X-API-Key, or the admin token.
+func (s *Server) deviceConfigV1(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !validID(id) {
+		writeErrorf(w, r, http.StatusBadRequest, "invalid device id %q", id)
+		return
+	}
+	d, ok := s.devices.Authenticate(r.Header.Get("X-API-Key"))
+	switch {
+	case ok && d.ID != id:
+		writeError(w, r, http.StatusForbidden, "device key is for another device")
+		return
+	case !ok && !s.isAdmin(r):
+		writeError(w, r, http.StatusUnauthorized, "device key required")
+		return
+	case !ok:
+		var err error
+		if d, err = s.devices.Get(id); err != nil {
+			s.writeDeviceError(w, r, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, deviceConfig{ID: d.ID, Name: d.Name, Role: d.Role, Settings: d.Settings})
+}
+
+// isAdmin reports whether r carries the admin token
+func (s *Server) isAdmin(r *http.Request) bool {
+	return s.cfg.Admin.Token != "" &&
+		subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.cfg.Admin.Token)) == 1
+}
+
+// listDevicesV1 returns every registered device
+func (s *Server) listDevicesV1(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.devices.List())
+}
+
+// getDeviceV1 returns one device
+func (s *Server) getDeviceV1(w http.ResponseWriter, r *http.Request) {
+	d, err := s.devices.Get(r.PathValue("id"))
+	if err != nil {
+		s.writeDeviceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// registerDeviceV1 adds a device with the settings given, returning its key
+func (s *Server) registerDeviceV1(w http.ResponseWriter, r *http.Request) {
+	q, ok := input(w, r, "id", "name", "role", "station", "locale", "stations", "orderTypes", "statuses")
+	if !ok {
+		return
+	}
+	var errs validate.Errors
+	id := strings.TrimSpace(q.Get("id"))
+	switch {
+	case id == "":
+		errs.Add("id", "is required")
+	case !validID(id):
+		errs.Add("id", "must be at most %d letters, digits, dashes and underscores", maxIDLength)
+	}
+	role := q.Get("role")
+	if !slices.Contains(devices.Roles, role) {
+		errs.Add("role", "must be one of %s", strings.Join(devices.Roles, ", "))
+	}
+	settings := deviceSettings(q, &errs)
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	d, key, err := s.devices.Register(id, strings.TrimSpace(q.Get("name")), role, settings)
+	if err != nil {
+		s.writeDeviceError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionDevice, "", "", map[string]string{"device": d.ID, "change": "register", "role": d.Role})
+	w.Header().Set("Location", "/v1/admin/devices/"+url.PathEscape(d.ID))
+	writeJSON(w, http.StatusCreated, deviceWithKey{Device: d, Key: key})
+}
+
+// configureDeviceV1 replaces a device's settings
+func (s *Server) configureDeviceV1(w http.ResponseWriter, r *http.Request) {
+	q, ok := input(w, r, "station", "locale", "stations", "orderTypes", "statuses")
+	if !ok {
+		return
+	}
+	var errs validate.Errors
+	settings := deviceSettings(q, &errs)
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	d, err := s.devices.Configure(r.PathValue("id"), settings)
+	if err != nil {
+		s.writeDeviceError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionDevice, "", "", map[string]string{"device": d.ID, "change": "config"})
+	writeJSON(w, http.StatusOK, d)
+}
+
+// rotateDeviceKeyV1 gives a device a new key, for when one is lost or leaked
+func (s *Server) rotateDeviceKeyV1(w http.ResponseWriter, r *http.Request) {
+	d, key, err := s.devices.RotateKey(r.PathValue("id"))
+	if err != nil {
+		s.writeDeviceError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionDevice, "", "", map[string]string{"device": d.ID, "change": "key"})
+	writeJSON(w, http.StatusOK, deviceWithKey{Device: d, Key: key})
+}
+
+// removeDeviceV1 takes a device out of the registry
+func (s *Server) removeDeviceV1(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := s.devices.Remove(id); err != nil {
+		s.writeDeviceError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionDevice, "", "", map[string]string{"device": id, "change": "remove"})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deviceSettings reads a device's settings from q
+func deviceSettings(q url.Values, errs *validate.Errors) devices.Settings {
+	s := devices.Settings{
+		Station: strings.TrimSpace(q.Get("station")),
+		Locale:  strings.TrimSpace(q.Get("locale")),
+		Filters: devices.Filters{
+			Stations:   listParam(q, "stations"),
+			OrderTypes: listParam(q, "orderTypes"),
+			Statuses:   listParam(q, "statuses"),
+		},
+	}
+	for _, ot := range s.Filters.OrderTypes {
+		if !slices.Contains(queue.OrderTypes, ot) {
+			errs.Add("orderTypes", "must be one of %s", strings.Join(queue.OrderTypes, ", "))
+			break
+		}
+	}
+	for _, st := range s.Filters.Statuses {
+		if !slices.Contains(queue.Statuses, st) {
+			errs.Add("statuses", "must be one of %s", strings.Join(queue.Statuses, ", "))
+			break
+		}
+	}
+	if s.Locale != "" && !slices.Contains(i18n.Languages(), s.Locale) {
+		errs.Add("locale", "must be one of %s", strings.Join(i18n.Languages(), ", "))
+	}
+	return s
+}
+
+// writeDeviceError reports a registry error with the matching status
+func (s *Server) writeDeviceError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, devices.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, err.Error())
+	case errors.Is(err, devices.ErrExists):
+		writeError(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, devices.ErrRole), errors.Is(err, devices.ErrFilter):
+		writeError(w, r, http.StatusUnprocessableEntity, err.Error())
+	default:
+		s.writeManagerError(w, r, err)
+	}
+}
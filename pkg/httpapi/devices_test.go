@@ -0,0 +1,106 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/devices"
+	"awesomeProject/pkg/manager"
+)
+
+func TestDevices(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Token = "t0ken"
+	reg, err := devices.Open(devices.Config{Path: filepath.Join(t.TempDir(), "devices.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trail := openAudit(t)
+	s := New(manager.New(manager.DefaultConfig()), cfg, WithDevices(reg), WithAuditLog(trail))
+	send := func(method, target string, header ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	admin := []string{"Authorization", "Bearer t0ken"}
+
+	if rec := send(http.MethodPost, "/v1/admin/devices?id=kiosk-1&role=kiosk"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("register without the admin token = %d", rec.Code)
+	}
+	if rec := send(http.MethodPost, "/v1/admin/devices?id=kiosk-1&role=till&locale=fr", admin...); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("register with a bad role and locale = %d", rec.Code)
+	}
+	rec := send(http.MethodPost, "/v1/admin/devices?id=kiosk-1&name=Front+door&role=kiosk&station=bar&locale=es&orderTypes=takeaway", admin...)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/v1/admin/devices/kiosk-1" {
+		t.Fatalf("register = %d %s", rec.Code, rec.Body)
+	}
+	var created deviceWithKey
+	decode(t, rec, &created)
+	if created.Key == "" || created.Device.Settings.Locale != "es" {
+		t.Fatalf("registered %+v", created)
+	}
+	if rec := send(http.MethodPost, "/v1/admin/devices?id=kiosk-1&role=kiosk", admin...); rec.Code != http.StatusConflict {
+		t.Errorf("register twice = %d", rec.Code)
+	}
+	decode(t, send(http.MethodPost, "/v1/admin/devices?id=kds-1&role=kds", admin...), &deviceWithKey{})
+
+	if rec := send(http.MethodGet, "/v1/devices/kiosk-1/config"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("config without a key = %d", rec.Code)
+	}
+	if rec := send(http.MethodGet, "/v1/devices/kds-1/config", "X-API-Key", created.Key); rec.Code != http.StatusForbidden {
+		t.Errorf("config with another device's key = %d", rec.Code)
+	}
+	var conf deviceConfig
+	decode(t, send(http.MethodGet, "/v1/devices/kiosk-1/config", "X-API-Key", created.Key), &conf)
+	if conf.ID != "kiosk-1" || conf.Role != devices.RoleKiosk || conf.Station != "bar" || len(conf.Filters.OrderTypes) != 1 {
+		t.Fatalf("config = %+v", conf)
+	}
+
+	if rec := send(http.MethodPut, "/v1/admin/devices/kiosk-1/config?station=grill&statuses=preparing", admin...); rec.Code != http.StatusOK {
+		t.Fatalf("configure = %d %s", rec.Code, rec.Body)
+	}
+	conf = deviceConfig{}
+	decode(t, send(http.MethodGet, "/v1/devices/kiosk-1/config", admin...), &conf)
+	if conf.Station != "grill" || conf.Locale != "" || len(conf.Filters.Statuses) != 1 {
+		t.Errorf("config after configure = %+v", conf)
+	}
+
+	var rotated deviceWithKey
+	decode(t, send(http.MethodPost, "/v1/admin/devices/kiosk-1/key", admin...), &rotated)
+	if rec := send(http.MethodGet, "/v1/devices/kiosk-1/config", "X-API-Key", created.Key); rec.Code != http.StatusUnauthorized {
+		t.Errorf("config with the old key = %d", rec.Code)
+	}
+	var tea struct{ ID string }
+	decode(t, send(http.MethodPost, "/v1/orders?item=tea&priority=1", "X-API-Key", rotated.Key), &tea)
+	if rec := send(http.MethodPost, "/v1/orders/"+tea.ID+"/cancel", "X-API-Key", rotated.Key); rec.Code != http.StatusOK {
+		t.Fatalf("cancel from the kiosk = %d", rec.Code)
+	}
+	if entries, _ := trail.Query(audit.Filter{Action: audit.ActionCancel}); len(entries) != 1 || entries[0].Actor != "device:kiosk-1" {
+		t.Errorf("cancel audit entries = %+v", entries)
+	}
+	if rec := send(http.MethodDelete, "/v1/admin/devices/kds-1", admin...); rec.Code != http.StatusNoContent {
+		t.Errorf("remove = %d", rec.Code)
+	}
+	if rec := send(http.MethodGet, "/v1/admin/devices/kds-1", admin...); rec.Code != http.StatusNotFound {
+		t.Errorf("get removed device = %d", rec.Code)
+	}
+	var list []devices.Device
+	decode(t, send(http.MethodGet, "/v1/admin/devices", admin...), &list)
+	if len(list) != 1 || list[0].ID != "kiosk-1" || list[0].LastSeen == nil {
+		t.Errorf("list = %+v", list)
+	}
+
+	entries, _ := trail.Query(audit.Filter{Action: audit.ActionDevice})
+	if len(entries) != 5 {
+		t.Errorf("audit entries = %+v", entries)
+	}
+}
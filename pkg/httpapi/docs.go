@@ -0,0 +1,28 @@
+package httpapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// The spec is maintained by hand; update it alongside any route change.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+//go:embed docs.html
+var docsPage []byte
+
+// registerDocRoutes serves the OpenAPI spec and, when enabled, Swagger UI
+func (s *Server) registerDocRoutes() {
+	s.handle("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+	if s.cfg.SwaggerUI {
+		s.handle("GET /docs", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(docsPage)
+		})
+	}
+}
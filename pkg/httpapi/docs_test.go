@@ -0,0 +1,86 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/attach"
+	"awesomeProject/pkg/backup"
+	"awesomeProject/pkg/devices"
+	"awesomeProject/pkg/fairness"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/outbound"
+)
+
+// TestSpecListsErrorCodes keeps the codes clients may branch on documented
+func TestSpecListsErrorCodes(t *testing.T) {
+	var spec struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]struct {
+					Description string `json:"description"`
+				} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+	doc := spec.Components.Schemas["Error"].Properties["code"].Description
+	for _, code := range append(manager.Codes(), codeInvalidInput, codeMalformedInput, codeTimeout, codeCancelled) {
+		if !strings.Contains(doc, code) {
+			t.Errorf("code %s is not in the spec", code)
+		}
+	}
+}
+
+// TestSpecCoversRoutes keeps the hand-written spec in step with the router
+func TestSpecCoversRoutes(t *testing.T) {
+	// Optional routes are turned on so they are checked too
+	cfg := DefaultConfig()
+	cfg.Payments.WebhookSecret = "secret"
+	cfg.Admin.Token = "token"
+	cfg.Receipts.Secret = "secret"
+	cfg.Branding.Logo = filepath.Join(t.TempDir(), "logo.png")
+	om := manager.New(manager.DefaultConfig())
+	backups, err := backup.New(backup.Config{Dir: t.TempDir()}, om, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := attach.DefaultConfig()
+	files.Dir = t.TempDir()
+	store, err := attach.New(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg, err := devices.Open(devices.Config{Path: filepath.Join(t.TempDir(), "devices.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(om, cfg, WithAuditLog(openAudit(t)), WithBackups(backups), WithOutbound(outbound.New(outbound.DefaultConfig())),
+		WithAttachments(store), WithFairness(fairness.New(fairness.DefaultConfig())), WithDevices(reg))
+	rec := do(t, s, http.MethodGet, "/openapi.json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	decode(t, rec, &spec)
+
+	for _, pattern := range s.patterns {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			method, path = "GET", pattern
+		}
+		if path == "/docs" {
+			continue
+		}
+		if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %q missing from openapi.json", pattern)
+		}
+	}
+}
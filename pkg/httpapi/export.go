@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"awesomeProject/pkg/analytics"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// registerExportRoutes mounts the spreadsheet export
+func (s *Server) registerExportRoutes() {
+	s.handle("GET /v1/export", s.kitchen(s.exportHandler))
+}
+
+// exportHandler streams the orders placed between from and to (as for
+// /v1/stats, defaulting to today) as CSV, optionally limited to one status
+// and one channel
+func (s *Server) exportHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, to, err := parseDateRange(q, s.om.Now())
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	f := manager.OrderFilter{From: from, To: to, Sort: "id"}
+	if f.Status = q.Get("status"); f.Status != "" && !queue.ValidStatus(f.Status) {
+		writeErrorf(w, r, http.StatusBadRequest, "invalid status %q", f.Status)
+		return
+	}
+	if f.Channel = q.Get("channel"); f.Channel != "" && !queue.ValidChannel(f.Channel) {
+		writeErrorf(w, r, http.StatusBadRequest, "invalid channel %q", f.Channel)
+		return
+	}
+
+	span := opSpan(r, "QueryOrders")
+	orders, _, err := s.om.QueryOrders(r.Context(), f)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders-%s.csv"`, from.Format(time.DateOnly)))
+	if err := analytics.WriteOrdersCSV(r.Context(), w, orders); err != nil {
+		log.Printf("write export: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"awesomeProject/pkg/fault"
+)
+
+// maxFaultsBody bounds the fault settings accepted
+const maxFaultsBody = 4 << 10
+
+// slowed holds requests as the injected faults say, before h runs
+func slowed(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fault.Slow(r.Context())
+		h.ServeHTTP(w, r)
+	})
+}
+
+// faultsV1 returns the faults being injected
+func (s *Server) faultsV1(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, fault.Current())
+}
+
+// setFaultsV1 replaces the faults injected with those in the body
+func (s *Server) setFaultsV1(w http.ResponseWriter, r *http.Request) {
+	var set fault.Settings
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFaultsBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&set); err != nil {
+		writeErrorf(w, r, http.StatusBadRequest, "invalid fault settings: %s", strings.TrimPrefix(err.Error(), "json: "))
+		return
+	}
+	if err := fault.Set(set); err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, fault.Current())
+}
+
+// clearFaultsV1 stops injecting faults
+func (s *Server) clearFaultsV1(w http.ResponseWriter, r *http.Request) {
+	fault.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,70 @@
+//go:build faults
+
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/fault"
+	"awesomeProject/pkg/manager"
+)
+
+func TestFaultInjection(t *testing.T) {
+	defer fault.Reset()
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Token = "t0ken"
+	om := manager.New(manager.DefaultConfig())
+	var events int
+	om.Subscribe(func(manager.Event) { events++ })
+	s := New(om, cfg)
+	admin := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/admin/faults", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer t0ken")
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := admin(http.MethodPut, `{"writeFailureRate":3}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("bad rate = %d", rec.Code)
+	}
+	if rec := admin(http.MethodPut, `{"writeFailureRate":1,"stores":["queue"],"dropRate":1}`); rec.Code != http.StatusOK {
+		t.Fatalf("set = %d %s", rec.Code, rec.Body)
+	}
+	if rec := doJSON(t, s, http.MethodPost, "/v1/orders", `{"item":"soup","priority":1}`); rec.Code != http.StatusInternalServerError {
+		t.Errorf("placing with the queue failing = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodGet, "/v1/orders"); rec.Code != http.StatusOK {
+		t.Errorf("listing with the queue failing = %d", rec.Code)
+	}
+
+	// Events are dropped while the queue works again
+	if rec := admin(http.MethodPut, `{"dropRate":1}`); rec.Code != http.StatusOK {
+		t.Fatalf("set = %d %s", rec.Code, rec.Body)
+	}
+	if rec := doJSON(t, s, http.MethodPost, "/v1/orders", `{"item":"soup","priority":1}`); rec.Code != http.StatusCreated || events != 0 {
+		t.Errorf("placing with events dropped = %d, %d events", rec.Code, events)
+	}
+
+	// Slow handlers run into their route's timeout
+	if rec := admin(http.MethodPut, `{"slowRate":1,"delay":"1h"}`); rec.Code != http.StatusOK {
+		t.Fatalf("set = %d %s", rec.Code, rec.Body)
+	}
+	cfg.Timeouts = TimeoutConfig{Default: config.Duration(20 * time.Millisecond)}
+	if rec := do(t, New(om, cfg), http.MethodGet, "/v1/orders"); !strings.Contains(rec.Body.String(), `"code":"timeout"`) {
+		t.Errorf("slowed = %d %s", rec.Code, rec.Body)
+	}
+	if rec := admin(http.MethodDelete, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("clear = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, "/v1/orders"); rec.Code != http.StatusOK {
+		t.Errorf("after clearing = %d", rec.Code)
+	}
+}
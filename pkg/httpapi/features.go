@@ -0,0 +1,89 @@
+package httpapi
+
+import (
+	"cmp"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/features"
+	"awesomeProject/pkg/validate"
+)
+
+// WithFeatures turns the optional behaviors off while f has their flags
+// off, and lets admins set the flags
+func WithFeatures(f *features.Set) Option {
+	return func(s *Server) { s.features = f }
+}
+
+// registerFeatureRoutes mounts the flags for admins, when there are flags
+func (s *Server) registerFeatureRoutes() {
+	if s.features == nil || s.cfg.Admin.Token == "" {
+		return
+	}
+	s.handle("GET /v1/admin/features", s.admin(s.listFeaturesV1))
+	s.handle("PUT /v1/admin/features/{name}", s.admin(s.setFeatureV1))
+	s.handle("DELETE /v1/admin/features/{name}", s.admin(s.resetFeatureV1))
+}
+
+// feature answers not found instead of calling h while the flag name is off
+func (s *Server) feature(name string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.features.Enabled(name) {
+			writeErrorf(w, r, http.StatusNotFound, "%s is turned off", name)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// listFeaturesV1 returns every flag
+func (s *Server) listFeaturesV1(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.features.List())
+}
+
+// setFeatureV1 turns a flag on or off until it is reset
+func (s *Server) setFeatureV1(w http.ResponseWriter, r *http.Request) {
+	q, ok := input(w, r, "enabled")
+	if !ok {
+		return
+	}
+	var errs validate.Errors
+	if !q.Has("enabled") {
+		errs.Add("enabled", "is required")
+	}
+	on := boolParam(q, "enabled", &errs)
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	by := cmp.Or(truncate(strings.TrimSpace(r.Header.Get(StaffHeader)), maxActor), "admin")
+	f, err := s.features.Set(r.PathValue("name"), on, by)
+	if err != nil {
+		s.writeFeatureError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionFeature, "", by, map[string]string{"feature": f.Name, "enabled": strconv.FormatBool(f.Enabled)})
+	writeJSON(w, http.StatusOK, f)
+}
+
+// resetFeatureV1 puts a flag back as configured
+func (s *Server) resetFeatureV1(w http.ResponseWriter, r *http.Request) {
+	f, err := s.features.Reset(r.PathValue("name"))
+	if err != nil {
+		s.writeFeatureError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionFeature, "", "admin", map[string]string{"feature": f.Name, "enabled": strconv.FormatBool(f.Enabled), "change": "reset"})
+	writeJSON(w, http.StatusOK, f)
+}
+
+func (s *Server) writeFeatureError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, features.ErrUnknown) {
+		writeErrorf(w, r, http.StatusNotFound, "unknown feature flag %q", r.PathValue("name"))
+		return
+	}
+	s.writeManagerError(w, r, err)
+}
@@ -0,0 +1,106 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/features"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Token = "t0ken"
+	cfg.KDS.Enabled = true
+	flags, err := features.Open(features.Config{Flags: map[string]bool{features.Webhooks: false}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trail := openAudit(t)
+	s := New(manager.New(manager.DefaultConfig()), cfg, WithFeatures(flags), WithAuditLog(trail))
+	send := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	admin := []string{"Authorization", "Bearer t0ken", StaffHeader, "ana"}
+
+	if rec := send(http.MethodGet, "/v1/admin/features", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("list without the admin token = %d", rec.Code)
+	}
+	var list []features.Flag
+	decode(t, send(http.MethodGet, "/v1/admin/features", "", admin...), &list)
+	if len(list) != len(features.Names) {
+		t.Fatalf("flags = %+v", list)
+	}
+	for _, f := range list {
+		if f.Enabled != (f.Name != features.Webhooks) || f.Enabled != f.Default {
+			t.Errorf("flag as configured = %+v", f)
+		}
+	}
+
+	if rec := send(http.MethodPut, "/v1/admin/features/kds", `{}`, admin...); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("set without enabled = %d", rec.Code)
+	}
+	if rec := send(http.MethodPut, "/v1/admin/features/fax", `{"enabled":true}`, admin...); rec.Code != http.StatusNotFound {
+		t.Errorf("set an unknown flag = %d", rec.Code)
+	}
+	rec := send(http.MethodPut, "/v1/admin/features/kds", `{"enabled":false}`, admin...)
+	var f features.Flag
+	decode(t, rec, &f)
+	if f.Enabled || !f.Default || f.ChangedBy != "ana" || f.ChangedAt == nil {
+		t.Fatalf("set kds = %+v", f)
+	}
+	for _, target := range []string{"/v1/kds", "/kds"} {
+		if rec := send(http.MethodGet, target, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s with kds off = %d", target, rec.Code)
+		}
+	}
+
+	var reset features.Flag
+	decode(t, send(http.MethodDelete, "/v1/admin/features/kds", "", admin...), &reset)
+	if !reset.Enabled || reset.ChangedAt != nil {
+		t.Errorf("reset kds = %+v", reset)
+	}
+	if rec := send(http.MethodGet, "/v1/kds", ""); rec.Code != http.StatusOK {
+		t.Errorf("/v1/kds after reset = %d", rec.Code)
+	}
+
+	entries, _ := trail.Query(audit.Filter{Action: audit.ActionFeature})
+	if len(entries) != 2 || entries[0].Actor != "ana" || entries[1].Detail["feature"] != features.KDS {
+		t.Errorf("audit = %+v", entries)
+	}
+}
+
+func TestKDSAgingFlag(t *testing.T) {
+	flags, err := features.Open(features.Config{Flags: map[string]bool{features.Aging: false}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(manager.New(manager.DefaultConfig()), DefaultConfig(), WithFeatures(flags))
+	now := time.Now()
+	late := &queue.Token{ID: "1", Timestamp: now.Add(-time.Hour), Status: queue.StatusPreparing}
+	if o := s.kdsOrder(late, now); o.Age != ageFresh || o.WaitingSeconds != 3600 {
+		t.Errorf("order with aging off = %+v", o)
+	}
+	if _, err := flags.Set(features.Aging, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if o := s.kdsOrder(late, now); o.Age != ageLate {
+		t.Errorf("order with aging on = %+v", o)
+	}
+}
@@ -0,0 +1,120 @@
+package httpapi
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/validate"
+)
+
+// registerFixRoutes mounts the admin escape hatches for repairing state
+// gone wrong, when there is an admin token: setting the next token number,
+// forcing an order's status and rebuilding the waiting queue
+func (s *Server) registerFixRoutes() {
+	if s.cfg.Admin.Token == "" {
+		return
+	}
+	s.handle("GET /v1/admin/number", s.admin(s.nextNumberV1))
+	s.handle("PUT /v1/admin/number", s.admin(s.setNextNumberV1))
+	s.handle("POST /v1/admin/orders/{id}/status", s.admin(s.forceStatusV1))
+	s.handle("POST /v1/admin/queue/rebuild", s.admin(s.rebuildQueueV1))
+}
+
+// nextNumber is the JSON payload for the next daily token number, with the
+// one it replaced when set
+type nextNumber struct {
+	Next     int `json:"next"`
+	Previous int `json:"previous,omitempty"`
+}
+
+// nextNumberV1 returns the daily token number the next order is given
+func (s *Server) nextNumberV1(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, nextNumber{Next: s.om.NextNumber()})
+}
+
+// setNextNumberV1 sets the daily token number the next order is given
+func (s *Server) setNextNumberV1(w http.ResponseWriter, r *http.Request) {
+	q, ok := input(w, r, "next")
+	if !ok {
+		return
+	}
+	var errs validate.Errors
+	next := intParam(q, "next", &errs)
+	switch {
+	case next == nil && len(errs) == 0:
+		errs.Add("next", "is required")
+	case next != nil && *next < 1:
+		errs.Add("next", "must be at least 1")
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	was := s.om.SetNextNumber(*next)
+	s.record(r, audit.ActionSetNumber, "", "", map[string]string{"next": strconv.Itoa(*next), "previous": strconv.Itoa(was)})
+	writeJSON(w, http.StatusOK, nextNumber{Next: *next, Previous: was})
+}
+
+// forceStatusV1 moves an order to the status given, outside the usual
+// transitions, for the reason given
+func (s *Server) forceStatusV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	q, ok := input(w, r, "status", "reason")
+	if !ok {
+		return
+	}
+	var errs validate.Errors
+	status := q.Get("status")
+	if !slices.Contains(manager.ForcibleStatuses, status) {
+		errs.Add("status", "must be one of %s", strings.Join(manager.ForcibleStatuses, ", "))
+	}
+	reason := strings.TrimSpace(q.Get("reason"))
+	if reason == "" {
+		errs.Add("reason", "is required")
+	}
+	if limit := s.cfg.Validation.MaxNotes; utf8.RuneCountInString(reason) > limit {
+		errs.Add("reason", "must be at most %d characters", limit)
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	span := opSpan(r, "ForceStatus")
+	token, from, err := s.om.ForceStatus(r.Context(), id, status)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionForceStatus, token.ID, "", map[string]string{"from": from, "to": status, "reason": reason})
+	setETag(w, token)
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+// rebuildQueueV1 rebuilds the waiting queue from the orders the manager
+// holds
+func (s *Server) rebuildQueueV1(w http.ResponseWriter, r *http.Request) {
+	if _, ok := input(w, r); !ok {
+		return
+	}
+	span := opSpan(r, "RebuildQueue")
+	rb, err := s.om.RebuildQueue(r.Context())
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionRebuildQueue, "", "", map[string]string{
+		"queued": strconv.Itoa(rb.Queued), "restored": strings.Join(rb.Restored, ","), "dropped": strings.Join(rb.Dropped, ","),
+	})
+	writeJSON(w, http.StatusOK, rb)
+}
@@ -0,0 +1,76 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestFixState(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Token = "t0ken"
+	trail := openAudit(t)
+	om := manager.New(manager.DefaultConfig())
+	s := New(om, cfg, WithAuditLog(trail))
+	send := func(method, target string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer t0ken")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	token, err := om.PlaceOrder(ctx, manager.NewOrder{Item: "soup", Priority: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := do(t, s, http.MethodPut, "/v1/admin/number?next=40"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("set the number without the admin token = %d", rec.Code)
+	}
+	if rec := send(http.MethodPut, "/v1/admin/number?next=0"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("set the number to 0 = %d", rec.Code)
+	}
+	var num nextNumber
+	decode(t, send(http.MethodPut, "/v1/admin/number?next=40"), &num)
+	if num.Next != 40 || num.Previous != 2 || om.NextNumber() != 40 {
+		t.Errorf("set the number = %+v", num)
+	}
+
+	target := "/v1/admin/orders/" + token.ID + "/status"
+	if rec := send(http.MethodPost, target+"?status=picked_up"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("force without a reason = %d", rec.Code)
+	}
+	if rec := send(http.MethodPost, target+"?status=voided&reason=x"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("force to voided = %d", rec.Code)
+	}
+	if rec := send(http.MethodPost, target+"?status=preparing&reason=x"); rec.Code != http.StatusConflict {
+		t.Errorf("force to the same status = %d", rec.Code)
+	}
+	rec := send(http.MethodPost, target+"?status=picked_up&reason=collected+at+the+bar")
+	var got queue.Token
+	decode(t, rec, &got)
+	if rec.Code != http.StatusOK || got.Status != queue.StatusPickedUp {
+		t.Fatalf("force = %d %s", rec.Code, rec.Body)
+	}
+
+	var rb manager.QueueRebuild
+	decode(t, send(http.MethodPost, "/v1/admin/queue/rebuild"), &rb)
+	if rb.Queued != 0 || len(rb.Restored) != 0 || len(rb.Dropped) != 0 {
+		t.Errorf("rebuild = %+v", rb)
+	}
+
+	entries, _ := trail.Query(audit.Filter{})
+	if len(entries) != 3 || entries[2].Action != audit.ActionSetNumber || entries[2].Detail["previous"] != "2" ||
+		entries[1].Action != audit.ActionForceStatus || entries[1].Detail["from"] != queue.StatusPreparing || entries[1].Detail["reason"] != "collected at the bar" ||
+		entries[0].Action != audit.ActionRebuildQueue {
+		t.Errorf("audit entries = %+v", entries)
+	}
+}
@@ -0,0 +1,23 @@
+package httpapi
+
+import "net/http"
+
+// registerForecastRoutes mounts the stations' outlook, for the managers'
+// planning screens
+func (s *Server) registerForecastRoutes() {
+	s.handle("GET /v1/forecast", s.forecastV1)
+}
+
+// forecastV1 reports when each station should clear its backlog and when it
+// will need more cooks at the current pace
+func (s *Server) forecastV1(w http.ResponseWriter, r *http.Request) {
+	span := opSpan(r, "Forecast")
+	f, err := s.om.Forecast(r.Context())
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, f)
+}
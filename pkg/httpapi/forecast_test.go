@@ -0,0 +1,29 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"awesomeProject/pkg/manager"
+)
+
+func TestForecast(t *testing.T) {
+	cfg := manager.DefaultConfig()
+	cfg.StationCooks = map[string]int{"fryer": 2}
+	om := manager.New(cfg)
+	for range 3 {
+		if _, err := om.PlaceOrder(context.Background(), manager.NewOrder{Item: "chips", Station: "fryer"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec := do(t, New(om, DefaultConfig()), http.MethodGet, "/v1/forecast")
+	var f manager.Forecast
+	decode(t, rec, &f)
+	if rec.Code != http.StatusOK || len(f.Stations) != 2 {
+		t.Fatalf("forecast = %d %s", rec.Code, rec.Body)
+	}
+	if fryer := f.Stations[1]; fryer.Station != "fryer" || fryer.Waiting != 3 || fryer.Cooks != 2 || fryer.ClearAt == nil || fryer.ArrivalsPerHour != 3 {
+		t.Errorf("fryer = %+v", fryer)
+	}
+}
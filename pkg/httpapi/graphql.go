@@ -0,0 +1,744 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/analytics"
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/graphql"
+	"awesomeProject/pkg/i18n"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+	"awesomeProject/pkg/validate"
+)
+
+// The GraphQL API at /graphql serves the orders, stats and order events of
+// the v1 API for clients that would rather pick the fields they get. Its
+// resolvers make the same manager calls as the v1 handlers, with the same
+// validation and audit records, and its schema grows the way v1 does:
+// fields are added, never changed or removed. The schema is published as
+// SDL at /graphql/schema.
+
+// registerGraphQLRoutes mounts the GraphQL API. The routes are registered
+// as streams so subscriptions stay open; queries and mutations are given
+// the endpoint's timeout as they are run.
+func (s *Server) registerGraphQLRoutes() {
+	schema, err := graphql.NewSchema(s.graphQLQuery(), s.graphQLMutation(), s.graphQLSubscription())
+	if err != nil {
+		panic(err) // The schema is fixed; TestGraphQL builds it
+	}
+	s.graphql = schema
+	s.handleStream("GET /graphql", s.graphQLHandler)
+	s.handleStream("POST /graphql", s.graphQLHandler)
+	s.handle("GET /graphql/schema", s.graphQLSchema)
+}
+
+// graphQLRequestKey carries the HTTP request to the resolvers, for its
+// spans and audit records
+type graphQLRequestKey struct{}
+
+func graphQLRequestOf(ctx context.Context) *http.Request {
+	return ctx.Value(graphQLRequestKey{}).(*http.Request)
+}
+
+// graphQLHandler runs a GraphQL request: a JSON body of query,
+// operationName and variables for POST, or those as parameters for GET,
+// which cannot run mutations. Subscriptions are answered as server-sent
+// events, a next event for each order event and complete once the stream
+// ends, as the GraphQL over SSE protocol sends them.
+func (s *Server) graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := graphQLInput(w, r)
+	if !ok {
+		return
+	}
+	doc, err := graphql.Parse(req.Query)
+	var kind string
+	if err == nil {
+		kind, err = doc.Operation(req.OperationName)
+	}
+	if err != nil {
+		var gerr *graphql.Error
+		errors.As(err, &gerr)
+		writeJSON(w, http.StatusBadRequest, &graphql.Response{Errors: []*graphql.Error{gerr}})
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), graphQLRequestKey{}, r))
+	if s.cfg.Roles.Redact {
+		w.Header().Add("Vary", "Authorization, X-API-Key")
+	}
+	switch {
+	case kind == graphql.Subscription:
+		s.graphQLSubscribe(w, r, doc, req)
+		return
+	case kind == graphql.Mutation && r.Method != http.MethodPost:
+		w.Header().Set("Allow", http.MethodPost)
+		writeGraphQLError(w, r, http.StatusMethodNotAllowed, "mutations must be sent with POST")
+		return
+	}
+
+	ctx := r.Context()
+	if timeout := s.cfg.Timeouts.timeoutFor(r.Method + " /graphql"); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	resp := s.graphql.Execute(ctx, doc, req.OperationName, req.Variables)
+	status := http.StatusOK
+	if resp.Data == nil {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, resp)
+}
+
+// graphQLInput reads the request from the body of a POST or the
+// parameters of a GET
+func graphQLInput(w http.ResponseWriter, r *http.Request) (graphql.Request, bool) {
+	var req graphql.Request
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			dec := json.NewDecoder(strings.NewReader(v))
+			dec.UseNumber()
+			if err := dec.Decode(&req.Variables); err != nil {
+				writeGraphQLError(w, r, http.StatusBadRequest, "invalid variables: %v", err)
+				return req, false
+			}
+		}
+	} else {
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+			writeGraphQLError(w, r, http.StatusUnsupportedMediaType, "send GraphQL requests as application/json")
+			return req, false
+		}
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxInputBody))
+		dec.UseNumber()
+		if err := dec.Decode(&req); err != nil {
+			var tooBig *http.MaxBytesError
+			if errors.As(err, &tooBig) {
+				writeGraphQLError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+				return req, false
+			}
+			writeGraphQLError(w, r, http.StatusBadRequest, "invalid JSON body: %v", err)
+			return req, false
+		}
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeGraphQLError(w, r, http.StatusBadRequest, "query is required")
+		return req, false
+	}
+	return req, true
+}
+
+// writeGraphQLError reports a request that could not be run, translated
+func writeGraphQLError(w http.ResponseWriter, r *http.Request, status int, format string, args ...any) {
+	msg := i18n.Sprintf(language(w, r), format, args...)
+	writeJSON(w, status, &graphql.Response{Errors: []*graphql.Error{{Message: msg, Extensions: map[string]any{"code": statusCode(status)}}}})
+}
+
+// graphQLSubscribe streams the events of a subscription
+func (s *Server) graphQLSubscribe(w http.ResponseWriter, r *http.Request, doc *graphql.Document, req graphql.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeGraphQLError(w, r, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		writeGraphQLError(w, r, http.StatusNotAcceptable, "subscriptions are sent as server-sent events, accept text/event-stream")
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	events, failed := s.graphql.Subscribe(ctx, doc, req.OperationName, req.Variables)
+	if failed != nil {
+		writeJSON(w, http.StatusBadRequest, failed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case resp, ok := <-events:
+			if !ok {
+				fmt.Fprint(w, "event: complete\ndata:\n\n")
+				flusher.Flush()
+				return
+			}
+			data, err := json.Marshal(resp)
+			if err != nil {
+				data, _ = json.Marshal(&graphql.Response{Errors: []*graphql.Error{{Message: err.Error()}}})
+			}
+			fmt.Fprintf(w, "event: next\ndata: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
+
+// graphQLSchema serves the schema as SDL
+func (s *Server) graphQLSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, s.graphql.SDL())
+}
+
+// graphQLValues turns resolver arguments into the parameters the v1
+// handlers read, so both are checked the same way
+func graphQLValues(args map[string]any) url.Values {
+	q := make(url.Values, len(args))
+	for name, v := range args {
+		switch v := v.(type) {
+		case nil:
+		case []any:
+			q[name] = []string{""} // Present but empty, as flags= is
+			if len(v) > 0 {
+				q[name] = make([]string, len(v))
+				for i, item := range v {
+					q[name][i] = fmt.Sprint(item)
+				}
+			}
+		default:
+			q.Set(name, fmt.Sprint(v))
+		}
+	}
+	return q
+}
+
+// graphQLError reports a manager error with its v1 status and code as
+// extensions
+func graphQLError(ctx context.Context, err error) error {
+	return &graphql.Error{
+		Message:    i18n.Error(i18n.FromContext(ctx), err),
+		Extensions: map[string]any{"status": managerStatus(err), "code": managerCode(err)},
+	}
+}
+
+// graphQLBadRequest reports an argument that could not be used
+func graphQLBadRequest(ctx context.Context, err error) error {
+	return &graphql.Error{
+		Message:    i18n.Error(i18n.FromContext(ctx), err),
+		Extensions: map[string]any{"status": http.StatusBadRequest, "code": statusCode(http.StatusBadRequest)},
+	}
+}
+
+// graphQLRateLimited reports a mutation over the rate limit, with the
+// seconds to wait as extensions.retryAfter
+func graphQLRateLimited(ctx context.Context, wait time.Duration) error {
+	return &graphql.Error{
+		Message: i18n.T(i18n.FromContext(ctx), "rate limit exceeded"),
+		Extensions: map[string]any{
+			"status": http.StatusTooManyRequests, "code": statusCode(http.StatusTooManyRequests),
+			"retryAfter": int(math.Ceil(wait.Seconds())),
+		},
+	}
+}
+
+// graphQLKitchen refuses public callers the order queries, the subscription
+// and the prepare and cancel mutations while redaction is on, as only the
+// REST views have a public form. addOrder answers whoever placed the order.
+func (s *Server) graphQLKitchen(ctx context.Context) error {
+	if !s.cfg.Roles.Redact || s.role(graphQLRequestOf(ctx)) != rolePublic {
+		return nil
+	}
+	return &graphql.Error{
+		Message:    i18n.T(i18n.FromContext(ctx), "kitchen credentials required"),
+		Extensions: map[string]any{"status": http.StatusUnauthorized, "code": statusCode(http.StatusUnauthorized)},
+	}
+}
+
+// graphQLValidationError reports field errors as writeValidationError does
+func graphQLValidationError(ctx context.Context, errs validate.Errors) error {
+	status, code := http.StatusUnprocessableEntity, codeInvalidInput
+	if errs.HasMalformed() {
+		status, code = http.StatusBadRequest, codeMalformedInput
+	}
+	lang := i18n.FromContext(ctx)
+	tr := func(format string) string { return i18n.T(lang, format) }
+	return &graphql.Error{
+		Message:    i18n.T(lang, "invalid request"),
+		Extensions: map[string]any{"status": status, "code": code, "fields": errs.Translate(tr)},
+	}
+}
+
+// Types of the schema
+
+var (
+	gqlDateTime = &graphql.Scalar{
+		Name:        "DateTime",
+		Description: "An RFC 3339 time",
+		Serialize: func(v any) (any, bool) {
+			switch v := v.(type) {
+			case string:
+				return v, true
+			case time.Time:
+				return v.Format(time.RFC3339Nano), true
+			}
+			return nil, false
+		},
+		Parse: func(v any) (any, bool) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, false
+			}
+			_, err := time.Parse(time.RFC3339, s)
+			return s, err == nil
+		},
+	}
+	gqlOrderStatus = &graphql.Enum{Name: "OrderStatus", Values: queue.Statuses}
+	gqlOrderType   = &graphql.Enum{Name: "OrderType", Values: queue.OrderTypes}
+	gqlPayment     = &graphql.Enum{Name: "PaymentStatus", Values: queue.PaymentStatuses}
+	gqlChannel     = &graphql.Enum{Name: "Channel", Values: queue.Channels}
+
+	gqlEdit = &graphql.Object{Name: "Edit", Description: "A change made to an order after it was placed", Fields: []*graphql.Field{
+		{Name: "field", Type: nonNull(graphql.String)},
+		{Name: "from", Type: nonNull(graphql.String)},
+		{Name: "to", Type: nonNull(graphql.String)},
+		{Name: "at", Type: nonNull(gqlDateTime)},
+		{Name: "by", Type: graphql.String, Description: "Who made the change, when known"},
+	}}
+	gqlSLA = &graphql.Object{Name: "SLA", Description: "The wait-time target an order was placed under", Fields: []*graphql.Field{
+		{Name: "priority", Type: nonNull(graphql.Int)},
+		{Name: "dueAt", Type: nonNull(gqlDateTime)},
+		{Name: "breachedAt", Type: gqlDateTime, Description: "When the order was found not prepared in time"},
+	}}
+	gqlTotal = &graphql.Object{Name: "Total", Description: "What an order costs, in minor units of its currency such as cents", Fields: []*graphql.Field{
+		{Name: "currency", Type: nonNull(graphql.String), Description: "ISO 4217 code"},
+		{Name: "subtotal", Type: nonNull(graphql.Int), Description: "Item price times quantity"},
+		{Name: "tax", Type: nonNull(graphql.Int)},
+		{Name: "total", Type: nonNull(graphql.Int)},
+		{Name: "taxRate", Type: nonNull(graphql.Float), Description: "Percent"},
+		{Name: "taxIncluded", Type: nonNull(graphql.Boolean), Resolve: isSet("taxIncluded")},
+	}}
+	gqlOrder = &graphql.Object{Name: "Order", Description: "An order, as GET /v1/orders/{id} returns it", Fields: []*graphql.Field{
+		{Name: "id", Type: nonNull(graphql.ID)},
+		{Name: "number", Type: graphql.Int, Description: "Daily token number called to the customer"},
+		{Name: "item", Type: nonNull(graphql.String)},
+		{Name: "quantity", Type: nonNull(graphql.Int)},
+		{Name: "priority", Type: nonNull(graphql.Int), Description: "Lower is prepared first; -1 marks a rushed order"},
+		{Name: "status", Type: nonNull(gqlOrderStatus)},
+		{Name: "timestamp", Type: nonNull(gqlDateTime)},
+		{Name: "station", Type: graphql.String},
+		{Name: "orderType", Type: gqlOrderType},
+		{Name: "channel", Type: gqlChannel, Description: "Where the order was placed, when known"},
+		{Name: "table", Type: graphql.Int},
+		{Name: "notes", Type: graphql.String},
+		{Name: "flags", Type: listOf(graphql.String), Resolve: orEmpty("flags")},
+		{Name: "edits", Type: listOf(gqlEdit), Resolve: orEmpty("edits")},
+		{Name: "group", Type: graphql.String},
+		{Name: "course", Type: graphql.Int},
+		{Name: "rushedBy", Type: graphql.String},
+		{Name: "remakeOf", Type: graphql.ID},
+		{Name: "remakeReason", Type: graphql.String},
+		{Name: "remakes", Type: listOf(graphql.ID), Resolve: orEmpty("remakes")},
+		{Name: "unavailable", Type: nonNull(graphql.Boolean), Resolve: isSet("unavailable")},
+		{Name: "sla", Type: gqlSLA},
+		{Name: "promisedBy", Type: gqlDateTime},
+		{Name: "atRisk", Type: nonNull(graphql.Boolean), Resolve: isSet("atRisk")},
+		{Name: "total", Type: gqlTotal},
+		{Name: "estimatedReadyAt", Type: gqlDateTime},
+		{Name: "position", Type: graphql.Int, Description: "Place in the station's queue while waiting, 1 for the next one up"},
+		{Name: "ahead", Type: graphql.Int},
+		{Name: "version", Type: graphql.String, Description: "Sent back with cancel to cancel only this version"},
+		{Name: "readyAt", Type: gqlDateTime},
+		{Name: "releaseAt", Type: gqlDateTime},
+		{Name: "rushedAt", Type: gqlDateTime},
+		{Name: "claimedAt", Type: gqlDateTime},
+		{Name: "preparedAt", Type: gqlDateTime},
+		{Name: "pickedUpAt", Type: gqlDateTime},
+		{Name: "expiredAt", Type: gqlDateTime},
+		{Name: "cancelledAt", Type: gqlDateTime},
+		{Name: "payment", Type: nonNull(gqlPayment)},
+		{Name: "paidAt", Type: gqlDateTime},
+		{Name: "refundedAt", Type: gqlDateTime},
+		{Name: "platform", Type: graphql.String},
+		{Name: "externalId", Type: graphql.String},
+	}}
+	gqlOrderPage = &graphql.Object{Name: "OrderPage", Description: "A page of matching orders", Fields: []*graphql.Field{
+		{Name: "orders", Type: listOf(gqlOrder)},
+		{Name: "total", Type: nonNull(graphql.Int), Description: "Orders matching, on every page"},
+		{Name: "limit", Type: nonNull(graphql.Int)},
+		{Name: "offset", Type: nonNull(graphql.Int)},
+	}}
+	gqlEvent = &graphql.Object{Name: "OrderEvent", Description: "A change to an order", Fields: []*graphql.Field{
+		{Name: "type", Type: nonNull(graphql.String), Description: "As the event names of GET /v1/events"},
+		{Name: "at", Type: nonNull(gqlDateTime)},
+		{Name: "order", Type: nonNull(gqlOrder), Description: "The order after the change", Resolve: func(p graphql.Params) (any, error) {
+			return p.Source.(map[string]any)["token"], nil
+		}},
+		{Name: "group", Type: listOf(gqlOrder), Description: "Every order of the group, for group_ready", Resolve: orEmpty("group")},
+	}}
+	gqlBucket = &graphql.Object{Name: "Bucket", Fields: []*graphql.Field{
+		{Name: "key", Type: nonNull(graphql.String), Description: "00 to 23 for hours, YYYY-MM-DD for days"},
+		{Name: "orders", Type: nonNull(graphql.Int)},
+	}}
+	gqlItemCount = &graphql.Object{Name: "ItemCount", Fields: []*graphql.Field{
+		{Name: "item", Type: nonNull(graphql.String)},
+		{Name: "orders", Type: nonNull(graphql.Int)},
+		{Name: "quantity", Type: nonNull(graphql.Int)},
+		{Name: "remakes", Type: nonNull(graphql.Int)},
+	}}
+	gqlChannelCount = &graphql.Object{Name: "ChannelCount", Fields: []*graphql.Field{
+		{Name: "channel", Type: nonNull(graphql.String), Description: "Empty for orders placed without one"},
+		{Name: "orders", Type: nonNull(graphql.Int)},
+		{Name: "share", Type: nonNull(graphql.Float), Description: "Percentage of all orders"},
+		{Name: "cancelled", Type: nonNull(graphql.Int)},
+		{Name: "avgPrepSeconds", Type: nonNull(graphql.Float)},
+	}}
+	gqlSLAClass = &graphql.Object{Name: "SLAClass", Fields: []*graphql.Field{
+		{Name: "priority", Type: nonNull(graphql.Int)},
+		{Name: "orders", Type: nonNull(graphql.Int)},
+		{Name: "met", Type: nonNull(graphql.Int)},
+		{Name: "breached", Type: nonNull(graphql.Int)},
+		{Name: "attainment", Type: nonNull(graphql.Float), Description: "Percentage met"},
+	}}
+	gqlReasonCount = &graphql.Object{Name: "ReasonCount", Fields: []*graphql.Field{
+		{Name: "reason", Type: nonNull(graphql.String)},
+		{Name: "orders", Type: nonNull(graphql.Int)},
+	}}
+	gqlStats = &graphql.Object{Name: "Stats", Description: "Statistics of the orders placed in a range, as GET /v1/stats reports them", Fields: []*graphql.Field{
+		{Name: "from", Type: nonNull(gqlDateTime)},
+		{Name: "to", Type: nonNull(gqlDateTime)},
+		{Name: "orders", Type: nonNull(graphql.Int)},
+		{Name: "pending", Type: nonNull(graphql.Int)},
+		{Name: "prepared", Type: nonNull(graphql.Int)},
+		{Name: "pickedUp", Type: nonNull(graphql.Int)},
+		{Name: "expired", Type: nonNull(graphql.Int)},
+		{Name: "cancelled", Type: nonNull(graphql.Int)},
+		{Name: "voided", Type: nonNull(graphql.Int)},
+		{Name: "refunded", Type: nonNull(graphql.Int)},
+		{Name: "remakes", Type: nonNull(graphql.Int)},
+		{Name: "avgPrepSeconds", Type: nonNull(graphql.Float)},
+		{Name: "p95PrepSeconds", Type: nonNull(graphql.Float)},
+		{Name: "peakHour", Type: nonNull(graphql.String), Description: "Busiest hour of day, empty without orders"},
+		{Name: "byHour", Type: listOf(gqlBucket), Resolve: orEmpty("byHour")},
+		{Name: "byDay", Type: listOf(gqlBucket), Resolve: orEmpty("byDay")},
+		{Name: "items", Type: listOf(gqlItemCount), Resolve: orEmpty("items")},
+		{Name: "channels", Type: listOf(gqlChannelCount), Resolve: orEmpty("channels")},
+		{Name: "sla", Type: listOf(gqlSLAClass), Resolve: orEmpty("sla")},
+		{Name: "voidReasons", Type: listOf(gqlReasonCount), Resolve: orEmpty("voidReasons")},
+		{Name: "remakeReasons", Type: listOf(gqlReasonCount), Resolve: orEmpty("remakeReasons")},
+	}}
+)
+
+func nonNull(t graphql.Type) graphql.Type {
+	return graphql.NonNullOf(t)
+}
+
+// listOf is a list of t that is never null and holds no nulls
+func listOf(t graphql.Type) graphql.Type {
+	return graphql.NonNullOf(graphql.ListOf(graphql.NonNullOf(t)))
+}
+
+// orEmpty resolves a list left out of the JSON when empty
+func orEmpty(name string) func(graphql.Params) (any, error) {
+	return func(p graphql.Params) (any, error) {
+		if v := p.Source.(map[string]any)[name]; v != nil {
+			return v, nil
+		}
+		return []any{}, nil
+	}
+}
+
+// isSet resolves a flag left out of the JSON when false
+func isSet(name string) func(graphql.Params) (any, error) {
+	return func(p graphql.Params) (any, error) {
+		return p.Source.(map[string]any)[name] == true, nil
+	}
+}
+
+// Queries
+
+func (s *Server) graphQLQuery() *graphql.Object {
+	return &graphql.Object{Name: "Query", Fields: []*graphql.Field{
+		{
+			Name:        "orders",
+			Description: "Orders matching every filter given, as GET /v1/orders lists them",
+			Type:        nonNull(gqlOrderPage),
+			Args: []*graphql.Arg{
+				{Name: "status", Type: gqlOrderStatus},
+				{Name: "from", Type: gqlDateTime},
+				{Name: "to", Type: gqlDateTime},
+				{Name: "item", Type: graphql.String},
+				{Name: "table", Type: graphql.Int},
+				{Name: "group", Type: graphql.String},
+				{Name: "orderType", Type: gqlOrderType},
+				{Name: "channel", Type: gqlChannel},
+				{Name: "payment", Type: gqlPayment},
+				{Name: "flags", Type: graphql.ListOf(nonNull(graphql.String)), Description: "Orders with every flag; allergy matches any allergy flag"},
+				{Name: "priority", Type: graphql.Int},
+				{Name: "minPriority", Type: graphql.Int},
+				{Name: "maxPriority", Type: graphql.Int},
+				{Name: "sort", Type: graphql.String, Description: "id, priority, timestamp or item, prefixed with - for descending"},
+				{Name: "limit", Type: graphql.Int, Default: manager.DefaultListLimit},
+				{Name: "offset", Type: graphql.Int, Default: 0},
+			},
+			Resolve: s.graphQLOrders,
+		},
+		{
+			Name:    "order",
+			Type:    gqlOrder,
+			Args:    []*graphql.Arg{{Name: "id", Type: nonNull(graphql.ID)}},
+			Resolve: s.graphQLOrder,
+		},
+		{
+			Name:        "stats",
+			Description: "Statistics of the orders placed from from to to, RFC 3339 times or YYYY-MM-DD dates, to inclusive; today by default",
+			Type:        nonNull(gqlStats),
+			Args:        []*graphql.Arg{{Name: "from", Type: graphql.String}, {Name: "to", Type: graphql.String}},
+			Resolve:     s.graphQLStats,
+		},
+	}}
+}
+
+func (s *Server) graphQLOrders(p graphql.Params) (any, error) {
+	if err := s.graphQLKitchen(p.Context); err != nil {
+		return nil, err
+	}
+	q := graphQLValues(p.Args)
+	if flags, ok := q["flags"]; ok {
+		q["flag"] = flags
+	}
+	f, errs := parseOrderFilter(q)
+	if len(errs) > 0 {
+		return nil, graphQLValidationError(p.Context, errs)
+	}
+	span := opSpan(graphQLRequestOf(p.Context), "QueryOrders")
+	orders, total, err := s.om.QueryOrders(p.Context, f)
+	span.Finish(err)
+	if err != nil {
+		return nil, graphQLError(p.Context, err)
+	}
+	return orderList{Orders: orders, Total: total, Limit: f.Limit, Offset: f.Offset}, nil
+}
+
+// graphQLOrder returns the order with the ID given, or null when there is
+// none
+func (s *Server) graphQLOrder(p graphql.Params) (any, error) {
+	if err := s.graphQLKitchen(p.Context); err != nil {
+		return nil, err
+	}
+	id := p.Args["id"].(string)
+	if !validID(id) {
+		return nil, graphQLBadRequest(p.Context, i18n.Errorf("invalid order id %q", id))
+	}
+	span := opSpan(graphQLRequestOf(p.Context), "GetOrder")
+	token, err := s.om.GetOrder(p.Context, id)
+	span.Finish(err)
+	switch {
+	case errors.Is(err, manager.ErrOrderNotFound):
+		return nil, nil
+	case err != nil:
+		return nil, graphQLError(p.Context, err)
+	}
+	return token, nil
+}
+
+func (s *Server) graphQLStats(p graphql.Params) (any, error) {
+	from, to, err := parseDateRange(graphQLValues(p.Args), s.om.Now())
+	if err != nil {
+		return nil, graphQLBadRequest(p.Context, err)
+	}
+	span := opSpan(graphQLRequestOf(p.Context), "QueryOrders")
+	orders, _, err := s.om.QueryOrders(p.Context, manager.OrderFilter{From: from, To: to})
+	span.Finish(err)
+	if err != nil {
+		return nil, graphQLError(p.Context, err)
+	}
+	return analytics.Compute(orders, from, to, time.Local), nil
+}
+
+// Mutations
+
+func (s *Server) graphQLMutation() *graphql.Object {
+	return &graphql.Object{Name: "Mutation", Fields: []*graphql.Field{
+		{
+			Name:        "addOrder",
+			Description: "Places an order, as POST /v1/orders does",
+			Type:        nonNull(gqlOrder),
+			Args: []*graphql.Arg{
+				{Name: "item", Type: nonNull(graphql.String)},
+				{Name: "priority", Type: nonNull(graphql.Int)},
+				{Name: "quantity", Type: graphql.Int},
+				{Name: "notes", Type: graphql.String},
+				{Name: "flags", Type: graphql.ListOf(nonNull(graphql.String))},
+				{Name: "station", Type: graphql.String},
+				{Name: "orderType", Type: gqlOrderType},
+				{Name: "channel", Type: gqlChannel, Description: "Where the order is placed; taken from the credentials when not given"},
+				{Name: "table", Type: graphql.Int},
+				{Name: "payment", Type: gqlPayment},
+				{Name: "readyAt", Type: gqlDateTime},
+				{Name: "promisedBy", Type: gqlDateTime},
+				{Name: "phone", Type: graphql.String},
+				{Name: "deviceToken", Type: graphql.String},
+				{Name: "platform", Type: graphql.String},
+				{Name: "externalId", Type: graphql.String},
+				{Name: "group", Type: graphql.String},
+				{Name: "notifyGroup", Type: graphql.Boolean},
+				{Name: "course", Type: graphql.Int},
+				{Name: "currency", Type: graphql.String},
+			},
+			Resolve: s.graphQLAddOrder,
+		},
+		{
+			Name: "prepare",
+			Description: "Prepares the order with the id given, or else the next order, for the station given if any, " +
+				`an empty one meaning orders without one, as POST /v1/orders/next does`,
+			Type:    nonNull(gqlOrder),
+			Args:    []*graphql.Arg{{Name: "id", Type: graphql.ID}, {Name: "station", Type: graphql.String}},
+			Resolve: s.graphQLPrepare,
+		},
+		{
+			Name:        "cancel",
+			Description: "Cancels an order, with version set only while it is at that version",
+			Type:        nonNull(gqlOrder),
+			Args:        []*graphql.Arg{{Name: "id", Type: nonNull(graphql.ID)}, {Name: "version", Type: graphql.String}},
+			Resolve:     s.graphQLCancel,
+		},
+	}}
+}
+
+func (s *Server) graphQLAddOrder(p graphql.Params) (any, error) {
+	// Charged to the same buckets as POST /v1/orders, so a client held back
+	// there cannot place orders here instead
+	if rl := s.limiter("POST /v1/orders"); rl != nil {
+		if ok, wait := rl.Allow(s.clientKey(graphQLRequestOf(p.Context))); !ok {
+			return nil, graphQLRateLimited(p.Context, wait)
+		}
+	}
+	o, errs := s.parseNewOrder(graphQLValues(p.Args))
+	if len(errs) > 0 {
+		return nil, graphQLValidationError(p.Context, errs)
+	}
+	o.Client = remoteIP(graphQLRequestOf(p.Context))
+	if o.Channel == "" && o.Platform == "" {
+		o.Channel = s.channel(graphQLRequestOf(p.Context))
+	}
+	span := opSpan(graphQLRequestOf(p.Context), "PlaceOrder")
+	token, err := s.om.PlaceOrder(p.Context, o)
+	span.Finish(err)
+	if err != nil {
+		return nil, graphQLError(p.Context, err)
+	}
+	return token, nil
+}
+
+func (s *Server) graphQLPrepare(p graphql.Params) (any, error) {
+	if err := s.graphQLKitchen(p.Context); err != nil {
+		return nil, err
+	}
+	r := graphQLRequestOf(p.Context)
+	q := graphQLValues(p.Args)
+	var token *queue.Token
+	var err error
+	switch {
+	case q.Has("id"):
+		span := opSpan(r, "PrepareOrderByID")
+		token, err = s.om.PrepareOrderByID(p.Context, q.Get("id"))
+		span.Finish(err)
+	case q.Has("station"):
+		span := opSpan(r, "PrepareStationOrder")
+		token, err = s.om.PrepareStationOrder(p.Context, q.Get("station"))
+		span.Finish(err)
+	default:
+		span := opSpan(r, "PrepareOrder")
+		token, err = s.om.PrepareOrder(p.Context)
+		span.Finish(err)
+	}
+	if err != nil {
+		return nil, graphQLError(p.Context, err)
+	}
+	s.record(r, audit.ActionPrepare, token.ID, "", params(q, "station"))
+	return token, nil
+}
+
+func (s *Server) graphQLCancel(p graphql.Params) (any, error) {
+	if err := s.graphQLKitchen(p.Context); err != nil {
+		return nil, err
+	}
+	r := graphQLRequestOf(p.Context)
+	q := graphQLValues(p.Args)
+	if q.Get("version") == "" && s.cfg.RequireVersion {
+		return nil, &graphql.Error{
+			Message:    i18n.T(i18n.FromContext(p.Context), "send the order's version"),
+			Extensions: map[string]any{"status": http.StatusPreconditionRequired, "code": statusCode(http.StatusPreconditionRequired)},
+		}
+	}
+	span := opSpan(r, "CancelOrder")
+	token, err := s.om.CancelOrder(p.Context, q.Get("id"), q.Get("version"))
+	span.Finish(err)
+	if err != nil {
+		return nil, graphQLError(p.Context, err)
+	}
+	s.record(r, audit.ActionCancel, token.ID, "", nil)
+	return token, nil
+}
+
+// Subscriptions
+
+func (s *Server) graphQLSubscription() *graphql.Object {
+	return &graphql.Object{Name: "Subscription", Fields: []*graphql.Field{
+		{
+			Name:        "orderEvents",
+			Description: "Order events as they happen, as GET /v1/events streams them: with station set only that station's, with types only those",
+			Type:        nonNull(gqlEvent),
+			Args:        []*graphql.Arg{{Name: "station", Type: graphql.String}, {Name: "types", Type: graphql.ListOf(nonNull(graphql.String))}},
+			Subscribe:   s.graphQLOrderEvents,
+		},
+	}}
+}
+
+func (s *Server) graphQLOrderEvents(p graphql.Params) (<-chan any, error) {
+	if err := s.graphQLKitchen(p.Context); err != nil {
+		return nil, err
+	}
+	var filter streamFilter
+	if station, ok := p.Args["station"].(string); ok {
+		filter.Stations = []string{station}
+	}
+	types, _ := p.Args["types"].([]any)
+	for _, typ := range types {
+		if typ, ok := typ.(string); ok {
+			filter.Types = append(filter.Types, typ)
+		}
+	}
+	client := s.stream.subscribe(nil, transportInternal, filter)
+	out := make(chan any)
+	go func() {
+		defer close(out)
+		defer s.stream.unsubscribe(client)
+		for {
+			select {
+			case <-p.Context.Done():
+				return
+			case e, ok := <-client.events:
+				if !ok {
+					return
+				}
+				client.delivered.Add(1)
+				select {
+				case out <- e:
+				case <-p.Context.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,154 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type graphQLResult struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Path       []any          `json:"path"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func TestGraphQL(t *testing.T) {
+	s := newTestServer(t)
+	run := func(query string, vars map[string]any) (int, graphQLResult) {
+		t.Helper()
+		body, _ := json.Marshal(map[string]any{"query": query, "variables": vars})
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		var res graphQLResult
+		decode(t, rec, &res)
+		return rec.Code, res
+	}
+	type order struct {
+		ID     string   `json:"id"`
+		Item   string   `json:"item"`
+		Status string   `json:"status"`
+		Flags  []string `json:"flags"`
+	}
+
+	code, res := run(`mutation($item: String!) { addOrder(item: $item, priority: 1, flags: ["vegan"]) { id item status flags } }`,
+		map[string]any{"item": "tea"})
+	var placed order
+	if code != http.StatusOK || len(res.Errors) > 0 || json.Unmarshal(res.Data["addOrder"], &placed) != nil {
+		t.Fatalf("addOrder = %d %+v", code, res)
+	}
+	if placed.Item != "tea" || len(placed.Flags) != 1 || placed.Flags[0] != "vegan" {
+		t.Errorf("addOrder placed %+v", placed)
+	}
+
+	_, res = run(`mutation { addOrder(item: "", priority: 1) { id } }`, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["status"] != float64(http.StatusUnprocessableEntity) || res.Errors[0].Extensions["fields"] == nil {
+		t.Errorf("addOrder without an item = %+v", res)
+	}
+
+	_, res = run(`{ orders(item: "tea") { total orders { id flags edits { field } } } missing: order(id: "nope") { id } }`, nil)
+	var page struct {
+		Total  int
+		Orders []order
+	}
+	if len(res.Errors) > 0 || json.Unmarshal(res.Data["orders"], &page) != nil || page.Total != 1 || page.Orders[0].ID != placed.ID {
+		t.Errorf("orders = %+v", res)
+	}
+	if string(res.Data["missing"]) != "null" {
+		t.Errorf("unknown order = %s", res.Data["missing"])
+	}
+
+	_, res = run(`mutation($id: ID) { prepare(id: $id) { status } }`, map[string]any{"id": placed.ID})
+	if string(res.Data["prepare"]) != `{"status":"prepared"}` {
+		t.Errorf("prepare = %+v", res)
+	}
+	_, res = run(`mutation($id: ID!) { cancel(id: $id) { status } }`, map[string]any{"id": placed.ID})
+	if res.Data != nil || len(res.Errors) != 1 || res.Errors[0].Extensions["status"] != float64(http.StatusConflict) {
+		t.Errorf("cancel of a prepared order = %+v", res)
+	}
+
+	_, res = run(`{ stats { orders prepared items { item orders } } }`, nil)
+	if string(res.Data["stats"]) != `{"orders":1,"prepared":1,"items":[{"item":"tea","orders":1}]}` {
+		t.Errorf("stats = %+v", res)
+	}
+
+	if code, res := run(`{ orders { nope } }`, nil); code != http.StatusBadRequest || len(res.Errors) != 1 {
+		t.Errorf("unknown field = %d %+v", code, res)
+	}
+	if rec := do(t, s, http.MethodGet, "/graphql?query="+url.QueryEscape(`{ orders { total } }`)); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"total":1`) {
+		t.Errorf("GET query = %d %s", rec.Code, rec.Body)
+	}
+	rec := do(t, s, http.MethodGet, "/graphql?query="+url.QueryEscape(`mutation { prepare { id } }`))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("GET mutation = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodGet, "/graphql?query={"); rec.Code != http.StatusBadRequest {
+		t.Errorf("syntax error = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodPost, "/graphql"); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("POST without JSON = %d", rec.Code)
+	}
+
+	rec = do(t, s, http.MethodGet, "/graphql/schema")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Body.String(), "type Query {") || strings.Contains(rec.Body.String(), "pickupCode") {
+		t.Errorf("schema = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestGraphQLSubscription(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close) // After the stream is closed
+
+	query := url.QueryEscape(`subscription { orderEvents(station: "bar", types: ["created"]) { type order { item } } }`)
+	if res, err := http.Get(srv.URL + "/graphql?query=" + query); err != nil || res.StatusCode != http.StatusNotAcceptable {
+		t.Fatalf("subscription without Accept = %v %v", res.Status, err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/graphql?query="+query, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	lines := bufio.NewScanner(res.Body)
+	lines.Scan() // Connected
+
+	for _, target := range []string{"/v1/orders?item=tea&priority=1", "/v1/orders?item=beer&priority=1&station=bar", "/v1/orders/next?station=bar"} {
+		if res, err := http.Post(srv.URL+target, "", nil); err != nil || res.StatusCode/100 != 2 {
+			t.Fatalf("%s: %v %v", target, res.Status, err)
+		}
+	}
+	got := make(chan string)
+	go func() {
+		var event string
+		for lines.Scan() {
+			line := lines.Text()
+			if v, ok := strings.CutPrefix(line, "event: "); ok {
+				event = v
+			}
+			if v, ok := strings.CutPrefix(line, "data: "); ok {
+				got <- event + " " + v
+				return
+			}
+		}
+		close(got)
+	}()
+	select {
+	case e := <-got:
+		if e != `next {"data":{"orderEvents":{"type":"created","order":{"item":"beer"}}}}` {
+			t.Errorf("event = %s", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+}
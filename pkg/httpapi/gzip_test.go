@@ -0,0 +1,49 @@
+package httpapi
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzip(t *testing.T) {
+	s := newTestServer(t)
+	for i := 0; i < 20; i++ {
+		do(t, s, http.MethodPost, "/v1/orders?item=soup&priority=1")
+	}
+	get := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept-Encoding", accept)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/v1/orders", "br, gzip;q=0.8")
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil || strings.Count(string(body), `"soup"`) != 20 {
+		t.Fatalf("decompressed body = %s, %v", body, err)
+	}
+
+	for _, accept := range []string{"", "identity", "gzip;q=0"} {
+		if rec := get("/v1/orders", accept); rec.Header().Get("Content-Encoding") != "" || !strings.Contains(rec.Body.String(), `"soup"`) {
+			t.Errorf("Accept-Encoding %q: encoding %q", accept, rec.Header().Get("Content-Encoding"))
+		}
+	}
+	if rec := get("/v1/orders/1", "gzip"); rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("single order compressed; only configured routes should be")
+	}
+	if rec := get("/v1/export", "gzip"); rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Content-Type") != "text/csv" {
+		t.Errorf("export headers = %v", rec.Header())
+	}
+}
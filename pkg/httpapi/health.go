@@ -0,0 +1,121 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/config"
+)
+
+// HealthConfig controls the readiness probe
+type HealthConfig struct {
+	Timeout  config.Duration `json:"timeout"`  // Limit for each readiness check
+	Disabled []string        `json:"disabled"` // Names of readiness checks to skip
+}
+
+// DefaultHealthConfig returns the settings used when nothing is configured
+func DefaultHealthConfig() HealthConfig {
+	return HealthConfig{Timeout: config.Duration(2 * time.Second)}
+}
+
+// Check reports whether a dependency the server needs is usable
+type Check func(ctx context.Context) error
+
+// namedCheck is a readiness check as listed in /readyz
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// WithReadinessCheck adds c to /readyz under name
+func WithReadinessCheck(name string, c Check) Option {
+	return func(s *Server) { s.checks = append(s.checks, namedCheck{name, c}) }
+}
+
+// registerHealthRoutes mounts the liveness and readiness probes. The built-in
+// readiness checks are "queue" and, with an event log, "eventLog".
+func (s *Server) registerHealthRoutes() {
+	builtin := []namedCheck{{"queue", func(ctx context.Context) error { return s.om.Check(ctx) }}}
+	if s.events != nil {
+		builtin = append(builtin, namedCheck{"eventLog", func(context.Context) error { return s.events.Check() }})
+	}
+	s.checks = append(builtin, s.checks...)
+	s.checks = slices.DeleteFunc(s.checks, func(c namedCheck) bool {
+		return slices.Contains(s.cfg.Health.Disabled, c.name)
+	})
+
+	s.handle("GET /healthz", s.healthzHandler)
+	s.handle("GET /readyz", s.readyzHandler)
+}
+
+// healthStatus is the JSON payload for the probes
+type healthStatus struct {
+	Status string        `json:"status"` // "ok" or "unavailable"
+	Checks []checkResult `json:"checks,omitempty"`
+}
+
+type checkResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"` // "ok" or "failing"
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+// healthzHandler reports that the process is up and serving; it checks
+// nothing else so a failing dependency never gets the pod restarted
+func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// readyzHandler runs every readiness check concurrently and answers 503 if
+// any fails or exceeds the timeout
+func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if timeout := time.Duration(s.cfg.Health.Timeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	results := make([]checkResult, len(s.checks))
+	var wg sync.WaitGroup
+	for i, c := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	body, status := healthStatus{Status: "ok", Checks: results}, http.StatusOK
+	for _, res := range results {
+		if res.Status != "ok" {
+			body.Status, status = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, status, body)
+}
+
+// runCheck runs c, giving up when ctx ends even if the check does not
+// watch ctx itself
+func runCheck(ctx context.Context, c namedCheck) checkResult {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- c.check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	res := checkResult{Name: c.name, Status: "ok", LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		res.Status, res.Error = "failing", err.Error()
+	}
+	return res
+}
@@ -0,0 +1,63 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+)
+
+func TestHealthz(t *testing.T) {
+	s := newTestServer(t)
+	rec := do(t, s, http.MethodGet, "/healthz")
+	var body healthStatus
+	decode(t, rec, &body)
+	if rec.Code != http.StatusOK || body.Status != "ok" {
+		t.Fatalf("healthz = %d %+v", rec.Code, body)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Health.Timeout = config.Duration(50 * time.Millisecond)
+	var depErr error
+	s := New(manager.New(manager.DefaultConfig()), cfg,
+		WithReadinessCheck("printer", func(context.Context) error { return depErr }),
+		WithReadinessCheck("slow", func(ctx context.Context) error { <-ctx.Done(); return nil }))
+
+	rec := do(t, s, http.MethodGet, "/readyz")
+	var body healthStatus
+	decode(t, rec, &body)
+	if rec.Code != http.StatusServiceUnavailable || body.Status != "unavailable" || len(body.Checks) != 3 {
+		t.Fatalf("readyz = %d %+v", rec.Code, body)
+	}
+	for _, c := range body.Checks {
+		want := "ok"
+		if c.Name == "slow" {
+			want = "failing"
+		}
+		if c.Status != want {
+			t.Errorf("check %s = %+v, want %s", c.Name, c, want)
+		}
+	}
+
+	cfg.Health.Disabled = []string{"slow"}
+	depErr = errors.New("paper out")
+	s = New(manager.New(manager.DefaultConfig()), cfg,
+		WithReadinessCheck("printer", func(context.Context) error { return depErr }),
+		WithReadinessCheck("slow", func(ctx context.Context) error { <-ctx.Done(); return nil }))
+	rec = do(t, s, http.MethodGet, "/readyz")
+	decode(t, rec, &body)
+	if rec.Code != http.StatusServiceUnavailable || len(body.Checks) != 2 || body.Checks[1].Error != "paper out" {
+		t.Fatalf("readyz with failing printer = %d %+v", rec.Code, body)
+	}
+
+	depErr = nil
+	if rec := do(t, s, http.MethodGet, "/readyz"); rec.Code != http.StatusOK {
+		t.Fatalf("readyz with healthy checks = %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,64 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocalizedErrors(t *testing.T) {
+	s := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders/99", nil)
+	req.Header.Set("Accept-Language", "es-ES,es;q=0.9,en;q=0.5")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	var body errorBody
+	decode(t, rec, &body)
+	if rec.Code != http.StatusNotFound || body.Error != "pedido no encontrado" || rec.Header().Get("Content-Language") != "es" {
+		t.Fatalf("GET = %d %q (%s)", rec.Code, body.Error, rec.Header().Get("Content-Language"))
+	}
+
+	// The query parameter wins over the header; field names stay as they are
+	rec = do(t, s, http.MethodPost, "/v1/orders?lang=es&item=&priority=2")
+	body = errorBody{}
+	decode(t, rec, &body)
+	if body.Error != "solicitud no válida" || len(body.Fields) != 1 || body.Fields[0].Field != "item" || body.Fields[0].Message != "no debe estar vacío" {
+		t.Fatalf("POST = %d %+v", rec.Code, body)
+	}
+	rec = do(t, s, http.MethodGet, "/v1/orders/a.b?lang=es")
+	body = errorBody{}
+	decode(t, rec, &body)
+	if body.Error != `id de pedido no válido "a.b"` {
+		t.Fatalf("bad id error = %q", body.Error)
+	}
+
+	// Unsupported languages get English
+	rec = do(t, s, http.MethodGet, "/v1/orders/99?lang=xx")
+	body = errorBody{}
+	decode(t, rec, &body)
+	if body.Error != "order not found" || rec.Header().Get("Content-Language") != "en" {
+		t.Fatalf("lang=xx: %q (%s)", body.Error, rec.Header().Get("Content-Language"))
+	}
+
+	// The legacy endpoints keep their text
+	if rec := do(t, s, http.MethodGet, "/addOrder?item=pizza&priority=abc&lang=es"); rec.Body.String() != "Invalid priority\n" {
+		t.Fatalf("legacy = %q", rec.Body.String())
+	}
+}
+
+func TestLocalizedKDSPage(t *testing.T) {
+	s := newTestServer(t)
+	rec := do(t, s, http.MethodGet, "/kds?lang=es")
+	page := rec.Body.String()
+	for _, want := range []string{`<html lang="es">`, "<title>Pantalla de cocina</title>", `"Sin asignar"`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page lacks %s", want)
+		}
+	}
+	rec = do(t, s, http.MethodGet, "/kds")
+	if page := rec.Body.String(); !strings.Contains(page, "<title>Kitchen display</title>") || !strings.Contains(page, "const messages = {};") {
+		t.Errorf("english page:\n%s", page)
+	}
+}
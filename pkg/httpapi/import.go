@@ -0,0 +1,211 @@
+package httpapi
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/i18n"
+	"awesomeProject/pkg/importer"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/validate"
+)
+
+// maxImportBody bounds the files uploaded to the import endpoints
+const maxImportBody = 32 << 20
+
+// registerImportRoutes mounts the menu and order imports, for admins, when
+// there is an admin token
+func (s *Server) registerImportRoutes() {
+	if s.cfg.Admin.Token == "" {
+		return
+	}
+	s.handle("GET /v1/admin/menu", s.admin(s.menuV1))
+	s.handle("POST /v1/admin/import/menu", s.admin(s.importMenuV1))
+	s.handle("POST /v1/admin/import/orders", s.admin(s.importOrdersV1))
+}
+
+// menuImport is the report of a menu import, with the menu after it
+type menuImport struct {
+	importer.Report
+	Menu *manager.Menu `json:"menu,omitempty"`
+}
+
+// menuV1 returns the item prices, complexity scores and preparation times
+// in use
+func (s *Server) menuV1(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.om.Menu())
+}
+
+// importMenuV1 sets the items of an uploaded menu file. A file with any
+// invalid row changes nothing, and with dryRun set only the report is
+// returned.
+func (s *Server) importMenuV1(w http.ResponseWriter, r *http.Request) {
+	dryRun, ok := dryRunParam(w, r)
+	if !ok {
+		return
+	}
+	body, format, ok := importFile(w, r)
+	if !ok {
+		return
+	}
+	items, rep, err := importer.Menu(body, format, s.cfg.Validation)
+	if err != nil {
+		writeImportError(w, r, err)
+		return
+	}
+	rep.DryRun = dryRun
+	if !rep.OK() || dryRun {
+		writeImportReport(w, r, rep)
+		return
+	}
+	menu := s.om.UpdateMenu(items)
+	rep.Imported = len(items)
+	s.record(r, audit.ActionImport, "", "", map[string]string{"kind": "menu", "rows": strconv.Itoa(rep.Rows)})
+	writeJSON(w, http.StatusOK, menuImport{Report: rep, Menu: &menu})
+}
+
+// importOrdersV1 adds the past orders of an uploaded file as closed orders.
+// A file with any invalid row imports nothing, and with dryRun set only the
+// report is returned.
+func (s *Server) importOrdersV1(w http.ResponseWriter, r *http.Request) {
+	dryRun, ok := dryRunParam(w, r)
+	if !ok {
+		return
+	}
+	body, format, ok := importFile(w, r)
+	if !ok {
+		return
+	}
+	orders, rep, err := importer.Orders(body, format, s.cfg.Validation)
+	if err != nil {
+		writeImportError(w, r, err)
+		return
+	}
+	rep.DryRun = dryRun
+	if !rep.OK() || dryRun {
+		writeImportReport(w, r, rep)
+		return
+	}
+	span := opSpan(r, "ImportOrders")
+	tokens, err := s.om.ImportOrders(r.Context(), orders)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	rep.Imported = len(tokens)
+	s.record(r, audit.ActionImport, "", "", map[string]string{"kind": "orders", "rows": strconv.Itoa(rep.Rows)})
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// dryRunParam reads the dryRun query parameter, writing the error and
+// returning false when it is not a boolean
+func dryRunParam(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	var errs validate.Errors
+	dryRun := boolParam(r.URL.Query(), "dryRun", &errs)
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return false, false
+	}
+	return dryRun, true
+}
+
+// importFile returns the file uploaded to an import endpoint and its format.
+// The file is the body itself, sent as text/csv or application/json, or the
+// file field of a multipart form, whose format is taken from its type or
+// name. The format parameter, when given, overrides either. importFile
+// writes the error and returns false when there is no file to read.
+func importFile(w http.ResponseWriter, r *http.Request) (io.Reader, string, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBody)
+	format := r.URL.Query().Get("format")
+	if format != "" && format != importer.FormatCSV && format != importer.FormatJSON {
+		writeError(w, r, http.StatusBadRequest, importer.ErrFormat.Error())
+		return nil, "", false
+	}
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mt != "multipart/form-data" {
+		if format == "" {
+			format = fileFormat(mt, "")
+		}
+		if format == "" {
+			writeError(w, r, http.StatusUnsupportedMediaType, "send the file as text/csv or application/json, or in a multipart form")
+			return nil, "", false
+		}
+		return r.Body, format, true
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeErrorf(w, r, http.StatusBadRequest, "invalid multipart body: %v", err)
+		return nil, "", false
+	}
+	for {
+		part, err := mr.NextPart()
+		var tooBig *http.MaxBytesError
+		switch {
+		case errors.Is(err, io.EOF):
+			writeError(w, r, http.StatusBadRequest, "upload the file in a field named file")
+			return nil, "", false
+		case errors.As(err, &tooBig):
+			writeErrorf(w, r, http.StatusRequestEntityTooLarge, "files must be at most %d bytes", maxImportBody)
+			return nil, "", false
+		case err != nil:
+			writeErrorf(w, r, http.StatusBadRequest, "invalid multipart body: %v", err)
+			return nil, "", false
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		if format == "" {
+			pt, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			format = fileFormat(pt, part.FileName())
+		}
+		if format == "" {
+			writeError(w, r, http.StatusUnsupportedMediaType, "name the file .csv or .json, or give the format")
+			return nil, "", false
+		}
+		return part, format, true
+	}
+}
+
+// fileFormat is the import format of a file of media type mt or with the
+// given name, or "" when neither tells
+func fileFormat(mt, name string) string {
+	switch {
+	case mt == "text/csv" || strings.EqualFold(path.Ext(name), ".csv"):
+		return importer.FormatCSV
+	case mt == "application/json" || strings.EqualFold(path.Ext(name), ".json"):
+		return importer.FormatJSON
+	}
+	return ""
+}
+
+// writeImportReport returns the report of an import that did not happen:
+// 200 for a dry run that found nothing wrong, 422 when a row is invalid
+func writeImportReport(w http.ResponseWriter, r *http.Request, rep importer.Report) {
+	status := http.StatusOK
+	if !rep.OK() {
+		status = http.StatusUnprocessableEntity
+	}
+	lang := language(w, r)
+	writeJSON(w, status, rep.Translate(func(format string) string { return i18n.T(lang, format) }))
+}
+
+// writeImportError reports a file that could not be read at all
+func writeImportError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooBig *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooBig):
+		writeErrorf(w, r, http.StatusRequestEntityTooLarge, "files must be at most %d bytes", maxImportBody)
+	case errors.Is(err, importer.ErrTooMany):
+		writeErrorf(w, r, http.StatusRequestEntityTooLarge, "files may have at most %d rows", importer.MaxRows)
+	default:
+		writeErrorf(w, r, http.StatusBadRequest, "invalid file: %v", err)
+	}
+}
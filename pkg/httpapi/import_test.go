@@ -0,0 +1,95 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/importer"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Token = "t0ken"
+	trail := openAudit(t)
+	om := manager.New(manager.DefaultConfig())
+	s := New(om, cfg, WithAuditLog(trail))
+	send := func(target, contentType, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer t0ken")
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(t, s, http.MethodPost, "/v1/admin/import/menu"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("import without the admin token = %d", rec.Code)
+	}
+	if rec := send("/v1/admin/import/menu", "text/plain", "item,price\n"); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("import as text/plain = %d", rec.Code)
+	}
+	if rec := send("/v1/admin/import/menu", "text/csv", "item,cost\nsoup,4\n"); rec.Code != http.StatusBadRequest {
+		t.Errorf("import with an unknown column = %d", rec.Code)
+	}
+
+	menu := "item,price,prepTime\nsoup,4.50,4m\nstew,x,\n"
+	rec := send("/v1/admin/import/menu?dryRun=true", "text/csv", menu)
+	var rep importer.Report
+	decode(t, rec, &rep)
+	if rec.Code != http.StatusUnprocessableEntity || rep.Rows != 2 || len(rep.Errors) != 1 || rep.Errors[0].Row != 2 || rep.Errors[0].Field != "price" {
+		t.Fatalf("dry run = %d %+v", rec.Code, rep)
+	}
+	rec = send("/v1/admin/import/menu", "text/csv", menu)
+	if rec.Code != http.StatusUnprocessableEntity || len(om.Menu().ItemPrices) != 0 {
+		t.Fatalf("import with an invalid row = %d, menu %+v", rec.Code, om.Menu())
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "menu.json")
+	part.Write([]byte(`[{"item": "soup", "price": 4.5, "prepTime": "4m"}]`))
+	mw.Close()
+	rec = send("/v1/admin/import/menu", mw.FormDataContentType(), body.String())
+	var imported menuImport
+	decode(t, rec, &imported)
+	if rec.Code != http.StatusOK || imported.Imported != 1 || imported.Menu.ItemPrices["soup"] != 4.5 || om.Menu().ItemPrices["soup"] != 4.5 {
+		t.Fatalf("import = %d %s", rec.Code, rec.Body)
+	}
+
+	orders := `[{"id": "A-1", "item": "soup", "status": "picked_up", "orderedAt": "2024-02-01T12:00:00Z", "closedAt": "2024-02-01T12:09:00Z"},
+		{"id": "A-2", "item": "tea", "status": "expired", "orderedAt": "2024-02-01T12:00:00Z", "closedAt": "2024-02-01T12:30:00Z"}]`
+	rec = send("/v1/admin/import/orders?dryRun=true", "application/json", orders)
+	rep = importer.Report{}
+	decode(t, rec, &rep)
+	if rec.Code != http.StatusOK || !rep.DryRun || rep.Rows != 2 || rep.Imported != 0 {
+		t.Fatalf("dry run = %d %+v", rec.Code, rep)
+	}
+	if _, n, _ := om.QueryOrders(ctx, manager.OrderFilter{}); n != 0 {
+		t.Fatalf("dry run imported %d orders", n)
+	}
+	rec = send("/v1/admin/import/orders", "application/json", orders)
+	rep = importer.Report{}
+	decode(t, rec, &rep)
+	if rec.Code != http.StatusOK || rep.Imported != 2 {
+		t.Fatalf("import = %d %s", rec.Code, rec.Body)
+	}
+	list, _, _ := om.QueryOrders(ctx, manager.OrderFilter{})
+	if len(list) != 2 || list[0].ImportedFrom != "A-1" || list[0].Status != queue.StatusPickedUp {
+		t.Errorf("orders = %+v", list)
+	}
+
+	entries, _ := trail.Query(audit.Filter{Action: audit.ActionImport})
+	if len(entries) != 2 || entries[0].Detail["kind"] != "orders" || entries[0].Detail["rows"] != "2" || entries[1].Detail["kind"] != "menu" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+}
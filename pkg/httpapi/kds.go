@@ -0,0 +1,195 @@
+package httpapi
+
+import (
+	_ "embed"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/alerts"
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/features"
+	"awesomeProject/pkg/i18n"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+//go:embed kds.html
+var kdsHTML string
+
+// kdsPage renders the display in the request's language; the script gets the
+// catalog for its own text
+var kdsPage = page("kds", kdsHTML)
+
+type kdsPageData struct {
+	Lang     string
+	T        func(string) string
+	Messages map[string]string
+	Brand    brand
+}
+
+// Age levels shown on the kitchen display
+const (
+	ageFresh = "fresh"
+	ageWarn  = "warn"
+	ageLate  = "late"
+)
+
+// KDSConfig controls the kitchen display
+type KDSConfig struct {
+	Enabled   bool            `json:"enabled"`   // Serve the display page at /kds
+	WarnAfter config.Duration `json:"warnAfter"` // Waiting time at which an order is flagged
+	LateAfter config.Duration `json:"lateAfter"` // Waiting time at which an order is late
+}
+
+// DefaultKDSConfig returns the settings used when nothing is configured
+func DefaultKDSConfig() KDSConfig {
+	return KDSConfig{
+		Enabled:   true,
+		WarnAfter: config.Duration(5 * time.Minute),
+		LateAfter: config.Duration(10 * time.Minute),
+	}
+}
+
+// kdsBoard is the kitchen display's view of the queue
+type kdsBoard struct {
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Stations    []kdsStation   `json:"stations"`
+	Alerts      []alerts.Alert `json:"alerts,omitempty"` // Alert rules broken, shown as warnings
+}
+
+// kdsStation is one station's column. Orders in progress come first, then
+// waiting orders in the order strict priority prepares them.
+type kdsStation struct {
+	Station    string     `json:"station"`
+	Orders     []kdsOrder `json:"orders"`
+	Waitlisted int        `json:"waitlisted"` // Orders held back until the station has room
+
+	// Paused is set while the station's orders are not handed out
+	Paused *manager.StationPause `json:"paused,omitempty"`
+}
+
+type kdsOrder struct {
+	*queue.Token
+	WaitingSeconds int    `json:"waitingSeconds"`
+	Age            string `json:"age"`        // fresh, warn or late
+	Allergy        bool   `json:"allergy"`    // Carries an allergy flag; shown as an alert
+	InProgress     bool   `json:"inProgress"` // A cook has claimed it
+}
+
+// registerKDSRoutes mounts the kitchen display page and its data, which are
+// not found while the kds flag is off. With redaction on, the page passes
+// the key it is opened with, as ?key=, to the board.
+func (s *Server) registerKDSRoutes() {
+	s.handle("GET /v1/kds", s.feature(features.KDS, s.kitchen(s.kdsBoardV1)))
+	if s.cfg.KDS.Enabled {
+		s.handle("GET /kds", s.feature(features.KDS, func(w http.ResponseWriter, r *http.Request) {
+			lang := language(w, r)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			data := kdsPageData{Lang: lang, Messages: i18n.Messages(lang), Brand: s.branding.page()}
+			data.T = func(msg string) string { return i18n.T(lang, msg) }
+			if err := kdsPage.Execute(w, data); err != nil {
+				log.Printf("kds page: %v", err)
+			}
+		}))
+	}
+}
+
+// kdsBoardV1 returns in-progress and waiting orders grouped by station, with
+// how long each has waited
+func (s *Server) kdsBoardV1(w http.ResponseWriter, r *http.Request) {
+	span := opSpan(r, "ListOrders")
+	waiting, _, err := s.om.ListOrders(r.Context())
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	span = opSpan(r, "QueryOrders")
+	inProgress, _, err := s.om.QueryOrders(r.Context(), manager.OrderFilter{Status: queue.StatusInProgress})
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	waiting = append(inProgress, waiting...)
+	span = opSpan(r, "QueryOrders")
+	waitlisted, _, err := s.om.QueryOrders(r.Context(), manager.OrderFilter{Status: queue.StatusWaitlisted})
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	board := s.kdsBoard(waiting, waitlisted, s.om.Now())
+	if s.alerts != nil {
+		board.Alerts = s.alerts.Active()
+	}
+	writeJSON(w, http.StatusOK, board)
+}
+
+func (s *Server) kdsBoard(waiting, waitlisted []*queue.Token, now time.Time) kdsBoard {
+	byStation := make(map[string]*kdsStation)
+	column := func(station string) *kdsStation {
+		c, ok := byStation[station]
+		if !ok {
+			c = &kdsStation{Station: station, Orders: []kdsOrder{}}
+			byStation[station] = c
+		}
+		return c
+	}
+	slices.SortFunc(waiting, func(a, b *queue.Token) int {
+		if a.ClaimedAt != nil || b.ClaimedAt != nil {
+			// Claimed orders first, in claim order
+			switch {
+			case b.ClaimedAt == nil:
+				return -1
+			case a.ClaimedAt == nil:
+				return 1
+			}
+			return a.ClaimedAt.Compare(*b.ClaimedAt)
+		}
+		if queue.Before(a, b) {
+			return -1
+		}
+		return 1
+	})
+	for _, t := range waiting {
+		c := column(t.Station)
+		c.Orders = append(c.Orders, s.kdsOrder(t, now))
+	}
+	for _, t := range waitlisted {
+		column(t.Station).Waitlisted++
+	}
+	for _, p := range s.om.PausedStations() {
+		column(p.Station).Paused = &p
+	}
+
+	board := kdsBoard{GeneratedAt: now, Stations: []kdsStation{}}
+	for _, c := range byStation {
+		board.Stations = append(board.Stations, *c)
+	}
+	slices.SortFunc(board.Stations, func(a, b kdsStation) int { return strings.Compare(a.Station, b.Station) })
+	return board
+}
+
+// kdsOrder flags t by how long it has been in the queue, unless the aging
+// flag is off; a pre-order counts from its release
+func (s *Server) kdsOrder(t *queue.Token, now time.Time) kdsOrder {
+	since := t.Timestamp
+	if t.ReleaseAt != nil && t.ReleaseAt.After(since) {
+		since = *t.ReleaseAt
+	}
+	waited := max(now.Sub(since), 0)
+	age := ageFresh
+	switch {
+	case !s.features.Enabled(features.Aging):
+	case s.cfg.KDS.LateAfter > 0 && waited >= time.Duration(s.cfg.KDS.LateAfter):
+		age = ageLate
+	case s.cfg.KDS.WarnAfter > 0 && waited >= time.Duration(s.cfg.KDS.WarnAfter):
+		age = ageWarn
+	}
+	return kdsOrder{Token: t, WaitingSeconds: int(waited.Seconds()), Age: age, Allergy: t.HasFlag(queue.FlagAllergy),
+		InProgress: t.Status == queue.StatusInProgress}
+}
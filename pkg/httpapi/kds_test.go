@@ -0,0 +1,87 @@
+package httpapi
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+func TestKDSBoard(t *testing.T) {
+	s := newTestServer(t)
+	now := time.Now()
+	tok := func(id string, priority int, station string, age time.Duration) *queue.Token {
+		return &queue.Token{ID: id, Priority: priority, Station: station, Timestamp: now.Add(-age), Status: queue.StatusPreparing}
+	}
+	board := s.kdsBoard([]*queue.Token{
+		tok("1", 2, "grill", time.Minute),
+		tok("2", 1, "grill", 6*time.Minute),
+		tok("3", 1, "cold", 12*time.Minute),
+		tok("4", 0, "", 0),
+	}, []*queue.Token{tok("5", 1, "grill", 0)}, now)
+
+	if len(board.Stations) != 3 {
+		t.Fatalf("stations = %+v", board.Stations)
+	}
+	var got []string
+	for _, st := range board.Stations {
+		got = append(got, st.Station)
+	}
+	if strings.Join(got, ",") != ",cold,grill" {
+		t.Fatalf("station order = %q", got)
+	}
+	grill := board.Stations[2]
+	if len(grill.Orders) != 2 || grill.Orders[0].ID != "2" || grill.Waitlisted != 1 {
+		t.Fatalf("grill = %+v", grill)
+	}
+	if grill.Orders[0].Age != ageWarn || grill.Orders[0].WaitingSeconds != 360 || grill.Orders[1].Age != ageFresh {
+		t.Errorf("grill ages = %+v", grill.Orders)
+	}
+	if board.Stations[1].Orders[0].Age != ageLate {
+		t.Errorf("cold age = %q, want late", board.Stations[1].Orders[0].Age)
+	}
+
+	if rec := do(t, s, http.MethodGet, "/kds"); rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("/kds = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/v1/events?station=grill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	lines := bufio.NewScanner(res.Body)
+	lines.Scan() // Comment sent on connect, after which the client is subscribed
+
+	for _, target := range []string{"/v1/orders?item=tea&priority=1&station=bar", "/v1/orders?item=steak&priority=1&station=grill"} {
+		if res, err := http.Post(srv.URL+target, "", nil); err != nil || res.StatusCode != http.StatusCreated {
+			t.Fatalf("create %s: %v %v", target, res.Status, err)
+		}
+	}
+	var event, data string
+	for lines.Scan() && data == "" {
+		line := lines.Text()
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			event = v
+		}
+		if v, ok := strings.CutPrefix(line, "data: "); ok {
+			data = v
+		}
+	}
+	if event != "created" || !strings.Contains(data, `"item":"steak"`) {
+		t.Fatalf("first event = %s %s", event, data)
+	}
+}
@@ -0,0 +1,99 @@
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/manager"
+)
+
+// Legacy plain-text endpoints kept for hardware that is hard-coded to them.
+// Their routes and output formats must not change; new features go to /v1.
+
+// registerLegacyRoutes mounts the old text endpoints
+func (s *Server) registerLegacyRoutes() {
+	s.handle("/addOrder", s.deprecated("/addOrder", "POST /v1/orders", s.addOrderHandler))
+	s.handle("/prepareOrder", s.deprecated("/prepareOrder", "POST /v1/orders/next", s.prepareOrderHandler))
+	s.handle("/listOrder", s.deprecated("/listOrder", "GET /v1/orders", s.listOrdersHandler))
+}
+
+func (s *Server) addOrderHandler(w http.ResponseWriter, r *http.Request) {
+	item := r.URL.Query().Get("item")
+	priorityStr := r.URL.Query().Get("priority")
+	priority, err := strconv.Atoi(priorityStr)
+	if err != nil {
+		http.Error(w, "Invalid priority", http.StatusBadRequest)
+		return
+	}
+	span := opSpan(r, "AddOrder")
+	token, err := s.om.AddOrder(r.Context(), item, priority)
+	span.Finish(err)
+	var full *manager.CapacityError
+	if errors.As(err, &full) {
+		setRetryAfter(w, full)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	var paused *manager.PausedError
+	if errors.As(err, &paused) {
+		msg := "Ordering paused, please try again later"
+		if paused.Reason != "" {
+			msg = "Ordering paused: " + paused.Reason
+		}
+		http.Error(w, msg, http.StatusServiceUnavailable)
+		return
+	}
+	var closed *manager.ClosedError
+	if errors.As(err, &closed) {
+		msg := "Kitchen closed"
+		if !closed.NextOpen.IsZero() {
+			msg = "Kitchen closed until " + closed.NextOpen.Format("Mon 2 Jan 15:04")
+		}
+		http.Error(w, msg, http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "Order received: ID=%s, Item=%s, Priority=%d\n", token.ID, token.Item, token.Priority)
+}
+
+func (s *Server) prepareOrderHandler(w http.ResponseWriter, r *http.Request) {
+	span := opSpan(r, "PrepareOrder")
+	token, err := s.om.PrepareOrder(r.Context())
+	span.Finish(err)
+	if errors.Is(err, manager.ErrQueueEmpty) {
+		fmt.Fprintln(w, "No orders to prepare")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.record(r, audit.ActionPrepare, token.ID, "", nil)
+	fmt.Fprintf(w, "Order prepared: ID=%s, Item=%s\n", token.ID, token.Item)
+}
+
+func (s *Server) listOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	span := opSpan(r, "ListOrders")
+	preparing, prepared, err := s.om.ListOrders(r.Context())
+	span.Finish(err)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintln(w, "Preparing Orders:")
+	for _, token := range preparing {
+		fmt.Fprintf(w, "ID=%s, Item=%s, Priority=%d\n", token.ID, token.Item, token.Priority)
+	}
+
+	fmt.Fprintln(w, "\nPrepared Orders:")
+	for _, token := range prepared {
+		fmt.Fprintf(w, "ID=%s, Item=%s\n", token.ID, token.Item)
+	}
+}
@@ -0,0 +1,64 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/manager"
+)
+
+func TestLegacyEndpoints(t *testing.T) {
+	s := newTestServer(t)
+
+	steps := []struct {
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/prepareOrder", http.StatusOK, "No orders to prepare\n"},
+		{"/addOrder?item=pizza&priority=abc", http.StatusBadRequest, "Invalid priority\n"},
+		{"/addOrder?item=pizza&priority=2", http.StatusOK, "Order received: ID=1, Item=pizza, Priority=2\n"},
+		{"/addOrder?item=tea&priority=1", http.StatusOK, "Order received: ID=2, Item=tea, Priority=1\n"},
+		{"/prepareOrder", http.StatusOK, "Order prepared: ID=2, Item=tea\n"},
+		{"/listOrder", http.StatusOK, "Preparing Orders:\nID=1, Item=pizza, Priority=2\n\nPrepared Orders:\nID=2, Item=tea\n"},
+	}
+	for _, st := range steps {
+		rec := do(t, s, http.MethodGet, st.target)
+		if rec.Code != st.wantStatus {
+			t.Fatalf("%s: status = %d, want %d", st.target, rec.Code, st.wantStatus)
+		}
+		if got := rec.Body.String(); got != st.wantBody {
+			t.Fatalf("%s: body = %q, want %q", st.target, got, st.wantBody)
+		}
+	}
+}
+
+func TestLegacyRoutesDisabled(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LegacyRoutes = false
+	s := New(manager.New(manager.DefaultConfig()), cfg)
+
+	for _, target := range []string{"/addOrder?item=a&priority=1", "/prepareOrder", "/listOrder"} {
+		if rec := do(t, s, http.MethodGet, target); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want %d", target, rec.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestLegacyAddOrderAtCapacity(t *testing.T) {
+	mcfg := manager.DefaultConfig()
+	mcfg.MaxPending = 1
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(manager.New(mcfg), cfg)
+
+	do(t, s, http.MethodGet, "/addOrder?item=pizza&priority=1")
+	rec := do(t, s, http.MethodGet, "/addOrder?item=tea&priority=1")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("status = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(rec.Body.String(), "estimated wait 3m0s") {
+		t.Fatalf("body = %q", rec.Body)
+	}
+}
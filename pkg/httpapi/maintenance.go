@@ -0,0 +1,86 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/validate"
+)
+
+// maxPauseReason bounds the reason shown to customers while ordering is paused
+const maxPauseReason = 200
+
+// registerMaintenanceRoutes mounts the ordering status for kiosks and, for
+// admins, pausing and resuming ordering
+func (s *Server) registerMaintenanceRoutes() {
+	s.handle("GET /v1/maintenance", s.maintenanceV1)
+	if s.cfg.Admin.Token == "" {
+		return
+	}
+	s.handle("PUT /v1/admin/maintenance", s.admin(s.pauseOrderingV1))
+	s.handle("DELETE /v1/admin/maintenance", s.admin(s.resumeOrderingV1))
+}
+
+// maintenanceStatus is the JSON payload for whether orders are taken
+type maintenanceStatus struct {
+	Paused bool       `json:"paused"`
+	Reason string     `json:"reason,omitempty"`
+	Since  *time.Time `json:"since,omitempty"`
+
+	// Closed is set outside the opening hours, with when they next open
+	Closed   bool       `json:"closed,omitempty"`
+	NextOpen *time.Time `json:"nextOpen,omitempty"`
+}
+
+func newMaintenanceStatus(p manager.Pause, paused bool) maintenanceStatus {
+	if !paused {
+		return maintenanceStatus{}
+	}
+	return maintenanceStatus{Paused: true, Reason: p.Reason, Since: &p.Since}
+}
+
+// maintenanceV1 reports whether new orders are being turned away, paused or
+// outside the opening hours, for kiosks to say so before the customer orders
+func (s *Server) maintenanceV1(w http.ResponseWriter, r *http.Request) {
+	status := newMaintenanceStatus(s.om.OrderingPaused())
+	if open, next := s.om.NextOpen(s.om.Now()); !open {
+		status.Closed = true
+		if !next.IsZero() {
+			status.NextOpen = &next
+		}
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// pauseOrderingV1 puts the server into maintenance mode: new orders are
+// turned away with the reason given while staff work through the queue
+func (s *Server) pauseOrderingV1(w http.ResponseWriter, r *http.Request) {
+	q, ok := input(w, r, "reason")
+	if !ok {
+		return
+	}
+	reason := strings.TrimSpace(q.Get("reason"))
+	if utf8.RuneCountInString(reason) > maxPauseReason {
+		var errs validate.Errors
+		errs.Add("reason", "must be at most %d characters", maxPauseReason)
+		writeValidationError(w, r, errs)
+		return
+	}
+	p := s.om.PauseOrdering(reason)
+	s.record(r, audit.ActionPause, "", "", map[string]string{"reason": reason})
+	writeJSON(w, http.StatusOK, newMaintenanceStatus(p, true))
+}
+
+// resumeOrderingV1 takes new orders again
+func (s *Server) resumeOrderingV1(w http.ResponseWriter, r *http.Request) {
+	if err := s.om.ResumeOrdering(); err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionResume, "", "", nil)
+	writeJSON(w, http.StatusOK, maintenanceStatus{})
+}
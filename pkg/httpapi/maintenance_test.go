@@ -0,0 +1,138 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/clock"
+	"awesomeProject/pkg/manager"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Token = "t0ken"
+	s := New(manager.New(manager.DefaultConfig()), cfg)
+	admin := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer t0ken")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	do(t, s, http.MethodPost, "/v1/orders?item=soup&priority=1")
+
+	if rec := do(t, s, http.MethodPut, "/v1/admin/maintenance"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("pause without the admin token = %d", rec.Code)
+	}
+	var status maintenanceStatus
+	decode(t, admin(http.MethodPut, "/v1/admin/maintenance?reason=kitchen+closed"), &status)
+	if !status.Paused || status.Reason != "kitchen closed" || status.Since == nil {
+		t.Fatalf("pause = %+v", status)
+	}
+	decode(t, do(t, s, http.MethodGet, "/v1/maintenance"), &status)
+	if !status.Paused {
+		t.Fatalf("status while paused = %+v", status)
+	}
+
+	rec := do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1")
+	var body pausedBody
+	decode(t, rec, &body)
+	if rec.Code != http.StatusServiceUnavailable || body.Error != "ordering is paused: kitchen closed" || body.Since.IsZero() {
+		t.Fatalf("order while paused = %d %s", rec.Code, rec.Body)
+	}
+	rec = do(t, s, http.MethodGet, "/addOrder?item=tea&priority=1")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "Ordering paused: kitchen closed") {
+		t.Fatalf("legacy order while paused = %d %q", rec.Code, rec.Body)
+	}
+	// The kitchen still works through what was ordered
+	if rec := do(t, s, http.MethodPost, "/v1/orders/next"); rec.Code != http.StatusOK {
+		t.Fatalf("prepare while paused = %d", rec.Code)
+	}
+
+	if rec := admin(http.MethodDelete, "/v1/admin/maintenance"); rec.Code != http.StatusOK {
+		t.Fatalf("resume = %d", rec.Code)
+	}
+	if rec := admin(http.MethodDelete, "/v1/admin/maintenance"); rec.Code != http.StatusConflict {
+		t.Fatalf("resume twice = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1"); rec.Code != http.StatusCreated {
+		t.Fatalf("order after resuming = %d", rec.Code)
+	}
+}
+
+func TestOpeningHours(t *testing.T) {
+	open := time.Now().Add(2 * time.Hour).Truncate(time.Minute)
+	mcfg := manager.DefaultConfig()
+	mcfg.Hours = manager.Hours{strings.ToLower(open.Weekday().String()): {open.Format("15:04") + "-" + open.Add(time.Hour).Format("15:04")}}
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(manager.New(mcfg), cfg)
+
+	var status maintenanceStatus
+	decode(t, do(t, s, http.MethodGet, "/v1/maintenance"), &status)
+	if !status.Closed || status.NextOpen == nil || !status.NextOpen.Equal(open) {
+		t.Fatalf("status while closed = %+v", status)
+	}
+	rec := do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1")
+	var body closedBody
+	decode(t, rec, &body)
+	if rec.Code != http.StatusServiceUnavailable || body.NextOpen == nil || !body.NextOpen.Equal(open) || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("order while closed = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodGet, "/addOrder?item=tea&priority=1"); rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "Kitchen closed until") {
+		t.Fatalf("legacy order while closed = %d %q", rec.Code, rec.Body)
+	}
+	readyAt := open.Add(10 * time.Minute).Format(time.RFC3339)
+	if rec := do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1&readyAt="+readyAt); rec.Code != http.StatusCreated {
+		t.Fatalf("pre-order for opening = %d %s", rec.Code, rec.Body)
+	}
+}
+
+// Opening hours, Retry-After and the kitchen display's waits all go by the
+// manager's clock, not the wall clock
+func TestHoursUseManagerClock(t *testing.T) {
+	start := time.Date(2020, time.March, 2, 8, 0, 0, 0, time.UTC) // A Monday
+	fake := clock.NewFake(start)
+	mcfg := manager.DefaultConfig()
+	mcfg.Hours = manager.Hours{"monday": {"10:00-11:00"}}
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(manager.New(mcfg, manager.WithClock(fake)), cfg)
+
+	var status maintenanceStatus
+	decode(t, do(t, s, http.MethodGet, "/v1/maintenance"), &status)
+	if open := start.Add(2 * time.Hour); !status.Closed || status.NextOpen == nil || !status.NextOpen.Equal(open) {
+		t.Fatalf("status at 08:00 = %+v", status)
+	}
+	rec := do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "7200" {
+		t.Fatalf("order at 08:00 = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	fake.Advance(2*time.Hour + 5*time.Minute)
+	var opened maintenanceStatus
+	decode(t, do(t, s, http.MethodGet, "/v1/maintenance"), &opened)
+	if opened.Closed {
+		t.Fatalf("status at 10:05 = %+v", opened)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1"); rec.Code != http.StatusCreated {
+		t.Fatalf("order at 10:05 = %d %s", rec.Code, rec.Body)
+	}
+	fake.Advance(10 * time.Minute)
+	var board struct {
+		GeneratedAt time.Time `json:"generatedAt"`
+		Stations    []struct {
+			Orders []struct {
+				WaitingSeconds int `json:"waitingSeconds"`
+			} `json:"orders"`
+		} `json:"stations"`
+	}
+	decode(t, do(t, s, http.MethodGet, "/v1/kds"), &board)
+	if len(board.Stations) != 1 || len(board.Stations[0].Orders) != 1 || board.Stations[0].Orders[0].WaitingSeconds != 600 || !board.GeneratedAt.Equal(fake.Now()) {
+		t.Fatalf("board = %+v", board)
+	}
+}
@@ -0,0 +1,57 @@
+package httpapi
+
+import (
+	"log"
+	"net/http"
+
+	"awesomeProject/pkg/fairness"
+)
+
+// WithFairness serves the wait histograms t keeps at /metrics and in the
+// fairness section of /v1/stats
+func WithFairness(t *fairness.Tracker) Option {
+	return func(s *Server) { s.fairness = t }
+}
+
+// registerMetricsRoutes mounts the Prometheus endpoint, when there is
+// something to serve there
+func (s *Server) registerMetricsRoutes() {
+	if s.fairness == nil {
+		return
+	}
+	s.handle("GET /metrics", s.metricsHandler)
+}
+
+// metricsHandler serves the wait histograms, starvation gauges and stream
+// clients' dropped events in the Prometheus text format
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := s.fairnessReport(r)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := fairness.WritePrometheus(w, *report); err != nil {
+		log.Printf("write metrics: %v", err)
+		return
+	}
+	if err := s.stream.writeMetrics(w); err != nil {
+		log.Printf("write metrics: %v", err)
+	}
+}
+
+// fairnessReport reports on the waits recorded so far and the orders waiting
+// now, or nil without a tracker
+func (s *Server) fairnessReport(r *http.Request) (*fairness.Report, error) {
+	if s.fairness == nil {
+		return nil, nil
+	}
+	span := opSpan(r, "ListOrders")
+	waiting, _, err := s.om.ListOrders(r.Context())
+	span.Finish(err)
+	if err != nil {
+		return nil, err
+	}
+	report := s.fairness.Report(waiting, s.om.Now())
+	return &report, nil
+}
@@ -0,0 +1,42 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/fairness"
+	"awesomeProject/pkg/manager"
+)
+
+func TestMetrics(t *testing.T) {
+	if rec := do(t, newTestServer(t), http.MethodGet, "/metrics"); rec.Code != http.StatusNotFound {
+		t.Errorf("metrics without a tracker = %d", rec.Code)
+	}
+
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	om := manager.New(manager.DefaultConfig())
+	waits := fairness.New(fairness.DefaultConfig())
+	waits.Attach(om)
+	s := New(om, cfg, WithFairness(waits))
+	do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=2")
+	do(t, s, http.MethodPost, "/v1/orders?item=soup&priority=4")
+	do(t, s, http.MethodPost, "/v1/orders/next")
+
+	rec := do(t, s, http.MethodGet, "/metrics")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("metrics = %d %s", rec.Code, rec.Header())
+	}
+	for _, want := range []string{`orders_wait_seconds_count{priority="2"} 1`, `orders_waiting{priority="4"} 1`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("missing %q in\n%s", want, rec.Body)
+		}
+	}
+
+	var stats statsBody
+	decode(t, do(t, s, http.MethodGet, "/v1/stats"), &stats)
+	if stats.Orders != 2 || stats.Fairness == nil || len(stats.Fairness.Classes) != 2 || stats.Fairness.Classes[0].Prepared != 1 {
+		t.Errorf("stats = %+v, fairness %+v", stats.Report, stats.Fairness)
+	}
+}
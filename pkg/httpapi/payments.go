@@ -0,0 +1,150 @@
+package httpapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/queue"
+	"awesomeProject/pkg/validate"
+)
+
+// maxWebhookBody bounds the payment callbacks read
+const maxWebhookBody = 1 << 20
+
+// PaymentsConfig configures the payment provider webhook. The webhook is
+// only served when WebhookSecret is set.
+type PaymentsConfig struct {
+	WebhookSecret   string `json:"webhookSecret"`   // Shared secret for the HMAC-SHA256 body signature
+	SignatureHeader string `json:"signatureHeader"` // Header carrying the hex signature, optionally prefixed "sha256="
+}
+
+// DefaultPaymentsConfig returns the settings used when nothing is configured
+func DefaultPaymentsConfig() PaymentsConfig {
+	return PaymentsConfig{SignatureHeader: "X-Signature"}
+}
+
+// registerPaymentRoutes mounts the payment endpoints
+func (s *Server) registerPaymentRoutes() {
+	s.handle("POST /v1/orders/{id}/payment", s.setPaymentV1)
+	if s.cfg.Payments.WebhookSecret != "" {
+		s.handle("POST /v1/payments/webhook", s.paymentWebhook)
+	}
+}
+
+// setPaymentV1 records a payment taken at the till: status (paid or
+// refunded) and an optional reference
+func (s *Server) setPaymentV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	q, ok := input(w, r, "status", "reference")
+	if !ok {
+		return
+	}
+	var errs validate.Errors
+	status := q.Get("status")
+	paymentChange(&errs, status)
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	span := opSpan(r, "SetPayment")
+	token, err := s.om.SetPayment(r.Context(), id, status, q.Get("reference"))
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionPayment, token.ID, "", params(q, "status", "reference"))
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+// paymentChange checks a requested payment status
+func paymentChange(errs *validate.Errors, status string) {
+	switch status {
+	case queue.PaymentPaid, queue.PaymentRefunded:
+	case "":
+		errs.Add("status", "is required")
+	default:
+		errs.Add("status", "must be %s or %s", queue.PaymentPaid, queue.PaymentRefunded)
+	}
+}
+
+// paymentCallback is the webhook payload
+type paymentCallback struct {
+	OrderID   callbackID `json:"orderId"`
+	Status    string     `json:"status"`
+	Reference string     `json:"reference"`
+}
+
+// callbackID is the order ID in a callback: a string, or a number from
+// providers set up when IDs were numbers
+type callbackID string
+
+func (id *callbackID) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		*id = callbackID(n)
+		return nil
+	}
+	return json.Unmarshal(data, (*string)(id))
+}
+
+// paymentWebhook applies a signed callback from the payment provider
+func (s *Server) paymentWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	if !s.validSignature(body, r.Header.Get(s.cfg.Payments.SignatureHeader)) {
+		writeError(w, r, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+
+	var cb paymentCallback
+	if err := json.Unmarshal(body, &cb); err != nil {
+		writeErrorf(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	var errs validate.Errors
+	switch {
+	case cb.OrderID == "":
+		errs.Add("orderId", "is required")
+	case !validID(string(cb.OrderID)):
+		errs.Malformed("orderId", "is not an order ID")
+	}
+	paymentChange(&errs, cb.Status)
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+
+	span := opSpan(r, "SetPayment")
+	token, err := s.om.SetPayment(r.Context(), string(cb.OrderID), cb.Status, cb.Reference)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+// validSignature checks sig against the HMAC-SHA256 of body
+func (s *Server) validSignature(body []byte, sig string) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(s.cfg.Payments.WebhookSecret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
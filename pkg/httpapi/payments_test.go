@@ -0,0 +1,61 @@
+package httpapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestPaymentWebhook(t *testing.T) {
+	mcfg := manager.DefaultConfig()
+	mcfg.RequirePayment = true
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Payments.WebhookSecret = "s3cret"
+	s := New(manager.New(mcfg), cfg)
+
+	rec := do(t, s, http.MethodPost, "/v1/orders?item=soup&priority=1")
+	var created queue.Token
+	decode(t, rec, &created)
+	if rec.Code != http.StatusCreated || created.Status != queue.StatusAwaitingPayment {
+		t.Fatalf("create = %d %+v", rec.Code, created)
+	}
+
+	callback := func(body, sig string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/payments/webhook", strings.NewReader(body))
+		req.Header.Set("X-Signature", sig)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	body := `{"orderId":1,"status":"paid","reference":"ch_1"}`
+	if rec := callback(body, "sha256=00"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("bad signature = %d %s", rec.Code, rec.Body)
+	}
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(body))
+	rec = callback(body, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	var paid queue.Token
+	decode(t, rec, &paid)
+	if rec.Code != http.StatusOK || paid.Status != queue.StatusPreparing || paid.PaymentRef != "ch_1" {
+		t.Fatalf("webhook = %d %+v", rec.Code, paid)
+	}
+
+	if rec := do(t, s, http.MethodPost, "/v1/orders/1/payment?status=paid"); rec.Code != http.StatusOK {
+		t.Fatalf("repeat payment = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders/1/payment?status=unpaid"); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid status = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodGet, "/v1/orders?payment=paid"); !strings.Contains(rec.Body.String(), `"ch_1"`) {
+		t.Fatalf("payment filter = %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/privacy"
+	"awesomeProject/pkg/validate"
+)
+
+// WithPrivacy serves erasing a customer's personal data through p
+func WithPrivacy(p *privacy.Purger) Option {
+	return func(s *Server) { s.privacy = p }
+}
+
+// registerPrivacyRoutes mounts the erasure endpoint, when there are managers
+// or an admin to use it
+func (s *Server) registerPrivacyRoutes() {
+	if s.privacy == nil || (s.cfg.Admin.Token == "" && len(s.cfg.Admin.Managers) == 0) {
+		return
+	}
+	s.handle("POST /v1/privacy/purge", s.managerOnly(s.purgeCustomerV1))
+}
+
+// purgeCustomerV1 erases the personal data of the customer with the phone
+// number or device token given, from every order, event and audit entry
+// kept. The audit log records the erasure without naming the customer.
+func (s *Server) purgeCustomerV1(w http.ResponseWriter, r *http.Request, by string) {
+	q, ok := input(w, r, "customer")
+	if !ok {
+		return
+	}
+	customer := strings.TrimSpace(q.Get("customer"))
+	if customer == "" {
+		var errs validate.Errors
+		errs.Add("customer", "is required")
+		writeValidationError(w, r, errs)
+		return
+	}
+	span := opSpan(r, "Purge")
+	res, err := s.privacy.Customer(r.Context(), customer)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionPurge, "", by, map[string]string{"orders": strconv.Itoa(len(res.Orders))})
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/privacy"
+)
+
+func TestPurgeCustomer(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Managers = map[string]string{"ana": "m4nager"}
+	om := manager.New(manager.DefaultConfig())
+	trail := openAudit(t)
+	s := New(om, cfg, WithAuditLog(trail), WithPrivacy(privacy.New(privacy.DefaultConfig(), om, nil, nil, trail)))
+	placed := doJSON(t, s, http.MethodPost, "/v1/orders", `{"item":"soup","priority":1,"phone":"+15550100","notes":"for Sam"}`)
+	if placed.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s", placed.Code, placed.Body)
+	}
+	purge := func(body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/privacy/purge", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := purge(`{"customer":"+15550100"}`, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without a manager = %d", rec.Code)
+	}
+	if rec := purge(`{"customer":" "}`, "m4nager"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("without a customer = %d", rec.Code)
+	}
+	rec := purge(`{"customer":"+15550100"}`, "m4nager")
+	var res privacy.Result
+	decode(t, rec, &res)
+	if rec.Code != http.StatusOK || len(res.Orders) != 1 {
+		t.Fatalf("purge = %d %s", rec.Code, rec.Body)
+	}
+	if body := do(t, s, http.MethodGet, "/v1/orders/"+res.Orders[0]).Body.String(); strings.Contains(body, "+15550100") || strings.Contains(body, "for Sam") {
+		t.Errorf("after purge: %s", body)
+	}
+	if rec := do(t, s, http.MethodGet, "/v1/audit?action=privacy_purge"); !strings.Contains(rec.Body.String(), `"orders":"1"`) || strings.Contains(rec.Body.String(), "+15550100") {
+		t.Errorf("audit = %s", rec.Body)
+	}
+
+	// Not served without a purger
+	if rec := do(t, New(om, cfg), http.MethodPost, "/v1/privacy/purge"); rec.Code != http.StatusNotFound {
+		t.Errorf("without a purger = %d", rec.Code)
+	}
+}
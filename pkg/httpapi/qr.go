@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"awesomeProject/pkg/qrcode"
+	"awesomeProject/pkg/queue"
+)
+
+// pickupPayload is the text a receipt's QR code holds: the order ID and its
+// pickup code, which a counter scanner passes to the pickup endpoint
+func pickupPayload(t *queue.Token) string {
+	return fmt.Sprintf("%s:%s", t.ID, t.PickupCode)
+}
+
+// pickupQRV1 renders an order's pickup QR code as a PNG for its receipt.
+// scale sets the pixels per module, 8 by default.
+func (s *Server) pickupQRV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	scale := 8
+	if v := r.URL.Query().Get("scale"); v != "" {
+		if scale, err = strconv.Atoi(v); err != nil || scale < 1 || scale > 40 {
+			writeError(w, r, http.StatusBadRequest, "scale must be a whole number from 1 to 40")
+			return
+		}
+	}
+	span := opSpan(r, "GetOrder")
+	token, err := s.om.GetOrder(r.Context(), id)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	if token.PickupCode == "" {
+		writeError(w, r, http.StatusNotFound, "order has no pickup code")
+		return
+	}
+	code, err := qrcode.Encode([]byte(pickupPayload(token)))
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var buf bytes.Buffer
+	if err := code.PNG(&buf, scale); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,139 @@
+package httpapi
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit configures a token bucket refilled at Rate tokens per second up to Burst.
+// A zero Rate disables limiting.
+type RateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// RateLimitConfig holds the default limit and per-endpoint overrides keyed by route pattern
+type RateLimitConfig struct {
+	Default   RateLimit            `json:"default"`
+	Endpoints map[string]RateLimit `json:"endpoints"`
+}
+
+// bucketIdle is how long an untouched bucket is kept before it is swept
+const bucketIdle = 10 * time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter is a token-bucket limiter keyed by client
+type RateLimiter struct {
+	limit     RateLimit
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &RateLimiter{
+		limit:   limit,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from key's bucket. When the bucket is empty it reports
+// how long the client has to wait for the next token.
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := rl.now()
+	rl.sweep(now)
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rl.limit.Burst), last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(rl.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*rl.limit.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / rl.limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have been idle long enough to be full again
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < bucketIdle {
+		return
+	}
+	rl.lastSweep = now
+	for key, b := range rl.buckets {
+		if now.Sub(b.last) > bucketIdle {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// Middleware rejects requests over the limit with 429 and a Retry-After
+// header, keeping a bucket for each client key names
+func (rl *RateLimiter) Middleware(key func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := rl.Allow(key(r))
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientKey identifies the caller by API key when it is a registered
+// device's, otherwise by IP, so a client cannot claim a fresh bucket with
+// each key it makes up
+func (s *Server) clientKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" && s.devices != nil {
+		if _, ok := s.devices.Authenticate(key); ok {
+			return "key:" + key
+		}
+	}
+	return "ip:" + remoteIP(r)
+}
+
+// limiter returns the limiter of the route pattern, shared by everything
+// charged against it, or nil when it is unlimited
+func (s *Server) limiter(pattern string) *RateLimiter {
+	if rl, ok := s.limiters[pattern]; ok {
+		return rl
+	}
+	if s.limiters == nil {
+		s.limiters = make(map[string]*RateLimiter)
+	}
+	rl := s.cfg.RateLimits.limitFor(pattern)
+	s.limiters[pattern] = rl
+	return rl
+}
+
+// limitFor returns the limiter for a route pattern, or nil when it is unlimited
+func (c RateLimitConfig) limitFor(pattern string) *RateLimiter {
+	limit, ok := c.Endpoints[pattern]
+	if !ok {
+		limit = c.Default
+	}
+	if limit.Rate <= 0 {
+		return nil
+	}
+	return NewRateLimiter(limit)
+}
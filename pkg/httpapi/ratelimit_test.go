@@ -0,0 +1,124 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/devices"
+	"awesomeProject/pkg/manager"
+)
+
+func TestRateLimiterRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	rl := NewRateLimiter(RateLimit{Rate: 2, Burst: 2})
+	rl.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := rl.Allow("a"); !ok {
+			t.Fatalf("request %d rejected within burst", i)
+		}
+	}
+	ok, wait := rl.Allow("a")
+	if ok {
+		t.Fatal("request beyond burst allowed")
+	}
+	if wait != 500*time.Millisecond {
+		t.Fatalf("wait = %v, want 500ms", wait)
+	}
+	if ok, _ := rl.Allow("b"); !ok {
+		t.Fatal("other client shares a bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := rl.Allow("a"); !ok {
+		t.Fatal("bucket did not refill")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{
+		Endpoints: map[string]RateLimit{"POST /v1/orders": {Rate: 0.001, Burst: 1}},
+	}
+	reg, err := devices.Open(devices.Config{Path: t.TempDir() + "/devices.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, kioskKey, err := reg.Register("kiosk-7", "", devices.RoleKiosk, devices.Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(manager.New(manager.DefaultConfig()), cfg, WithDevices(reg))
+
+	send := func(remote, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/orders?item=a&priority=1", nil)
+		req.RemoteAddr = remote
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := send("10.0.0.1:1000", ""); rec.Code != http.StatusCreated {
+		t.Fatalf("first request status = %d", rec.Code)
+	}
+	rec := send("10.0.0.1:2000", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request status = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatal("missing Retry-After")
+	}
+	if rec := send("10.0.0.2:1000", ""); rec.Code != http.StatusCreated {
+		t.Fatalf("other IP status = %d", rec.Code)
+	}
+	if rec := send("10.0.0.1:1000", kioskKey); rec.Code != http.StatusCreated {
+		t.Fatalf("device key status = %d", rec.Code)
+	}
+
+	// Keys no device holds are limited by IP, however many are tried
+	for i := range 3 {
+		if rec := send("10.0.0.1:1000", fmt.Sprintf("made-up-%d", i)); rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("made-up key %d status = %d, want 429", i, rec.Code)
+		}
+	}
+
+	// Unlimited endpoints are untouched
+	for i := 0; i < 5; i++ {
+		if rec := do(t, s, http.MethodGet, "/v1/orders"); rec.Code != http.StatusOK {
+			t.Fatalf("listing status = %d", rec.Code)
+		}
+	}
+}
+
+func TestGraphQLSharesOrderRateLimit(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.GraphQL = true
+	cfg.RateLimits = RateLimitConfig{
+		Endpoints: map[string]RateLimit{"POST /v1/orders": {Rate: 0.001, Burst: 1}},
+	}
+	s := New(manager.New(manager.DefaultConfig()), cfg)
+	if rec := do(t, s, http.MethodPost, "/v1/orders?item=a&priority=1"); rec.Code != http.StatusCreated {
+		t.Fatalf("first order = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders?item=a&priority=1"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second order = %d, want 429", rec.Code)
+	}
+
+	// Held back on REST, the same client is held back through GraphQL
+	rec := doJSON(t, s, http.MethodPost, "/graphql", `{"query":"mutation { addOrder(item: \"a\", priority: 1) { id } }"}`)
+	var res graphQLResult
+	decode(t, rec, &res)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["status"] != float64(http.StatusTooManyRequests) || res.Errors[0].Extensions["retryAfter"] == nil {
+		t.Fatalf("addOrder over the limit = %+v", res)
+	}
+	if orders, _, _ := s.om.ListOrders(context.Background()); len(orders) != 1 {
+		t.Errorf("orders placed = %d, want 1", len(orders))
+	}
+}
@@ -0,0 +1,128 @@
+package httpapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// ReceiptConfig signs the order-status links printed on customer receipts.
+// With no secret the links and the status page are not served.
+type ReceiptConfig struct {
+	// Secret keys the HMAC-SHA256 of each order's ID. Changing it
+	// invalidates every link handed out.
+	Secret string `json:"secret"`
+}
+
+// sigLength is the bytes of the HMAC kept in a signed ID
+const sigLength = 16
+
+// registerReceiptRoutes mounts the status link for each order, the
+// customer status it opens and the tracking page that follows it
+func (s *Server) registerReceiptRoutes() {
+	if s.cfg.Receipts.Secret == "" {
+		return
+	}
+	s.handle("GET /v1/orders/{id}/receipt", s.till(s.receiptV1))
+	s.handle("GET /status", s.statusHandler)
+	s.handle("GET /t/{signed}", s.trackPageHandler)
+	s.handleStream("GET /t/{signed}/events", s.trackEvents)
+}
+
+// signID returns id with its signature, as "<id>.<signature>"; IDs never
+// hold a dot
+func (s *Server) signID(id string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.Receipts.Secret))
+	mac.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:sigLength])
+}
+
+// verifyID returns the order ID signed in signed, or false when the
+// signature does not match it
+func (s *Server) verifyID(signed string) (string, bool) {
+	id, _, ok := strings.Cut(signed, ".")
+	if !ok || !validID(id) {
+		return "", false
+	}
+	return id, hmac.Equal([]byte(s.signID(id)), []byte(signed))
+}
+
+// statusURL is the path of the status page for id
+func (s *Server) statusURL(id string) string {
+	return "/status?t=" + url.QueryEscape(s.signID(id))
+}
+
+// receiptBody is an order's signed ID and the links made from it
+type receiptBody struct {
+	Signed    string `json:"signed"`
+	StatusURL string `json:"statusUrl"`
+	TrackURL  string `json:"trackUrl"` // Page for the customer's phone
+}
+
+// receiptV1 returns the signed status link for an order's receipt, for the
+// till to print or encode in a QR code. Only staff and kiosks are served it,
+// as the link opens the order for anyone holding it.
+func (s *Server) receiptV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	span := opSpan(r, "GetOrder")
+	token, err := s.om.GetOrder(r.Context(), id)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, receiptBody{Signed: s.signID(token.ID), StatusURL: s.statusURL(token.ID), TrackURL: s.trackPath(token.ID)})
+}
+
+// orderStatus is what the status page shows the customer: enough to follow
+// the order, and nothing that would help someone else collect it
+type orderStatus struct {
+	Number           int        `json:"number,omitempty"`
+	Item             string     `json:"item"`
+	Quantity         int        `json:"quantity"`
+	Status           string     `json:"status"`
+	Position         int        `json:"position,omitempty"`
+	EstimatedReadyAt *time.Time `json:"estimatedReadyAt,omitempty"`
+	ReadyAt          *time.Time `json:"readyAt,omitempty"`
+	PreparedAt       *time.Time `json:"preparedAt,omitempty"`
+	PickedUpAt       *time.Time `json:"pickedUpAt,omitempty"`
+}
+
+// customerStatus is token as the customer sees it
+func customerStatus(token *queue.Token) orderStatus {
+	return orderStatus{
+		Number: token.Number, Item: token.Item, Quantity: token.Quantity, Status: token.Status,
+		Position: token.Position, EstimatedReadyAt: token.EstimatedReadyAt, ReadyAt: token.ReadyAt,
+		PreparedAt: token.PreparedAt, PickedUpAt: token.PickedUpAt,
+	}
+}
+
+// statusHandler shows the order signed in t to whoever holds the link. An
+// ID whose signature does not match is refused, so links can be neither
+// guessed nor made from another order's.
+func (s *Server) statusHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.verifyID(r.URL.Query().Get("t"))
+	if !ok {
+		writeError(w, r, http.StatusForbidden, "invalid status link")
+		return
+	}
+	span := opSpan(r, "GetOrder")
+	token, err := s.om.GetOrder(r.Context(), id)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, customerStatus(token))
+}
@@ -0,0 +1,83 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/devices"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestStatusLinks(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Receipts.Secret = "secret"
+	cfg.Roles.KitchenTokens = map[string]string{"till": "k1tchen"}
+	reg, err := devices.Open(devices.Config{Path: t.TempDir() + "/devices.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, kioskKey, err := reg.Register("kiosk-1", "", devices.RoleKiosk, devices.Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(manager.New(manager.DefaultConfig()), cfg, WithDevices(reg))
+
+	var first, second queue.Token
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=latte&priority=1&notes=for+Sam"), &first)
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1"), &second)
+
+	// Anyone holding a link can follow the order, so only the till and
+	// kiosks are handed them
+	if rec := do(t, s, http.MethodGet, "/v1/orders/"+first.ID+"/receipt"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("public receipt = %d %s", rec.Code, rec.Body)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders/"+first.ID+"/receipt", nil)
+	req.Header.Set("X-API-Key", kioskKey)
+	kiosk := httptest.NewRecorder()
+	s.ServeHTTP(kiosk, req)
+	var receipt receiptBody
+	decode(t, doAs(t, s, http.MethodGet, "/v1/orders/"+first.ID+"/receipt", "k1tchen"), &receipt)
+	if kiosk.Code != http.StatusOK || !strings.Contains(kiosk.Body.String(), receipt.Signed) {
+		t.Fatalf("kiosk receipt = %d %s", kiosk.Code, kiosk.Body)
+	}
+	if !strings.HasPrefix(receipt.Signed, first.ID+".") || receipt.StatusURL != "/status?t="+url.QueryEscape(receipt.Signed) {
+		t.Fatalf("receipt = %+v", receipt)
+	}
+
+	rec := do(t, s, http.MethodGet, receipt.StatusURL)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status page: %d %s", rec.Code, rec.Body)
+	}
+	var status orderStatus
+	decode(t, rec, &status)
+	if status.Item != "latte" || status.Status != queue.StatusPreparing || strings.Contains(rec.Body.String(), "Sam") ||
+		strings.Contains(rec.Body.String(), first.PickupCode) {
+		t.Errorf("status = %s", rec.Body)
+	}
+
+	_, sig, _ := strings.Cut(receipt.Signed, ".")
+	tampered := "A" + sig[1:]
+	if tampered == sig {
+		tampered = "B" + sig[1:]
+	}
+	for _, forged := range []string{
+		"",
+		first.ID,
+		second.ID + "." + sig, // Another order's ID under this one's signature
+		first.ID + "." + tampered,
+		first.ID + ".",
+	} {
+		if rec := do(t, s, http.MethodGet, "/status?t="+url.QueryEscape(forged)); rec.Code != http.StatusForbidden {
+			t.Errorf("%q: status = %d, want 403", forged, rec.Code)
+		}
+	}
+
+	if rec := do(t, newTestServer(t), http.MethodGet, receipt.StatusURL); rec.Code != http.StatusNotFound {
+		t.Errorf("without a secret: status = %d, want 404", rec.Code)
+	}
+}
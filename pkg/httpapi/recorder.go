@@ -0,0 +1,310 @@
+package httpapi
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+	"awesomeProject/pkg/validate"
+)
+
+// RecorderConfig turns on the flight recorder, which keeps the latest
+// requests with the order changes each made, and the changes the manager
+// made on its own, for admins to dump when the queue is found in a state
+// nobody can explain
+type RecorderConfig struct {
+	// Entries is how many requests and changes are kept, the newest
+	// replacing the oldest; zero turns the recorder off
+	Entries int `json:"entries"`
+
+	// MaxBody bounds the request and response bodies kept, in bytes
+	MaxBody int `json:"maxBody"`
+}
+
+// DefaultRecorderConfig returns the recorder settings used when nothing is
+// configured
+func DefaultRecorderConfig() RecorderConfig {
+	return RecorderConfig{MaxBody: 4 << 10}
+}
+
+// recordedHeaders are the request headers kept; credentials never are
+var recordedHeaders = []string{"Content-Type", "If-Match", "Idempotency-Key", StaffHeader, "User-Agent"}
+
+// redactedFields are the query parameters and JSON body fields holding
+// customer contact details and secrets, kept only as redacted
+var redactedFields = []string{"phone", "deviceToken", "customer", "pickupCode", "code", "t", "token", "secret", "password"}
+
+// redacted replaces the values of redactedFields
+const redacted = "[redacted]"
+
+// recording is one entry of the flight recorder: a request served, or a
+// change the manager made without one
+type recording struct {
+	Seq     int64            `json:"seq"` // Numbers entries in the order they began
+	At      time.Time        `json:"at"`
+	Request *recordedRequest `json:"request,omitempty"`
+	Event   *recordedEvent   `json:"event,omitempty"`
+}
+
+type recordedRequest struct {
+	Method         string            `json:"method"`
+	Path           string            `json:"path"`
+	Query          string            `json:"query,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	Status         int               `json:"status"`
+	DurationMillis float64           `json:"durationMillis"`
+	Response       string            `json:"response,omitempty"`
+	Truncated      bool              `json:"truncated,omitempty"` // A body was longer than MaxBody
+	Events         []recordedEvent   `json:"events"`              // The changes the request made, in order
+}
+
+type recordedEvent struct {
+	Type  string       `json:"type"`
+	At    time.Time    `json:"at"`
+	Token *queue.Token `json:"token"` // The order after the change, contact details removed
+}
+
+// recorder is the flight recorder's ring of entries
+type recorder struct {
+	maxBody int
+
+	mu       sync.Mutex
+	entries  []*recording // Ring; next is the oldest once it is full
+	next     int
+	seq      int64
+	dropped  int64                 // Entries overwritten
+	inFlight map[string]*recording // Requests being served, by cause
+}
+
+func newRecorder(cfg RecorderConfig) *recorder {
+	return &recorder{
+		maxBody:  cmp.Or(max(cfg.MaxBody, 0), DefaultRecorderConfig().MaxBody),
+		entries:  make([]*recording, 0, cfg.Entries),
+		inFlight: make(map[string]*recording),
+	}
+}
+
+// add puts e in the ring; mu must be held
+func (rec *recorder) add(e *recording) {
+	if len(rec.entries) < cap(rec.entries) {
+		rec.entries = append(rec.entries, e)
+		return
+	}
+	rec.entries[rec.next] = e
+	rec.next = (rec.next + 1) % len(rec.entries)
+	rec.dropped++
+}
+
+// event records e with the request that caused it, or as an entry of its
+// own for the manager's own work. It runs as a manager listener.
+func (rec *recorder) event(e manager.Event) {
+	re := recordedEvent{Type: e.Type, At: e.At, Token: redactToken(e.Token)}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if r, ok := rec.inFlight[e.Cause]; ok {
+		r.Request.Events = append(r.Request.Events, re)
+		return
+	}
+	rec.seq++
+	rec.add(&recording{Seq: rec.seq, At: e.At, Event: &re})
+}
+
+// recorded records each request h serves, apart from subscriptions, with
+// the order changes made while serving it
+func (s *Server) recorded(h http.Handler) http.Handler {
+	rec := s.recorder
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			h.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		req := &recordedRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  redactQuery(r.URL.Query()),
+			Events: []recordedEvent{},
+		}
+		for _, name := range recordedHeaders {
+			if v := r.Header.Get(name); v != "" {
+				if req.Headers == nil {
+					req.Headers = make(map[string]string)
+				}
+				req.Headers[name] = v
+			}
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			// Read what is kept, then hand the handler the whole body
+			head, _ := io.ReadAll(io.LimitReader(r.Body, int64(rec.maxBody)+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+			req.Body, req.Truncated = rec.keep(head)
+		}
+
+		rec.mu.Lock()
+		rec.seq++
+		entry := &recording{Seq: rec.seq, At: start, Request: req}
+		cause := "request-" + strconv.FormatInt(entry.Seq, 10)
+		rec.inFlight[cause] = entry
+		rec.mu.Unlock()
+
+		cw := &captureWriter{statusRecorder: statusRecorder{ResponseWriter: w}, max: rec.maxBody}
+		defer func() {
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			delete(rec.inFlight, cause)
+			req.Status = cmp.Or(cw.status, http.StatusOK)
+			req.DurationMillis = float64(time.Since(start).Microseconds()) / 1000
+			var truncated bool
+			req.Response, truncated = rec.keep(cw.body.Bytes())
+			req.Truncated = req.Truncated || truncated || cw.over
+			rec.add(entry)
+		}()
+		h.ServeHTTP(cw, r.WithContext(manager.WithCause(r.Context(), cause)))
+	})
+}
+
+// keep returns the part of body the recorder keeps, redacted, and whether
+// it was cut short
+func (rec *recorder) keep(body []byte) (string, bool) {
+	truncated := len(body) > rec.maxBody
+	if truncated {
+		return string(body[:rec.maxBody]), true
+	}
+	return redactJSON(body), false
+}
+
+// dump returns the entries oldest first
+func (rec *recorder) dump() recorderDump {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	d := recorderDump{Capacity: cap(rec.entries), Dropped: rec.dropped, Entries: make([]recording, 0, len(rec.entries))}
+	for _, e := range rec.entries {
+		c := *e
+		if e.Request != nil {
+			req := *e.Request
+			req.Events = slices.Clone(req.Events)
+			c.Request = &req
+		}
+		d.Entries = append(d.Entries, c)
+	}
+	// Requests are added as they finish, so entries may not be in the ring
+	// in the order they began
+	slices.SortFunc(d.Entries, func(a, b recording) int { return int(a.Seq - b.Seq) })
+	return d
+}
+
+// clear empties the ring
+func (rec *recorder) clear() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.entries = rec.entries[:0]
+	rec.next = 0
+}
+
+// captureWriter keeps the start of the response as it is written
+type captureWriter struct {
+	statusRecorder
+	max  int
+	body bytes.Buffer
+	over bool // The response was longer than max
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if room := w.max - w.body.Len(); room > 0 {
+		w.body.Write(b[:min(room, len(b))])
+	}
+	if w.body.Len()+len(b) > w.max {
+		w.over = true
+	}
+	return w.statusRecorder.Write(b)
+}
+
+// redactQuery encodes q with the redacted fields' values replaced
+func redactQuery(q url.Values) string {
+	for _, name := range redactedFields {
+		if q.Has(name) {
+			q.Set(name, redacted)
+		}
+	}
+	return q.Encode()
+}
+
+// redactJSON returns body with the redacted fields of a JSON object
+// replaced, or as it is when it is not one
+func redactJSON(body []byte) string {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(body, &obj) != nil {
+		return string(body)
+	}
+	changed := false
+	for _, name := range redactedFields {
+		if _, ok := obj[name]; ok {
+			obj[name] = json.RawMessage(`"` + redacted + `"`)
+			changed = true
+		}
+	}
+	if !changed {
+		return string(body)
+	}
+	out, err := json.Marshal(obj)
+	if err != nil {
+		return string(body)
+	}
+	return string(out)
+}
+
+// redactToken returns a copy of t without the customer's contact details,
+// address and pickup code
+func redactToken(t *queue.Token) *queue.Token {
+	c := t.Clone()
+	for _, f := range []*string{&c.Phone, &c.DeviceToken, &c.PickupCode, &c.Client} {
+		if *f != "" {
+			*f = redacted
+		}
+	}
+	return c
+}
+
+// recorderDump is the JSON payload of GET /v1/admin/recorder
+type recorderDump struct {
+	Capacity int         `json:"capacity"`
+	Dropped  int64       `json:"dropped"` // Entries overwritten since the server started
+	Entries  []recording `json:"entries"` // Oldest first
+}
+
+// dumpRecorderV1 serves the flight recorder's entries, those numbered after
+// since when it is given
+func (s *Server) dumpRecorderV1(w http.ResponseWriter, r *http.Request) {
+	var errs validate.Errors
+	since := intParam(r.URL.Query(), "since", &errs)
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	d := s.recorder.dump()
+	if since != nil {
+		d.Entries = slices.DeleteFunc(d.Entries, func(e recording) bool { return e.Seq <= int64(*since) })
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, d)
+}
+
+// clearRecorderV1 empties the flight recorder
+func (s *Server) clearRecorderV1(w http.ResponseWriter, r *http.Request) {
+	s.recorder.clear()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,86 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/manager"
+)
+
+func TestRecorder(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Token = "t0ken"
+	cfg.Recorder.Entries = 4
+	om := manager.New(manager.DefaultConfig())
+	s := New(om, cfg)
+	admin := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer t0ken")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	dump := func(target string) recorderDump {
+		t.Helper()
+		var d recorderDump
+		decode(t, admin(http.MethodGet, target), &d)
+		return d
+	}
+
+	doJSON(t, s, http.MethodPost, "/v1/orders", `{"item":"soup","priority":2,"phone":"+15550100"}`)
+	if _, err := om.PlaceOrder(context.Background(), manager.NewOrder{Item: "tea", Priority: 1}); err != nil {
+		t.Fatal(err)
+	}
+	do(t, s, http.MethodPost, "/v1/orders/next")
+
+	d := dump("/v1/admin/recorder")
+	if d.Capacity != 4 || len(d.Entries) != 3 {
+		t.Fatalf("dump = %+v", d)
+	}
+	placed := d.Entries[0].Request
+	if placed == nil || placed.Method != http.MethodPost || placed.Status != http.StatusCreated || len(placed.Events) != 1 ||
+		placed.Events[0].Type != manager.EventCreated || placed.Events[0].Token.Phone != redacted {
+		t.Fatalf("placed = %+v", d.Entries[0])
+	}
+	if strings.Contains(placed.Body, "+15550100") || strings.Contains(placed.Response, "+15550100") {
+		t.Errorf("phone recorded: %s %s", placed.Body, placed.Response)
+	}
+	if e := d.Entries[1]; e.Request != nil || e.Event == nil || e.Event.Token.Item != "tea" {
+		t.Errorf("manager's own change = %+v", e)
+	}
+	if next := d.Entries[2].Request; next == nil || next.Path != "/v1/orders/next" || len(next.Events) != 1 || next.Events[0].Token.Item != "tea" {
+		t.Errorf("next = %+v", d.Entries[2])
+	}
+
+	// Dumping is not recorded; older entries give way to newer
+	for range 3 {
+		do(t, s, http.MethodGet, "/v1/orders?status=waiting")
+	}
+	d = dump("/v1/admin/recorder")
+	if len(d.Entries) != 4 || d.Dropped != 2 || d.Entries[0].Seq != 3 {
+		t.Fatalf("after overflow = %+v", d)
+	}
+	if d = dump("/v1/admin/recorder?since=5"); len(d.Entries) != 1 || d.Entries[0].Seq != 6 {
+		t.Errorf("since 5 = %+v", d.Entries)
+	}
+	if rec := admin(http.MethodGet, "/v1/admin/recorder?since=x"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad since = %d", rec.Code)
+	}
+
+	if rec := admin(http.MethodDelete, "/v1/admin/recorder"); rec.Code != http.StatusNoContent {
+		t.Fatalf("clear = %d", rec.Code)
+	}
+	if d = dump("/v1/admin/recorder"); len(d.Entries) != 0 {
+		t.Errorf("after clear = %+v", d.Entries)
+	}
+
+	// Off unless entries are configured
+	cfg.Recorder.Entries = 0
+	if rec := do(t, New(om, cfg), http.MethodGet, "/v1/admin/recorder"); rec.Code != http.StatusNotFound {
+		t.Errorf("unconfigured = %d", rec.Code)
+	}
+}
@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/validate"
+)
+
+// registerRefireRoutes mounts remakes of orders that came back
+func (s *Server) registerRefireRoutes() {
+	s.handle("POST /v1/orders/{id}/refire", s.refireOrderV1)
+}
+
+// refireOrderV1 places a remake of a prepared or picked up order that came
+// back, for the reason code given, at the front of the queue
+func (s *Server) refireOrderV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	q, ok := input(w, r, "reason", "notes", "by")
+	if !ok {
+		return
+	}
+	var errs validate.Errors
+	req := manager.RefireRequest{
+		Reason: strings.TrimSpace(q.Get("reason")),
+		Notes:  strings.TrimSpace(q.Get("notes")),
+		By:     strings.TrimSpace(q.Get("by")),
+	}
+	if req.Reason == "" {
+		errs.Add("reason", "is required")
+	}
+	if req.By == "" {
+		errs.Add("by", "is required")
+	}
+	if limit := s.cfg.Validation.MaxNotes; utf8.RuneCountInString(req.Notes) > limit {
+		errs.Add("notes", "must be at most %d characters", limit)
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	span := opSpan(r, "RefireOrder")
+	token, err := s.om.RefireOrder(r.Context(), id, req)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionRefire, id, req.By, map[string]string{"reason": req.Reason, "remake": token.ID})
+	s.writeOrder(w, r, http.StatusCreated, token)
+}
@@ -0,0 +1,56 @@
+package httpapi
+
+import (
+	"net/http"
+	"testing"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestRefireV1(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	trail := openAudit(t)
+	s := New(manager.New(manager.DefaultConfig()), cfg, WithAuditLog(trail))
+	var soup queue.Token
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=soup&priority=1&notes=extra+hot"), &soup)
+	if rec := do(t, s, http.MethodPost, "/v1/orders/"+soup.ID+"/refire?reason=cold&by=maria"); rec.Code != http.StatusConflict {
+		t.Errorf("refire of a waiting order = %d", rec.Code)
+	}
+	do(t, s, http.MethodPost, "/v1/orders/"+soup.ID+"/prepare")
+	do(t, s, http.MethodPost, "/v1/orders/"+soup.ID+"/pickup?code="+soup.PickupCode)
+
+	if rec := do(t, s, http.MethodPost, "/v1/orders/"+soup.ID+"/refire?reason=cold"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("refire without by = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders/"+soup.ID+"/refire?reason=bored&by=maria"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("refire with an unknown reason = %d", rec.Code)
+	}
+	rec := do(t, s, http.MethodPost, "/v1/orders/"+soup.ID+"/refire?reason=cold&by=maria")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("refire = %d %s", rec.Code, rec.Body)
+	}
+	var remake queue.Token
+	decode(t, rec, &remake)
+	if remake.RemakeOf != soup.ID || remake.Priority != queue.RushPriority || remake.Notes != "extra hot" {
+		t.Fatalf("remake = %+v", remake)
+	}
+	var got queue.Token
+	decode(t, do(t, s, http.MethodGet, "/v1/orders/"+soup.ID), &got)
+	if len(got.Remakes) != 1 || got.Remakes[0] != remake.ID {
+		t.Errorf("original remakes = %v", got.Remakes)
+	}
+
+	entries, _ := trail.Query(audit.Filter{Action: audit.ActionRefire})
+	if len(entries) != 1 || entries[0].OrderID != soup.ID || entries[0].Actor != "maria" || entries[0].Detail["remake"] != remake.ID {
+		t.Errorf("audit entries = %+v", entries)
+	}
+
+	var stats statsBody
+	decode(t, do(t, s, http.MethodGet, "/v1/stats"), &stats)
+	if stats.Orders != 1 || stats.Remakes != 1 || len(stats.RemakeReasons) != 1 || stats.RemakeReasons[0].Reason != "cold" {
+		t.Errorf("stats = %+v", stats.Report)
+	}
+}
@@ -0,0 +1,59 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/validate"
+)
+
+// registerRequeueRoutes mounts the bulk requeue for admins
+func (s *Server) registerRequeueRoutes() {
+	if s.cfg.Admin.Token == "" {
+		return
+	}
+	s.handle("POST /v1/admin/requeue", s.admin(s.requeueV1))
+}
+
+// requeueV1 moves prepared orders back into the queue: those in ids, or
+// prepared between from and to, optionally at one station. With dryRun set
+// it only reports what would move.
+func (s *Server) requeueV1(w http.ResponseWriter, r *http.Request) {
+	q, ok := input(w, r, "ids", "from", "to", "station", "dryRun")
+	if !ok {
+		return
+	}
+	var errs validate.Errors
+	f := manager.RequeueFilter{
+		IDs:     listParam(q, "ids"),
+		From:    timeParam(q, "from", &errs),
+		To:      timeParam(q, "to", &errs),
+		Station: q.Get("station"),
+		DryRun:  boolParam(q, "dryRun", &errs),
+	}
+	if len(f.IDs) == 0 && f.From.IsZero() && f.To.IsZero() && len(errs) == 0 {
+		errs.Add("ids", "give ids, from or to to pick the orders")
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.To.After(f.From) {
+		errs.Add("to", "from must be before to")
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	span := opSpan(r, "RequeuePrepared")
+	res, err := s.om.RequeuePrepared(r.Context(), f)
+	span.Finish(err)
+	if res != nil && !res.DryRun {
+		for _, token := range res.Requeued {
+			s.record(r, audit.ActionRequeue, token.ID, "", map[string]string{"batch": strconv.Itoa(len(res.Requeued))})
+		}
+	}
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestRequeue(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Token = "t0ken"
+	s := New(manager.New(manager.DefaultConfig()), cfg)
+	admin := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		req.Header.Set("Authorization", "Bearer t0ken")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	var a, b queue.Token
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=soup&priority=1"), &a)
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1"), &b)
+	do(t, s, http.MethodPost, "/v1/orders/next")
+	do(t, s, http.MethodPost, "/v1/orders/next")
+
+	if rec := do(t, s, http.MethodPost, "/v1/admin/requeue?ids="+a.ID); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("requeue without the admin token = %d", rec.Code)
+	}
+	if rec := admin("/v1/admin/requeue"); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("requeue without a filter = %d", rec.Code)
+	}
+	var res manager.RequeueResult
+	decode(t, admin("/v1/admin/requeue?dryRun=true&ids="+a.ID+","+b.ID), &res)
+	if !res.DryRun || len(res.Requeued) != 2 {
+		t.Fatalf("dry run = %+v", res)
+	}
+	rec := admin("/v1/admin/requeue?ids=" + b.ID)
+	decode(t, rec, &res)
+	if rec.Code != http.StatusOK || res.DryRun || len(res.Requeued) != 1 || res.Requeued[0].Status != queue.StatusPreparing {
+		t.Fatalf("requeue = %d %+v", rec.Code, res)
+	}
+	var got queue.Token
+	decode(t, do(t, s, http.MethodGet, "/v1/orders/"+a.ID), &got)
+	if got.Status != queue.StatusPrepared {
+		t.Errorf("order left out is %s", got.Status)
+	}
+}
@@ -0,0 +1,203 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"time"
+
+	"awesomeProject/pkg/devices"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// Roles a request is served as
+const (
+	rolePublic  = "public"  // Anyone, such as the lobby display and customers' phones
+	roleKitchen = "kitchen" // Kitchen staff and their displays and printers
+	roleAdmin   = "admin"   // Managers and holders of the admin token
+)
+
+// RolesConfig shapes order responses by who asks. Kitchen staff and admins
+// see every detail of an order; with Redact set everyone else sees only its
+// token number, status and when it should be ready, so customers' phone
+// numbers and notes stay off the public display feed.
+type RolesConfig struct {
+	// Redact serves public callers only the public fields of every order
+	// in a response, the event stream and announcements included, and keeps
+	// the views with no public form to kitchen staff and admins: the kitchen
+	// display board, tickets, the export, order history and attachments, and
+	// the GraphQL order queries and staff mutations. Pickup QR codes are for
+	// staff whether or not it is set.
+	Redact bool `json:"redact"`
+
+	// KitchenTokens gives the bearer token of each kitchen login, by name.
+	// Devices registered as kitchen displays or printers are kitchen too,
+	// with their own keys, as are the admin and manager tokens.
+	KitchenTokens map[string]string `json:"kitchenTokens"`
+}
+
+// role returns the role r is served as
+func (s *Server) role(r *http.Request) string {
+	got := []byte(r.Header.Get("Authorization"))
+	bearer := func(token string) bool {
+		return token != "" && subtle.ConstantTimeCompare(got, []byte("Bearer "+token)) == 1
+	}
+	if s.isAdmin(r) {
+		return roleAdmin
+	}
+	for _, token := range s.cfg.Admin.Managers {
+		if bearer(token) {
+			return roleAdmin
+		}
+	}
+	for _, token := range s.cfg.Roles.KitchenTokens {
+		if bearer(token) {
+			return roleKitchen
+		}
+	}
+	if s.devices != nil {
+		if d, ok := s.devices.Authenticate(r.Header.Get("X-API-Key")); ok && d.Role != devices.RoleKiosk {
+			return roleKitchen
+		}
+	}
+	return rolePublic
+}
+
+// publicView reports whether r is to be served only the public fields of
+// orders. It tells caches the response depends on the caller's credentials.
+func (s *Server) publicView(w http.ResponseWriter, r *http.Request) bool {
+	if !s.cfg.Roles.Redact {
+		return false
+	}
+	if !slices.Contains(w.Header().Values("Vary"), varyCredentials) {
+		w.Header().Add("Vary", varyCredentials)
+	}
+	return s.role(r) == rolePublic
+}
+
+// varyCredentials names the headers a redacted response depends on
+const varyCredentials = "Authorization, X-API-Key"
+
+// kitchen serves h only to kitchen staff and admins while Redact is set, for
+// views of the queue that have no public form
+func (s *Server) kitchen(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.publicView(w, r) {
+			writeError(w, r, http.StatusUnauthorized, "kitchen credentials required")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// staff serves h only to kitchen staff and admins, whether or not Redact is
+// set, for views that give away what only the customer is to hold, such as
+// the pickup QR code
+func (s *Server) staff(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.role(r) == rolePublic {
+			writeError(w, r, http.StatusUnauthorized, "kitchen credentials required")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// till serves h to kitchen staff, admins and kiosks, the callers that print
+// receipts
+func (s *Server) till(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.role(r) == rolePublic && !s.isKiosk(r) {
+			writeError(w, r, http.StatusUnauthorized, "kitchen or kiosk credentials required")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// isKiosk reports whether r carries the key of a device registered as a kiosk
+func (s *Server) isKiosk(r *http.Request) bool {
+	if s.devices == nil {
+		return false
+	}
+	d, ok := s.devices.Authenticate(r.Header.Get("X-API-Key"))
+	return ok && d.Role == devices.RoleKiosk
+}
+
+// publicOrder is what public callers see of an order
+type publicOrder struct {
+	Number           int        `json:"number,omitempty"`
+	Status           string     `json:"status"`
+	Position         int        `json:"position,omitempty"`
+	EstimatedReadyAt *time.Time `json:"estimatedReadyAt,omitempty"`
+}
+
+func newPublicOrder(t *queue.Token) publicOrder {
+	return publicOrder{Number: t.Number, Status: t.Status, Position: t.Position, EstimatedReadyAt: t.EstimatedReadyAt}
+}
+
+// view returns t as the caller's role may see it: whole, or its public
+// fields. Every response carrying orders goes through it or views.
+func (s *Server) view(w http.ResponseWriter, r *http.Request, t *queue.Token) any {
+	if t == nil || !s.publicView(w, r) {
+		return t
+	}
+	return newPublicOrder(t)
+}
+
+// views returns tokens as the caller's role may see them, never null
+func (s *Server) views(w http.ResponseWriter, r *http.Request, tokens []*queue.Token) any {
+	if !s.publicView(w, r) {
+		return nonNil(tokens)
+	}
+	out := make([]publicOrder, len(tokens))
+	for i, t := range tokens {
+		out[i] = newPublicOrder(t)
+	}
+	return out
+}
+
+// writeOrder writes t with status as the caller's role may see it
+func (s *Server) writeOrder(w http.ResponseWriter, r *http.Request, status int, t *queue.Token) {
+	writeJSON(w, status, s.view(w, r, t))
+}
+
+// withoutPersonal returns a copy of t with the customer's personal data and
+// pickup code taken out, for announcement templates, which are given the
+// order's whole shape
+func withoutPersonal(t *queue.Token) *queue.Token {
+	c := t.Copy()
+	c.Scrub()
+	c.PickupCode = ""
+	return c
+}
+
+// viewedOrderList is an order listing with its orders as views gives them
+type viewedOrderList struct {
+	Orders any `json:"orders"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// writeOrders writes list as the caller's role may see it
+func (s *Server) writeOrders(w http.ResponseWriter, r *http.Request, list orderList) {
+	writeJSON(w, http.StatusOK, viewedOrderList{Orders: s.views(w, r, list.Orders), Total: list.Total, Limit: list.Limit, Offset: list.Offset})
+}
+
+// publicEvent is an order event as public callers see it
+type publicEvent struct {
+	Type  string        `json:"type"`
+	Token publicOrder   `json:"token"`
+	At    time.Time     `json:"at"`
+	Group []publicOrder `json:"group,omitempty"`
+}
+
+func newPublicEvent(e manager.Event) publicEvent {
+	pe := publicEvent{Type: e.Type, Token: newPublicOrder(e.Token), At: e.At}
+	for _, t := range e.Group {
+		pe.Group = append(pe.Group, newPublicOrder(t))
+	}
+	return pe
+}
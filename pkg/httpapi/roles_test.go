@@ -0,0 +1,231 @@
+package httpapi
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/attach"
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/devices"
+	"awesomeProject/pkg/eventlog"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestRoleRedaction(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Token = "t0ken"
+	cfg.Admin.Managers = map[string]string{"ana": "m4nager"}
+	cfg.Roles = RolesConfig{Redact: true, KitchenTokens: map[string]string{"line": "k1tchen"}}
+	reg, err := devices.Open(devices.Config{Path: t.TempDir() + "/devices.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, kdsKey, err := reg.Register("kds-1", "", devices.RoleKDS, devices.Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, kioskKey, err := reg.Register("kiosk-1", "", devices.RoleKiosk, devices.Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(manager.New(manager.DefaultConfig()), cfg, WithDevices(reg))
+	rec := doJSON(t, s, http.MethodPost, "/v1/orders", `{"item":"soup","priority":1,"notes":"for Sam","phone":"+15550100"}`)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), "+15550100") {
+		t.Fatalf("placing shows the customer their order: %d %s", rec.Code, rec.Body)
+	}
+
+	get := func(target, header, value string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s = %d %s", target, rec.Code, rec.Body)
+		}
+		if vary := rec.Header().Values("Vary"); !slices.Contains(vary, "Authorization, X-API-Key") {
+			t.Errorf("%s: Vary = %q", target, vary)
+		}
+		return rec.Body.String()
+	}
+	for _, target := range []string{"/v1/orders", "/v1/orders/1", "/v1/search?q=soup", "/v1/orders/1/wait?status=scheduled"} {
+		for _, caller := range []struct{ header, value string }{{}, {"X-API-Key", kioskKey}, {"Authorization", "Bearer wrong"}} {
+			body := get(target, caller.header, caller.value)
+			if strings.Contains(body, "+15550100") || strings.Contains(body, "Sam") || strings.Contains(body, "soup") ||
+				!strings.Contains(body, `"number":1`) || !strings.Contains(body, `"status":"preparing"`) {
+				t.Errorf("%s as %q: %s", target, caller.value, body)
+			}
+		}
+		for _, caller := range []struct{ header, value string }{
+			{"Authorization", "Bearer t0ken"}, {"Authorization", "Bearer m4nager"}, {"Authorization", "Bearer k1tchen"}, {"X-API-Key", kdsKey},
+		} {
+			if body := get(target, caller.header, caller.value); !strings.Contains(body, "+15550100") || !strings.Contains(body, "for Sam") {
+				t.Errorf("%s as %q: %s", target, caller.value, body)
+			}
+		}
+	}
+
+	// The kitchen board and the GraphQL order queries have no public form
+	send := func(method, target, body, header, value string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, query := range []string{`{"query":"{ order(id: \"1\") { notes } }"}`, `{"query":"{ orders { orders { notes rushedBy } } }"}`} {
+		for _, caller := range []struct{ header, value string }{{}, {"X-API-Key", kioskKey}} {
+			rec := send(http.MethodPost, "/graphql", query, caller.header, caller.value)
+			if body := rec.Body.String(); strings.Contains(body, "Sam") || !strings.Contains(body, "kitchen credentials required") {
+				t.Errorf("graphql %s as %q: %s", query, caller.value, body)
+			}
+		}
+		if body := send(http.MethodPost, "/graphql", query, "X-API-Key", kdsKey).Body.String(); !strings.Contains(body, "for Sam") {
+			t.Errorf("graphql %s as the display: %s", query, body)
+		}
+	}
+	sub := send(http.MethodPost, "/graphql", `{"query":"subscription { orderEvents { type } }"}`, "Accept", "text/event-stream")
+	if sub.Code != http.StatusBadRequest || !strings.Contains(sub.Body.String(), "kitchen credentials required") {
+		t.Errorf("public subscription = %d %s", sub.Code, sub.Body)
+	}
+	for _, caller := range []struct{ header, value string }{{}, {"X-API-Key", kioskKey}} {
+		if rec := send(http.MethodGet, "/v1/kds", "", caller.header, caller.value); rec.Code != http.StatusUnauthorized || strings.Contains(rec.Body.String(), "soup") {
+			t.Errorf("/v1/kds as %q = %d %s", caller.value, rec.Code, rec.Body)
+		}
+	}
+	if body := get("/v1/kds", "X-API-Key", kdsKey); !strings.Contains(body, "for Sam") {
+		t.Errorf("/v1/kds as the display: %s", body)
+	}
+
+	// Without redaction everyone sees everything, as before
+	cfg.Roles.Redact = false
+	if rec := do(t, New(manager.New(manager.DefaultConfig()), cfg), http.MethodGet, "/v1/orders"); slices.Contains(rec.Header().Values("Vary"), "Authorization, X-API-Key") {
+		t.Errorf("Vary = %q", rec.Header().Values("Vary"))
+	}
+}
+
+func TestPublicEvent(t *testing.T) {
+	om := manager.New(manager.DefaultConfig())
+	var got []manager.Event
+	om.Subscribe(func(e manager.Event) { got = append(got, e) })
+	rec := doJSON(t, New(om, DefaultConfig()), http.MethodPost, "/v1/orders", `{"item":"soup","priority":1,"phone":"+15550100"}`)
+	if rec.Code != http.StatusCreated || len(got) != 1 {
+		t.Fatalf("create = %d, events %v", rec.Code, got)
+	}
+	pe := newPublicEvent(got[0])
+	if pe.Type != manager.EventCreated || pe.Token.Number != 1 || pe.Token.Status != got[0].Token.Status || !pe.At.Equal(got[0].At) {
+		t.Errorf("public event = %+v", pe)
+	}
+}
+
+// TestRedactionPerRoute checks, route by route, that a public caller under
+// Redact is never shown the customer's phone, notes or pickup code
+func TestRedactionPerRoute(t *testing.T) {
+	events, _, err := eventlog.Open(eventlog.Config{Path: filepath.Join(t.TempDir(), "events.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+	files := attach.DefaultConfig()
+	files.Dir = t.TempDir()
+	store, err := attach.New(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mcfg := manager.DefaultConfig()
+	mcfg.DuplicateWindow = config.Duration(time.Minute)
+	mcfg.RejectDuplicates = true
+	om := manager.New(mcfg)
+	events.Attach(om)
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Roles = RolesConfig{Redact: true, KitchenTokens: map[string]string{"line": "k1tchen"}}
+	cfg.Announcements.Templates = map[string]string{"en": "{{.Number}} {{.Phone}} {{.Notes}} {{.PickupCode}}"}
+	s := New(om, cfg, WithEventLog(events), WithAttachments(store))
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	const order = `{"item":"soup","priority":1,"notes":"for Sam","phone":"+15550100","deviceToken":"kiosk-7"}`
+	var placed queue.Token
+	decode(t, doJSON(t, s, http.MethodPost, "/v1/orders", order), &placed)
+	if placed.PickupCode == "" {
+		t.Fatal("order placed without a pickup code")
+	}
+	send := func(method, target, body, token string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	personal := func(body string) bool {
+		return strings.Contains(body, "+15550100") || strings.Contains(body, "Sam") ||
+			strings.Contains(body, "pickupCode") || strings.Contains(body, placed.PickupCode)
+	}
+	if rec := send(http.MethodPost, "/v1/orders/1/attachments", "", "k1tchen"); rec.Code == http.StatusUnauthorized {
+		t.Fatalf("kitchen attach = %d", rec.Code)
+	}
+
+	for _, tc := range []struct {
+		method, target, body string
+		status               int
+	}{
+		{http.MethodGet, "/v1/orders/1/ticket", "", http.StatusUnauthorized},
+		{http.MethodGet, "/v1/orders/1/qr", "", http.StatusUnauthorized},
+		{http.MethodGet, "/v1/export", "", http.StatusUnauthorized},
+		{http.MethodGet, "/export", "", http.StatusUnauthorized},
+		{http.MethodGet, "/v1/orders/1/history", "", http.StatusUnauthorized},
+		{http.MethodGet, "/v1/orders/1/attachments", "", http.StatusUnauthorized},
+		{http.MethodGet, "/v1/orders/1/attachments/a1", "", http.StatusUnauthorized},
+		{http.MethodDelete, "/v1/orders/1/attachments/a1", "", http.StatusUnauthorized},
+		{http.MethodPost, "/v1/orders", order, http.StatusConflict},
+		{http.MethodPatch, "/v1/orders/1?version=stale", `{"quantity":2}`, http.StatusConflict},
+		{http.MethodPatch, "/v1/orders/1", `{"quantity":2}`, http.StatusOK},
+		{http.MethodPost, "/v1/orders/1/cancel", "", http.StatusOK},
+		{http.MethodPost, "/v1/orders/1/restore", "", http.StatusOK},
+		{http.MethodPost, "/graphql", `{"query":"mutation { cancel(id: \"1\") { notes } }"}`, http.StatusOK},
+	} {
+		rec := send(tc.method, tc.target, tc.body, "")
+		if rec.Code != tc.status || personal(rec.Body.String()) {
+			t.Errorf("%s %s as the public = %d %s", tc.method, tc.target, rec.Code, rec.Body)
+		}
+	}
+
+	res, err := http.Get(srv.URL + "/v1/announcements")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	lines := bufio.NewScanner(res.Body)
+	lines.Scan() // Connected
+	if rec := send(http.MethodPost, "/v1/orders/1/prepare", "", "k1tchen"); rec.Code != http.StatusOK {
+		t.Fatalf("prepare = %d %s", rec.Code, rec.Body)
+	}
+	for lines.Scan() {
+		if data, ok := strings.CutPrefix(lines.Text(), "data: "); ok {
+			if personal(data) || strings.Contains(data, "orderId") || !strings.Contains(data, `"text":"1   "`) {
+				t.Errorf("public announcement = %s", data)
+			}
+			return
+		}
+	}
+	t.Error("no announcement")
+}
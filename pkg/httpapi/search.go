@@ -0,0 +1,43 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxSearch bounds the search queries accepted
+const maxSearch = 200
+
+// registerSearchRoutes mounts the front-desk order search
+func (s *Server) registerSearchRoutes() {
+	s.handle("GET /v1/search", s.searchHandler)
+}
+
+// searchHandler finds the orders whose item, notes or customer details hold
+// every word of q, narrowed by any of the listing parameters, so the front
+// desk can find an order from what the customer says: the name written in
+// the notes, the last digits of a phone number, a pickup code
+func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f, errs := parseOrderFilter(q)
+	switch f.Text = strings.TrimSpace(q.Get("q")); {
+	case !strings.ContainsFunc(f.Text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }):
+		errs.Add("q", "is required")
+	case utf8.RuneCountInString(f.Text) > maxSearch:
+		errs.Add("q", "must be at most %d characters", maxSearch)
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	span := opSpan(r, "QueryOrders")
+	orders, total, err := s.om.QueryOrders(r.Context(), f)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.writeOrders(w, r, orderList{Orders: orders, Total: total, Limit: f.Limit, Offset: f.Offset})
+}
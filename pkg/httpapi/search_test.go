@@ -0,0 +1,43 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	s := newTestServer(t)
+	do(t, s, http.MethodPost, "/v1/orders?item=oat+latte&priority=1&notes=for+Sam")
+	do(t, s, http.MethodPost, "/v1/orders?item=latte&priority=1&table=4")
+	do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1&notes=for+Sam")
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantTotal  int
+	}{
+		{"/v1/search?q=latte", http.StatusOK, 2},
+		{"/v1/search?q=sam", http.StatusOK, 2},
+		{"/v1/search?q=latte&table=4", http.StatusOK, 1},
+		{"/v1/search?q=sam&status=prepared", http.StatusOK, 0},
+		{"/v1/search", http.StatusUnprocessableEntity, 0},
+		{"/v1/search?q=%21%3F", http.StatusUnprocessableEntity, 0},
+		{"/v1/search?q=" + strings.Repeat("a", maxSearch+1), http.StatusUnprocessableEntity, 0},
+	}
+	for _, tt := range tests {
+		rec := do(t, s, http.MethodGet, tt.target)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d: %s", tt.target, rec.Code, tt.wantStatus, rec.Body)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var list orderList
+		decode(t, rec, &list)
+		if list.Total != tt.wantTotal || len(list.Orders) != tt.wantTotal {
+			t.Errorf("%s: %d of %d orders, want %d", tt.target, len(list.Orders), list.Total, tt.wantTotal)
+		}
+	}
+}
@@ -0,0 +1,460 @@
+// Package httpapi exposes an OrderManager over HTTP: the v1 JSON API and the
+// legacy plain-text endpoints.
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/alerts"
+	"awesomeProject/pkg/attach"
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/backup"
+	"awesomeProject/pkg/devices"
+	"awesomeProject/pkg/eventlog"
+	"awesomeProject/pkg/fairness"
+	"awesomeProject/pkg/fault"
+	"awesomeProject/pkg/features"
+	"awesomeProject/pkg/graphql"
+	"awesomeProject/pkg/i18n"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/outbound"
+	"awesomeProject/pkg/privacy"
+	"awesomeProject/pkg/tracing"
+	"awesomeProject/pkg/validate"
+)
+
+// Config holds the HTTP API settings
+type Config struct {
+	LegacyRoutes bool            `json:"legacyRoutes"` // Serve the old plain-text endpoints
+	RateLimits   RateLimitConfig `json:"rateLimits"`
+	SwaggerUI    bool            `json:"swaggerUI"` // Serve Swagger UI at /docs
+	GraphQL      bool            `json:"graphql"`   // Serve the GraphQL API at /graphql
+	Validation   validate.Rules  `json:"validation"`
+	CORS         CORSConfig      `json:"cors"`
+	Health       HealthConfig    `json:"health"`
+	Payments     PaymentsConfig  `json:"payments"`
+	KDS          KDSConfig       `json:"kds"`
+	Gzip         GzipConfig      `json:"gzip"`
+	Admin        AdminConfig     `json:"admin"`
+	Receipts     ReceiptConfig   `json:"receipts"`
+
+	Announcements AnnouncementConfig `json:"announcements"`
+	Timeouts      TimeoutConfig      `json:"timeouts"`
+	Recorder      RecorderConfig     `json:"recorder"`
+	Roles         RolesConfig        `json:"roles"`
+	Branding      BrandingConfig     `json:"branding"`
+	Channels      ChannelConfig      `json:"channels"`
+
+	// WebSocketOrigins are the origins, besides the server's own, whose
+	// pages may open the event streams as WebSockets: exact origins such as
+	// "https://kds.example.com", or "*" for any
+	WebSocketOrigins []string `json:"webSocketOrigins"`
+
+	// UnversionedRoutes serves /stats, /search and /export as deprecated
+	// aliases of their /v1 paths; Sunset, when set, is the date they and the
+	// legacy endpoints are to be removed, sent in their Sunset header
+	UnversionedRoutes bool      `json:"unversionedRoutes"`
+	Sunset            time.Time `json:"sunset"`
+
+	// RequireVersion rejects changes and cancellations that do not say
+	// which version of the order they were made against
+	RequireVersion bool `json:"requireVersion"`
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		LegacyRoutes:      true,
+		UnversionedRoutes: true,
+		SwaggerUI:         true,
+		GraphQL:           true,
+		Validation:        validate.DefaultRules(),
+		CORS:              DefaultCORSConfig(),
+		Health:            DefaultHealthConfig(),
+		Payments:          DefaultPaymentsConfig(),
+		KDS:               DefaultKDSConfig(),
+		Gzip:              DefaultGzipConfig(),
+		Timeouts:          DefaultTimeoutConfig(),
+		Recorder:          DefaultRecorderConfig(),
+		RateLimits: RateLimitConfig{
+			Endpoints: map[string]RateLimit{
+				"/addOrder":       {Rate: 1, Burst: 10},
+				"POST /v1/orders": {Rate: 1, Burst: 10},
+			},
+		},
+	}
+}
+
+// Server is an http.Handler serving the order API
+type Server struct {
+	om          *manager.OrderManager
+	cfg         Config
+	events      *eventlog.Log     // Optional; enables order history
+	audit       *audit.Log        // Optional; records staff actions
+	backups     *backup.Backups   // Optional; served to admins
+	outbound    *outbound.Client  // Optional; its stats are served to admins
+	alerts      *alerts.Alerts    // Optional; served and shown on the kitchen display
+	attachments *attach.Store     // Optional; keeps files attached to orders
+	fairness    *fairness.Tracker // Optional; wait histograms by priority
+	devices     *devices.Registry // Optional; kiosks, displays and printers
+	features    *features.Set     // Optional; turns optional behaviors off at runtime
+	mux         *http.ServeMux
+	handler     http.Handler    // mux wrapped in server-wide middleware
+	patterns    []string        // Registered route patterns, in registration order
+	checks      []namedCheck    // Readiness checks, see registerHealthRoutes
+	stream      *broadcaster    // Fans manager events out to /v1/events
+	announce    *announcer      // Renders /v1/announcements
+	gzip        *compressor     // For the routes in Config.Gzip
+	tracer      *tracing.Tracer // Optional; spans for requests and manager calls
+	graphql     *graphql.Schema // Served at /graphql when Config.GraphQL is set
+	recorder    *recorder       // Records requests when Config.Recorder has entries
+	privacy     *privacy.Purger // Optional; erases customers' personal data
+	branding    *branding       // The name, logo and colors pages are shown with
+
+	// Rate limiters by route pattern, shared by everything charged against
+	// them; nil for unlimited routes
+	limiters map[string]*RateLimiter
+}
+
+// Option attaches an optional subsystem to a Server
+type Option func(*Server)
+
+// WithEventLog serves order history from l
+func WithEventLog(l *eventlog.Log) Option {
+	return func(s *Server) { s.events = l }
+}
+
+// New returns a Server for om with all routes registered
+func New(om *manager.OrderManager, cfg Config, opts ...Option) *Server {
+	s := &Server{
+		om:       om,
+		cfg:      cfg,
+		mux:      http.NewServeMux(),
+		gzip:     newCompressor(cfg.Gzip.Level),
+		branding: newBranding(cfg.Branding),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if cfg.Recorder.Entries > 0 {
+		s.recorder = newRecorder(cfg.Recorder)
+		om.Subscribe(s.recorder.event)
+	}
+	s.registerV1()
+	if cfg.GraphQL {
+		s.registerGraphQLRoutes()
+	}
+	s.registerDocRoutes()
+	s.registerHealthRoutes()
+	s.registerMetricsRoutes()
+	if cfg.UnversionedRoutes {
+		s.registerUnversionedRoutes()
+	}
+	if cfg.LegacyRoutes {
+		s.registerLegacyRoutes()
+	}
+	s.handler = localized(cors(cfg.CORS, s.mux))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// handle registers h for pattern, wrapped in that endpoint's timeout and rate
+// limiter, gzip compression for the configured routes, tracing and the
+// flight recorder when enabled
+func (s *Server) handle(pattern string, h http.HandlerFunc) {
+	s.route(pattern, h, s.cfg.Timeouts.timeoutFor(pattern), true)
+}
+
+// handleStream registers a route whose responses stay open, such as the
+// event streams, so no timeout applies, the server's own are lifted and
+// nothing is recorded
+func (s *Server) handleStream(pattern string, h http.HandlerFunc) {
+	s.route(pattern, untimed(h), 0, false)
+}
+
+func (s *Server) route(pattern string, h http.HandlerFunc, timeout time.Duration, record bool) {
+	var handler http.Handler = h
+	// Switching faults off is never slowed by them
+	if fault.Enabled && !strings.HasSuffix(pattern, " /v1/admin/faults") {
+		handler = slowed(handler)
+	}
+	if timeout > 0 {
+		handler = withTimeout(timeout, handler)
+	}
+	if rl := s.limiter(pattern); rl != nil {
+		handler = rl.Middleware(s.clientKey, handler)
+	}
+	if slices.Contains(s.cfg.Gzip.Routes, pattern) {
+		handler = s.gzip.Middleware(handler)
+	}
+	if s.tracer != nil {
+		handler = s.traced(pattern, handler)
+	}
+	// Dumping the recorder is left out, so it does not fill itself
+	if record && s.recorder != nil && !strings.HasSuffix(pattern, " /v1/admin/recorder") {
+		handler = s.recorded(handler)
+	}
+	s.mux.Handle(pattern, handler)
+	s.patterns = append(s.patterns, pattern)
+}
+
+// errorBody is the JSON payload for failed requests. Error is for people
+// and is translated; Code is for programs and never changes.
+type errorBody struct {
+	Error  string                `json:"error"`
+	Code   string                `json:"code"`
+	Fields []validate.FieldError `json:"fields,omitempty"`
+}
+
+// Codes of the errors that are not the manager's, whose codes come from
+// manager.Code
+const (
+	codeInvalidInput   = "invalid_input"   // Values parsed but broke a rule
+	codeMalformedInput = "malformed_input" // A value could not be parsed
+	codeTimeout        = "timeout"
+	codeCancelled      = "cancelled"
+)
+
+// statusCode is the code of an error with nothing more specific to say
+// than its status: the status text in snake case, such as not_found
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("write response: %v", err)
+	}
+}
+
+// localized selects the language of each request's messages, from the lang
+// query parameter or the Accept-Language header
+func localized(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := i18n.Negotiate(r.URL.Query().Get("lang"), r.Header.Get("Accept-Language"))
+		h.ServeHTTP(w, r.WithContext(i18n.NewContext(r.Context(), lang)))
+	})
+}
+
+// language returns the language chosen for r's messages and marks the
+// response as being in it
+func language(w http.ResponseWriter, r *http.Request) string {
+	lang := i18n.FromContext(r.Context())
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	return lang
+}
+
+// writeError reports msg, translated
+func writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	writeJSON(w, status, errorBody{Error: i18n.T(language(w, r), msg), Code: statusCode(status)})
+}
+
+// writeErrorf reports a message formatted from the translation of format
+func writeErrorf(w http.ResponseWriter, r *http.Request, status int, format string, args ...any) {
+	writeJSON(w, status, errorBody{Error: i18n.Sprintf(language(w, r), format, args...), Code: statusCode(status)})
+}
+
+// writeBadRequest reports a 400 with err's text, translated
+func writeBadRequest(w http.ResponseWriter, r *http.Request, err error) {
+	writeJSON(w, http.StatusBadRequest, errorBody{Error: i18n.Error(language(w, r), err), Code: statusCode(http.StatusBadRequest)})
+}
+
+// writeValidationError reports field errors: 400 when a value could not be
+// parsed, 422 when values parsed but broke a rule
+func writeValidationError(w http.ResponseWriter, r *http.Request, errs validate.Errors) {
+	status, code := http.StatusUnprocessableEntity, codeInvalidInput
+	if errs.HasMalformed() {
+		status, code = http.StatusBadRequest, codeMalformedInput
+	}
+	lang := language(w, r)
+	tr := func(format string) string { return i18n.T(lang, format) }
+	writeJSON(w, status, errorBody{Error: i18n.T(lang, "invalid request"), Code: code, Fields: errs.Translate(tr)})
+}
+
+// writeManagerError maps an OrderManager error to its HTTP status and code
+func (s *Server) writeManagerError(w http.ResponseWriter, r *http.Request, err error) {
+	lang := language(w, r)
+	msg := i18n.Error(lang, err)
+	status, code := managerStatus(err), managerCode(err)
+	var full *manager.CapacityError
+	if errors.As(err, &full) {
+		setRetryAfter(w, full)
+		writeJSON(w, status, capacityBody{
+			errorBody:            errorBody{Error: msg, Code: code},
+			EstimatedWaitSeconds: int(math.Ceil(full.EstimatedWait.Seconds())),
+		})
+		return
+	}
+	var busy *manager.StationBusyError
+	if errors.As(err, &busy) {
+		writeJSON(w, status, busyBody{
+			errorBody:  errorBody{Error: msg, Code: code},
+			Station:    busy.Station,
+			InProgress: busy.InProgress,
+			Limit:      busy.Limit,
+		})
+		return
+	}
+	var rush *manager.RushLimitError
+	if errors.As(err, &rush) {
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(rush.RetryAfter.Seconds())))))
+		writeJSON(w, status, errorBody{Error: msg, Code: code})
+		return
+	}
+	var dup *manager.DuplicateError
+	if errors.As(err, &dup) {
+		writeJSON(w, status, duplicateBody{errorBody: errorBody{Error: msg, Code: code}, Existing: s.view(w, r, dup.Existing)})
+		return
+	}
+	var paused *manager.PausedError
+	if errors.As(err, &paused) {
+		writeJSON(w, status, pausedBody{errorBody: errorBody{Error: msg, Code: code}, Since: paused.Since})
+		return
+	}
+	var closed *manager.ClosedError
+	if errors.As(err, &closed) {
+		body := closedBody{errorBody: errorBody{Error: msg, Code: code}}
+		if !closed.NextOpen.IsZero() {
+			body.NextOpen = &closed.NextOpen
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(closed.Wait.Seconds())))))
+		}
+		writeJSON(w, status, body)
+		return
+	}
+	var stale *manager.VersionError
+	if errors.As(err, &stale) {
+		setETag(w, stale.Current)
+		writeJSON(w, status, staleBody{errorBody: errorBody{Error: msg, Code: code}, Current: s.view(w, r, stale.Current)})
+		return
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		msg = i18n.T(lang, "request timed out")
+	case errors.Is(err, context.Canceled):
+		msg = i18n.T(lang, "request cancelled")
+	}
+	writeJSON(w, status, errorBody{Error: msg, Code: code})
+}
+
+// managerCode returns the code of an OrderManager error
+func managerCode(err error) string {
+	if code := manager.Code(err); code != "" {
+		return code
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return codeTimeout
+	case errors.Is(err, context.Canceled):
+		return codeCancelled
+	}
+	return statusCode(http.StatusInternalServerError)
+}
+
+// managerStatus returns the HTTP status of an OrderManager error
+func managerStatus(err error) int {
+	var (
+		full   *manager.CapacityError
+		busy   *manager.StationBusyError
+		rush   *manager.RushLimitError
+		dup    *manager.DuplicateError
+		paused *manager.PausedError
+		closed *manager.ClosedError
+		stale  *manager.VersionError
+	)
+	switch {
+	case errors.As(err, &full), errors.As(err, &paused), errors.As(err, &closed),
+		errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	case errors.As(err, &rush), errors.Is(err, manager.ErrQuota):
+		return http.StatusTooManyRequests
+	case errors.As(err, &busy), errors.As(err, &dup), errors.As(err, &stale):
+		return http.StatusConflict
+	case errors.Is(err, manager.ErrPickupCode):
+		return http.StatusForbidden
+	case errors.Is(err, manager.ErrInvalidSnapshot):
+		return http.StatusBadRequest
+	case errors.Is(err, manager.ErrVoidReason), errors.Is(err, manager.ErrRemakeReason), errors.Is(err, manager.ErrImport),
+		errors.Is(err, manager.ErrCurrency):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, manager.ErrOrderNotFound), errors.Is(err, manager.ErrQueueEmpty),
+		errors.Is(err, manager.ErrAttachmentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, manager.ErrNotModifiable), errors.Is(err, manager.ErrNotCancellable),
+		errors.Is(err, manager.ErrNotPrepared), errors.Is(err, manager.ErrGraceExpired),
+		errors.Is(err, manager.ErrPaymentTransition), errors.Is(err, manager.ErrNotCancelled),
+		errors.Is(err, manager.ErrNotWaiting), errors.Is(err, manager.ErrNothingToFire),
+		errors.Is(err, manager.ErrNotPaused), errors.Is(err, manager.ErrNotVoidable),
+		errors.Is(err, manager.ErrNotRefirable), errors.Is(err, manager.ErrStationPaused),
+		errors.Is(err, manager.ErrStationRunning), errors.Is(err, manager.ErrForceStatus),
+		errors.Is(err, manager.ErrSameStatus):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// capacityBody is the JSON payload for orders rejected by a full station
+type capacityBody struct {
+	errorBody
+	EstimatedWaitSeconds int `json:"estimatedWaitSeconds"`
+}
+
+// busyBody is the JSON payload for claims at a station whose cooks are all
+// busy
+type busyBody struct {
+	errorBody
+	Station    string `json:"station"`
+	InProgress int    `json:"inProgress"`
+	Limit      int    `json:"limit"`
+}
+
+// duplicateBody is the JSON payload for rejected duplicate orders; kiosks show
+// the existing order's token instead
+type duplicateBody struct {
+	errorBody
+	Existing any `json:"existing"` // As view gives it
+}
+
+// pausedBody is the JSON payload for orders turned away in maintenance mode;
+// kiosks show the error, which carries the reason for the pause
+type pausedBody struct {
+	errorBody
+	Since time.Time `json:"pausedSince"`
+}
+
+// closedBody is the JSON payload for orders outside the opening hours, with
+// when the kitchen next opens for kiosks to show
+type closedBody struct {
+	errorBody
+	NextOpen *time.Time `json:"nextOpen,omitempty"`
+}
+
+// staleBody is the JSON payload for changes made against an outdated version
+// of an order, with the order as it is now to redo them on
+type staleBody struct {
+	errorBody
+	Current any `json:"current"` // As view gives it
+}
+
+// setRetryAfter suggests retrying once the full station has prepared one order
+func setRetryAfter(w http.ResponseWriter, full *manager.CapacityError) {
+	wait := full.EstimatedWait
+	if full.Pending > 0 {
+		wait /= time.Duration(full.Pending)
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(wait.Seconds())))))
+}
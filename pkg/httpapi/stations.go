@@ -0,0 +1,85 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/validate"
+)
+
+// registerStationRoutes mounts pausing and resuming stations
+func (s *Server) registerStationRoutes() {
+	s.handle("GET /v1/stations/paused", s.pausedStationsV1)
+	s.handle("POST /v1/stations/pause", s.pauseStationV1)
+	s.handle("POST /v1/stations/resume", s.resumeStationV1)
+}
+
+// stationChange is the JSON payload for pausing or resuming a station: the
+// pause, while there is one, and the orders waiting there
+type stationChange struct {
+	Pause  *manager.StationPause `json:"pause,omitempty"`
+	Orders any                   `json:"orders"` // As views gives them
+}
+
+// pausedStationsV1 lists the paused stations
+func (s *Server) pausedStationsV1(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string][]manager.StationPause{"stations": s.om.PausedStations()})
+}
+
+// pauseStationV1 stops handing out a station's orders, for when its
+// equipment is down; they stay queued until it is resumed
+func (s *Server) pauseStationV1(w http.ResponseWriter, r *http.Request) {
+	q, ok := input(w, r, "station", "reason")
+	if !ok {
+		return
+	}
+	var errs validate.Errors
+	if !q.Has("station") {
+		errs.Add("station", "is required")
+	}
+	reason := strings.TrimSpace(q.Get("reason"))
+	if utf8.RuneCountInString(reason) > maxPauseReason {
+		errs.Add("reason", "must be at most %d characters", maxPauseReason)
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	station := q.Get("station")
+	span := opSpan(r, "PauseStation")
+	p, orders, err := s.om.PauseStation(r.Context(), station, reason)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionPauseStation, "", "", map[string]string{"station": station, "reason": reason})
+	writeJSON(w, http.StatusOK, stationChange{Pause: &p, Orders: s.views(w, r, orders)})
+}
+
+// resumeStationV1 hands out a paused station's orders again
+func (s *Server) resumeStationV1(w http.ResponseWriter, r *http.Request) {
+	q, ok := input(w, r, "station")
+	if !ok {
+		return
+	}
+	if !q.Has("station") {
+		var errs validate.Errors
+		errs.Add("station", "is required")
+		writeValidationError(w, r, errs)
+		return
+	}
+	station := q.Get("station")
+	span := opSpan(r, "ResumeStation")
+	orders, err := s.om.ResumeStation(r.Context(), station)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionResumeStation, "", "", map[string]string{"station": station})
+	writeJSON(w, http.StatusOK, stationChange{Orders: s.views(w, r, orders)})
+}
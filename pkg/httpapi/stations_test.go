@@ -0,0 +1,72 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestStationPause(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	trail := openAudit(t)
+	om := manager.New(manager.DefaultConfig())
+	s := New(om, cfg, WithAuditLog(trail))
+	if _, err := om.PlaceOrder(ctx, manager.NewOrder{Item: "chips", Priority: 0, Station: "fryer"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := do(t, s, http.MethodPost, "/v1/stations/pause"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("pause without a station = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/stations/pause?station=fryer&reason="+strings.Repeat("x", maxPauseReason+1)); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("pause with a long reason = %d", rec.Code)
+	}
+	rec := do(t, s, http.MethodPost, "/v1/stations/pause?station=fryer&reason=fryer+broken")
+	var change struct {
+		Pause  *manager.StationPause
+		Orders []*queue.Token
+	}
+	decode(t, rec, &change)
+	if rec.Code != http.StatusOK || change.Pause == nil || change.Pause.Reason != "fryer broken" || len(change.Orders) != 1 {
+		t.Fatalf("pause = %d %s", rec.Code, rec.Body)
+	}
+	var paused struct {
+		Stations []manager.StationPause `json:"stations"`
+	}
+	decode(t, do(t, s, http.MethodGet, "/v1/stations/paused"), &paused)
+	if len(paused.Stations) != 1 || paused.Stations[0].Station != "fryer" {
+		t.Errorf("paused = %+v", paused)
+	}
+	var board struct {
+		Stations []struct {
+			Paused *manager.StationPause `json:"paused"`
+		} `json:"stations"`
+	}
+	decode(t, do(t, s, http.MethodGet, "/v1/kds"), &board)
+	if len(board.Stations) != 1 || board.Stations[0].Paused == nil || board.Stations[0].Paused.Reason != "fryer broken" {
+		t.Errorf("board = %+v", board)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders/next?station=fryer"); rec.Code != http.StatusConflict {
+		t.Errorf("prepare at the paused station = %d", rec.Code)
+	}
+
+	if rec := do(t, s, http.MethodPost, "/v1/stations/resume?station=fryer"); rec.Code != http.StatusOK {
+		t.Fatalf("resume = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/stations/resume?station=fryer"); rec.Code != http.StatusConflict {
+		t.Errorf("resume a running station = %d", rec.Code)
+	}
+
+	entries, _ := trail.Query(audit.Filter{})
+	if len(entries) != 2 || entries[0].Action != audit.ActionResumeStation || entries[1].Action != audit.ActionPauseStation ||
+		entries[1].Detail["reason"] != "fryer broken" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+}
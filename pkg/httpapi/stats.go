@@ -0,0 +1,104 @@
+package httpapi
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"awesomeProject/pkg/analytics"
+	"awesomeProject/pkg/fairness"
+	"awesomeProject/pkg/i18n"
+	"awesomeProject/pkg/manager"
+)
+
+// statsBody is the JSON payload of /v1/stats
+type statsBody struct {
+	analytics.Report
+
+	// Fairness covers every order prepared since the server started,
+	// whatever the range, and the orders waiting now
+	Fairness *fairness.Report `json:"fairness,omitempty"`
+}
+
+// registerStatsRoutes mounts the analytics endpoint
+func (s *Server) registerStatsRoutes() {
+	s.handle("GET /v1/stats", s.statsHandler)
+}
+
+// statsHandler reports on orders placed between from and to (RFC 3339
+// times or YYYY-MM-DD dates, to inclusive), defaulting to today, as JSON or
+// as CSV with format=csv
+func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, to, err := parseDateRange(q, s.om.Now())
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+
+	span := opSpan(r, "QueryOrders")
+	orders, _, err := s.om.QueryOrders(r.Context(), manager.OrderFilter{From: from, To: to})
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	report := analytics.Compute(orders, from, to, time.Local)
+
+	switch q.Get("format") {
+	case "", "json":
+		fair, err := s.fairnessReport(r)
+		if err != nil {
+			s.writeManagerError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, statsBody{Report: report, Fairness: fair})
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="stats.csv"`)
+		if err := analytics.WriteCSV(w, report); err != nil {
+			log.Printf("write stats: %v", err)
+		}
+	default:
+		writeError(w, r, http.StatusBadRequest, "format must be json or csv")
+	}
+}
+
+// parseDateRange reads from and to as RFC 3339 times or local dates. A date
+// for to covers that whole day. Missing bounds default to the start of
+// today and to now.
+func parseDateRange(q url.Values, now time.Time) (time.Time, time.Time, error) {
+	y, m, d := now.Date()
+	from := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	to := now
+
+	if v := q.Get("from"); v != "" {
+		t, err := parseTimeOrDate(v)
+		if err != nil {
+			return from, to, i18n.Errorf("invalid from %q, want RFC 3339 or YYYY-MM-DD", v)
+		}
+		from = t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := parseTimeOrDate(v)
+		if err != nil {
+			return from, to, i18n.Errorf("invalid to %q, want RFC 3339 or YYYY-MM-DD", v)
+		}
+		if len(v) == len(time.DateOnly) {
+			t = t.AddDate(0, 0, 1)
+		}
+		to = t
+	}
+	if !from.Before(to) {
+		return from, to, i18n.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
+
+func parseTimeOrDate(v string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
@@ -0,0 +1,333 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"awesomeProject/pkg/manager"
+)
+
+// streamBuffer is how many events a stream client may fall behind by. Past
+// it the oldest are dropped, so a slow screen never holds up the others,
+// and the client is told how many it missed so it can reload its view.
+const streamBuffer = 64
+
+// streamHeartbeat keeps idle streams from being closed by proxies
+const streamHeartbeat = 15 * time.Second
+
+// Stream transports, as listed by GET /v1/admin/streams
+const (
+	transportSSE       = "sse"
+	transportWebSocket = "websocket"
+	transportInternal  = "internal" // Long polls and GraphQL subscriptions
+)
+
+// streamFilter picks the events a client is sent. It is applied as events
+// are published, so those a client does not want never take up its buffer.
+// Empty fields match everything. A server keeps one restaurant's orders, so
+// there is no tenant to filter by; each restaurant runs its own.
+type streamFilter struct {
+	Stations []string `json:"stations,omitempty"`
+	Statuses []string `json:"statuses,omitempty"` // Of the order after the event
+	Types    []string `json:"types,omitempty"`
+	Order    string   `json:"order,omitempty"`
+}
+
+// parseStreamFilter reads the station, status and type query parameters,
+// each of which may be repeated. An empty station is the default station.
+func parseStreamFilter(r *http.Request) streamFilter {
+	q := r.URL.Query()
+	return streamFilter{Stations: q["station"], Statuses: q["status"], Types: q["type"]}
+}
+
+func (f streamFilter) match(e manager.Event) bool {
+	return (len(f.Stations) == 0 || slices.Contains(f.Stations, e.Token.Station)) &&
+		(len(f.Statuses) == 0 || slices.Contains(f.Statuses, e.Token.Status)) &&
+		(len(f.Types) == 0 || slices.Contains(f.Types, e.Type)) &&
+		(f.Order == "" || e.Token.ID == f.Order)
+}
+
+// streamClient is one subscriber to the events. Events reaches it through
+// events, which is closed when it is unsubscribed or the server shuts down.
+type streamClient struct {
+	id        int64
+	remote    string
+	transport string
+	filter    streamFilter
+	since     time.Time
+	events    chan manager.Event
+	delivered atomic.Int64 // Events taken from the buffer, as counted by the client's loop
+	dropped   atomic.Int64 // Events dropped, oldest first, while the buffer was full
+}
+
+// streamClientInfo is a client as listed by GET /v1/admin/streams
+type streamClientInfo struct {
+	ID        int64        `json:"id"`
+	Remote    string       `json:"remote"`
+	Transport string       `json:"transport"`
+	Filter    streamFilter `json:"filter"`
+	Since     time.Time    `json:"since"`
+	Buffered  int          `json:"buffered"` // Events waiting in the buffer
+	Delivered int64        `json:"delivered"`
+	Dropped   int64        `json:"dropped"`
+}
+
+// streamList is the response of GET /v1/admin/streams
+type streamList struct {
+	Clients []streamClientInfo `json:"clients"`
+	Dropped int64              `json:"dropped"` // Across every client since the server started
+}
+
+// broadcaster fans manager events out to the connected stream clients
+type broadcaster struct {
+	mu      sync.Mutex
+	clients map[*streamClient]struct{}
+	nextID  int64
+	dropped int64 // Events dropped across every client, connected or gone
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{clients: make(map[*streamClient]struct{})}
+}
+
+// publish is the manager listener. It never blocks: each client the event
+// matches gets it in its buffer, in place of the oldest event there when
+// the buffer is full.
+func (b *broadcaster) publish(e manager.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.clients {
+		if !c.filter.match(e) {
+			continue
+		}
+		select {
+		case c.events <- e:
+			continue
+		default:
+		}
+		// Only publish sends, under mu, so once one is taken the send
+		// cannot block
+		select {
+		case <-c.events:
+			c.dropped.Add(1)
+			b.dropped++
+		default:
+		}
+		c.events <- e
+	}
+}
+
+// subscribe adds a client for the events filter matches; r says who it is
+func (b *broadcaster) subscribe(r *http.Request, transport string, filter streamFilter) *streamClient {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	var remote string
+	if r != nil {
+		remote = r.RemoteAddr
+	}
+	c := &streamClient{id: b.nextID, remote: remote, transport: transport, filter: filter, since: time.Now(), events: make(chan manager.Event, streamBuffer)}
+	b.clients[c] = struct{}{}
+	return c
+}
+
+func (b *broadcaster) unsubscribe(c *streamClient) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.clients[c]; ok {
+		delete(b.clients, c)
+		close(c.events)
+	}
+}
+
+// closeAll disconnects every client
+func (b *broadcaster) closeAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.clients {
+		delete(b.clients, c)
+		close(c.events)
+	}
+}
+
+// list returns the connected clients, longest connected first, and the
+// events dropped across every client so far
+func (b *broadcaster) list() ([]streamClientInfo, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	list := make([]streamClientInfo, 0, len(b.clients))
+	for _, c := range slices.SortedFunc(maps.Keys(b.clients), func(a, b *streamClient) int { return int(a.id - b.id) }) {
+		list = append(list, streamClientInfo{
+			ID: c.id, Remote: c.remote, Transport: c.transport, Filter: c.filter, Since: c.since,
+			Buffered: len(c.events), Delivered: c.delivered.Load(), Dropped: c.dropped.Load(),
+		})
+	}
+	return list, b.dropped
+}
+
+// writeMetrics adds the clients and their dropped events to /metrics
+func (b *broadcaster) writeMetrics(w io.Writer) error {
+	clients, dropped := b.list()
+	var sb strings.Builder
+	sb.WriteString("# HELP stream_clients Clients following the event streams.\n# TYPE stream_clients gauge\n")
+	counts := make(map[string]int)
+	for _, c := range clients {
+		counts[c.Transport]++
+	}
+	for _, transport := range []string{transportSSE, transportWebSocket, transportInternal} {
+		fmt.Fprintf(&sb, "stream_clients{transport=%q} %d\n", transport, counts[transport])
+	}
+	sb.WriteString("# HELP stream_events_dropped_total Events dropped for stream clients too slow to take them, across every client.\n# TYPE stream_events_dropped_total counter\n")
+	fmt.Fprintf(&sb, "stream_events_dropped_total %d\n", dropped)
+	sb.WriteString("# HELP stream_client_events_dropped_total Events dropped for each connected client.\n# TYPE stream_client_events_dropped_total counter\n")
+	for _, c := range clients {
+		fmt.Fprintf(&sb, "stream_client_events_dropped_total{client=\"%d\",transport=%q} %d\n", c.ID, c.Transport, c.Dropped)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// CloseStreams ends the open event streams, which otherwise keep a graceful
+// shutdown waiting; register it with http.Server.RegisterOnShutdown
+func (s *Server) CloseStreams() {
+	s.stream.closeAll()
+}
+
+// registerStreamRoutes mounts the live event stream
+func (s *Server) registerStreamRoutes() {
+	s.stream = newBroadcaster()
+	s.om.Subscribe(s.stream.publish)
+	s.handleStream("GET /v1/events", s.eventStream)
+}
+
+// streamsV1 lists the clients following the event streams, with what each
+// asked for and how many events it was too slow to take
+func (s *Server) streamsV1(w http.ResponseWriter, r *http.Request) {
+	var list streamList
+	list.Clients, list.Dropped = s.stream.list()
+	writeJSON(w, http.StatusOK, list)
+}
+
+// eventStream sends order events, each named after the event type with the
+// event as JSON data, as server-sent events or over a WebSocket. Public
+// callers get only the orders' public fields when they are redacted.
+func (s *Server) eventStream(w http.ResponseWriter, r *http.Request) {
+	if s.publicView(w, r) {
+		s.serveStream(w, r, func(e manager.Event) (string, any) { return e.Type, newPublicEvent(e) })
+		return
+	}
+	s.serveStream(w, r, func(e manager.Event) (string, any) { return e.Type, e })
+}
+
+// streamSink is where serveStream sends events: a server-sent event stream
+// or a WebSocket
+type streamSink interface {
+	send(name string, data []byte) error
+	ping() error
+	gone() <-chan struct{} // Closed once the client has gone
+}
+
+// sseSink sends server-sent events
+type sseSink struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	r       *http.Request
+}
+
+func (s sseSink) send(name string, data []byte) error {
+	_, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data)
+	s.flusher.Flush()
+	return err
+}
+
+func (s sseSink) ping() error {
+	_, err := fmt.Fprint(s.w, ": ping\n\n")
+	s.flusher.Flush()
+	return err
+}
+
+func (s sseSink) gone() <-chan struct{} { return s.r.Context().Done() }
+
+// serveStream sends manager events to the client, as server-sent events or,
+// when it asks to upgrade, WebSocket text messages of {"event", "data"}.
+// Each is the name and JSON data that pick returns for it, skipping those
+// it returns no name for. Only events matching the station, status and
+// type query parameters are considered. When the client falls behind and
+// events are dropped, a dropped event with their count comes before the
+// next one sent.
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, pick func(manager.Event) (string, any)) {
+	transport := transportSSE
+	if isWebSocket(r) {
+		transport = transportWebSocket
+	}
+	flusher, ok := w.(http.Flusher)
+	if transport == transportSSE && !ok {
+		writeError(w, r, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	// Subscribed before the client is told it is connected
+	client := s.stream.subscribe(r, transport, parseStreamFilter(r))
+	defer s.stream.unsubscribe(client)
+
+	var sink streamSink
+	if transport == transportWebSocket {
+		ws, ok := upgradeWebSocket(w, r, s.cfg.WebSocketOrigins)
+		if !ok {
+			return
+		}
+		defer ws.close()
+		sink = ws
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ": connected\n\n")
+		flusher.Flush()
+		sink = sseSink{w: w, flusher: flusher, r: r}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	var reported int64
+	for {
+		var err error
+		select {
+		case <-sink.gone():
+			return
+		case <-heartbeat.C:
+			err = sink.ping()
+		case e, ok := <-client.events:
+			if !ok {
+				return
+			}
+			client.delivered.Add(1)
+			if dropped := client.dropped.Load(); dropped > reported {
+				err = sink.send("dropped", fmt.Appendf(nil, `{"dropped":%d}`, dropped-reported))
+				reported = dropped
+			}
+			name, v := pick(e)
+			if name == "" || err != nil {
+				break
+			}
+			data, merr := json.Marshal(v)
+			if merr != nil {
+				log.Printf("stream event: %v", merr)
+				break
+			}
+			err = sink.send(name, data)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
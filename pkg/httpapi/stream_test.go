@@ -0,0 +1,305 @@
+package httpapi
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/fairness"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestBroadcasterDropsOldest(t *testing.T) {
+	b := newBroadcaster()
+	grill := b.subscribe(nil, transportSSE, streamFilter{Stations: []string{"grill"}, Types: []string{manager.EventCreated}})
+	all := b.subscribe(nil, transportSSE, streamFilter{})
+	for i := range streamBuffer + 3 {
+		b.publish(manager.Event{Type: manager.EventCreated, Token: &queue.Token{ID: strconv.Itoa(i), Station: "grill"}})
+	}
+	b.publish(manager.Event{Type: manager.EventCreated, Token: &queue.Token{ID: "bar", Station: "bar"}})
+	b.publish(manager.Event{Type: manager.EventPrepared, Token: &queue.Token{ID: "0", Station: "grill"}})
+
+	if n := len(grill.events); n != streamBuffer {
+		t.Fatalf("grill buffered %d, want %d", n, streamBuffer)
+	}
+	if first := <-grill.events; first.Token.ID != "3" {
+		t.Errorf("oldest kept = %s, want 3", first.Token.ID)
+	}
+	if grill.dropped.Load() != 3 || all.dropped.Load() != 5 {
+		t.Errorf("dropped grill %d, all %d", grill.dropped.Load(), all.dropped.Load())
+	}
+	clients, dropped := b.list()
+	if len(clients) != 2 || clients[0].ID != grill.id || clients[0].Dropped != 3 || clients[0].Buffered != streamBuffer-1 || dropped != 8 {
+		t.Errorf("list = %+v, dropped %d", clients, dropped)
+	}
+
+	b.unsubscribe(grill)
+	if _, dropped := b.list(); dropped != 8 {
+		t.Errorf("dropped after unsubscribing = %d, want 8", dropped)
+	}
+}
+
+func TestEventStreamReportsDropped(t *testing.T) {
+	s := newTestServer(t)
+	picked, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.serveStream(w, r, func(e manager.Event) (string, any) {
+			if e.Token.ID == "first" {
+				close(picked)
+				<-release
+			}
+			return e.Type, e.Token.ID
+		})
+	}))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/?type=created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	lines := bufio.NewScanner(res.Body)
+	lines.Scan() // Connected
+
+	// The stream is held up on the first event while the buffer overflows
+	s.stream.publish(manager.Event{Type: manager.EventCreated, Token: &queue.Token{ID: "first"}})
+	<-picked
+	for i := range streamBuffer + 5 {
+		s.stream.publish(manager.Event{Type: manager.EventCreated, Token: &queue.Token{ID: strconv.Itoa(i)}})
+		s.stream.publish(manager.Event{Type: manager.EventPrepared, Token: &queue.Token{ID: strconv.Itoa(i)}})
+	}
+	close(release)
+
+	var events []string
+	for len(events) < 3 && lines.Scan() {
+		if v, ok := strings.CutPrefix(lines.Text(), "data: "); ok {
+			events = append(events, v)
+		}
+	}
+	if want := []string{`"first"`, `{"dropped":5}`, `"5"`}; !slices.Equal(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+}
+
+// dialWebSocket opens a WebSocket to target on srv, returning the
+// connection and a reader of its frames
+func TestEventStreamOutlastsServerTimeouts(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewUnstartedServer(s)
+	srv.Config.ReadTimeout = 50 * time.Millisecond
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/v1/events?type=created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	lines := bufio.NewScanner(res.Body)
+	lines.Scan() // Connected
+	time.Sleep(150 * time.Millisecond)
+	if res, err := http.Post(srv.URL+"/v1/orders?item=tea&priority=1", "", nil); err != nil || res.StatusCode != http.StatusCreated {
+		t.Fatalf("create: %v %v", res, err)
+	}
+	for lines.Scan() {
+		if strings.HasPrefix(lines.Text(), "data: ") {
+			return
+		}
+	}
+	t.Errorf("stream ended past the server's timeouts: %v", lines.Err())
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server, target string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	io.WriteString(conn, "GET "+target+" HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: "+key+"\r\n\r\n")
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Fatalf("handshake = %s %v", res.Status, res.Header)
+	}
+	return conn, br
+}
+
+// writeClientFrame sends a masked frame, as clients must
+func writeClientFrame(t *testing.T, conn net.Conn, opcode byte, payload []byte) {
+	t.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readServerFrame reads an unmasked frame
+func readServerFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := int(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(br, ext[:])
+		n = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0F, payload
+}
+
+func TestWebSocketStream(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	conn, br := dialWebSocket(t, srv, "/v1/events?station=grill&type=created")
+
+	for _, target := range []string{"/v1/orders?item=tea&priority=1&station=bar", "/v1/orders?item=steak&priority=1&station=grill"} {
+		if res, err := http.Post(srv.URL+target, "", nil); err != nil || res.StatusCode != http.StatusCreated {
+			t.Fatalf("create %s: %v %v", target, res.Status, err)
+		}
+	}
+	opcode, payload := readServerFrame(t, br)
+	var msg struct {
+		Event string
+		Data  manager.Event
+	}
+	if err := json.Unmarshal(payload, &msg); opcode != wsText || err != nil {
+		t.Fatalf("frame %x %s: %v", opcode, payload, err)
+	}
+	if msg.Event != manager.EventCreated || msg.Data.Token.Item != "steak" {
+		t.Errorf("message = %+v", msg)
+	}
+
+	writeClientFrame(t, conn, wsPing, []byte("hi"))
+	if opcode, payload := readServerFrame(t, br); opcode != wsPong || string(payload) != "hi" {
+		t.Errorf("ping answered with %x %q", opcode, payload)
+	}
+	writeClientFrame(t, conn, wsClose, binary.BigEndian.AppendUint16(nil, wsCloseNormal))
+	if opcode, _ := readServerFrame(t, br); opcode != wsClose {
+		t.Errorf("close answered with %x", opcode)
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("after close: %v", err)
+	}
+}
+
+func TestWebSocketRefusesUnmasked(t *testing.T) {
+	srv := httptest.NewServer(newTestServer(t))
+	defer srv.Close()
+	conn, br := dialWebSocket(t, srv, "/v1/events")
+	conn.Write([]byte{0x80 | wsText, 2, 'h', 'i'})
+	opcode, payload := readServerFrame(t, br)
+	if opcode != wsClose || binary.BigEndian.Uint16(payload) != wsCloseProtocol {
+		t.Errorf("unmasked frame answered with %x %v", opcode, payload)
+	}
+}
+
+func TestWebSocketRefusesLongControlFrames(t *testing.T) {
+	srv := httptest.NewServer(newTestServer(t))
+	defer srv.Close()
+	conn, br := dialWebSocket(t, srv, "/v1/events")
+	// A 126-byte ping, one past what control frames may carry
+	frame := []byte{0x80 | wsPing, 0x80 | 126, 0, 126, 0, 0, 0, 0}
+	conn.Write(append(frame, make([]byte, 126)...))
+	opcode, payload := readServerFrame(t, br)
+	if opcode != wsClose || binary.BigEndian.Uint16(payload) != wsCloseProtocol {
+		t.Errorf("long ping answered with %x %v", opcode, payload)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.WebSocketOrigins = []string{"https://kds.example.com"}
+	srv := httptest.NewServer(New(manager.New(manager.DefaultConfig()), cfg))
+	defer srv.Close()
+	for origin, want := range map[string]int{
+		"":                                       http.StatusSwitchingProtocols,
+		"https://kds.example.com":                http.StatusSwitchingProtocols,
+		"http://" + srv.Listener.Addr().String(): http.StatusSwitchingProtocols,
+		"https://evil.example.com":               http.StatusForbidden,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/v1/events", nil)
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")))
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != want {
+			t.Errorf("origin %q: %s, want %d", origin, res.Status, want)
+		}
+	}
+}
+
+func TestStreamsAdmin(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Token = "token"
+	om := manager.New(manager.DefaultConfig())
+	s := New(om, cfg, WithFairness(fairness.New(fairness.DefaultConfig())))
+
+	slow := s.stream.subscribe(httptest.NewRequest(http.MethodGet, "/v1/events", nil), transportSSE, streamFilter{})
+	for range streamBuffer + 2 {
+		s.stream.publish(manager.Event{Type: manager.EventCreated, Token: &queue.Token{}})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/admin/streams", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	var list streamList
+	decode(t, rec, &list)
+	if len(list.Clients) != 1 || list.Clients[0].ID != slow.id || list.Clients[0].Dropped != 2 || list.Clients[0].Remote != "192.0.2.1:1234" || list.Dropped != 2 {
+		t.Errorf("streams = %+v", list)
+	}
+
+	metrics := do(t, s, http.MethodGet, "/metrics").Body.String()
+	for _, want := range []string{`stream_clients{transport="sse"} 1`, "stream_events_dropped_total 2", `stream_client_events_dropped_total{client="` + strconv.FormatInt(slow.id, 10) + `",transport="sse"} 2`} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics lack %q:\n%s", want, metrics)
+		}
+	}
+}
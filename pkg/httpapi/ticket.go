@@ -0,0 +1,86 @@
+package httpapi
+
+import (
+	"bytes"
+	_ "embed"
+	"net/http"
+	"strconv"
+
+	"awesomeProject/pkg/ticket"
+)
+
+//go:embed ticket.html
+var ticketHTML string
+
+var ticketPage = page("ticket", ticketHTML)
+
+// Ticket formats, for the format query parameter
+const (
+	ticketFormatText   = "text"   // Plain text any receipt printer prints as it comes
+	ticketFormatESCPOS = "escpos" // The text with ESC/POS commands for size, weight and the cut
+	ticketFormatHTML   = "html"
+)
+
+type ticketPageData struct {
+	Lang  string
+	Title string
+	Width int
+	Lines []ticket.Line
+	Brand brand
+}
+
+// ticketV1 renders an order as a ticket for receipt printers to pull.
+// format picks plain text, the default, ESC/POS or HTML, and
+// width the characters per line of the text formats.
+func (s *Server) ticketV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	q := r.URL.Query()
+	format := q.Get("format")
+	switch format {
+	case "":
+		format = ticketFormatText
+	case ticketFormatText, ticketFormatESCPOS, ticketFormatHTML:
+	default:
+		writeErrorf(w, r, http.StatusBadRequest, "format must be %s, %s or %s", ticketFormatText, ticketFormatESCPOS, ticketFormatHTML)
+		return
+	}
+	width := ticket.DefaultWidth
+	if v := q.Get("width"); v != "" {
+		if width, err = strconv.Atoi(v); err != nil || width < ticket.MinWidth || width > ticket.MaxWidth {
+			writeErrorf(w, r, http.StatusBadRequest, "width must be a whole number from %d to %d", ticket.MinWidth, ticket.MaxWidth)
+			return
+		}
+	}
+	span := opSpan(r, "GetOrder")
+	token, err := s.om.GetOrder(r.Context(), id)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	lang := language(w, r)
+	lines := ticket.Lines(token, lang, width)
+
+	var buf bytes.Buffer
+	switch format {
+	case ticketFormatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		buf.Write(ticket.Text(lines, width))
+	case ticketFormatESCPOS:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		buf.Write(ticket.ESCPOS(lines, width))
+	case ticketFormatHTML:
+		data := ticketPageData{Lang: lang, Title: lines[0].Text, Width: width, Lines: lines, Brand: s.branding.page()}
+		if err := ticketPage.Execute(&buf, data); err != nil {
+			writeError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,50 @@
+package httpapi
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTicketV1(t *testing.T) {
+	s := newTestServer(t)
+	do(t, s, http.MethodPost, "/v1/orders?item=pad+thai&priority=1&quantity=2&flags=peanuts,vegan&notes=no+chilli%1b%40&orderType=dine_in&table=4")
+
+	rec := do(t, s, http.MethodGet, "/v1/orders/1/ticket?width=32")
+	text := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("text ticket = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{"TOKEN 1\n", "dine in - table 4", "2 x pad thai", "Notes: no chilli @", "ALLERGY: peanuts", "vegan", "Ordered", "Pickup code"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("ticket lacks %q:\n%s", want, text)
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if len([]rune(line)) > 32 {
+			t.Errorf("line %q is wider than 32", line)
+		}
+	}
+
+	// The printer reset in the notes must not get through
+	rec = do(t, s, http.MethodGet, "/v1/orders/1/ticket?format=escpos&lang=es")
+	raw := rec.Body.Bytes()
+	if !bytes.HasPrefix(raw, []byte("\x1b@")) || !bytes.HasSuffix(raw, []byte("\x1dVB\x03")) || bytes.Count(raw, []byte("\x1b@")) != 1 {
+		t.Errorf("escpos ticket = %q", raw)
+	}
+	if !bytes.Contains(raw, []byte("C\xa2digo")) {
+		t.Errorf("escpos ticket not in code page 437: %q", raw)
+	}
+
+	rec = do(t, s, http.MethodGet, "/v1/orders/1/ticket?format=html")
+	if page := rec.Body.String(); !strings.Contains(page, `<div class="line title">Token 1</div>`) || !strings.Contains(page, `<div class="rule">`) {
+		t.Errorf("html ticket:\n%s", page)
+	}
+	if rec := do(t, s, http.MethodGet, "/v1/orders/1/ticket?format=pdf"); rec.Code != http.StatusBadRequest {
+		t.Errorf("format=pdf = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, "/v1/orders/9/ticket"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing order = %d", rec.Code)
+	}
+}
@@ -0,0 +1,80 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"awesomeProject/pkg/config"
+)
+
+// TimeoutConfig bounds how long a request may take: the default and
+// per-endpoint overrides keyed by route pattern. A zero timeout leaves
+// requests unbounded. The event streams, order waits and GraphQL
+// subscriptions are never timed out.
+type TimeoutConfig struct {
+	Default   config.Duration            `json:"default"`
+	Endpoints map[string]config.Duration `json:"endpoints"`
+}
+
+// DefaultTimeoutConfig returns the timeouts used when nothing is configured:
+// long enough for any single order, with more room for the exports and
+// snapshots that walk every order.
+func DefaultTimeoutConfig() TimeoutConfig {
+	return TimeoutConfig{
+		Default: config.Duration(10 * time.Second),
+		Endpoints: map[string]config.Duration{
+			"GET /export":            config.Duration(2 * time.Minute),
+			"GET /v1/export":         config.Duration(2 * time.Minute),
+			"GET /v1/admin/snapshot": config.Duration(time.Minute),
+			"POST /v1/admin/backups": config.Duration(time.Minute),
+			"PUT /v1/admin/snapshot": config.Duration(time.Minute),
+		},
+	}
+}
+
+// Validate checks that no timeout is negative
+func (c TimeoutConfig) Validate() error {
+	if c.Default < 0 {
+		return errors.New("the default request timeout must not be negative")
+	}
+	for pattern, d := range c.Endpoints {
+		if d < 0 {
+			return fmt.Errorf("the request timeout for %s must not be negative", pattern)
+		}
+	}
+	return nil
+}
+
+// timeoutFor returns the timeout for a route pattern, or zero for none
+func (c TimeoutConfig) timeoutFor(pattern string) time.Duration {
+	if d, ok := c.Endpoints[pattern]; ok {
+		return time.Duration(d)
+	}
+	return time.Duration(c.Default)
+}
+
+// untimed lifts the http.Server's read and write timeouts from responses
+// that stay open; they are for requests, not streams. A connection that
+// cannot have them lifted keeps them.
+func untimed(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+		next(w, r)
+	}
+}
+
+// withTimeout cancels each request's context once timeout has passed, which
+// the manager and queue backend give up on. The handler still writes the
+// response, reporting the timeout as for any other failure.
+func withTimeout(timeout time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
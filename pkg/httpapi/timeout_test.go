@@ -0,0 +1,71 @@
+package httpapi
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Timeouts.Endpoints = map[string]config.Duration{"GET /v1/orders": config.Duration(time.Nanosecond)}
+	s := New(manager.New(manager.DefaultConfig()), cfg)
+
+	if rec := do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1"); rec.Code != http.StatusCreated {
+		t.Fatalf("place status = %d", rec.Code)
+	}
+	rec := do(t, s, http.MethodGet, "/v1/orders")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("list status = %d, want 503", rec.Code)
+	}
+	var body errorBody
+	decode(t, rec, &body)
+	if body.Error != "request timed out" {
+		t.Errorf("error = %q", body.Error)
+	}
+}
+
+func TestStreamsHaveNoTimeout(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Timeouts = TimeoutConfig{Default: config.Duration(time.Millisecond)}
+	s := New(manager.New(manager.DefaultConfig()), cfg)
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close) // After the stream is closed
+
+	res, err := http.Get(srv.URL + "/v1/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	lines := bufio.NewScanner(res.Body)
+	lines.Scan() // Connected
+
+	time.Sleep(20 * time.Millisecond)
+	if res, err := http.Post(srv.URL+"/v1/orders?item=tea&priority=1", "", nil); err != nil || res.StatusCode != http.StatusCreated {
+		t.Fatalf("place: %v %v", res.Status, err)
+	}
+	for lines.Scan() {
+		if lines.Text() == "event: "+manager.EventCreated {
+			return
+		}
+	}
+	t.Fatal("stream ended")
+}
+
+func TestTimeoutConfigValidate(t *testing.T) {
+	if err := DefaultTimeoutConfig().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cfg := TimeoutConfig{Endpoints: map[string]config.Duration{"GET /export": -1}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "GET /export") {
+		t.Errorf("negative timeout: %v", err)
+	}
+}
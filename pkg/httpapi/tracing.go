@@ -0,0 +1,85 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"awesomeProject/pkg/tracing"
+)
+
+// WithTracer records a span for every request, and a child span for each
+// OrderManager call made serving it, joining the caller's trace when the
+// request carries a traceparent header
+func WithTracer(t *tracing.Tracer) Option {
+	return func(s *Server) { s.tracer = t }
+}
+
+// traced runs h in a server span named after its route pattern
+func (s *Server) traced(pattern string, h http.Handler) http.Handler {
+	method, route, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, route = "", pattern
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if sc, ok := tracing.Extract(r.Header); ok {
+			ctx = tracing.ContextWithRemote(ctx, sc)
+		}
+		name := pattern
+		if method == "" {
+			name = r.Method + " " + route
+		}
+		ctx, span := s.tracer.Start(ctx, name, tracing.KindServer)
+		defer span.End()
+		span.SetAttr("http.request.method", r.Method)
+		span.SetAttr("http.route", route)
+		span.SetAttr("url.path", r.URL.Path)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		h.ServeHTTP(rec, r.WithContext(ctx))
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		span.SetAttr("http.response.status_code", rec.status)
+		if rec.status >= 500 {
+			span.RecordError(fmt.Errorf("%d %s", rec.status, http.StatusText(rec.status)))
+		}
+	})
+}
+
+// opSpan starts a span for an OrderManager call made while serving r; finish
+// it with the call's error
+func opSpan(r *http.Request, op string) *tracing.Span {
+	_, span := tracing.Start(r.Context(), "OrderManager."+op)
+	return span
+}
+
+// statusRecorder notes the status code a handler sends
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush keeps event streams working through the recorder
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,90 @@
+package httpapi
+
+import (
+	_ "embed"
+	"log"
+	"net/http"
+
+	"awesomeProject/pkg/i18n"
+	"awesomeProject/pkg/manager"
+)
+
+//go:embed track.html
+var trackHTML string
+
+// trackPage is the customer's own view of their order, for following it on
+// a phone instead of watching the wall display
+var trackPage = page("track", trackHTML)
+
+type trackPageData struct {
+	Lang     string
+	T        func(string) string
+	Messages map[string]string
+	Brand    brand
+	Number   int
+	Item     string
+	Signed   string // For the script to fetch the status and follow its stream
+}
+
+// trackPath is the path of the tracking page for id
+func (s *Server) trackPath(id string) string {
+	return "/t/" + s.signID(id)
+}
+
+// trackPageHandler serves the tracking page of the order signed in the
+// path. The page shows the token number and follows the order's stream,
+// refetching the status each time it connects.
+func (s *Server) trackPageHandler(w http.ResponseWriter, r *http.Request) {
+	signed := r.PathValue("signed")
+	id, ok := s.verifyID(signed)
+	if !ok {
+		writeError(w, r, http.StatusForbidden, "invalid status link")
+		return
+	}
+	span := opSpan(r, "GetOrder")
+	token, err := s.om.GetOrder(r.Context(), id)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	lang := language(w, r)
+	data := trackPageData{Lang: lang, Messages: i18n.Messages(lang), Brand: s.branding.page(), Number: token.Number, Item: token.Item, Signed: signed}
+	data.T = func(msg string) string { return i18n.T(lang, msg) }
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer") // The path is the link
+	if err := trackPage.Execute(w, data); err != nil {
+		log.Printf("track page: %v", err)
+	}
+}
+
+// trackEvents streams the status of the order signed in the path, as
+// server-sent events named status, whenever it or an order of its station
+// changes: those move it up the queue and change its estimate.
+func (s *Server) trackEvents(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.verifyID(r.PathValue("signed"))
+	if !ok {
+		writeError(w, r, http.StatusForbidden, "invalid status link")
+		return
+	}
+	span := opSpan(r, "GetOrder")
+	token, err := s.om.GetOrder(r.Context(), id)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	station := token.Station
+	s.serveStream(w, r, func(e manager.Event) (string, any) {
+		if e.Token.ID != id && e.Token.Station != station {
+			return "", nil
+		}
+		token, err := s.om.GetOrder(r.Context(), id)
+		if err != nil {
+			return "", nil
+		}
+		station = token.Station
+		return "status", customerStatus(token)
+	})
+}
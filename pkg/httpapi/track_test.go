@@ -0,0 +1,86 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestTrackingPage(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Receipts.Secret = "secret"
+	cfg.Roles.KitchenTokens = map[string]string{"till": "k1tchen"}
+	s := New(manager.New(manager.DefaultConfig()), cfg)
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close) // After the stream is closed
+
+	var first, second queue.Token
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=latte&priority=1"), &first)
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1"), &second)
+	var receipt receiptBody
+	decode(t, doAs(t, s, http.MethodGet, "/v1/orders/"+second.ID+"/receipt", "k1tchen"), &receipt)
+	if receipt.TrackURL != "/t/"+receipt.Signed {
+		t.Fatalf("receipt = %+v", receipt)
+	}
+
+	rec := do(t, s, http.MethodGet, receipt.TrackURL+"?lang=es")
+	page := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(page, `<div id="number">2</div>`) || !strings.Contains(page, "<title>Su pedido</title>") ||
+		strings.Contains(page, second.PickupCode) {
+		t.Errorf("page = %d\n%s", rec.Code, page)
+	}
+	if rec := do(t, s, http.MethodGet, "/t/"+second.ID+".forged"); rec.Code != http.StatusForbidden {
+		t.Errorf("forged link = %d", rec.Code)
+	}
+
+	res, err := http.Get(srv.URL + receipt.TrackURL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	lines := bufio.NewScanner(res.Body)
+	lines.Scan() // Connected
+	got := make(chan orderStatus)
+	go func() {
+		defer close(got)
+		for lines.Scan() {
+			if v, ok := strings.CutPrefix(lines.Text(), "data: "); ok {
+				var status orderStatus
+				if json.Unmarshal([]byte(v), &status) != nil {
+					return
+				}
+				got <- status
+			}
+		}
+	}()
+	next := func() orderStatus {
+		select {
+		case status := <-got:
+			return status
+		case <-time.After(5 * time.Second):
+			t.Fatal("no status")
+			return orderStatus{}
+		}
+	}
+
+	// The order ahead is prepared, then this one
+	for _, target := range []string{"/v1/orders/next", "/v1/orders/next"} {
+		if res, err := http.Post(srv.URL+target, "", nil); err != nil || res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: %v %v", target, res.Status, err)
+		}
+	}
+	if status := next(); status.Status != queue.StatusPreparing || status.Position != 1 || status.Item != "tea" {
+		t.Errorf("after the order ahead = %+v", status)
+	}
+	if status := next(); status.Status != queue.StatusPrepared || status.PreparedAt == nil {
+		t.Errorf("after this order = %+v", status)
+	}
+}
@@ -0,0 +1,620 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/eventlog"
+	"awesomeProject/pkg/i18n"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/money"
+	"awesomeProject/pkg/queue"
+	"awesomeProject/pkg/validate"
+)
+
+// registerV1Routes mounts the JSON API
+func (s *Server) registerV1Routes() {
+	s.handle("POST /v1/orders", s.createOrderV1)
+	s.handle("POST /v1/orders/next", s.prepareNextV1)
+	s.handle("POST /v1/orders/claim", s.claimNextV1)
+	s.handle("GET /v1/orders", s.listOrdersV1)
+	s.handle("GET /v1/orders/{id}", s.getOrderV1)
+	s.handle("PATCH /v1/orders/{id}", s.modifyOrderV1)
+	s.handle("POST /v1/orders/{id}/cancel", s.cancelOrderV1)
+	s.handle("POST /v1/orders/{id}/pickup", s.pickUpOrderV1)
+	s.handle("POST /v1/orders/{id}/prepare", s.prepareOrderV1)
+	s.handle("POST /v1/orders/{id}/claim", s.claimOrderV1)
+	s.handle("POST /v1/orders/{id}/rush", s.rushOrderV1)
+	s.handle("POST /v1/orders/{id}/unprepare", s.unprepareOrderV1)
+	s.handle("POST /v1/orders/{id}/restore", s.restoreOrderV1)
+	s.handle("GET /v1/orders/{id}/qr", s.staff(s.pickupQRV1))
+	s.handle("GET /v1/orders/{id}/ticket", s.kitchen(s.ticketV1))
+	if s.events != nil {
+		s.handle("GET /v1/orders/{id}/history", s.kitchen(s.orderHistoryV1))
+	}
+}
+
+// orderFields are the parameters an order is placed with
+var orderFields = []string{"item", "priority", "quantity", "notes", "flags", "station", "orderType", "table",
+	"payment", "readyAt", "phone", "deviceToken", "platform", "externalId",
+	"group", "notifyGroup", "course", "promisedBy", "currency", "channel"}
+
+func (s *Server) createOrderV1(w http.ResponseWriter, r *http.Request) {
+	q, ok := input(w, r, orderFields...)
+	if !ok {
+		return
+	}
+	o, errs := s.parseNewOrder(q)
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	o.Client = remoteIP(r)
+	if o.Channel == "" && o.Platform == "" {
+		o.Channel = s.channel(r)
+	}
+	span := opSpan(r, "PlaceOrder")
+	token, err := s.om.PlaceOrder(r.Context(), o)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	status := http.StatusCreated
+	if token.Status == queue.StatusWaitlisted {
+		status = http.StatusAccepted
+	}
+	// Whoever places an order sees all of it, its pickup code too, once
+	writeJSON(w, status, token.Stored())
+}
+
+// parseNewOrder reads and checks the orderFields of a new order
+func (s *Server) parseNewOrder(q url.Values) (manager.NewOrder, validate.Errors) {
+	rules := s.cfg.Validation
+	var errs validate.Errors
+
+	o := manager.NewOrder{
+		Item:        q.Get("item"),
+		Notes:       q.Get("notes"),
+		Station:     q.Get("station"),
+		OrderType:   q.Get("orderType"),
+		Payment:     q.Get("payment"),
+		Phone:       q.Get("phone"),
+		DeviceToken: q.Get("deviceToken"),
+		Platform:    q.Get("platform"),
+		ExternalID:  q.Get("externalId"),
+		Group:       q.Get("group"),
+		NotifyGroup: boolParam(q, "notifyGroup", &errs),
+		Currency:    q.Get("currency"),
+		Channel:     q.Get("channel"),
+	}
+	rules.Item(&errs, o.Item)
+	rules.Notes(&errs, o.Notes)
+	rules.OrderType(&errs, o.OrderType)
+	rules.Channel(&errs, o.Channel)
+	rules.Delivery(&errs, o.Platform, o.ExternalID)
+	rules.Group(&errs, o.Group, o.NotifyGroup)
+	if o.Currency != "" && !money.Valid(o.Currency) {
+		errs.Add("currency", "must be a three-letter currency code like EUR")
+	}
+	o.Flags = listParam(q, "flags")
+	rules.Flags(&errs, o.Flags)
+	switch o.Payment {
+	case "", queue.PaymentUnpaid, queue.PaymentPaid:
+	default:
+		errs.Add("payment", "must be %s or %s", queue.PaymentUnpaid, queue.PaymentPaid)
+	}
+	if table := intParam(q, "table", &errs); table != nil {
+		o.Table = *table
+		rules.Table(&errs, o.Table, o.OrderType)
+	}
+	if course := intParam(q, "course", &errs); course != nil {
+		o.Course = *course
+		rules.Course(&errs, o.Course, o.Group)
+	}
+
+	if priority := intParam(q, "priority", &errs); priority == nil {
+		if q.Get("priority") == "" {
+			errs.Add("priority", "is required")
+		}
+	} else {
+		o.Priority = *priority
+		rules.Priority(&errs, o.Priority)
+	}
+	if quantity := intParam(q, "quantity", &errs); quantity != nil {
+		o.Quantity = *quantity
+		rules.Quantity(&errs, o.Quantity)
+	}
+	now := s.om.Now()
+	if o.ReadyAt = timeParam(q, "readyAt", &errs); !o.ReadyAt.IsZero() && !o.ReadyAt.After(now) {
+		errs.Add("readyAt", "must be in the future")
+	}
+	if o.PromisedBy = timeParam(q, "promisedBy", &errs); !o.PromisedBy.IsZero() && !o.PromisedBy.After(now) {
+		errs.Add("promisedBy", "must be in the future")
+	}
+	return o, errs
+}
+
+// maxIDLength bounds order IDs; UUIDs, the longest generated, take 36
+const maxIDLength = 64
+
+// orderID reads the {id} path segment. IDs are opaque, but every generator
+// makes them of letters, digits and dashes.
+func orderID(r *http.Request) (string, error) {
+	id := r.PathValue("id")
+	if !validID(id) {
+		return "", i18n.Errorf("invalid order id %q", id)
+	}
+	return id, nil
+}
+
+func validID(id string) bool {
+	if id == "" || len(id) > maxIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) getOrderV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	span := opSpan(r, "GetOrder")
+	token, err := s.om.GetOrder(r.Context(), id)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	setETag(w, token)
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+// modifyOrderV1 changes the fields given as query parameters: item, quantity,
+// notes, flags and priority. An empty flags value clears the flags. With
+// If-Match or version set the changes only apply to that version of the
+// order.
+func (s *Server) modifyOrderV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	q, ok := input(w, r, "item", "quantity", "notes", "flags", "priority", "version")
+	if !ok {
+		return
+	}
+	version, ok := s.ifMatch(w, r, q)
+	if !ok {
+		return
+	}
+	rules := s.cfg.Validation
+	var errs validate.Errors
+	ch := manager.OrderChanges{Version: version}
+	if q.Has("item") {
+		item := q.Get("item")
+		rules.Item(&errs, item)
+		ch.Item = &item
+	}
+	if q.Has("notes") {
+		notes := q.Get("notes")
+		rules.Notes(&errs, notes)
+		ch.Notes = &notes
+	}
+	if q.Has("flags") {
+		flags := listParam(q, "flags")
+		rules.Flags(&errs, flags)
+		ch.Flags = &flags
+	}
+	if ch.Quantity = intParam(q, "quantity", &errs); ch.Quantity != nil {
+		rules.Quantity(&errs, *ch.Quantity)
+	}
+	if ch.Priority = intParam(q, "priority", &errs); ch.Priority != nil {
+		rules.Priority(&errs, *ch.Priority)
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+
+	span := opSpan(r, "ModifyOrder")
+	token, err := s.om.ModifyOrder(r.Context(), id, ch)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionModify, token.ID, "", params(q, "item", "quantity", "notes", "flags", "priority"))
+	setETag(w, token)
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+// prepareNextV1 prepares the next order, or with station set the next order
+// for that station (an empty value meaning orders without one)
+func (s *Server) prepareNextV1(w http.ResponseWriter, r *http.Request) {
+	q, ok := input(w, r, "station")
+	if !ok {
+		return
+	}
+	var token *queue.Token
+	var err error
+	if q.Has("station") {
+		span := opSpan(r, "PrepareStationOrder")
+		token, err = s.om.PrepareStationOrder(r.Context(), q.Get("station"))
+		span.Finish(err)
+	} else {
+		span := opSpan(r, "PrepareOrder")
+		token, err = s.om.PrepareOrder(r.Context())
+		span.Finish(err)
+	}
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionPrepare, token.ID, "", params(q, "station"))
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+// claimNextV1 starts a cook on the next order, or with station set the next
+// order for that station, as prepareNextV1 chooses it
+func (s *Server) claimNextV1(w http.ResponseWriter, r *http.Request) {
+	q, ok := input(w, r, "station")
+	if !ok {
+		return
+	}
+	var token *queue.Token
+	var err error
+	if q.Has("station") {
+		span := opSpan(r, "ClaimStationOrder")
+		token, err = s.om.ClaimStationOrder(r.Context(), q.Get("station"))
+		span.Finish(err)
+	} else {
+		span := opSpan(r, "ClaimOrder")
+		token, err = s.om.ClaimOrder(r.Context())
+		span.Finish(err)
+	}
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionClaim, token.ID, "", params(q, "station"))
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+// claimOrderV1 starts a cook on one order out of queue order
+func (s *Server) claimOrderV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	if _, ok := input(w, r); !ok {
+		return
+	}
+	span := opSpan(r, "ClaimOrderByID")
+	token, err := s.om.ClaimOrderByID(r.Context(), id)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionClaim, token.ID, "", nil)
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+// rushOrderV1 moves an order to the front of the queue on behalf of the
+// manager named by the by parameter
+func (s *Server) rushOrderV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	q, ok := input(w, r, "by")
+	if !ok {
+		return
+	}
+	by := strings.TrimSpace(q.Get("by"))
+	if by == "" {
+		var errs validate.Errors
+		errs.Add("by", "is required")
+		writeValidationError(w, r, errs)
+		return
+	}
+	span := opSpan(r, "RushOrder")
+	token, err := s.om.RushOrder(r.Context(), id, by)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionRush, token.ID, by, nil)
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+// cancelOrderV1 cancels an order, with If-Match or version set only while it
+// is at that version
+func (s *Server) cancelOrderV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	q, ok := input(w, r, "version")
+	if !ok {
+		return
+	}
+	version, ok := s.ifMatch(w, r, q)
+	if !ok {
+		return
+	}
+	span := opSpan(r, "CancelOrder")
+	token, err := s.om.CancelOrder(r.Context(), id, version)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionCancel, token.ID, "", nil)
+	setETag(w, token)
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+// pickUpOrderV1 hands over a prepared order, checking the pickup code from
+// the receipt when one is given or required
+func (s *Server) pickUpOrderV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	q, ok := input(w, r, "code")
+	if !ok {
+		return
+	}
+	span := opSpan(r, "PickUpOrder")
+	token, err := s.om.PickUpOrder(r.Context(), id, q.Get("code"))
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionPickUp, token.ID, "", nil)
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+// prepareOrderV1 prepares one order out of queue order
+func (s *Server) prepareOrderV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	if _, ok := input(w, r); !ok {
+		return
+	}
+	span := opSpan(r, "PrepareOrderByID")
+	token, err := s.om.PrepareOrderByID(r.Context(), id)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionPrepare, token.ID, "", nil)
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+func (s *Server) unprepareOrderV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	if _, ok := input(w, r); !ok {
+		return
+	}
+	span := opSpan(r, "UnprepareOrder")
+	token, err := s.om.UnprepareOrder(r.Context(), id)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionUnprepare, token.ID, "", nil)
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+func (s *Server) restoreOrderV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	if _, ok := input(w, r); !ok {
+		return
+	}
+	span := opSpan(r, "RecoverOrder")
+	token, err := s.om.RecoverOrder(r.Context(), id)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionRecover, token.ID, "", nil)
+	s.writeOrder(w, r, http.StatusOK, token)
+}
+
+// orderHistory is the JSON payload for an order's recorded events
+type orderHistory struct {
+	OrderID string            `json:"orderId"`
+	Events  []eventlog.Record `json:"events"`
+}
+
+func (s *Server) orderHistoryV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	events := s.events.History(id)
+	if len(events) == 0 {
+		s.writeManagerError(w, r, manager.ErrOrderNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, orderHistory{OrderID: id, Events: events})
+}
+
+// orderList is the JSON payload for order listings
+type orderList struct {
+	Orders []*queue.Token `json:"orders"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+func (s *Server) listOrdersV1(w http.ResponseWriter, r *http.Request) {
+	f, errs := parseOrderFilter(r.URL.Query())
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	span := opSpan(r, "QueryOrders")
+	orders, total, err := s.om.QueryOrders(r.Context(), f)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.writeOrders(w, r, orderList{Orders: orders, Total: total, Limit: f.Limit, Offset: f.Offset})
+}
+
+// parseOrderFilter reads listing parameters:
+// status, from, to (RFC 3339), item, table, group, orderType, channel, payment, flag (comma
+// separated or repeated; "allergy" matches any allergy flag), priority,
+// minPriority, maxPriority, sort (id, priority, timestamp or item, prefixed with "-" for descending),
+// limit and offset.
+func parseOrderFilter(q url.Values) (manager.OrderFilter, validate.Errors) {
+	f := manager.OrderFilter{Limit: manager.DefaultListLimit}
+	var errs validate.Errors
+
+	if f.Status = q.Get("status"); f.Status != "" && !queue.ValidStatus(f.Status) {
+		errs.Add("status", "must be one of %s", strings.Join(queue.Statuses, ", "))
+	}
+	f.From = timeParam(q, "from", &errs)
+	f.To = timeParam(q, "to", &errs)
+	f.Item = q.Get("item")
+	f.Table = intParam(q, "table", &errs)
+	f.Group = q.Get("group")
+	if f.Payment = q.Get("payment"); f.Payment != "" && !queue.ValidPayment(f.Payment) {
+		errs.Add("payment", "must be one of %s", strings.Join(queue.PaymentStatuses, ", "))
+	}
+	if f.OrderType = q.Get("orderType"); f.OrderType != "" && !queue.ValidOrderType(f.OrderType) {
+		errs.Add("orderType", "must be one of %s", strings.Join(queue.OrderTypes, ", "))
+	}
+	if f.Channel = q.Get("channel"); f.Channel != "" && !queue.ValidChannel(f.Channel) {
+		errs.Add("channel", "must be one of %s", strings.Join(queue.Channels, ", "))
+	}
+
+	f.Flags = listParam(q, "flag")
+	for _, flag := range f.Flags {
+		if flag != queue.FlagAllergy && !queue.ValidFlag(flag) {
+			errs.Add("flag", "unknown flag %q", flag)
+		}
+	}
+
+	if p := intParam(q, "priority", &errs); p != nil {
+		f.MinPriority, f.MaxPriority = p, p
+	} else {
+		f.MinPriority = intParam(q, "minPriority", &errs)
+		f.MaxPriority = intParam(q, "maxPriority", &errs)
+	}
+
+	if s := q.Get("sort"); s != "" {
+		f.Sort, f.Desc = strings.TrimPrefix(s, "-"), strings.HasPrefix(s, "-")
+		switch f.Sort {
+		case "id", "priority", "timestamp", "item":
+		default:
+			errs.Add("sort", "must be id, priority, timestamp or item, optionally prefixed with -")
+		}
+	}
+
+	if n := intParam(q, "limit", &errs); n != nil {
+		if *n < 1 || *n > manager.MaxListLimit {
+			errs.Add("limit", "must be between 1 and %d", manager.MaxListLimit)
+		}
+		f.Limit = *n
+	}
+	if n := intParam(q, "offset", &errs); n != nil {
+		if *n < 0 {
+			errs.Add("offset", "must not be negative")
+		}
+		f.Offset = *n
+	}
+	return f, errs
+}
+
+// listParam collects a comma-separated parameter that may also be repeated,
+// dropping empty entries
+func listParam(q url.Values, name string) []string {
+	var list []string
+	for _, v := range q[name] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+	}
+	return list
+}
+
+// intParam parses an optional integer parameter, returning nil when absent
+func intParam(q url.Values, name string, errs *validate.Errors) *int {
+	v := q.Get(name)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		errs.Malformed(name, "must be an integer, got %q", v)
+		return nil
+	}
+	return &n
+}
+
+// boolParam parses an optional boolean parameter, returning false when absent
+func boolParam(q url.Values, name string, errs *validate.Errors) bool {
+	v := q.Get(name)
+	if v == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		errs.Malformed(name, "must be true or false, got %q", v)
+	}
+	return b
+}
+
+// timeParam parses an optional RFC 3339 parameter, returning the zero time when absent
+func timeParam(q url.Values, name string, errs *validate.Errors) time.Time {
+	v := q.Get(name)
+	if v == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		errs.Malformed(name, "must be an RFC 3339 time, got %q", v)
+		return time.Time{}
+	}
+	return t
+}
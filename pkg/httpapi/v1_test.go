@@ -0,0 +1,420 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/clock"
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/eventlog"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestCreateOrderV1(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantError  string
+	}{
+		{"valid", "/v1/orders?item=pizza&priority=2", http.StatusCreated, ""},
+		{"missing priority", "/v1/orders?item=pizza", http.StatusUnprocessableEntity, "priority: is required"},
+		{"empty priority", "/v1/orders?item=pizza&priority=", http.StatusUnprocessableEntity, "priority: is required"},
+		{"non-numeric priority", "/v1/orders?item=pizza&priority=high", http.StatusBadRequest, `priority: must be an integer, got "high"`},
+		{"priority out of range", "/v1/orders?item=pizza&priority=11", http.StatusUnprocessableEntity, "priority: must be between 0 and 10"},
+		{"empty item", "/v1/orders?item=%20&priority=1", http.StatusUnprocessableEntity, "item: must not be empty"},
+		{"zero quantity", "/v1/orders?item=pizza&priority=1&quantity=0", http.StatusUnprocessableEntity, "quantity: must be greater than 0"},
+		{"unknown order type", "/v1/orders?item=pizza&priority=1&orderType=drive_thru", http.StatusUnprocessableEntity, "orderType: must be one of dine_in, takeaway, delivery"},
+		{"unknown flag", "/v1/orders?item=pizza&priority=1&flags=nuts,spicy", http.StatusUnprocessableEntity, `flags: unknown flag "spicy", want one of nuts, peanuts, gluten, dairy, eggs, fish, shellfish, soy, sesame, vegetarian, vegan, halal, kosher`},
+		{"table for takeaway", "/v1/orders?item=pizza&priority=1&orderType=takeaway&table=4", http.StatusUnprocessableEntity, "table: only applies to dine_in orders"},
+		{"notify without group", "/v1/orders?item=pizza&priority=1&notifyGroup=true", http.StatusUnprocessableEntity, "group: is required with notifyGroup"},
+		{"malformed notify", "/v1/orders?item=pizza&priority=1&group=t4&notifyGroup=yes", http.StatusBadRequest, `notifyGroup: must be true or false, got "yes"`},
+		{"several fields", "/v1/orders?priority=99&quantity=x", http.StatusBadRequest, "item: must not be empty; priority: must be between 0 and 10; quantity: must be an integer, got \"x\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, newTestServer(t), http.MethodPost, tt.target)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantError != "" {
+				var body errorBody
+				decode(t, rec, &body)
+				var msgs []string
+				for _, fe := range body.Fields {
+					msgs = append(msgs, fe.Field+": "+fe.Message)
+				}
+				if got := strings.Join(msgs, "; "); got != tt.wantError {
+					t.Fatalf("field errors = %q, want %q", got, tt.wantError)
+				}
+				return
+			}
+			var tok queue.Token
+			decode(t, rec, &tok)
+			if tok.ID != "1" || tok.Item != "pizza" || tok.Priority != 2 || tok.Status != queue.StatusPreparing {
+				t.Fatalf("token = %+v", tok)
+			}
+		})
+	}
+}
+
+func TestCreateOrderFutureTimesUseClock(t *testing.T) {
+	start := time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC)
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(manager.New(manager.DefaultConfig(), manager.WithClock(clock.NewFake(start))), cfg)
+
+	// Past by the wall clock's reckoning, but an hour ahead of the manager's
+	ahead := start.Add(time.Hour).Format(time.RFC3339)
+	if rec := do(t, s, http.MethodPost, "/v1/orders?item=pizza&priority=1&promisedBy="+ahead); rec.Code != http.StatusCreated {
+		t.Errorf("promisedBy ahead of the clock = %d %s", rec.Code, rec.Body)
+	}
+	behind := start.Add(-time.Minute).Format(time.RFC3339)
+	if rec := do(t, s, http.MethodPost, "/v1/orders?item=pizza&priority=1&readyAt="+behind); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("readyAt behind the clock = %d", rec.Code)
+	}
+}
+
+func TestOrdersRejectsUnknownMethod(t *testing.T) {
+	rec := do(t, newTestServer(t), http.MethodDelete, "/v1/orders")
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestErrorCodesV1(t *testing.T) {
+	s := newTestServer(t)
+	for _, tt := range []struct {
+		method, target string
+		wantStatus     int
+		wantCode       string
+	}{
+		{http.MethodPost, "/v1/orders?item=pizza&priority=11", http.StatusUnprocessableEntity, "invalid_input"},
+		{http.MethodPost, "/v1/orders?item=pizza&priority=high", http.StatusBadRequest, "malformed_input"},
+		{http.MethodPost, "/v1/orders/next", http.StatusNotFound, "queue_empty"},
+		{http.MethodGet, "/v1/orders/nope", http.StatusNotFound, "order_not_found"},
+		{http.MethodGet, "/v1/stats?from=someday", http.StatusBadRequest, "bad_request"},
+	} {
+		rec := do(t, s, tt.method, tt.target)
+		var body errorBody
+		decode(t, rec, &body)
+		if rec.Code != tt.wantStatus || body.Code != tt.wantCode {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.target, rec.Code, body.Code, tt.wantStatus, tt.wantCode)
+		}
+	}
+}
+
+func TestPrepareNextV1(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := do(t, s, http.MethodPost, "/v1/orders/next")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("empty queue status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	do(t, s, http.MethodPost, "/v1/orders?item=cake&priority=5")
+	do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1")
+
+	rec = do(t, s, http.MethodPost, "/v1/orders/next")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var tok queue.Token
+	decode(t, rec, &tok)
+	if tok.Item != "tea" || tok.Status != queue.StatusPrepared {
+		t.Fatalf("prepared %+v, want tea", tok)
+	}
+
+	do(t, s, http.MethodPost, "/v1/orders?item=soup&priority=0")
+	rec = do(t, s, http.MethodPost, "/v1/orders/1/prepare")
+	decode(t, rec, &tok)
+	if rec.Code != http.StatusOK || tok.Item != "cake" || tok.Status != queue.StatusPrepared {
+		t.Fatalf("prepare by ID = %d %+v, want cake", rec.Code, tok)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders/1/prepare"); rec.Code != http.StatusConflict {
+		t.Fatalf("preparing twice status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestListOrdersV1(t *testing.T) {
+	s := newTestServer(t)
+	flags := map[int]string{3: "nuts,vegan", 4: "vegan"}
+	for i := 1; i <= 5; i++ {
+		do(t, s, http.MethodPost, fmt.Sprintf("/v1/orders?item=item%d&priority=%d&table=%d&flags=%s", i, i, i%2+1, flags[i]))
+	}
+	do(t, s, http.MethodPost, "/v1/orders/next")
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantIDs    string
+		wantTotal  int
+	}{
+		{"/v1/orders", http.StatusOK, "[2 3 4 5 1]", 5},
+		{"/v1/orders?status=prepared", http.StatusOK, "[1]", 1},
+		{"/v1/orders?sort=-priority&limit=2", http.StatusOK, "[5 4]", 5},
+		{"/v1/orders?maxPriority=2", http.StatusOK, "[2 1]", 2},
+		{"/v1/orders?table=1", http.StatusOK, "[2 4]", 2},
+		{"/v1/orders?orderType=dine_in&limit=1", http.StatusOK, "[2]", 5},
+		{"/v1/orders?orderType=delivery", http.StatusOK, "[]", 0},
+		{"/v1/orders?flag=allergy", http.StatusOK, "[3]", 1},
+		{"/v1/orders?flag=vegan", http.StatusOK, "[3 4]", 2},
+		{"/v1/orders?flag=vegan&flag=nuts", http.StatusOK, "[3]", 1},
+		{"/v1/orders?flag=spicy", http.StatusUnprocessableEntity, "", 0},
+		{"/v1/orders?orderType=boat", http.StatusUnprocessableEntity, "", 0},
+		{"/v1/orders?status=cooking", http.StatusUnprocessableEntity, "", 0},
+		{"/v1/orders?sort=price", http.StatusUnprocessableEntity, "", 0},
+		{"/v1/orders?limit=0", http.StatusUnprocessableEntity, "", 0},
+		{"/v1/orders?offset=-1", http.StatusUnprocessableEntity, "", 0},
+		{"/v1/orders?from=yesterday", http.StatusBadRequest, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := do(t, s, http.MethodGet, tt.target)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var list orderList
+			decode(t, rec, &list)
+			var ids []string
+			for _, tok := range list.Orders {
+				ids = append(ids, tok.ID)
+			}
+			if got := fmt.Sprint(ids); got != tt.wantIDs {
+				t.Errorf("IDs = %s, want %s", got, tt.wantIDs)
+			}
+			if list.Total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", list.Total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	s := newTestServer(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			do(t, s, http.MethodPost, fmt.Sprintf("/v1/orders?item=x&priority=%d", i%3))
+		}(i)
+		go func() {
+			defer wg.Done()
+			do(t, s, http.MethodPost, "/v1/orders/next")
+		}()
+		go func() {
+			defer wg.Done()
+			do(t, s, http.MethodGet, "/v1/orders")
+		}()
+	}
+	wg.Wait()
+
+	var list orderList
+	decode(t, do(t, s, http.MethodGet, "/v1/orders"), &list)
+	if list.Total != 20 {
+		t.Fatalf("total = %d, want 20", list.Total)
+	}
+}
+
+func TestCapacityV1(t *testing.T) {
+	mcfg := manager.DefaultConfig()
+	mcfg.StationMaxPending = map[string]int{"grill": 1}
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(manager.New(mcfg), cfg)
+
+	if rec := do(t, s, http.MethodPost, "/v1/orders?item=steak&priority=1&station=grill"); rec.Code != http.StatusCreated {
+		t.Fatalf("first grill order status = %d", rec.Code)
+	}
+	rec := do(t, s, http.MethodPost, "/v1/orders?item=ribs&priority=1&station=grill")
+	var body capacityBody
+	decode(t, rec, &body)
+	if rec.Code != http.StatusServiceUnavailable || body.EstimatedWaitSeconds != 180 || rec.Header().Get("Retry-After") != "180" {
+		t.Fatalf("full station = %d %+v Retry-After %q", rec.Code, body, rec.Header().Get("Retry-After"))
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders?item=salad&priority=1"); rec.Code != http.StatusCreated {
+		t.Fatalf("other station status = %d", rec.Code)
+	}
+
+	mcfg.Waitlist = true
+	s = New(manager.New(mcfg), cfg)
+	do(t, s, http.MethodPost, "/v1/orders?item=steak&priority=1&station=grill")
+	rec = do(t, s, http.MethodPost, "/v1/orders?item=ribs&priority=1&station=grill")
+	var tok queue.Token
+	decode(t, rec, &tok)
+	if rec.Code != http.StatusAccepted || tok.Status != queue.StatusWaitlisted {
+		t.Fatalf("waitlisted order = %d %+v", rec.Code, tok)
+	}
+}
+
+func TestDuplicateV1(t *testing.T) {
+	mcfg := manager.DefaultConfig()
+	mcfg.DuplicateWindow = config.Duration(time.Minute)
+	mcfg.RejectDuplicates = true
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(manager.New(mcfg), cfg)
+
+	const order = "/v1/orders?item=latte&priority=1&deviceToken=kiosk-7"
+	if rec := do(t, s, http.MethodPost, order); rec.Code != http.StatusCreated {
+		t.Fatalf("first order status = %d", rec.Code)
+	}
+	rec := do(t, s, http.MethodPost, order)
+	var body struct{ Existing *queue.Token }
+	decode(t, rec, &body)
+	if rec.Code != http.StatusConflict || body.Existing == nil || body.Existing.ID != "1" {
+		t.Fatalf("double tap = %d %+v", rec.Code, body)
+	}
+}
+
+func TestPickupCodeV1(t *testing.T) {
+	mcfg := manager.DefaultConfig()
+	mcfg.RequirePickupCode = true
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Roles.KitchenTokens = map[string]string{"counter": "k1tchen"}
+	s := New(manager.New(mcfg), cfg)
+
+	var created queue.Token
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=wrap&priority=1"), &created)
+	if created.PickupCode == "" {
+		t.Fatal("order has no pickup code")
+	}
+	qr := func(target string) *httptest.ResponseRecorder {
+		return doAs(t, s, http.MethodGet, target, "k1tchen")
+	}
+	rec := qr("/v1/orders/1/qr?scale=2")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" || !strings.HasPrefix(rec.Body.String(), "\x89PNG") {
+		t.Fatalf("qr = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec := qr("/v1/orders/1/qr?scale=0"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("qr scale 0 = %d", rec.Code)
+	}
+	if rec := qr("/v1/orders/9/qr"); rec.Code != http.StatusNotFound {
+		t.Fatalf("qr of missing order = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, "/v1/orders/1/qr"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("qr for the public = %d", rec.Code)
+	}
+	for _, target := range []string{"/v1/orders/1", "/v1/orders", "/v1/search?q=" + created.PickupCode} {
+		if body := do(t, s, http.MethodGet, target).Body.String(); strings.Contains(body, created.PickupCode) {
+			t.Errorf("%s gives away the pickup code: %s", target, body)
+		}
+	}
+
+	do(t, s, http.MethodPost, "/v1/orders/next")
+	if rec := do(t, s, http.MethodPost, "/v1/orders/1/pickup"); rec.Code != http.StatusForbidden {
+		t.Fatalf("pickup without code = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders/1/pickup?code="+strings.ToLower(created.PickupCode)); rec.Code != http.StatusOK {
+		t.Fatalf("pickup with code = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestClaimV1(t *testing.T) {
+	mcfg := manager.DefaultConfig()
+	mcfg.MaxInProgress = 1
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(manager.New(mcfg), cfg)
+	do(t, s, http.MethodPost, "/v1/orders?item=soup&priority=1&station=hot")
+	do(t, s, http.MethodPost, "/v1/orders?item=stew&priority=2&station=hot")
+
+	rec := do(t, s, http.MethodPost, "/v1/orders/claim?station=hot")
+	var claimed queue.Token
+	decode(t, rec, &claimed)
+	if rec.Code != http.StatusOK || claimed.ID != "1" || claimed.Status != queue.StatusInProgress {
+		t.Fatalf("claim = %d %+v", rec.Code, claimed)
+	}
+	rec = do(t, s, http.MethodPost, "/v1/orders/2/claim")
+	var busy busyBody
+	decode(t, rec, &busy)
+	if rec.Code != http.StatusConflict || busy.Station != "hot" || busy.Limit != 1 || busy.InProgress != 1 {
+		t.Fatalf("claim at a busy station = %d %+v", rec.Code, busy)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders/1/prepare"); rec.Code != http.StatusOK {
+		t.Fatalf("prepare claimed = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders/2/claim"); rec.Code != http.StatusOK {
+		t.Fatalf("claim after prepare = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders/claim"); rec.Code != http.StatusNotFound {
+		t.Fatalf("claim with nothing waiting = %d", rec.Code)
+	}
+}
+
+func TestRushV1(t *testing.T) {
+	mcfg := manager.DefaultConfig()
+	mcfg.MaxRushesPerHour = 1
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(manager.New(mcfg), cfg)
+	do(t, s, http.MethodPost, "/v1/orders?item=soup&priority=1")
+	do(t, s, http.MethodPost, "/v1/orders?item=stew&priority=4")
+	do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=4")
+
+	if rec := do(t, s, http.MethodPost, "/v1/orders/2/rush"); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("rush without by = %d %s", rec.Code, rec.Body)
+	}
+	rec := do(t, s, http.MethodPost, "/v1/orders/2/rush?by=sam")
+	var rushed queue.Token
+	decode(t, rec, &rushed)
+	if rec.Code != http.StatusOK || rushed.Priority != queue.RushPriority || rushed.RushedBy != "sam" {
+		t.Fatalf("rush = %d %+v", rec.Code, rushed)
+	}
+	rec = do(t, s, http.MethodPost, "/v1/orders/3/rush?by=sam")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("rush over the limit = %d %s", rec.Code, rec.Body)
+	}
+	rec = do(t, s, http.MethodPost, "/v1/orders/next")
+	var next queue.Token
+	decode(t, rec, &next)
+	if next.ID != "2" {
+		t.Fatalf("next = %+v, want the rushed order", next)
+	}
+}
+
+func TestOrderHistoryV1(t *testing.T) {
+	events, _, err := eventlog.Open(eventlog.Config{Path: filepath.Join(t.TempDir(), "events.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+	om := manager.New(manager.DefaultConfig())
+	events.Attach(om)
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	s := New(om, cfg, WithEventLog(events))
+	do(t, s, http.MethodPost, "/v1/orders?item=soup&priority=1")
+	do(t, s, http.MethodPost, "/v1/orders?item=stew&priority=2")
+	do(t, s, http.MethodPost, "/v1/orders/1/prepare")
+
+	rec := do(t, s, http.MethodGet, "/v1/orders/1/history")
+	var history orderHistory
+	decode(t, rec, &history)
+	if rec.Code != http.StatusOK || history.OrderID != "1" || len(history.Events) != 2 {
+		t.Fatalf("history = %d %+v", rec.Code, history)
+	}
+	if history.Events[0].Type != manager.EventCreated || history.Events[1].Type != manager.EventPrepared || history.Events[1].Seq != 3 {
+		t.Errorf("events = %+v", history.Events)
+	}
+	if rec := do(t, s, http.MethodGet, "/v1/orders/9/history"); rec.Code != http.StatusNotFound {
+		t.Errorf("history of an unknown order = %d", rec.Code)
+	}
+
+	// Without a log there is no history to serve
+	if rec := do(t, newTestServer(t), http.MethodGet, "/v1/orders/1/history"); rec.Code != http.StatusNotFound {
+		t.Errorf("history without a log = %d", rec.Code)
+	}
+}
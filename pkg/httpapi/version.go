@@ -0,0 +1,40 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"awesomeProject/pkg/queue"
+)
+
+// setETag sends the order's version as the response's entity tag, for
+// clients to send back in If-Match
+func setETag(w http.ResponseWriter, token *queue.Token) {
+	w.Header().Set("ETag", `"`+token.Version+`"`)
+}
+
+// ifMatch returns the version a change is conditional on, from the If-Match
+// header or the version parameter, or "" for none. When the server requires
+// one and none is given, or the two disagree, it writes the error and
+// returns false.
+func (s *Server) ifMatch(w http.ResponseWriter, r *http.Request, q url.Values) (string, bool) {
+	header := strings.TrimPrefix(strings.TrimSpace(r.Header.Get("If-Match")), "W/")
+	header = strings.Trim(header, `"`)
+	if header == "*" {
+		header = ""
+	}
+	version := q.Get("version")
+	switch {
+	case header != "" && version != "" && header != version:
+		writeError(w, r, http.StatusBadRequest, "If-Match and version disagree")
+		return "", false
+	case header != "":
+		version = header
+	}
+	if version == "" && s.cfg.RequireVersion {
+		writeError(w, r, http.StatusPreconditionRequired, "send the order's version in If-Match or version")
+		return "", false
+	}
+	return version, true
+}
@@ -0,0 +1,74 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestIfMatch(t *testing.T) {
+	s := newTestServer(t)
+	send := func(method, target, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var placed queue.Token
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1"), &placed)
+	rec := do(t, s, http.MethodGet, "/v1/orders/"+placed.ID)
+	etag := rec.Header().Get("ETag")
+	if etag != `"`+placed.Version+`"` {
+		t.Fatalf("ETag = %s, want the version %s", etag, placed.Version)
+	}
+
+	rec = send(http.MethodPatch, "/v1/orders/"+placed.ID+"?notes=hot", etag)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Fatalf("first change: %d, ETag %s", rec.Code, rec.Header().Get("ETag"))
+	}
+	// A second change against the version read before the first one
+	rec = send(http.MethodPatch, "/v1/orders/"+placed.ID+"?quantity=2", etag)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("stale change status = %d, want 409", rec.Code)
+	}
+	var stale struct{ Current *queue.Token }
+	decode(t, rec, &stale)
+	if stale.Current == nil || stale.Current.Notes != "hot" || rec.Header().Get("ETag") != `"`+stale.Current.Version+`"` {
+		t.Fatalf("stale body = %+v", stale)
+	}
+
+	if rec := send(http.MethodPost, "/v1/orders/"+placed.ID+"/cancel?version="+placed.Version, `"`+stale.Current.Version+`"`); rec.Code != http.StatusBadRequest {
+		t.Errorf("disagreeing versions status = %d, want 400", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/orders/"+placed.ID+"/cancel", strings.NewReader(`{"version":"`+stale.Current.Version+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("cancel with the current version status = %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRequireVersion(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.RequireVersion = true
+	s := New(manager.New(manager.DefaultConfig()), cfg)
+
+	var placed queue.Token
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1"), &placed)
+	if rec := do(t, s, http.MethodPost, "/v1/orders/"+placed.ID+"/cancel"); rec.Code != http.StatusPreconditionRequired {
+		t.Fatalf("cancel without a version status = %d, want 428", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/v1/orders/"+placed.ID+"/cancel?version="+placed.Version); rec.Code != http.StatusOK {
+		t.Fatalf("cancel with a version status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package httpapi
+
+import (
+	"log"
+	"net/http"
+	"strings"
+)
+
+// The JSON API is served under a version prefix, /v1. A version's routes
+// and payloads only grow: fields and endpoints are added, never changed or
+// removed. A breaking change, such as dropping the query-string inputs or
+// reshaping the order resources, goes in the next version, mounted beside
+// the last by its own register function in New, so both are served from one
+// router and clients move over at their own pace.
+//
+// The paths from before the prefix are served as aliases of their /v1
+// successors, marked with Deprecation and Link headers, and Sunset once a
+// removal date is set.
+
+// Versions lists the API versions served, oldest first
+var Versions = []string{"v1"}
+
+// registerV1 mounts every /v1 route
+func (s *Server) registerV1() {
+	s.registerV1Routes()
+	s.registerStatsRoutes()
+	s.registerPaymentRoutes()
+	s.registerStreamRoutes()
+	s.registerAnnouncementRoutes()
+	s.registerKDSRoutes()
+	s.registerDayCloseRoutes()
+	s.registerExportRoutes()
+	s.registerSearchRoutes()
+	s.registerAvailabilityRoutes()
+	s.registerCourseRoutes()
+	s.registerAdminRoutes()
+	s.registerMaintenanceRoutes()
+	s.registerRequeueRoutes()
+	s.registerAlertRoutes()
+	s.registerAuditRoutes()
+	s.registerReceiptRoutes()
+	s.registerAttachmentRoutes()
+	s.registerVoidRoutes()
+	s.registerRefireRoutes()
+	s.registerDeviceRoutes()
+	s.registerImportRoutes()
+	s.registerStationRoutes()
+	s.registerForecastRoutes()
+	s.registerPrivacyRoutes()
+	s.registerWaitRoutes()
+	s.registerFixRoutes()
+	s.registerBrandingRoutes()
+	s.registerFeatureRoutes()
+}
+
+// registerUnversionedRoutes mounts the JSON and CSV paths from before the
+// version prefix as aliases of their /v1 successors
+func (s *Server) registerUnversionedRoutes() {
+	s.handle("GET /stats", s.deprecated("/stats", "GET /v1/stats", s.statsHandler))
+	s.handle("GET /search", s.deprecated("/search", "GET /v1/search", s.searchHandler))
+	s.handle("GET /export", s.deprecated("/export", "GET /v1/export", s.kitchen(s.exportHandler)))
+}
+
+// deprecated serves h with headers pointing the client at replacement, a
+// route pattern, and logs a warning for every call
+func (s *Server) deprecated(path, replacement string, h http.HandlerFunc) http.HandlerFunc {
+	_, successor, _ := strings.Cut(replacement, " ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("deprecated endpoint %s called by %s, use %s instead", path, r.RemoteAddr, replacement)
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		if !s.cfg.Sunset.IsZero() {
+			w.Header().Set("Sunset", s.cfg.Sunset.UTC().Format(http.TimeFormat))
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,43 @@
+package httpapi
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/manager"
+)
+
+func TestUnversionedAliases(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Sunset = time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New(manager.New(manager.DefaultConfig()), cfg)
+	do(t, s, http.MethodPost, "/v1/orders?item=latte&priority=1")
+
+	for _, tc := range []struct{ old, successor string }{
+		{"/stats", "/v1/stats"},
+		{"/search?q=latte", "/v1/search"},
+		{"/export", "/v1/export"},
+		{"/listOrder", "/v1/orders"},
+	} {
+		rec := do(t, s, http.MethodGet, tc.old)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", tc.old, rec.Code)
+		}
+		h := rec.Header()
+		if h.Get("Deprecation") != "true" || h.Get("Link") != "<"+tc.successor+`>; rel="successor-version"` ||
+			h.Get("Sunset") != "Fri, 01 Jan 2027 00:00:00 GMT" {
+			t.Errorf("%s: headers = %v", tc.old, h)
+		}
+	}
+	if rec := do(t, s, http.MethodGet, "/v1/stats"); rec.Code != http.StatusOK || rec.Header().Get("Deprecation") != "" {
+		t.Errorf("/v1/stats: status = %d, Deprecation %q", rec.Code, rec.Header().Get("Deprecation"))
+	}
+
+	cfg.UnversionedRoutes = false
+	s = New(manager.New(manager.DefaultConfig()), cfg)
+	if rec := do(t, s, http.MethodGet, "/stats"); rec.Code != http.StatusNotFound {
+		t.Errorf("disabled alias status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,63 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/validate"
+)
+
+// registerVoidRoutes mounts voids and refunds, when there are managers or an
+// admin to make them
+func (s *Server) registerVoidRoutes() {
+	if s.cfg.Admin.Token == "" && len(s.cfg.Admin.Managers) == 0 {
+		return
+	}
+	s.handle("POST /v1/orders/{id}/void", s.managerOnly(s.voidOrderV1))
+}
+
+// voidOrderV1 voids a completed or cancelled order for the reason code given,
+// refunding its payment when refund is set
+func (s *Server) voidOrderV1(w http.ResponseWriter, r *http.Request, by string) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	q, ok := input(w, r, "reason", "note", "refund")
+	if !ok {
+		return
+	}
+	var errs validate.Errors
+	req := manager.VoidRequest{
+		Reason: strings.TrimSpace(q.Get("reason")),
+		Note:   strings.TrimSpace(q.Get("note")),
+		By:     by,
+		Refund: boolParam(q, "refund", &errs),
+	}
+	if req.Reason == "" {
+		errs.Add("reason", "is required")
+	}
+	if limit := s.cfg.Validation.MaxNotes; utf8.RuneCountInString(req.Note) > limit {
+		errs.Add("note", "must be at most %d characters", limit)
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+	span := opSpan(r, "VoidOrder")
+	token, err := s.om.VoidOrder(r.Context(), id, req)
+	span.Finish(err)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	s.record(r, audit.ActionVoid, token.ID, by, map[string]string{
+		"reason": req.Reason, "note": req.Note, "from": token.Void.From, "refund": strconv.FormatBool(req.Refund),
+	})
+	s.writeOrder(w, r, http.StatusOK, token)
+}
@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestVoidV1(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	cfg.Admin.Token = "t0ken"
+	cfg.Admin.Managers = map[string]string{"maria": "m4ria"}
+	trail := openAudit(t)
+	s := New(manager.New(manager.DefaultConfig()), cfg, WithAuditLog(trail))
+	void := func(target, token string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set(StaffHeader, "someone else")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	var tea, soup queue.Token
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=tea&priority=1"), &tea)
+	decode(t, do(t, s, http.MethodPost, "/v1/orders?item=soup&priority=1"), &soup)
+	do(t, s, http.MethodPost, "/v1/orders/"+tea.ID+"/prepare")
+	do(t, s, http.MethodPost, "/v1/orders/"+tea.ID+"/pickup?code="+tea.PickupCode)
+
+	if rec := void("/v1/orders/"+tea.ID+"/void?reason=quality", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("void without a token = %d", rec.Code)
+	}
+	if rec := void("/v1/orders/"+tea.ID+"/void?reason=quality", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("void with a wrong token = %d", rec.Code)
+	}
+	if rec := void("/v1/orders/"+tea.ID+"/void", "m4ria"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("void without a reason = %d", rec.Code)
+	}
+	if rec := void("/v1/orders/"+tea.ID+"/void?reason=bored", "m4ria"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("void with an unknown reason = %d", rec.Code)
+	}
+	if rec := void("/v1/orders/"+soup.ID+"/void?reason=quality", "m4ria"); rec.Code != http.StatusConflict {
+		t.Errorf("void of a waiting order = %d", rec.Code)
+	}
+
+	rec := void("/v1/orders/"+tea.ID+"/void?reason=quality&note=cold", "m4ria")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("void = %d %s", rec.Code, rec.Body)
+	}
+	var got queue.Token
+	decode(t, rec, &got)
+	if got.Status != queue.StatusVoided || got.Void == nil || got.Void.By != "maria" || got.Void.Note != "cold" {
+		t.Fatalf("voided = %+v", got.Void)
+	}
+	do(t, s, http.MethodPost, "/v1/orders/"+soup.ID+"/cancel")
+	if rec := void("/v1/orders/"+soup.ID+"/void?reason=duplicate", "t0ken"); rec.Code != http.StatusOK {
+		t.Fatalf("void with the admin token = %d %s", rec.Code, rec.Body)
+	}
+
+	entries, _ := trail.Query(audit.Filter{Action: audit.ActionVoid})
+	if len(entries) != 2 {
+		t.Fatalf("audit entries = %+v", entries)
+	}
+	for _, e := range entries {
+		if e.OrderID == tea.ID && (e.Actor != "maria" || e.Detail["reason"] != "quality" || e.Detail["from"] != queue.StatusPickedUp) {
+			t.Errorf("audit entry = %+v", e)
+		}
+	}
+
+	var day dayCloseBody
+	decode(t, do(t, s, http.MethodPost, "/v1/day/close"), &day)
+	if day.Summary.Voided != 2 || day.Summary.PickedUp != 0 || len(day.Summary.VoidReasons) != 2 {
+		t.Errorf("day close summary = %+v", day.Summary)
+	}
+}
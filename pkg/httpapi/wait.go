@@ -0,0 +1,111 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/queue"
+	"awesomeProject/pkg/validate"
+)
+
+// defaultWait and maxWait bound how long a wait request is held open, in
+// seconds; under the idle timeouts of most proxies
+const (
+	defaultWait = 30
+	maxWait     = 120
+)
+
+// registerWaitRoutes mounts the long-poll for clients that cannot keep an
+// event stream open. It takes events from the stream's broadcaster, so it
+// is registered after registerStreamRoutes.
+func (s *Server) registerWaitRoutes() {
+	s.handleStream("GET /v1/orders/{id}/wait", s.waitOrderV1)
+}
+
+// orderWait is the JSON payload of a wait: the order, and whether its status
+// changed before the wait timed out
+type orderWait struct {
+	Changed bool `json:"changed"`
+	Order   any  `json:"order"` // As view gives it
+}
+
+// waitOrderV1 holds the request until the order's status differs from
+// status, returning it as soon as it does, or for timeout seconds. Without
+// status it waits for the order to move on from the status it has now. A
+// client polls again with the status it was given.
+func (s *Server) waitOrderV1(w http.ResponseWriter, r *http.Request) {
+	id, err := orderID(r)
+	if err != nil {
+		writeBadRequest(w, r, err)
+		return
+	}
+	q := r.URL.Query()
+	var errs validate.Errors
+	since := q.Get("status")
+	if since != "" && !queue.ValidStatus(since) {
+		errs.Add("status", "must be one of %s", strings.Join(queue.Statuses, ", "))
+	}
+	timeout := defaultWait
+	if n := intParam(q, "timeout", &errs); n != nil {
+		timeout = *n
+		if timeout < 1 || timeout > maxWait {
+			errs.Add("timeout", "must be between 1 and %d", maxWait)
+		}
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+
+	// Subscribed before reading the order, so no change falls in between
+	client := s.stream.subscribe(r, transportInternal, streamFilter{Order: id})
+	defer s.stream.unsubscribe(client)
+	token, err := s.om.GetOrder(r.Context(), id)
+	if err != nil {
+		s.writeManagerError(w, r, err)
+		return
+	}
+	if since == "" {
+		since = token.Status
+	}
+	if token.Status != since {
+		s.writeWait(w, r, true, token)
+		return
+	}
+
+	timer := time.NewTimer(time.Duration(timeout) * time.Second)
+	defer timer.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timer.C:
+			s.writeWait(w, r, false, token)
+			return
+		case e, ok := <-client.events:
+			if !ok {
+				// Shutting down: answer with the order as it is, and the
+				// client polls again
+				if token, err = s.om.GetOrder(r.Context(), id); err != nil {
+					s.writeManagerError(w, r, err)
+					return
+				}
+				s.writeWait(w, r, token.Status != since, token)
+				return
+			}
+			client.delivered.Add(1)
+			if e.Token.ID == id && e.Token.Status != since {
+				s.writeWait(w, r, true, e.Token)
+				return
+			}
+		}
+	}
+}
+
+// writeWait returns the outcome of a wait, with the order's ETag, as the
+// caller's role may see it
+func (s *Server) writeWait(w http.ResponseWriter, r *http.Request, changed bool, token *queue.Token) {
+	setETag(w, token)
+	writeJSON(w, http.StatusOK, orderWait{Changed: changed, Order: s.view(w, r, token)})
+}
@@ -0,0 +1,74 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestWaitOrder(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.RateLimits = RateLimitConfig{}
+	om := manager.New(manager.DefaultConfig())
+	s := New(om, cfg)
+	token, err := om.PlaceOrder(ctx, manager.NewOrder{Item: "soup", Priority: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := "/v1/orders/" + token.ID + "/wait"
+
+	if rec := do(t, s, http.MethodGet, target+"?timeout=600"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("wait too long = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, target+"?status=ready"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("wait on an unknown status = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, "/v1/orders/nope/wait"); rec.Code != http.StatusNotFound {
+		t.Errorf("wait on an unknown order = %d", rec.Code)
+	}
+
+	type tokenWait struct {
+		Changed bool
+		Order   *queue.Token
+	}
+	var got tokenWait
+	decode(t, do(t, s, http.MethodGet, target+"?status=scheduled"), &got)
+	if !got.Changed || got.Order.Status != queue.StatusPreparing {
+		t.Errorf("wait on a stale status = %+v", got)
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target+"?timeout=10", nil))
+		done <- rec
+	}()
+	// Give the request time to subscribe before the order changes
+	time.Sleep(50 * time.Millisecond)
+	if _, err := om.PrepareOrderByID(ctx, token.ID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case rec := <-done:
+		got = tokenWait{}
+		decode(t, rec, &got)
+		if !got.Changed || got.Order.Status != queue.StatusPrepared || rec.Header().Get("ETag") != `"`+got.Order.Version+`"` {
+			t.Errorf("wait = %s", rec.Body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait did not return when the order was prepared")
+	}
+
+	start := time.Now()
+	got = tokenWait{}
+	decode(t, do(t, s, http.MethodGet, target+"?timeout=1"), &got)
+	if got.Changed || got.Order.Status != queue.StatusPrepared || time.Since(start) < time.Second {
+		t.Errorf("wait that timed out = %+v after %v", got, time.Since(start))
+	}
+}
@@ -0,0 +1,272 @@
+package httpapi
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// wsAcceptGUID is appended to the client's key to answer a WebSocket
+// handshake, as RFC 6455 fixes it
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket frame opcodes
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+)
+
+// WebSocket close codes
+const (
+	wsCloseNormal   = 1000
+	wsCloseProtocol = 1002
+	wsCloseTooBig   = 1009
+)
+
+// wsMaxFrame is the largest frame taken from a client; the streams only
+// send, so clients have nothing bigger than a ping to say
+const wsMaxFrame = 4 << 10
+
+// wsMaxControl is the largest payload of a control frame, as RFC 6455
+// fixes it
+const wsMaxControl = 125
+
+// wsWriteTimeout is how long a frame may take to send before the client is
+// taken to be gone
+const wsWriteTimeout = 10 * time.Second
+
+// isWebSocket reports whether r asks to upgrade to a WebSocket
+func isWebSocket(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsConn is the server end of a WebSocket: enough of RFC 6455 to push
+// events. It sends text messages and answers pings and the closing
+// handshake; anything else the client sends is discarded.
+type wsConn struct {
+	conn      net.Conn
+	rw        *bufio.ReadWriter
+	mu        sync.Mutex // Held while a frame is written
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// wsOriginAllowed reports whether a page from r's Origin may open a
+// WebSocket: one from the server's own host or one of origins, exact or
+// "*". Browsers always send an Origin, and are not held to the same-origin
+// policy for WebSockets, so without this any page a kitchen display visits
+// could read the stream. Other clients send none and are let in.
+func wsOriginAllowed(r *http.Request, origins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, o := range origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket completes the handshake of r, refusing pages from
+// origins other than the server's own and those given, and starts reading
+// the client's frames, writing the error and returning false when it cannot
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, origins []string) (*wsConn, bool) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, "WebSocket upgrades must be GET requests")
+		return nil, false
+	}
+	if !wsOriginAllowed(r, origins) {
+		writeError(w, r, http.StatusForbidden, "WebSocket upgrades are not allowed from this origin")
+		return nil, false
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeError(w, r, http.StatusUpgradeRequired, "only WebSocket version 13 is supported")
+		return nil, false
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		writeError(w, r, http.StatusBadRequest, "missing Sec-WebSocket-Key")
+		return nil, false
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "streaming unsupported")
+		return nil, false
+	}
+	// The server's read and write timeouts are for requests, not streams
+	conn.SetDeadline(time.Time{})
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " +
+		base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, false
+	}
+	ws := &wsConn{conn: conn, rw: rw, done: make(chan struct{})}
+	go ws.read()
+	return ws, true
+}
+
+// send writes a text message of the event's name and data
+func (ws *wsConn) send(name string, data []byte) error {
+	quoted, _ := json.Marshal(name)
+	msg := make([]byte, 0, len(data)+len(quoted)+20)
+	msg = append(msg, `{"event":`...)
+	msg = append(msg, quoted...)
+	msg = append(msg, `,"data":`...)
+	msg = append(msg, data...)
+	msg = append(msg, '}')
+	return ws.write(wsText, msg)
+}
+
+func (ws *wsConn) ping() error { return ws.write(wsPing, nil) }
+
+func (ws *wsConn) gone() <-chan struct{} { return ws.done }
+
+// write sends one unmasked, unfragmented frame
+func (ws *wsConn) write(opcode byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	ws.rw.Write(header)
+	ws.rw.Write(payload)
+	return ws.rw.Flush()
+}
+
+// closeWith starts the closing handshake with code, then closes the
+// connection
+func (ws *wsConn) closeWith(code uint16) {
+	ws.write(wsClose, binary.BigEndian.AppendUint16(nil, code))
+	ws.close()
+}
+
+// close closes the connection, ending the stream
+func (ws *wsConn) close() {
+	ws.closeOnce.Do(func() {
+		close(ws.done)
+		ws.conn.Close()
+	})
+}
+
+// read takes the client's frames until it closes or breaks the protocol
+func (ws *wsConn) read() {
+	defer ws.close()
+	for {
+		opcode, payload, err := ws.readFrame()
+		var perr wsProtocolError
+		switch {
+		case errors.As(err, &perr):
+			ws.closeWith(perr.code)
+			return
+		case err != nil:
+			return
+		}
+		switch opcode {
+		case wsClose:
+			code := uint16(wsCloseNormal)
+			if len(payload) >= 2 {
+				code = binary.BigEndian.Uint16(payload)
+			}
+			ws.closeWith(code)
+			return
+		case wsPing:
+			if ws.write(wsPong, payload) != nil {
+				return
+			}
+		}
+	}
+}
+
+// wsProtocolError is a frame the client should not have sent, closed with
+// code
+type wsProtocolError struct{ code uint16 }
+
+func (e wsProtocolError) Error() string { return "websocket protocol error" }
+
+// readFrame reads one frame from the client, unmasking its payload
+func (ws *wsConn) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(ws.rw, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0F
+	control := opcode&0x8 != 0
+	if head[1]&0x80 == 0 {
+		return 0, nil, wsProtocolError{wsCloseProtocol} // Clients must mask what they send
+	}
+	if control && head[0]&0x80 == 0 {
+		return 0, nil, wsProtocolError{wsCloseProtocol} // Control frames must not be fragmented
+	}
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if control && n > wsMaxControl {
+		return 0, nil, wsProtocolError{wsCloseProtocol}
+	}
+	if n > wsMaxFrame {
+		return 0, nil, wsProtocolError{wsCloseTooBig}
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(ws.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
@@ -0,0 +1,200 @@
+// Package i18n translates the messages people read: API errors and the
+// kitchen display. Messages are written in English in the code and looked up
+// by that text, format verbs included, in a catalog per language.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Default is the language messages are written in, used when a request asks
+// for none that is supported
+const Default = "en"
+
+//go:embed catalogs/*.json
+var catalogFiles embed.FS
+
+// catalogs maps a language to its translations, keyed by the English text
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	files, err := catalogFiles.ReadDir("catalogs")
+	if err != nil {
+		panic(err)
+	}
+	out := map[string]map[string]string{Default: {}}
+	for _, f := range files {
+		data, err := catalogFiles.ReadFile("catalogs/" + f.Name())
+		if err != nil {
+			panic(err)
+		}
+		var c map[string]string
+		if err := json.Unmarshal(data, &c); err != nil {
+			panic(fmt.Sprintf("i18n: catalog %s: %v", f.Name(), err))
+		}
+		out[strings.TrimSuffix(f.Name(), path.Ext(f.Name()))] = c
+	}
+	return out
+}
+
+// Languages returns the supported languages, sorted
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// Messages returns lang's translations keyed by their English text, empty
+// for English. The map must not be modified.
+func Messages(lang string) map[string]string {
+	return catalogs[lang]
+}
+
+// T translates msg into lang, or returns it unchanged when the catalog has
+// no translation
+func T(lang, msg string) string {
+	if s, ok := catalogs[lang][msg]; ok && s != "" {
+		return s
+	}
+	return msg
+}
+
+// Sprintf formats the translation of format. Arguments that are errors are
+// translated as by Error.
+func Sprintf(lang, format string, args ...any) string {
+	args = slices.Clone(args)
+	for i, arg := range args {
+		if err, ok := arg.(error); ok {
+			args[i] = Error(lang, err)
+		}
+	}
+	return fmt.Sprintf(T(lang, format), args...)
+}
+
+// Message is implemented by errors that can be translated: they report the
+// format and arguments their text is built from
+type Message interface {
+	Message() (format string, args []any)
+}
+
+// Errorf returns an error whose text is formatted from format and args, and
+// which Error translates
+func Errorf(format string, args ...any) error {
+	return &formatted{format: format, args: args}
+}
+
+type formatted struct {
+	format string
+	args   []any
+}
+
+func (e *formatted) Error() string            { return fmt.Sprintf(e.format, e.args...) }
+func (e *formatted) Message() (string, []any) { return e.format, e.args }
+
+// Error translates err's text into lang. Errors implementing Message are
+// formatted from their translation; otherwise the text of the first error in
+// err's chain with a translation replaces the start of err's text, which
+// covers messages wrapping a sentinel as "sentinel: detail".
+func Error(lang string, err error) string {
+	var m Message
+	if errors.As(err, &m) {
+		format, args := m.Message()
+		return Sprintf(lang, format, args...)
+	}
+	msg := err.Error()
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		key := e.Error()
+		if _, ok := catalogs[lang][key]; ok && strings.HasPrefix(msg, key) {
+			return T(lang, key) + msg[len(key):]
+		}
+	}
+	return msg
+}
+
+// Negotiate picks the language for a request: lang when it is given and
+// supported, otherwise the best supported match in an Accept-Language
+// header, otherwise Default. Regional tags fall back to their base language,
+// so es-MX selects es.
+func Negotiate(lang, acceptLanguage string) string {
+	if l, ok := match(lang); ok {
+		return l
+	}
+	type choice struct {
+		tag string
+		q   float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if tag != "" && q > 0 {
+			choices = append(choices, choice{strings.TrimSpace(tag), q})
+		}
+	}
+	slices.SortStableFunc(choices, func(a, b choice) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	for _, c := range choices {
+		if c.tag == "*" {
+			return Default
+		}
+		if l, ok := match(c.tag); ok {
+			return l
+		}
+	}
+	return Default
+}
+
+// match finds the supported language for a language tag
+func match(tag string) (string, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+	if tag == "" {
+		return "", false
+	}
+	if _, ok := catalogs[tag]; ok {
+		return tag, true
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	if _, ok := catalogs[base]; ok {
+		return base, true
+	}
+	return "", false
+}
+
+type langKey struct{}
+
+// NewContext returns a context carrying lang
+func NewContext(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, langKey{}, lang)
+}
+
+// FromContext returns the language in ctx, or Default
+func FromContext(ctx context.Context) string {
+	if lang, ok := ctx.Value(langKey{}).(string); ok {
+		return lang
+	}
+	return Default
+}
@@ -0,0 +1,71 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+var verb = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+
+// TestCatalogVerbs keeps translations formattable with their English
+// arguments
+func TestCatalogVerbs(t *testing.T) {
+	for _, lang := range Languages() {
+		for key, msg := range Messages(lang) {
+			if msg == "" {
+				t.Errorf("%s: %q has an empty translation", lang, key)
+			}
+			if want, got := verb.FindAllString(key, -1), verb.FindAllString(msg, -1); !slices.Equal(got, want) {
+				t.Errorf("%s: %q has verbs %v, want %v", lang, msg, got, want)
+			}
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, tt := range []struct {
+		lang, accept, want string
+	}{
+		{"", "", "en"},
+		{"es", "", "es"},
+		{"ES", "en", "es"},
+		{"fr", "", "en"},
+		{"fr", "es", "es"},
+		{"", "es-MX,es;q=0.9,en;q=0.8", "es"},
+		{"", "en-GB,es;q=0.5", "en"},
+		{"", "fr, es;q=0.3, en;q=0.2", "es"},
+		{"", "en;q=0.1, es", "es"},
+		{"", "es;q=0, *", "en"},
+		{"", "es;q=bad, es_AR", "es"},
+	} {
+		if got := Negotiate(tt.lang, tt.accept); got != tt.want {
+			t.Errorf("Negotiate(%q, %q) = %q, want %q", tt.lang, tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	sentinel := errors.New("order not found")
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{sentinel, "pedido no encontrado"},
+		{fmt.Errorf("%w: 12", sentinel), "pedido no encontrado: 12"},
+		{Errorf("invalid order id %q", "x"), `id de pedido no válido "x"`},
+		{errors.New("no translation"), "no translation"},
+	} {
+		if got := Error("es", tt.err); got != tt.want {
+			t.Errorf("Error(es, %q) = %q, want %q", tt.err, got, tt.want)
+		}
+		if got := Error(Default, tt.err); got != tt.err.Error() {
+			t.Errorf("Error(en, %q) = %q", tt.err, got)
+		}
+	}
+	if got := Sprintf("es", "invalid JSON: %v", sentinel); got != "JSON no válido: pedido no encontrado" {
+		t.Errorf("Sprintf = %q", got)
+	}
+}
@@ -0,0 +1,209 @@
+// Package importer reads the menu and past orders exported from another
+// system, as CSV or JSON, checking every row, so that moving to this service
+// takes no typing in. A CSV file has a header naming its columns; a JSON file
+// is an array of objects with the same names as fields.
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"awesomeProject/pkg/validate"
+)
+
+// Formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// MaxRows bounds the rows of one file
+const MaxRows = 50000
+
+var (
+	ErrFormat  = errors.New("format must be json or csv")
+	ErrTooMany = fmt.Errorf("files may have at most %d rows", MaxRows)
+)
+
+// RowError is a problem with one field of one row. Rows count from 1, for the
+// first after a CSV header or the first element of a JSON array.
+type RowError struct {
+	Row int `json:"row"`
+	validate.FieldError
+}
+
+// Report tells how an import went, row by row
+type Report struct {
+	DryRun   bool       `json:"dryRun"`
+	Rows     int        `json:"rows"`
+	Imported int        `json:"imported"` // None when any row has errors
+	Errors   []RowError `json:"errors"`
+}
+
+// OK reports whether every row can be imported
+func (r Report) OK() bool {
+	return len(r.Errors) == 0
+}
+
+// Translate returns a copy of r with each message formatted from
+// translate's version of its format
+func (r Report) Translate(translate func(format string) string) Report {
+	out := r
+	out.Errors = make([]RowError, len(r.Errors))
+	for i, e := range r.Errors {
+		out.Errors[i] = RowError{Row: e.Row, FieldError: validate.Errors{e.FieldError}.Translate(translate)[0]}
+	}
+	return out
+}
+
+// check adds the problems with row n to the report
+func (r *Report) check(n int, errs validate.Errors) {
+	for _, fe := range errs {
+		r.Errors = append(r.Errors, RowError{Row: n, FieldError: fe})
+	}
+}
+
+// row is one record, by column name; columns that are absent or empty are
+// not set
+type row map[string]string
+
+// readRows reads every row of a file in format, with columns among the ones
+// given. Columns a CSV header does not know fail the whole file; fields a
+// JSON object does not know are reported in errs for their row.
+func readRows(r io.Reader, format string, columns []string) ([]row, []validate.Errors, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(r, columns)
+	case FormatJSON:
+		return readJSON(r, columns)
+	}
+	return nil, nil, ErrFormat
+}
+
+func readCSV(r io.Reader, columns []string) ([]row, []validate.Errors, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, errors.New("csv: the file is empty")
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("csv: %w", err)
+	}
+	names := make([]string, len(header))
+	for i, h := range header {
+		h = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		for _, c := range columns {
+			if strings.EqualFold(h, c) {
+				names[i] = c
+			}
+		}
+		if names[i] == "" {
+			return nil, nil, fmt.Errorf("csv: unknown column %q, want %s", h, strings.Join(columns, ", "))
+		}
+	}
+	var rows []row
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("csv: %w", err)
+		}
+		if len(rows) == MaxRows {
+			return nil, nil, ErrTooMany
+		}
+		rw := make(row, len(rec))
+		for i, v := range rec {
+			if v = strings.TrimSpace(v); v != "" {
+				rw[names[i]] = v
+			}
+		}
+		rows = append(rows, rw)
+	}
+	return rows, make([]validate.Errors, len(rows)), nil
+}
+
+func readJSON(r io.Reader, columns []string) ([]row, []validate.Errors, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var objects []map[string]any
+	if err := dec.Decode(&objects); err != nil {
+		return nil, nil, fmt.Errorf("json: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, nil, errors.New("json: unexpected data after the array")
+	}
+	if len(objects) > MaxRows {
+		return nil, nil, ErrTooMany
+	}
+	rows := make([]row, len(objects))
+	errs := make([]validate.Errors, len(objects))
+	for i, obj := range objects {
+		rows[i] = make(row, len(obj))
+		for _, name := range slices.Sorted(maps.Keys(obj)) {
+			if !slices.Contains(columns, name) {
+				errs[i].Malformed(name, "unknown field")
+				continue
+			}
+			switch v := obj[name].(type) {
+			case nil:
+			case string:
+				if v = strings.TrimSpace(v); v != "" {
+					rows[i][name] = v
+				}
+			case json.Number:
+				rows[i][name] = v.String()
+			case bool:
+				rows[i][name] = strconv.FormatBool(v)
+			default:
+				errs[i].Malformed(name, "must be a string, number or boolean")
+			}
+		}
+	}
+	return rows, errs, nil
+}
+
+// has reports whether column name is set
+func (rw row) has(name string) bool {
+	_, ok := rw[name]
+	return ok
+}
+
+// intField parses an optional whole number
+func (rw row) intField(name string, errs *validate.Errors) (int, bool) {
+	v, ok := rw[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		errs.Malformed(name, "must be an integer, got %q", v)
+		return 0, false
+	}
+	return n, true
+}
+
+// floatField parses an optional number that may not be negative
+func (rw row) floatField(name string, errs *validate.Errors) *float64 {
+	v, ok := rw[name]
+	if !ok {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	switch {
+	case err != nil:
+		errs.Malformed(name, "must be a number, got %q", v)
+		return nil
+	case f < 0:
+		errs.Add(name, "must not be negative")
+		return nil
+	}
+	return &f
+}
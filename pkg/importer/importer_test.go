@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/queue"
+	"awesomeProject/pkg/validate"
+)
+
+func TestMenu(t *testing.T) {
+	rules := validate.DefaultRules()
+	csv := "Item,price,prepTime\nsoup,4.50,4m30s\nstew,,90\nsoup,3,\ntea,cheap,\ncake,,\n"
+	items, rep, err := Menu(strings.NewReader(csv), FormatCSV, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Rows != 5 || len(items) != 5 {
+		t.Fatalf("rows = %d, items = %d", rep.Rows, len(items))
+	}
+	if soup := items[0]; soup.Item != "soup" || *soup.Price != 4.5 || *soup.PrepTime != config.Duration(270*time.Second) || soup.Complexity != nil {
+		t.Errorf("soup = %+v", soup)
+	}
+	if stew := items[1]; stew.Price != nil || *stew.PrepTime != config.Duration(90*time.Second) {
+		t.Errorf("stew = %+v", stew)
+	}
+	want := []struct {
+		row   int
+		field string
+	}{{3, "item"}, {4, "price"}, {5, "item"}}
+	if len(rep.Errors) != len(want) {
+		t.Fatalf("errors = %+v", rep.Errors)
+	}
+	for i, w := range want {
+		if e := rep.Errors[i]; e.Row != w.row || e.Field != w.field {
+			t.Errorf("error %d = %+v, want row %d %s", i, e, w.row, w.field)
+		}
+	}
+
+	if _, _, err := Menu(strings.NewReader("item,cost\nsoup,4\n"), FormatCSV, rules); err == nil {
+		t.Error("unknown column accepted")
+	}
+	_, rep, err = Menu(strings.NewReader(`[{"item": "soup", "price": 4.5}, {"item": "stew", "size": "large", "complexity": 2}]`), FormatJSON, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Errors) != 1 || rep.Errors[0].Row != 2 || rep.Errors[0].Field != "size" {
+		t.Errorf("json errors = %+v", rep.Errors)
+	}
+	if _, _, err := Menu(strings.NewReader("[]"), "xml", rules); err != ErrFormat {
+		t.Errorf("xml = %v", err)
+	}
+}
+
+func TestOrders(t *testing.T) {
+	csv := `id,item,quantity,priority,status,orderedAt,preparedAt,closedAt,payment
+A-1,soup,2,1,picked_up,2024-02-01T12:00:00Z,2024-02-01T12:05:00Z,2024-02-01T12:09:00Z,paid
+A-2,tea,,,cancelled,2024-02-01T12:01:00Z,,2024-02-01T12:02:00Z,
+A-1,stew,1,1,preparing,2024-02-01 12:00,,2024-02-01T11:00:00Z,cash
+`
+	orders, rep, err := Orders(strings.NewReader(csv), FormatCSV, validate.DefaultRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Rows != 3 || rep.OK() {
+		t.Fatalf("report = %+v", rep)
+	}
+	soup := orders[0]
+	if soup.SourceID != "A-1" || soup.Quantity != 2 || soup.Status != queue.StatusPickedUp || soup.Payment != queue.PaymentPaid ||
+		!soup.OrderedAt.Equal(time.Date(2024, time.February, 1, 12, 0, 0, 0, time.UTC)) || soup.PreparedAt.IsZero() {
+		t.Errorf("soup = %+v", soup)
+	}
+	fields := make(map[string]bool)
+	for _, e := range rep.Errors {
+		if e.Row != 3 {
+			t.Errorf("error in row %d: %+v", e.Row, e)
+		}
+		fields[e.Field] = true
+	}
+	for _, f := range []string{"id", "status", "orderedAt", "payment"} {
+		if !fields[f] {
+			t.Errorf("no error for %s in %+v", f, rep.Errors)
+		}
+	}
+
+	_, rep, _ = Orders(strings.NewReader(`[{"item": "tea", "status": "expired", "orderedAt": "2024-02-01T12:00:00Z", "closedAt": "2024-02-01T11:00:00Z", "table": 3}]`), FormatJSON, validate.DefaultRules())
+	if len(rep.Errors) != 1 || rep.Errors[0].Field != "closedAt" {
+		t.Errorf("json errors = %+v", rep.Errors)
+	}
+}
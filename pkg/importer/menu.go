@@ -0,0 +1,66 @@
+package importer
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/validate"
+)
+
+// MenuColumns are the columns of a menu file. prepTime is a duration such as
+// 4m30s, or a number of seconds.
+var MenuColumns = []string{"item", "price", "complexity", "prepTime"}
+
+// Menu reads a menu file in format, returning its items and a report of the
+// problems with each row. It returns an error only for a file it cannot
+// read at all.
+func Menu(r io.Reader, format string, rules validate.Rules) ([]manager.MenuItem, Report, error) {
+	rows, errs, err := readRows(r, format, MenuColumns)
+	if err != nil {
+		return nil, Report{}, err
+	}
+	rep := Report{Rows: len(rows)}
+	items := make([]manager.MenuItem, 0, len(rows))
+	seen := make(map[string]int)
+	for i, rw := range rows {
+		it := manager.MenuItem{
+			Item:       strings.TrimSpace(rw["item"]),
+			Price:      rw.floatField("price", &errs[i]),
+			Complexity: rw.floatField("complexity", &errs[i]),
+		}
+		rules.Item(&errs[i], it.Item)
+		if first, ok := seen[it.Item]; ok && it.Item != "" {
+			errs[i].Add("item", "is also in row %d", first)
+		} else {
+			seen[it.Item] = i + 1
+		}
+		if v, ok := rw["prepTime"]; ok {
+			if d, ok := parseDuration(v); !ok {
+				errs[i].Malformed("prepTime", "must be a duration such as 4m30s or a number of seconds, got %q", v)
+			} else if d <= 0 {
+				errs[i].Add("prepTime", "must be greater than 0")
+			} else {
+				it.PrepTime = &d
+			}
+		}
+		if !rw.has("price") && !rw.has("complexity") && !rw.has("prepTime") {
+			errs[i].Add("item", "needs a price, complexity or prepTime")
+		}
+		rep.check(i+1, errs[i])
+		items = append(items, it)
+	}
+	return items, rep, nil
+}
+
+// parseDuration reads a Go duration or a number of seconds
+func parseDuration(v string) (config.Duration, bool) {
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		return config.Duration(secs * float64(time.Second)), true
+	}
+	d, err := time.ParseDuration(v)
+	return config.Duration(d), err == nil
+}
@@ -0,0 +1,115 @@
+package importer
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+	"awesomeProject/pkg/validate"
+)
+
+// OrderColumns are the columns of an order file. Times are RFC 3339; status
+// is one of manager.ImportStatuses and closedAt when the order was picked
+// up, expired or cancelled.
+var OrderColumns = []string{"id", "item", "quantity", "priority", "status", "orderedAt", "preparedAt", "closedAt",
+	"station", "orderType", "table", "notes", "payment", "channel"}
+
+// maxSourceID bounds the IDs orders had in the system they come from
+const maxSourceID = 100
+
+// Orders reads a file of past orders in format, returning them and a report
+// of the problems with each row. It returns an error only for a file it
+// cannot read at all.
+func Orders(r io.Reader, format string, rules validate.Rules) ([]manager.HistoricalOrder, Report, error) {
+	rows, errs, err := readRows(r, format, OrderColumns)
+	if err != nil {
+		return nil, Report{}, err
+	}
+	rep := Report{Rows: len(rows)}
+	orders := make([]manager.HistoricalOrder, 0, len(rows))
+	seen := make(map[string]int)
+	for i, rw := range rows {
+		e := &errs[i]
+		o := manager.HistoricalOrder{
+			SourceID:  rw["id"],
+			Item:      strings.TrimSpace(rw["item"]),
+			Status:    rw["status"],
+			Station:   rw["station"],
+			OrderType: rw["orderType"],
+			Notes:     rw["notes"],
+			Payment:   rw["payment"],
+			Channel:   rw["channel"],
+		}
+		switch first, dup := seen[o.SourceID]; {
+		case utf8.RuneCountInString(o.SourceID) > maxSourceID:
+			e.Add("id", "must be at most %d characters", maxSourceID)
+		case dup && o.SourceID != "":
+			e.Add("id", "is also in row %d", first)
+		default:
+			seen[o.SourceID] = i + 1
+		}
+		rules.Item(e, o.Item)
+		rules.Notes(e, o.Notes)
+		rules.OrderType(e, o.OrderType)
+		rules.Channel(e, o.Channel)
+		if n, ok := rw.intField("quantity", e); ok {
+			o.Quantity = n
+			rules.Quantity(e, n)
+		}
+		if n, ok := rw.intField("priority", e); ok {
+			o.Priority = n
+			rules.Priority(e, n)
+		}
+		if n, ok := rw.intField("table", e); ok {
+			o.Table = n
+			rules.Table(e, n, o.OrderType)
+		}
+		switch {
+		case o.Status == "":
+			e.Add("status", "is required")
+		case !slices.Contains(manager.ImportStatuses, o.Status):
+			e.Add("status", "must be one of %s", strings.Join(manager.ImportStatuses, ", "))
+		}
+		if o.Payment != "" && !slices.Contains(queue.PaymentStatuses, o.Payment) {
+			e.Add("payment", "must be one of %s", strings.Join(queue.PaymentStatuses, ", "))
+		}
+
+		o.OrderedAt = rw.timeField("orderedAt", e, true)
+		o.PreparedAt = rw.timeField("preparedAt", e, false)
+		o.ClosedAt = rw.timeField("closedAt", e, true)
+		if !o.OrderedAt.IsZero() && !o.ClosedAt.IsZero() {
+			switch {
+			case o.ClosedAt.Before(o.OrderedAt):
+				e.Add("closedAt", "must not be before %s", "orderedAt")
+			case !o.PreparedAt.IsZero() && o.PreparedAt.Before(o.OrderedAt):
+				e.Add("preparedAt", "must not be before %s", "orderedAt")
+			case o.PreparedAt.After(o.ClosedAt):
+				e.Add("preparedAt", "must not be after %s", "closedAt")
+			}
+		}
+		rep.check(i+1, *e)
+		orders = append(orders, o)
+	}
+	return orders, rep, nil
+}
+
+// timeField parses a time column, which may be required
+func (rw row) timeField(name string, errs *validate.Errors, required bool) time.Time {
+	v, ok := rw[name]
+	if !ok {
+		if required {
+			errs.Add(name, "is required")
+		}
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		errs.Malformed(name, "must be an RFC 3339 time, got %q", v)
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,69 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"awesomeProject/pkg/queue"
+)
+
+// ErrAttachmentNotFound is returned for attachments an order does not have
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// AddAttachment records a file attached to an order that has not been closed;
+// closed orders return ErrNotModifiable. The file itself is stored by the
+// caller, under a.ID.
+func (om *OrderManager) AddAttachment(ctx context.Context, id string, a queue.Attachment) (*queue.Token, error) {
+	return om.changeAttachments(ctx, id, func(token *queue.Token) error {
+		token.Attachments = append(token.Attachments, a)
+		token.Edits = append(token.Edits, queue.Edit{Field: "attachments", To: a.Name, At: om.clock.Now()})
+		return nil
+	})
+}
+
+// RemoveAttachment takes the attachment with the given ID off an order that
+// has not been closed, returning ErrAttachmentNotFound when it has none
+func (om *OrderManager) RemoveAttachment(ctx context.Context, id, attachmentID string) (*queue.Token, error) {
+	return om.changeAttachments(ctx, id, func(token *queue.Token) error {
+		i := slices.IndexFunc(token.Attachments, func(a queue.Attachment) bool { return a.ID == attachmentID })
+		if i < 0 {
+			return ErrAttachmentNotFound
+		}
+		name := token.Attachments[i].Name
+		token.Attachments = slices.Delete(slices.Clone(token.Attachments), i, i+1)
+		token.Edits = append(token.Edits, queue.Edit{Field: "attachments", From: name, At: om.clock.Now()})
+		return nil
+	})
+}
+
+// changeAttachments applies change to an open order and announces it
+func (om *OrderManager) changeAttachments(ctx context.Context, id string, change func(*queue.Token) error) (*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	token, err := om.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch token.Status {
+	case queue.StatusCancelled, queue.StatusPickedUp, queue.StatusExpired, queue.StatusVoided:
+		return nil, ErrNotModifiable
+	}
+	prior := token.Clone()
+	if err := change(token); err != nil {
+		return nil, err
+	}
+	if token.Status == queue.StatusPreparing {
+		if err := om.waiting.Update(ctx, token); err != nil {
+			*token = *prior
+			if errors.Is(err, ErrNotQueued) {
+				err = ErrNotModifiable
+			}
+			return nil, err
+		}
+	}
+	om.emit(ctx, EventModified, token)
+	c := token.Clone()
+	om.estimate(ctx, c)
+	return c, nil
+}
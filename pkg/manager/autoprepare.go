@@ -0,0 +1,84 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// autoPrepare runs cfg.AutoPrepare workers standing in for the kitchen until
+// ctx is cancelled. Each claims the next order it may, waits out the item's
+// preparation time and marks it prepared, so the usual claimed and prepared
+// events go out as a real kitchen's would.
+func (om *OrderManager) autoPrepare(ctx context.Context) {
+	// Workers out of orders sleep until anything changes; the listener only
+	// signals, as listeners must not block
+	wakes := make([]chan struct{}, om.cfg.AutoPrepare)
+	for i := range wakes {
+		wakes[i] = make(chan struct{}, 1)
+	}
+	om.Subscribe(func(Event) {
+		for _, wake := range wakes {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	})
+	var wg sync.WaitGroup
+	for _, wake := range wakes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			om.autoPrepareWorker(ctx, wake)
+		}()
+	}
+	wg.Wait()
+}
+
+func (om *OrderManager) autoPrepareWorker(ctx context.Context, wake chan struct{}) {
+	for ctx.Err() == nil {
+		token, err := om.ClaimOrder(ctx)
+		if err != nil {
+			if !errors.Is(err, ErrQueueEmpty) && !errors.Is(err, ErrStationBusy) {
+				log.Printf("auto-prepare: claim: %v", err)
+			}
+			// Also poll, for orders placed through a shared queue
+			select {
+			case <-ctx.Done():
+			case <-wake:
+			case <-om.clock.After(tickInterval):
+			}
+			continue
+		}
+		if !om.autoPrepareOrder(ctx, token) {
+			return
+		}
+	}
+}
+
+// autoPrepareOrder waits out token's preparation time and prepares it,
+// reporting false when ctx ends first. The order stays claimed then, as a
+// cook walking out leaves it.
+func (om *OrderManager) autoPrepareOrder(ctx context.Context, token *queue.Token) bool {
+	om.mu.RLock()
+	d := om.cfg.prepTime(token.Item)
+	om.mu.RUnlock()
+	if speed := om.cfg.AutoPrepareSpeed; speed > 0 {
+		d = time.Duration(float64(d) / speed)
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-om.clock.After(d):
+	}
+	// Cancelled or prepared by hand in the meantime
+	if _, err := om.PrepareOrderByID(ctx, token.ID); err != nil && !errors.Is(err, ErrNotWaiting) && !errors.Is(err, ErrOrderNotFound) {
+		log.Printf("auto-prepare: prepare order %s: %v", token.ID, err)
+	}
+	return true
+}
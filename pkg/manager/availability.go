@@ -0,0 +1,187 @@
+package manager
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+
+	"awesomeProject/pkg/queue"
+)
+
+// itemKey is the form item names are compared in for availability
+func itemKey(item string) string {
+	return strings.ToLower(strings.TrimSpace(item))
+}
+
+// MarkUnavailable records that item has run out ("86'd") and flags the orders
+// for it that have not been started yet. With BlockUnavailable set, waiting
+// ones are also parked as blocked, out of the queue, and orders leaving the
+// waitlist, a payment hold or the schedule are parked instead of queued. It
+// returns the orders flagged. Items are matched ignoring case.
+//
+// Snapshots keep the items marked; an event log replay only restores those
+// with orders still flagged.
+func (om *OrderManager) MarkUnavailable(ctx context.Context, item string) ([]*queue.Token, error) {
+	key := itemKey(item)
+	om.mu.Lock()
+	defer om.unlock()
+	if om.unavailable == nil {
+		om.unavailable = make(map[string]bool)
+	}
+	om.unavailable[key] = true
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(waiting, queueOrder)
+	var flagged []*queue.Token
+	for _, t := range slices.Concat(waiting, om.waitlist, om.unpaid, om.scheduled) {
+		if itemKey(t.Item) != key || t.Unavailable {
+			continue
+		}
+		if t.Status == queue.StatusPreparing && om.cfg.BlockUnavailable {
+			parked, err := om.park(ctx, t)
+			if err != nil {
+				return nil, err
+			}
+			if parked != nil {
+				flagged = append(flagged, parked.Clone())
+			}
+			continue
+		}
+		if t.Status == queue.StatusPreparing {
+			// A shared queue has the latest of waiting orders
+			if t, err = om.lookup(ctx, t.ID); err != nil {
+				continue
+			}
+		}
+		t.Unavailable = true
+		if t.Status == queue.StatusPreparing {
+			if err := om.waiting.Update(ctx, t); err != nil {
+				t.Unavailable = false
+				log.Printf("mark %s unavailable: order %s: %v", item, t.ID, err)
+				continue
+			}
+		}
+		om.emit(ctx, EventModified, t)
+		flagged = append(flagged, t.Clone())
+	}
+	return flagged, nil
+}
+
+// MarkAvailable records that item is back and clears the flag on its orders.
+// Orders parked for it go back into the queue with the priority and order
+// time they had, so they regain their old places. It returns the orders
+// cleared.
+func (om *OrderManager) MarkAvailable(ctx context.Context, item string) ([]*queue.Token, error) {
+	key := itemKey(item)
+	om.mu.Lock()
+	defer om.unlock()
+	delete(om.unavailable, key)
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(waiting, queueOrder)
+	var cleared []*queue.Token
+	for _, t := range slices.Concat(om.blocked, waiting, om.waitlist, om.unpaid, om.scheduled) {
+		if itemKey(t.Item) != key || !t.Unavailable {
+			continue
+		}
+		if t.Status == queue.StatusPreparing {
+			// A shared queue has the latest of waiting orders
+			if t, err = om.lookup(ctx, t.ID); err != nil {
+				continue
+			}
+		}
+		t.Unavailable = false
+		switch t.Status {
+		case queue.StatusBlocked:
+			if err := om.unblock(ctx, t); err != nil {
+				t.Unavailable = true
+				return cleared, err
+			}
+		case queue.StatusPreparing:
+			if err := om.waiting.Update(ctx, t); err != nil {
+				t.Unavailable = true
+				log.Printf("mark %s available: order %s: %v", item, t.ID, err)
+				continue
+			}
+			om.emit(ctx, EventModified, t)
+		default:
+			om.emit(ctx, EventModified, t)
+		}
+		cleared = append(cleared, t.Clone())
+	}
+	return cleared, nil
+}
+
+// UnavailableItems lists the items marked unavailable, as compared: trimmed
+// and lowercased
+func (om *OrderManager) UnavailableItems() []string {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	items := make([]string, 0, len(om.unavailable))
+	for item := range om.unavailable {
+		items = append(items, item)
+	}
+	slices.Sort(items)
+	return items
+}
+
+// enqueue puts token, placed or leaving a hold, into the queue, or parks it
+// with the blocked orders when its item is unavailable and BlockUnavailable
+// is set. It reports whether the order was parked; on an error the caller
+// restores the status it had. mu must be held.
+func (om *OrderManager) enqueue(ctx context.Context, token *queue.Token) (bool, error) {
+	token.Unavailable = om.unavailable[itemKey(token.Item)]
+	if token.Unavailable && om.cfg.BlockUnavailable {
+		token.Status = queue.StatusBlocked
+		om.blocked = append(om.blocked, token)
+		return true, nil
+	}
+	token.Status = queue.StatusPreparing
+	return false, om.waiting.Push(ctx, token)
+}
+
+// park takes a waiting order out of the queue into the blocked orders,
+// flagged, and returns it, or nil when another instance sharing the queue
+// took it first; mu must be held
+func (om *OrderManager) park(ctx context.Context, token *queue.Token) (*queue.Token, error) {
+	queued, err := om.waiting.Remove(ctx, token.ID)
+	if err != nil || queued == nil {
+		return nil, err
+	}
+	queued.Status = queue.StatusBlocked
+	queued.Unavailable = true
+	om.byID[queued.ID] = queued
+	om.blocked = append(om.blocked, queued)
+	om.emit(ctx, EventBlocked, queued)
+	return queued, nil
+}
+
+// unblock puts a parked order back into the queue; mu must be held
+func (om *OrderManager) unblock(ctx context.Context, token *queue.Token) error {
+	token.Status = queue.StatusPreparing
+	if err := om.waiting.Push(ctx, token); err != nil {
+		token.Status = queue.StatusBlocked
+		return err
+	}
+	om.blocked = removeToken(om.blocked, token)
+	om.emit(ctx, EventReleased, token)
+	return nil
+}
+
+// settle moves token between the queue and the blocked orders after its
+// item changed; mu must be held
+func (om *OrderManager) settle(ctx context.Context, token *queue.Token) error {
+	switch {
+	case token.Status == queue.StatusBlocked && !token.Unavailable:
+		return om.unblock(ctx, token)
+	case token.Status == queue.StatusPreparing && token.Unavailable && om.cfg.BlockUnavailable:
+		_, err := om.park(ctx, token)
+		return err
+	}
+	return nil
+}
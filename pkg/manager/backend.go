@@ -0,0 +1,181 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"awesomeProject/pkg/fault"
+	"awesomeProject/pkg/queue"
+)
+
+// Queue holds the orders waiting to be prepared. The manager keeps its
+// scheduled, prepared and closed lists itself; only the waiting queue is
+// delegated, so that a shared backend can let several server instances work
+// the same queue.
+//
+// The manager owns the tokens it passes to Push and treats tokens returned by
+// Pop, Get and Remove as authoritative, so a backend may keep either the
+// pushed tokens or copies of them. Backends that leave the process should
+// give up when ctx is done.
+type Queue interface {
+	// Push adds a waiting token, replacing any queued token with the same ID
+	Push(ctx context.Context, t *queue.Token) error
+	// Pop removes and returns the next token to prepare, or nil when empty
+	Pop(ctx context.Context) (*queue.Token, error)
+	// Get returns the queued token with the given ID, or nil
+	Get(ctx context.Context, id string) (*queue.Token, error)
+	// Remove takes the token with the given ID out of the queue and returns it, or nil
+	Remove(ctx context.Context, id string) (*queue.Token, error)
+	// Update stores a queued token whose fields have changed. It returns
+	// ErrNotQueued when the token has already left the queue.
+	Update(ctx context.Context, t *queue.Token) error
+	// List returns every queued token, in no particular order
+	List(ctx context.Context) ([]*queue.Token, error)
+	// Len reports how many tokens are queued
+	Len(ctx context.Context) (int, error)
+}
+
+// Ranker is implemented by queues that can count the tokens ahead of one
+// without listing the whole queue
+type Ranker interface {
+	// Ahead counts the queued tokens at t's station that are prepared
+	// before t
+	Ahead(ctx context.Context, t *queue.Token) (int, error)
+}
+
+// IDSource is implemented by queues that allocate order IDs, so instances
+// sharing the queue never hand out the same ID twice
+type IDSource interface {
+	NextID(ctx context.Context) (int, error)
+}
+
+// Option configures an OrderManager
+type Option func(*OrderManager)
+
+// WithQueue makes the manager keep waiting orders in q instead of its own
+// in-memory heap
+func WithQueue(q Queue) Option {
+	return func(om *OrderManager) { om.waiting = q }
+}
+
+// MemoryQueue is the default Queue: a heap local to one manager. It keeps the
+// tokens pushed to it and is guarded by the manager's lock. With a journal,
+// every change is recorded before it is made.
+type MemoryQueue struct {
+	pq      queue.PriorityQueue
+	byID    map[string]*queue.Token
+	journal Journal // Optional
+}
+
+// NewMemoryQueue returns an empty MemoryQueue
+func NewMemoryQueue() *MemoryQueue {
+	return NewMemoryQueueSize(0)
+}
+
+// NewMemoryQueueSize returns an empty MemoryQueue with room for n tokens
+func NewMemoryQueueSize(n int) *MemoryQueue {
+	return &MemoryQueue{pq: queue.NewPriorityQueue(n), byID: make(map[string]*queue.Token, n)}
+}
+
+func (q *MemoryQueue) Push(_ context.Context, t *queue.Token) error {
+	if err := fault.Write(fault.StoreQueue); err != nil {
+		return err
+	}
+	if err := q.record(HeapPush, t); err != nil {
+		return err
+	}
+	q.push(t)
+	return nil
+}
+
+// record tells the journal, if any, of op about to be applied to t
+func (q *MemoryQueue) record(op string, t *queue.Token) error {
+	if q.journal == nil {
+		return nil
+	}
+	return q.journal.Append(op, t)
+}
+
+// push adds t, for rebuilding a queue where injected faults do not apply
+func (q *MemoryQueue) push(t *queue.Token) {
+	if old, ok := q.byID[t.ID]; ok {
+		q.pq.Remove(old)
+	}
+	q.pq.PushToken(t)
+	q.byID[t.ID] = t
+}
+
+func (q *MemoryQueue) Pop(_ context.Context) (*queue.Token, error) {
+	if q.pq.Len() == 0 {
+		return nil, nil
+	}
+	if err := q.record(HeapPop, q.pq[0]); err != nil {
+		return nil, err
+	}
+	t := q.pq.PopToken()
+	delete(q.byID, t.ID)
+	return t, nil
+}
+
+func (q *MemoryQueue) Get(_ context.Context, id string) (*queue.Token, error) {
+	return q.byID[id], nil
+}
+
+func (q *MemoryQueue) Remove(_ context.Context, id string) (*queue.Token, error) {
+	t, ok := q.byID[id]
+	if !ok {
+		return nil, nil
+	}
+	if err := q.record(HeapRemove, t); err != nil {
+		return nil, err
+	}
+	q.pq.Remove(t)
+	delete(q.byID, id)
+	return t, nil
+}
+
+func (q *MemoryQueue) Update(ctx context.Context, t *queue.Token) error {
+	old, ok := q.byID[t.ID]
+	switch {
+	case !ok:
+		return ErrNotQueued
+	case old != t:
+		return q.Push(ctx, t)
+	}
+	if err := q.record(HeapFix, t); err != nil {
+		return err
+	}
+	q.pq.Fix(t)
+	return nil
+}
+
+// List returns the queued tokens in heap order
+func (q *MemoryQueue) List(_ context.Context) ([]*queue.Token, error) {
+	return append([]*queue.Token(nil), q.pq...), nil
+}
+
+// Ahead walks only the part of the heap ahead of t
+func (q *MemoryQueue) Ahead(_ context.Context, t *queue.Token) (int, error) {
+	return q.pq.Ahead(t, func(o *queue.Token) bool { return o.Station == t.Station }), nil
+}
+
+func (q *MemoryQueue) Len(_ context.Context) (int, error) {
+	return q.pq.Len(), nil
+}
+
+// Verify checks the heap invariants, and that the ID index holds exactly
+// the tokens in the heap
+func (q *MemoryQueue) Verify() error {
+	if err := q.pq.Verify(); err != nil {
+		return err
+	}
+	if len(q.byID) != len(q.pq) {
+		return fmt.Errorf("%d tokens in the heap but %d in the ID index", len(q.pq), len(q.byID))
+	}
+	for i, t := range q.pq {
+		if q.byID[t.ID] != t {
+			return fmt.Errorf("token %s at position %d is not the one indexed under its ID", t.ID, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package manager
+
+import (
+	"context"
+
+	"awesomeProject/pkg/queue"
+)
+
+// CancelOrder takes an order that has not been prepared yet out of the queue
+// or whichever list holds it and marks it cancelled.
+// Orders that have already been prepared return ErrNotCancellable. With
+// version set the order is only cancelled while it is still at that version.
+func (om *OrderManager) CancelOrder(ctx context.Context, id, version string) (*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	token, err := om.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(token, version); err != nil {
+		return nil, err
+	}
+	switch token.Status {
+	case queue.StatusPreparing:
+		queued, err := om.waiting.Remove(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if queued == nil {
+			return nil, ErrNotCancellable
+		}
+		token = queued
+		om.byID[id] = token
+	case queue.StatusScheduled:
+		om.unschedule(token)
+	case queue.StatusWaitlisted:
+		om.waitlist = removeToken(om.waitlist, token)
+	case queue.StatusBlocked:
+		om.blocked = removeToken(om.blocked, token)
+	case queue.StatusOnHold:
+		om.onHold = removeToken(om.onHold, token)
+	case queue.StatusAwaitingPayment:
+		om.unpaid = removeToken(om.unpaid, token)
+	case queue.StatusInProgress:
+		om.inProgress = removeToken(om.inProgress, token)
+	default:
+		return nil, ErrNotCancellable
+	}
+
+	now := om.clock.Now()
+	token.Status = queue.StatusCancelled
+	token.CancelledAt = &now
+	om.closed = append(om.closed, token)
+	om.emit(ctx, EventCancelled, token)
+	om.groupReady(token)
+	om.drainWaitlist(ctx)
+	return token.Clone(), nil
+}
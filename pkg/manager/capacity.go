@@ -0,0 +1,156 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// prepSamples is how many recent prepare times are kept per station to
+// measure its pace
+const prepSamples = 20
+
+// CapacityError is returned by PlaceOrder when the order's station already
+// has its maximum number of waiting orders and the waitlist is off. It wraps
+// ErrQueueFull.
+type CapacityError struct {
+	Station       string
+	Pending       int
+	Limit         int
+	EstimatedWait time.Duration // Until the orders already waiting are prepared
+}
+
+func (e *CapacityError) Error() string {
+	format, args := e.Message()
+	return fmt.Sprintf(format, args...)
+}
+
+// Message returns the format and arguments of the error text, for translation
+func (e *CapacityError) Message() (string, []any) {
+	return "station %q is full: %d orders waiting, estimated wait %s",
+		[]any{e.Station, e.Pending, e.EstimatedWait.Round(time.Second)}
+}
+
+func (e *CapacityError) Unwrap() error { return ErrQueueFull }
+
+// maxPending returns the waiting-order limit for station, zero for none
+func (c Config) maxPending(station string) int {
+	if n, ok := c.StationMaxPending[station]; ok {
+		return n
+	}
+	return c.MaxPending
+}
+
+// admit checks whether a new order for station may enter the queue now. It
+// returns false while the station is at its limit or still has waitlisted
+// orders, which go first. mu must be held.
+func (om *OrderManager) admit(ctx context.Context, station string) (bool, error) {
+	limit := om.cfg.maxPending(station)
+	if limit <= 0 {
+		return true, nil
+	}
+	for _, t := range om.waitlist {
+		if t.Station == station {
+			return false, nil
+		}
+	}
+	pending, err := om.pending(ctx)
+	if err != nil {
+		return false, err
+	}
+	return pending[station] < limit, nil
+}
+
+// capacityError describes a full station; mu must be held
+func (om *OrderManager) capacityError(ctx context.Context, station string) error {
+	pending, err := om.pending(ctx)
+	if err != nil {
+		return err
+	}
+	n := pending[station]
+	return &CapacityError{
+		Station:       station,
+		Pending:       n,
+		Limit:         om.cfg.maxPending(station),
+		EstimatedWait: time.Duration(n) * om.prepInterval(station),
+	}
+}
+
+// pending counts waiting orders per station; mu must be held
+func (om *OrderManager) pending(ctx context.Context) (map[string]int, error) {
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, t := range waiting {
+		counts[t.Station]++
+	}
+	return counts, nil
+}
+
+// drainWaitlist queues waitlisted orders, oldest first, as their stations
+// get room; mu must be held. Orders the queue refuses are retried on the
+// next tick.
+func (om *OrderManager) drainWaitlist(ctx context.Context) {
+	if len(om.waitlist) == 0 {
+		return
+	}
+	pending, err := om.pending(ctx)
+	if err != nil {
+		log.Printf("drain waitlist: %v", err)
+		return
+	}
+	kept := om.waitlist[:0]
+	full := make(map[string]bool)
+	for _, t := range om.waitlist {
+		limit := om.cfg.maxPending(t.Station)
+		if full[t.Station] || (limit > 0 && pending[t.Station] >= limit) {
+			// Later orders for this station stay behind this one
+			full[t.Station] = true
+			kept = append(kept, t)
+			continue
+		}
+		parked, err := om.enqueue(ctx, t)
+		if err != nil {
+			log.Printf("release order %s: %v", t.ID, err)
+			t.Status = queue.StatusWaitlisted
+			full[t.Station] = true
+			kept = append(kept, t)
+			continue
+		}
+		if parked {
+			om.emit(ctx, EventBlocked, t)
+			continue
+		}
+		pending[t.Station]++
+		om.emit(ctx, EventReleased, t)
+	}
+	clear(om.waitlist[len(kept):])
+	om.waitlist = kept
+}
+
+// recordPrepare notes that an order for station was prepared at; mu must be held
+func (om *OrderManager) recordPrepare(station string, at time.Time) {
+	if om.prepTimes == nil {
+		om.prepTimes = make(map[string][]time.Time)
+	}
+	times := append(om.prepTimes[station], at)
+	if len(times) > prepSamples {
+		times = times[len(times)-prepSamples:]
+	}
+	om.prepTimes[station] = times
+}
+
+// prepInterval is the average time between recent prepares at station, or
+// the configured default until there are enough of them; mu must be held
+func (om *OrderManager) prepInterval(station string) time.Duration {
+	times := om.prepTimes[station]
+	if len(times) < 2 {
+		return time.Duration(om.cfg.DefaultPrepTime)
+	}
+	return times[len(times)-1].Sub(times[0]) / time.Duration(len(times)-1)
+}
@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"awesomeProject/pkg/queue"
+)
+
+// StationBusyError is returned by the claim methods when the order's station
+// already has its maximum number of orders in progress. It wraps
+// ErrStationBusy.
+type StationBusyError struct {
+	Station    string
+	InProgress int
+	Limit      int
+}
+
+func (e *StationBusyError) Error() string {
+	format, args := e.Message()
+	return fmt.Sprintf(format, args...)
+}
+
+// Message returns the format and arguments of the error text, for translation
+func (e *StationBusyError) Message() (string, []any) {
+	return "station %q is busy: %d of %d orders in progress", []any{e.Station, e.InProgress, e.Limit}
+}
+
+func (e *StationBusyError) Unwrap() error { return ErrStationBusy }
+
+// maxInProgress returns the in-progress limit for station, zero for none
+func (c Config) maxInProgress(station string) int {
+	if n, ok := c.StationMaxInProgress[station]; ok {
+		return n
+	}
+	return c.MaxInProgress
+}
+
+// inProgressAt counts the orders claimed at station; mu must be held
+func (om *OrderManager) inProgressAt(station string) int {
+	n := 0
+	for _, t := range om.inProgress {
+		if t.Station == station {
+			n++
+		}
+	}
+	return n
+}
+
+// busy returns a *StationBusyError when station has no room for another
+// claim, or nil; mu must be held
+func (om *OrderManager) busy(station string) error {
+	limit := om.cfg.maxInProgress(station)
+	if n := om.inProgressAt(station); limit > 0 && n >= limit {
+		return &StationBusyError{Station: station, InProgress: n, Limit: limit}
+	}
+	return nil
+}
+
+// ClaimOrder takes the order the strategy chooses out of the queue and marks
+// it in progress, for a cook starting on it. Orders at stations with no
+// room are passed over; when only those are waiting the error is a
+// *StationBusyError for the strategy's choice. It returns ErrQueueEmpty when
+// nothing is waiting but at paused stations.
+func (om *OrderManager) ClaimOrder(ctx context.Context) (*queue.Token, error) {
+	return om.claimNext(ctx, nil)
+}
+
+// ClaimStationOrder claims the next of station's waiting orders, as
+// PrepareStationOrder prepares it. It returns a *StationBusyError when the
+// station has no room, ErrQueueEmpty when it has nothing waiting and
+// ErrStationPaused while it is paused.
+func (om *OrderManager) ClaimStationOrder(ctx context.Context, station string) (*queue.Token, error) {
+	return om.claimNext(ctx, &station)
+}
+
+func (om *OrderManager) claimNext(ctx context.Context, station *string) (*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	if station != nil && om.stationPaused(*station) {
+		return nil, ErrStationPaused
+	}
+	eligible := atStation(station)
+	token, err := om.next(ctx, func(t *queue.Token) bool {
+		return (eligible == nil || eligible(t)) && om.busy(t.Station) == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if token != nil {
+		return om.markClaimed(ctx, token), nil
+	}
+
+	// Nothing claimable: say whether anything is waiting at a busy station
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []*queue.Token
+	for _, t := range waiting {
+		if (eligible == nil || eligible(t)) && !om.stationPaused(t.Station) {
+			candidates = append(candidates, t)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, ErrQueueEmpty
+	}
+	return nil, om.busy(om.strategy.Next(candidates).Station)
+}
+
+// ClaimOrderByID claims a particular waiting order, wherever it is in the
+// queue. Orders not in the queue return ErrNotWaiting.
+func (om *OrderManager) ClaimOrderByID(ctx context.Context, id string) (*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	token, err := om.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if token.Status != queue.StatusPreparing {
+		return nil, ErrNotWaiting
+	}
+	if err := om.busy(token.Station); err != nil {
+		return nil, err
+	}
+	queued, err := om.waiting.Remove(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if queued == nil {
+		return nil, ErrNotWaiting
+	}
+	return om.markClaimed(ctx, queued), nil
+}
+
+// markClaimed records that token, just taken out of the queue, is in progress
+// and returns a copy; mu must be held
+func (om *OrderManager) markClaimed(ctx context.Context, token *queue.Token) *queue.Token {
+	now := om.clock.Now()
+	token.Status = queue.StatusInProgress
+	token.ClaimedAt = &now
+	om.byID[token.ID] = token
+	om.inProgress = append(om.inProgress, token)
+	om.emit(ctx, EventClaimed, token)
+	om.drainWaitlist(ctx)
+	c := token.Clone()
+	om.estimate(ctx, c)
+	return c
+}
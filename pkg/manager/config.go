@@ -0,0 +1,286 @@
+package manager
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"awesomeProject/pkg/config"
+)
+
+// Config holds the OrderManager settings
+type Config struct {
+	// ScheduleLeadTime is how long before its ready time a pre-order is
+	// released into the queue
+	ScheduleLeadTime config.Duration `json:"scheduleLeadTime"`
+
+	// PreparedTTL is how long a prepared order waits for pickup before it
+	// expires. Zero keeps prepared orders until they are picked up.
+	PreparedTTL config.Duration `json:"preparedTTL"`
+
+	// UnprepareGrace is how long after PrepareOrder a cook may undo it
+	UnprepareGrace config.Duration `json:"unprepareGrace"`
+
+	// CancelRecovery is how long after CancelOrder the order may be
+	// recovered with RecoverOrder
+	CancelRecovery config.Duration `json:"cancelRecovery"`
+
+	// MaxPending caps the orders waiting in the queue per station. Zero
+	// leaves stations unbounded.
+	MaxPending int `json:"maxPending"`
+
+	// StationMaxPending overrides MaxPending for the named stations
+	StationMaxPending map[string]int `json:"stationMaxPending"`
+
+	// Waitlist holds orders placed while their station is full and queues
+	// them as room frees up, instead of rejecting them with ErrQueueFull
+	Waitlist bool `json:"waitlist"`
+
+	// BlockUnavailable parks waiting orders whose item is marked unavailable
+	// out of the queue until it is back, instead of only flagging them
+	BlockUnavailable bool `json:"blockUnavailable"`
+
+	// DefaultPrepTime estimates the time per order for wait estimates until
+	// a station has prepared enough orders to measure its pace
+	DefaultPrepTime config.Duration `json:"defaultPrepTime"`
+
+	// ItemPrepTimes gives the usual preparation time of each item, used by
+	// the sjf strategy and ready-time estimates; other items take
+	// DefaultPrepTime
+	ItemPrepTimes map[string]config.Duration `json:"itemPrepTimes"`
+
+	// QueueCapacity preallocates room for this many waiting orders in the
+	// in-memory queue, so very large queues do not grow it while busy. Zero
+	// grows it as orders arrive.
+	QueueCapacity int `json:"queueCapacity"`
+
+	// Cooks is how many orders each station prepares at once, for
+	// ready-time estimates. Zero means one.
+	Cooks int `json:"cooks"`
+
+	// StationCooks overrides Cooks for the named stations
+	StationCooks map[string]int `json:"stationCooks"`
+
+	// MaxInProgress caps the orders each station's cooks may have claimed
+	// at once; further claims fail with a *StationBusyError. Zero means no
+	// limit. When set it also replaces Cooks in ready-time estimates.
+	MaxInProgress int `json:"maxInProgress"`
+
+	// StationMaxInProgress overrides MaxInProgress for the named stations
+	StationMaxInProgress map[string]int `json:"stationMaxInProgress"`
+
+	// DuplicateWindow is how soon after an order the same customer placing
+	// the same item and quantity again counts as a duplicate, such as a
+	// double tap on a kiosk. Zero turns detection off.
+	DuplicateWindow config.Duration `json:"duplicateWindow"`
+
+	// RejectDuplicates makes PlaceOrder refuse duplicates with a
+	// *DuplicateError holding the earlier order. Otherwise they are placed
+	// and flagged with DuplicateOf.
+	RejectDuplicates bool `json:"rejectDuplicates"`
+
+	// MaxRushesPerHour caps how many orders each person may rush in any
+	// hour; more fail with a *RushLimitError. Zero means no limit.
+	MaxRushesPerHour int `json:"maxRushesPerHour"`
+
+	// Quota caps the orders each customer may have waiting; more fail with
+	// a *QuotaError
+	Quota QuotaConfig `json:"quota"`
+
+	// Forecast sets the window Forecast measures the kitchen's pace over
+	// and the wait past which a station needs more cooks
+	Forecast ForecastConfig `json:"forecast"`
+
+	// RequirePayment holds orders out of the queue until they are paid
+	RequirePayment bool `json:"requirePayment"`
+
+	// RequirePickupCode makes PickUpOrder refuse orders placed before pickup
+	// codes were issued, which have none to check. Orders with a code are
+	// only ever picked up with it.
+	RequirePickupCode bool `json:"requirePickupCode"`
+
+	// Strategy picks the order PrepareOrder takes next: priority (default),
+	// weighted, sjf, roundRobin or derived
+	Strategy string `json:"strategy"`
+
+	// GroupPriority sets how a priority change spreads across the orders of
+	// a group so they come out together: none (default), rush or inherit
+	GroupPriority string `json:"groupPriority"`
+
+	// TieBreak orders the orders of one priority placed at the same
+	// instant: sequence (default), smallest or random
+	TieBreak string `json:"tieBreak"`
+
+	// DayCloseAt is the local time of day, as "15:04", at which the day is
+	// closed automatically. Empty leaves day close to the API.
+	DayCloseAt string `json:"dayCloseAt"`
+
+	// Hours are the opening hours. Outside them new orders are turned
+	// away, while pre-orders wanted ready within them are still taken and
+	// released no earlier than the kitchen opens. Empty never closes.
+	Hours Hours `json:"hours"`
+
+	// PriorityRules adjust the priority of the orders they match as they
+	// are placed, in the order given; each order records those that matched
+	PriorityRules []PriorityRule `json:"priorityRules"`
+
+	// SLATargets sets how soon each priority should be prepared, from when
+	// the order is placed; pre-orders are due at their ready time. Orders
+	// keep the target of the priority they are placed with, and one not
+	// prepared in time is flagged with an EventBreached. Priorities left
+	// out have no target.
+	SLATargets map[int]config.Duration `json:"slaTargets"`
+
+	// VoidReasons are the reason codes VoidOrder accepts
+	VoidReasons []string `json:"voidReasons"`
+
+	// RemakeReasons are the reason codes RefireOrder accepts
+	RemakeReasons []string `json:"remakeReasons"`
+
+	// PriorityWeights sets each priority level's share under the weighted
+	// strategy
+	PriorityWeights map[int]float64 `json:"priorityWeights"`
+
+	// PriorityFormula is how the derived strategy scores orders, from the
+	// prices in ItemPrices and scores in ItemComplexity
+	PriorityFormula PriorityFormula `json:"priorityFormula"`
+
+	// ItemPrices gives each item's price, for the derived strategy's order
+	// value; other items count as free
+	ItemPrices map[string]float64 `json:"itemPrices"`
+
+	// ItemComplexity scores how involved each item is to prepare, for the
+	// derived strategy; other items score zero
+	ItemComplexity map[string]float64 `json:"itemComplexity"`
+
+	// Currency is the ISO 4217 code of the prices in ItemPrices, such as
+	// EUR. With it set every order for a priced item carries its Total, in
+	// the currency's minor units, charged in Currency unless the order asks
+	// for one of CurrencyPrices.
+	Currency string `json:"currency"`
+
+	// CurrencyPrices prices items in currencies besides Currency, by
+	// currency code and then item, for orders paid in them
+	CurrencyPrices map[string]map[string]float64 `json:"currencyPrices"`
+
+	// Rounding is how prices and tax are rounded to the minor unit, one of
+	// money.Roundings; halfUp when empty
+	Rounding string `json:"rounding"`
+
+	// Tax is the tax order totals carry
+	Tax TaxConfig `json:"tax"`
+
+	// AutoPrepare runs this many workers that claim waiting orders and mark
+	// them prepared once the item's preparation time has passed, standing
+	// in for a kitchen in demos, kiosk mode and frontend testing. Zero
+	// leaves preparing to the API.
+	AutoPrepare int `json:"autoPrepare"`
+
+	// AutoPrepareSpeed divides the preparation times the workers wait, so 60
+	// makes a three minute item take three seconds. Zero means real time.
+	AutoPrepareSpeed float64 `json:"autoPrepareSpeed"`
+
+	// ItemStations lists the stations able to make each item, such as two
+	// pizza ovens. An order for one placed without a station goes to
+	// whichever would have it ready soonest, by the ready-time plan,
+	// passing over paused and full stations, and pausing one of them moves
+	// its waiting orders for the item to the others.
+	ItemStations map[string][]string `json:"itemStations"`
+
+	// EscalationWindow is how long before its promised-by time a waiting
+	// order starts being escalated: its priority is raised step by step,
+	// in proportion to the window that has passed, so it reaches 0 by the
+	// promised time. Zero leaves priorities alone; orders at risk of
+	// missing their time are announced either way.
+	EscalationWindow config.Duration `json:"escalationWindow"`
+
+	// CheckQueue verifies the in-memory queue after every change: the heap
+	// ordering, the index each token holds and the index by ID. A violation
+	// is logged with the tokens involved and the method that left it. Each
+	// check walks the whole queue, so this is for debugging and tests.
+	CheckQueue bool `json:"checkQueue"`
+
+	// RepairQueue rebuilds the in-memory queue, as RebuildQueue does, when a
+	// check finds it broken. Without CheckQueue, Run checks it once a minute.
+	RepairQueue bool `json:"repairQueue"`
+}
+
+// TaxConfig sets the tax on order totals
+type TaxConfig struct {
+	// Rate is the tax in percent, such as 8.875
+	Rate float64 `json:"rate"`
+
+	// ItemRates overrides Rate for some items, such as food taxed lower
+	// than drink
+	ItemRates map[string]float64 `json:"itemRates"`
+
+	// Included means menu prices include the tax, as VAT usually is, so
+	// totals show it rather than add it
+	Included bool `json:"included"`
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		ScheduleLeadTime: config.Duration(15 * time.Minute),
+		UnprepareGrace:   config.Duration(2 * time.Minute),
+		CancelRecovery:   config.Duration(5 * time.Minute),
+		DefaultPrepTime:  config.Duration(3 * time.Minute),
+		VoidReasons:      slices.Clone(DefaultVoidReasons),
+		RemakeReasons:    slices.Clone(DefaultRemakeReasons),
+	}
+}
+
+// Validate checks settings that New cannot report on
+func (c Config) Validate() error {
+	if c.Strategy != "" && !slices.Contains(Strategies, c.Strategy) {
+		return fmt.Errorf("unknown strategy %q, want one of %v", c.Strategy, Strategies)
+	}
+	if c.GroupPriority != "" && !slices.Contains(GroupPriorities, c.GroupPriority) {
+		return fmt.Errorf("unknown group priority %q, want one of %v", c.GroupPriority, GroupPriorities)
+	}
+	if c.TieBreak != "" && !slices.Contains(TieBreaks, c.TieBreak) {
+		return fmt.Errorf("unknown tie-break %q, want one of %v", c.TieBreak, TieBreaks)
+	}
+	if c.AutoPrepare < 0 || c.AutoPrepareSpeed < 0 {
+		return fmt.Errorf("autoPrepare and autoPrepareSpeed must not be negative")
+	}
+	if c.QueueCapacity < 0 {
+		return fmt.Errorf("queueCapacity must not be negative")
+	}
+	if c.EscalationWindow < 0 {
+		return fmt.Errorf("escalationWindow must not be negative")
+	}
+	if c.DayCloseAt != "" {
+		if _, err := time.Parse("15:04", c.DayCloseAt); err != nil {
+			return fmt.Errorf("dayCloseAt %q must be a time of day like 03:00", c.DayCloseAt)
+		}
+	}
+	if _, err := parseHours(c.Hours); err != nil {
+		return err
+	}
+	if _, err := parseRules(c.PriorityRules); err != nil {
+		return err
+	}
+	for p, target := range c.SLATargets {
+		if target <= 0 {
+			return fmt.Errorf("slaTargets: the target for priority %d must be positive", p)
+		}
+	}
+	if len(c.VoidReasons) == 0 {
+		return fmt.Errorf("voidReasons must list at least one reason code")
+	}
+	if len(c.RemakeReasons) == 0 {
+		return fmt.Errorf("remakeReasons must list at least one reason code")
+	}
+	if err := validateItemStations(c.ItemStations); err != nil {
+		return err
+	}
+	if err := c.Quota.validate(); err != nil {
+		return err
+	}
+	if err := c.Forecast.validate(); err != nil {
+		return err
+	}
+	return c.validatePricing()
+}
@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"context"
+	"errors"
+
+	"awesomeProject/pkg/queue"
+)
+
+// ErrNothingToFire is returned by FireCourse for a group with no orders on
+// hold
+var ErrNothingToFire = errors.New("no course on hold")
+
+// firedCourse returns the latest course of group already let into the
+// kitchen, 1 when only the first course is; mu must be held
+func (om *OrderManager) firedCourse(group string) int {
+	fired := 1
+	for _, t := range om.groupMembers(group) {
+		if t.Status != queue.StatusOnHold {
+			fired = max(fired, t.Course)
+		}
+	}
+	return fired
+}
+
+// holdsCourse reports whether a new order waits for its course to be fired:
+// it belongs to a group and a course after the ones already fired; mu must
+// be held
+func (om *OrderManager) holdsCourse(o NewOrder) bool {
+	return o.Group != "" && o.Course > 1 && o.Course > om.firedCourse(o.Group)
+}
+
+// FireCourse releases the next course of a table or check: every order of
+// group on hold for the earliest course still held goes into the queue, or
+// the payment hold when it is unpaid and payment is required. Orders keep
+// their priority and order time. Orders placed for a course already fired
+// are queued as they come. It returns the orders fired, or
+// ErrNothingToFire when none of the group's orders are on hold.
+func (om *OrderManager) FireCourse(ctx context.Context, group string) ([]*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	var held []*queue.Token
+	course := 0
+	for _, t := range om.onHold {
+		if t.Group != group {
+			continue
+		}
+		if course == 0 || t.Course < course {
+			course, held = t.Course, held[:0]
+		}
+		if t.Course == course {
+			held = append(held, t)
+		}
+	}
+	if len(held) == 0 {
+		return nil, ErrNothingToFire
+	}
+	fired := make([]*queue.Token, 0, len(held))
+	for _, t := range held {
+		if om.held(t) {
+			om.holdForPayment(t)
+		} else if _, err := om.enqueue(ctx, t); err != nil {
+			t.Status = queue.StatusOnHold
+			return fired, err
+		}
+		om.onHold = removeToken(om.onHold, t)
+		om.emit(ctx, EventFired, t)
+		fired = append(fired, t.Clone())
+	}
+	return fired, nil
+}
@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// dayCloseRetry is how long a failed scheduled day close waits to try again
+const dayCloseRetry = time.Minute
+
+// DayClose is the business day a day close ends: the orders it took out of
+// memory and the period they cover
+type DayClose struct {
+	From   time.Time      // The previous day close, or the oldest order if earlier
+	To     time.Time      // When the day was closed
+	Orders []*queue.Token // Prepared orders, then closed ones
+}
+
+// Archiver stores the orders a day close removes from memory
+type Archiver interface {
+	Archive(day DayClose) error
+}
+
+// WithArchiver stores closed days with a. Without one, a day close only frees
+// memory and the orders are kept by the event log alone.
+func WithArchiver(a Archiver) Option {
+	return func(om *OrderManager) { om.archiver = a }
+}
+
+// CloseDay ends the business day: prepared and closed orders are archived
+// and dropped from memory, and daily token numbers start again from 1.
+// Orders still to be prepared carry over to the next day. If the archiver
+// fails nothing is removed.
+func (om *OrderManager) CloseDay(ctx context.Context) (*DayClose, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	return om.closeDay(ctx, om.clock.Now())
+}
+
+// closeDay implements CloseDay; mu must be held
+func (om *OrderManager) closeDay(ctx context.Context, now time.Time) (*DayClose, error) {
+	done := make([]*queue.Token, 0, len(om.prepared)+len(om.closed))
+	done = append(append(done, om.prepared...), om.closed...)
+	day := &DayClose{From: om.lastClose, To: now, Orders: make([]*queue.Token, len(done))}
+	for i, t := range done {
+		day.Orders[i] = t.Clone()
+		if t.Timestamp.Before(day.From) {
+			day.From = t.Timestamp
+		}
+	}
+	if om.archiver != nil {
+		if err := om.archiver.Archive(*day); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range done {
+		delete(om.byID, t.ID)
+		om.emit(ctx, EventArchived, t)
+	}
+	clear(om.prepared)
+	clear(om.closed)
+	om.prepared, om.closed = om.prepared[:0], om.closed[:0]
+	om.daily = 0
+	om.lastClose = now
+	return day, nil
+}
+
+// nextDayClose returns the first scheduled day close after t, or the zero
+// time when none is configured
+func (c Config) nextDayClose(t time.Time) time.Time {
+	at, err := time.Parse("15:04", c.DayCloseAt)
+	if c.DayCloseAt == "" || err != nil {
+		return time.Time{}
+	}
+	y, m, d := t.Date()
+	next := time.Date(y, m, d, at.Hour(), at.Minute(), 0, 0, t.Location())
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// closeDayIfDue runs the scheduled day close once its time has passed; mu
+// must be held. A failed close is retried a minute later.
+func (om *OrderManager) closeDayIfDue(ctx context.Context, now time.Time) {
+	due := om.cfg.nextDayClose(om.lastClose)
+	if due.IsZero() || now.Before(due) || now.Before(om.closeRetry) {
+		return
+	}
+	day, err := om.closeDay(ctx, now)
+	if err != nil {
+		log.Printf("day close: %v", err)
+		om.closeRetry = now.Add(dayCloseRetry)
+		return
+	}
+	log.Printf("day close: archived %d orders placed since %s", len(day.Orders), day.From.Format(time.DateTime))
+}
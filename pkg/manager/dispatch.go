@@ -0,0 +1,104 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// validateItemStations checks that each item lists its stations once
+func validateItemStations(items map[string][]string) error {
+	for item, stations := range items {
+		if len(stations) == 0 {
+			return fmt.Errorf("itemStations: %q must list at least one station", item)
+		}
+		for i, s := range stations {
+			if slices.Contains(stations[:i], s) {
+				return fmt.Errorf("itemStations: %q lists station %q twice", item, s)
+			}
+		}
+	}
+	return nil
+}
+
+// dispatch picks the station for an order of item from the item's
+// ItemStations: the one that would have it ready soonest, going by the
+// ready-time plan of the orders already there. Stations that are running
+// and have room are chosen first, then running ones, then any. It returns
+// "" for items with no stations listed. mu must be held.
+func (om *OrderManager) dispatch(ctx context.Context, item string) (string, error) {
+	candidates := om.cfg.ItemStations[item]
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		return "", err
+	}
+	now := om.clock.Now()
+	_, free := om.project(waiting, now, om.cfg.prepTime)
+	best, bestRank := "", 0
+	var bestReady time.Time
+	for _, station := range candidates {
+		rank := 0
+		if om.stationPaused(station) {
+			rank = 2
+		} else if ok, err := om.admit(ctx, station); err != nil {
+			return "", err
+		} else if !ok {
+			rank = 1
+		}
+		cooks, ok := free[station]
+		if !ok {
+			cooks = om.idleCooks(station)
+		}
+		start := cooks[firstFree(cooks)]
+		if start.Before(now) {
+			start = now
+		}
+		ready := start.Add(om.cfg.prepTime(item))
+		if best == "" || rank < bestRank || (rank == bestRank && ready.Before(bestReady)) {
+			best, bestRank, bestReady = station, rank, ready
+		}
+	}
+	return best, nil
+}
+
+// rebalance moves the orders waiting at station, just paused, to the other
+// stations that make their items, as dispatch picks them, announcing each
+// move with EventReassigned. Orders stay put when no other station is
+// running with room. mu must be held.
+func (om *OrderManager) rebalance(ctx context.Context, station string) error {
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(waiting, queueOrder)
+	for _, t := range waiting {
+		if t.Station != station || len(om.cfg.ItemStations[t.Item]) < 2 {
+			continue
+		}
+		to, err := om.dispatch(ctx, t.Item)
+		if err != nil {
+			return err
+		}
+		if to == station || om.stationPaused(to) {
+			continue
+		}
+		ok, err := om.admit(ctx, to)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		t.Station = to
+		if err := om.waiting.Update(ctx, t); err != nil {
+			return err
+		}
+		om.byID[t.ID] = t
+		om.emit(ctx, EventReassigned, t)
+	}
+	return nil
+}
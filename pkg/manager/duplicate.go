@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// DuplicateError is returned by PlaceOrder when RejectDuplicates is set and
+// the same customer placed the same order within DuplicateWindow. It wraps
+// ErrDuplicateOrder.
+type DuplicateError struct {
+	Existing *queue.Token // Copy of the earlier order
+}
+
+func (e *DuplicateError) Error() string {
+	format, args := e.Message()
+	return fmt.Sprintf(format, args...)
+}
+
+// Message returns the format and arguments of the error text, for translation
+func (e *DuplicateError) Message() (string, []any) {
+	return "duplicate of order %s placed at %s", []any{e.Existing.ID, e.Existing.Timestamp.Format(time.TimeOnly)}
+}
+
+func (e *DuplicateError) Unwrap() error { return ErrDuplicateOrder }
+
+// customer identifies who placed o for duplicate detection: the phone number,
+// device token or table, in that order. Orders without any are never
+// duplicates.
+func (o NewOrder) customer() string {
+	switch {
+	case o.Phone != "":
+		return "phone:" + o.Phone
+	case o.DeviceToken != "":
+		return "device:" + o.DeviceToken
+	case o.Table != 0:
+		return fmt.Sprintf("table:%d", o.Table)
+	}
+	return ""
+}
+
+// duplicateOf finds an order identical to o, same customer, item and
+// quantity, placed in the last DuplicateWindow and not cancelled; mu must be
+// held
+func (om *OrderManager) duplicateOf(o NewOrder, now time.Time) *queue.Token {
+	window := time.Duration(om.cfg.DuplicateWindow)
+	customer := o.customer()
+	if window <= 0 || customer == "" {
+		return nil
+	}
+	var found *queue.Token
+	for _, t := range om.byID {
+		if t.Status == queue.StatusCancelled || now.Sub(t.Timestamp) > window ||
+			!strings.EqualFold(t.Item, o.Item) || t.Quantity != o.Quantity {
+			continue
+		}
+		prior := NewOrder{Phone: t.Phone, DeviceToken: t.DeviceToken, Table: t.Table}
+		if prior.customer() == customer && (found == nil || t.Timestamp.After(found.Timestamp)) {
+			found = t
+		}
+	}
+	return found
+}
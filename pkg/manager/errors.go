@@ -0,0 +1,104 @@
+package manager
+
+import "errors"
+
+// Errors returned by OrderManager methods
+var (
+	ErrOrderNotFound  = errors.New("order not found")
+	ErrNotModifiable  = errors.New("order can no longer be modified")
+	ErrNotCancellable = errors.New("order can no longer be cancelled")
+	ErrNotPrepared    = errors.New("order is not awaiting pickup")
+	ErrPickupCode     = errors.New("pickup code does not match")
+	ErrNotWaiting     = errors.New("order is not waiting in the queue")
+	ErrGraceExpired   = errors.New("undo window has passed")
+	ErrNotCancelled   = errors.New("order is not cancelled")
+	ErrQueueEmpty     = errors.New("no orders to prepare")
+	ErrQueueFull      = errors.New("station is at capacity")
+	ErrStationBusy    = errors.New("station has its maximum orders in progress")
+	ErrDuplicateOrder = errors.New("same order placed moments ago")
+	ErrRushLimit      = errors.New("too many orders rushed in the last hour")
+	ErrStaleVersion   = errors.New("order has changed since it was read")
+	ErrPaused         = errors.New("ordering is paused")
+	ErrNotPaused      = errors.New("ordering is not paused")
+	ErrStationPaused  = errors.New("station is paused")
+	ErrStationRunning = errors.New("station is not paused")
+	ErrClosed         = errors.New("kitchen is closed")
+	ErrNotVoidable    = errors.New("only completed or cancelled orders can be voided")
+	ErrVoidReason     = errors.New("unknown void reason")
+	ErrNotRefirable   = errors.New("only prepared or picked up orders can be remade")
+	ErrRemakeReason   = errors.New("unknown remake reason")
+	ErrImport         = errors.New("invalid imported order")
+	ErrForceStatus    = errors.New("status cannot be forced")
+	ErrSameStatus     = errors.New("order already has that status")
+	ErrCurrency       = errors.New("items are not priced in that currency")
+	ErrQuota          = errors.New("customer has too many orders waiting")
+
+	ErrPaymentTransition = errors.New("payment status cannot change that way")
+	ErrInvalidSnapshot   = errors.New("invalid snapshot")
+
+	// ErrNotQueued is returned by Queue implementations for tokens that
+	// have left the queue
+	ErrNotQueued = errors.New("order is not queued")
+)
+
+// codes name the errors above for clients to branch on, as the HTTP API
+// reports them next to their translated messages. A code never changes
+// once published, however the message is reworded.
+var codes = []struct {
+	err  error
+	code string
+}{
+	{ErrOrderNotFound, "order_not_found"},
+	{ErrNotModifiable, "not_modifiable"},
+	{ErrNotCancellable, "not_cancellable"},
+	{ErrNotPrepared, "not_prepared"},
+	{ErrPickupCode, "pickup_code_mismatch"},
+	{ErrNotWaiting, "not_waiting"},
+	{ErrGraceExpired, "grace_expired"},
+	{ErrNotCancelled, "not_cancelled"},
+	{ErrQueueEmpty, "queue_empty"},
+	{ErrQueueFull, "queue_full"},
+	{ErrStationBusy, "station_busy"},
+	{ErrDuplicateOrder, "duplicate_order"},
+	{ErrRushLimit, "rush_limit"},
+	{ErrStaleVersion, "stale_version"},
+	{ErrPaused, "ordering_paused"},
+	{ErrNotPaused, "ordering_not_paused"},
+	{ErrStationPaused, "station_paused"},
+	{ErrStationRunning, "station_not_paused"},
+	{ErrClosed, "kitchen_closed"},
+	{ErrNotVoidable, "not_voidable"},
+	{ErrVoidReason, "unknown_void_reason"},
+	{ErrNotRefirable, "not_refirable"},
+	{ErrRemakeReason, "unknown_remake_reason"},
+	{ErrImport, "invalid_import"},
+	{ErrForceStatus, "status_not_forceable"},
+	{ErrSameStatus, "same_status"},
+	{ErrCurrency, "unknown_currency"},
+	{ErrQuota, "quota_exceeded"},
+	{ErrPaymentTransition, "payment_transition"},
+	{ErrInvalidSnapshot, "invalid_snapshot"},
+	{ErrNotQueued, "not_queued"},
+	{ErrAttachmentNotFound, "attachment_not_found"},
+	{ErrNothingToFire, "nothing_to_fire"},
+}
+
+// Code returns the code of the error err is or wraps, or "" when it is
+// none of the manager's errors
+func Code(err error) string {
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ""
+}
+
+// Codes returns every error code, in the order the errors are declared
+func Codes() []string {
+	out := make([]string, len(codes))
+	for i, c := range codes {
+		out[i] = c.code
+	}
+	return out
+}
@@ -0,0 +1,188 @@
+package manager
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// cooks is how many orders station prepares at once: its in-progress limit
+// when it has one, otherwise its cooks
+func (c Config) cooks(station string) int {
+	if n := c.maxInProgress(station); n > 0 {
+		return n
+	}
+	if n, ok := c.StationCooks[station]; ok && n > 0 {
+		return n
+	}
+	return max(c.Cooks, 1)
+}
+
+// plan projects when each in-progress and waiting order will be ready.
+// Orders are taken in the order the strategy would prepare them, and each
+// station's cooks work through its orders in parallel, one item prep time
+// per order. In-progress orders keep a cook busy until one prep time after
+// their claim. A cook starts on a waiting order once the order is queued and
+// the cook's previous order is done, the first of which is taken to be the
+// station's last prepare. Orders running late are expected now, and orders
+// at paused stations are left out. mu must be held, at least for reading.
+func (om *OrderManager) plan(waiting []*queue.Token, now time.Time) map[string]time.Time {
+	etas, _ := om.project(waiting, now, om.cfg.prepTime)
+	return etas
+}
+
+// project works out the plan with each item taking prep to prepare,
+// returning with it when each cook at the stations it touched is next free
+// once their orders are done
+func (om *OrderManager) project(waiting []*queue.Token, now time.Time, prep func(item string) time.Duration) (map[string]time.Time, map[string][]time.Time) {
+	order := om.prepOrder(waiting)
+	free := make(map[string][]time.Time) // When each cook at a station is next free
+	cooksAt := func(station string) []time.Time {
+		cooks, ok := free[station]
+		if !ok {
+			cooks = om.idleCooks(station)
+			free[station] = cooks
+		}
+		return cooks
+	}
+	etas := make(map[string]time.Time, len(om.inProgress)+len(order))
+	for _, t := range om.inProgress {
+		cooks := cooksAt(t.Station)
+		ready := t.ClaimedAt.Add(prep(t.Item))
+		if ready.Before(now) {
+			ready = now
+		}
+		cooks[firstFree(cooks)] = ready
+		etas[t.ID] = ready
+	}
+	for _, t := range order {
+		cooks := cooksAt(t.Station)
+		cook := firstFree(cooks)
+		start := cooks[cook]
+		if queued := queuedAt(t); queued.After(start) {
+			start = queued
+		}
+		ready := start.Add(prep(t.Item))
+		if ready.Before(now) {
+			ready = now
+		}
+		cooks[cook] = ready
+		etas[t.ID] = ready
+	}
+	return etas, free
+}
+
+// prepOrder returns waiting in the order the strategy would prepare it, as
+// next takes orders: rushed orders first, then the strategy's choice each
+// time. The weighted and round-robin strategies choose by what they chose
+// before, so they are run on a copy of their state; the others sort. Orders
+// at paused stations are left out, not being started until the station
+// resumes. mu must be held, at least for reading.
+func (om *OrderManager) prepOrder(waiting []*queue.Token) []*queue.Token {
+	order := slices.DeleteFunc(slices.Clone(waiting), func(t *queue.Token) bool { return om.stationPaused(t.Station) })
+	var sim Strategy
+	switch s := om.strategy.(type) {
+	case *WeightedFair:
+		sim = s.clone()
+	case *RoundRobin:
+		sim = &RoundRobin{next: s.next}
+	}
+	if sim != nil {
+		out := make([]*queue.Token, 0, len(order))
+		for len(order) > 0 {
+			candidates := order
+			if rushed := slices.DeleteFunc(slices.Clone(order), func(t *queue.Token) bool { return t.Priority != queue.RushPriority }); len(rushed) > 0 {
+				candidates = rushed
+			}
+			pick := sim.Next(candidates)
+			out = append(out, pick)
+			order = slices.DeleteFunc(order, func(t *queue.Token) bool { return t == pick })
+		}
+		return out
+	}
+
+	_, sjf := om.strategy.(*ShortestFirst)
+	derived, _ := om.strategy.(*Derived)
+	slices.SortFunc(order, func(a, b *queue.Token) int {
+		if sjf && (a.Priority == queue.RushPriority) == (b.Priority == queue.RushPriority) {
+			if da, db := om.cfg.prepTime(a.Item), om.cfg.prepTime(b.Item); da != db {
+				return cmp.Compare(da, db)
+			}
+		}
+		if derived != nil && (a.Priority == queue.RushPriority) == (b.Priority == queue.RushPriority) {
+			if sa, sb := derived.Score(a), derived.Score(b); sa != sb {
+				return cmp.Compare(sb, sa)
+			}
+		}
+		if queue.Before(a, b) {
+			return -1
+		}
+		return 1
+	})
+	return order
+}
+
+// idleCooks returns when each cook at station is free with no orders in
+// hand: from the station's last prepare. mu must be held, at least for
+// reading.
+func (om *OrderManager) idleCooks(station string) []time.Time {
+	var last time.Time
+	if times := om.prepTimes[station]; len(times) > 0 {
+		last = times[len(times)-1]
+	}
+	cooks := make([]time.Time, om.cfg.cooks(station))
+	for i := range cooks {
+		cooks[i] = last
+	}
+	return cooks
+}
+
+// firstFree returns the index of the cook free soonest
+func firstFree(cooks []time.Time) int {
+	cook := 0
+	for i, at := range cooks {
+		if at.Before(cooks[cook]) {
+			cook = i
+		}
+	}
+	return cook
+}
+
+// queuedAt is when t entered the waiting queue, as far as the token records
+func queuedAt(t *queue.Token) time.Time {
+	if t.ReleaseAt != nil && t.ReleaseAt.After(t.Timestamp) {
+		return *t.ReleaseAt
+	}
+	return t.Timestamp
+}
+
+// withETAs sets the projected ready time on copies of waiting and
+// in-progress orders
+func withETAs(tokens []*queue.Token, etas map[string]time.Time) {
+	for _, t := range tokens {
+		if eta, ok := etas[t.ID]; ok && (t.Status == queue.StatusPreparing || t.Status == queue.StatusInProgress) {
+			t.EstimatedReadyAt = &eta
+		}
+	}
+}
+
+// estimate sets the projected ready time on c, a copy of a token about to be
+// handed out, when it is waiting or in progress, and its queue position when
+// waiting. The plan is worked out from the queue as it is now, so every
+// change to the queue is reflected. mu must be held.
+func (om *OrderManager) estimate(ctx context.Context, c *queue.Token) {
+	if c.Status != queue.StatusPreparing && c.Status != queue.StatusInProgress {
+		return
+	}
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		log.Printf("estimate ready time of order %s: %v", c.ID, err)
+		return
+	}
+	withETAs([]*queue.Token{c}, om.plan(waiting, om.clock.Now()))
+	om.setPosition(ctx, c, waiting)
+}
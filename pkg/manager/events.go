@@ -0,0 +1,124 @@
+package manager
+
+import (
+	"context"
+	"time"
+
+	"awesomeProject/pkg/fault"
+	"awesomeProject/pkg/queue"
+)
+
+// Event types
+const (
+	EventCreated    = "created"
+	EventModified   = "modified"
+	EventRushed     = "rushed"   // Moved to the front of the queue
+	EventReleased   = "released" // Scheduled, waitlisted, blocked or newly paid order entered the queue
+	EventHeld       = "held"     // Released pre-order is waiting for payment
+	EventWaitlisted = "waitlisted"
+	EventBlocked    = "blocked" // Parked while its item is unavailable
+	EventFired      = "fired"   // Held course let into the kitchen
+	EventPayment    = "payment"
+	EventClaimed    = "claimed" // A cook started on the order
+	EventPrepared   = "prepared"
+	EventUnprepared = "unprepared" // Accidental prepare undone
+	EventCancelled  = "cancelled"
+	EventRecovered  = "recovered" // Cancellation undone
+	EventPickedUp   = "picked_up"
+	EventExpired    = "expired"
+	EventArchived   = "archived"     // Removed from memory by a day close
+	EventBreached   = "sla_breached" // Not prepared by its SLA target
+	EventVoided     = "voided"       // Completed or cancelled order voided or refunded
+	EventRefired    = "refired"      // Order came back and a remake was placed for it
+	EventImported   = "imported"     // Completed order brought over from another system
+	EventForced     = "forced"       // Status set by an admin, outside the usual transitions
+	EventReassigned = "reassigned"   // Moved to another station making its item, off a paused one
+	EventEscalated  = "escalated"    // Priority raised as its promised-by time nears
+	EventAtRisk     = "at_risk"      // Projected ready after its promised-by time
+	EventPurged     = "purged"       // The customer's personal data erased
+
+	// EventStationPaused and EventStationResumed are sent for each order
+	// waiting at a station when it is paused or resumed, as its ready
+	// estimate goes or comes back
+	EventStationPaused  = "station_paused"
+	EventStationResumed = "station_resumed"
+
+	// EventGroupReady follows the prepared or cancelled event of the last
+	// order of a group to be made, when the group asked to be notified as a
+	// whole
+	EventGroupReady = "group_ready"
+)
+
+// Event describes a change to an order
+type Event struct {
+	Type  string       `json:"type"`
+	Token *queue.Token `json:"token"` // Copy of the token after the change, with its ready estimate; treat as read-only
+	At    time.Time    `json:"at"`
+
+	// Group holds copies of every order of the group, in ID order, for
+	// EventGroupReady
+	Group []*queue.Token `json:"group,omitempty"`
+
+	// Cause is what the change was made for, as WithCause put it in the
+	// context of the call making it; empty for the manager's own work,
+	// such as releasing pre-orders. It is not encoded.
+	Cause string `json:"-"`
+}
+
+type causeKey struct{}
+
+// WithCause returns ctx carrying cause, such as the ID of a request being
+// served, for the events of changes made with it to say what they were for
+func WithCause(ctx context.Context, cause string) context.Context {
+	return context.WithValue(ctx, causeKey{}, cause)
+}
+
+// causeOf returns the cause ctx carries, if any
+func causeOf(ctx context.Context) string {
+	cause, _ := ctx.Value(causeKey{}).(string)
+	return cause
+}
+
+// Listener receives order events
+type Listener func(Event)
+
+// Publisher is a source of order events: the manager itself, or an event
+// bus relaying them to each subscriber in its own goroutine
+type Publisher interface {
+	Subscribe(Listener)
+}
+
+// Subscribe registers l to receive every event from now on.
+//
+// Listeners run synchronously while the manager's lock is held, so events
+// arrive in the order the changes happened. A listener must return quickly,
+// hand any slow work (network calls, disk I/O) to another goroutine, and must
+// not call back into the manager. Consumers doing slow work subscribe
+// through an event bus instead.
+func (om *OrderManager) Subscribe(l Listener) {
+	om.mu.Lock()
+	defer om.unlock()
+	om.listeners = append(om.listeners, l)
+}
+
+// emit delivers an event for token to all listeners and updates the search
+// index; mu must be held. The change has been made by then, so the event's
+// estimates are worked out even when ctx has been cancelled.
+func (om *OrderManager) emit(ctx context.Context, typ string, token *queue.Token) {
+	if typ == EventArchived {
+		om.search.remove(token.ID)
+	} else {
+		om.search.add(token)
+	}
+	if len(om.listeners) == 0 {
+		return
+	}
+	e := Event{Type: typ, Token: token.Clone(), At: om.clock.Now(), Cause: causeOf(ctx)}
+	om.estimate(context.WithoutCancel(ctx), e.Token)
+	for _, l := range om.listeners {
+		if fault.Drop() {
+			continue
+		}
+		l(e)
+	}
+}
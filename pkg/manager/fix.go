@@ -0,0 +1,185 @@
+package manager
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// ForcibleStatuses are the statuses ForceStatus can set
+var ForcibleStatuses = []string{
+	queue.StatusPreparing, queue.StatusPrepared, queue.StatusPickedUp, queue.StatusExpired, queue.StatusCancelled,
+}
+
+// SetNextNumber makes n the daily token number given to the next order, for
+// when the numbers have drifted from the ones called or printed, and
+// returns the number that was next. n must be at least 1. The change is
+// kept in snapshots, and by the event log once an order has been numbered
+// after it.
+func (om *OrderManager) SetNextNumber(n int) int {
+	om.mu.Lock()
+	defer om.unlock()
+	was := om.daily + 1
+	om.daily = n - 1
+	return was
+}
+
+// NextNumber returns the daily token number the next order is given
+func (om *OrderManager) NextNumber() int {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	return om.daily + 1
+}
+
+// ForceStatus moves an order straight to status, one of ForcibleStatuses,
+// whatever its status now, for repairing orders left in the wrong state.
+// None of the checks of the usual transitions apply: the order is queued
+// even at a full or paused station, and closed without a pickup code. The
+// time of the new status is set to now, except that an order prepared
+// before keeps its prepared time, and the times of later statuses are
+// cleared. It is announced with EventForced, and the status the order had is
+// returned with it. Voided orders cannot be forced, and an order already at
+// status returns ErrSameStatus.
+func (om *OrderManager) ForceStatus(ctx context.Context, id, status string) (*queue.Token, string, error) {
+	if !slices.Contains(ForcibleStatuses, status) {
+		return nil, "", ErrForceStatus
+	}
+	om.mu.Lock()
+	defer om.unlock()
+	token, err := om.lookup(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	from := token.Status
+	switch from {
+	case status:
+		return nil, "", ErrSameStatus
+	case queue.StatusVoided:
+		return nil, "", ErrForceStatus
+	case queue.StatusPreparing:
+		queued, err := om.waiting.Remove(ctx, id)
+		if err != nil {
+			return nil, "", err
+		}
+		if queued == nil {
+			// Prepared or cancelled by another instance sharing the queue
+			return nil, "", ErrNotWaiting
+		}
+		token = queued
+		om.byID[id] = token
+	case queue.StatusScheduled:
+		om.unschedule(token)
+	case queue.StatusWaitlisted:
+		om.waitlist = removeToken(om.waitlist, token)
+	case queue.StatusBlocked:
+		om.blocked = removeToken(om.blocked, token)
+	case queue.StatusOnHold:
+		om.onHold = removeToken(om.onHold, token)
+	case queue.StatusAwaitingPayment:
+		om.unpaid = removeToken(om.unpaid, token)
+	case queue.StatusInProgress:
+		om.inProgress = removeToken(om.inProgress, token)
+	case queue.StatusPrepared:
+		om.prepared = removeToken(om.prepared, token)
+	default:
+		om.closed = removeToken(om.closed, token)
+	}
+
+	now := om.clock.Now()
+	token.PickedUpAt, token.ExpiredAt, token.CancelledAt = nil, nil, nil
+	token.Status = status
+	switch status {
+	case queue.StatusPreparing:
+		token.ClaimedAt, token.PreparedAt = nil, nil
+		if err := om.waiting.Push(ctx, token); err != nil {
+			return nil, "", err
+		}
+	case queue.StatusPrepared:
+		if token.PreparedAt == nil {
+			token.PreparedAt = &now
+		}
+		// Kept in the order they were prepared for expiry
+		i, _ := slices.BinarySearchFunc(om.prepared, *token.PreparedAt, func(t *queue.Token, at time.Time) int {
+			return t.PreparedAt.Compare(at)
+		})
+		om.prepared = slices.Insert(om.prepared, i, token)
+	case queue.StatusPickedUp:
+		token.PickedUpAt = &now
+		om.closed = append(om.closed, token)
+	case queue.StatusExpired:
+		token.ExpiredAt = &now
+		om.closed = append(om.closed, token)
+	case queue.StatusCancelled:
+		token.CancelledAt = &now
+		om.closed = append(om.closed, token)
+	}
+	om.emit(ctx, EventForced, token)
+	om.groupReady(token)
+	om.drainWaitlist(ctx)
+	c := token.Clone()
+	om.estimate(ctx, c)
+	return c, from, nil
+}
+
+// QueueRebuild reports what RebuildQueue changed
+type QueueRebuild struct {
+	Queued   int      `json:"queued"`   // Orders waiting afterwards
+	Restored []string `json:"restored"` // Waiting orders missing from the queue, put back
+	Dropped  []string `json:"dropped"`  // Orders in the queue that are no longer waiting, taken out
+}
+
+// RebuildQueue rebuilds the queue of waiting orders from the orders the
+// manager holds, for when the two have come apart. The in-memory heap is
+// built afresh from every order whose status is preparing, dropping any
+// entry for an order that has moved on. A shared queue backend is the
+// record itself, so the manager instead takes its copies of the waiting
+// orders from it.
+func (om *OrderManager) RebuildQueue(ctx context.Context) (QueueRebuild, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	return om.rebuildQueue(ctx)
+}
+
+// rebuildQueue does the work of RebuildQueue; mu must be held for writing
+func (om *OrderManager) rebuildQueue(ctx context.Context) (QueueRebuild, error) {
+	listed, err := om.waiting.List(ctx)
+	if err != nil {
+		return QueueRebuild{}, err
+	}
+	rb := QueueRebuild{Restored: []string{}, Dropped: []string{}}
+	if _, ok := om.waiting.(*MemoryQueue); !ok {
+		for _, t := range listed {
+			om.byID[t.ID] = t
+			om.search.add(t)
+		}
+		rb.Queued = len(listed)
+		return rb, nil
+	}
+
+	mq := NewMemoryQueueSize(om.cfg.QueueCapacity)
+	for _, t := range listed {
+		if held, ok := om.byID[t.ID]; ok && held.Status != queue.StatusPreparing {
+			rb.Dropped = append(rb.Dropped, t.ID)
+			continue
+		}
+		om.byID[t.ID] = t
+		mq.push(t)
+	}
+	for id, t := range om.byID {
+		if t.Status != queue.StatusPreparing {
+			continue
+		}
+		if queued, _ := mq.Get(ctx, id); queued == nil {
+			rb.Restored = append(rb.Restored, id)
+			mq.push(t)
+		}
+	}
+	slices.SortFunc(rb.Restored, compareIDs)
+	slices.SortFunc(rb.Dropped, compareIDs)
+	om.waiting = mq
+	om.journalHeap(mq)
+	rb.Queued = mq.pq.Len()
+	return rb, nil
+}
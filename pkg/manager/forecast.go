@@ -0,0 +1,228 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/queue"
+)
+
+// ForecastConfig tunes Forecast
+type ForecastConfig struct {
+	// Window is how far back Forecast measures arrivals and prep
+	// durations. Zero means an hour.
+	Window config.Duration `json:"window"`
+
+	// MaxWait is the longest a newly placed order should take to be ready
+	// before its station needs more cooks. Zero means 20 minutes.
+	MaxWait config.Duration `json:"maxWait"`
+}
+
+const (
+	defaultForecastWindow  = time.Hour
+	defaultForecastMaxWait = 20 * time.Minute
+)
+
+func (c ForecastConfig) validate() error {
+	if c.Window < 0 || c.MaxWait < 0 {
+		return fmt.Errorf("forecast window and maxWait must not be negative")
+	}
+	return nil
+}
+
+func (c ForecastConfig) window() time.Duration {
+	if c.Window > 0 {
+		return time.Duration(c.Window)
+	}
+	return defaultForecastWindow
+}
+
+func (c ForecastConfig) maxWait() time.Duration {
+	if c.MaxWait > 0 {
+		return time.Duration(c.MaxWait)
+	}
+	return defaultForecastMaxWait
+}
+
+// Forecast is the outlook for every station, for planning who works when
+type Forecast struct {
+	At             time.Time         `json:"at"`
+	WindowSeconds  float64           `json:"windowSeconds"`  // How far back arrivals and prep durations were measured
+	MaxWaitSeconds float64           `json:"maxWaitSeconds"` // The wait past which a station needs more cooks
+	Stations       []StationForecast `json:"stations"`       // By station name, the default station first
+}
+
+// StationForecast is one station's outlook. Its rates assume the pace of
+// the measuring window holds.
+type StationForecast struct {
+	Station    string `json:"station"`
+	Paused     bool   `json:"paused,omitempty"`
+	Cooks      int    `json:"cooks"`
+	Waiting    int    `json:"waiting"`
+	InProgress int    `json:"inProgress"`
+
+	// AvgPrepSeconds is how long an order takes a cook, averaged over the
+	// orders waiting, or over those placed in the window when none are.
+	// Each item takes as long as it took on average from claim to prepared
+	// in the window, or its configured prep time when it was never claimed.
+	AvgPrepSeconds float64 `json:"avgPrepSeconds"`
+
+	ArrivalsPerHour float64 `json:"arrivalsPerHour"` // Orders placed in the window, per hour
+	CapacityPerHour float64 `json:"capacityPerHour"` // Orders the cooks can prepare per hour
+	Utilization     float64 `json:"utilization"`     // Arrivals over capacity; above 1 the backlog grows
+
+	// ClearAt is when the last order waiting or in progress should be
+	// ready; nil with none, or while the station is paused
+	ClearAt *time.Time `json:"clearAt,omitempty"`
+
+	// WaitSeconds is how long an order placed now should take to be ready
+	WaitSeconds float64 `json:"waitSeconds"`
+
+	// CooksNeeded is how many cooks would keep up with arrivals and bring
+	// the wait within the maximum
+	CooksNeeded int `json:"cooksNeeded"`
+
+	// CapacityNeededAt is when the wait passes the maximum at this pace:
+	// now when it already has, nil while it will not
+	CapacityNeededAt *time.Time `json:"capacityNeededAt,omitempty"`
+}
+
+// Forecast predicts when each station will clear its backlog and when it
+// will need more cooks, from the orders waiting and in progress now and
+// the arrivals and prep durations of Config.Forecast's window
+func (om *OrderManager) Forecast(ctx context.Context) (Forecast, error) {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		return Forecast{}, err
+	}
+	now := om.clock.Now()
+	window, maxWait := om.cfg.Forecast.window(), om.cfg.Forecast.maxWait()
+	since := now.Add(-window)
+
+	stations := map[string]*StationForecast{"": {}}
+	at := func(station string) *StationForecast {
+		sf, ok := stations[station]
+		if !ok {
+			sf = &StationForecast{}
+			stations[station] = sf
+		}
+		return sf
+	}
+	for station := range om.cfg.StationCooks {
+		at(station)
+	}
+	for _, list := range om.cfg.ItemStations {
+		for _, station := range list {
+			at(station)
+		}
+	}
+
+	// Measure the window: arrivals by station and prep durations by item
+	arrived := make(map[string][]string)
+	took := make(map[string][]time.Duration)
+	for _, t := range om.byID {
+		if t.ImportedFrom == "" && !t.Timestamp.Before(since) && !t.Timestamp.After(now) {
+			arrived[t.Station] = append(arrived[t.Station], t.Item)
+			at(t.Station)
+		}
+		if t.ClaimedAt != nil && t.PreparedAt != nil && !t.PreparedAt.Before(since) {
+			took[t.Item] = append(took[t.Item], t.PreparedAt.Sub(*t.ClaimedAt))
+		}
+	}
+	means := make(map[string]time.Duration, len(took))
+	for item, ds := range took {
+		var sum time.Duration
+		for _, d := range ds {
+			sum += d
+		}
+		means[item] = sum / time.Duration(len(ds))
+	}
+	prep := func(item string) time.Duration {
+		if d, ok := means[item]; ok {
+			return d
+		}
+		return om.cfg.prepTime(item)
+	}
+
+	queued := make(map[string][]string)
+	for _, t := range waiting {
+		at(t.Station).Waiting++
+		queued[t.Station] = append(queued[t.Station], t.Item)
+	}
+	for _, t := range om.inProgress {
+		at(t.Station).InProgress++
+	}
+	etas, free := om.project(waiting, now, prep)
+	last := make(map[string]time.Time)
+	for _, list := range [][]*queue.Token{om.inProgress, waiting} {
+		for _, t := range list {
+			if eta, ok := etas[t.ID]; ok && eta.After(last[t.Station]) {
+				last[t.Station] = eta
+			}
+		}
+	}
+
+	f := Forecast{At: now, WindowSeconds: window.Seconds(), MaxWaitSeconds: maxWait.Seconds()}
+	for _, station := range slices.Sorted(maps.Keys(stations)) {
+		sf := stations[station]
+		sf.Station = station
+		sf.Paused = om.stationPaused(station)
+		sf.Cooks = om.cfg.cooks(station)
+
+		items := queued[station]
+		if len(items) == 0 {
+			items = arrived[station]
+		}
+		avg := time.Duration(om.cfg.DefaultPrepTime)
+		if len(items) > 0 {
+			var sum time.Duration
+			for _, item := range items {
+				sum += prep(item)
+			}
+			avg = sum / time.Duration(len(items))
+		}
+		sf.AvgPrepSeconds = avg.Seconds()
+		sf.ArrivalsPerHour = float64(len(arrived[station])) / window.Hours()
+		if avg > 0 {
+			sf.CapacityPerHour = float64(sf.Cooks) * float64(time.Hour) / float64(avg)
+			sf.Utilization = sf.ArrivalsPerHour / sf.CapacityPerHour
+		}
+
+		// A new order starts once the first cook is through their share of
+		// the backlog
+		wait := avg
+		if cooks, ok := free[station]; ok {
+			wait += max(cooks[firstFree(cooks)].Sub(now), 0)
+		}
+		if done, ok := last[station]; ok && !sf.Paused {
+			sf.ClearAt = &done
+		}
+		sf.WaitSeconds = wait.Seconds()
+
+		backlog := float64(sf.Waiting + sf.InProgress)
+		sf.CooksNeeded = max(int(math.Ceil(sf.Utilization*float64(sf.Cooks))), 1)
+		if room := maxWait - avg; room > 0 {
+			sf.CooksNeeded = max(sf.CooksNeeded, int(math.Ceil(backlog*float64(avg)/float64(room))))
+		}
+
+		// The wait grows by the work arriving beyond what the cooks get
+		// through: utilization - 1 seconds per second
+		switch {
+		case wait > maxWait:
+			needed := now
+			sf.CapacityNeededAt = &needed
+		case sf.Utilization > 1:
+			needed := now.Add(time.Duration(float64(maxWait-wait) / (sf.Utilization - 1)))
+			sf.CapacityNeededAt = &needed
+		}
+		f.Stations = append(f.Stations, *sf)
+	}
+	return f, nil
+}
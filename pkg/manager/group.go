@@ -0,0 +1,131 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// Group priority policies, for Config.GroupPriority
+const (
+	GroupPriorityNone    = "none"    // Orders keep their own priority; the default
+	GroupPriorityRush    = "rush"    // Rushing an order rushes the rest of its group
+	GroupPriorityInherit = "inherit" // The rest of the group takes on any rise in priority, rushes included
+)
+
+// GroupPriorities lists every group priority policy
+var GroupPriorities = []string{GroupPriorityNone, GroupPriorityRush, GroupPriorityInherit}
+
+// groupMembers returns every order held under group in ID order; mu must be
+// held
+func (om *OrderManager) groupMembers(group string) []*queue.Token {
+	var members []*queue.Token
+	for _, t := range om.byID {
+		if t.Group == group {
+			members = append(members, t)
+		}
+	}
+	slices.SortFunc(members, func(a, b *queue.Token) int { return compareIDs(a.ID, b.ID) })
+	return members
+}
+
+// groupReady emits EventGroupReady when token, just prepared or cancelled,
+// was the last order of its group still to be made and one of the group's
+// orders asked for the combined notification. Cancelled orders do not hold
+// the group up, but a group with nothing prepared is not ready. Orders
+// placed under the group afterwards, or an accidental prepare undone, make
+// it wait again and so ready again; mu must be held.
+func (om *OrderManager) groupReady(token *queue.Token) {
+	if token.Group == "" || len(om.listeners) == 0 {
+		return
+	}
+	members := om.groupMembers(token.Group)
+	if !slices.ContainsFunc(members, func(t *queue.Token) bool { return t.NotifyGroup }) {
+		return
+	}
+	ready := false
+	for _, t := range members {
+		switch t.Status {
+		case queue.StatusCancelled:
+		case queue.StatusPrepared, queue.StatusPickedUp, queue.StatusExpired:
+			ready = true
+		default:
+			return
+		}
+	}
+	if !ready {
+		return
+	}
+	e := Event{Type: EventGroupReady, Token: token.Clone(), At: om.clock.Now()}
+	for _, t := range members {
+		e.Group = append(e.Group, t.Clone())
+	}
+	for _, l := range om.listeners {
+		l(e)
+	}
+}
+
+// boostGroup raises the rest of token's group to token's priority, as set by
+// GroupPriority, after token was rushed or had its priority raised. Orders
+// already as urgent and those no longer waiting are left alone; each one
+// raised records the change, by whoever made the original one, and emits its
+// own event. mu must be held.
+func (om *OrderManager) boostGroup(ctx context.Context, token *queue.Token, by string, now time.Time) {
+	rushed := token.Priority == queue.RushPriority
+	switch {
+	case token.Group == "":
+		return
+	case om.cfg.GroupPriority == GroupPriorityInherit:
+	case om.cfg.GroupPriority == GroupPriorityRush && rushed:
+	default:
+		return
+	}
+	for _, t := range om.groupMembers(token.Group) {
+		if t.ID == token.ID {
+			continue
+		}
+		if t.Status == queue.StatusPreparing {
+			// A shared queue has the latest of waiting orders
+			var err error
+			if t, err = om.lookup(ctx, t.ID); err != nil {
+				continue
+			}
+		}
+		if t.Priority <= token.Priority {
+			continue
+		}
+		switch t.Status {
+		case queue.StatusPreparing, queue.StatusWaitlisted, queue.StatusAwaitingPayment, queue.StatusScheduled, queue.StatusBlocked, queue.StatusOnHold:
+		default:
+			continue
+		}
+		prior := t.Clone()
+		t.Edits = append(t.Edits, queue.Edit{
+			Field: "priority", From: strconv.Itoa(t.Priority), To: strconv.Itoa(token.Priority), At: now, By: by,
+		})
+		t.Priority = token.Priority
+		if rushed {
+			t.RushedAt = &now
+			t.RushedBy = by
+		}
+		if t.Status == queue.StatusPreparing {
+			if err := om.waiting.Update(ctx, t); err != nil {
+				*t = *prior
+				if !errors.Is(err, ErrNotQueued) {
+					log.Printf("group priority: order %s: %v", t.ID, err)
+				}
+				continue
+			}
+		}
+		if rushed {
+			om.emit(ctx, EventRushed, t)
+		} else {
+			om.emit(ctx, EventModified, t)
+		}
+	}
+}
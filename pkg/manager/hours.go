@@ -0,0 +1,175 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Hours maps days of the week, by lowercase English name, to the windows
+// in which the kitchen takes orders, as "11:00-15:00". A window that ends
+// at or before its start runs past midnight into the next day. Days left
+// out are closed all day; with no days at all the kitchen never closes.
+type Hours map[string][]string
+
+// window is one opening, in minutes from the start of its day; end may run
+// past the day's 1440
+type window struct {
+	start, end int
+}
+
+// openingHours is Hours parsed, indexed by time.Weekday; nil when the kitchen
+// never closes
+type openingHours [][]window
+
+// parseHours checks and parses h
+func parseHours(h Hours) (openingHours, error) {
+	if len(h) == 0 {
+		return nil, nil
+	}
+	days := make(openingHours, 7)
+	for name, spans := range h {
+		day, ok := weekday(name)
+		if !ok {
+			return nil, fmt.Errorf("hours: unknown day %q, want monday to sunday", name)
+		}
+		for _, span := range spans {
+			w, err := parseWindow(span)
+			if err != nil {
+				return nil, fmt.Errorf("hours for %s: %w", name, err)
+			}
+			days[day] = append(days[day], w)
+		}
+	}
+	return days, nil
+}
+
+// weekday returns the day of the week named name
+func weekday(name string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(name, d.String()) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// parseWindow parses an opening like "17:00-23:30"
+func parseWindow(span string) (window, error) {
+	from, to, ok := strings.Cut(span, "-")
+	open, err1 := time.Parse("15:04", strings.TrimSpace(from))
+	closed, err2 := time.Parse("15:04", strings.TrimSpace(to))
+	if !ok || err1 != nil || err2 != nil {
+		return window{}, fmt.Errorf("%q must be opening and closing times like 11:00-15:00", span)
+	}
+	w := window{start: open.Hour()*60 + open.Minute(), end: closed.Hour()*60 + closed.Minute()}
+	if w.end <= w.start {
+		w.end += 24 * 60
+	}
+	return w, nil
+}
+
+// at returns the opening window that t falls in, as times, looking back to
+// the day before for windows that run past midnight
+func (h openingHours) at(t time.Time) (start, end time.Time, ok bool) {
+	if h == nil {
+		return time.Time{}, time.Time{}, true
+	}
+	for back := 1; back >= 0; back-- {
+		day := midnight(t).AddDate(0, 0, -back)
+		for _, w := range h[day.Weekday()] {
+			start, end := w.times(day)
+			if !t.Before(start) && t.Before(end) {
+				return start, end, true
+			}
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// next returns the first opening after t, or the zero time when the week
+// has none
+func (h openingHours) next(t time.Time) time.Time {
+	var first time.Time
+	for ahead := 0; ahead <= 7; ahead++ {
+		day := midnight(t).AddDate(0, 0, ahead)
+		for _, w := range h[day.Weekday()] {
+			start, _ := w.times(day)
+			if start.After(t) && (first.IsZero() || start.Before(first)) {
+				first = start
+			}
+		}
+		if !first.IsZero() {
+			return first
+		}
+	}
+	return first
+}
+
+// times returns when w opens and closes on day, given as its local midnight
+func (w window) times(day time.Time) (time.Time, time.Time) {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, 0, w.start, 0, 0, day.Location()),
+		time.Date(y, m, d, 0, w.end, 0, 0, day.Location())
+}
+
+// midnight returns the start of t's day
+func midnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// ClosedError is returned by PlaceOrder for orders outside the opening
+// hours: placed while the kitchen is closed, or wanted ready at a time it
+// is closed
+type ClosedError struct {
+	// NextOpen is when the kitchen next opens after the time asked for, or
+	// zero when it never does
+	NextOpen time.Time
+
+	// Wait is how long from when the order was placed until NextOpen, by the
+	// manager's clock
+	Wait time.Duration
+}
+
+func (e *ClosedError) Error() string {
+	format, args := e.Message()
+	return fmt.Sprintf(format, args...)
+}
+
+// Message returns the format and arguments of the error text, for translation
+func (e *ClosedError) Message() (string, []any) {
+	if e.NextOpen.IsZero() {
+		return ErrClosed.Error(), nil
+	}
+	return "kitchen is closed until %s", []any{e.NextOpen.Format("Mon 2 Jan 15:04")}
+}
+
+func (e *ClosedError) Unwrap() error { return ErrClosed }
+
+// checkHours rejects an order placed at now outside the opening hours,
+// unless it is a pre-order wanted ready while the kitchen is open. A
+// pre-order wanted ready while it is closed is rejected too.
+func (om *OrderManager) checkHours(o NewOrder, now time.Time) error {
+	at := now
+	if !o.ReadyAt.IsZero() {
+		at = o.ReadyAt
+	}
+	if _, _, open := om.hours.at(at); open {
+		return nil
+	}
+	next := om.hours.next(at)
+	if next.IsZero() {
+		return &ClosedError{}
+	}
+	return &ClosedError{NextOpen: next, Wait: next.Sub(now)}
+}
+
+// NextOpen reports whether the kitchen is taking orders at t and, when it is
+// not, when it next opens; the zero time means never
+func (om *OrderManager) NextOpen(t time.Time) (bool, time.Time) {
+	if _, _, open := om.hours.at(t); open {
+		return true, time.Time{}
+	}
+	return false, om.hours.next(t)
+}
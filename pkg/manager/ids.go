@@ -0,0 +1,233 @@
+package manager
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Order ID formats
+const (
+	IDSequential = "sequential" // 1, 2, 3 and on; the default
+	IDUUID       = "uuid"       // Version 7 UUIDs, ordered by creation time
+	IDULID       = "ulid"       // ULIDs, ordered by creation time
+)
+
+// IDFormats lists every order ID format
+var IDFormats = []string{IDSequential, IDUUID, IDULID}
+
+// IDGenerator allocates order IDs. IDs are opaque to the manager and its
+// clients, which read the daily token number to customers instead. The
+// manager calls NewID with its lock held, so generators need not be safe for
+// concurrent use.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// WithIDGenerator makes the manager take order IDs from g instead of its
+// sequential counter, or the queue's when the queue is an IDSource
+func WithIDGenerator(g IDGenerator) Option {
+	return func(om *OrderManager) { om.ids = g }
+}
+
+// NewIDGenerator returns the generator for an ID format. Sequential IDs have
+// none: the manager counts them itself, carrying on after the orders it
+// restores, and takes them from a queue that is an IDSource so instances
+// sharing it never collide. Use a SequenceFile to keep the count across
+// restarts without an event log or snapshot.
+func NewIDGenerator(format string) (IDGenerator, error) {
+	switch format {
+	case "", IDSequential:
+		return nil, nil
+	case IDUUID:
+		return UUIDv7{}, nil
+	case IDULID:
+		return &ULID{}, nil
+	}
+	return nil, fmt.Errorf("unknown ID format %q, want one of %v", format, IDFormats)
+}
+
+// maxIDAttempts bounds how often nextID asks a generator again for an ID
+// that is not taken
+const maxIDAttempts = 100
+
+// nextID allocates an order ID from the generator when there is one, from
+// the queue when it hands them out, or from the local counter otherwise; mu
+// must be held
+func (om *OrderManager) nextID(ctx context.Context) (string, error) {
+	if om.ids != nil {
+		for range maxIDAttempts {
+			id, err := om.ids.NewID()
+			if err != nil {
+				return "", fmt.Errorf("allocate order ID: %w", err)
+			}
+			// A sequence file left behind by restored orders skips past them
+			if _, taken := om.byID[id]; !taken {
+				return id, nil
+			}
+		}
+		return "", errors.New("allocate order ID: every ID generated is taken")
+	}
+	if src, ok := om.waiting.(IDSource); ok {
+		id, err := src.NextID(ctx)
+		if err != nil {
+			return "", err
+		}
+		om.counter = max(om.counter, id)
+		return strconv.Itoa(id), nil
+	}
+	om.counter++
+	return strconv.Itoa(om.counter), nil
+}
+
+// compareIDs orders IDs by creation for each format: sequential IDs by
+// value, UUIDv7s and ULIDs as text
+func compareIDs(a, b string) int {
+	return cmp.Or(cmp.Compare(len(a), len(b)), strings.Compare(a, b))
+}
+
+// sequence returns the counter value of a sequential ID, or 0 for IDs of
+// other formats
+func sequence(id string) int {
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// UUIDv7 generates version 7 UUIDs (RFC 9562): a millisecond timestamp
+// followed by random bits
+type UUIDv7 struct{}
+
+func (UUIDv7) NewID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(b[0:], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:], uint32(ms))
+	b[6] = b[6]&0x0f | 0x70 // Version 7
+	b[8] = b[8]&0x3f | 0x80 // RFC 9562 variant
+
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	hex.Encode(s[9:13], b[4:6])
+	hex.Encode(s[14:18], b[6:8])
+	hex.Encode(s[19:23], b[8:10])
+	hex.Encode(s[24:], b[10:])
+	s[8], s[13], s[18], s[23] = '-', '-', '-', '-'
+	return string(s[:]), nil
+}
+
+// crockford is the base32 alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID generates ULIDs: a 48-bit millisecond timestamp and 80 random bits in
+// 26 characters of Crockford base32. IDs made in the same millisecond
+// increment the random part, so they stay in creation order.
+type ULID struct {
+	ms      uint64
+	entropy [10]byte
+}
+
+func (u *ULID) NewID() (string, error) {
+	ms := uint64(time.Now().UnixMilli())
+	if ms <= u.ms {
+		// Same millisecond, or the clock stepped back
+		if !increment(u.entropy[:]) {
+			return "", errors.New("ulid: too many IDs in one millisecond")
+		}
+	} else {
+		u.ms = ms
+		if _, err := rand.Read(u.entropy[:]); err != nil {
+			return "", err
+		}
+	}
+
+	var b [16]byte
+	binary.BigEndian.PutUint16(b[0:], uint16(u.ms>>32))
+	binary.BigEndian.PutUint32(b[2:], uint32(u.ms))
+	copy(b[6:], u.entropy[:])
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+
+	var s [26]byte
+	for i := len(s) - 1; i >= 0; i-- {
+		s[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s[:]), nil
+}
+
+// increment adds one to a big-endian number, reporting false on overflow
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SequenceFile generates sequential IDs from a counter kept in a file, so
+// they carry on across restarts of a server without an event log or
+// snapshot to restore from. Every ID is written to the file before it is
+// handed out.
+type SequenceFile struct {
+	path string
+	last int
+}
+
+// OpenSequenceFile opens the counter at path, starting from zero when the
+// file does not exist yet
+func OpenSequenceFile(path string) (*SequenceFile, error) {
+	s := &SequenceFile{path: path}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return s, nil
+	case err != nil:
+		return nil, err
+	}
+	if s.last, err = strconv.Atoi(strings.TrimSpace(string(data))); err != nil || s.last < 0 {
+		return nil, fmt.Errorf("sequence file %s: %q is not a count", path, strings.TrimSpace(string(data)))
+	}
+	return s, nil
+}
+
+func (s *SequenceFile) NewID() (string, error) {
+	id := strconv.Itoa(s.last + 1)
+	// Write and rename, so a crash leaves the old count or the new one
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmp.WriteString(id + "\n")
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	s.last++
+	return id, nil
+}
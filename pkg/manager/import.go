@@ -0,0 +1,170 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/queue"
+)
+
+// Menu is what the manager knows of each item, as Config holds it
+type Menu struct {
+	ItemPrices     map[string]float64         `json:"itemPrices"`
+	ItemComplexity map[string]float64         `json:"itemComplexity"`
+	ItemPrepTimes  map[string]config.Duration `json:"itemPrepTimes"`
+}
+
+// MenuItem sets what the manager knows of one item; nil fields are left as
+// they are
+type MenuItem struct {
+	Item       string
+	Price      *float64
+	Complexity *float64
+	PrepTime   *config.Duration
+}
+
+// Menu returns a copy of the item prices, complexity scores and
+// preparation times in use
+func (om *OrderManager) Menu() Menu {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	return Menu{
+		ItemPrices:     maps.Clone(om.cfg.ItemPrices),
+		ItemComplexity: maps.Clone(om.cfg.ItemComplexity),
+		ItemPrepTimes:  maps.Clone(om.cfg.ItemPrepTimes),
+	}
+}
+
+// UpdateMenu sets the prices, complexity scores and preparation times of
+// items, as the configuration would, and returns the menu after the change.
+// The strategies and ready estimates use them from the next order on. They
+// last until a restart, which reads the configuration again.
+func (om *OrderManager) UpdateMenu(items []MenuItem) Menu {
+	om.mu.Lock()
+	for _, it := range items {
+		if it.Price != nil {
+			om.cfg.ItemPrices[it.Item] = *it.Price
+		}
+		if it.Complexity != nil {
+			om.cfg.ItemComplexity[it.Item] = *it.Complexity
+		}
+		if it.PrepTime != nil {
+			om.cfg.ItemPrepTimes[it.Item] = *it.PrepTime
+		}
+	}
+	om.mu.Unlock()
+	return om.Menu()
+}
+
+// ImportStatuses are the statuses an imported order may have: it is over
+// and done with in the system it came from
+var ImportStatuses = []string{queue.StatusPickedUp, queue.StatusExpired, queue.StatusCancelled}
+
+// HistoricalOrder is an order completed in another system, for ImportOrders
+type HistoricalOrder struct {
+	SourceID   string // Its ID in the other system, kept as ImportedFrom
+	Item       string
+	Priority   int
+	Quantity   int    // 1 when zero
+	Status     string // One of ImportStatuses
+	OrderedAt  time.Time
+	PreparedAt time.Time // Zero when not known or never prepared
+	ClosedAt   time.Time // When it was picked up, expired or cancelled
+	Station    string
+	OrderType  string
+	Table      int
+	Notes      string
+	Payment    string // One of the payment statuses; unpaid when empty
+	Channel    string // One of the queue channels; empty when not known
+}
+
+// Check reports what is wrong with o, as ImportOrders does
+func (o HistoricalOrder) Check() error {
+	switch {
+	case !slices.Contains(ImportStatuses, o.Status):
+		return fmt.Errorf("%w: status %q", ErrImport, o.Status)
+	case o.OrderedAt.IsZero() || o.ClosedAt.IsZero():
+		return fmt.Errorf("%w: order and close times are required", ErrImport)
+	case !o.PreparedAt.IsZero() && (o.PreparedAt.Before(o.OrderedAt) || o.PreparedAt.After(o.ClosedAt)):
+		return fmt.Errorf("%w: prepared outside the order and close times", ErrImport)
+	case o.ClosedAt.Before(o.OrderedAt):
+		return fmt.Errorf("%w: closed before it was ordered", ErrImport)
+	case o.Payment != "" && !slices.Contains(queue.PaymentStatuses, o.Payment):
+		return fmt.Errorf("%w: payment %q", ErrImport, o.Payment)
+	case o.Channel != "" && !queue.ValidChannel(o.Channel):
+		return fmt.Errorf("%w: channel %q", ErrImport, o.Channel)
+	}
+	return nil
+}
+
+// ImportOrders adds orders completed in another system as closed orders, so
+// that stats, exports and the archive cover the time before the move. Each
+// gets an ID of this system, keeping its old one in ImportedFrom, but no
+// daily number. They are announced with EventImported, not EventCreated, so
+// nothing is prepared, printed or notified; the next day close archives
+// them. Orders that fail Check return an error wrapping ErrImport, and none
+// are imported.
+func (om *OrderManager) ImportOrders(ctx context.Context, orders []HistoricalOrder) ([]*queue.Token, error) {
+	for i, o := range orders {
+		if err := o.Check(); err != nil {
+			return nil, fmt.Errorf("order %d: %w", i+1, err)
+		}
+	}
+	om.mu.Lock()
+	defer om.unlock()
+	tokens := make([]*queue.Token, 0, len(orders))
+	for _, o := range orders {
+		id, err := om.nextID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		token := &queue.Token{
+			ID:           id,
+			Item:         o.Item,
+			Priority:     o.Priority,
+			Status:       o.Status,
+			Timestamp:    o.OrderedAt,
+			Quantity:     max(o.Quantity, 1),
+			Station:      o.Station,
+			OrderType:    o.OrderType,
+			Table:        o.Table,
+			Notes:        o.Notes,
+			Payment:      o.Payment,
+			Channel:      o.Channel,
+			ImportedFrom: o.SourceID,
+		}
+		if token.Payment == "" {
+			token.Payment = queue.PaymentUnpaid
+		}
+		if !o.PreparedAt.IsZero() {
+			prepared := o.PreparedAt
+			token.PreparedAt = &prepared
+		}
+		closed := o.ClosedAt
+		switch o.Status {
+		case queue.StatusPickedUp:
+			token.PickedUpAt = &closed
+		case queue.StatusExpired:
+			token.ExpiredAt = &closed
+		case queue.StatusCancelled:
+			token.CancelledAt = &closed
+		}
+		om.stamp(token)
+		om.byID[token.ID] = token
+		om.closed = append(om.closed, token)
+		om.emit(ctx, EventImported, token)
+		tokens = append(tokens, token.Clone())
+	}
+	return tokens, nil
+}
+
+// cloneMenu copies one of the menu maps, making it when there is none
+func cloneMenu[V any](m map[string]V) map[string]V {
+	c := make(map[string]V, len(m))
+	maps.Copy(c, m)
+	return c
+}
@@ -0,0 +1,116 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"awesomeProject/pkg/queue"
+)
+
+// Changes to the in-memory heap, as a Journal is told of them
+const (
+	HeapPush   = "push"   // A token added, replacing any with its ID
+	HeapPop    = "pop"    // The first token taken out
+	HeapRemove = "remove" // A token taken out wherever it is
+	HeapFix    = "fix"    // A queued token changed, and moved to its new place
+)
+
+// Journal keeps the changes made to the in-memory queue ahead of making
+// them, so that after a crash replaying them rebuilds the heap exactly as it
+// was, down to the position of every token. The wal package provides one.
+type Journal interface {
+	// Append records op about to be applied to t; the change is not made
+	// when it fails
+	Append(op string, t *queue.Token) error
+	// Compact replaces everything recorded with heap, the whole heap in heap
+	// order
+	Compact(heap []*queue.Token) error
+	// Due reports whether enough has been appended since the last Compact
+	// for compacting to be worthwhile
+	Due() bool
+}
+
+// RestoreQueue makes heap, a queue rebuilt from j, the in-memory queue and
+// records every change to it in j from then on. The tokens of heap take the
+// place of the manager's copies, as they are newer than any event log; those
+// the manager holds with another status are dropped, and waiting orders heap
+// lacks are queued again, so an order is never lost between the two. The ID
+// counter and daily numbers carry on after the tokens of heap. No events are
+// emitted.
+func (om *OrderManager) RestoreQueue(heap []*queue.Token, j Journal) (QueueRebuild, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	if _, ok := om.waiting.(*MemoryQueue); !ok {
+		return QueueRebuild{}, errors.New("a queue journal needs the in-memory queue")
+	}
+	rb := QueueRebuild{Restored: []string{}, Dropped: []string{}}
+	inHeap := make(map[string]bool, len(heap))
+	tokens := make([]*queue.Token, 0, len(heap)+len(om.byID))
+	for _, t := range heap {
+		switch {
+		case t.Status != queue.StatusPreparing:
+			return QueueRebuild{}, fmt.Errorf("journaled token %s has status %q", t.ID, t.Status)
+		case inHeap[t.ID]:
+			return QueueRebuild{}, fmt.Errorf("duplicate journaled token %s", t.ID)
+		}
+		inHeap[t.ID] = true
+		if held, ok := om.byID[t.ID]; ok && held.Status != queue.StatusPreparing {
+			rb.Dropped = append(rb.Dropped, t.ID)
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+	// restoredState pushes the waiting tokens in the order given, which
+	// rebuilds the heap as it was, then queues the orders it lacked
+	var missing []*queue.Token
+	for id, t := range om.byID {
+		switch {
+		case inHeap[id]:
+		case t.Status == queue.StatusPreparing:
+			missing = append(missing, t)
+			rb.Restored = append(rb.Restored, id)
+		default:
+			tokens = append(tokens, t)
+		}
+	}
+	slices.SortFunc(missing, queueOrder)
+	st, err := restoredState(append(tokens, missing...))
+	if err != nil {
+		return QueueRebuild{}, err
+	}
+	st.counter, st.seq = max(st.counter, om.counter), max(st.seq, om.seq)
+	for item := range om.unavailable {
+		st.unavailable[item] = true
+	}
+	om.journal = j
+	om.install(st)
+	slices.SortFunc(rb.Restored, compareIDs)
+	slices.SortFunc(rb.Dropped, compareIDs)
+	rb.Queued = st.waiting.pq.Len()
+	return rb, nil
+}
+
+// journalHeap starts the journal afresh from mq, once mq has replaced the
+// queue; mu must be held
+func (om *OrderManager) journalHeap(mq *MemoryQueue) {
+	if om.journal == nil {
+		return
+	}
+	mq.journal = om.journal
+	if err := om.journal.Compact(mq.pq); err != nil {
+		log.Printf("queue journal: %v", err)
+	}
+}
+
+// compactJournal compacts the journal when it is due; mu must be held
+func (om *OrderManager) compactJournal() {
+	mq, ok := om.waiting.(*MemoryQueue)
+	if !ok || om.journal == nil || !om.journal.Due() {
+		return
+	}
+	if err := om.journal.Compact(mq.pq); err != nil {
+		log.Printf("queue journal: %v", err)
+	}
+}
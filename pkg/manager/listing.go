@@ -0,0 +1,177 @@
+package manager
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// Listing page sizes
+const (
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+)
+
+// OrderFilter selects, sorts and pages orders for a listing
+type OrderFilter struct {
+	Status      string    // One of the queue statuses, or empty for all
+	From        time.Time // Earliest order time, zero for unbounded
+	To          time.Time // Latest order time, zero for unbounded
+	Item        string    // Case-insensitive substring of the item name
+	Text        string    // Words to search for in the item, notes and customer details; each must begin a word of the order
+	MinPriority *int
+	MaxPriority *int
+	Table       *int     // Dine-in table number
+	OrderType   string   // One of the queue order types, or empty for all
+	Channel     string   // One of the queue channels, or empty for all
+	Payment     string   // One of the payment statuses, or empty for all
+	Group       string   // Orders of one table or check
+	Flags       []string // Flags every order must carry; queue.FlagAllergy matches any allergy flag
+	Sort        string   // "id", "priority", "timestamp" or "item"; empty keeps queue order
+	Desc        bool
+	Limit       int
+	Offset      int
+}
+
+func (f OrderFilter) match(t *queue.Token) bool {
+	if f.Status != "" && t.Status != f.Status {
+		return false
+	}
+	if !f.From.IsZero() && t.Timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && t.Timestamp.After(f.To) {
+		return false
+	}
+	if f.Item != "" && !strings.Contains(strings.ToLower(t.Item), strings.ToLower(f.Item)) {
+		return false
+	}
+	if f.Table != nil && t.Table != *f.Table {
+		return false
+	}
+	if f.OrderType != "" && t.OrderType != f.OrderType {
+		return false
+	}
+	if f.Channel != "" && t.Channel != f.Channel {
+		return false
+	}
+	if f.Payment != "" && t.Payment != f.Payment {
+		return false
+	}
+	if f.Group != "" && t.Group != f.Group {
+		return false
+	}
+	for _, flag := range f.Flags {
+		if !t.HasFlag(flag) {
+			return false
+		}
+	}
+	if f.MinPriority != nil && t.Priority < *f.MinPriority {
+		return false
+	}
+	if f.MaxPriority != nil && t.Priority > *f.MaxPriority {
+		return false
+	}
+	return true
+}
+
+// less compares two tokens by the filter's sort key, falling back to ID
+func (f OrderFilter) less(a, b *queue.Token) bool {
+	switch f.Sort {
+	case "priority":
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+	case "timestamp":
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.Before(b.Timestamp)
+		}
+	case "item":
+		if a.Item != b.Item {
+			return a.Item < b.Item
+		}
+	}
+	return compareIDs(a.ID, b.ID) < 0
+}
+
+// QueryOrders returns one page of the orders matching f along with the total
+// number of matches. Without a sort key, in-progress orders come first oldest
+// claim first, then preparing orders in the order they will be prepared,
+// followed by waitlisted orders oldest first, blocked orders oldest first,
+// held courses oldest first, orders awaiting payment oldest first, scheduled
+// orders by release time, then prepared orders oldest first and closed
+// orders in closing order. It gives up with ctx's error once ctx is done.
+func (om *OrderManager) QueryOrders(ctx context.Context, f OrderFilter) ([]*queue.Token, int, error) {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	keep := f.match
+	if terms := searchTerms(f.Text); len(terms) > 0 {
+		found := om.search.find(terms)
+		keep = func(t *queue.Token) bool { return f.match(t) && om.search.matches(t, terms, found) }
+	}
+	var preparing, matched []*queue.Token
+	for _, t := range om.inProgress {
+		if keep(t) {
+			matched = append(matched, t)
+		}
+	}
+	for _, t := range waiting {
+		if keep(t) {
+			preparing = append(preparing, t)
+		}
+	}
+	sort.Slice(preparing, func(i, j int) bool {
+		return queue.Before(preparing[i], preparing[j])
+	})
+	matched = append(matched, preparing...)
+	for _, list := range [][]*queue.Token{om.waitlist, om.blocked, om.onHold, om.unpaid, om.scheduled, om.prepared, om.closed} {
+		if ctx.Err() != nil {
+			break
+		}
+		for _, t := range list {
+			if keep(t) {
+				matched = append(matched, t)
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if f.Sort != "" {
+		sort.SliceStable(matched, func(i, j int) bool {
+			if f.Desc {
+				return f.less(matched[j], matched[i])
+			}
+			return f.less(matched[i], matched[j])
+		})
+	}
+
+	total := len(matched)
+	if f.Offset >= total {
+		return []*queue.Token{}, total, nil
+	}
+	matched = matched[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(matched) {
+		matched = matched[:f.Limit]
+	}
+	// Only the page is copied and given estimates, however many match
+	page := make([]*queue.Token, len(matched))
+	queued := false
+	for i, t := range matched {
+		page[i] = t.Clone()
+		queued = queued || t.Status == queue.StatusPreparing || t.Status == queue.StatusInProgress
+	}
+	if queued {
+		withETAs(page, om.plan(waiting, om.clock.Now()))
+		om.withPositions(page, waiting)
+	}
+	return page, total, nil
+}
@@ -0,0 +1,595 @@
+// Package manager tracks orders from creation until they are prepared.
+package manager
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"awesomeProject/pkg/clock"
+	"awesomeProject/pkg/features"
+	"awesomeProject/pkg/queue"
+)
+
+// OrderManager manages tokens and priorities.
+//
+// A single RWMutex guards all state: the waiting queue, the scheduled,
+// prepared and closed lists, the ID index, the counter, the listeners and
+// every field of the tokens held in them. Mutations take the write lock,
+// reads take the read lock, and no method calls another locking method while
+// holding mu. Tokens handed to callers are copies, so callers never share
+// memory with the manager and need no locking of their own.
+//
+// Each release of the write lock moves the state on a generation. ListOrders
+// keeps its last listing with the generation it was built at and hands out
+// copies of it without taking mu until the generation moves on, so dashboards
+// polling the queue do not hold up orders being placed and prepared.
+type OrderManager struct {
+	cfg         Config
+	mu          sync.RWMutex
+	clock       clock.Clock             // Tells the time; clock.System unless WithClock is given
+	waiting     Queue                   // Orders to prepare; a MemoryQueue unless WithQueue is given
+	strategy    Strategy                // Chooses the next order to prepare
+	ids         IDGenerator             // Allocates order IDs; nil for the sequential counter
+	scheduled   []*queue.Token          // Pre-orders sorted by release time
+	waitlist    []*queue.Token          // Placed while their station was full, oldest first
+	unpaid      []*queue.Token          // Held for payment, oldest first
+	inProgress  []*queue.Token          // Claimed by cooks, oldest claim first
+	blocked     []*queue.Token          // Parked while their item is unavailable, oldest first
+	onHold      []*queue.Token          // Later courses waiting to be fired, oldest first
+	prepared    []*queue.Token          // Awaiting pickup, oldest first
+	closed      []*queue.Token          // Cancelled, picked up or expired, in closing order
+	byID        map[string]*queue.Token // Every token held, whatever its status
+	search      *searchIndex            // Words of the tokens in byID
+	unavailable map[string]bool         // Items run out, by itemKey
+	counter     int
+	seq         uint64        // Last Seq handed out
+	daily       int           // Last daily token number handed out
+	lastClose   time.Time     // When the current business day began
+	closeRetry  time.Time     // Earliest retry of a failed scheduled day close
+	archiver    Archiver      // Optional; stores orders a day close removes
+	journal     Journal       // Optional; keeps the in-memory queue's changes, set by RestoreQueue
+	features    *features.Set // Optional; turns auto-expiry off at runtime
+	listeners   []Listener
+	lastTick    time.Time               // When Run last did its background work
+	prepTimes   map[string][]time.Time  // Recent prepare times per station
+	rushes      map[string][]time.Time  // Recent rushes per person, for MaxRushesPerHour
+	pause       *Pause                  // Set while new orders are turned away
+	hours       openingHours            // Parsed from cfg.Hours
+	rules       []rule                  // Parsed from cfg.PriorityRules
+	deadlines   []deadline              // SLA targets still to be met, soonest first
+	gen         atomic.Uint64           // Bumped on each release of the write lock
+	listing     atomic.Pointer[listing] // Last ListOrders result, when the queue is local
+	listMu      sync.Mutex              // Held while rebuilding listing
+
+	// stations holds the stations PauseStation paused, whose orders are not
+	// handed out
+	stations map[string]*StationPause
+
+	// promised holds the IDs of open orders with a promised-by time, which
+	// escalate watches
+	promised map[string]bool
+
+	lastQueueCheck time.Time // When Run last checked the queue for RepairQueue
+}
+
+// unlock releases the write lock, first moving the state on a generation so
+// the cached listing is rebuilt, and checking the queue with CheckQueue
+func (om *OrderManager) unlock() {
+	if om.cfg.CheckQueue {
+		if err := om.verifyQueue(); err != nil {
+			pc, _, _, _ := runtime.Caller(1)
+			om.repairQueue(context.Background(), err, methodName(pc))
+		}
+	}
+	om.gen.Add(1)
+	om.mu.Unlock()
+}
+
+// NewOrder describes an order to be placed
+type NewOrder struct {
+	Item     string
+	Priority int
+	Quantity int // Defaults to 1
+	Notes    string
+	Flags    []string // Allergy and dietary flags from the queue package
+	Station  string   // Kitchen station; empty for the default
+
+	// Where the food goes: OrderType is one of the queue order types and
+	// Table the table number for dine-in. A table implies dine-in.
+	OrderType string
+	Table     int
+
+	// Payment is the payment status at creation, unpaid by default. Paid
+	// orders skip the payment hold.
+	Payment string
+
+	// ReadyAt requests a future ready time. The order is held until the
+	// configured lead time before it and then queued.
+	ReadyAt time.Time
+
+	// Optional contact details for the ready notification
+	Phone       string
+	DeviceToken string
+
+	// Client identifies where the order was placed from, such as the
+	// address of the kiosk or phone, for Config.Quota
+	Client string
+
+	// Channel is one of the queue channels the order was placed through.
+	// An order from a delivery platform came through the delivery channel.
+	Channel string
+
+	// The delivery platform the order came through and its ID there
+	Platform   string
+	ExternalID string
+
+	// Group links the order to others of the same table or check, and
+	// NotifyGroup asks for EventGroupReady once all of them are prepared
+	Group       string
+	NotifyGroup bool
+
+	// Course is the order's course within its group, 1 for starters. Orders
+	// of a course after those already fired are held until FireCourse.
+	Course int
+
+	// PromisedBy is when the order was promised ready, such as a delivery
+	// pickup slot; see Config.EscalationWindow
+	PromisedBy time.Time
+
+	// Currency is the ISO 4217 code the order is charged in, one of
+	// Config.CurrencyPrices; empty for Config.Currency
+	Currency string
+}
+
+// WithClock makes the manager tell the time by c, such as a clock.Fake in
+// tests, for order times and everything timed from them: expiry, undo
+// windows, SLA targets, estimates and its background work
+func WithClock(c clock.Clock) Option {
+	return func(om *OrderManager) { om.clock = c }
+}
+
+// Now returns the time by the manager's clock
+func (om *OrderManager) Now() time.Time {
+	return om.clock.Now()
+}
+
+// WithFeatures makes the manager skip the background work whose flag f has
+// turned off, such as expiring uncollected orders
+func WithFeatures(f *features.Set) Option {
+	return func(om *OrderManager) { om.features = f }
+}
+
+// New returns an empty OrderManager. Call Run to start its background work.
+func New(cfg Config, opts ...Option) *OrderManager {
+	hours, _ := parseHours(cfg.Hours) // Reported by Validate
+	rules, _ := parseRules(cfg.PriorityRules)
+	// Copies UpdateMenu can change, shared with the strategy
+	cfg.ItemPrices = cloneMenu(cfg.ItemPrices)
+	cfg.ItemComplexity = cloneMenu(cfg.ItemComplexity)
+	cfg.ItemPrepTimes = cloneMenu(cfg.ItemPrepTimes)
+	om := &OrderManager{
+		cfg:      cfg,
+		byID:     make(map[string]*queue.Token),
+		search:   newSearchIndex(),
+		strategy: newStrategy(cfg),
+		hours:    hours,
+		rules:    rules,
+		clock:    clock.System,
+	}
+	for _, opt := range opts {
+		opt(om)
+	}
+	om.lastClose = om.clock.Now()
+	if om.waiting == nil {
+		om.waiting = NewMemoryQueueSize(cfg.QueueCapacity)
+	}
+	return om
+}
+
+// AddOrder creates a new order and places it in the priority queue
+func (om *OrderManager) AddOrder(ctx context.Context, item string, priority int) (*queue.Token, error) {
+	return om.PlaceOrder(ctx, NewOrder{Item: item, Priority: priority})
+}
+
+// PlaceOrder creates a token for o and places it in the priority queue, or
+// in the scheduled set when o asks for a ready time beyond the lead time.
+// When o's station is at capacity the order is waitlisted if the waitlist is
+// enabled, and otherwise rejected with a *CapacityError. Repeats of a recent
+// order are flagged or rejected, as set by DuplicateWindow. While ordering is
+// paused every order is rejected with a *PausedError, and outside the
+// opening hours every order but a pre-order for when they are open with a
+// *ClosedError. The priority rules are applied to o's priority. An order
+// placed without a station for an item in ItemStations goes to the station
+// among them that would have it ready soonest. An order for an item priced in
+// o's currency carries its Total; a currency the items are not priced in is
+// rejected with ErrCurrency. A customer with as many orders waiting as the
+// quota allows is refused with a *QuotaError.
+func (om *OrderManager) PlaceOrder(ctx context.Context, o NewOrder) (*queue.Token, error) {
+	if o.Quantity == 0 {
+		o.Quantity = 1
+	}
+	if o.Table != 0 && o.OrderType == "" {
+		o.OrderType = queue.OrderDineIn
+	}
+	if o.Platform != "" && o.Channel == "" {
+		o.Channel = queue.ChannelDelivery
+	}
+	if o.Payment == "" {
+		o.Payment = queue.PaymentUnpaid
+	}
+	hold := om.cfg.RequirePayment && o.Payment != queue.PaymentPaid
+
+	om.mu.Lock()
+	defer om.unlock()
+	if om.pause != nil {
+		return nil, &PausedError{Pause: *om.pause}
+	}
+	now := om.clock.Now()
+	if err := om.checkHours(o, now); err != nil {
+		return nil, err
+	}
+	if err := om.checkCurrency(o.Currency); err != nil {
+		return nil, err
+	}
+	dup := om.duplicateOf(o, now)
+	if dup != nil && om.cfg.RejectDuplicates {
+		return nil, &DuplicateError{Existing: dup.Clone()}
+	}
+	if err := om.checkQuota(o); err != nil {
+		return nil, err
+	}
+	if o.Station == "" {
+		var err error
+		if o.Station, err = om.dispatch(ctx, o.Item); err != nil {
+			return nil, err
+		}
+	}
+	admitted := true
+	if o.ReadyAt.IsZero() && !hold {
+		var err error
+		if admitted, err = om.admit(ctx, o.Station); err != nil {
+			return nil, err
+		}
+		if !admitted && !om.cfg.Waitlist {
+			return nil, om.capacityError(ctx, o.Station)
+		}
+	}
+	code, err := newPickupCode()
+	if err != nil {
+		return nil, err
+	}
+	id, err := om.nextID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	om.daily++
+	priority, matched := om.applyRules(o, now)
+	token := &queue.Token{
+		ID:          id,
+		Number:      om.daily,
+		Item:        o.Item,
+		Priority:    priority,
+		Rules:       matched,
+		Status:      queue.StatusPreparing,
+		Timestamp:   now,
+		Quantity:    o.Quantity,
+		Notes:       o.Notes,
+		Flags:       normalizeFlags(o.Flags),
+		Station:     o.Station,
+		OrderType:   o.OrderType,
+		Table:       o.Table,
+		Payment:     o.Payment,
+		Phone:       o.Phone,
+		DeviceToken: o.DeviceToken,
+		Client:      o.Client,
+		Channel:     o.Channel,
+		Platform:    o.Platform,
+		ExternalID:  o.ExternalID,
+		Group:       o.Group,
+		NotifyGroup: o.NotifyGroup,
+		Course:      o.Course,
+		PickupCode:  code,
+		Unavailable: om.unavailable[itemKey(o.Item)],
+	}
+	if o.Payment == queue.PaymentPaid {
+		token.PaidAt = &now
+	}
+	if dup != nil {
+		token.DuplicateOf = dup.ID
+	}
+	om.stamp(token)
+	om.price(token, o.Currency)
+	om.setSLA(token, o.ReadyAt, now)
+	om.promise(token, o.PromisedBy)
+	switch {
+	case !o.ReadyAt.IsZero():
+		om.schedule(token, o.ReadyAt)
+	case om.holdsCourse(o):
+		token.Status = queue.StatusOnHold
+		om.onHold = append(om.onHold, token)
+	case hold:
+		om.holdForPayment(token)
+	case !admitted:
+		token.Status = queue.StatusWaitlisted
+		om.waitlist = append(om.waitlist, token)
+	default:
+		if _, err := om.enqueue(ctx, token); err != nil {
+			om.daily-- // The next order is given the number instead
+			return nil, err
+		}
+	}
+	om.byID[token.ID] = token
+	om.emit(ctx, EventCreated, token)
+	om.releaseScheduled(ctx, now)
+	c := token.Clone()
+	om.estimate(ctx, c)
+	return c, nil
+}
+
+// normalizeFlags sorts flags and drops repeats, leaving nil for none
+func normalizeFlags(flags []string) []string {
+	if len(flags) == 0 {
+		return nil
+	}
+	return slices.Compact(slices.Sorted(slices.Values(flags)))
+}
+
+// lookup finds a token by ID. Waiting tokens are read from the queue, which
+// may be shared and so hold orders placed or changed by other instances, and
+// the copy read is kept in byID; mu must be held for writing.
+func (om *OrderManager) lookup(ctx context.Context, id string) (*queue.Token, error) {
+	token, err := om.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	om.byID[id] = token
+	return token, nil
+}
+
+// find returns the token with the given ID as lookup does, without keeping
+// the queue's copy in byID; mu must be held, at least for reading
+func (om *OrderManager) find(ctx context.Context, id string) (*queue.Token, error) {
+	token, ok := om.byID[id]
+	if ok && token.Status != queue.StatusPreparing {
+		return token, nil
+	}
+	queued, err := om.waiting.Get(ctx, id)
+	switch {
+	case err != nil:
+		return nil, err
+	case queued != nil:
+		return queued, nil
+	case ok:
+		// Prepared by another instance sharing the queue; its later
+		// lifecycle is tracked there
+		return token, nil
+	}
+	return nil, ErrOrderNotFound
+}
+
+// GetOrder returns the token with the given ID
+func (om *OrderManager) GetOrder(ctx context.Context, id string) (*queue.Token, error) {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	token, err := om.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c := token.Clone()
+	om.estimate(ctx, c)
+	return c, nil
+}
+
+// PrepareOrder marks the order chosen by the strategy as prepared, passing
+// over paused stations. It returns ErrQueueEmpty when there is nothing to
+// prepare.
+func (om *OrderManager) PrepareOrder(ctx context.Context) (*queue.Token, error) {
+	return om.prepareNext(ctx, nil)
+}
+
+// PrepareStationOrder marks the order the strategy chooses among station's
+// waiting orders as prepared, so each kitchen screen works through its own
+// orders. An empty station means orders without one. It returns
+// ErrQueueEmpty when the station has nothing to prepare and
+// ErrStationPaused while it is paused.
+func (om *OrderManager) PrepareStationOrder(ctx context.Context, station string) (*queue.Token, error) {
+	return om.prepareNext(ctx, &station)
+}
+
+// prepareNext prepares the next order of station, or of any station when it
+// is nil
+func (om *OrderManager) prepareNext(ctx context.Context, station *string) (*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	if station != nil && om.stationPaused(*station) {
+		return nil, ErrStationPaused
+	}
+	token, err := om.next(ctx, atStation(station))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, ErrQueueEmpty
+	}
+	return om.markPrepared(ctx, token), nil
+}
+
+// atStation returns a filter for station's orders, or nil for every order
+// when station is nil
+func atStation(station *string) func(*queue.Token) bool {
+	if station == nil {
+		return nil
+	}
+	return func(t *queue.Token) bool { return t.Station == *station }
+}
+
+// PrepareOrderByID marks a particular waiting or in-progress order as
+// prepared, wherever it is in the queue, for when the kitchen finishes a
+// later order first or a cook finishes a claimed one. Other orders return
+// ErrNotWaiting.
+func (om *OrderManager) PrepareOrderByID(ctx context.Context, id string) (*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	token, err := om.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if token.Status == queue.StatusInProgress {
+		om.inProgress = removeToken(om.inProgress, token)
+		return om.markPrepared(ctx, token), nil
+	}
+	if token.Status != queue.StatusPreparing {
+		return nil, ErrNotWaiting
+	}
+	queued, err := om.waiting.Remove(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if queued == nil {
+		// Prepared or cancelled by another instance sharing the queue
+		return nil, ErrNotWaiting
+	}
+	return om.markPrepared(ctx, queued), nil
+}
+
+// markPrepared records that token, just taken out of the queue, is prepared
+// and returns a copy; mu must be held
+func (om *OrderManager) markPrepared(ctx context.Context, token *queue.Token) *queue.Token {
+	now := om.clock.Now()
+	if token.SLA != nil && token.SLA.BreachedAt == nil && now.After(token.SLA.DueAt) {
+		// Late since the last check
+		sla := *token.SLA
+		sla.BreachedAt = &now
+		token.SLA = &sla
+		om.emit(ctx, EventBreached, token)
+	}
+	token.Status = queue.StatusPrepared
+	token.PreparedAt = &now
+	om.byID[token.ID] = token
+	om.prepared = append(om.prepared, token)
+	om.recordPrepare(token.Station, now)
+	om.emit(ctx, EventPrepared, token)
+	om.groupReady(token)
+	om.drainWaitlist(ctx)
+	return token.Clone()
+}
+
+// next takes the strategy's choice among the eligible waiting orders out of
+// the queue, or returns nil when there are none. Orders at paused stations
+// are never eligible. mu must be held.
+func (om *OrderManager) next(ctx context.Context, eligible func(*queue.Token) bool) (*queue.Token, error) {
+	if _, strict := om.strategy.(StrictPriority); strict && eligible == nil && len(om.stations) == 0 {
+		return om.waiting.Pop(ctx)
+	}
+	// Another instance sharing the queue may take the chosen order first
+	for attempt := 0; attempt < 3; attempt++ {
+		waiting, err := om.waiting.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		waiting = slices.DeleteFunc(waiting, func(t *queue.Token) bool {
+			return om.stationPaused(t.Station) || eligible != nil && !eligible(t)
+		})
+		if len(waiting) == 0 {
+			return nil, nil
+		}
+		// Rushed orders go first whatever the strategy
+		if rushed := slices.DeleteFunc(slices.Clone(waiting), func(t *queue.Token) bool { return t.Priority != queue.RushPriority }); len(rushed) > 0 {
+			waiting = rushed
+		}
+		token, err := om.waiting.Remove(ctx, om.strategy.Next(waiting).ID)
+		if err != nil || token != nil {
+			return token, err
+		}
+	}
+	return nil, fmt.Errorf("queue changed under every attempt to take an order")
+}
+
+// listingMaxAge bounds how long a cached listing is handed out while nothing
+// changes, as its ready-time estimates drift with the clock
+const listingMaxAge = time.Second
+
+// listing is a ListOrders result as of a generation of the state. Its tokens
+// are copies no one else holds and are never changed.
+type listing struct {
+	gen                 uint64
+	at                  time.Time
+	preparing, prepared []*queue.Token
+}
+
+// ListOrders lists preparing orders in the order the queue lists them, with
+// their projected ready times, and prepared orders oldest first. With the
+// in-memory queue the listing is cached until the state next changes, or
+// for at most listingMaxAge; a queue shared with other instances can change
+// behind the manager's back, so is listed on every call.
+func (om *OrderManager) ListOrders(ctx context.Context) ([]*queue.Token, []*queue.Token, error) {
+	if _, local := om.waiting.(*MemoryQueue); !local {
+		om.mu.RLock()
+		defer om.mu.RUnlock()
+		return om.list(ctx)
+	}
+	if l := om.cachedListing(); l != nil {
+		return copyTokens(l.preparing), copyTokens(l.prepared), nil
+	}
+	// One caller rebuilds at a time, so a burst of polls after a change
+	// holds the read lock once rather than once each
+	om.listMu.Lock()
+	defer om.listMu.Unlock()
+	l := om.cachedListing()
+	if l == nil {
+		om.mu.RLock()
+		preparing, prepared, err := om.list(ctx)
+		gen := om.gen.Load()
+		om.mu.RUnlock()
+		if err != nil {
+			return nil, nil, err
+		}
+		l = &listing{gen: gen, at: om.clock.Now(), preparing: preparing, prepared: prepared}
+		om.listing.Store(l)
+	}
+	return copyTokens(l.preparing), copyTokens(l.prepared), nil
+}
+
+// cachedListing returns the cached listing while it is current, or nil
+func (om *OrderManager) cachedListing() *listing {
+	if l := om.listing.Load(); l != nil && l.gen == om.gen.Load() && om.clock.Now().Sub(l.at) < listingMaxAge {
+		return l
+	}
+	return nil
+}
+
+// copyTokens copies the tokens of a cached listing, which already carry
+// their versions and estimates
+func copyTokens(tokens []*queue.Token) []*queue.Token {
+	out := make([]*queue.Token, len(tokens))
+	for i, t := range tokens {
+		out[i] = t.Copy()
+	}
+	return out
+}
+
+// list builds the ListOrders result; mu must be held, at least for reading
+func (om *OrderManager) list(ctx context.Context) ([]*queue.Token, []*queue.Token, error) {
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	preparing := make([]*queue.Token, len(waiting))
+	for i, t := range waiting {
+		preparing[i] = t.Clone()
+	}
+	withETAs(preparing, om.plan(waiting, om.clock.Now()))
+	om.withPositions(preparing, waiting)
+
+	prepared := make([]*queue.Token, len(om.prepared))
+	for i, t := range om.prepared {
+		prepared[i] = t.Clone()
+	}
+
+	return preparing, prepared, nil
+}
@@ -0,0 +1,3155 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/clock"
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/features"
+	"awesomeProject/pkg/queue"
+)
+
+func add(t testing.TB, om *OrderManager, item string, priority int) *queue.Token {
+	t.Helper()
+	return place(t, om, NewOrder{Item: item, Priority: priority})
+}
+
+func place(t testing.TB, om *OrderManager, o NewOrder) *queue.Token {
+	t.Helper()
+	tok, err := om.PlaceOrder(context.Background(), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func prepare(t *testing.T, om *OrderManager) *queue.Token {
+	t.Helper()
+	tok, err := om.PrepareOrder(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func list(t *testing.T, om *OrderManager) (preparing, prepared []*queue.Token) {
+	t.Helper()
+	preparing, prepared, err := om.ListOrders(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return preparing, prepared
+}
+
+// checkInvariants verifies the manager's internal state under its lock
+func checkInvariants(t *testing.T, om *OrderManager) {
+	t.Helper()
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+
+	mq := om.waiting.(*MemoryQueue)
+	if err := mq.Verify(); err != nil {
+		t.Error(err)
+	}
+	for _, tok := range mq.pq {
+		if tok.Status != queue.StatusPreparing {
+			t.Errorf("queued token %s has status %q", tok.ID, tok.Status)
+		}
+	}
+	for _, tok := range om.prepared {
+		if tok.Status != queue.StatusPrepared {
+			t.Errorf("prepared token %s has status %q", tok.ID, tok.Status)
+		}
+	}
+	for _, tok := range om.closed {
+		switch tok.Status {
+		case queue.StatusCancelled, queue.StatusPickedUp, queue.StatusExpired:
+		default:
+			t.Errorf("closed token %s has status %q", tok.ID, tok.Status)
+		}
+	}
+	for _, tok := range om.waitlist {
+		if tok.Status != queue.StatusWaitlisted {
+			t.Errorf("waitlisted token %s has status %q", tok.ID, tok.Status)
+		}
+	}
+	for _, tok := range om.unpaid {
+		if tok.Status != queue.StatusAwaitingPayment {
+			t.Errorf("unpaid token %s has status %q", tok.ID, tok.Status)
+		}
+	}
+	for _, tok := range om.scheduled {
+		if tok.Status != queue.StatusScheduled {
+			t.Errorf("scheduled token %s has status %q", tok.ID, tok.Status)
+		}
+	}
+	for _, tok := range om.inProgress {
+		if tok.Status != queue.StatusInProgress || tok.ClaimedAt == nil {
+			t.Errorf("in-progress token %s has status %q", tok.ID, tok.Status)
+		}
+	}
+	for id, tok := range mq.byID {
+		if om.byID[id] != tok {
+			t.Errorf("queued token %s is not the indexed one", id)
+		}
+	}
+	if n := len(mq.pq) + len(om.waitlist) + len(om.unpaid) + len(om.scheduled) + len(om.inProgress) + len(om.prepared) + len(om.closed); n != len(om.byID) {
+		t.Errorf("%d tokens tracked, %d indexed", n, len(om.byID))
+	}
+	for id := range om.byID {
+		if om.ids == nil && sequence(id) > om.counter {
+			t.Errorf("token %s is beyond the counter %d", id, om.counter)
+		}
+	}
+}
+
+func TestStateTransitions(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+
+	if tok, err := om.PrepareOrder(ctx); err != ErrQueueEmpty {
+		t.Fatalf("PrepareOrder on empty queue = %+v, %v, want ErrQueueEmpty", tok, err)
+	}
+
+	first := add(t, om, "burger", 2)
+	second := add(t, om, "fries", 1)
+	if first.ID != "1" || second.ID != "2" {
+		t.Fatalf("IDs = %s, %s, want 1, 2", first.ID, second.ID)
+	}
+	if first.Status != queue.StatusPreparing {
+		t.Fatalf("new order status = %q, want %q", first.Status, queue.StatusPreparing)
+	}
+
+	got := prepare(t, om)
+	if got.ID != second.ID {
+		t.Fatalf("PrepareOrder = %+v, want token %s", got, second.ID)
+	}
+	if got.Status != queue.StatusPrepared {
+		t.Fatalf("prepared order status = %q, want %q", got.Status, queue.StatusPrepared)
+	}
+
+	preparing, prepared := list(t, om)
+	if len(preparing) != 1 || preparing[0].ID != first.ID {
+		t.Fatalf("preparing = %v, want token %s", preparing, first.ID)
+	}
+	if len(prepared) != 1 || prepared[0].ID != second.ID {
+		t.Fatalf("prepared = %v, want token %s", prepared, second.ID)
+	}
+	checkInvariants(t, om)
+
+	prepare(t, om)
+	if tok, err := om.PrepareOrder(ctx); err != ErrQueueEmpty {
+		t.Fatalf("PrepareOrder on drained queue = %+v, %v, want ErrQueueEmpty", tok, err)
+	}
+	checkInvariants(t, om)
+}
+
+func TestQueryOrders(t *testing.T) {
+	om := New(DefaultConfig())
+	add(t, om, "pizza", 3)
+	add(t, om, "salad", 1)
+	add(t, om, "pizza slice", 2)
+	add(t, om, "soda", 1)
+	prepare(t, om) // salad
+
+	one, two := 1, 2
+	tests := []struct {
+		name      string
+		filter    OrderFilter
+		wantIDs   []int
+		wantTotal int
+	}{
+		{"default order", OrderFilter{}, []int{4, 3, 1, 2}, 4},
+		{"status preparing", OrderFilter{Status: queue.StatusPreparing}, []int{4, 3, 1}, 3},
+		{"status prepared", OrderFilter{Status: queue.StatusPrepared}, []int{2}, 1},
+		{"item substring", OrderFilter{Item: "PIZZA"}, []int{3, 1}, 2},
+		{"priority range", OrderFilter{MinPriority: &one, MaxPriority: &two}, []int{4, 3, 2}, 3},
+		{"sort by id desc", OrderFilter{Sort: "id", Desc: true}, []int{4, 3, 2, 1}, 4},
+		{"sort by item", OrderFilter{Sort: "item"}, []int{1, 3, 2, 4}, 4},
+		{"limit and offset", OrderFilter{Sort: "id", Limit: 2, Offset: 1}, []int{2, 3}, 4},
+		{"offset past end", OrderFilter{Offset: 10}, nil, 4},
+		{"future range", OrderFilter{From: time.Now().Add(time.Hour)}, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := om.QueryOrders(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+			var ids []string
+			for _, tok := range got {
+				ids = append(ids, tok.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("IDs = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestQueryOrdersCancelled(t *testing.T) {
+	om := New(DefaultConfig())
+	add(t, om, "pizza", 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if orders, _, err := om.QueryOrders(ctx, OrderFilter{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("QueryOrders after cancel = %v, %v, want context.Canceled", orders, err)
+	}
+}
+
+func TestConcurrentOperations(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	const workers, perWorker = 8, 200
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(3)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				om.AddOrder(ctx, fmt.Sprintf("item-%d-%d", w, i), i%5)
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker/2; i++ {
+				om.PrepareOrder(ctx)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker/4; i++ {
+				preparing, prepared, _ := om.ListOrders(ctx)
+				for _, tok := range append(preparing, prepared...) {
+					_ = tok.Status
+				}
+				om.QueryOrders(ctx, OrderFilter{Status: queue.StatusPrepared, Limit: 10})
+			}
+		}()
+	}
+	wg.Wait()
+
+	checkInvariants(t, om)
+
+	preparing, prepared := list(t, om)
+	seen := make(map[string]bool)
+	for _, tok := range append(preparing, prepared...) {
+		if seen[tok.ID] {
+			t.Fatalf("token %s listed twice", tok.ID)
+		}
+		seen[tok.ID] = true
+	}
+	if len(seen) != workers*perWorker {
+		t.Fatalf("got %d distinct tokens, want %d", len(seen), workers*perWorker)
+	}
+}
+
+func TestReturnedTokensAreCopies(t *testing.T) {
+	om := New(DefaultConfig())
+	added := add(t, om, "soup", 1)
+	added.Status = "tampered"
+	added.Priority = -100
+
+	preparing, _ := list(t, om)
+	if preparing[0].Status != "preparing" || preparing[0].Priority != 1 {
+		t.Fatalf("caller mutation leaked into manager: %+v", preparing[0])
+	}
+
+	preparing[0].Item = "changed"
+	prepared := prepare(t, om)
+	if prepared.Item != "soup" {
+		t.Fatalf("listing mutation leaked into manager: %+v", prepared)
+	}
+	checkInvariants(t, om)
+}
+
+func TestModifyOrder(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	tok := place(t, om, NewOrder{Item: "pizza", Priority: 1})
+	if tok.Quantity != 1 {
+		t.Fatalf("default quantity = %d, want 1", tok.Quantity)
+	}
+
+	item, qty := "calzone", 2
+	got, err := om.ModifyOrder(ctx, tok.ID, OrderChanges{Item: &item, Quantity: &qty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Item != "calzone" || got.Quantity != 2 || len(got.Edits) != 2 {
+		t.Fatalf("modified token = %+v", got)
+	}
+	if e := got.Edits[0]; e.Field != "item" || e.From != "pizza" || e.To != "calzone" {
+		t.Fatalf("edit = %+v", e)
+	}
+
+	if _, err := om.ModifyOrder(ctx, "99", OrderChanges{Item: &item}); err != ErrOrderNotFound {
+		t.Fatalf("unknown ID error = %v", err)
+	}
+
+	// Raising the priority moves the order ahead of one placed earlier
+	later := add(t, om, "soup", 3)
+	urgent := 0
+	if _, err := om.ModifyOrder(ctx, later.ID, OrderChanges{Priority: &urgent}); err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, om)
+	if got := prepare(t, om); got.ID != later.ID {
+		t.Fatalf("prepared %s after reprioritizing, want %s", got.ID, later.ID)
+	}
+
+	prepare(t, om)
+	if _, err := om.ModifyOrder(ctx, tok.ID, OrderChanges{Item: &item}); err != ErrNotModifiable {
+		t.Fatalf("prepared order error = %v", err)
+	}
+}
+
+func TestVersionedChanges(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	read := place(t, om, NewOrder{Item: "pizza", Priority: 1})
+	if read.Version == "" {
+		t.Fatal("placed order has no version")
+	}
+
+	// Two people change the order they read; the second has to redo it
+	notes := "extra cheese"
+	changed, err := om.ModifyOrder(ctx, read.ID, OrderChanges{Notes: &notes, Version: read.Version})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Version == read.Version {
+		t.Fatal("version unchanged after a change")
+	}
+	qty := 2
+	_, err = om.ModifyOrder(ctx, read.ID, OrderChanges{Quantity: &qty, Version: read.Version})
+	var stale *VersionError
+	if !errors.As(err, &stale) || !errors.Is(err, ErrStaleVersion) || stale.Current.Notes != notes {
+		t.Fatalf("stale change = %v", err)
+	}
+	if _, err := om.CancelOrder(ctx, read.ID, read.Version); !errors.As(err, &stale) {
+		t.Fatalf("stale cancel = %v", err)
+	}
+	if _, err := om.CancelOrder(ctx, read.ID, stale.Current.Version); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	a := add(t, om, "a", 1)
+	b := add(t, om, "b", 2)
+	add(t, om, "c", 3)
+
+	got, err := om.CancelOrder(ctx, b.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != queue.StatusCancelled || got.CancelledAt == nil {
+		t.Fatalf("cancelled token = %+v", got)
+	}
+	checkInvariants(t, om)
+
+	if _, err := om.CancelOrder(ctx, b.ID, ""); err != ErrNotCancellable {
+		t.Fatalf("second cancel error = %v", err)
+	}
+	prepare(t, om)
+	if _, err := om.CancelOrder(ctx, a.ID, ""); err != ErrNotCancellable {
+		t.Fatalf("cancel prepared error = %v", err)
+	}
+	if next := prepare(t, om); next.Item != "c" {
+		t.Fatalf("next prepared = %q, want c", next.Item)
+	}
+	checkInvariants(t, om)
+}
+
+func TestScheduledOrders(t *testing.T) {
+	ctx := context.Background()
+	om := New(Config{ScheduleLeadTime: config.Duration(10 * time.Minute)})
+	now := time.Now()
+
+	soon := place(t, om, NewOrder{Item: "soon", ReadyAt: now.Add(5 * time.Minute)})
+	if soon.Status != queue.StatusPreparing {
+		t.Fatalf("order inside lead time has status %q, want preparing", soon.Status)
+	}
+	later := place(t, om, NewOrder{Item: "later", ReadyAt: now.Add(time.Hour)})
+	if later.Status != queue.StatusScheduled {
+		t.Fatalf("pre-order status = %q, want scheduled", later.Status)
+	}
+	checkInvariants(t, om)
+
+	om.tick(ctx, now.Add(49*time.Minute))
+	if got, _ := om.GetOrder(ctx, later.ID); got.Status != queue.StatusScheduled {
+		t.Fatalf("released early: %q", got.Status)
+	}
+	om.tick(ctx, now.Add(50*time.Minute))
+	if got, _ := om.GetOrder(ctx, later.ID); got.Status != queue.StatusPreparing {
+		t.Fatalf("not released at lead time: %q", got.Status)
+	}
+	checkInvariants(t, om)
+
+	cancelMe := place(t, om, NewOrder{Item: "x", ReadyAt: now.Add(2 * time.Hour)})
+	if _, err := om.CancelOrder(ctx, cancelMe.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, om)
+}
+
+func TestPickupAndExpiry(t *testing.T) {
+	ctx := context.Background()
+	om := New(Config{PreparedTTL: config.Duration(10 * time.Minute)})
+	a := add(t, om, "a", 1)
+	b := add(t, om, "b", 2)
+
+	if _, err := om.PickUpOrder(ctx, a.ID, ""); err != ErrNotPrepared {
+		t.Fatalf("pickup of queued order error = %v", err)
+	}
+	prepare(t, om)
+	prepare(t, om)
+
+	got, err := om.PickUpOrder(ctx, a.ID, a.PickupCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != queue.StatusPickedUp || got.PickedUpAt == nil {
+		t.Fatalf("picked up token = %+v", got)
+	}
+
+	var expired []string
+	om.Subscribe(func(e Event) {
+		if e.Type == EventExpired {
+			expired = append(expired, e.Token.ID)
+		}
+	})
+	om.tick(ctx, time.Now().Add(5*time.Minute))
+	if len(expired) != 0 {
+		t.Fatalf("expired too early: %v", expired)
+	}
+	om.tick(ctx, time.Now().Add(11*time.Minute))
+	if len(expired) != 1 || expired[0] != b.ID {
+		t.Fatalf("expired = %v, want [%s]", expired, b.ID)
+	}
+	if _, prepared := list(t, om); len(prepared) != 0 {
+		t.Fatalf("expired order still awaiting pickup: %v", prepared)
+	}
+	checkInvariants(t, om)
+}
+
+func TestAutoExpiryFlag(t *testing.T) {
+	ctx := context.Background()
+	flags, err := features.Open(features.Config{Flags: map[string]bool{features.AutoExpiry: false}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	om := New(Config{PreparedTTL: config.Duration(10 * time.Minute)}, WithFeatures(flags))
+	a := add(t, om, "a", 1)
+	prepare(t, om)
+
+	om.tick(ctx, time.Now().Add(11*time.Minute))
+	if got, _ := om.GetOrder(ctx, a.ID); got.Status != queue.StatusPrepared {
+		t.Fatalf("status with autoExpiry off = %s", got.Status)
+	}
+	if _, err := flags.Set(features.AutoExpiry, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	om.tick(ctx, time.Now().Add(11*time.Minute))
+	if got, _ := om.GetOrder(ctx, a.ID); got.Status != queue.StatusExpired {
+		t.Fatalf("status with autoExpiry on = %s", got.Status)
+	}
+	checkInvariants(t, om)
+}
+
+func TestUnprepareOrder(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	first := add(t, om, "first", 1)
+	add(t, om, "second", 1)
+
+	prepare(t, om)
+	got, err := om.UnprepareOrder(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != queue.StatusPreparing || got.PreparedAt != nil || !got.Timestamp.Equal(first.Timestamp) {
+		t.Fatalf("unprepared token = %+v", got)
+	}
+	if next := prepare(t, om); next.ID != first.ID {
+		t.Fatalf("token did not regain its position: next is %s", next.ID)
+	}
+	checkInvariants(t, om)
+
+	om.mu.Lock()
+	old := time.Now().Add(-time.Hour)
+	om.byID[first.ID].PreparedAt = &old
+	om.unlock()
+	if _, err := om.UnprepareOrder(ctx, first.ID); err != ErrGraceExpired {
+		t.Fatalf("late undo error = %v", err)
+	}
+}
+
+func TestRequeuePrepared(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	a := add(t, om, "a", 1)
+	b := add(t, om, "b", 1)
+	c := add(t, om, "c", 1)
+	for range 3 {
+		prepare(t, om)
+	}
+	old := time.Now().Add(-time.Hour)
+	om.mu.Lock()
+	om.byID[a.ID].PreparedAt = &old
+	om.unlock()
+
+	since := time.Now().Add(-time.Minute)
+	dry, err := om.RequeuePrepared(ctx, RequeueFilter{From: since, DryRun: true})
+	if err != nil || !dry.DryRun || len(dry.Requeued) != 2 {
+		t.Fatalf("dry run = %+v, %v", dry, err)
+	}
+	if got, _ := om.GetOrder(ctx, b.ID); got.Status != queue.StatusPrepared {
+		t.Fatalf("dry run moved %s to %s", b.ID, got.Status)
+	}
+
+	res, err := om.RequeuePrepared(ctx, RequeueFilter{IDs: []string{a.ID, b.ID, "nope"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Requeued) != 2 || fmt.Sprint(res.Skipped) != "[nope]" {
+		t.Fatalf("requeue = %+v", res)
+	}
+	if next := prepare(t, om); next.ID != a.ID {
+		t.Errorf("next after requeue = %s, want the oldest %s", next.ID, a.ID)
+	}
+	if got, _ := om.GetOrder(ctx, c.ID); got.Status != queue.StatusPrepared {
+		t.Errorf("unselected order is %s", got.Status)
+	}
+	checkInvariants(t, om)
+}
+
+func TestCapacityLimits(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.MaxPending = 2
+	cfg.StationMaxPending = map[string]int{"bar": 1}
+	om := New(cfg)
+
+	add(t, om, "a", 1)
+	add(t, om, "b", 1)
+	_, err := om.AddOrder(ctx, "c", 1)
+	var full *CapacityError
+	if !errors.As(err, &full) || !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("order beyond capacity error = %v", err)
+	}
+	if full.Pending != 2 || full.EstimatedWait != 2*time.Duration(cfg.DefaultPrepTime) {
+		t.Fatalf("capacity error = %+v", full)
+	}
+
+	place(t, om, NewOrder{Item: "beer", Priority: 1, Station: "bar"})
+	if _, err := om.PlaceOrder(ctx, NewOrder{Item: "wine", Station: "bar"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("bar beyond its own limit error = %v", err)
+	}
+	checkInvariants(t, om)
+
+	prepare(t, om)
+	add(t, om, "c", 1)
+	checkInvariants(t, om)
+}
+
+func TestWaitlist(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.MaxPending = 1
+	cfg.Waitlist = true
+	om := New(cfg)
+
+	var released []string
+	om.Subscribe(func(e Event) {
+		if e.Type == EventReleased {
+			released = append(released, e.Token.ID)
+		}
+	})
+	a := add(t, om, "a", 1)
+	b := add(t, om, "b", 1)
+	c := add(t, om, "c", 0)
+	if b.Status != queue.StatusWaitlisted || c.Status != queue.StatusWaitlisted {
+		t.Fatalf("statuses = %q, %q, want waitlisted", b.Status, c.Status)
+	}
+	checkInvariants(t, om)
+
+	if _, err := om.CancelOrder(ctx, b.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := prepare(t, om); got.ID != a.ID {
+		t.Fatalf("prepared %s, want %s", got.ID, a.ID)
+	}
+	if len(released) != 1 || released[0] != c.ID {
+		t.Fatalf("released = %v, want [%s]", released, c.ID)
+	}
+	if got, _ := om.GetOrder(ctx, c.ID); got.Status != queue.StatusPreparing {
+		t.Fatalf("drained order status = %q", got.Status)
+	}
+	checkInvariants(t, om)
+}
+
+func TestPayment(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.RequirePayment = true
+	om := New(cfg)
+
+	held := add(t, om, "soup", 1)
+	paid := place(t, om, NewOrder{Item: "tea", Priority: 2, Payment: queue.PaymentPaid})
+	if held.Status != queue.StatusAwaitingPayment || held.Payment != queue.PaymentUnpaid {
+		t.Fatalf("unpaid order = %q/%q, want awaiting payment", held.Status, held.Payment)
+	}
+	if paid.Status != queue.StatusPreparing {
+		t.Fatalf("paid order status = %q", paid.Status)
+	}
+	checkInvariants(t, om)
+
+	if _, err := om.SetPayment(ctx, held.ID, queue.PaymentRefunded, ""); err != ErrPaymentTransition {
+		t.Fatalf("refund unpaid order: err = %v, want ErrPaymentTransition", err)
+	}
+	got, err := om.SetPayment(ctx, held.ID, queue.PaymentPaid, "txn-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != queue.StatusPreparing || got.PaymentRef != "txn-1" || got.PaidAt == nil {
+		t.Fatalf("paid order = %+v", got)
+	}
+	if again, err := om.SetPayment(ctx, held.ID, queue.PaymentPaid, ""); err != nil || !again.PaidAt.Equal(*got.PaidAt) {
+		t.Fatalf("repeat payment = %+v, %v", again, err)
+	}
+	checkInvariants(t, om)
+
+	if tok := prepare(t, om); tok.ID != held.ID {
+		t.Fatalf("prepared %s, want %s", tok.ID, held.ID)
+	}
+	refunded, err := om.SetPayment(ctx, held.ID, queue.PaymentRefunded, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refunded.Payment != queue.PaymentRefunded || refunded.RefundedAt == nil || refunded.Status != queue.StatusPrepared {
+		t.Fatalf("refunded order = %+v", refunded)
+	}
+	if _, err := om.SetPayment(ctx, held.ID, queue.PaymentPaid, ""); err != ErrPaymentTransition {
+		t.Fatalf("pay refunded order: err = %v, want ErrPaymentTransition", err)
+	}
+	checkInvariants(t, om)
+}
+
+func TestPrepareStationOrder(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	place(t, om, NewOrder{Item: "steak", Priority: 2, Station: "grill"})
+	burger := place(t, om, NewOrder{Item: "burger", Priority: 1, Station: "grill"})
+	place(t, om, NewOrder{Item: "salad", Priority: 0, Station: "cold"})
+	water := add(t, om, "water", 3)
+
+	got, err := om.PrepareStationOrder(ctx, "grill")
+	if err != nil || got.ID != burger.ID {
+		t.Fatalf("PrepareStationOrder(grill) = %+v, %v, want order %s", got, err, burger.ID)
+	}
+	if got, err := om.PrepareStationOrder(ctx, ""); err != nil || got.ID != water.ID {
+		t.Fatalf("PrepareStationOrder(\"\") = %+v, %v, want order %s", got, err, water.ID)
+	}
+	if _, err := om.PrepareStationOrder(ctx, "bar"); err != ErrQueueEmpty {
+		t.Fatalf("PrepareStationOrder(bar) err = %v, want ErrQueueEmpty", err)
+	}
+	checkInvariants(t, om)
+}
+
+type archiveFunc func(DayClose) error
+
+func (f archiveFunc) Archive(day DayClose) error { return f(day) }
+
+func TestCloseDay(t *testing.T) {
+	ctx := context.Background()
+	var archived []DayClose
+	fail := errors.New("disk full")
+	var archiveErr error
+	om := New(DefaultConfig(), WithArchiver(archiveFunc(func(day DayClose) error {
+		if archiveErr != nil {
+			return archiveErr
+		}
+		archived = append(archived, day)
+		return nil
+	})))
+
+	done := add(t, om, "soup", 1)
+	cancelled := add(t, om, "tea", 2)
+	waiting := add(t, om, "cake", 3)
+	prepare(t, om)
+	if _, err := om.CancelOrder(ctx, cancelled.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if waiting.Number != 3 {
+		t.Fatalf("third order number = %d", waiting.Number)
+	}
+
+	archiveErr = fail
+	if _, err := om.CloseDay(ctx); err != fail {
+		t.Fatalf("CloseDay with failing archive: err = %v", err)
+	}
+	if _, err := om.GetOrder(ctx, done.ID); err != nil {
+		t.Fatalf("order removed after failed close: %v", err)
+	}
+
+	archiveErr = nil
+	day, err := om.CloseDay(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archived) != 1 || len(day.Orders) != 2 || day.Orders[0].ID != done.ID || day.Orders[1].ID != cancelled.ID {
+		t.Fatalf("archived = %+v", day.Orders)
+	}
+	if _, err := om.GetOrder(ctx, done.ID); err != ErrOrderNotFound {
+		t.Fatalf("archived order lookup err = %v, want ErrOrderNotFound", err)
+	}
+	if got, _ := om.GetOrder(ctx, waiting.ID); got.Status != queue.StatusPreparing {
+		t.Fatalf("carried over order status = %q", got.Status)
+	}
+	next := add(t, om, "pie", 1)
+	if next.Number != 1 || next.ID != "4" {
+		t.Fatalf("first order after close: number %d, id %s", next.Number, next.ID)
+	}
+	checkInvariants(t, om)
+}
+
+func TestScheduledDayClose(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.DayCloseAt = "03:00"
+	om := New(cfg)
+	add(t, om, "soup", 1)
+	prepare(t, om)
+
+	start := time.Date(2026, 3, 1, 22, 0, 0, 0, time.Local)
+	om.lastClose = start
+	om.tick(ctx, start.Add(4*time.Hour))
+	if _, err := om.GetOrder(ctx, "1"); err != nil {
+		t.Fatalf("closed before 03:00: %v", err)
+	}
+	om.tick(ctx, start.Add(5*time.Hour))
+	if _, err := om.GetOrder(ctx, "1"); err != ErrOrderNotFound {
+		t.Fatalf("not closed after 03:00: err = %v", err)
+	}
+	if want := start.Add(5 * time.Hour); !om.lastClose.Equal(want) {
+		t.Fatalf("lastClose = %v, want %v", om.lastClose, want)
+	}
+	checkInvariants(t, om)
+}
+
+func TestStrategies(t *testing.T) {
+	drain := func(om *OrderManager) []string {
+		var items []string
+		for {
+			tok, err := om.PrepareOrder(context.Background())
+			if err == ErrQueueEmpty {
+				return items
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			items = append(items, tok.Item)
+		}
+	}
+
+	t.Run("weighted", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Strategy = StrategyWeighted
+		cfg.PriorityWeights = map[int]float64{0: 3, 5: 1}
+		om := New(cfg)
+		for i := 0; i < 6; i++ {
+			add(t, om, fmt.Sprintf("u%d", i), 0)
+		}
+		add(t, om, "l0", 5)
+		add(t, om, "l1", 5)
+		if got := fmt.Sprint(drain(om)); got != "[u0 u1 u2 l0 u3 u4 u5 l1]" {
+			t.Fatalf("order = %s", got)
+		}
+		checkInvariants(t, om)
+	})
+
+	t.Run("sjf", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Strategy = StrategySJF
+		cfg.ItemPrepTimes = map[string]config.Duration{"soda": config.Duration(time.Minute), "roast": config.Duration(time.Hour)}
+		om := New(cfg)
+		add(t, om, "roast", 0)
+		add(t, om, "burger", 1)
+		add(t, om, "soda", 2)
+		if got := fmt.Sprint(drain(om)); got != "[soda burger roast]" {
+			t.Fatalf("order = %s", got)
+		}
+	})
+
+	t.Run("roundRobin", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Strategy = StrategyRoundRobin
+		om := New(cfg)
+		for _, o := range []NewOrder{
+			{Item: "d1", OrderType: queue.OrderDineIn},
+			{Item: "d2", OrderType: queue.OrderDineIn},
+			{Item: "d3", OrderType: queue.OrderDineIn},
+			{Item: "t1", OrderType: queue.OrderTakeaway},
+			{Item: "x1", OrderType: queue.OrderDelivery},
+			{Item: "t2", OrderType: queue.OrderTakeaway},
+		} {
+			place(t, om, o)
+		}
+		if got := fmt.Sprint(drain(om)); got != "[d1 t1 x1 d2 t2 d3]" {
+			t.Fatalf("order = %s", got)
+		}
+	})
+
+	t.Run("derived", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Strategy = StrategyDerived
+		cfg.PriorityFormula = PriorityFormula{Value: 1, Complexity: 10}
+		cfg.ItemPrices = map[string]float64{"coffee": 3, "steak": 30, "salad": 12}
+		cfg.ItemComplexity = map[string]float64{"salad": 2}
+		om := New(cfg)
+		add(t, om, "coffee", 0)
+		place(t, om, NewOrder{Item: "coffee", Priority: 9, Quantity: 20})
+		add(t, om, "steak", 5)
+		add(t, om, "salad", 1)
+		// Scores: coffee 3, coffee x20 60, steak 30, salad 12+20
+		if got := fmt.Sprint(drain(om)); got != "[coffee salad steak coffee]" {
+			t.Fatalf("order = %s", got)
+		}
+	})
+
+	if err := (Config{Strategy: "random"}).Validate(); err == nil {
+		t.Fatal("unknown strategy accepted")
+	}
+}
+
+func TestReadyEstimates(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.ItemPrepTimes = map[string]config.Duration{"pizza": config.Duration(10 * time.Minute), "tea": config.Duration(2 * time.Minute)}
+	cfg.StationCooks = map[string]int{"bar": 2}
+	om := New(cfg)
+	var events []Event
+	om.Subscribe(func(e Event) { events = append(events, e) })
+
+	eta := func(tok *queue.Token) time.Time {
+		t.Helper()
+		got, err := om.GetOrder(ctx, tok.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.EstimatedReadyAt == nil {
+			t.Fatalf("order %s has no ready estimate", tok.ID)
+		}
+		return *got.EstimatedReadyAt
+	}
+	check := func(tok *queue.Token, want time.Time) {
+		t.Helper()
+		if got := eta(tok); !got.Equal(want) {
+			t.Errorf("order %s (%s) ready at %s, want %s", tok.ID, tok.Item, got, want)
+		}
+	}
+
+	tea := add(t, om, "tea", 2)
+	pizza := add(t, om, "pizza", 1)
+	if pizza.EstimatedReadyAt == nil || events[len(events)-1].Token.EstimatedReadyAt == nil {
+		t.Fatal("placed order and its event carry no ready estimate")
+	}
+	// One cook: the pizza goes first, then the tea
+	check(pizza, pizza.Timestamp.Add(10*time.Minute))
+	check(tea, pizza.Timestamp.Add(12*time.Minute))
+
+	// Two cooks at the bar work in parallel
+	a := place(t, om, NewOrder{Item: "tea", Priority: 1, Station: "bar"})
+	b := place(t, om, NewOrder{Item: "tea", Priority: 1, Station: "bar"})
+	c := place(t, om, NewOrder{Item: "tea", Priority: 1, Station: "bar"})
+	check(a, a.Timestamp.Add(2*time.Minute))
+	check(b, b.Timestamp.Add(2*time.Minute))
+	check(c, a.Timestamp.Add(4*time.Minute))
+
+	// Preparing the pizza replans the tea from the prepare time
+	done := prepare(t, om)
+	if done.ID != pizza.ID || done.EstimatedReadyAt != nil {
+		t.Fatalf("prepared %+v, want the pizza without an estimate", done)
+	}
+	check(tea, done.PreparedAt.Add(2*time.Minute))
+
+	preparing, _ := list(t, om)
+	for _, tok := range preparing {
+		if tok.EstimatedReadyAt == nil {
+			t.Errorf("listed order %s has no ready estimate", tok.ID)
+		}
+	}
+	matched, _, err := om.QueryOrders(ctx, OrderFilter{Status: queue.StatusPreparing, Item: "tea"})
+	if err != nil || len(matched) != 4 || !matched[0].EstimatedReadyAt.Equal(eta(matched[0])) {
+		t.Fatalf("QueryOrders = %v, %v", matched, err)
+	}
+}
+
+func TestDuplicateOrders(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.DuplicateWindow = config.Duration(time.Minute)
+	om := New(cfg)
+
+	first := place(t, om, NewOrder{Item: "Latte", Phone: "555-0100"})
+	again := place(t, om, NewOrder{Item: "latte", Phone: "555-0100"})
+	if again.DuplicateOf != first.ID {
+		t.Fatalf("DuplicateOf = %s, want %s", again.DuplicateOf, first.ID)
+	}
+	for _, o := range []NewOrder{
+		{Item: "latte", Phone: "555-0199"},              // Another customer
+		{Item: "latte", Phone: "555-0100", Quantity: 2}, // Another quantity
+		{Item: "latte"}, // Anonymous
+		{Item: "mocha", Phone: "555-0100"},
+	} {
+		if tok := place(t, om, o); tok.DuplicateOf != "" {
+			t.Errorf("%+v flagged as duplicate of %s", o, tok.DuplicateOf)
+		}
+	}
+
+	// Cancelled and old orders do not count
+	table := place(t, om, NewOrder{Item: "soup", Table: 4})
+	if _, err := om.CancelOrder(ctx, table.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if tok := place(t, om, NewOrder{Item: "soup", Table: 4}); tok.DuplicateOf != "" {
+		t.Errorf("repeat of cancelled order flagged as duplicate of %s", tok.DuplicateOf)
+	}
+	om.mu.Lock()
+	for _, tok := range om.byID {
+		tok.Timestamp = tok.Timestamp.Add(-2 * time.Minute)
+	}
+	om.unlock()
+	if tok := place(t, om, NewOrder{Item: "latte", Phone: "555-0100"}); tok.DuplicateOf != "" {
+		t.Errorf("order outside the window flagged as duplicate of %s", tok.DuplicateOf)
+	}
+
+	om.cfg.RejectDuplicates = true
+	recent := place(t, om, NewOrder{Item: "tea", DeviceToken: "kiosk-2"})
+	_, err := om.PlaceOrder(ctx, NewOrder{Item: "tea", DeviceToken: "kiosk-2"})
+	var dup *DuplicateError
+	if !errors.As(err, &dup) || !errors.Is(err, ErrDuplicateOrder) || dup.Existing.ID != recent.ID {
+		t.Fatalf("rejected duplicate err = %v", err)
+	}
+	checkInvariants(t, om)
+}
+
+func TestRecoverOrder(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	first := add(t, om, "first", 1)
+	add(t, om, "second", 1)
+
+	if _, err := om.RecoverOrder(ctx, first.ID); err != ErrNotCancelled {
+		t.Fatalf("recovering a waiting order: err = %v", err)
+	}
+	if _, err := om.CancelOrder(ctx, first.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	got, err := om.RecoverOrder(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != queue.StatusPreparing || got.CancelledAt != nil || !got.Timestamp.Equal(first.Timestamp) {
+		t.Fatalf("recovered token = %+v", got)
+	}
+	if next := prepare(t, om); next.ID != first.ID {
+		t.Fatalf("token did not regain its position: next is %s", next.ID)
+	}
+	checkInvariants(t, om)
+
+	// A pre-order not yet due is scheduled again
+	pre := place(t, om, NewOrder{Item: "cake", ReadyAt: time.Now().Add(time.Hour)})
+	om.CancelOrder(ctx, pre.ID, "")
+	if got, err := om.RecoverOrder(ctx, pre.ID); err != nil || got.Status != queue.StatusScheduled {
+		t.Fatalf("recovered pre-order = %+v, %v", got, err)
+	}
+	checkInvariants(t, om)
+
+	om.CancelOrder(ctx, pre.ID, "")
+	om.mu.Lock()
+	old := time.Now().Add(-time.Hour)
+	om.byID[pre.ID].CancelledAt = &old
+	om.unlock()
+	if _, err := om.RecoverOrder(ctx, pre.ID); err != ErrGraceExpired {
+		t.Fatalf("late recovery error = %v", err)
+	}
+}
+
+func TestOrderFlags(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	satay := place(t, om, NewOrder{Item: "satay", Flags: []string{queue.FlagPeanuts, queue.FlagHalal, queue.FlagPeanuts}})
+	salad := place(t, om, NewOrder{Item: "salad", Flags: []string{queue.FlagVegan}})
+	add(t, om, "steak", 1)
+	if fmt.Sprint(satay.Flags) != "[halal peanuts]" {
+		t.Fatalf("flags = %v, want sorted without repeats", satay.Flags)
+	}
+
+	ids := func(f OrderFilter) string {
+		t.Helper()
+		got, _, err := om.QueryOrders(ctx, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, tok := range got {
+			ids = append(ids, tok.ID)
+		}
+		return fmt.Sprint(ids)
+	}
+	if got := ids(OrderFilter{Flags: []string{queue.FlagAllergy}}); got != fmt.Sprint([]string{satay.ID}) {
+		t.Errorf("allergy orders = %s", got)
+	}
+
+	gluten := []string{queue.FlagGluten, queue.FlagVegan}
+	got, err := om.ModifyOrder(ctx, salad.ID, OrderChanges{Flags: &gluten})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := got.Edits[len(got.Edits)-1]; e.Field != "flags" || e.From != "vegan" || e.To != "gluten,vegan" {
+		t.Fatalf("edit = %+v", e)
+	}
+	if got := ids(OrderFilter{Flags: []string{queue.FlagAllergy}}); got != fmt.Sprint([]string{satay.ID, salad.ID}) {
+		t.Errorf("allergy orders after edit = %s", got)
+	}
+	if got := ids(OrderFilter{Flags: []string{queue.FlagAllergy, queue.FlagVegan}}); got != fmt.Sprint([]string{salad.ID}) {
+		t.Errorf("vegan allergy orders = %s", got)
+	}
+}
+
+func TestPrepareOrderByID(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	add(t, om, "soup", 1)
+	drink := add(t, om, "drink", 5)
+	add(t, om, "bread", 2)
+
+	got, err := om.PrepareOrderByID(ctx, drink.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != drink.ID || got.Status != queue.StatusPrepared || got.PreparedAt == nil {
+		t.Fatalf("prepared %+v", got)
+	}
+	checkInvariants(t, om)
+	if next := prepare(t, om); next.Item != "soup" {
+		t.Fatalf("queue order disturbed: next is %s", next.Item)
+	}
+	if _, err := om.PrepareOrderByID(ctx, drink.ID); err != ErrNotWaiting {
+		t.Fatalf("preparing twice: err = %v", err)
+	}
+	if _, err := om.PrepareOrderByID(ctx, "99"); err != ErrOrderNotFound {
+		t.Fatalf("unknown order: err = %v", err)
+	}
+	checkInvariants(t, om)
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	add(t, om, "low", 3)
+	high := add(t, om, "high", 1)
+	done := add(t, om, "done", 0)
+	if got := prepare(t, om); got.ID != done.ID {
+		t.Fatalf("prepared %s", got.ID)
+	}
+	pre := place(t, om, NewOrder{Item: "cake", ReadyAt: time.Now().Add(time.Hour)})
+	om.CancelOrder(ctx, pre.ID, "")
+	om.CloseDay(ctx)
+	add(t, om, "after close", 2)
+
+	snap, err := om.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Waiting) != 3 || snap.Waiting[0].ID != high.ID || len(snap.Prepared)+len(snap.Closed) != 0 {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := New(DefaultConfig())
+	var decoded Snapshot
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.RestoreSnapshot(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, restored)
+	if next := prepare(t, restored); next.ID != high.ID || next.PickupCode == "" || next.PickupCode != snap.PickupCodes[high.ID] {
+		t.Fatalf("next after restore = %s with code %q, want %s with %q", next.ID, next.PickupCode, high.ID, snap.PickupCodes[high.ID])
+	}
+	// IDs continue past the archived orders and numbers past today's
+	next := add(t, restored, "new", 0)
+	if next.ID != strconv.Itoa(snap.Counter+1) || next.Number != snap.Daily+1 {
+		t.Fatalf("new order ID %s number %d, snapshot counter %d daily %d", next.ID, next.Number, snap.Counter, snap.Daily)
+	}
+
+	bad := decoded
+	bad.Version = 99
+	if err := restored.RestoreSnapshot(&bad); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Fatalf("wrong version error = %v", err)
+	}
+	bad = decoded
+	bad.Prepared = append(slices.Clone(bad.Prepared), bad.Waiting[0])
+	if err := restored.RestoreSnapshot(&bad); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Fatalf("duplicate order error = %v", err)
+	}
+	if _, err := restored.GetOrder(ctx, next.ID); err != nil {
+		t.Fatalf("failed restore changed state: %v", err)
+	}
+}
+
+func TestPickupCode(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.RequirePickupCode = true
+	om := New(cfg)
+	a := add(t, om, "a", 1)
+	if len(a.PickupCode) != pickupCodeLength || strings.Trim(a.PickupCode, pickupCodeAlphabet) != "" {
+		t.Fatalf("pickup code %q", a.PickupCode)
+	}
+	prepare(t, om)
+
+	if _, err := om.PickUpOrder(ctx, a.ID, ""); err != ErrPickupCode {
+		t.Fatalf("no code: err = %v", err)
+	}
+	wrong := "2222"
+	if a.PickupCode == wrong {
+		wrong = "3333"
+	}
+	if _, err := om.PickUpOrder(ctx, a.ID, wrong); err != ErrPickupCode {
+		t.Fatalf("wrong code: err = %v", err)
+	}
+	typed := strings.ToLower(a.PickupCode[:2]) + " " + a.PickupCode[2:]
+	if got, err := om.PickUpOrder(ctx, a.ID, typed); err != nil || got.Status != queue.StatusPickedUp {
+		t.Fatalf("right code: %+v, %v", got, err)
+	}
+
+	// Without the requirement an order's code is still needed, and only
+	// orders placed before codes were issued go without
+	om = New(DefaultConfig())
+	b := add(t, om, "b", 1)
+	legacy := add(t, om, "legacy", 2)
+	om.byID[legacy.ID].PickupCode = ""
+	prepare(t, om)
+	prepare(t, om)
+	for _, code := range []string{"", wrong + "X"} {
+		if _, err := om.PickUpOrder(ctx, b.ID, code); err != ErrPickupCode {
+			t.Fatalf("code %q: err = %v", code, err)
+		}
+	}
+	if _, err := om.PickUpOrder(ctx, b.ID, b.PickupCode); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := om.PickUpOrder(ctx, legacy.ID, ""); err != nil {
+		t.Fatalf("order without a code: %v", err)
+	}
+}
+
+func TestClaimLimits(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.MaxInProgress = 1
+	cfg.StationMaxInProgress = map[string]int{"grill": 2}
+	cfg.ItemPrepTimes = map[string]config.Duration{"burger": config.Duration(10 * time.Minute)}
+	om := New(cfg)
+	b1 := place(t, om, NewOrder{Item: "burger", Station: "grill", Priority: 1})
+	b2 := place(t, om, NewOrder{Item: "burger", Station: "grill", Priority: 1})
+	b3 := place(t, om, NewOrder{Item: "burger", Station: "grill", Priority: 1})
+	tea := place(t, om, NewOrder{Item: "tea", Station: "bar", Priority: 2})
+
+	for _, want := range []string{b1.ID, b2.ID} {
+		got, err := om.ClaimStationOrder(ctx, "grill")
+		if err != nil || got.ID != want || got.Status != queue.StatusInProgress || got.ClaimedAt == nil {
+			t.Fatalf("claim = %+v, %v; want order %s", got, err, want)
+		}
+	}
+	_, err := om.ClaimStationOrder(ctx, "grill")
+	var busy *StationBusyError
+	if !errors.As(err, &busy) || !errors.Is(err, ErrStationBusy) || busy.Station != "grill" || busy.Limit != 2 {
+		t.Fatalf("claim over the limit: err = %v", err)
+	}
+	if _, err := om.ClaimOrderByID(ctx, b3.ID); !errors.As(err, &busy) {
+		t.Fatalf("claim by ID over the limit: err = %v", err)
+	}
+	checkInvariants(t, om)
+
+	// The grill's third burger waits for a free cook: the first claimed
+	// burgers finish ten minutes after their claims
+	got, err := om.GetOrder(ctx, b3.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eta := time.Until(*got.EstimatedReadyAt); eta < 19*time.Minute || eta > 21*time.Minute {
+		t.Fatalf("third burger ready in %s, want about 20m", eta)
+	}
+	if got, _ := om.GetOrder(ctx, b1.ID); got.EstimatedReadyAt == nil {
+		t.Fatal("in-progress order has no estimate")
+	}
+
+	// Any-station claims pass over the busy grill
+	if got, err := om.ClaimOrder(ctx); err != nil || got.ID != tea.ID {
+		t.Fatalf("claim = %+v, %v; want the tea", got, err)
+	}
+	if _, err := om.ClaimOrder(ctx); !errors.As(err, &busy) || busy.Station != "grill" {
+		t.Fatalf("claim with every station busy: err = %v", err)
+	}
+
+	// Finishing or cancelling a claim frees the cook
+	if got, err := om.PrepareOrderByID(ctx, b1.ID); err != nil || got.Status != queue.StatusPrepared {
+		t.Fatalf("prepare claimed = %+v, %v", got, err)
+	}
+	if _, err := om.CancelOrder(ctx, b2.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := om.ClaimOrderByID(ctx, b3.ID); err != nil || got.ID != b3.ID {
+		t.Fatalf("claim after finishing = %+v, %v", got, err)
+	}
+	if _, err := om.ClaimOrder(ctx); err != ErrQueueEmpty {
+		t.Fatalf("claim from an empty queue: err = %v", err)
+	}
+	checkInvariants(t, om)
+
+	snap, err := om.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := New(cfg)
+	if err := restored.RestoreSnapshot(snap); err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, restored)
+	if len(restored.inProgress) != 2 {
+		t.Fatalf("%d in progress after restore", len(restored.inProgress))
+	}
+}
+
+func TestRushOrder(t *testing.T) {
+	ctx := context.Background()
+	for _, strategy := range Strategies {
+		cfg := DefaultConfig()
+		cfg.Strategy = strategy
+		cfg.MaxRushesPerHour = 2
+		om := New(cfg)
+		place(t, om, NewOrder{Item: "soup", Priority: 0})
+		place(t, om, NewOrder{Item: "tea", Priority: 1})
+		late := place(t, om, NewOrder{Item: "stew", Priority: 5})
+
+		got, err := om.RushOrder(ctx, late.ID, "sam")
+		if err != nil || got.Priority != queue.RushPriority || got.RushedBy != "sam" || got.RushedAt == nil {
+			t.Fatalf("%s: rush = %+v, %v", strategy, got, err)
+		}
+		if edit := got.Edits[len(got.Edits)-1]; edit.Field != "priority" || edit.From != "5" || edit.By != "sam" {
+			t.Fatalf("%s: edit = %+v", strategy, edit)
+		}
+		checkInvariants(t, om)
+		if next, err := om.PrepareOrder(ctx); err != nil || next.ID != late.ID {
+			t.Fatalf("%s: prepared %+v, %v; want the rushed order", strategy, next, err)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.MaxRushesPerHour = 2
+	om := New(cfg)
+	var ids []string
+	for i := 0; i < 4; i++ {
+		ids = append(ids, place(t, om, NewOrder{Item: "tea", Priority: 3}).ID)
+	}
+	for _, id := range ids[:2] {
+		if _, err := om.RushOrder(ctx, id, "sam"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Rushing again is a no-op and does not count
+	if _, err := om.RushOrder(ctx, ids[0], "sam"); err != nil {
+		t.Fatalf("rush a rushed order: err = %v", err)
+	}
+	_, err := om.RushOrder(ctx, ids[2], "sam")
+	var limit *RushLimitError
+	if !errors.As(err, &limit) || !errors.Is(err, ErrRushLimit) || limit.RetryAfter <= 59*time.Minute {
+		t.Fatalf("rush over the limit: err = %v", err)
+	}
+	if _, err := om.RushOrder(ctx, ids[2], "alex"); err != nil {
+		t.Fatalf("another manager's rush: err = %v", err)
+	}
+
+	// Only orders not yet prepared can be rushed
+	om.PrepareOrder(ctx)
+	if _, err := om.RushOrder(ctx, ids[0], "alex"); err != ErrNotModifiable {
+		t.Fatalf("rush a prepared order: err = %v", err)
+	}
+	checkInvariants(t, om)
+}
+
+func TestQueuePosition(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	soup := place(t, om, NewOrder{Item: "soup", Priority: 2, Station: "hot"})
+	if soup.Position != 1 || soup.Ahead == nil || *soup.Ahead != 0 {
+		t.Fatalf("first order: position %d ahead %v", soup.Position, soup.Ahead)
+	}
+	place(t, om, NewOrder{Item: "tea", Priority: 0, Station: "bar"})
+	stew := place(t, om, NewOrder{Item: "stew", Priority: 1, Station: "hot"})
+	if stew.Position != 1 {
+		t.Fatalf("stew position %d, want 1: other stations do not count", stew.Position)
+	}
+	got, err := om.GetOrder(ctx, soup.ID)
+	if err != nil || got.Position != 2 || *got.Ahead != 1 {
+		t.Fatalf("soup after stew: %+v, %v", got, err)
+	}
+
+	var moved *queue.Token
+	om.Subscribe(func(e Event) { moved = e.Token })
+	if _, err := om.RushOrder(ctx, soup.ID, "sam"); err != nil {
+		t.Fatal(err)
+	}
+	if moved == nil || moved.Position != 1 {
+		t.Fatalf("rush event token = %+v", moved)
+	}
+
+	orders, _, err := om.QueryOrders(ctx, OrderFilter{Status: queue.StatusPreparing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 3 || orders[0].ID != soup.ID || orders[0].Position != 1 || orders[1].Position != 1 ||
+		orders[2].ID != stew.ID || orders[2].Position != 2 || *orders[2].Ahead != 1 {
+		t.Fatalf("listed = %+v", orders)
+	}
+	prepared, err := om.PrepareOrder(ctx)
+	if err != nil || prepared.Position != 0 || prepared.Ahead != nil {
+		t.Fatalf("prepared = %+v, %v", prepared, err)
+	}
+}
+
+func TestQueuePositionFollowsStrategy(t *testing.T) {
+	ctx := context.Background()
+	for _, strategy := range Strategies {
+		cfg := DefaultConfig()
+		cfg.Strategy = strategy
+		cfg.PriorityWeights = map[int]float64{0: 3, 5: 1}
+		cfg.ItemPrepTimes = map[string]config.Duration{"soda": config.Duration(time.Minute), "roast": config.Duration(time.Hour)}
+		cfg.ItemPrices = map[string]float64{"roast": 30, "soda": 2}
+		cfg.PriorityFormula = PriorityFormula{Value: 1}
+		om := New(cfg)
+		for _, o := range []NewOrder{
+			{Item: "roast", Priority: 0, OrderType: queue.OrderDineIn},
+			{Item: "burger", Priority: 0, OrderType: queue.OrderDineIn},
+			{Item: "soda", Priority: 5, OrderType: queue.OrderDineIn},
+			{Item: "burger", Priority: 0, OrderType: queue.OrderTakeaway},
+			{Item: "soda", Priority: 0, OrderType: queue.OrderDelivery},
+			{Item: "roast", Priority: 5, OrderType: queue.OrderTakeaway},
+		} {
+			place(t, om, o)
+		}
+		// Take one, so the weighted and round-robin strategies have history
+		if _, err := om.PrepareOrder(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		preparing, _, err := om.ListOrders(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byPosition := make([]string, len(preparing))
+		for _, tok := range preparing {
+			if tok.Position < 1 || tok.Position > len(preparing) || byPosition[tok.Position-1] != "" {
+				t.Fatalf("%s: position %d of %d", strategy, tok.Position, len(preparing))
+			}
+			byPosition[tok.Position-1] = tok.ID
+			if got, _ := om.GetOrder(ctx, tok.ID); got.Position != tok.Position {
+				t.Errorf("%s: order %s is %d listed, %d on its own", strategy, tok.ID, tok.Position, got.Position)
+			}
+		}
+		var taken []string
+		for range preparing {
+			tok, err := om.PrepareOrder(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			taken = append(taken, tok.ID)
+		}
+		if !slices.Equal(taken, byPosition) {
+			t.Errorf("%s: prepared %v, positions gave %v", strategy, taken, byPosition)
+		}
+	}
+}
+
+func TestQueuePositionPausedStation(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	fries := place(t, om, NewOrder{Item: "fries", Station: "fryer"})
+	if _, _, err := om.PauseStation(ctx, "fryer", "fryer broken"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := om.GetOrder(ctx, fries.ID); got.Position != 0 || got.Ahead != nil {
+		t.Errorf("order at a paused station: position %d ahead %v", got.Position, got.Ahead)
+	}
+}
+
+func TestIDGenerators(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	ulid := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+	for _, tt := range []struct {
+		format string
+		valid  *regexp.Regexp
+	}{
+		{IDUUID, uuid},
+		{IDULID, ulid},
+	} {
+		gen, err := NewIDGenerator(tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev := ""
+		for range 1000 {
+			id, err := gen.NewID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.valid.MatchString(id) {
+				t.Fatalf("%s ID %q is malformed", tt.format, id)
+			}
+			// UUIDs made in the same millisecond may come in any order
+			if tt.format == IDULID && compareIDs(prev, id) >= 0 {
+				t.Fatalf("%s ID %q after %q", tt.format, id, prev)
+			}
+			prev = id
+		}
+	}
+	if _, err := NewIDGenerator("snowflake"); err == nil {
+		t.Fatal("unknown format accepted")
+	}
+
+	if got := slices.SortedFunc(slices.Values([]string{"10", "9", "100", "1"}), compareIDs); fmt.Sprint(got) != "[1 9 10 100]" {
+		t.Fatalf("sequential IDs sorted as %v", got)
+	}
+}
+
+func TestSequenceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seq")
+	seq, err := OpenSequenceFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	om := New(DefaultConfig(), WithIDGenerator(seq))
+	if a, b := add(t, om, "soup", 1), add(t, om, "tea", 1); a.ID != "1" || b.ID != "2" {
+		t.Fatalf("IDs = %s, %s", a.ID, b.ID)
+	}
+	snap, err := om.Snapshot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart carries on from the file
+	seq, err = OpenSequenceFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted := New(DefaultConfig(), WithIDGenerator(seq))
+	if next := add(t, restarted, "stew", 1); next.ID != "3" {
+		t.Fatalf("ID after restart = %s, want 3", next.ID)
+	}
+
+	// A file left behind the restored orders skips past them
+	os.WriteFile(path, []byte("1\n"), 0o644)
+	if seq, err = OpenSequenceFile(path); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(DefaultConfig(), WithIDGenerator(seq))
+	if err := restored.RestoreSnapshot(snap); err != nil {
+		t.Fatal(err)
+	}
+	if next := add(t, restored, "pie", 1); next.ID != "3" {
+		t.Fatalf("ID after restore = %s, want 3", next.ID)
+	}
+	checkInvariants(t, restored)
+
+	os.WriteFile(path, []byte("lots"), 0o644)
+	if _, err := OpenSequenceFile(path); err == nil {
+		t.Fatal("corrupt sequence file accepted")
+	}
+}
+
+func TestAutoPrepare(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AutoPrepare = 2
+	cfg.AutoPrepareSpeed = 100
+	cfg.ItemPrepTimes = map[string]config.Duration{"soup": config.Duration(time.Second), "tea": config.Duration(2 * time.Second)}
+	om := New(cfg)
+	prepared := make(chan *queue.Token, 10)
+	om.Subscribe(func(e Event) {
+		if e.Type == EventPrepared {
+			prepared <- e.Token
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go om.Run(ctx)
+
+	start := time.Now()
+	for _, item := range []string{"soup", "tea", "soup"} {
+		add(t, om, item, 1)
+	}
+	for range 3 {
+		select {
+		case tok := <-prepared:
+			if tok.ClaimedAt == nil || tok.PreparedAt.Sub(*tok.ClaimedAt) < cfg.prepTime(tok.Item)/100 {
+				t.Errorf("order %s prepared %v after its claim", tok.ID, tok.PreparedAt.Sub(*tok.ClaimedAt))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("orders not prepared")
+		}
+	}
+	// Two workers take no longer than the longest pair of orders
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("prepared in %s", elapsed)
+	}
+	cancel()
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	if len(om.prepared) != 3 || len(om.inProgress) != 0 {
+		t.Fatalf("%d prepared, %d in progress", len(om.prepared), len(om.inProgress))
+	}
+}
+
+func TestGroupReady(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	var ready []Event
+	om.Subscribe(func(e Event) {
+		if e.Type == EventGroupReady {
+			ready = append(ready, e)
+		}
+	})
+	place := func(item, group string, notify bool) *queue.Token {
+		t.Helper()
+		tok, err := om.PlaceOrder(ctx, NewOrder{Item: item, Group: group, NotifyGroup: notify})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	soup := place("soup", "t4", true)
+	steak := place("steak", "t4", false)
+	wine := place("wine", "t4", false)
+	tea := place("tea", "t5", false) // Not asked to notify
+
+	if _, err := om.PrepareOrderByID(ctx, soup.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := om.CancelOrder(ctx, wine.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(ready) != 0 {
+		t.Fatalf("group ready with steak to come: %+v", ready)
+	}
+	if _, err := om.PrepareOrderByID(ctx, tea.ID); err != nil || len(ready) != 0 {
+		t.Fatalf("prepare = %v, %d group events", err, len(ready))
+	}
+	if _, err := om.PrepareOrderByID(ctx, steak.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(ready) != 1 {
+		t.Fatalf("%d group events, want 1", len(ready))
+	}
+	e := ready[0]
+	var statuses []string
+	for _, tok := range e.Group {
+		statuses = append(statuses, tok.ID+":"+tok.Status)
+	}
+	if e.Token.ID != steak.ID || fmt.Sprint(statuses) != "[1:prepared 2:prepared 3:cancelled]" {
+		t.Fatalf("event = %s %v", e.Token.ID, statuses)
+	}
+
+	orders, total, err := om.QueryOrders(ctx, OrderFilter{Group: "t4", Limit: DefaultListLimit})
+	if err != nil || total != 3 || len(orders) != 3 {
+		t.Fatalf("group listing = %d orders, %v", total, err)
+	}
+}
+
+func TestGroupPriority(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		policy           string
+		rushed, modified string // Priorities of the rest of the group after each
+	}{
+		{GroupPriorityNone, "[5 3 0]", "[5 1]"},
+		{GroupPriorityRush, "[-1 -1 0]", "[5 1]"},
+		{GroupPriorityInherit, "[-1 -1 0]", "[2 1]"},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.GroupPriority = tt.policy
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			om := New(cfg)
+			place := func(group string, priority int) *queue.Token {
+				t.Helper()
+				tok, err := om.PlaceOrder(ctx, NewOrder{Item: "soup", Priority: priority, Group: group})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return tok
+			}
+			priorities := func(ids ...string) string {
+				t.Helper()
+				var got []int
+				for _, id := range ids {
+					tok, err := om.GetOrder(ctx, id)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got = append(got, tok.Priority)
+				}
+				return fmt.Sprint(got)
+			}
+
+			a, b, c := place("t1", 4), place("t1", 5), place("t1", 3)
+			prepared := place("t1", 0)
+			om.PrepareOrderByID(ctx, prepared.ID)
+			other := place("t2", 5)
+			if _, err := om.RushOrder(ctx, a.ID, "amy"); err != nil {
+				t.Fatal(err)
+			}
+			if got := priorities(b.ID, c.ID, prepared.ID); got != tt.rushed {
+				t.Errorf("after rush = %s, want %s", got, tt.rushed)
+			}
+
+			d, e, f := place("t3", 5), place("t3", 5), place("t3", 1)
+			for _, p := range []int{2, 4} {
+				// Lowering a priority again never spreads
+				if _, err := om.ModifyOrder(ctx, d.ID, OrderChanges{Priority: &p}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := priorities(e.ID, f.ID); got != tt.modified {
+				t.Errorf("after modify = %s, want %s", got, tt.modified)
+			}
+			if got := priorities(other.ID); got != "[5]" {
+				t.Errorf("other group = %s", got)
+			}
+			checkInvariants(t, om)
+		})
+	}
+	if err := (Config{GroupPriority: "table"}).Validate(); err == nil {
+		t.Error("unknown policy accepted")
+	}
+}
+
+func TestSearchOrders(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	for _, o := range []NewOrder{
+		{Item: "Oat latte", Notes: "for Priya", Phone: "+1 (555) 010-4477"},
+		{Item: "Flat white", Notes: "extra hot"},
+		{Item: "Latte", Platform: "eats", ExternalID: "EX-981"},
+	} {
+		if _, err := om.PlaceOrder(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	search := func(f OrderFilter) string {
+		t.Helper()
+		orders, _, err := om.QueryOrders(ctx, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, tok := range orders {
+			ids = append(ids, tok.ID)
+		}
+		return fmt.Sprint(ids)
+	}
+	for text, want := range map[string]string{
+		"latte":       "[1 3]",
+		"LATTE priya": "[1]",
+		"4477":        "[1]",
+		"0104477":     "[1]",
+		"lat":         "[1 3]",
+		"atte":        "[]",
+		"ex-981":      "[3]",
+		"981":         "[3]",
+		"white hot":   "[2]",
+		"tea":         "[]",
+	} {
+		if got := search(OrderFilter{Text: text}); got != want {
+			t.Errorf("search %q = %s, want %s", text, got, want)
+		}
+	}
+	if code := om.byID["2"].PickupCode; search(OrderFilter{Text: code}) != "[]" {
+		t.Errorf("search by pickup code %s found orders", code)
+	}
+
+	// The index follows changes and day closes
+	item := "Chai"
+	if _, err := om.ModifyOrder(ctx, "3", OrderChanges{Item: &item}); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(OrderFilter{Text: "latte"}); got != "[1]" {
+		t.Errorf("latte after changing 3 = %s", got)
+	}
+	if _, err := om.PrepareOrderByID(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(OrderFilter{Text: "latte", Status: queue.StatusPreparing}); got != "[]" {
+		t.Errorf("preparing lattes = %s", got)
+	}
+	if _, err := om.CloseDay(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(OrderFilter{Text: "priya"}); got != "[]" {
+		t.Errorf("priya after the day close = %s", got)
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	ix := newSearchIndex()
+	var tokens []*queue.Token
+	for i := range 5000 {
+		tok := &queue.Token{ID: strconv.Itoa(i + 1), Item: fmt.Sprintf("item%d dish%d", i, i%97), Notes: fmt.Sprintf("note%x", i*7919)}
+		tokens = append(tokens, tok)
+		ix.add(tok)
+	}
+	// Changed and removed orders leave nothing behind
+	for _, tok := range tokens[:100] {
+		tok.Item = "soup soup"
+		ix.add(tok)
+		ix.remove(tok.ID)
+	}
+	if !slices.IsSorted(ix.sorted) || len(ix.sorted) != len(ix.words) {
+		t.Fatalf("%d sorted words for %d words", len(ix.sorted), len(ix.words))
+	}
+	if _, ok := ix.words["soup"]; ok {
+		t.Error("removed order's word still indexed")
+	}
+
+	for _, query := range []string{"item42", "dish9", "dish96 item1", "note1", "soup", "zzz"} {
+		terms := searchTerms(query)
+		found := ix.find(terms)
+		for _, tok := range tokens[100:] {
+			want := true
+			for _, term := range terms {
+				want = want && slices.ContainsFunc(searchWords(tok), func(w string) bool { return strings.HasPrefix(w, term) })
+			}
+			if found[tok.ID] != want {
+				t.Errorf("%q: found %s = %t, want %t", query, tok.ID, found[tok.ID], want)
+			}
+		}
+	}
+}
+
+func TestUnavailableItems(t *testing.T) {
+	ctx := context.Background()
+	for _, block := range []bool{false, true} {
+		t.Run(fmt.Sprintf("block=%t", block), func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.BlockUnavailable = block
+			om := New(cfg)
+			var events []string
+			om.Subscribe(func(e Event) { events = append(events, e.Type+":"+e.Token.ID) })
+			for _, o := range []NewOrder{{Item: "soup", Priority: 1}, {Item: "Salmon", Priority: 2}, {Item: "salmon", Priority: 5}} {
+				if _, err := om.PlaceOrder(ctx, o); err != nil {
+					t.Fatal(err)
+				}
+			}
+			events = nil
+
+			flagged, err := om.MarkUnavailable(ctx, "SALMON ")
+			if err != nil || len(flagged) != 2 {
+				t.Fatalf("MarkUnavailable = %v, %v", flagged, err)
+			}
+			late, _ := om.PlaceOrder(ctx, NewOrder{Item: "salmon", Priority: 0})
+			status := queue.StatusPreparing
+			if block {
+				status = queue.StatusBlocked
+			}
+			for _, id := range []string{"2", "3", late.ID} {
+				tok, _ := om.GetOrder(ctx, id)
+				if !tok.Unavailable || tok.Status != status {
+					t.Errorf("order %s = %s, unavailable %t", id, tok.Status, tok.Unavailable)
+				}
+			}
+			if items := om.UnavailableItems(); fmt.Sprint(items) != "[salmon]" {
+				t.Errorf("items = %v", items)
+			}
+			snap, err := om.Snapshot(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			restored := New(cfg)
+			if err := restored.RestoreSnapshot(snap); err != nil {
+				t.Fatal(err)
+			}
+			if tok, _ := restored.GetOrder(ctx, "3"); tok.Status != status || fmt.Sprint(restored.UnavailableItems()) != "[salmon]" {
+				t.Errorf("restored order 3 = %s, items %v", tok.Status, restored.UnavailableItems())
+			}
+
+			// Blocked orders keep their priority and time and take their
+			// places back
+			if _, err := om.MarkAvailable(ctx, "salmon"); err != nil {
+				t.Fatal(err)
+			}
+			want := "[modified:2 modified:3 created:4 modified:4 modified:2 modified:3]"
+			if block {
+				want = "[blocked:2 blocked:3 created:4 released:2 released:3 released:4]"
+			}
+			if got := fmt.Sprint(events); got != want {
+				t.Errorf("events = %s, want %s", got, want)
+			}
+			var order []string
+			for range 4 {
+				tok, err := om.PrepareOrder(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tok.Unavailable {
+					t.Errorf("order %s still flagged", tok.ID)
+				}
+				order = append(order, tok.ID)
+			}
+			if got := fmt.Sprint(order); got != "[4 1 2 3]" {
+				t.Errorf("prepared %s, want [4 1 2 3]", got)
+			}
+		})
+	}
+}
+
+func TestFireCourse(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	for _, o := range []NewOrder{
+		{Item: "soup", Priority: 5, Group: "t1", Course: 1},
+		{Item: "steak", Priority: 1, Group: "t1", Course: 2},
+		{Item: "cake", Priority: 0, Group: "t1", Course: 3},
+		{Item: "fish", Priority: 3, Group: "t1", Course: 2},
+		{Item: "tea", Priority: 9},
+	} {
+		if _, err := om.PlaceOrder(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, held, _ := om.QueryOrders(ctx, OrderFilter{Status: queue.StatusOnHold}); held != 3 {
+		t.Fatalf("%d orders on hold, want 3", held)
+	}
+	if _, err := om.FireCourse(ctx, "t2"); !errors.Is(err, ErrNothingToFire) {
+		t.Errorf("FireCourse(t2) = %v, want ErrNothingToFire", err)
+	}
+
+	snap, err := om.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	om = New(DefaultConfig())
+	if err := om.RestoreSnapshot(snap); err != nil {
+		t.Fatal(err)
+	}
+	fired, err := om.FireCourse(ctx, "t1")
+	if err != nil || len(fired) != 2 || fired[0].ID != "2" || fired[1].ID != "4" || fired[0].Status != queue.StatusPreparing {
+		t.Fatalf("FireCourse = %v, %v", fired, err)
+	}
+	// Once mains are fired, another main goes straight in but dessert waits
+	late, _ := om.PlaceOrder(ctx, NewOrder{Item: "pasta", Priority: 2, Group: "t1", Course: 2})
+	if late.Status != queue.StatusPreparing {
+		t.Errorf("late main = %s", late.Status)
+	}
+	var order []string
+	for {
+		tok, err := om.PrepareOrder(ctx)
+		if errors.Is(err, ErrQueueEmpty) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		order = append(order, tok.ID)
+	}
+	if got := fmt.Sprint(order); got != "[2 6 4 1 5]" {
+		t.Errorf("prepared %s, want [2 6 4 1 5]", got)
+	}
+	if cake, _ := om.GetOrder(ctx, "3"); cake.Status != queue.StatusOnHold {
+		t.Errorf("dessert = %s, want on hold", cake.Status)
+	}
+	if fired, err := om.FireCourse(ctx, "t1"); err != nil || len(fired) != 1 || fired[0].Course != 3 {
+		t.Errorf("second FireCourse = %v, %v", fired, err)
+	}
+}
+
+func TestPauseOrdering(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	soup := add(t, om, "soup", 1)
+	om.PauseOrdering("kitchen closed")
+
+	_, err := om.PlaceOrder(ctx, NewOrder{Item: "tea", Priority: 1})
+	var paused *PausedError
+	if !errors.As(err, &paused) || !errors.Is(err, ErrPaused) || paused.Reason != "kitchen closed" {
+		t.Fatalf("order while paused = %v", err)
+	}
+	if got := prepare(t, om); got.ID != soup.ID {
+		t.Fatalf("prepared %s while paused, want %s", got.ID, soup.ID)
+	}
+	if err := om.ResumeOrdering(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := om.OrderingPaused(); ok {
+		t.Fatal("still paused after resuming")
+	}
+	if err := om.ResumeOrdering(); err != ErrNotPaused {
+		t.Fatalf("resume twice = %v", err)
+	}
+	add(t, om, "tea", 1)
+}
+
+func TestOpeningHours(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	open := now.Add(2 * time.Hour).Truncate(time.Minute)
+	cfg := DefaultConfig()
+	cfg.Hours = Hours{strings.ToLower(open.Weekday().String()): {open.Format("15:04") + "-" + open.Add(time.Hour).Format("15:04")}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	om := New(cfg)
+
+	_, err := om.PlaceOrder(ctx, NewOrder{Item: "tea", Priority: 1})
+	var closed *ClosedError
+	if !errors.As(err, &closed) || !errors.Is(err, ErrClosed) || !closed.NextOpen.Equal(open) {
+		t.Fatalf("order while closed = %v, want next open %v", err, open)
+	}
+	pre, err := om.PlaceOrder(ctx, NewOrder{Item: "tea", Priority: 1, ReadyAt: open.Add(5 * time.Minute)})
+	if err != nil {
+		t.Fatalf("pre-order for opening: %v", err)
+	}
+	if pre.Status != queue.StatusScheduled || !pre.ReleaseAt.Equal(open) {
+		t.Errorf("pre-order %s released at %v, want scheduled for %v", pre.Status, pre.ReleaseAt, open)
+	}
+	_, err = om.PlaceOrder(ctx, NewOrder{Item: "tea", Priority: 1, ReadyAt: open.Add(2 * time.Hour)})
+	if !errors.As(err, &closed) || !closed.NextOpen.Equal(open.AddDate(0, 0, 7)) {
+		t.Errorf("pre-order after closing = %v", err)
+	}
+	if ok, next := om.NextOpen(now); ok || !next.Equal(open) {
+		t.Errorf("NextOpen = %v %v", ok, next)
+	}
+
+	late, _ := parseHours(Hours{"friday": {"22:00-02:00"}})
+	saturday := time.Date(2024, time.March, 2, 1, 30, 0, 0, time.UTC)
+	if _, end, ok := late.at(saturday); !ok || end.Hour() != 2 {
+		t.Errorf("01:30 after a late Friday: open %v until %v", ok, end)
+	}
+	for _, bad := range []Hours{{"funday": {"09:00-17:00"}}, {"monday": {"09:00"}}, {"monday": {"25:00-26:00"}}} {
+		if _, err := parseHours(bad); err == nil {
+			t.Errorf("parseHours(%v) did not fail", bad)
+		}
+	}
+}
+
+func TestPriorityRules(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cfg := DefaultConfig()
+	cfg.PriorityRules = []PriorityRule{
+		{Name: "late delivery", When: Condition{OrderTypes: []string{queue.OrderDelivery}, Times: []string{now.Add(-time.Hour).Format("15:04") + "-" + now.Add(time.Hour).Format("15:04")}}, Boost: 1},
+		{Name: "kids meal", When: Condition{Items: []string{"Kids Meal"}}, Boost: 2},
+		{Name: "big orders wait", When: Condition{MinQuantity: 10}, Boost: -1},
+		{Name: "weekdays", When: Condition{Days: []string{strings.ToLower(now.AddDate(0, 0, 1).Weekday().String())}}, Boost: 5},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	om := New(cfg)
+
+	kids, err := om.PlaceOrder(ctx, NewOrder{Item: "pasta kids meal", Priority: 3, OrderType: queue.OrderDelivery})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kids.Priority != 0 || len(kids.Rules) != 2 || kids.Rules[0] != (queue.RuleMatch{Rule: "late delivery", From: 3, To: 2}) ||
+		kids.Rules[1] != (queue.RuleMatch{Rule: "kids meal", From: 2, To: 0}) {
+		t.Errorf("kids meal delivery: priority %d after %+v", kids.Priority, kids.Rules)
+	}
+	big, _ := om.PlaceOrder(ctx, NewOrder{Item: "tea", Priority: 1, Quantity: 12})
+	if big.Priority != 2 || len(big.Rules) != 1 {
+		t.Errorf("big order: priority %d after %+v", big.Priority, big.Rules)
+	}
+	plain, _ := om.PlaceOrder(ctx, NewOrder{Item: "tea", Priority: 1, OrderType: queue.OrderTakeaway})
+	if plain.Priority != 1 || plain.Rules != nil {
+		t.Errorf("no rule: priority %d after %+v", plain.Priority, plain.Rules)
+	}
+	if next, _ := om.PrepareOrder(ctx); next.ID != kids.ID {
+		t.Errorf("prepared %s first, want the boosted %s", next.ID, kids.ID)
+	}
+
+	overnight, _ := parseRules([]PriorityRule{{Name: "night", When: Condition{Times: []string{"21:00-02:00"}, Days: []string{"friday"}}, Boost: 1}})
+	for at, want := range map[time.Time]bool{
+		time.Date(2024, time.March, 1, 22, 0, 0, 0, time.UTC): true,  // Friday
+		time.Date(2024, time.March, 2, 1, 30, 0, 0, time.UTC): true,  // Friday night, into Saturday
+		time.Date(2024, time.March, 2, 22, 0, 0, 0, time.UTC): false, // Saturday
+		time.Date(2024, time.March, 1, 20, 0, 0, 0, time.UTC): false,
+	} {
+		if got := overnight[0].placedWithin(at); got != want {
+			t.Errorf("placed at %v: matches %v, want %v", at, got, want)
+		}
+	}
+	for _, bad := range [][]PriorityRule{
+		{{When: Condition{}, Boost: 1}},
+		{{Name: "a", Boost: 1}, {Name: "a", Boost: 2}},
+		{{Name: "a"}},
+		{{Name: "a", Boost: 1, When: Condition{Times: []string{"9pm"}}}},
+		{{Name: "a", Boost: 1, When: Condition{OrderTypes: []string{"drive_thru"}}}},
+	} {
+		if _, err := parseRules(bad); err == nil {
+			t.Errorf("parseRules(%+v) did not fail", bad)
+		}
+	}
+}
+
+func TestSLA(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.SLATargets = map[int]config.Duration{0: config.Duration(10 * time.Minute), 1: config.Duration(20 * time.Minute)}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	om := New(cfg)
+	var breached []string
+	om.Subscribe(func(e Event) {
+		if e.Type == EventBreached {
+			breached = append(breached, e.Token.ID)
+		}
+	})
+
+	vip := add(t, om, "steak", 0)
+	regular := add(t, om, "tea", 1)
+	untracked := add(t, om, "water", 3)
+	if vip.SLA == nil || vip.SLA.Priority != 0 || !vip.SLA.DueAt.Equal(vip.Timestamp.Add(10*time.Minute)) || untracked.SLA != nil {
+		t.Fatalf("targets: vip %+v, untracked %+v", vip.SLA, untracked.SLA)
+	}
+
+	om.tick(ctx, time.Now().Add(5*time.Minute))
+	if len(breached) != 0 {
+		t.Fatalf("breached early: %v", breached)
+	}
+	om.tick(ctx, time.Now().Add(11*time.Minute))
+	if len(breached) != 1 || breached[0] != vip.ID {
+		t.Fatalf("breached %v, want the vip order", breached)
+	}
+	om.tick(ctx, time.Now().Add(12*time.Minute))
+	if len(breached) != 1 {
+		t.Errorf("breach reported again: %v", breached)
+	}
+	late, _ := om.PrepareOrderByID(ctx, vip.ID)
+	met, _ := om.PrepareOrderByID(ctx, regular.ID)
+	if late.SLA.BreachedAt == nil || late.SLA.Met(late) || !met.SLA.Met(met) {
+		t.Errorf("vip %+v, regular %+v", late.SLA, met.SLA)
+	}
+	om.tick(ctx, time.Now().Add(time.Hour))
+	if len(breached) != 1 {
+		t.Errorf("prepared order breached: %v", breached)
+	}
+
+	bad := DefaultConfig()
+	bad.SLATargets = map[int]config.Duration{0: 0}
+	if err := bad.Validate(); err == nil {
+		t.Error("zero target accepted")
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	cake := add(t, om, "cake", 1)
+	got, err := om.AddAttachment(ctx, cake.ID, queue.Attachment{ID: "a1", Name: "design.png"})
+	if err != nil || len(got.Attachments) != 1 || got.Version == cake.Version {
+		t.Fatalf("add = %+v, %v", got, err)
+	}
+	if last := got.Edits[len(got.Edits)-1]; last.Field != "attachments" || last.To != "design.png" {
+		t.Errorf("edit = %+v", last)
+	}
+	if _, err := om.RemoveAttachment(ctx, cake.ID, "missing"); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Errorf("remove missing = %v", err)
+	}
+	got, err = om.RemoveAttachment(ctx, cake.ID, "a1")
+	if err != nil || len(got.Attachments) != 0 {
+		t.Fatalf("remove = %+v, %v", got, err)
+	}
+
+	if _, err := om.CancelOrder(ctx, cake.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := om.AddAttachment(ctx, cake.ID, queue.Attachment{ID: "a2"}); !errors.Is(err, ErrNotModifiable) {
+		t.Errorf("attach to a cancelled order = %v", err)
+	}
+}
+
+func TestVoidOrder(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	tea := add(t, om, "tea", 1)
+	soup := add(t, om, "soup", 1)
+	wrong := VoidRequest{Reason: "wrong_order", By: "maria"}
+	if _, err := om.VoidOrder(ctx, tea.ID, wrong); !errors.Is(err, ErrNotVoidable) {
+		t.Fatalf("void of a waiting order = %v", err)
+	}
+	om.PrepareOrderByID(ctx, tea.ID)
+	om.PickUpOrder(ctx, tea.ID, tea.PickupCode)
+	om.CancelOrder(ctx, soup.ID, "")
+
+	if _, err := om.VoidOrder(ctx, tea.ID, VoidRequest{Reason: "bored"}); !errors.Is(err, ErrVoidReason) {
+		t.Errorf("unknown reason = %v", err)
+	}
+	if _, err := om.VoidOrder(ctx, tea.ID, VoidRequest{Reason: "quality", Refund: true}); !errors.Is(err, ErrPaymentTransition) {
+		t.Errorf("refund of an unpaid order = %v", err)
+	}
+	om.SetPayment(ctx, tea.ID, queue.PaymentPaid, "")
+	got, err := om.VoidOrder(ctx, tea.ID, VoidRequest{Reason: "quality", Note: "cold", By: "maria", Refund: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != queue.StatusVoided || got.Payment != queue.PaymentRefunded || got.Void == nil ||
+		got.Void.From != queue.StatusPickedUp || got.Void.By != "maria" || got.Void.Reason != "quality" {
+		t.Fatalf("voided = %+v, void %+v", got, got.Void)
+	}
+	if _, err := om.VoidOrder(ctx, tea.ID, wrong); !errors.Is(err, ErrNotVoidable) {
+		t.Errorf("void twice = %v", err)
+	}
+	cancelled, err := om.VoidOrder(ctx, soup.ID, wrong)
+	if err != nil || cancelled.Void.From != queue.StatusCancelled || cancelled.Void.Refund {
+		t.Errorf("void of a cancelled order = %+v, %v", cancelled, err)
+	}
+	if _, err := om.RecoverOrder(ctx, soup.ID); err == nil {
+		t.Error("voided order recovered")
+	}
+	if _, err := om.AddAttachment(ctx, soup.ID, queue.Attachment{ID: "a1"}); !errors.Is(err, ErrNotModifiable) {
+		t.Errorf("attach to a voided order = %v", err)
+	}
+
+	day, err := om.CloseDay(ctx)
+	if err != nil || len(day.Orders) != 2 {
+		t.Fatalf("day close = %+v, %v", day, err)
+	}
+}
+
+// failingQueue refuses pushes while fail is set
+type failingQueue struct {
+	*MemoryQueue
+	fail bool
+}
+
+func (q *failingQueue) Push(ctx context.Context, t *queue.Token) error {
+	if q.fail {
+		return errors.New("queue unavailable")
+	}
+	return q.MemoryQueue.Push(ctx, t)
+}
+
+func TestFailedEnqueueKeepsNumber(t *testing.T) {
+	ctx := context.Background()
+	q := &failingQueue{MemoryQueue: NewMemoryQueue()}
+	om := New(DefaultConfig(), WithQueue(q))
+	burger := place(t, om, NewOrder{Item: "burger"})
+	if _, err := om.PrepareOrder(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	q.fail = true
+	if _, err := om.PlaceOrder(ctx, NewOrder{Item: "tea"}); err == nil {
+		t.Fatal("placed an order the queue refused")
+	}
+	if _, err := om.RefireOrder(ctx, burger.ID, RefireRequest{Reason: "cold"}); err == nil {
+		t.Fatal("refired an order the queue refused")
+	}
+	q.fail = false
+	if tea := place(t, om, NewOrder{Item: "tea"}); tea.Number != burger.Number+1 {
+		t.Errorf("number after failed enqueues = %d, want %d", tea.Number, burger.Number+1)
+	}
+}
+
+func TestRefireOrder(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.MaxRushesPerHour = 1
+	om := New(cfg)
+	burger, err := om.PlaceOrder(ctx, NewOrder{Item: "burger", Priority: 2, Quantity: 2, Notes: "no onion", Phone: "+15550100", Payment: queue.PaymentPaid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cold := RefireRequest{Reason: "cold", By: "maria"}
+	if _, err := om.RefireOrder(ctx, burger.ID, cold); !errors.Is(err, ErrNotRefirable) {
+		t.Fatalf("refire of a waiting order = %v", err)
+	}
+	om.PrepareOrderByID(ctx, burger.ID)
+	if _, err := om.RefireOrder(ctx, burger.ID, RefireRequest{Reason: "bored"}); !errors.Is(err, ErrRemakeReason) {
+		t.Errorf("unknown reason = %v", err)
+	}
+	tea := add(t, om, "tea", 1)
+	om.PauseOrdering("short staffed")
+
+	remake, err := om.RefireOrder(ctx, burger.ID, cold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remake.ID == burger.ID || remake.RemakeOf != burger.ID || remake.RemakeReason != "cold" ||
+		remake.Priority != queue.RushPriority || remake.Status != queue.StatusPreparing || remake.Quantity != 2 ||
+		remake.Notes != "no onion" || remake.Phone != burger.Phone || remake.Payment != queue.PaymentPaid || remake.Number != tea.Number+1 {
+		t.Fatalf("remake = %+v", remake)
+	}
+	next, err := om.PrepareOrder(ctx)
+	if err != nil || next.ID != remake.ID {
+		t.Fatalf("prepared %+v, %v; want the remake first", next, err)
+	}
+	if _, err := om.RushOrder(ctx, tea.ID, "maria"); err != nil {
+		t.Errorf("remake counted towards the rush limit: %v", err)
+	}
+
+	again, err := om.RefireOrder(ctx, burger.ID, RefireRequest{Reason: "wrong_order", Notes: "no onion, no pickle", By: "maria"})
+	if err != nil || again.Notes != "no onion, no pickle" {
+		t.Fatalf("second remake = %+v, %v", again, err)
+	}
+	got, _ := om.GetOrder(ctx, burger.ID)
+	if !slices.Equal(got.Remakes, []string{remake.ID, again.ID}) || got.Status != queue.StatusPrepared {
+		t.Errorf("original = %+v", got)
+	}
+	if last := got.Edits[len(got.Edits)-1]; last.Field != "remakes" || last.To != again.ID || last.By != "maria" {
+		t.Errorf("edit = %+v", last)
+	}
+}
+
+func TestPauseStation(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	var events []Event
+	om.Subscribe(func(e Event) { events = append(events, e) })
+	chips, err := om.PlaceOrder(ctx, NewOrder{Item: "chips", Priority: 0, Station: "fryer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	salad := add(t, om, "salad", 1)
+
+	p, affected, err := om.PauseStation(ctx, "fryer", "fryer broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Station != "fryer" || p.Reason != "fryer broken" || len(affected) != 1 || affected[0].ID != chips.ID {
+		t.Fatalf("pause = %+v, affected %+v", p, affected)
+	}
+	if last := events[len(events)-1]; last.Type != EventStationPaused || last.Token.ID != chips.ID || last.Token.EstimatedReadyAt != nil {
+		t.Errorf("event = %+v", last)
+	}
+	if again, _, _ := om.PauseStation(ctx, "fryer", "still broken"); !again.Since.Equal(p.Since) || again.Reason != "still broken" {
+		t.Errorf("pausing again = %+v", again)
+	}
+	if got := om.PausedStations(); len(got) != 1 || got[0].Station != "fryer" {
+		t.Errorf("paused stations = %+v", got)
+	}
+	if got, _ := om.GetOrder(ctx, chips.ID); got.Status != queue.StatusPreparing || got.EstimatedReadyAt != nil {
+		t.Errorf("paused order = %+v", got)
+	}
+	if got, _ := om.GetOrder(ctx, salad.ID); got.EstimatedReadyAt == nil {
+		t.Error("order at another station lost its estimate")
+	}
+	if _, err := om.PrepareStationOrder(ctx, "fryer"); !errors.Is(err, ErrStationPaused) {
+		t.Errorf("prepare at the paused station = %v", err)
+	}
+	if _, err := om.ClaimStationOrder(ctx, "fryer"); !errors.Is(err, ErrStationPaused) {
+		t.Errorf("claim at the paused station = %v", err)
+	}
+	next, err := om.PrepareOrder(ctx)
+	if err != nil || next.ID != salad.ID {
+		t.Fatalf("prepared %+v, %v; want the salad, passing over the fryer", next, err)
+	}
+	if _, err := om.ClaimOrder(ctx); !errors.Is(err, ErrQueueEmpty) {
+		t.Errorf("claim with only the paused station waiting = %v", err)
+	}
+
+	affected, err = om.ResumeStation(ctx, "fryer")
+	if err != nil || len(affected) != 1 {
+		t.Fatalf("resume = %+v, %v", affected, err)
+	}
+	if last := events[len(events)-1]; last.Type != EventStationResumed || last.Token.EstimatedReadyAt == nil {
+		t.Errorf("event = %+v", last)
+	}
+	if _, err := om.ResumeStation(ctx, "fryer"); !errors.Is(err, ErrStationRunning) {
+		t.Errorf("resume again = %v", err)
+	}
+	if next, err := om.PrepareOrder(ctx); err != nil || next.ID != chips.ID {
+		t.Errorf("prepared %+v, %v after resuming", next, err)
+	}
+}
+
+func TestForceStatus(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	var events []Event
+	om.Subscribe(func(e Event) { events = append(events, e) })
+	soup := add(t, om, "soup", 1)
+	stew := add(t, om, "stew", 2)
+
+	if _, _, err := om.ForceStatus(ctx, soup.ID, queue.StatusWaitlisted); !errors.Is(err, ErrForceStatus) {
+		t.Errorf("force to waitlisted = %v", err)
+	}
+	if _, _, err := om.ForceStatus(ctx, soup.ID, queue.StatusPreparing); !errors.Is(err, ErrSameStatus) {
+		t.Errorf("force to the same status = %v", err)
+	}
+	got, from, err := om.ForceStatus(ctx, soup.ID, queue.StatusPickedUp)
+	if err != nil || from != queue.StatusPreparing || got.Status != queue.StatusPickedUp || got.PickedUpAt == nil {
+		t.Fatalf("force to picked up = %+v, %q, %v", got, from, err)
+	}
+	if last := events[len(events)-1]; last.Type != EventForced || last.Token.ID != soup.ID {
+		t.Errorf("event = %+v", last)
+	}
+	if next, _ := om.PrepareOrder(ctx); next.ID != stew.ID {
+		t.Errorf("prepared %s, want the stew as the soup left the queue", next.ID)
+	}
+
+	got, _, err = om.ForceStatus(ctx, soup.ID, queue.StatusPreparing)
+	if err != nil || got.PickedUpAt != nil || got.PreparedAt != nil {
+		t.Fatalf("force back to preparing = %+v, %v", got, err)
+	}
+	if next, _ := om.PrepareOrder(ctx); next.ID != soup.ID {
+		t.Errorf("prepared %s, want the soup back in the queue", next.ID)
+	}
+	if got, _, err = om.ForceStatus(ctx, soup.ID, queue.StatusCancelled); err != nil || got.CancelledAt == nil {
+		t.Fatalf("force to cancelled = %+v, %v", got, err)
+	}
+	if _, _, err = om.ForceStatus(ctx, stew.ID, queue.StatusPreparing); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err = om.ForceStatus(ctx, stew.ID, queue.StatusPrepared); err != nil || got.PreparedAt == nil {
+		t.Fatalf("force to prepared = %+v, %v", got, err)
+	}
+	if _, err := om.PickUpOrder(ctx, stew.ID, stew.PickupCode); err != nil {
+		t.Errorf("pick up a forced order: %v", err)
+	}
+}
+
+func TestSetNextNumber(t *testing.T) {
+	om := New(DefaultConfig())
+	add(t, om, "soup", 1)
+	if was := om.SetNextNumber(40); was != 2 {
+		t.Errorf("next number was %d, want 2", was)
+	}
+	if got := add(t, om, "stew", 1); got.Number != 40 || om.NextNumber() != 41 {
+		t.Errorf("number = %d, next %d", got.Number, om.NextNumber())
+	}
+}
+
+func TestRebuildQueue(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	soup := add(t, om, "soup", 1)
+	stew := add(t, om, "stew", 2)
+	tea := add(t, om, "tea", 3)
+
+	// Drift the heap from the orders: lose the soup, and keep the tea
+	// after it has been picked up
+	mq := om.waiting.(*MemoryQueue)
+	mq.Remove(ctx, soup.ID)
+	om.byID[tea.ID].Status = queue.StatusPickedUp
+
+	rb, err := om.RebuildQueue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rb.Queued != 2 || !slices.Equal(rb.Restored, []string{soup.ID}) || !slices.Equal(rb.Dropped, []string{tea.ID}) {
+		t.Fatalf("rebuild = %+v", rb)
+	}
+	if err := om.waiting.(*MemoryQueue).Verify(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{soup.ID, stew.ID} {
+		if next, err := om.PrepareOrder(ctx); err != nil || next.ID != want {
+			t.Errorf("prepared %+v, %v; want %s", next, err, want)
+		}
+	}
+	if rb, _ = om.RebuildQueue(ctx); rb.Queued != 0 || len(rb.Restored)+len(rb.Dropped) != 0 {
+		t.Errorf("rebuild of a sound queue = %+v", rb)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.ItemPrepTimes = map[string]config.Duration{"pizza": config.Duration(10 * time.Minute)}
+	cfg.ItemStations = map[string][]string{"pizza": {"oven1", "oven2"}}
+	cfg.StationCooks = map[string]int{"oven2": 2}
+	om := New(cfg)
+	var events []Event
+	om.Subscribe(func(e Event) { events = append(events, e) })
+
+	// oven2 has two cooks, so it takes two pizzas before oven1 is sooner
+	var got []string
+	for range 4 {
+		got = append(got, add(t, om, "pizza", 1).Station)
+	}
+	if want := []string{"oven1", "oven2", "oven2", "oven1"}; !slices.Equal(got, want) {
+		t.Errorf("stations = %v, want %v", got, want)
+	}
+	if s := place(t, om, NewOrder{Item: "pizza", Priority: 1, Station: "oven1"}).Station; s != "oven1" {
+		t.Errorf("order asking for oven1 went to %q", s)
+	}
+	if s := add(t, om, "salad", 1).Station; s != "" {
+		t.Errorf("salad went to %q", s)
+	}
+
+	_, left, err := om.PauseStation(ctx, "oven1", "door broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 0 {
+		t.Errorf("left at oven1 = %+v", left)
+	}
+	moved := 0
+	for _, e := range events {
+		if e.Type == EventReassigned {
+			moved++
+			if e.Token.Station != "oven2" {
+				t.Errorf("reassigned to %q", e.Token.Station)
+			}
+		}
+	}
+	if moved != 3 {
+		t.Errorf("%d orders reassigned, want 3", moved)
+	}
+	if s := add(t, om, "pizza", 1).Station; s != "oven2" {
+		t.Errorf("order while oven1 is paused went to %q", s)
+	}
+
+	cfg.ItemStations = map[string][]string{"pizza": {"oven1", "oven1"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("station listed twice accepted")
+	}
+}
+
+func TestEscalation(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	cfg := DefaultConfig()
+	cfg.EscalationWindow = config.Duration(30 * time.Minute)
+	om := New(cfg, WithClock(fake))
+	var events []string
+	om.Subscribe(func(e Event) { events = append(events, e.Type+":"+e.Token.Item) })
+
+	soup := place(t, om, NewOrder{Item: "soup", Priority: 6, PromisedBy: start.Add(time.Hour)})
+	add(t, om, "stew", 5)
+	if soup.PromisedBy == nil || !soup.PromisedBy.Equal(start.Add(time.Hour)) {
+		t.Fatalf("promised by = %v", soup.PromisedBy)
+	}
+	events = nil
+	priority := func() int {
+		t.Helper()
+		got, err := om.GetOrder(ctx, soup.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.Priority
+	}
+
+	om.tick(ctx, start.Add(30*time.Minute))
+	if p := priority(); p != 6 || len(events) != 0 {
+		t.Errorf("at the start of the window priority = %d, events %v", p, events)
+	}
+	om.tick(ctx, start.Add(45*time.Minute))
+	if p := priority(); p != 3 || !slices.Equal(events, []string{"escalated:soup"}) {
+		t.Errorf("half way through the window priority = %d, events %v", p, events)
+	}
+	om.tick(ctx, start.Add(50*time.Minute))
+	if p := priority(); p != 2 {
+		t.Errorf("with 10 minutes left priority = %d", p)
+	}
+	events = nil
+	fake.Set(start.Add(61 * time.Minute))
+	om.tick(ctx, fake.Now())
+	got, _ := om.GetOrder(ctx, soup.ID)
+	if got.Priority != 0 || !got.AtRisk || !slices.Equal(events, []string{"escalated:soup", "at_risk:soup"}) {
+		t.Errorf("past the promised time = %+v, events %v", got, events)
+	}
+	if e := got.Edits[len(got.Edits)-1]; e.By != escalator || e.From != "2" || e.To != "0" {
+		t.Errorf("edit = %+v", e)
+	}
+	if next, _ := om.PrepareOrder(ctx); next.ID != soup.ID {
+		t.Errorf("prepared %s first, want the soup", next.Item)
+	}
+	om.tick(ctx, fake.Now())
+	if len(om.promised) != 0 {
+		t.Errorf("still watching %v once prepared", om.promised)
+	}
+}
+
+func TestAtRisk(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	om := New(DefaultConfig(), WithClock(clock.NewFake(start)))
+	for range 3 {
+		add(t, om, "stew", 1)
+	}
+	// Three stews of three minutes each go first
+	late := place(t, om, NewOrder{Item: "soup", Priority: 5, PromisedBy: start.Add(5 * time.Minute)})
+	fine := place(t, om, NewOrder{Item: "tea", Priority: 1, PromisedBy: start.Add(time.Hour)})
+	var events []Event
+	om.Subscribe(func(e Event) { events = append(events, e) })
+
+	om.tick(ctx, start)
+	if len(events) != 1 || events[0].Type != EventAtRisk || events[0].Token.ID != late.ID {
+		t.Fatalf("events = %+v", events)
+	}
+	if got, _ := om.GetOrder(ctx, fine.ID); got.AtRisk || got.Priority != 1 {
+		t.Errorf("order with time to spare = %+v", got)
+	}
+	om.tick(ctx, start)
+	if len(events) != 1 {
+		t.Errorf("at risk announced again: %+v", events[1:])
+	}
+}
+
+func TestQueueChecks(t *testing.T) {
+	var logged strings.Builder
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	ctx := context.Background()
+
+	cfg := DefaultConfig()
+	cfg.CheckQueue = true
+	om := New(cfg)
+	soup := add(t, om, "soup", 1)
+	add(t, om, "stew", 2)
+	// Corrupt the heap behind the manager's back: the soup is now last
+	om.byID[soup.ID].Priority = 9
+	add(t, om, "tea", 3)
+	if !strings.Contains(logged.String(), "queue check after PlaceOrder: token") {
+		t.Errorf("log = %q", logged.String())
+	}
+	if err := om.waiting.(*MemoryQueue).Verify(); err == nil {
+		t.Error("the queue was repaired without RepairQueue")
+	}
+
+	logged.Reset()
+	cfg.RepairQueue = true
+	om = New(cfg)
+	soup = add(t, om, "soup", 1)
+	stew := add(t, om, "stew", 2)
+	om.byID[soup.ID].Priority = 9
+	tea := add(t, om, "tea", 3)
+	if !strings.Contains(logged.String(), "queue repair: rebuilt with 3 orders") {
+		t.Errorf("log = %q", logged.String())
+	}
+	for _, want := range []string{stew.ID, tea.ID, soup.ID} {
+		if next, err := om.PrepareOrder(ctx); err != nil || next.ID != want {
+			t.Errorf("prepared %+v, %v; want %s", next, err, want)
+		}
+	}
+
+	// Without CheckQueue the background work finds it
+	logged.Reset()
+	cfg.CheckQueue = false
+	om = New(cfg)
+	soup = add(t, om, "soup", 1)
+	add(t, om, "stew", 2)
+	mq := om.waiting.(*MemoryQueue)
+	delete(mq.byID, soup.ID)
+	om.tick(ctx, time.Now())
+	if !strings.Contains(logged.String(), "queue check after periodic check: 2 tokens in the heap but 1 in the ID index") {
+		t.Errorf("log = %q", logged.String())
+	}
+	if err := om.waiting.(*MemoryQueue).Verify(); err != nil {
+		t.Errorf("after repair: %v", err)
+	}
+}
+
+func TestOrderTotals(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.Currency = "USD"
+	cfg.ItemPrices = map[string]float64{"latte": 4.5, "cake": 3.29}
+	cfg.CurrencyPrices = map[string]map[string]float64{"JPY": {"latte": 650}}
+	cfg.Tax = TaxConfig{Rate: 8.875, ItemRates: map[string]float64{"cake": 0}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	om := New(cfg)
+
+	latte, err := om.PlaceOrder(ctx, NewOrder{Item: "latte", Priority: 1, Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := queue.Total{Currency: "USD", Subtotal: 900, Tax: 80, Total: 980, TaxRate: 8.875}
+	if latte.Total == nil || *latte.Total != want {
+		t.Errorf("latte total = %+v, want %+v", latte.Total, want)
+	}
+	cake, _ := om.PlaceOrder(ctx, NewOrder{Item: "cake", Priority: 1})
+	if cake.Total == nil || cake.Total.Total != 329 || cake.Total.Tax != 0 {
+		t.Errorf("cake total = %+v", cake.Total)
+	}
+	if soup, _ := om.PlaceOrder(ctx, NewOrder{Item: "soup", Priority: 1}); soup.Total != nil {
+		t.Errorf("unpriced item has total %+v", soup.Total)
+	}
+
+	yen, err := om.PlaceOrder(ctx, NewOrder{Item: "latte", Priority: 1, Currency: "JPY"})
+	if err != nil || yen.Total == nil || yen.Total.Currency != "JPY" || yen.Total.Subtotal != 650 || yen.Total.Tax != 58 {
+		t.Errorf("latte in yen = %+v, %v", yen, err)
+	}
+	qty := 3
+	if yen, err = om.ModifyOrder(ctx, yen.ID, OrderChanges{Quantity: &qty}); err != nil || yen.Total.Subtotal != 1950 {
+		t.Errorf("after changing the quantity = %+v, %v", yen.Total, err)
+	}
+	if _, err := om.PlaceOrder(ctx, NewOrder{Item: "latte", Priority: 1, Currency: "EUR"}); !errors.Is(err, ErrCurrency) {
+		t.Errorf("order in an unpriced currency = %v", err)
+	}
+
+	// VAT-style prices hold the tax
+	cfg.Tax = TaxConfig{Rate: 20, Included: true}
+	om = New(cfg)
+	latte, _ = om.PlaceOrder(ctx, NewOrder{Item: "latte", Priority: 1})
+	want = queue.Total{Currency: "USD", Subtotal: 450, Tax: 75, Total: 450, TaxRate: 20, TaxIncluded: true}
+	if latte.Total == nil || *latte.Total != want {
+		t.Errorf("tax-inclusive total = %+v, want %+v", latte.Total, want)
+	}
+
+	cfg.Rounding = "nearest"
+	if err := cfg.Validate(); err == nil {
+		t.Error("unknown rounding accepted")
+	}
+}
+
+func TestQuota(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.Quota = QuotaConfig{PerPhone: 2, PerClient: 3, ExemptClients: []string{"10.0.0.9"}}
+	om := New(cfg)
+	for i := 0; i < 2; i++ {
+		place(t, om, NewOrder{Item: "tea", Priority: 1, Phone: "+15550100", Client: "203.0.113.7"})
+	}
+	_, err := om.PlaceOrder(ctx, NewOrder{Item: "cake", Priority: 1, Phone: "+15550100"})
+	var quota *QuotaError
+	if !errors.As(err, &quota) || !errors.Is(err, ErrQuota) || quota.By != "phone" || quota.Limit != 2 {
+		t.Fatalf("third order for the phone: err = %v", err)
+	}
+	place(t, om, NewOrder{Item: "cake", Priority: 1, Phone: "+15550199", Client: "203.0.113.7"})
+	if _, err := om.PlaceOrder(ctx, NewOrder{Item: "pie", Priority: 1, Client: "203.0.113.7"}); !errors.As(err, &quota) || quota.By != "client" {
+		t.Fatalf("fourth order from the client: err = %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		place(t, om, NewOrder{Item: "soup", Priority: 1, Client: "10.0.0.9"})
+	}
+
+	// Prepared orders no longer count
+	if _, err := om.PrepareOrder(ctx); err != nil {
+		t.Fatal(err)
+	}
+	place(t, om, NewOrder{Item: "pie", Priority: 1, Phone: "+15550100"})
+	checkInvariants(t, om)
+
+	cfg.Quota.PerDevice = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("negative quota accepted")
+	}
+}
+
+func TestTieBreak(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	order := func(om *OrderManager) []int {
+		var quantities []int
+		for {
+			tok, err := om.PrepareOrder(ctx)
+			if errors.Is(err, ErrQueueEmpty) {
+				return quantities
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			quantities = append(quantities, tok.Quantity)
+		}
+	}
+	// Every order lands on the same instant of the stopped clock
+	batch := []int{3, 1, 4, 1, 5, 2}
+	for _, tt := range []struct {
+		policy string
+		want   []int
+	}{
+		{"", batch},
+		{TieBreakSequence, batch},
+		{TieBreakSmallest, []int{1, 1, 2, 3, 4, 5}},
+	} {
+		cfg := DefaultConfig()
+		cfg.TieBreak = tt.policy
+		om := New(cfg, WithClock(clock.NewFake(start)))
+		for _, q := range batch {
+			place(t, om, NewOrder{Item: "tea", Priority: 1, Quantity: q})
+		}
+		if got := order(om); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: prepared %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+
+	// Smallest first follows changes to the quantity
+	cfg := DefaultConfig()
+	cfg.TieBreak = TieBreakSmallest
+	om := New(cfg, WithClock(clock.NewFake(start)))
+	first := place(t, om, NewOrder{Item: "tea", Priority: 1, Quantity: 1})
+	place(t, om, NewOrder{Item: "tea", Priority: 1, Quantity: 2})
+	qty := 6
+	if _, err := om.ModifyOrder(ctx, first.ID, OrderChanges{Quantity: &qty}); err != nil {
+		t.Fatal(err)
+	}
+	if got := order(om); !slices.Equal(got, []int{2, 6}) {
+		t.Errorf("after growing the first order: prepared %v", got)
+	}
+
+	// A random draw keeps every order, and the same draw across a restore
+	cfg.TieBreak = TieBreakRandom
+	om = New(cfg, WithClock(clock.NewFake(start)))
+	for _, q := range batch {
+		place(t, om, NewOrder{Item: "tea", Priority: 1, Quantity: q})
+	}
+	snap, err := om.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := New(cfg, WithClock(clock.NewFake(start)))
+	if err := restored.RestoreSnapshot(snap); err != nil {
+		t.Fatal(err)
+	}
+	got := order(om)
+	if again := order(restored); !slices.Equal(got, again) {
+		t.Errorf("restored order %v, want %v", again, got)
+	}
+	sorted := slices.Sorted(slices.Values(got))
+	if !slices.Equal(sorted, []int{1, 1, 2, 3, 4, 5}) {
+		t.Errorf("random draw prepared %v", got)
+	}
+	if next := place(t, restored, NewOrder{Item: "tea", Priority: 1}); next.Seq != uint64(len(batch)+1) {
+		t.Errorf("sequence after restore = %d", next.Seq)
+	}
+
+	cfg.TieBreak = "coin"
+	if err := cfg.Validate(); err == nil {
+		t.Error("unknown tie-break accepted")
+	}
+}
+
+func TestForecast(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	cfg := DefaultConfig()
+	cfg.ItemPrepTimes = map[string]config.Duration{"soup": config.Duration(5 * time.Minute), "tea": config.Duration(time.Minute)}
+	cfg.StationCooks = map[string]int{"bar": 2}
+	om := New(cfg, WithClock(fake))
+
+	// The soup took ten minutes from claim, double its configured time
+	soup := add(t, om, "soup", 1)
+	if _, err := om.ClaimOrderByID(ctx, soup.ID); err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(10 * time.Minute)
+	if _, err := om.PrepareOrderByID(ctx, soup.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []string{"soup", "soup", "soup", "tea"} {
+		add(t, om, item, 1)
+	}
+	f, err := om.Forecast(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Stations) != 2 || f.Stations[0].Station != "" || f.Stations[1].Station != "bar" || f.MaxWaitSeconds != 1200 {
+		t.Fatalf("forecast = %+v", f)
+	}
+	now := start.Add(10 * time.Minute)
+	sf := f.Stations[0]
+	if sf.Waiting != 4 || sf.Cooks != 1 || sf.AvgPrepSeconds != 465 || sf.ArrivalsPerHour != 5 {
+		t.Errorf("station = %+v", sf)
+	}
+	if sf.ClearAt == nil || !sf.ClearAt.Equal(now.Add(31*time.Minute)) || sf.WaitSeconds != 465+31*60 {
+		t.Errorf("clear at %v, wait %v", sf.ClearAt, sf.WaitSeconds)
+	}
+	if sf.CooksNeeded != 3 || sf.CapacityNeededAt == nil || !sf.CapacityNeededAt.Equal(now) {
+		t.Errorf("over the wait: cooks %d, needed at %v", sf.CooksNeeded, sf.CapacityNeededAt)
+	}
+	if bar := f.Stations[1]; bar.Cooks != 2 || bar.ClearAt != nil || bar.CapacityNeededAt != nil || bar.CooksNeeded != 1 {
+		t.Errorf("idle station = %+v", bar)
+	}
+
+	// Arrivals beyond capacity push the wait past the maximum in time
+	cfg = DefaultConfig()
+	cfg.Forecast.MaxWait = config.Duration(2 * time.Hour)
+	om = New(cfg, WithClock(clock.NewFake(start)))
+	for range 25 {
+		add(t, om, "stew", 1)
+	}
+	for range 15 {
+		if _, err := om.PrepareOrder(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f, err = om.Forecast(ctx); err != nil {
+		t.Fatal(err)
+	}
+	sf = f.Stations[0]
+	if sf.Utilization != 1.25 || sf.WaitSeconds != 33*60 || sf.CooksNeeded != 2 {
+		t.Errorf("station = %+v", sf)
+	}
+	if sf.CapacityNeededAt == nil || !sf.CapacityNeededAt.Equal(start.Add(348*time.Minute)) {
+		t.Errorf("capacity needed at %v", sf.CapacityNeededAt)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	var events []string
+	om.Subscribe(func(e Event) { events = append(events, e.Type+":"+e.Token.ID) })
+	waiting := place(t, om, NewOrder{Item: "cake", Phone: "+15550100", Notes: "for Sam"})
+	done := place(t, om, NewOrder{Item: "tea", Phone: "+15550100", Client: "10.0.0.9"})
+	other := place(t, om, NewOrder{Item: "tea", Phone: "+15550199"})
+	if _, err := om.PrepareOrderByID(ctx, done.ID); err != nil {
+		t.Fatal(err)
+	}
+	events = nil
+
+	ids, err := om.Purge(ctx, func(t *queue.Token) bool { return t.OrderedBy("+15550100") })
+	if err != nil || !slices.Equal(ids, slices.Sorted(slices.Values([]string{waiting.ID, done.ID}))) || len(events) != 2 {
+		t.Fatalf("purge = %v, %v, events %v", ids, err, events)
+	}
+	for _, id := range ids {
+		got, _ := om.GetOrder(ctx, id)
+		if got.Phone != "" || got.Notes != "" || got.Client != "" {
+			t.Errorf("after purge = %+v", got)
+		}
+	}
+	if got, _ := om.GetOrder(ctx, other.ID); got.Phone != "+15550199" {
+		t.Errorf("another customer's order = %+v", got)
+	}
+	if found, _, _ := om.QueryOrders(ctx, OrderFilter{Text: "Sam"}); len(found) != 0 {
+		t.Errorf("search still finds %v", found)
+	}
+	if ids, _ := om.Purge(ctx, func(t *queue.Token) bool { return t.OrderedBy("+15550100") }); len(ids) != 0 {
+		t.Errorf("purged again = %v", ids)
+	}
+}
+
+func TestOrderChannel(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	for _, o := range []NewOrder{
+		{Item: "Latte", Channel: queue.ChannelKiosk},
+		{Item: "Mocha", Platform: "eats", ExternalID: "EX-1"},
+		{Item: "Tea"},
+	} {
+		if _, err := om.PlaceOrder(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	orders, total, err := om.QueryOrders(ctx, OrderFilter{Channel: queue.ChannelDelivery})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || orders[0].Item != "Mocha" {
+		t.Errorf("delivery orders = %v", orders)
+	}
+	if tok, _ := om.GetOrder(ctx, "3"); tok.Channel != "" {
+		t.Errorf("channel of an order placed without one = %q", tok.Channel)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, code := range Codes() {
+		if code == "" || seen[code] {
+			t.Errorf("code %q is empty or repeated", code)
+		}
+		seen[code] = true
+	}
+	for err, want := range map[error]string{
+		ErrOrderNotFound:                         "order_not_found",
+		fmt.Errorf("prepare: %w", ErrNotWaiting): "not_waiting",
+		&CapacityError{Station: "grill"}:         "queue_full",
+		&VersionError{Current: &queue.Token{}}:   "stale_version",
+		errors.New("disk full"):                  "",
+		nil:                                      "",
+	} {
+		if got := Code(err); got != want {
+			t.Errorf("Code(%v) = %q, want %q", err, got, want)
+		}
+	}
+}
+
+func TestImportOrders(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	var events []string
+	om.Subscribe(func(e Event) { events = append(events, e.Type) })
+	ordered := time.Date(2024, time.February, 1, 12, 0, 0, 0, time.UTC)
+	prepared, closed := ordered.Add(5*time.Minute), ordered.Add(9*time.Minute)
+	bad := HistoricalOrder{Item: "tea", Status: queue.StatusPreparing, OrderedAt: ordered, ClosedAt: closed}
+	good := HistoricalOrder{SourceID: "A-17", Item: "soup", Quantity: 2, Status: queue.StatusPickedUp,
+		OrderedAt: ordered, PreparedAt: prepared, ClosedAt: closed, Payment: queue.PaymentPaid}
+	if _, err := om.ImportOrders(ctx, []HistoricalOrder{good, bad}); !errors.Is(err, ErrImport) {
+		t.Fatalf("import with an open order = %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("failed import announced %v", events)
+	}
+
+	tokens, err := om.ImportOrders(ctx, []HistoricalOrder{good, {Item: "tea", Status: queue.StatusCancelled, OrderedAt: ordered, ClosedAt: closed}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	soup := tokens[0]
+	if soup.ImportedFrom != "A-17" || soup.Number != 0 || soup.Status != queue.StatusPickedUp || !soup.Timestamp.Equal(ordered) ||
+		soup.PreparedAt == nil || !soup.PreparedAt.Equal(prepared) || soup.PickedUpAt == nil || !soup.PickedUpAt.Equal(closed) {
+		t.Fatalf("imported = %+v", soup)
+	}
+	if tea := tokens[1]; tea.CancelledAt == nil || tea.Payment != queue.PaymentUnpaid || tea.Quantity != 1 {
+		t.Errorf("imported = %+v", tea)
+	}
+	if !slices.Equal(events, []string{EventImported, EventImported}) {
+		t.Errorf("events = %v", events)
+	}
+	if _, err := om.PrepareOrder(ctx); !errors.Is(err, ErrQueueEmpty) {
+		t.Errorf("imported orders were queued: %v", err)
+	}
+	if got, err := om.GetOrder(ctx, soup.ID); err != nil || got.ImportedFrom != "A-17" {
+		t.Errorf("get = %+v, %v", got, err)
+	}
+}
+
+func TestUpdateMenu(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.Strategy = StrategySJF
+	cfg.ItemPrepTimes = map[string]config.Duration{"stew": config.Duration(20 * time.Minute)}
+	om := New(cfg)
+	price, quick := 4.5, config.Duration(time.Minute)
+	menu := om.UpdateMenu([]MenuItem{{Item: "stew", Price: &price}, {Item: "salad", PrepTime: &quick}})
+	if menu.ItemPrices["stew"] != 4.5 || menu.ItemPrepTimes["stew"] != cfg.ItemPrepTimes["stew"] || menu.ItemPrepTimes["salad"] != quick {
+		t.Fatalf("menu = %+v", menu)
+	}
+	if _, ok := cfg.ItemPrepTimes["salad"]; ok {
+		t.Error("UpdateMenu changed the caller's config")
+	}
+	add(t, om, "stew", 1)
+	add(t, om, "salad", 1)
+	if next, err := om.PrepareOrder(ctx); err != nil || next.Item != "salad" {
+		t.Errorf("prepared %+v, %v; want the salad the new prep time makes quicker", next, err)
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	cfg := DefaultConfig()
+	cfg.PreparedTTL = config.Duration(10 * time.Minute)
+	om := New(cfg, WithClock(clk))
+
+	first := add(t, om, "tea", 1)
+	second := add(t, om, "soup", 1)
+	if !first.Timestamp.Equal(start) || !second.Timestamp.Equal(start) {
+		t.Fatalf("timestamps %v, %v", first.Timestamp, second.Timestamp)
+	}
+	got, err := om.PrepareOrder(ctx)
+	if err != nil || got.ID != first.ID {
+		t.Fatalf("tie went to %+v, %v; want the first placed", got, err)
+	}
+	cancelled, _ := om.CancelOrder(ctx, second.ID, "")
+	clk.Advance(time.Duration(cfg.CancelRecovery) + time.Second)
+	if _, err := om.RecoverOrder(ctx, cancelled.ID); !errors.Is(err, ErrGraceExpired) {
+		t.Errorf("recover after the window = %v", err)
+	}
+
+	go om.Run(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if clk.Waiting() > 0 {
+			clk.Advance(time.Minute)
+		}
+		token, _ := om.GetOrder(ctx, first.ID)
+		if token.Status == queue.StatusExpired {
+			if !token.ExpiredAt.After(got.PreparedAt.Add(time.Duration(cfg.PreparedTTL))) {
+				t.Errorf("expired at %v, prepared at %v", token.ExpiredAt, got.PreparedAt)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("not expired by %v", clk.Now())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestListingCache(t *testing.T) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	tea := add(t, om, "tea", 1)
+	first, _, err := om.ListOrders(ctx)
+	if err != nil || len(first) != 1 {
+		t.Fatalf("list = %v, %v", first, err)
+	}
+	first[0].Item = "changed by the caller"
+	again, _, _ := om.ListOrders(ctx)
+	if again[0].Item != "tea" || again[0].Version != tea.Version || again[0].Position != 1 {
+		t.Fatalf("cached listing = %+v", again[0])
+	}
+
+	// Reading single orders leaves the state, and so the listing, as it was
+	cached, gen := om.listing.Load(), om.gen.Load()
+	for range 3 {
+		if _, err := om.GetOrder(ctx, tea.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	om.ListOrders(ctx)
+	if om.gen.Load() != gen || om.listing.Load() != cached {
+		t.Errorf("GetOrder moved the generation from %d to %d and rebuilt the listing", gen, om.gen.Load())
+	}
+
+	add(t, om, "soup", 0)
+	notes := "hot"
+	if _, err := om.ModifyOrder(ctx, tea.ID, OrderChanges{Notes: &notes}); err != nil {
+		t.Fatal(err)
+	}
+	preparing, _, _ := om.ListOrders(ctx)
+	if len(preparing) != 2 || preparing[1].Notes != "hot" || preparing[1].Position != 2 {
+		t.Fatalf("listing after changes = %v", preparing)
+	}
+	prepare(t, om)
+	preparing, prepared, _ := om.ListOrders(ctx)
+	if len(preparing) != 1 || len(prepared) != 1 {
+		t.Fatalf("listing after prepare: %d preparing, %d prepared", len(preparing), len(prepared))
+	}
+}
+
+func TestListedTokensShareNothing(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.PriorityRules = []PriorityRule{{Name: "kids meal", When: Condition{Items: []string{"Kids Meal"}}, Boost: 1}}
+	om := New(cfg)
+	tok, err := om.PlaceOrder(ctx, NewOrder{Item: "kids meal", Priority: 3, PromisedBy: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := om.AddAttachment(ctx, tok.ID, queue.Attachment{ID: "a1", Name: "allergy.pdf"}); err != nil {
+		t.Fatal(err)
+	}
+	want, err := om.GetOrder(ctx, tok.ID)
+	if err != nil || len(want.Rules) != 1 || len(want.Attachments) != 1 || want.PromisedBy == nil {
+		t.Fatalf("order = %+v, %v", want, err)
+	}
+
+	// Twice, so the second round scribbles on copies of the cached listing
+	for range 2 {
+		preparing, _, _ := om.ListOrders(ctx)
+		got := preparing[0]
+		got.Rules[0].Rule = "scribbled"
+		got.Attachments[0].Name = "scribbled"
+		*got.PromisedBy = time.Time{}
+		got.Flags = append(got.Flags, "scribbled")
+	}
+	after, _ := om.GetOrder(ctx, tok.ID)
+	if after.Rules[0] != want.Rules[0] || after.Attachments[0] != want.Attachments[0] || !after.PromisedBy.Equal(*want.PromisedBy) || after.Version != want.Version {
+		t.Errorf("order after callers changed their copies = %+v, want %+v", after, want)
+	}
+	if preparing, _, _ := om.ListOrders(ctx); preparing[0].Rules[0] != want.Rules[0] || preparing[0].Attachments[0] != want.Attachments[0] {
+		t.Errorf("cached listing changed: %+v", preparing[0])
+	}
+}
+
+func BenchmarkListOrders(b *testing.B) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	for i := range 1000 {
+		add(b, om, fmt.Sprintf("item %d", i), i%5)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, _, err := om.ListOrders(ctx); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+// BenchmarkPlaceOrderWhileListing times orders placed and prepared while
+// dashboards poll the queue as fast as they can
+func BenchmarkPlaceOrderWhileListing(b *testing.B) {
+	for _, pollers := range []int{0, 8} {
+		b.Run(fmt.Sprintf("pollers=%d", pollers), func(b *testing.B) { benchmarkPlaceWhileListing(b, pollers) })
+	}
+}
+
+func benchmarkPlaceWhileListing(b *testing.B, pollers int) {
+	ctx := context.Background()
+	om := New(DefaultConfig())
+	for i := range 1000 {
+		add(b, om, fmt.Sprintf("item %d", i), i%5)
+	}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range pollers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					om.ListOrders(ctx)
+				}
+			}
+		}()
+	}
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := om.PlaceOrder(ctx, NewOrder{Item: "tea", Priority: i % 5}); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := om.PrepareOrder(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	close(stop)
+	wg.Wait()
+}
+
+func BenchmarkSearch(b *testing.B) {
+	ix := newSearchIndex()
+	for i := range 20000 {
+		ix.add(&queue.Token{ID: strconv.Itoa(i + 1), Item: fmt.Sprintf("item%d dish%d", i, i%97), Notes: fmt.Sprintf("note%x", i*7919)})
+	}
+	terms := searchTerms("dish42 item4")
+	b.ResetTimer()
+	for range b.N {
+		ix.find(terms)
+	}
+}
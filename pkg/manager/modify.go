@@ -0,0 +1,105 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"awesomeProject/pkg/queue"
+)
+
+// OrderChanges lists the fields to change on an order; nil fields are kept
+type OrderChanges struct {
+	Item     *string
+	Quantity *int
+	Notes    *string
+	Flags    *[]string // Replaces the allergy and dietary flags
+	Priority *int      // Moves a waiting order up or down the queue
+
+	// Version, when set, makes the changes apply only while the order is
+	// still at that version; see VersionError
+	Version string
+}
+
+// ModifyOrder applies ch to an order that has not been prepared yet and
+// records each changed field in the token's edit history. Orders that have
+// left the queue return ErrNotModifiable. A raised priority carries over to
+// the rest of the order's group under GroupPriorityInherit. Changing the item
+// to or from one marked unavailable flags or clears the order, parking or
+// queuing it as MarkUnavailable and MarkAvailable would.
+func (om *OrderManager) ModifyOrder(ctx context.Context, id string, ch OrderChanges) (*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	token, err := om.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(token, ch.Version); err != nil {
+		return nil, err
+	}
+	switch token.Status {
+	case queue.StatusPreparing, queue.StatusWaitlisted, queue.StatusAwaitingPayment, queue.StatusScheduled, queue.StatusBlocked, queue.StatusOnHold:
+	default:
+		return nil, ErrNotModifiable
+	}
+
+	prior := token.Clone()
+	now := om.clock.Now()
+	record := func(field, from, to string) {
+		if from != to {
+			token.Edits = append(token.Edits, queue.Edit{Field: field, From: from, To: to, At: now})
+		}
+	}
+	if ch.Item != nil {
+		record("item", token.Item, *ch.Item)
+		token.Item = *ch.Item
+		token.Unavailable = om.unavailable[itemKey(token.Item)]
+	}
+	if ch.Quantity != nil {
+		record("quantity", strconv.Itoa(token.Quantity), strconv.Itoa(*ch.Quantity))
+		token.Quantity = *ch.Quantity
+		om.rekey(token)
+	}
+	if ch.Item != nil || ch.Quantity != nil {
+		om.price(token, orderCurrency(prior))
+	}
+	if ch.Notes != nil {
+		record("notes", token.Notes, *ch.Notes)
+		token.Notes = *ch.Notes
+	}
+	if ch.Flags != nil {
+		flags := normalizeFlags(*ch.Flags)
+		record("flags", strings.Join(token.Flags, ","), strings.Join(flags, ","))
+		token.Flags = flags
+	}
+	if ch.Priority != nil {
+		record("priority", strconv.Itoa(token.Priority), strconv.Itoa(*ch.Priority))
+		token.Priority = *ch.Priority
+	}
+	if token.Status == queue.StatusPreparing {
+		if err := om.waiting.Update(ctx, token); err != nil {
+			*token = *prior
+			if errors.Is(err, ErrNotQueued) {
+				err = ErrNotModifiable
+			}
+			return nil, err
+		}
+	}
+	om.emit(ctx, EventModified, token)
+	if token.Unavailable != prior.Unavailable {
+		if err := om.settle(ctx, token); err != nil {
+			log.Printf("modify order %s: %v", token.ID, err)
+		}
+		if token, err = om.lookup(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	if ch.Priority != nil && token.Priority < prior.Priority {
+		om.boostGroup(ctx, token, "", now)
+	}
+	c := token.Clone()
+	om.estimate(ctx, c)
+	return c, nil
+}
@@ -0,0 +1,166 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// Pause describes why new orders are being turned away
+type Pause struct {
+	Reason string    `json:"reason,omitempty"` // Shown to customers, such as "kitchen closed"
+	Since  time.Time `json:"since"`
+}
+
+// PausedError is returned by PlaceOrder while ordering is paused
+type PausedError struct {
+	Pause
+}
+
+func (e *PausedError) Error() string {
+	format, args := e.Message()
+	return fmt.Sprintf(format, args...)
+}
+
+// Message returns the format and arguments of the error text, for translation
+func (e *PausedError) Message() (string, []any) {
+	if e.Reason == "" {
+		return ErrPaused.Error(), nil
+	}
+	return "ordering is paused: %s", []any{e.Reason}
+}
+
+func (e *PausedError) Unwrap() error { return ErrPaused }
+
+// PauseOrdering turns new orders away, for when the kitchen is closed or
+// overwhelmed, until ResumeOrdering. Orders already placed go on through
+// the kitchen as before, so staff can work through the queue, and
+// scheduled, waitlisted and held orders are still released. Pausing again
+// replaces the reason and keeps the time it started.
+func (om *OrderManager) PauseOrdering(reason string) Pause {
+	om.mu.Lock()
+	defer om.unlock()
+	if om.pause == nil {
+		om.pause = &Pause{Since: om.clock.Now()}
+	}
+	om.pause.Reason = reason
+	return *om.pause
+}
+
+// ResumeOrdering takes new orders again, returning ErrNotPaused when they
+// were not being turned away
+func (om *OrderManager) ResumeOrdering() error {
+	om.mu.Lock()
+	defer om.unlock()
+	if om.pause == nil {
+		return ErrNotPaused
+	}
+	om.pause = nil
+	return nil
+}
+
+// OrderingPaused returns why new orders are turned away, or false when they
+// are taken
+func (om *OrderManager) OrderingPaused() (Pause, bool) {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	if om.pause == nil {
+		return Pause{}, false
+	}
+	return *om.pause, true
+}
+
+// StationPause describes why a station's orders are not being handed out
+type StationPause struct {
+	Station string    `json:"station"`          // Empty for orders without one
+	Reason  string    `json:"reason,omitempty"` // Such as "fryer broken"
+	Since   time.Time `json:"since"`
+}
+
+// PauseStation stops handing out station's orders, for when its equipment
+// is down: they stay queued, in their places, but PrepareOrder, ClaimOrder
+// and auto-prepare pass them over, PrepareStationOrder and
+// ClaimStationOrder return ErrStationPaused, and they have no ready
+// estimate. Orders are still placed for the station, and a particular order
+// can still be claimed or prepared by its ID. Waiting orders for items that
+// ItemStations lets other stations make are first moved to the one that
+// would have them ready soonest, announced with EventReassigned. Each order
+// left waiting there is announced with EventStationPaused, and copies of
+// them are returned. Pausing again replaces the reason and keeps the time
+// it started.
+func (om *OrderManager) PauseStation(ctx context.Context, station, reason string) (StationPause, []*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	p, again := om.stations[station]
+	if !again {
+		p = &StationPause{Station: station, Since: om.clock.Now()}
+		if om.stations == nil {
+			om.stations = make(map[string]*StationPause)
+		}
+		om.stations[station] = p
+	}
+	p.Reason = reason
+	if again {
+		return *p, nil, nil
+	}
+	if err := om.rebalance(ctx, station); err != nil {
+		return *p, nil, err
+	}
+	affected, err := om.announceStation(ctx, station, EventStationPaused)
+	return *p, affected, err
+}
+
+// ResumeStation hands out station's orders again, announcing each waiting
+// there with EventStationResumed and returning copies of them. It returns
+// ErrStationRunning when the station was not paused.
+func (om *OrderManager) ResumeStation(ctx context.Context, station string) ([]*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	if _, ok := om.stations[station]; !ok {
+		return nil, ErrStationRunning
+	}
+	delete(om.stations, station)
+	return om.announceStation(ctx, station, EventStationResumed)
+}
+
+// PausedStations lists the paused stations, by station
+func (om *OrderManager) PausedStations() []StationPause {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	list := make([]StationPause, 0, len(om.stations))
+	for _, p := range om.stations {
+		list = append(list, *p)
+	}
+	slices.SortFunc(list, func(a, b StationPause) int { return strings.Compare(a.Station, b.Station) })
+	return list
+}
+
+// stationPaused reports whether station's orders are held back; mu must be
+// held, at least for reading
+func (om *OrderManager) stationPaused(station string) bool {
+	_, ok := om.stations[station]
+	return ok
+}
+
+// announceStation emits typ for each order waiting at station, in queue
+// order, returning copies of them; mu must be held
+func (om *OrderManager) announceStation(ctx context.Context, station, typ string) ([]*queue.Token, error) {
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(waiting, queueOrder)
+	var affected []*queue.Token
+	for _, t := range waiting {
+		if t.Station != station {
+			continue
+		}
+		om.emit(ctx, typ, t)
+		affected = append(affected, t.Clone())
+	}
+	return affected, nil
+}
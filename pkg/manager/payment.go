@@ -0,0 +1,89 @@
+package manager
+
+import (
+	"context"
+	"errors"
+
+	"awesomeProject/pkg/queue"
+)
+
+// held reports whether token must wait for payment before it is queued
+func (om *OrderManager) held(token *queue.Token) bool {
+	return om.cfg.RequirePayment && token.Payment != queue.PaymentPaid
+}
+
+// holdForPayment parks token until it is paid; mu must be held
+func (om *OrderManager) holdForPayment(token *queue.Token) {
+	token.Status = queue.StatusAwaitingPayment
+	om.unpaid = append(om.unpaid, token)
+}
+
+// SetPayment records a payment status change reported by the till or the
+// payment provider. Orders go from unpaid to paid and from paid to refunded;
+// repeating the current status is accepted so provider retries are harmless.
+// When payment is required, paying an order releases it into the queue; a
+// paid order is waitlisted rather than refused if its station is full, or
+// queued regardless when the waitlist is off.
+func (om *OrderManager) SetPayment(ctx context.Context, id string, status, ref string) (*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	token, err := om.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if token.Payment == status {
+		return token.Clone(), nil
+	}
+	switch {
+	case status == queue.PaymentPaid && token.Payment == queue.PaymentUnpaid:
+	case status == queue.PaymentRefunded && token.Payment == queue.PaymentPaid:
+	default:
+		return nil, ErrPaymentTransition
+	}
+
+	prior := token.Clone()
+	now := om.clock.Now()
+	token.Payment = status
+	if ref != "" {
+		token.PaymentRef = ref
+	}
+	if status == queue.PaymentPaid {
+		token.PaidAt = &now
+	} else {
+		token.RefundedAt = &now
+	}
+	if token.Status == queue.StatusPreparing {
+		if err := om.waiting.Update(ctx, token); err != nil && !errors.Is(err, ErrNotQueued) {
+			*token = *prior
+			return nil, err
+		}
+	}
+	om.emit(ctx, EventPayment, token)
+
+	if token.Status == queue.StatusAwaitingPayment && !om.held(token) {
+		admitted, err := om.admit(ctx, token.Station)
+		switch {
+		case err != nil:
+			// Leave it held; the payment itself is recorded
+			return nil, err
+		case !admitted && om.cfg.Waitlist:
+			om.unpaid = removeToken(om.unpaid, token)
+			token.Status = queue.StatusWaitlisted
+			om.waitlist = append(om.waitlist, token)
+			om.emit(ctx, EventWaitlisted, token)
+		default:
+			parked, err := om.enqueue(ctx, token)
+			if err != nil {
+				token.Status = queue.StatusAwaitingPayment
+				return nil, err
+			}
+			om.unpaid = removeToken(om.unpaid, token)
+			if parked {
+				om.emit(ctx, EventBlocked, token)
+			} else {
+				om.emit(ctx, EventReleased, token)
+			}
+		}
+	}
+	return token.Clone(), nil
+}
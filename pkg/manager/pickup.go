@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/features"
+	"awesomeProject/pkg/queue"
+)
+
+// pickupCodeAlphabet leaves out letters and digits easily mistaken for each
+// other, such as O and 0
+const pickupCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// pickupCodeLength gives about a million codes, enough that guessing one at
+// the counter is hopeless
+const pickupCodeLength = 4
+
+// newPickupCode returns a random code for a new order's receipt
+func newPickupCode() (string, error) {
+	b := make([]byte, pickupCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("pickup code: %w", err)
+	}
+	for i := range b {
+		b[i] = pickupCodeAlphabet[int(b[i])%len(pickupCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// PickUpOrder marks a prepared order as collected and removes it from the
+// list awaiting pickup. code is the pickup code from the receipt, which is
+// always checked. Orders placed before codes were issued have none and need
+// none, unless RequirePickupCode is set.
+func (om *OrderManager) PickUpOrder(ctx context.Context, id string, code string) (*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	token, ok := om.byID[id]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	if token.Status != queue.StatusPrepared {
+		return nil, ErrNotPrepared
+	}
+	if token.PickupCode == "" {
+		if om.cfg.RequirePickupCode {
+			return nil, ErrPickupCode
+		}
+	} else if subtle.ConstantTimeCompare([]byte(normalizeCode(code)), []byte(token.PickupCode)) != 1 {
+		return nil, ErrPickupCode
+	}
+
+	now := om.clock.Now()
+	om.prepared = removeToken(om.prepared, token)
+	token.Status = queue.StatusPickedUp
+	token.PickedUpAt = &now
+	om.closed = append(om.closed, token)
+	om.emit(ctx, EventPickedUp, token)
+	return token.Clone(), nil
+}
+
+// normalizeCode accepts codes typed in lower case or with spaces
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(code), ""))
+}
+
+// expirePrepared closes prepared orders that have waited longer than the
+// configured TTL, unless the autoExpiry flag is off; mu must be held
+func (om *OrderManager) expirePrepared(ctx context.Context, now time.Time) {
+	ttl := time.Duration(om.cfg.PreparedTTL)
+	if ttl <= 0 || !om.features.Enabled(features.AutoExpiry) {
+		return
+	}
+	n := 0
+	for n < len(om.prepared) && !om.prepared[n].PreparedAt.Add(ttl).After(now) {
+		token := om.prepared[n]
+		at := now
+		token.Status = queue.StatusExpired
+		token.ExpiredAt = &at
+		om.closed = append(om.closed, token)
+		om.emit(ctx, EventExpired, token)
+		n++
+	}
+	if n > 0 {
+		om.prepared = append(om.prepared[:0], om.prepared[n:]...)
+	}
+}
+
+// removeToken deletes t from list, preserving order
+func removeToken(list []*queue.Token, t *queue.Token) []*queue.Token {
+	for i, x := range list {
+		if x == t {
+			return append(list[:i], list[i+1:]...)
+		}
+	}
+	return list
+}
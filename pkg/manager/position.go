@@ -0,0 +1,49 @@
+package manager
+
+import (
+	"context"
+	"log"
+
+	"awesomeProject/pkg/queue"
+)
+
+// setPosition sets c's place in its station's queue when c is a copy of a
+// token in waiting, counting the orders the strategy would prepare first.
+// Under strict priority a queue that implements Ranker counts them itself.
+// Orders at paused stations have no place until it resumes. mu must be held.
+func (om *OrderManager) setPosition(ctx context.Context, c *queue.Token, waiting []*queue.Token) {
+	if c.Status != queue.StatusPreparing || om.stationPaused(c.Station) {
+		return
+	}
+	if r, ok := om.waiting.(Ranker); ok {
+		if _, strict := om.strategy.(StrictPriority); strict {
+			ahead, err := r.Ahead(ctx, c)
+			if err != nil {
+				log.Printf("queue position of order %s: %v", c.ID, err)
+				return
+			}
+			c.Position = ahead + 1
+			c.Ahead = &ahead
+			return
+		}
+	}
+	om.withPositions([]*queue.Token{c}, waiting)
+}
+
+// withPositions sets the queue position on copies of waiting orders among
+// tokens, ranking the whole of waiting once in the order the strategy would
+// prepare it. mu must be held, at least for reading.
+func (om *OrderManager) withPositions(tokens, waiting []*queue.Token) {
+	places := make(map[string]int, len(waiting))
+	next := make(map[string]int) // Orders ranked so far per station
+	for _, t := range om.prepOrder(waiting) {
+		places[t.ID] = next[t.Station]
+		next[t.Station]++
+	}
+	for _, t := range tokens {
+		if ahead, ok := places[t.ID]; ok && t.Status == queue.StatusPreparing {
+			t.Position = ahead + 1
+			t.Ahead = &ahead
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"fmt"
+	"slices"
+
+	"awesomeProject/pkg/money"
+	"awesomeProject/pkg/queue"
+)
+
+// validatePricing checks the currencies, rounding and tax rates
+func (c Config) validatePricing() error {
+	if c.Currency != "" && !money.Valid(c.Currency) {
+		return fmt.Errorf("currency %q must be an ISO 4217 code like EUR", c.Currency)
+	}
+	if len(c.CurrencyPrices) > 0 && c.Currency == "" {
+		return fmt.Errorf("currencyPrices needs currency set for the prices in itemPrices")
+	}
+	for currency := range c.CurrencyPrices {
+		if !money.Valid(currency) {
+			return fmt.Errorf("currencyPrices: %q must be an ISO 4217 code like EUR", currency)
+		}
+	}
+	if c.Rounding != "" && !slices.Contains(money.Roundings, c.Rounding) {
+		return fmt.Errorf("unknown rounding %q, want one of %v", c.Rounding, money.Roundings)
+	}
+	if c.Tax.Rate < 0 || c.Tax.Rate > 100 {
+		return fmt.Errorf("tax.rate must be a percentage between 0 and 100")
+	}
+	for item, rate := range c.Tax.ItemRates {
+		if rate < 0 || rate > 100 {
+			return fmt.Errorf("tax.itemRates: the rate for %q must be a percentage between 0 and 100", item)
+		}
+	}
+	return nil
+}
+
+// checkCurrency checks that items are priced in currency, with "" for the
+// configured one
+func (om *OrderManager) checkCurrency(currency string) error {
+	if currency == "" || currency == om.cfg.Currency || om.cfg.CurrencyPrices[currency] != nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrCurrency, currency)
+}
+
+// price sets token's Total in currency, "" for the configured one, from its
+// item's price and quantity, or clears it when the item has no price there;
+// mu must be held
+func (om *OrderManager) price(token *queue.Token, currency string) {
+	token.Total = nil
+	if om.cfg.Currency == "" {
+		return
+	}
+	prices := om.cfg.ItemPrices
+	if currency == "" || currency == om.cfg.Currency {
+		currency = om.cfg.Currency
+	} else {
+		prices = om.cfg.CurrencyPrices[currency]
+	}
+	price, ok := prices[token.Item]
+	if !ok {
+		return
+	}
+	rounding := om.cfg.Rounding
+	if rounding == "" {
+		rounding = money.HalfUp
+	}
+	rate, ok := om.cfg.Tax.ItemRates[token.Item]
+	if !ok {
+		rate = om.cfg.Tax.Rate
+	}
+	// The unit price is rounded before it is multiplied, as a till would
+	subtotal := money.FromMajor(price, currency, rounding) * int64(max(token.Quantity, 1))
+	tax := money.Tax(subtotal, rate, om.cfg.Tax.Included, rounding)
+	total := subtotal
+	if !om.cfg.Tax.Included {
+		total += tax
+	}
+	token.Total = &queue.Total{
+		Currency:    currency,
+		Subtotal:    subtotal,
+		Tax:         tax,
+		Total:       total,
+		TaxRate:     rate,
+		TaxIncluded: om.cfg.Tax.Included,
+	}
+}
+
+// orderCurrency is the currency token was charged in, "" for the
+// configured one
+func orderCurrency(token *queue.Token) string {
+	if token.Total == nil {
+		return ""
+	}
+	return token.Total.Currency
+}
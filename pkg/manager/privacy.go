@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"context"
+	"slices"
+
+	"awesomeProject/pkg/queue"
+)
+
+// Purge erases the customer's personal data from every order held that
+// match selects, as Token.Scrub does, and announces each with an
+// EventPurged so stores following the events write the order over. It
+// returns the IDs of the orders that had any, in order.
+func (om *OrderManager) Purge(ctx context.Context, match func(*queue.Token) bool) ([]string, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for id, t := range om.byID {
+		if match(t) {
+			ids = append(ids, id)
+		}
+	}
+	for _, t := range waiting {
+		if _, held := om.byID[t.ID]; !held && match(t) {
+			ids = append(ids, t.ID)
+		}
+	}
+	slices.Sort(ids)
+
+	purged := ids[:0]
+	for _, id := range ids {
+		token, err := om.lookup(ctx, id)
+		if err != nil {
+			return purged, err
+		}
+		prior := token.Clone()
+		if !match(token) || !token.Scrub() {
+			continue
+		}
+		if token.Status == queue.StatusPreparing {
+			if err := om.waiting.Update(ctx, token); err != nil {
+				*token = *prior
+				return purged, err
+			}
+		}
+		om.emit(ctx, EventPurged, token)
+		purged = append(purged, id)
+	}
+	return purged, nil
+}
@@ -0,0 +1,166 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// escalator is recorded as the author of the priority edits escalate makes
+const escalator = "escalation"
+
+// promise records that token, being placed, was promised ready by at, if
+// set, and watches it; mu must be held
+func (om *OrderManager) promise(token *queue.Token, at time.Time) {
+	if at.IsZero() {
+		return
+	}
+	token.PromisedBy = &at
+	if om.promised == nil {
+		om.promised = make(map[string]bool)
+	}
+	om.promised[token.ID] = true
+}
+
+// rewatchPromises rebuilds the promised orders from the restored ones; mu
+// must be held
+func (om *OrderManager) rewatchPromises() {
+	om.promised = nil
+	for _, t := range om.byID {
+		if promiseOpen(t) {
+			if om.promised == nil {
+				om.promised = make(map[string]bool)
+			}
+			om.promised[t.ID] = true
+		}
+	}
+}
+
+// promiseOpen reports whether token has a promised-by time it may still
+// keep or miss: it has not been prepared or closed yet
+func promiseOpen(token *queue.Token) bool {
+	if token.PromisedBy == nil {
+		return false
+	}
+	switch token.Status {
+	case queue.StatusPrepared, queue.StatusPickedUp, queue.StatusExpired, queue.StatusCancelled, queue.StatusVoided:
+		return false
+	}
+	return true
+}
+
+// escalate raises the priority of waiting orders as their promised-by time
+// nears, announcing each step with EventEscalated, and flags the open orders
+// the ready-time plan now puts after their promised time, announcing each
+// as it becomes at risk with EventAtRisk. mu must be held.
+func (om *OrderManager) escalate(ctx context.Context, now time.Time) {
+	if len(om.promised) == 0 {
+		return
+	}
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		// Checked again on the next tick
+		log.Printf("escalate: %v", err)
+		return
+	}
+	queued := make(map[string]*queue.Token, len(waiting))
+	for _, t := range waiting {
+		queued[t.ID] = t
+	}
+	etas := om.plan(waiting, now)
+
+	ids := make([]string, 0, len(om.promised))
+	for id := range om.promised {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, compareIDs)
+	for _, id := range ids {
+		token, ok := om.byID[id]
+		if ok && token.Status == queue.StatusPreparing {
+			// A shared queue holds the current copy, if another instance
+			// has not taken the order
+			if token, ok = queued[id]; ok {
+				om.byID[id] = token
+			}
+		}
+		if !ok || !promiseOpen(token) {
+			delete(om.promised, id)
+			continue
+		}
+		if err := om.escalateOne(ctx, token, etas, now); err != nil {
+			log.Printf("escalate: order %s: %v", id, err)
+		}
+	}
+}
+
+// escalateOne raises token's priority to where its promised-by time puts
+// it and updates whether it is at risk; mu must be held
+func (om *OrderManager) escalateOne(ctx context.Context, token *queue.Token, etas map[string]time.Time, now time.Time) error {
+	prior := token.Clone()
+	var events []string
+	if target := om.escalatedPriority(token, now); token.Status == queue.StatusPreparing && target < token.Priority {
+		token.Edits = append(token.Edits, queue.Edit{
+			Field: "priority", From: strconv.Itoa(token.Priority), To: strconv.Itoa(target), At: now, By: escalator,
+		})
+		token.Priority = target
+		events = append(events, EventEscalated)
+	}
+	late := now.After(*token.PromisedBy)
+	if eta, ok := etas[token.ID]; ok {
+		late = eta.After(*token.PromisedBy)
+	}
+	if late != token.AtRisk {
+		token.AtRisk = late
+		if late {
+			events = append(events, EventAtRisk)
+		}
+	}
+	if token.Digest() == prior.Digest() {
+		return nil
+	}
+	if token.Status == queue.StatusPreparing {
+		if err := om.waiting.Update(ctx, token); err != nil && !errors.Is(err, ErrNotQueued) {
+			*token = *prior
+			return err
+		}
+	}
+	for _, typ := range events {
+		om.emit(ctx, typ, token)
+	}
+	return nil
+}
+
+// escalatedPriority is the priority token should have by now: within
+// EscalationWindow of its promised-by time, the priority it had before
+// escalation scaled down by the share of the window left, reaching 0 at
+// the promised time. Rushed orders and those at 0 are left alone.
+func (om *OrderManager) escalatedPriority(token *queue.Token, now time.Time) int {
+	window := time.Duration(om.cfg.EscalationWindow)
+	if window <= 0 || token.Priority <= 0 {
+		return token.Priority
+	}
+	left := token.PromisedBy.Sub(now)
+	if left >= window {
+		return token.Priority
+	}
+	base := token.Priority
+	for i := len(token.Edits) - 1; i >= 0; i-- {
+		e := token.Edits[i]
+		if e.Field != "priority" {
+			continue
+		}
+		if e.By != escalator {
+			break
+		}
+		if p, err := strconv.Atoi(e.From); err == nil {
+			base = p
+		}
+	}
+	return int(math.Ceil(float64(base) * max(left, 0).Seconds() / window.Seconds()))
+}
@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"context"
+	"log"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// queueCheckInterval is how often Run checks the queue for RepairQueue
+const queueCheckInterval = time.Minute
+
+// verifyQueue checks the in-memory queue's invariants. Shared backends keep
+// their own, so they are not checked.
+func (om *OrderManager) verifyQueue() error {
+	mq, ok := om.waiting.(*MemoryQueue)
+	if !ok {
+		return nil
+	}
+	return mq.Verify()
+}
+
+// repairQueue logs err, a violation found after the named method or check,
+// and rebuilds the queue when RepairQueue is set; mu must be held for
+// writing
+func (om *OrderManager) repairQueue(ctx context.Context, err error, after string) {
+	n, _ := om.waiting.Len(ctx)
+	log.Printf("queue check after %s: %v (%d orders queued)", after, err, n)
+	if !om.cfg.RepairQueue {
+		return
+	}
+	rb, err := om.rebuildQueue(ctx)
+	if err != nil {
+		log.Printf("queue repair: %v", err)
+		return
+	}
+	log.Printf("queue repair: rebuilt with %d orders, restored %v, dropped %v", rb.Queued, rb.Restored, rb.Dropped)
+}
+
+// methodName returns the name of the OrderManager method at pc, such as
+// PlaceOrder
+func methodName(pc uintptr) string {
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return "an unknown method"
+	}
+	name := f.Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"fmt"
+	"slices"
+
+	"awesomeProject/pkg/queue"
+)
+
+// QuotaConfig caps the orders one customer may have in the kitchen at once,
+// those placed but not yet prepared, so a prankster at a walk-up kiosk or
+// on a phone cannot flood it. Zero limits leave customers unlimited.
+type QuotaConfig struct {
+	PerPhone  int `json:"perPhone"`  // By the phone number given for the ready notification
+	PerDevice int `json:"perDevice"` // By the push notification device token
+	PerClient int `json:"perClient"` // By NewOrder.Client, such as the address the order came from
+
+	// ExemptClients are never limited by client, such as the front desk
+	// till or kiosks shared by every walk-up customer
+	ExemptClients []string `json:"exemptClients"`
+}
+
+func (c QuotaConfig) validate() error {
+	if c.PerPhone < 0 || c.PerDevice < 0 || c.PerClient < 0 {
+		return fmt.Errorf("quota limits must not be negative")
+	}
+	return nil
+}
+
+// QuotaError is returned by PlaceOrder when the customer already has as many
+// orders in the kitchen as Config.Quota allows. It wraps ErrQuota.
+type QuotaError struct {
+	By    string // What the customer was known by: phone, device or client
+	Limit int
+}
+
+func (e *QuotaError) Error() string {
+	format, args := e.Message()
+	return fmt.Sprintf(format, args...)
+}
+
+// Message returns the format and arguments of the error text, for translation
+func (e *QuotaError) Message() (string, []any) {
+	return "this customer already has %d orders waiting, the most allowed at once", []any{e.Limit}
+}
+
+func (e *QuotaError) Unwrap() error { return ErrQuota }
+
+// inKitchen reports whether token has been placed and is not prepared yet
+func inKitchen(token *queue.Token) bool {
+	switch token.Status {
+	case queue.StatusPrepared, queue.StatusPickedUp, queue.StatusExpired, queue.StatusCancelled, queue.StatusVoided:
+		return false
+	}
+	return true
+}
+
+// checkQuota refuses o when its customer is at any of the quota's limits;
+// mu must be held
+func (om *OrderManager) checkQuota(o NewOrder) error {
+	q := om.cfg.Quota
+	limits := []struct {
+		by    string
+		limit int
+		key   string
+		of    func(*queue.Token) string
+	}{
+		{"phone", q.PerPhone, o.Phone, func(t *queue.Token) string { return t.Phone }},
+		{"device", q.PerDevice, o.DeviceToken, func(t *queue.Token) string { return t.DeviceToken }},
+		{"client", q.PerClient, o.Client, func(t *queue.Token) string { return t.Client }},
+	}
+	if slices.Contains(q.ExemptClients, o.Client) {
+		limits = limits[:2]
+	}
+	for _, l := range limits {
+		if l.limit <= 0 || l.key == "" {
+			continue
+		}
+		active := 0
+		for _, t := range om.byID {
+			if l.of(t) == l.key && inKitchen(t) {
+				active++
+			}
+		}
+		if active >= l.limit {
+			return &QuotaError{By: l.by, Limit: l.limit}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"context"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// RecoverOrder undoes CancelOrder within the configured recovery window. The
+// token keeps its original priority and timestamp, so it regains its old
+// position: it goes back into the queue, or is scheduled again if it is a
+// pre-order not yet due, or held again if it still awaits payment, or
+// parked again if its item is unavailable and BlockUnavailable is set. A
+// recovered order is queued even if its station has since filled up.
+func (om *OrderManager) RecoverOrder(ctx context.Context, id string) (*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	token, ok := om.byID[id]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	if token.Status != queue.StatusCancelled {
+		return nil, ErrNotCancelled
+	}
+	now := om.clock.Now()
+	if now.Sub(*token.CancelledAt) > time.Duration(om.cfg.CancelRecovery) {
+		return nil, ErrGraceExpired
+	}
+
+	switch {
+	case token.ReleaseAt != nil && token.ReleaseAt.After(now):
+		om.schedule(token, *token.ReadyAt)
+	case om.held(token):
+		om.holdForPayment(token)
+	default:
+		if _, err := om.enqueue(ctx, token); err != nil {
+			token.Status = queue.StatusCancelled
+			return nil, err
+		}
+	}
+	token.CancelledAt, token.ClaimedAt = nil, nil
+	om.closed = removeToken(om.closed, token)
+	om.emit(ctx, EventRecovered, token)
+	c := token.Clone()
+	om.estimate(ctx, c)
+	return c, nil
+}
@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// DefaultRemakeReasons are the reason codes a remake may give when
+// RemakeReasons is not configured
+var DefaultRemakeReasons = []string{"wrong_order", "cold", "undercooked", "quality", "dropped", "other"}
+
+// RefireRequest says why an order came back and who sent it to be remade
+type RefireRequest struct {
+	Reason string // One of Config.RemakeReasons
+	Notes  string // For the remake, in place of the original's when given
+	By     string
+}
+
+// RefireOrder sends an order that came back, wrong or cold, to be made
+// again: a new remake order for the same item, linked to the original by
+// RemakeOf, goes to the front of the queue with queue.RushPriority. It is
+// not held for payment, capacity, opening hours or a pause, and does not
+// count towards MaxRushesPerHour; it is not told to the delivery platform
+// the original came through. The original records the remake in Remakes.
+// Only prepared and picked up orders can be refired, others return
+// ErrNotRefirable; reasons not configured return ErrRemakeReason.
+func (om *OrderManager) RefireOrder(ctx context.Context, id string, req RefireRequest) (*queue.Token, error) {
+	if !slices.Contains(om.cfg.RemakeReasons, req.Reason) {
+		return nil, ErrRemakeReason
+	}
+	om.mu.Lock()
+	defer om.unlock()
+	original, err := om.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch original.Status {
+	case queue.StatusPrepared, queue.StatusPickedUp:
+	default:
+		return nil, ErrNotRefirable
+	}
+
+	code, err := newPickupCode()
+	if err != nil {
+		return nil, err
+	}
+	newID, err := om.nextID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	om.daily++
+	now := om.clock.Now()
+	notes := original.Notes
+	if req.Notes != "" {
+		notes = req.Notes
+	}
+	token := &queue.Token{
+		ID:           newID,
+		Number:       om.daily,
+		Item:         original.Item,
+		Priority:     queue.RushPriority,
+		Status:       queue.StatusPreparing,
+		Timestamp:    now,
+		Quantity:     original.Quantity,
+		Notes:        notes,
+		Flags:        slices.Clone(original.Flags),
+		Station:      original.Station,
+		OrderType:    original.OrderType,
+		Table:        original.Table,
+		Channel:      original.Channel,
+		Payment:      original.Payment,
+		PaidAt:       original.PaidAt,
+		Phone:        original.Phone,
+		DeviceToken:  original.DeviceToken,
+		PickupCode:   code,
+		Unavailable:  om.unavailable[itemKey(original.Item)],
+		RemakeOf:     original.ID,
+		RemakeReason: req.Reason,
+		RushedAt:     &now,
+		RushedBy:     req.By,
+	}
+	om.stamp(token)
+	om.setSLA(token, time.Time{}, now)
+	if _, err := om.enqueue(ctx, token); err != nil {
+		om.daily-- // The next order is given the number instead
+		return nil, err
+	}
+	om.byID[token.ID] = token
+	original.Remakes = append(original.Remakes, token.ID)
+	original.Edits = append(original.Edits, queue.Edit{Field: "remakes", To: token.ID, At: now, By: req.By})
+	om.emit(ctx, EventCreated, token)
+	om.emit(ctx, EventRefired, original)
+	c := token.Clone()
+	om.estimate(ctx, c)
+	return c, nil
+}
@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// RequeueFilter picks the prepared orders RequeuePrepared moves back into
+// the queue: those among IDs, when given, prepared from From up to To, when
+// set, at Station, when set
+type RequeueFilter struct {
+	IDs      []string
+	From, To time.Time
+	Station  string
+
+	// DryRun reports the orders that would be requeued without moving them
+	DryRun bool
+}
+
+// RequeueResult reports a bulk requeue
+type RequeueResult struct {
+	Requeued []*queue.Token `json:"requeued"`
+	Skipped  []string       `json:"skipped,omitempty"` // IDs given that are unknown or not awaiting pickup
+	DryRun   bool           `json:"dryRun"`
+}
+
+// RequeuePrepared moves the prepared orders f picks back into the queue, as
+// UnprepareOrder does but however long ago they were prepared, for orders
+// marked prepared too soon during a rush. Each keeps its priority and
+// timestamp, so regains its old position. Should the queue fail part way,
+// the orders requeued so far are returned with the error.
+func (om *OrderManager) RequeuePrepared(ctx context.Context, f RequeueFilter) (*RequeueResult, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	res := &RequeueResult{Requeued: []*queue.Token{}, DryRun: f.DryRun}
+	for _, id := range f.IDs {
+		if token, ok := om.byID[id]; !ok || token.Status != queue.StatusPrepared {
+			res.Skipped = append(res.Skipped, id)
+		}
+	}
+	picked := slices.DeleteFunc(slices.Clone(om.prepared), func(t *queue.Token) bool {
+		return (len(f.IDs) > 0 && !slices.Contains(f.IDs, t.ID)) ||
+			(!f.From.IsZero() && t.PreparedAt.Before(f.From)) ||
+			(!f.To.IsZero() && !t.PreparedAt.Before(f.To)) ||
+			(f.Station != "" && t.Station != f.Station)
+	})
+	for _, token := range picked {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		if !f.DryRun {
+			if err := om.unprepare(ctx, token); err != nil {
+				return res, err
+			}
+		}
+		res.Requeued = append(res.Requeued, token.Clone())
+	}
+	return res, nil
+}
@@ -0,0 +1,145 @@
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// Restore replaces the manager's state with tokens, for example rebuilt from
+// an event log. Each token is placed according to its status and the ID
+// counter continues after the highest ID and daily token numbers after the
+// most recently placed order. Items of orders flagged unavailable are marked
+// unavailable again. No events are emitted.
+//
+// Waiting tokens are only restored into a MemoryQueue. A shared queue already
+// holds them, along with any changes other instances made since.
+func (om *OrderManager) Restore(tokens []*queue.Token) error {
+	st, err := restoredState(tokens)
+	if err != nil {
+		return err
+	}
+	om.mu.Lock()
+	defer om.unlock()
+	om.install(st)
+	return nil
+}
+
+// state is the manager's order state, built up outside the lock by Restore
+// and RestoreSnapshot
+type state struct {
+	waiting                                                                    *MemoryQueue
+	scheduled, unpaid, waitlist, blocked, onHold, inProgress, prepared, closed []*queue.Token
+	byID                                                                       map[string]*queue.Token
+	unavailable                                                                map[string]bool
+	counter, daily                                                             int
+	seq                                                                        uint64
+}
+
+// restoredState places each token according to its status
+func restoredState(tokens []*queue.Token) (*state, error) {
+	mq := NewMemoryQueue()
+	var scheduled, unpaid, waitlist, blocked, onHold, inProgress, prepared, closed []*queue.Token
+	byID := make(map[string]*queue.Token, len(tokens))
+	unavailable := make(map[string]bool)
+	counter, daily := 0, 0
+	var seq uint64
+	var newest time.Time
+
+	for _, t := range tokens {
+		t = t.Clone()
+		t.EstimatedReadyAt = nil // Recorded from an event; worked out afresh
+		if t.Payment == "" {
+			// Recorded before payments were tracked
+			t.Payment = queue.PaymentUnpaid
+		}
+		if _, dup := byID[t.ID]; dup {
+			return nil, fmt.Errorf("duplicate token %s", t.ID)
+		}
+		switch t.Status {
+		case queue.StatusPreparing:
+			mq.push(t)
+		case queue.StatusScheduled:
+			if t.ReleaseAt == nil {
+				return nil, fmt.Errorf("scheduled token %s has no release time", t.ID)
+			}
+			scheduled = append(scheduled, t)
+		case queue.StatusWaitlisted:
+			waitlist = append(waitlist, t)
+		case queue.StatusBlocked:
+			blocked = append(blocked, t)
+		case queue.StatusOnHold:
+			onHold = append(onHold, t)
+		case queue.StatusAwaitingPayment:
+			unpaid = append(unpaid, t)
+		case queue.StatusInProgress:
+			if t.ClaimedAt == nil {
+				return nil, fmt.Errorf("in-progress token %s has no claim time", t.ID)
+			}
+			inProgress = append(inProgress, t)
+		case queue.StatusPrepared:
+			if t.PreparedAt == nil {
+				return nil, fmt.Errorf("prepared token %s has no prepared time", t.ID)
+			}
+			prepared = append(prepared, t)
+		case queue.StatusPickedUp, queue.StatusExpired, queue.StatusCancelled, queue.StatusVoided:
+			closed = append(closed, t)
+		default:
+			return nil, fmt.Errorf("token %s has unknown status %q", t.ID, t.Status)
+		}
+		byID[t.ID] = t
+		if t.Unavailable {
+			unavailable[itemKey(t.Item)] = true
+		}
+		counter = max(counter, sequence(t.ID))
+		seq = max(seq, t.Seq)
+		if t.Timestamp.After(newest) {
+			newest, daily = t.Timestamp, t.Number
+		}
+	}
+
+	sort.SliceStable(scheduled, func(i, j int) bool { return scheduled[i].ReleaseAt.Before(*scheduled[j].ReleaseAt) })
+	sort.SliceStable(unpaid, func(i, j int) bool { return unpaid[i].Timestamp.Before(unpaid[j].Timestamp) })
+	sort.SliceStable(waitlist, func(i, j int) bool { return waitlist[i].Timestamp.Before(waitlist[j].Timestamp) })
+	sort.SliceStable(blocked, func(i, j int) bool { return blocked[i].Timestamp.Before(blocked[j].Timestamp) })
+	sort.SliceStable(onHold, func(i, j int) bool { return onHold[i].Timestamp.Before(onHold[j].Timestamp) })
+	sort.SliceStable(inProgress, func(i, j int) bool { return inProgress[i].ClaimedAt.Before(*inProgress[j].ClaimedAt) })
+	sort.SliceStable(prepared, func(i, j int) bool { return prepared[i].PreparedAt.Before(*prepared[j].PreparedAt) })
+	sort.SliceStable(closed, func(i, j int) bool { return closedAt(closed[i]).Before(closedAt(closed[j])) })
+
+	return &state{
+		waiting:   mq,
+		scheduled: scheduled, unpaid: unpaid, waitlist: waitlist, blocked: blocked, onHold: onHold, inProgress: inProgress, prepared: prepared, closed: closed,
+		byID: byID, unavailable: unavailable, counter: counter, daily: daily, seq: seq,
+	}, nil
+}
+
+// install replaces the manager's state with st; mu must be held
+func (om *OrderManager) install(st *state) {
+	if _, ok := om.waiting.(*MemoryQueue); ok {
+		om.waiting = st.waiting
+		om.journalHeap(st.waiting)
+	}
+	om.scheduled, om.unpaid, om.waitlist, om.prepared, om.closed = st.scheduled, st.unpaid, st.waitlist, st.prepared, st.closed
+	om.inProgress, om.blocked, om.onHold = st.inProgress, st.blocked, st.onHold
+	om.unavailable = st.unavailable
+	om.byID, om.counter, om.daily, om.seq = st.byID, st.counter, st.daily, st.seq
+	om.search = newSearchIndex()
+	for _, t := range st.byID {
+		om.search.add(t)
+	}
+	om.rewatchSLA()
+	om.rewatchPromises()
+}
+
+// closedAt is when a closed token reached its final status
+func closedAt(t *queue.Token) time.Time {
+	for _, at := range []*time.Time{t.CancelledAt, t.PickedUpAt, t.ExpiredAt} {
+		if at != nil {
+			return *at
+		}
+	}
+	return t.Timestamp
+}
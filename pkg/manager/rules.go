@@ -0,0 +1,160 @@
+package manager
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// PriorityRule adjusts the priority of the orders it matches as they are
+// placed, such as delivery orders late in the evening or kids' meals
+type PriorityRule struct {
+	Name string    `json:"name"`
+	When Condition `json:"when"`
+
+	// Boost is how many levels a matching order moves up the queue, that is
+	// taken off its priority; negative moves it down. Priorities stop at 0,
+	// short of a rush.
+	Boost int `json:"boost"`
+}
+
+// Condition is what a rule matches. Every field given must match; within a
+// list any entry may. An empty condition matches every order.
+type Condition struct {
+	Items      []string `json:"items"`      // Words or phrases in the item name, ignoring case
+	OrderTypes []string `json:"orderTypes"` // Queue order types
+	Stations   []string `json:"stations"`   // "" for the default station
+	Platforms  []string `json:"platforms"`  // Delivery platforms the order came through
+	Flags      []string `json:"flags"`      // Allergy and dietary flags; "allergy" for any allergy
+
+	// Times are windows of local time the order is placed in, as
+	// "21:00-02:00"; a window that ends at or before its start runs past
+	// midnight. Days are weekdays, by lowercase English name, and with
+	// Times name the day a window starts on.
+	Times []string `json:"times"`
+	Days  []string `json:"days"`
+
+	MinQuantity int `json:"minQuantity"`
+}
+
+// rule is a PriorityRule with its times parsed
+type rule struct {
+	PriorityRule
+	times []window
+	days  []time.Weekday
+}
+
+// parseRules checks and parses rules
+func parseRules(rules []PriorityRule) ([]rule, error) {
+	parsed := make([]rule, 0, len(rules))
+	seen := make(map[string]bool, len(rules))
+	for i, pr := range rules {
+		switch {
+		case pr.Name == "":
+			return nil, fmt.Errorf("priority rule %d: name is required", i+1)
+		case seen[pr.Name]:
+			return nil, fmt.Errorf("priority rule %q: name is used twice", pr.Name)
+		case pr.Boost == 0:
+			return nil, fmt.Errorf("priority rule %q: boost must not be 0", pr.Name)
+		case pr.When.MinQuantity < 0:
+			return nil, fmt.Errorf("priority rule %q: minQuantity must not be negative", pr.Name)
+		}
+		seen[pr.Name] = true
+		r := rule{PriorityRule: pr}
+		for _, ot := range pr.When.OrderTypes {
+			if !queue.ValidOrderType(ot) {
+				return nil, fmt.Errorf("priority rule %q: unknown order type %q", pr.Name, ot)
+			}
+		}
+		for _, f := range pr.When.Flags {
+			if f != queue.FlagAllergy && !queue.ValidFlag(f) {
+				return nil, fmt.Errorf("priority rule %q: unknown flag %q", pr.Name, f)
+			}
+		}
+		for _, span := range pr.When.Times {
+			w, err := parseWindow(span)
+			if err != nil {
+				return nil, fmt.Errorf("priority rule %q: %w", pr.Name, err)
+			}
+			r.times = append(r.times, w)
+		}
+		for _, name := range pr.When.Days {
+			day, ok := weekday(name)
+			if !ok {
+				return nil, fmt.Errorf("priority rule %q: unknown day %q, want monday to sunday", pr.Name, name)
+			}
+			r.days = append(r.days, day)
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed, nil
+}
+
+// matches reports whether o, placed at now, meets the rule's condition
+func (r rule) matches(o NewOrder, now time.Time) bool {
+	c := r.When
+	if len(c.Items) > 0 {
+		item := itemKey(o.Item)
+		if !slices.ContainsFunc(c.Items, func(s string) bool { return strings.Contains(item, itemKey(s)) }) {
+			return false
+		}
+	}
+	if len(c.OrderTypes) > 0 && !slices.Contains(c.OrderTypes, o.OrderType) ||
+		len(c.Stations) > 0 && !slices.Contains(c.Stations, o.Station) ||
+		len(c.Platforms) > 0 && !slices.Contains(c.Platforms, o.Platform) ||
+		o.Quantity < c.MinQuantity {
+		return false
+	}
+	if len(c.Flags) > 0 {
+		t := queue.Token{Flags: o.Flags}
+		if !slices.ContainsFunc(c.Flags, t.HasFlag) {
+			return false
+		}
+	}
+	return r.placedWithin(now)
+}
+
+// placedWithin reports whether now falls in the rule's times and days,
+// looking back to the day before for windows that run past midnight
+func (r rule) placedWithin(now time.Time) bool {
+	if len(r.times) == 0 {
+		return len(r.days) == 0 || slices.Contains(r.days, now.Weekday())
+	}
+	for back := 1; back >= 0; back-- {
+		day := midnight(now).AddDate(0, 0, -back)
+		if len(r.days) > 0 && !slices.Contains(r.days, day.Weekday()) {
+			continue
+		}
+		for _, w := range r.times {
+			start, end := w.times(day)
+			if !now.Before(start) && now.Before(end) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyRules runs the priority rules over o in the order they are
+// configured, each matching one adjusting the priority left by those
+// before it, and returns the priority o is placed with and the rules that
+// matched. Rushed orders are left alone; mu must be held.
+func (om *OrderManager) applyRules(o NewOrder, now time.Time) (int, []queue.RuleMatch) {
+	priority := o.Priority
+	if priority == queue.RushPriority {
+		return priority, nil
+	}
+	var matched []queue.RuleMatch
+	for _, r := range om.rules {
+		if !r.matches(o, now) {
+			continue
+		}
+		next := max(priority-r.Boost, 0)
+		matched = append(matched, queue.RuleMatch{Rule: r.Name, From: priority, To: next})
+		priority = next
+	}
+	return priority, matched
+}
@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// tickInterval is how often background work runs
+const tickInterval = time.Second
+
+// stallAfter is how long Run may go without a tick before Check reports it
+const stallAfter = 5 * tickInterval
+
+// Run performs the manager's time-driven work, such as releasing scheduled
+// orders into the queue and expiring unclaimed ones, until ctx is cancelled.
+// It also runs the AutoPrepare workers.
+func (om *OrderManager) Run(ctx context.Context) {
+	if om.cfg.AutoPrepare > 0 {
+		go om.autoPrepare(ctx)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-om.clock.After(tickInterval):
+			om.tick(ctx, now)
+		}
+	}
+}
+
+// tick runs one round of background work
+func (om *OrderManager) tick(ctx context.Context, now time.Time) {
+	om.mu.Lock()
+	defer om.unlock()
+	om.lastTick = om.clock.Now()
+	om.releaseScheduled(ctx, now)
+	om.drainWaitlist(ctx)
+	om.expirePrepared(ctx, now)
+	om.checkSLA(ctx, now)
+	om.escalate(ctx, now)
+	om.closeDayIfDue(ctx, now)
+	om.compactJournal()
+	if om.cfg.RepairQueue && !om.cfg.CheckQueue && now.Sub(om.lastQueueCheck) >= queueCheckInterval {
+		om.lastQueueCheck = now
+		if err := om.verifyQueue(); err != nil {
+			om.repairQueue(ctx, err, "periodic check")
+		}
+	}
+}
+
+// Check reports whether the manager can serve orders: the queue backend must
+// answer and, once Run has started, its background work must keep running
+func (om *OrderManager) Check(ctx context.Context) error {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	if _, err := om.waiting.Len(ctx); err != nil {
+		return fmt.Errorf("queue: %w", err)
+	}
+	if since := om.clock.Now().Sub(om.lastTick); !om.lastTick.IsZero() && since > stallAfter {
+		return fmt.Errorf("background work last ran %s ago", since.Round(time.Second))
+	}
+	return nil
+}
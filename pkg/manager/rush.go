@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// rushWindow is the period MaxRushesPerHour counts over
+const rushWindow = time.Hour
+
+// RushLimitError is returned by RushOrder when the person has already rushed
+// MaxRushesPerHour orders in the last hour. It wraps ErrRushLimit.
+type RushLimitError struct {
+	By         string
+	Limit      int
+	RetryAfter time.Duration // Until the oldest of those rushes leaves the window
+}
+
+func (e *RushLimitError) Error() string {
+	format, args := e.Message()
+	return fmt.Sprintf(format, args...)
+}
+
+// Message returns the format and arguments of the error text, for translation
+func (e *RushLimitError) Message() (string, []any) {
+	return "%s has rushed %d orders in the last hour, try again in %s", []any{e.By, e.Limit, e.RetryAfter.Round(time.Second)}
+}
+
+func (e *RushLimitError) Unwrap() error { return ErrRushLimit }
+
+// RushOrder moves an order that has not been prepared yet to the front of the
+// queue, whatever the strategy, by giving it queue.RushPriority. by names who
+// rushed it; the token and its edit history record them and the time. An
+// order already rushed is returned unchanged. Orders that have left the queue
+// return ErrNotModifiable. With GroupPriority set the rest of the order's
+// group is rushed too, without counting towards MaxRushesPerHour.
+func (om *OrderManager) RushOrder(ctx context.Context, id string, by string) (*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	token, err := om.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch token.Status {
+	case queue.StatusPreparing, queue.StatusWaitlisted, queue.StatusAwaitingPayment, queue.StatusScheduled, queue.StatusBlocked, queue.StatusOnHold:
+	default:
+		return nil, ErrNotModifiable
+	}
+	if token.Priority == queue.RushPriority {
+		c := token.Clone()
+		om.estimate(ctx, c)
+		return c, nil
+	}
+
+	now := om.clock.Now()
+	recent := om.recentRushes(by, now)
+	if limit := om.cfg.MaxRushesPerHour; limit > 0 && len(recent) >= limit {
+		return nil, &RushLimitError{By: by, Limit: limit, RetryAfter: recent[len(recent)-limit].Add(rushWindow).Sub(now)}
+	}
+
+	prior := token.Clone()
+	token.Edits = append(token.Edits, queue.Edit{
+		Field: "priority", From: strconv.Itoa(token.Priority), To: strconv.Itoa(queue.RushPriority), At: now, By: by,
+	})
+	token.Priority = queue.RushPriority
+	token.RushedAt = &now
+	token.RushedBy = by
+	if token.Status == queue.StatusPreparing {
+		if err := om.waiting.Update(ctx, token); err != nil {
+			*token = *prior
+			if errors.Is(err, ErrNotQueued) {
+				err = ErrNotModifiable
+			}
+			return nil, err
+		}
+	}
+	if om.rushes == nil {
+		om.rushes = make(map[string][]time.Time)
+	}
+	om.rushes[by] = append(recent, now)
+	om.emit(ctx, EventRushed, token)
+	om.boostGroup(ctx, token, by, now)
+	c := token.Clone()
+	om.estimate(ctx, c)
+	return c, nil
+}
+
+// recentRushes returns by's rushes within the window before now, oldest
+// first, dropping older ones; mu must be held
+func (om *OrderManager) recentRushes(by string, now time.Time) []time.Time {
+	times := om.rushes[by]
+	i, _ := slices.BinarySearchFunc(times, now.Add(-rushWindow), func(t, cutoff time.Time) int { return t.Compare(cutoff) })
+	if i == len(times) {
+		delete(om.rushes, by)
+		return nil
+	}
+	times = times[i:]
+	om.rushes[by] = times
+	return times
+}
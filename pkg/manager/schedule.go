@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// schedule holds a pre-order until its release time, the lead time before
+// it is wanted but not before the kitchen opens for it; mu must be held
+func (om *OrderManager) schedule(token *queue.Token, readyAt time.Time) {
+	release := readyAt.Add(-time.Duration(om.cfg.ScheduleLeadTime))
+	if open, _, ok := om.hours.at(readyAt); ok && release.Before(open) {
+		release = open
+	}
+	token.ReadyAt = &readyAt
+	token.ReleaseAt = &release
+	token.Status = queue.StatusScheduled
+
+	i := sort.Search(len(om.scheduled), func(i int) bool {
+		return om.scheduled[i].ReleaseAt.After(release)
+	})
+	om.scheduled = append(om.scheduled, nil)
+	copy(om.scheduled[i+1:], om.scheduled[i:])
+	om.scheduled[i] = token
+}
+
+// releaseScheduled moves every pre-order whose release time has come into
+// the queue, or into the payment hold when it is unpaid and payment is
+// required; mu must be held. A pre-order the queue refuses stays scheduled
+// and is retried on the next tick.
+func (om *OrderManager) releaseScheduled(ctx context.Context, now time.Time) {
+	n := 0
+	for n < len(om.scheduled) && !om.scheduled[n].ReleaseAt.After(now) {
+		token := om.scheduled[n]
+		if om.held(token) {
+			om.holdForPayment(token)
+			om.emit(ctx, EventHeld, token)
+			n++
+			continue
+		}
+		parked, err := om.enqueue(ctx, token)
+		if err != nil {
+			token.Status = queue.StatusScheduled
+			log.Printf("release order %s: %v", token.ID, err)
+			break
+		}
+		if parked {
+			om.emit(ctx, EventBlocked, token)
+		} else {
+			om.emit(ctx, EventReleased, token)
+		}
+		n++
+	}
+	if n > 0 {
+		om.scheduled = append(om.scheduled[:0], om.scheduled[n:]...)
+	}
+}
+
+// unschedule removes token from the scheduled set; mu must be held
+func (om *OrderManager) unschedule(token *queue.Token) {
+	om.scheduled = removeToken(om.scheduled, token)
+}
@@ -0,0 +1,151 @@
+package manager
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"awesomeProject/pkg/queue"
+)
+
+// searchIndex maps the words of each order's item, notes and customer
+// details to the orders they appear in, for OrderFilter.Text. It is kept up
+// to date as the manager emits events; orders placed through another
+// instance sharing the queue are not in it and are matched directly. A term
+// matches the words it begins, found by binary search of the sorted words.
+type searchIndex struct {
+	orders map[string][]string            // Order ID to its words
+	words  map[string]map[string]struct{} // Word to the IDs of the orders it appears in
+	sorted []string                       // The keys of words, in order
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{orders: make(map[string][]string), words: make(map[string]map[string]struct{})}
+}
+
+// add indexes t, replacing what was indexed for it before
+func (ix *searchIndex) add(t *queue.Token) {
+	ix.remove(t.ID)
+	words := searchWords(t)
+	ix.orders[t.ID] = words
+	for _, w := range words {
+		ids, ok := ix.words[w]
+		if !ok {
+			ids = make(map[string]struct{})
+			ix.words[w] = ids
+			i, _ := slices.BinarySearch(ix.sorted, w)
+			ix.sorted = slices.Insert(ix.sorted, i, w)
+		}
+		ids[t.ID] = struct{}{}
+	}
+}
+
+// remove drops the order with the given ID from the index
+func (ix *searchIndex) remove(id string) {
+	for _, w := range ix.orders[id] {
+		ids, ok := ix.words[w]
+		if !ok {
+			continue // Listed twice for this order
+		}
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(ix.words, w)
+			if i, found := slices.BinarySearch(ix.sorted, w); found {
+				ix.sorted = slices.Delete(ix.sorted, i, i+1)
+			}
+		}
+	}
+	delete(ix.orders, id)
+}
+
+// find returns the IDs of the indexed orders matching every one of terms
+func (ix *searchIndex) find(terms []string) map[string]bool {
+	var found map[string]bool
+	for _, term := range terms {
+		ids := make(map[string]bool)
+		i, _ := slices.BinarySearch(ix.sorted, term)
+		for _, w := range ix.sorted[i:] {
+			if !strings.HasPrefix(w, term) {
+				break
+			}
+			for id := range ix.words[w] {
+				if found == nil || found[id] {
+					ids[id] = true
+				}
+			}
+		}
+		if found = ids; len(found) == 0 {
+			break
+		}
+	}
+	return found
+}
+
+// matches reports whether t matches every one of terms, looking it up in
+// found when it is indexed
+func (ix *searchIndex) matches(t *queue.Token, terms []string, found map[string]bool) bool {
+	if _, ok := ix.orders[t.ID]; ok {
+		return found[t.ID]
+	}
+	words := searchWords(t)
+	for _, term := range terms {
+		hit := false
+		for _, w := range words {
+			if hit = strings.HasPrefix(w, term); hit {
+				break
+			}
+		}
+		if !hit {
+			return false
+		}
+	}
+	return true
+}
+
+// searchTerms splits a search query into the terms every match must have a
+// word starting with
+func searchTerms(query string) []string {
+	return splitWords(query)
+}
+
+// minPhoneEnding is the fewest last digits of a phone number that find it
+const minPhoneEnding = 4
+
+// searchWords lists the words an order is found by: those of its item,
+// notes and group, its ID and token number, the delivery platform and the
+// platform's ID, whole and in words, and its phone number given as digits
+// only, whole and by each of its last four or more digits. The pickup code
+// is left out, so a search cannot confirm a guessed one.
+func searchWords(t *queue.Token) []string {
+	words := splitWords(strings.Join([]string{t.Item, t.Notes, t.Group, t.Platform}, " "))
+	words = append(words, strings.ToLower(t.ID))
+	if t.Number != 0 {
+		words = append(words, strconv.Itoa(t.Number))
+	}
+	if s := t.ExternalID; s != "" {
+		words = append(words, strings.ToLower(s))
+		if parts := splitWords(s); len(parts) > 1 {
+			words = append(words, parts...)
+		}
+	}
+	if digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, t.Phone); digits != "" {
+		words = append(words, digits)
+		for i := 1; i <= len(digits)-minPhoneEnding; i++ {
+			words = append(words, digits[i:])
+		}
+	}
+	return words
+}
+
+// splitWords lowercases s and splits it into runs of letters and digits
+func splitWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// deadline is when an order falls due under its SLA target. The order is
+// looked up by ID when it does, as shared queues replace tokens with copies.
+type deadline struct {
+	id string
+	at time.Time
+}
+
+// setSLA gives token, being placed at now, the target of its priority, if
+// any, and watches for it falling due; mu must be held
+func (om *OrderManager) setSLA(token *queue.Token, readyAt, now time.Time) {
+	target, ok := om.cfg.SLATargets[token.Priority]
+	if !ok {
+		return
+	}
+	due := now.Add(time.Duration(target))
+	if !readyAt.IsZero() {
+		due = readyAt
+	}
+	token.SLA = &queue.SLA{Priority: token.Priority, DueAt: due}
+	om.watchSLA(deadline{id: token.ID, at: due})
+}
+
+// watchSLA adds d to the deadlines, kept soonest first; mu must be held
+func (om *OrderManager) watchSLA(d deadline) {
+	i, _ := slices.BinarySearchFunc(om.deadlines, d.at, func(d deadline, at time.Time) int { return d.at.Compare(at) })
+	om.deadlines = slices.Insert(om.deadlines, i, d)
+}
+
+// checkSLA flags the orders that fell due by now without being prepared;
+// mu must be held
+func (om *OrderManager) checkSLA(ctx context.Context, now time.Time) {
+	n := 0
+	for ; n < len(om.deadlines) && !om.deadlines[n].at.After(now); n++ {
+		token, ok := om.byID[om.deadlines[n].id]
+		if !ok || !open(token) {
+			continue
+		}
+		if token.Status == queue.StatusPreparing {
+			// A shared queue holds the current copy, if another instance
+			// has not taken the order
+			queued, err := om.waiting.Get(ctx, token.ID)
+			if err != nil {
+				// Checked again on the next tick
+				log.Printf("sla: order %s: %v", token.ID, err)
+				break
+			}
+			if queued == nil || !open(queued) {
+				continue
+			}
+			token = queued
+			om.byID[token.ID] = token
+		}
+		if err := om.breach(ctx, token, now); err != nil {
+			log.Printf("sla: order %s: %v", token.ID, err)
+			break
+		}
+	}
+	om.deadlines = slices.Delete(om.deadlines, 0, n)
+}
+
+// open reports whether token has an SLA target it has neither met nor
+// breached yet, and can still meet
+func open(token *queue.Token) bool {
+	if token.SLA == nil || token.SLA.BreachedAt != nil || token.PreparedAt != nil {
+		return false
+	}
+	switch token.Status {
+	case queue.StatusCancelled, queue.StatusExpired, queue.StatusPickedUp, queue.StatusVoided:
+		return false
+	}
+	return true
+}
+
+// breach records that token was not prepared in time and announces it; mu
+// must be held
+func (om *OrderManager) breach(ctx context.Context, token *queue.Token, now time.Time) error {
+	prior := token.SLA
+	sla := *prior
+	sla.BreachedAt = &now
+	token.SLA = &sla
+	if token.Status == queue.StatusPreparing {
+		if err := om.waiting.Update(ctx, token); err != nil && !errors.Is(err, ErrNotQueued) {
+			token.SLA = prior
+			return err
+		}
+	}
+	om.emit(ctx, EventBreached, token)
+	return nil
+}
+
+// rewatchSLA rebuilds the deadlines from the restored orders; mu must be
+// held
+func (om *OrderManager) rewatchSLA() {
+	om.deadlines = nil
+	for _, t := range om.byID {
+		if open(t) {
+			om.deadlines = append(om.deadlines, deadline{id: t.ID, at: t.SLA.DueAt})
+		}
+	}
+	slices.SortFunc(om.deadlines, func(a, b deadline) int { return a.at.Compare(b.at) })
+}
@@ -0,0 +1,146 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// SnapshotVersion is the Snapshot format this package writes and reads
+const SnapshotVersion = 1
+
+// Snapshot is the manager's complete state: every order it holds, grouped as
+// the manager holds them, and the counters that continue after them. It moves
+// a running kitchen between hosts, or a production problem onto a laptop.
+type Snapshot struct {
+	Version int       `json:"version"`
+	TakenAt time.Time `json:"takenAt"`
+
+	Counter   int       `json:"counter"`   // Last order ID handed out
+	Daily     int       `json:"daily"`     // Last daily token number handed out
+	DayOpened time.Time `json:"dayOpened"` // When the current business day began
+
+	// The groups are for reading; RestoreSnapshot places each order by its
+	// status. Waiting orders are listed in preparation order.
+	Waiting    []*queue.Token `json:"waiting"`
+	Scheduled  []*queue.Token `json:"scheduled"`
+	Waitlist   []*queue.Token `json:"waitlist"`
+	Blocked    []*queue.Token `json:"blocked"`
+	OnHold     []*queue.Token `json:"onHold"`
+	Unpaid     []*queue.Token `json:"unpaid"`
+	InProgress []*queue.Token `json:"inProgress"`
+	Prepared   []*queue.Token `json:"prepared"`
+	Closed     []*queue.Token `json:"closed"`
+
+	// Pickup codes by order ID, as the orders' own JSON leaves them out
+	PickupCodes map[string]string `json:"pickupCodes,omitempty"`
+
+	// Recent prepare times per station, for capacity and ready estimates
+	PrepTimes map[string][]time.Time `json:"prepTimes,omitempty"`
+
+	// Items marked unavailable, whether or not any order is waiting for them
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+// Snapshot returns a copy of the manager's state
+func (om *OrderManager) Snapshot(ctx context.Context) (*Snapshot, error) {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	waiting, err := om.waiting.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(waiting, queueOrder)
+	prepTimes := make(map[string][]time.Time, len(om.prepTimes))
+	for station, times := range om.prepTimes {
+		prepTimes[station] = slices.Clone(times)
+	}
+	snap := &Snapshot{
+		Version:     SnapshotVersion,
+		TakenAt:     om.clock.Now(),
+		Counter:     om.counter,
+		Daily:       om.daily,
+		DayOpened:   om.lastClose,
+		Waiting:     cloneAll(waiting),
+		Scheduled:   cloneAll(om.scheduled),
+		Waitlist:    cloneAll(om.waitlist),
+		Blocked:     cloneAll(om.blocked),
+		OnHold:      cloneAll(om.onHold),
+		Unpaid:      cloneAll(om.unpaid),
+		InProgress:  cloneAll(om.inProgress),
+		Prepared:    cloneAll(om.prepared),
+		Closed:      cloneAll(om.closed),
+		PrepTimes:   prepTimes,
+		Unavailable: slices.Sorted(maps.Keys(om.unavailable)),
+		PickupCodes: make(map[string]string),
+	}
+	for _, t := range snap.tokens() {
+		if t.PickupCode != "" {
+			snap.PickupCodes[t.ID] = t.PickupCode
+		}
+	}
+	return snap, nil
+}
+
+// tokens returns every order of snap, group by group
+func (snap *Snapshot) tokens() []*queue.Token {
+	return slices.Concat(snap.Waiting, snap.Scheduled, snap.Waitlist, snap.Blocked, snap.OnHold, snap.Unpaid, snap.InProgress, snap.Prepared, snap.Closed)
+}
+
+// RestoreSnapshot replaces the manager's state with snap, as Restore does for
+// a list of orders, and carries on its counters. Errors in snap wrap
+// ErrInvalidSnapshot and leave the manager unchanged. No events are emitted,
+// so connected displays should reload.
+func (om *OrderManager) RestoreSnapshot(snap *Snapshot) error {
+	if snap.Version != SnapshotVersion {
+		return fmt.Errorf("%w: version %d, want %d", ErrInvalidSnapshot, snap.Version, SnapshotVersion)
+	}
+	tokens := snap.tokens()
+	if slices.Contains(tokens, nil) {
+		return fmt.Errorf("%w: null order", ErrInvalidSnapshot)
+	}
+	for _, t := range tokens {
+		if code, ok := snap.PickupCodes[t.ID]; ok {
+			t.PickupCode = code
+		}
+	}
+	st, err := restoredState(tokens)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	// IDs never go backwards, even past orders a day close archived
+	st.counter = max(st.counter, snap.Counter)
+	st.daily = snap.Daily
+	for _, item := range snap.Unavailable {
+		st.unavailable[itemKey(item)] = true
+	}
+
+	om.mu.Lock()
+	defer om.unlock()
+	om.install(st)
+	if !snap.DayOpened.IsZero() {
+		om.lastClose = snap.DayOpened
+	}
+	om.prepTimes = maps.Clone(snap.PrepTimes)
+	return nil
+}
+
+// queueOrder compares waiting tokens in the order the queue gives them out
+func queueOrder(a, b *queue.Token) int {
+	if queue.Before(a, b) {
+		return -1
+	}
+	return 1
+}
+
+func cloneAll(tokens []*queue.Token) []*queue.Token {
+	out := make([]*queue.Token, len(tokens))
+	for i, t := range tokens {
+		out[i] = t.Clone()
+	}
+	return out
+}
@@ -0,0 +1,229 @@
+package manager
+
+import (
+	"maps"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// Strategy names accepted in Config.Strategy
+const (
+	StrategyPriority   = "priority"   // Strict priority, then oldest first
+	StrategyWeighted   = "weighted"   // Weighted fair share between priority levels
+	StrategySJF        = "sjf"        // Shortest item prep time first
+	StrategyRoundRobin = "roundRobin" // Rotate between order types
+	StrategyDerived    = "derived"    // Highest priority derived from order value and complexity
+)
+
+// Strategies lists the built-in strategy names
+var Strategies = []string{StrategyPriority, StrategyWeighted, StrategySJF, StrategyRoundRobin, StrategyDerived}
+
+// Strategy decides which waiting order PrepareOrder takes next. Next is called
+// with mu held and may keep state between calls, such as whose turn it is.
+type Strategy interface {
+	// Next picks the token to prepare from waiting, which is non-empty and
+	// in no particular order. The manager then removes it from the queue.
+	Next(waiting []*queue.Token) *queue.Token
+}
+
+// WithStrategy overrides the strategy named in the config
+func WithStrategy(s Strategy) Option {
+	return func(om *OrderManager) { om.strategy = s }
+}
+
+// newStrategy builds the strategy named in cfg
+func newStrategy(cfg Config) Strategy {
+	switch cfg.Strategy {
+	case StrategyWeighted:
+		return &WeightedFair{Weights: cfg.PriorityWeights}
+	case StrategySJF:
+		return &ShortestFirst{PrepTime: cfg.prepTime}
+	case StrategyRoundRobin:
+		return &RoundRobin{}
+	case StrategyDerived:
+		return &Derived{Score: cfg.PriorityFormula.score(cfg)}
+	}
+	return StrictPriority{}
+}
+
+// prepTime is the configured time to prepare item
+func (c Config) prepTime(item string) time.Duration {
+	if d, ok := c.ItemPrepTimes[item]; ok {
+		return time.Duration(d)
+	}
+	return time.Duration(c.DefaultPrepTime)
+}
+
+// first returns the token in list prepared first under strict priority
+func first(list []*queue.Token) *queue.Token {
+	best := list[0]
+	for _, t := range list[1:] {
+		if queue.Before(t, best) {
+			best = t
+		}
+	}
+	return best
+}
+
+// StrictPriority always takes the highest priority order, oldest first. The
+// manager pops the queue directly for it instead of calling Next.
+type StrictPriority struct{}
+
+func (StrictPriority) Next(waiting []*queue.Token) *queue.Token {
+	return first(waiting)
+}
+
+// WeightedFair shares preparation between priority levels in proportion to
+// their weights, so low priorities progress under a steady stream of urgent
+// orders. Within a level orders are taken oldest first. Levels without a
+// weight get 1/(priority+1).
+type WeightedFair struct {
+	Weights map[int]float64
+
+	vtime  float64         // Virtual time: the latest start tag picked
+	finish map[int]float64 // Finish tag of each level's last pick
+	head   map[int]float64 // Finish tag of each waiting level's next order
+}
+
+func (w *WeightedFair) weight(priority int) float64 {
+	if v, ok := w.Weights[priority]; ok && v > 0 {
+		return v
+	}
+	return 1 / float64(max(priority, 0)+1)
+}
+
+// clone returns a copy of w that chooses as w would, without changing w
+func (w *WeightedFair) clone() *WeightedFair {
+	return &WeightedFair{Weights: w.Weights, vtime: w.vtime, finish: maps.Clone(w.finish), head: maps.Clone(w.head)}
+}
+
+func (w *WeightedFair) Next(waiting []*queue.Token) *queue.Token {
+	if w.finish == nil {
+		w.finish = make(map[int]float64)
+		w.head = make(map[int]float64)
+	}
+	levels := make(map[int]*queue.Token)
+	for _, t := range waiting {
+		if cur, ok := levels[t.Priority]; !ok || queue.Before(t, cur) {
+			levels[t.Priority] = t
+		}
+	}
+	for p := range w.head {
+		if levels[p] == nil {
+			delete(w.head, p)
+		}
+	}
+
+	// Tag each level's next order when the level becomes busy; a level
+	// returning from idle starts at the current virtual time rather than
+	// cashing in the turns it did not need. The smallest finish tag goes next.
+	var pick *queue.Token
+	for p, t := range levels {
+		if _, ok := w.head[p]; !ok {
+			w.head[p] = max(w.finish[p], w.vtime) + 1/w.weight(p)
+		}
+		if pick == nil || w.head[p] < w.head[pick.Priority] ||
+			(w.head[p] == w.head[pick.Priority] && p < pick.Priority) {
+			pick = t
+		}
+	}
+	p := pick.Priority
+	w.vtime = max(w.vtime, w.head[p]-1/w.weight(p))
+	w.finish[p] = w.head[p]
+	delete(w.head, p)
+	return pick
+}
+
+// ShortestFirst takes the order whose item is quickest to prepare, breaking
+// ties by priority and age. Long items can wait indefinitely while quick ones
+// keep arriving.
+type ShortestFirst struct {
+	PrepTime func(item string) time.Duration
+}
+
+func (s *ShortestFirst) Next(waiting []*queue.Token) *queue.Token {
+	best, bestTime := waiting[0], s.PrepTime(waiting[0].Item)
+	for _, t := range waiting[1:] {
+		d := s.PrepTime(t.Item)
+		if d < bestTime || (d == bestTime && queue.Before(t, best)) {
+			best, bestTime = t, d
+		}
+	}
+	return best
+}
+
+// RoundRobin rotates between order types, taking the highest priority order
+// of each type in turn, so takeaway and delivery keep moving during a
+// dine-in rush. Orders without a type form their own group.
+type RoundRobin struct {
+	next int // Index into the rotation of the type whose turn it is
+}
+
+// rotation is the order types in turn order, ending with untyped orders
+var rotation = append(append([]string(nil), queue.OrderTypes...), "")
+
+func (r *RoundRobin) Next(waiting []*queue.Token) *queue.Token {
+	byType := make(map[string][]*queue.Token)
+	for _, t := range waiting {
+		byType[t.OrderType] = append(byType[t.OrderType], t)
+	}
+	for i := range rotation {
+		idx := (r.next + i) % len(rotation)
+		if group := byType[rotation[idx]]; len(group) > 0 {
+			r.next = idx + 1
+			return first(group)
+		}
+	}
+	// Types outside the rotation, which validation normally prevents
+	return first(waiting)
+}
+
+// Derived takes the order with the highest effective priority, as scored by
+// Score, instead of by the priority the caller gave. Ties go by priority and
+// age.
+type Derived struct {
+	Score func(t *queue.Token) float64
+}
+
+func (d *Derived) Next(waiting []*queue.Token) *queue.Token {
+	best, bestScore := waiting[0], d.Score(waiting[0])
+	for _, t := range waiting[1:] {
+		s := d.Score(t)
+		if s > bestScore || (s == bestScore && queue.Before(t, best)) {
+			best, bestScore = t, s
+		}
+	}
+	return best
+}
+
+// PriorityFormula weighs the signals the derived strategy scores orders by;
+// the highest score is prepared first. Signals left at zero weight are
+// ignored.
+type PriorityFormula struct {
+	// Value weighs the order total, the item's price in ItemPrices times the
+	// quantity
+	Value float64 `json:"value"`
+
+	// Items weighs the quantity ordered
+	Items float64 `json:"items"`
+
+	// Complexity weighs the item's score in ItemComplexity
+	Complexity float64 `json:"complexity"`
+
+	// Priority weighs the priority the caller gave, counting against the
+	// later priorities, which have the larger numbers
+	Priority float64 `json:"priority"`
+}
+
+// score returns the effective priority of orders under f, with item prices
+// and complexity from cfg
+func (f PriorityFormula) score(cfg Config) func(t *queue.Token) float64 {
+	return func(t *queue.Token) float64 {
+		qty := float64(max(t.Quantity, 1))
+		return f.Value*cfg.ItemPrices[t.Item]*qty +
+			f.Items*qty +
+			f.Complexity*cfg.ItemComplexity[t.Item] -
+			f.Priority*float64(t.Priority)
+	}
+}
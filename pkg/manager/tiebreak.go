@@ -0,0 +1,42 @@
+package manager
+
+import (
+	"math/rand/v2"
+
+	"awesomeProject/pkg/queue"
+)
+
+// Tie-break policies accepted in Config.TieBreak, for orders of the same
+// priority placed at the same instant, as a batch of imports or a burst of
+// kiosk orders can be
+const (
+	TieBreakSequence = "sequence" // In the order they were placed
+	TieBreakSmallest = "smallest" // Smallest quantity first, so quick orders are not held up
+	TieBreakRandom   = "random"   // In a random order drawn when each is placed, fair to every client
+)
+
+// TieBreaks lists every tie-break policy
+var TieBreaks = []string{TieBreakSequence, TieBreakSmallest, TieBreakRandom}
+
+// stamp numbers token as the next order placed and keys it by the
+// tie-break policy; mu must be held
+func (om *OrderManager) stamp(token *queue.Token) {
+	om.seq++
+	token.Seq = om.seq
+	om.rekey(token)
+}
+
+// rekey sets token's tie-break key after what it is keyed by changed; mu
+// must be held
+func (om *OrderManager) rekey(token *queue.Token) {
+	switch om.cfg.TieBreak {
+	case TieBreakSmallest:
+		token.TieBreak = int64(token.Quantity)
+	case TieBreakRandom:
+		if token.TieBreak == 0 {
+			token.TieBreak = rand.Int64N(1<<62) + 1
+		}
+	default:
+		token.TieBreak = 0
+	}
+}
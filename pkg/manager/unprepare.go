@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"context"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// UnprepareOrder undoes PrepareOrder: the token goes back into the queue with
+// its original priority and timestamp, so it regains its old position. It is
+// only allowed within the configured grace window after preparing.
+func (om *OrderManager) UnprepareOrder(ctx context.Context, id string) (*queue.Token, error) {
+	om.mu.Lock()
+	defer om.unlock()
+	token, ok := om.byID[id]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	if token.Status != queue.StatusPrepared {
+		return nil, ErrNotPrepared
+	}
+	preparedAt := *token.PreparedAt
+	if om.clock.Now().Sub(preparedAt) > time.Duration(om.cfg.UnprepareGrace) {
+		return nil, ErrGraceExpired
+	}
+	if err := om.unprepare(ctx, token); err != nil {
+		return nil, err
+	}
+	return token.Clone(), nil
+}
+
+// unprepare puts a prepared token back into the queue, where it waits for a
+// cook to claim it again; mu must be held
+func (om *OrderManager) unprepare(ctx context.Context, token *queue.Token) error {
+	preparedAt, claimedAt := token.PreparedAt, token.ClaimedAt
+	token.Status = queue.StatusPreparing
+	token.PreparedAt, token.ClaimedAt = nil, nil
+	if err := om.waiting.Push(ctx, token); err != nil {
+		token.Status = queue.StatusPrepared
+		token.PreparedAt, token.ClaimedAt = preparedAt, claimedAt
+		return err
+	}
+	om.prepared = removeToken(om.prepared, token)
+	om.emit(ctx, EventUnprepared, token)
+	return nil
+}
@@ -0,0 +1,22 @@
+package manager
+
+import "awesomeProject/pkg/queue"
+
+// VersionError is returned when an order is changed on condition that it is
+// still at a version it has since moved on from
+type VersionError struct {
+	Current *queue.Token // Copy of the order as it is now
+}
+
+func (e *VersionError) Error() string { return ErrStaleVersion.Error() }
+
+func (e *VersionError) Unwrap() error { return ErrStaleVersion }
+
+// checkVersion reports a *VersionError when version is set and token is no
+// longer at it; mu must be held
+func checkVersion(token *queue.Token, version string) error {
+	if version == "" || version == token.Digest() {
+		return nil
+	}
+	return &VersionError{Current: token.Clone()}
+}
@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"context"
+	"slices"
+
+	"awesomeProject/pkg/queue"
+)
+
+// DefaultVoidReasons are the reason codes a void may give when VoidReasons
+// is not configured
+var DefaultVoidReasons = []string{"wrong_order", "customer_complaint", "quality", "duplicate", "test", "other"}
+
+// VoidRequest says why an order is voided and by whom
+type VoidRequest struct {
+	Reason string // One of Config.VoidReasons
+	Note   string
+	By     string // Manager voiding it
+
+	// Refund marks the order's payment refunded, which it must have been
+	// paid for
+	Refund bool
+}
+
+// VoidOrder takes a picked up, expired or cancelled order off the books, so
+// the day's takings reconcile with the till: it becomes voided, recording
+// the reason, who voided it and the status it had, and with Refund set its
+// payment becomes refunded. Orders not yet completed return ErrNotVoidable,
+// reasons not configured ErrVoidReason, and refunds of unpaid orders
+// ErrPaymentTransition.
+func (om *OrderManager) VoidOrder(ctx context.Context, id string, req VoidRequest) (*queue.Token, error) {
+	if !slices.Contains(om.cfg.VoidReasons, req.Reason) {
+		return nil, ErrVoidReason
+	}
+	om.mu.Lock()
+	defer om.unlock()
+	token, err := om.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch token.Status {
+	case queue.StatusPickedUp, queue.StatusExpired, queue.StatusCancelled:
+	default:
+		return nil, ErrNotVoidable
+	}
+	if req.Refund && token.Payment != queue.PaymentPaid {
+		return nil, ErrPaymentTransition
+	}
+
+	now := om.clock.Now()
+	token.Void = &queue.Void{Reason: req.Reason, Note: req.Note, By: req.By, From: token.Status, Refund: req.Refund, At: now}
+	token.Edits = append(token.Edits, queue.Edit{Field: "status", From: token.Status, To: queue.StatusVoided, At: now, By: req.By})
+	token.Status = queue.StatusVoided
+	if req.Refund {
+		token.Payment = queue.PaymentRefunded
+		token.RefundedAt = &now
+	}
+	om.emit(ctx, EventVoided, token)
+	return token.Clone(), nil
+}
@@ -0,0 +1,140 @@
+// Package money works with amounts of money held in a currency's minor
+// units, such as cents, so totals add up exactly: converting menu prices,
+// rounding to the minor unit, working out tax and formatting amounts.
+package money
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Rounding modes, for amounts falling between two minor units
+const (
+	HalfUp   = "halfUp"   // Halves away from zero, as most tills round
+	HalfEven = "halfEven" // Halves to the even unit, so rounding errors cancel out
+	Down     = "down"     // Towards zero
+	Up       = "up"       // Away from zero
+)
+
+// Roundings lists the rounding modes
+var Roundings = []string{HalfUp, HalfEven, Down, Up}
+
+// digits gives the minor units of the ISO 4217 currencies that do not have
+// two; every other currency has two
+var digits = map[string]int{
+	"BHD": 3, "CLP": 0, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0,
+	"KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0, "TND": 3, "UGX": 0, "VND": 0,
+	"XAF": 0, "XOF": 0,
+}
+
+// Valid reports whether code looks like an ISO 4217 code: three capital
+// letters
+func Valid(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Digits returns the number of decimal digits in currency's minor unit
+func Digits(currency string) int {
+	if d, ok := digits[currency]; ok {
+		return d
+	}
+	return 2
+}
+
+// FromMajor converts price, in whole units of currency such as euros, to
+// minor units, rounding as given
+func FromMajor(price float64, currency, rounding string) int64 {
+	// Decimal prices are not exact as floats: 0.29 is a hair under, so
+	// round from the shortest decimal that reads back as the same float
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(price, 'f', -1, 64))
+	if !ok {
+		return 0 // NaN or infinite
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(Digits(currency))), nil)
+	r.Mul(r, new(big.Rat).SetInt(scale))
+	return divide(r.Num(), r.Denom(), rounding)
+}
+
+// Tax returns the tax on amount at rate percent, rounded as given. With
+// included set amount already holds the tax, as with VAT shown in menu
+// prices, and the tax is the part of it above the net price; otherwise it
+// is to be added on top.
+func Tax(amount int64, rate float64, included bool, rounding string) int64 {
+	if rate == 0 || amount == 0 {
+		return 0
+	}
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(rate, 'f', -1, 64))
+	if !ok {
+		return 0
+	}
+	r.Quo(r, big.NewRat(100, 1))
+	a := new(big.Rat).SetInt64(amount)
+	if included {
+		// Net is amount / (1 + rate); rounding the net keeps net plus tax
+		// equal to amount
+		net := new(big.Rat).Quo(a, r.Add(r, big.NewRat(1, 1)))
+		return amount - divide(net.Num(), net.Denom(), rounding)
+	}
+	tax := a.Mul(a, r)
+	return divide(tax.Num(), tax.Denom(), rounding)
+}
+
+// divide returns num / den rounded as given; den is positive
+func divide(num, den *big.Int, rounding string) int64 {
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int)) // Truncated towards zero
+	if m.Sign() != 0 {
+		away := false
+		switch rounding {
+		case Down:
+		case Up:
+			away = true
+		default:
+			// Compare twice the remainder with the divisor
+			c := new(big.Int).Abs(m)
+			c.Lsh(c, 1)
+			switch c.Cmp(den) {
+			case 1:
+				away = true
+			case 0:
+				away = rounding != HalfEven || q.Bit(0) == 1
+			}
+		}
+		if away {
+			q.Add(q, big.NewInt(int64(num.Sign())))
+		}
+	}
+	return q.Int64()
+}
+
+// Format writes amount, in minor units of currency, as a decimal followed
+// by the currency code, like 12.50 EUR
+func Format(amount int64, currency string) string {
+	return Decimal(amount, currency) + " " + currency
+}
+
+// Decimal writes amount, in minor units of currency, as a decimal in whole
+// units, like 12.50, for spreadsheets to read
+func Decimal(amount int64, currency string) string {
+	d := Digits(currency)
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	s := strconv.FormatInt(amount, 10)
+	if d > 0 {
+		if len(s) <= d {
+			s = strings.Repeat("0", d-len(s)+1) + s
+		}
+		s = s[:len(s)-d] + "." + s[len(s)-d:]
+	}
+	return sign + s
+}
@@ -0,0 +1,72 @@
+package money
+
+import "testing"
+
+func TestFromMajor(t *testing.T) {
+	for _, tc := range []struct {
+		price    float64
+		currency string
+		rounding string
+		want     int64
+	}{
+		{0.29, "EUR", HalfUp, 29},
+		{4.5, "USD", HalfUp, 450},
+		{1.005, "USD", HalfUp, 101},
+		{1.005, "USD", HalfEven, 100},
+		{1.015, "USD", HalfEven, 102},
+		{1.009, "USD", Down, 100},
+		{1.001, "USD", Up, 101},
+		{450, "JPY", HalfUp, 450},
+		{1.2345, "KWD", HalfUp, 1235},
+		{-2.5, "JPY", HalfUp, -3},
+	} {
+		if got := FromMajor(tc.price, tc.currency, tc.rounding); got != tc.want {
+			t.Errorf("FromMajor(%v, %s, %s) = %d, want %d", tc.price, tc.currency, tc.rounding, got, tc.want)
+		}
+	}
+}
+
+func TestTax(t *testing.T) {
+	for _, tc := range []struct {
+		amount   int64
+		rate     float64
+		included bool
+		rounding string
+		want     int64
+	}{
+		{1000, 8.875, false, HalfUp, 89}, // 88.75
+		{1000, 8.875, false, Down, 88},
+		{250, 7, false, HalfUp, 18},   // 17.5
+		{250, 7, false, HalfEven, 18}, // 17.5
+		{350, 7, false, HalfEven, 24}, // 24.5
+		{1190, 19, true, HalfUp, 190}, // Net 1000
+		{450, 20, true, HalfUp, 75},   // Net 375
+		{999, 10, true, HalfUp, 91},   // Net 908.18
+		{1000, 0, false, HalfUp, 0},
+	} {
+		if got := Tax(tc.amount, tc.rate, tc.included, tc.rounding); got != tc.want {
+			t.Errorf("Tax(%d, %v, %v, %s) = %d, want %d", tc.amount, tc.rate, tc.included, tc.rounding, got, tc.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		amount   int64
+		currency string
+		want     string
+	}{
+		{1250, "EUR", "12.50 EUR"},
+		{5, "USD", "0.05 USD"},
+		{-75, "GBP", "-0.75 GBP"},
+		{1200, "JPY", "1200 JPY"},
+		{1500, "KWD", "1.500 KWD"},
+	} {
+		if got := Format(tc.amount, tc.currency); got != tc.want {
+			t.Errorf("Format(%d, %s) = %q, want %q", tc.amount, tc.currency, got, tc.want)
+		}
+	}
+	if !Valid("EUR") || Valid("eur") || Valid("EURO") {
+		t.Error("Valid accepts the wrong codes")
+	}
+}
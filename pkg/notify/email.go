@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// EmailConfig configures an SMTP mail server
+type EmailConfig struct {
+	Addr     string `json:"addr"` // host:port, e.g. smtp.example.com:587
+	From     string `json:"from"`
+	Username string `json:"username"` // Empty to send without logging in
+	Password string `json:"password"`
+}
+
+// EmailProvider sends messages as plain-text mail, upgrading the connection
+// with STARTTLS when the server offers it
+type EmailProvider struct {
+	cfg EmailConfig
+}
+
+// NewEmailProvider sends through the server cfg names
+func NewEmailProvider(cfg EmailConfig) *EmailProvider {
+	return &EmailProvider{cfg: cfg}
+}
+
+func (p *EmailProvider) Name() string { return "email" }
+
+func (p *EmailProvider) Send(ctx context.Context, m Message) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	host, _, _ := net.SplitHostPort(p.cfg.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(nil); err != nil {
+			return err
+		}
+	}
+	if p.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", p.cfg.Username, p.cfg.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(p.cfg.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(m.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(mail(p.cfg.From, m, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// mail formats m as a message sent at now
+func mail(from string, m Message, now time.Time) []byte {
+	var b bytes.Buffer
+	header := func(name, value string) {
+		// Header values must not start new headers
+		value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+	header("From", from)
+	header("To", m.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
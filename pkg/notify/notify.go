@@ -0,0 +1,132 @@
+// Package notify tells customers when their order is ready, and holds the
+// providers other packages send staff and owners messages through.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// Message is one notification to deliver
+type Message struct {
+	To      string // Phone number or device token, depending on the provider
+	Subject string
+	Body    string
+}
+
+// Provider delivers messages over one channel
+type Provider interface {
+	Name() string
+	Send(ctx context.Context, m Message) error
+}
+
+// Config selects the providers; a nil section disables that channel
+type Config struct {
+	SMS     *SMSConfig      `json:"sms"`
+	Push    *PushConfig     `json:"push"`
+	Email   *EmailConfig    `json:"email"`   // For reports to owners; customers are not emailed
+	Message string          `json:"message"` // fmt template taking the daily token number and item
+	Timeout config.Duration `json:"timeout"`
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		Message: "Your order #%d (%s) is ready for pickup",
+		Timeout: config.Duration(10 * time.Second),
+	}
+}
+
+// queueSize bounds the messages waiting for delivery
+const queueSize = 256
+
+type job struct {
+	provider Provider
+	msg      Message
+	tokenID  string
+}
+
+// Notifier sends a message to every opted-in customer whose order is prepared
+type Notifier struct {
+	sms     Provider
+	push    Provider
+	message string
+	timeout time.Duration
+	jobs    chan job
+	done    chan struct{}
+}
+
+// New builds a Notifier from cfg, sending through hc or http.DefaultClient
+// when it is nil. Call Attach to start receiving events and Close to stop.
+func New(cfg Config, hc *http.Client) *Notifier {
+	n := &Notifier{
+		message: cfg.Message,
+		timeout: time.Duration(cfg.Timeout),
+		jobs:    make(chan job, queueSize),
+		done:    make(chan struct{}),
+	}
+	if cfg.SMS != nil {
+		n.sms = NewSMSProvider(*cfg.SMS, hc)
+	}
+	if cfg.Push != nil {
+		n.push = NewPushProvider(*cfg.Push, hc)
+	}
+	go n.run()
+	return n
+}
+
+// Attach subscribes the notifier to the manager's events, or a bus relaying them
+func (n *Notifier) Attach(p manager.Publisher) {
+	p.Subscribe(n.handle)
+}
+
+// Close stops accepting messages and waits for queued ones to be sent
+func (n *Notifier) Close() {
+	close(n.jobs)
+	<-n.done
+}
+
+func (n *Notifier) handle(e manager.Event) {
+	if e.Type != manager.EventPrepared {
+		return
+	}
+	t := e.Token
+	if t.Phone != "" && n.sms != nil {
+		n.enqueue(n.sms, t.Phone, t)
+	}
+	if t.DeviceToken != "" && n.push != nil {
+		n.enqueue(n.push, t.DeviceToken, t)
+	}
+}
+
+// enqueue hands a message to the sender without blocking the manager
+func (n *Notifier) enqueue(p Provider, to string, t *queue.Token) {
+	m := Message{
+		To:      to,
+		Subject: fmt.Sprintf("Order #%d ready", t.Number),
+		Body:    fmt.Sprintf(n.message, t.Number, t.Item),
+	}
+	select {
+	case n.jobs <- job{provider: p, msg: m, tokenID: t.ID}:
+	default:
+		log.Printf("notify: queue full, dropping %s message for order %s", p.Name(), t.ID)
+	}
+}
+
+func (n *Notifier) run() {
+	defer close(n.done)
+	for j := range n.jobs {
+		ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+		if err := j.provider.Send(ctx, j.msg); err != nil {
+			log.Printf("notify: %s message for order %s failed: %v", j.provider.Name(), j.tokenID, err)
+		}
+		cancel()
+	}
+}
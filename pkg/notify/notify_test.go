@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestSMSProvider(t *testing.T) {
+	var got *http.Request
+	var form url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		r.ParseForm()
+		form = r.PostForm
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	p := NewSMSProvider(SMSConfig{BaseURL: srv.URL + "/", AccountSID: "AC 1", AuthToken: "secret", From: "+15550000"}, srv.Client())
+	if err := p.Send(context.Background(), Message{To: "+15550100", Body: "Order #7 & more"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != http.MethodPost || got.URL.EscapedPath() != "/Accounts/AC%201/Messages.json" {
+		t.Errorf("request = %s %s", got.Method, got.URL.EscapedPath())
+	}
+	if ct := got.Header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if user, pass, ok := got.BasicAuth(); !ok || user != "AC 1" || pass != "secret" {
+		t.Errorf("basic auth = %q %q %t", user, pass, ok)
+	}
+	if form.Get("To") != "+15550100" || form.Get("From") != "+15550000" || form.Get("Body") != "Order #7 & more" {
+		t.Errorf("form = %v", form)
+	}
+}
+
+func TestPushProvider(t *testing.T) {
+	var auth, ct string
+	var body map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, ct = r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer srv.Close()
+
+	p := NewPushProvider(PushConfig{URL: srv.URL, BearerToken: "tok"}, srv.Client())
+	if err := p.Send(context.Background(), Message{To: "device-1", Subject: "Order #7 ready", Body: "Come and get it"}); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer tok" || ct != "application/json" {
+		t.Errorf("Authorization = %q, Content-Type = %q", auth, ct)
+	}
+	if body["deviceToken"] != "device-1" || body["title"] != "Order #7 ready" || body["body"] != "Come and get it" || len(body) != 3 {
+		t.Errorf("body = %v", body)
+	}
+
+	// Without a token no Authorization is sent
+	p = NewPushProvider(PushConfig{URL: srv.URL}, srv.Client())
+	if err := p.Send(context.Background(), Message{To: "device-1"}); err != nil || auth != "" {
+		t.Errorf("Send = %v, Authorization = %q", err, auth)
+	}
+}
+
+func TestProviderError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown device", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	err := NewPushProvider(PushConfig{URL: srv.URL}, srv.Client()).Send(context.Background(), Message{To: "x"})
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "unknown device") {
+		t.Errorf("Send = %v, want the status and body", err)
+	}
+}
+
+func TestMail(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	got := string(mail("kitchen@example.com", Message{
+		To:      "owner@example.com\r\nBcc: someone@example.com",
+		Subject: "Café report",
+		Body:    "Line one\nLine two",
+	}, now))
+	for _, want := range []string{
+		"From: kitchen@example.com\r\n",
+		"To: owner@example.com  Bcc: someone@example.com\r\n",
+		"Subject: =?utf-8?q?Caf=C3=A9_report?=\r\n",
+		"Date: Fri, 01 Mar 2024 12:00:00 +0000\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\nLine one\r\nLine two",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("mail lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "Bcc:") != 1 || strings.Contains(got, "\nBcc:") {
+		t.Errorf("header injected:\n%s", got)
+	}
+}
+
+// recorder is a Provider that keeps what it is sent
+type recorder struct {
+	sent []Message
+}
+
+func (p *recorder) Name() string { return "test" }
+
+func (p *recorder) Send(ctx context.Context, m Message) error {
+	p.sent = append(p.sent, m)
+	return nil
+}
+
+func TestNotifier(t *testing.T) {
+	p := &recorder{}
+	n := New(DefaultConfig(), nil)
+	n.sms, n.push = p, p
+	n.handle(manager.Event{Type: manager.EventCreated, Token: &queue.Token{ID: "1", Number: 6, Phone: "+15550100"}})
+	n.handle(manager.Event{Type: manager.EventPrepared, Token: &queue.Token{ID: "2", Number: 7, Item: "cake", Phone: "+15550100", DeviceToken: "device-1"}})
+	n.handle(manager.Event{Type: manager.EventPrepared, Token: &queue.Token{ID: "3", Number: 8, Item: "tea"}})
+	n.Close()
+
+	if len(p.sent) != 2 || p.sent[0].To != "+15550100" || p.sent[1].To != "device-1" {
+		t.Fatalf("sent = %+v", p.sent)
+	}
+	if m := p.sent[0]; m.Subject != "Order #7 ready" || m.Body != "Your order #7 (cake) is ready for pickup" {
+		t.Errorf("message = %+v", m)
+	}
+}
+
+func TestNotifierQueueFull(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	// Nothing is sending yet, so the second message finds the queue full
+	p := &recorder{}
+	n := &Notifier{sms: p, message: DefaultConfig().Message, timeout: time.Second, jobs: make(chan job, 1), done: make(chan struct{})}
+	for _, id := range []string{"1", "2"} {
+		n.handle(manager.Event{Type: manager.EventPrepared, Token: &queue.Token{ID: id, Phone: "+15550100"}})
+	}
+	go n.run()
+	n.Close()
+
+	if len(p.sent) != 1 {
+		t.Errorf("sent %d messages, want 1", len(p.sent))
+	}
+	if !strings.Contains(logged.String(), "queue full, dropping test message for order 2") {
+		t.Errorf("log = %q", logged.String())
+	}
+}
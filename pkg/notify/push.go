@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// PushConfig configures a generic HTTP push gateway
+type PushConfig struct {
+	URL         string `json:"url"`
+	BearerToken string `json:"bearerToken"`
+}
+
+// PushProvider posts {"deviceToken", "title", "body"} as JSON to a push gateway
+type PushProvider struct {
+	cfg    PushConfig
+	client *http.Client
+}
+
+// NewPushProvider sends through hc, or http.DefaultClient when it is nil
+func NewPushProvider(cfg PushConfig, hc *http.Client) *PushProvider {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &PushProvider{cfg: cfg, client: hc}
+}
+
+func (p *PushProvider) Name() string { return "push" }
+
+func (p *PushProvider) Send(ctx context.Context, m Message) error {
+	body, err := json.Marshal(struct {
+		DeviceToken string `json:"deviceToken"`
+		Title       string `json:"title"`
+		Body        string `json:"body"`
+	}{m.To, m.Subject, m.Body})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.cfg.BearerToken)
+	}
+	return do(p.client, req)
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SMSConfig configures a Twilio-style SMS gateway
+type SMSConfig struct {
+	BaseURL    string `json:"baseURL"` // e.g. https://api.twilio.com/2010-04-01
+	AccountSID string `json:"accountSID"`
+	AuthToken  string `json:"authToken"`
+	From       string `json:"from"`
+}
+
+// SMSProvider posts messages to {BaseURL}/Accounts/{AccountSID}/Messages.json
+// as a form with To, From and Body, authenticating with HTTP basic auth
+type SMSProvider struct {
+	cfg    SMSConfig
+	client *http.Client
+}
+
+// NewSMSProvider sends through hc, or http.DefaultClient when it is nil
+func NewSMSProvider(cfg SMSConfig, hc *http.Client) *SMSProvider {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &SMSProvider{cfg: cfg, client: hc}
+}
+
+func (p *SMSProvider) Name() string { return "sms" }
+
+func (p *SMSProvider) Send(ctx context.Context, m Message) error {
+	form := url.Values{
+		"To":   {m.To},
+		"From": {p.cfg.From},
+		"Body": {m.Body},
+	}
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", strings.TrimRight(p.cfg.BaseURL, "/"), url.PathEscape(p.cfg.AccountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(p.cfg.AccountSID, p.cfg.AuthToken)
+	return do(p.client, req)
+}
+
+// do sends req and treats any non-2xx response as an error
+func do(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
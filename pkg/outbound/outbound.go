@@ -0,0 +1,324 @@
+// Package outbound is the HTTP client shared by everything that calls out to
+// other services: SMS and push gateways, delivery platforms and the like.
+//
+// Each attempt is bounded by a timeout, transient failures are retried with
+// jittered backoff, and a circuit breaker per destination host stops calls
+// to one that keeps failing, so a dead gateway costs a fast error rather than
+// a timeout per order. Counts of requests, failures, retries and refusals
+// are kept per destination for Stats.
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/config"
+)
+
+// ErrCircuitOpen is returned, wrapped with the host, for requests refused
+// because the destination's breaker is open
+var ErrCircuitOpen = errors.New("circuit open")
+
+// Breaker states
+const (
+	StateClosed   = "closed"    // Requests flow
+	StateOpen     = "open"      // Requests are refused until the cooldown passes
+	StateHalfOpen = "half-open" // One trial request decides whether to close again
+)
+
+// Config sets the timeouts, retries and breakers of a Client
+type Config struct {
+	Timeout config.Duration `json:"timeout"` // Per attempt
+
+	// MaxAttempts bounds the attempts per request, the first included.
+	// Retries wait RetryBackoff, doubling each time up to MaxBackoff, with
+	// up to half of each wait taken off at random.
+	MaxAttempts  int             `json:"maxAttempts"`
+	RetryBackoff config.Duration `json:"retryBackoff"`
+	MaxBackoff   config.Duration `json:"maxBackoff"`
+
+	// BreakerThreshold consecutive failures open a destination's breaker
+	// for BreakerCooldown; zero disables the breakers
+	BreakerThreshold int             `json:"breakerThreshold"`
+	BreakerCooldown  config.Duration `json:"breakerCooldown"`
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		Timeout:          config.Duration(10 * time.Second),
+		MaxAttempts:      3,
+		RetryBackoff:     config.Duration(200 * time.Millisecond),
+		MaxBackoff:       config.Duration(5 * time.Second),
+		BreakerThreshold: 5,
+		BreakerCooldown:  config.Duration(30 * time.Second),
+	}
+}
+
+// Stats describes the calls made to one destination
+type Stats struct {
+	Host                string     `json:"host"`
+	State               string     `json:"state"`
+	Requests            uint64     `json:"requests"` // Attempts sent, retries included
+	Failures            uint64     `json:"failures"` // Attempts that failed or got a 408, 429 or 5xx
+	Retries             uint64     `json:"retries"`
+	Rejected            uint64     `json:"rejected"` // Requests refused by the open breaker
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastFailureAt       *time.Time `json:"lastFailureAt,omitempty"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"` // When the breaker last opened, while not closed
+}
+
+// destination is the breaker and counts for one host
+type destination struct {
+	stats Stats
+	until time.Time // End of the open breaker's cooldown
+	trial bool      // A half-open trial request is in flight
+}
+
+// Client tracks the destinations called through the http.Clients it hands out
+type Client struct {
+	cfg   Config
+	now   func() time.Time
+	mu    sync.Mutex
+	hosts map[string]*destination
+}
+
+// New returns a Client for cfg, zero fields taking their defaults
+func New(cfg Config) *Client {
+	def := DefaultConfig()
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = def.Timeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = def.MaxAttempts
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = def.RetryBackoff
+	}
+	if cfg.MaxBackoff < cfg.RetryBackoff {
+		cfg.MaxBackoff = max(def.MaxBackoff, cfg.RetryBackoff)
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = def.BreakerCooldown
+	}
+	return &Client{cfg: cfg, now: time.Now, hosts: make(map[string]*destination)}
+}
+
+// HTTPClient returns an http.Client sending through c, making up to attempts
+// attempts per request or MaxAttempts when attempts is zero. Callers that
+// retry on their own schedule ask for one attempt and still get the timeout
+// and the breakers.
+func (c *Client) HTTPClient(attempts int) *http.Client {
+	if attempts <= 0 {
+		attempts = c.cfg.MaxAttempts
+	}
+	return &http.Client{Transport: &transport{c: c, next: http.DefaultTransport, attempts: attempts}}
+}
+
+// Stats returns the counts for every destination called so far, by host
+func (c *Client) Stats() []Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	out := make([]Stats, 0, len(c.hosts))
+	for _, d := range c.hosts {
+		c.refresh(d, now)
+		out = append(out, d.stats)
+	}
+	slices.SortFunc(out, func(a, b Stats) int { return strings.Compare(a.Host, b.Host) })
+	return out
+}
+
+// Backoff is the wait before the retry following attempt number n: base
+// doubled per earlier retry up to limit, less up to half at random so
+// callers failing together do not retry together
+func Backoff(base, limit time.Duration, n int) time.Duration {
+	wait := base
+	for i := 1; i < n && wait < limit; i++ {
+		wait *= 2
+	}
+	wait = min(wait, limit)
+	if wait < 2 {
+		return wait
+	}
+	return wait - rand.N(wait/2)
+}
+
+// destination returns the entry for host; mu must be held
+func (c *Client) destination(host string) *destination {
+	d, ok := c.hosts[host]
+	if !ok {
+		d = &destination{stats: Stats{Host: host, State: StateClosed}}
+		c.hosts[host] = d
+	}
+	return d
+}
+
+// refresh moves an open breaker whose cooldown has passed to half-open; mu
+// must be held
+func (c *Client) refresh(d *destination, now time.Time) {
+	if d.stats.State == StateOpen && !now.Before(d.until) {
+		d.stats.State = StateHalfOpen
+	}
+}
+
+// allow reports whether a request to host may be sent, counting it if so
+func (c *Client) allow(host string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d := c.destination(host)
+	c.refresh(d, c.now())
+	switch {
+	case d.stats.State == StateOpen, d.stats.State == StateHalfOpen && d.trial:
+		d.stats.Rejected++
+		return fmt.Errorf("%s: %w", host, ErrCircuitOpen)
+	case d.stats.State == StateHalfOpen:
+		d.trial = true
+	}
+	d.stats.Requests++
+	return nil
+}
+
+// result records the outcome of an attempt allowed by allow; err is nil for
+// a success
+func (c *Client) result(host string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d := c.destination(host)
+	d.trial = false
+	if err == nil {
+		d.stats.State = StateClosed
+		d.stats.ConsecutiveFailures = 0
+		d.stats.OpenedAt = nil
+		return
+	}
+	now := c.now()
+	d.stats.Failures++
+	d.stats.ConsecutiveFailures++
+	d.stats.LastError = err.Error()
+	d.stats.LastFailureAt = &now
+	if c.cfg.BreakerThreshold <= 0 {
+		return
+	}
+	if d.stats.State == StateHalfOpen || d.stats.ConsecutiveFailures >= c.cfg.BreakerThreshold {
+		d.stats.State = StateOpen
+		d.stats.OpenedAt = &now
+		d.until = now.Add(time.Duration(c.cfg.BreakerCooldown))
+	}
+}
+
+// retried counts a retry to host
+func (c *Client) retried(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.destination(host).stats.Retries++
+}
+
+// transport is the http.RoundTripper behind HTTPClient
+type transport struct {
+	c        *Client
+	next     http.RoundTripper
+	attempts int
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	// A body can only be sent again when the request can recreate it
+	attempts := t.attempts
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		attempts = 1
+	}
+	for n := 1; ; n++ {
+		if err := t.c.allow(host); err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+		resp, err := t.attempt(req, n)
+		failure := err
+		if err == nil && failed(resp.StatusCode) {
+			failure = errors.New(resp.Status)
+		}
+		t.c.result(host, failure)
+		if failure == nil || n >= attempts || req.Context().Err() != nil {
+			return resp, err
+		}
+		wait := Backoff(time.Duration(t.c.cfg.RetryBackoff), time.Duration(t.c.cfg.MaxBackoff), n)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				if after > time.Duration(t.c.cfg.MaxBackoff) {
+					// Longer than we are prepared to hold the caller
+					return resp, nil
+				}
+				wait = max(wait, after)
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		t.c.retried(host)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt sends attempt number n of req under the per-attempt timeout
+func (t *transport) attempt(req *http.Request, n int) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), time.Duration(t.c.cfg.Timeout))
+	r := req.Clone(ctx)
+	if n > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r.Body = body
+	}
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// failed reports whether a response status means the destination failed or
+// is throttling us, and so counts against its breaker and is retried
+func failed(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// retryAfter reads the response's Retry-After header, given in seconds
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// cancelBody releases an attempt's timeout once its response is read
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
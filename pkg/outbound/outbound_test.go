@@ -0,0 +1,118 @@
+package outbound
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/config"
+)
+
+// server answers each request with the next of its statuses, then 200, and
+// keeps the bodies it got
+type server struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bodies = append(s.bodies, string(body))
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func setup(t *testing.T, statuses ...int) (*server, *Client, string) {
+	t.Helper()
+	s := &server{statuses: statuses}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	cfg := DefaultConfig()
+	cfg.RetryBackoff = config.Duration(time.Millisecond)
+	cfg.MaxBackoff = config.Duration(2 * time.Millisecond)
+	cfg.BreakerThreshold = 2
+	return s, New(cfg), srv.URL
+}
+
+func post(t *testing.T, hc *http.Client, target string) (int, error) {
+	t.Helper()
+	resp, err := hc.Post(target, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestRetries(t *testing.T) {
+	s, c, target := setup(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	c.cfg.BreakerThreshold = 5
+	if code, err := post(t, c.HTTPClient(0), target); err != nil || code != http.StatusOK {
+		t.Fatalf("first post = %d, %v", code, err)
+	}
+	if len(s.bodies) != 3 || s.bodies[2] != "hello" {
+		t.Fatalf("bodies = %q, want 3 of hello", s.bodies)
+	}
+	// A rejection is the caller's to deal with; one attempt asked for is one made
+	s.statuses = []int{http.StatusBadRequest, http.StatusBadGateway}
+	if code, _ := post(t, c.HTTPClient(0), target); code != http.StatusBadRequest {
+		t.Errorf("rejected post = %d", code)
+	}
+	if code, _ := post(t, c.HTTPClient(1), target); code != http.StatusBadGateway {
+		t.Errorf("single attempt = %d", code)
+	}
+	stats := c.Stats()
+	u, _ := url.Parse(target)
+	if len(stats) != 1 || stats[0].Host != u.Host || stats[0].Requests != 5 || stats[0].Failures != 3 || stats[0].Retries != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if stats[0].State != StateClosed || stats[0].ConsecutiveFailures != 1 {
+		t.Errorf("breaker = %s after %d failures", stats[0].State, stats[0].ConsecutiveFailures)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	s, c, target := setup(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	hc := c.HTTPClient(1)
+	post(t, hc, target)
+	post(t, hc, target)
+	if _, err := post(t, hc, target); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("open breaker let a request through: %v", err)
+	}
+	if len(s.bodies) != 2 {
+		t.Errorf("%d requests reached the server, want 2", len(s.bodies))
+	}
+
+	// After the cooldown one trial goes through; failing, it reopens
+	now = now.Add(time.Duration(c.cfg.BreakerCooldown))
+	if st := c.Stats()[0]; st.State != StateHalfOpen || st.Rejected != 1 {
+		t.Fatalf("stats after cooldown = %+v", st)
+	}
+	if code, err := post(t, hc, target); err != nil || code != http.StatusInternalServerError {
+		t.Fatalf("trial = %d, %v", code, err)
+	}
+	if _, err := post(t, hc, target); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("failed trial left the breaker closed: %v", err)
+	}
+	now = now.Add(time.Duration(c.cfg.BreakerCooldown))
+	if code, err := post(t, hc, target); err != nil || code != http.StatusOK {
+		t.Fatalf("second trial = %d, %v", code, err)
+	}
+	if st := c.Stats()[0]; st.State != StateClosed || st.ConsecutiveFailures != 0 || st.OpenedAt != nil {
+		t.Errorf("stats after a good trial = %+v", st)
+	}
+}
@@ -0,0 +1,120 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// Attach subscribes the store to the manager's events, writing each as it
+// happens. Attached to a bus rather than the manager the writes are made
+// outside the manager's lock.
+func (s *Store) Attach(p manager.Publisher) {
+	p.Subscribe(func(e manager.Event) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.cfg.Timeout))
+		defer cancel()
+		if err := s.Append(ctx, e); err != nil {
+			log.Printf("pgstore: %s event for %s: %v", e.Type, eventOrder(e), err)
+		}
+	})
+}
+
+// eventOrder is the ID of the order e is about, or "" for none
+func eventOrder(e manager.Event) string {
+	if e.Token == nil {
+		return ""
+	}
+	return e.Token.ID
+}
+
+// Append records e together with the state it left its order in and, for a
+// new order with a phone number, the customer's tally, all or nothing
+func (s *Store) Append(ctx context.Context, e manager.Event) error {
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		var token sql.NullString
+		var orderID sql.NullString
+		if t := e.Token; t != nil {
+			// The queue's own writes set the queued flag; archiving is for good
+			archived := e.Type == manager.EventArchived
+			if _, err := write(ctx, tx, upsert+", archived = orders.archived OR EXCLUDED.archived", t, false, archived); err != nil {
+				return err
+			}
+			data, err := json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			token = sql.NullString{String: string(data), Valid: true}
+			orderID = sql.NullString{String: t.ID, Valid: true}
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO events (type, order_id, at, token) VALUES ($1, $2, $3, $4)",
+			e.Type, orderID, e.At, token); err != nil {
+			return err
+		}
+		if e.Type != manager.EventCreated || e.Token.Phone == "" {
+			return nil
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO customers (phone, orders, first_order_at, last_order_at) VALUES ($1, 1, $2, $2)
+ON CONFLICT (phone) DO UPDATE SET orders = customers.orders + 1, last_order_at = EXCLUDED.last_order_at`,
+			e.Token.Phone, e.Token.Timestamp)
+		return err
+	})
+}
+
+// SyncMenu records the items the manager is configured with, their prices
+// and their complexity, replacing what was recorded for them before
+func (s *Store) SyncMenu(ctx context.Context, cfg manager.Config) error {
+	items := make(map[string]bool)
+	for item := range cfg.ItemPrices {
+		items[item] = true
+	}
+	for item := range cfg.ItemComplexity {
+		items[item] = true
+	}
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		for item := range items {
+			price, hasPrice := cfg.ItemPrices[item]
+			complexity, hasComplexity := cfg.ItemComplexity[item]
+			_, err := tx.ExecContext(ctx, `INSERT INTO menu (item, price, complexity) VALUES ($1, $2, $3)
+ON CONFLICT (item) DO UPDATE SET price = EXCLUDED.price, complexity = EXCLUDED.complexity, updated_at = now()`,
+				item, sql.NullFloat64{Float64: price, Valid: hasPrice}, sql.NullFloat64{Float64: complexity, Valid: hasComplexity})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Load returns every order not yet archived by a day close, for
+// manager.Restore
+func (s *Store) Load(ctx context.Context) ([]*queue.Token, error) {
+	return s.query(ctx, "SELECT token FROM orders WHERE NOT archived ORDER BY placed_at, id")
+}
+
+// Customer is one customer's tally
+type Customer struct {
+	Phone        string    `json:"phone"`
+	Orders       int       `json:"orders"`
+	FirstOrderAt time.Time `json:"firstOrderAt"`
+	LastOrderAt  time.Time `json:"lastOrderAt"`
+}
+
+// Customer returns the tally for phone, or nil when it has never ordered
+func (s *Store) Customer(ctx context.Context, phone string) (*Customer, error) {
+	c := Customer{Phone: phone}
+	err := s.db.QueryRowContext(ctx, "SELECT orders, first_order_at, last_order_at FROM customers WHERE phone = $1", phone).
+		Scan(&c.Orders, &c.FirstOrderAt, &c.LastOrderAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
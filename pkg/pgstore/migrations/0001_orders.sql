@@ -0,0 +1,22 @@
+-- Every order, its latest state as JSON beside the columns it is queried by.
+-- The waiting queue is the rows flagged queued.
+CREATE TABLE orders (
+    id         text PRIMARY KEY,
+    number     integer NOT NULL DEFAULT 0,
+    item       text NOT NULL,
+    station    text NOT NULL DEFAULT '',
+    status     text NOT NULL,
+    priority   integer NOT NULL,
+    placed_at  timestamptz NOT NULL,
+    phone      text NOT NULL DEFAULT '',
+    queued     boolean NOT NULL DEFAULT false,
+    archived   boolean NOT NULL DEFAULT false,
+    token      jsonb NOT NULL,
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX orders_queue ON orders (priority, placed_at, id) WHERE queued;
+CREATE INDEX orders_open ON orders (placed_at) WHERE NOT archived;
+
+-- Order IDs for every instance sharing the database
+CREATE SEQUENCE order_ids;
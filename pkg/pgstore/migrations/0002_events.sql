@@ -0,0 +1,10 @@
+-- Every change to an order, as the manager published it
+CREATE TABLE events (
+    seq      bigserial PRIMARY KEY,
+    type     text NOT NULL,
+    order_id text REFERENCES orders (id),
+    at       timestamptz NOT NULL,
+    token    jsonb
+);
+
+CREATE INDEX events_order ON events (order_id, seq);
@@ -0,0 +1,7 @@
+-- The items the kitchen is configured with, and what they cost and take
+CREATE TABLE menu (
+    item       text PRIMARY KEY,
+    price      numeric(10, 2),
+    complexity double precision,
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,7 @@
+-- Customers who left a phone number, and how often they order
+CREATE TABLE customers (
+    phone          text PRIMARY KEY,
+    orders         integer NOT NULL DEFAULT 0,
+    first_order_at timestamptz NOT NULL,
+    last_order_at  timestamptz NOT NULL
+);
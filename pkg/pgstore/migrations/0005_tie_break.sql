@@ -0,0 +1,8 @@
+-- Orders of one priority placed at the same instant go by the manager's
+-- tie-break key, then the order they were placed in
+ALTER TABLE orders
+    ADD COLUMN tie_break bigint NOT NULL DEFAULT 0,
+    ADD COLUMN seq       bigint NOT NULL DEFAULT 0;
+
+DROP INDEX orders_queue;
+CREATE INDEX orders_queue ON orders (priority, placed_at, tie_break, seq, id) WHERE queued;
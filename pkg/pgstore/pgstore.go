@@ -0,0 +1,125 @@
+// Package pgstore keeps orders in PostgreSQL, for restaurants already
+// running it: the waiting queue, so several server instances share one, and
+// a record of every order, its events, the menu and the customers who
+// ordered.
+//
+// The waiting queue is the orders flagged queued, indexed by priority and
+// then time of order; popping one locks its row and skips rows other
+// instances hold, so each order is prepared once. Every event is written in
+// one transaction with the order's new state and, for new orders, the
+// customer's tally. The schema is created and upgraded by the migrations
+// embedded in the package, run when the store is opened.
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/lib/pq" // Registers the "postgres" driver
+
+	"awesomeProject/pkg/config"
+)
+
+// Config holds the connection and pool settings
+type Config struct {
+	// DSN is a connection URL or key=value list, as
+	// "postgres://kitchen:secret@db:5432/orders?sslmode=disable"
+	DSN string `json:"dsn"`
+
+	MaxOpenConns    int             `json:"maxOpenConns"` // Zero for no limit
+	MaxIdleConns    int             `json:"maxIdleConns"`
+	ConnMaxLifetime config.Duration `json:"connMaxLifetime"` // Zero keeps connections for good
+	ConnMaxIdleTime config.Duration `json:"connMaxIdleTime"`
+
+	// Timeout bounds opening the store and each event written
+	Timeout config.Duration `json:"timeout"`
+
+	// Restore rebuilds the manager's orders from the database at start. Only
+	// one instance of those sharing a database should restore.
+	Restore bool `json:"restore"`
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		MaxOpenConns:    10,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: config.Duration(30 * time.Minute),
+		ConnMaxIdleTime: config.Duration(5 * time.Minute),
+		Timeout:         config.Duration(5 * time.Second),
+	}
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	switch {
+	case c.DSN == "":
+		return errors.New("postgres: dsn is required")
+	case c.MaxOpenConns < 0 || c.MaxIdleConns < 0:
+		return errors.New("postgres: connection limits must not be negative")
+	case c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns:
+		return errors.New("postgres: maxIdleConns must not exceed maxOpenConns")
+	case c.ConnMaxLifetime < 0 || c.ConnMaxIdleTime < 0:
+		return errors.New("postgres: connection lifetimes must not be negative")
+	case c.Timeout <= 0:
+		return errors.New("postgres: timeout must be positive")
+	}
+	return nil
+}
+
+// Store is a connection pool to the orders database
+type Store struct {
+	cfg Config
+	db  *sql.DB
+}
+
+// Open connects to the database, checks that it answers and brings its
+// schema up to date
+func Open(cfg Config) (*Store, error) {
+	db, err := sql.Open("postgres", cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+	db.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout))
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	if err := Migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{cfg: cfg, db: db}, nil
+}
+
+// Close releases the connections
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Check reports whether the database answers, for readiness probes
+func (s *Store) Check(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// tx runs fn in a transaction, committing when it returns nil
+func (s *Store) tx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,181 @@
+package pgstore
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestMigrations(t *testing.T) {
+	list, err := migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range list {
+		if m.version != i+1 || m.name == "" || m.sql == "" {
+			t.Errorf("migration %d = %d_%s, want versions counting up from 1", i, m.version, m.name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := cfg.Validate(); err == nil {
+		t.Error("no dsn: no error")
+	}
+	cfg.DSN = "postgres://localhost/orders"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cfg.MaxIdleConns = cfg.MaxOpenConns + 1
+	if err := cfg.Validate(); err == nil {
+		t.Error("more idle than open connections: no error")
+	}
+}
+
+// TestSharedQueue runs two managers against a real PostgreSQL named by
+// POSTGRES_DSN. The database should be a scratch one: its order tables are
+// emptied first.
+func TestSharedQueue(t *testing.T) {
+	ctx := context.Background()
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN not set")
+	}
+	cfg := DefaultConfig()
+	cfg.DSN = dsn
+	open := func() (*Store, *manager.OrderManager) {
+		s, err := Open(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		om := manager.New(manager.DefaultConfig(), manager.WithQueue(s))
+		s.Attach(om)
+		return s, om
+	}
+	first, one := open()
+	if _, err := first.db.ExecContext(ctx, "TRUNCATE orders, events, menu, customers"); err != nil {
+		t.Fatal(err)
+	}
+	_, two := open()
+
+	low, err := one.PlaceOrder(ctx, manager.NewOrder{Item: "low", Priority: 5, Phone: "+15550100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := two.AddOrder(ctx, "high", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if low.ID == high.ID {
+		t.Fatalf("both instances allocated ID %s", low.ID)
+	}
+	if n, err := first.Ahead(ctx, low); err != nil || n != 1 {
+		t.Errorf("ahead of the low order = %d, %v, want 1", n, err)
+	}
+
+	got, err := one.PrepareOrder(ctx)
+	if err != nil || got.ID != high.ID {
+		t.Fatalf("first instance prepared %+v, %v, want order %s", got, err, high.ID)
+	}
+	got, err = two.PrepareOrder(ctx)
+	if err != nil || got.ID != low.ID {
+		t.Fatalf("second instance prepared %+v, %v, want order %s", got, err, low.ID)
+	}
+	if _, err := one.PrepareOrder(ctx); err != manager.ErrQueueEmpty {
+		t.Fatalf("drained queue error = %v", err)
+	}
+
+	tokens, err := first.Load(ctx)
+	if err != nil || len(tokens) != 2 || tokens[0].Status != queue.StatusPrepared || tokens[1].Status != queue.StatusPrepared {
+		t.Fatalf("stored orders = %+v, %v", tokens, err)
+	}
+	var events int
+	first.db.QueryRowContext(ctx, "SELECT count(*) FROM events").Scan(&events)
+	if events != 4 {
+		t.Errorf("events = %d, want a created and prepared event for each order", events)
+	}
+	if c, err := first.Customer(ctx, "+15550100"); err != nil || c == nil || c.Orders != 1 {
+		t.Errorf("customer = %+v, %v", c, err)
+	}
+
+	mcfg := manager.DefaultConfig()
+	mcfg.ItemPrices = map[string]float64{"low": 4.5}
+	if err := first.SyncMenu(ctx, mcfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestScrub erases a customer from a real PostgreSQL named by POSTGRES_DSN,
+// emptied first as for TestSharedQueue
+func TestScrub(t *testing.T) {
+	ctx := context.Background()
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN not set")
+	}
+	cfg := DefaultConfig()
+	cfg.DSN = dsn
+	s, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.db.ExecContext(ctx, "TRUNCATE orders, events, menu, customers"); err != nil {
+		t.Fatal(err)
+	}
+	om := manager.New(manager.DefaultConfig(), manager.WithQueue(s))
+	s.Attach(om)
+	const phone = "+15550100"
+	sam, err := om.PlaceOrder(ctx, manager.NewOrder{Item: "soup", Notes: "for Sam", Phone: phone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := om.PlaceOrder(ctx, manager.NewOrder{Item: "stew", Phone: "+15550199"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := om.PrepareOrder(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Purging the manager's copy leaves the earlier events and the tally
+	if _, err := om.Purge(ctx, func(t *queue.Token) bool { return t.OrderedBy(phone) }); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.Scrub(func(t *queue.Token) bool { return t.OrderedBy(phone) })
+	if err != nil || n == 0 {
+		t.Fatalf("scrub = %d, %v", n, err)
+	}
+	var left int
+	s.db.QueryRowContext(ctx, "SELECT count(*) FROM events WHERE token::text LIKE '%' || $1 || '%' OR token::text LIKE '%for Sam%'", phone).Scan(&left)
+	if left != 0 {
+		t.Errorf("%d events still hold the customer's data", left)
+	}
+	s.db.QueryRowContext(ctx, "SELECT count(*) FROM orders WHERE phone = $1 OR token::text LIKE '%' || $1 || '%'", phone).Scan(&left)
+	if left != 0 {
+		t.Errorf("%d orders still hold the phone number", left)
+	}
+	if c, err := s.Customer(ctx, phone); err != nil || c != nil {
+		t.Errorf("customer after the scrub = %+v, %v", c, err)
+	}
+	if c, err := s.Customer(ctx, "+15550199"); err != nil || c == nil {
+		t.Errorf("other customer = %+v, %v", c, err)
+	}
+	tokens, err := s.Load(ctx)
+	if err != nil || len(tokens) != 2 {
+		t.Fatalf("stored orders = %+v, %v", tokens, err)
+	}
+	for _, tok := range tokens {
+		if tok.ID == sam.ID && (tok.Phone != "" || tok.Notes != "" || tok.PickupCode != sam.PickupCode) {
+			t.Errorf("scrubbed order = %+v", tok)
+		}
+		if tok.ID == other.ID && tok.Phone != "+15550199" {
+			t.Errorf("other order = %+v", tok)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+// personal matches the rows whose token JSON may hold personal data, so a
+// scrub need not read every order ever placed
+const personal = "token ?| array['phone', 'deviceToken', 'client', 'notes', 'edits']"
+
+// Scrub erases the personal data of the orders match picks from their
+// stored state and from every event recorded for them, and drops the tally
+// of each of their customers who has no order left holding the phone
+// number, in one transaction. It reports how many rows it changed.
+func (s *Store) Scrub(match func(*queue.Token) bool) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.cfg.Timeout))
+	defer cancel()
+	var changed int
+	err := s.tx(ctx, func(tx *sql.Tx) error {
+		changed = 0
+		picked := make(map[string]bool)
+		phones := make(map[string]bool)
+		orders, err := scrubRows(ctx, tx, "SELECT id, token FROM orders WHERE "+personal+" FOR UPDATE", match)
+		if err != nil {
+			return err
+		}
+		for _, row := range orders {
+			picked[row.token.ID] = true
+			if row.phone != "" {
+				phones[row.phone] = true
+			}
+			data, err := json.Marshal(row.token.Stored())
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE orders SET token = $2, phone = '', updated_at = now() WHERE id = $1",
+				row.key, string(data)); err != nil {
+				return err
+			}
+			changed++
+		}
+
+		events, err := scrubRows(ctx, tx, "SELECT seq, token FROM events WHERE "+personal+" FOR UPDATE",
+			func(t *queue.Token) bool { return picked[t.ID] || match(t) })
+		if err != nil {
+			return err
+		}
+		for _, row := range events {
+			if row.phone != "" {
+				phones[row.phone] = true
+			}
+			data, err := json.Marshal(row.token)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE events SET token = $2 WHERE seq = $1", row.key, string(data)); err != nil {
+				return err
+			}
+			changed++
+		}
+
+		for phone := range phones {
+			res, err := tx.ExecContext(ctx,
+				"DELETE FROM customers WHERE phone = $1 AND NOT EXISTS (SELECT 1 FROM orders WHERE phone = $1)", phone)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			changed += int(n)
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// scrubbed is a row whose token Scrub erased personal data from
+type scrubbed struct {
+	key   string       // The row's key
+	token *queue.Token // Scrubbed
+	phone string       // The phone number the token held
+}
+
+// scrubRows reads the key and token of each row query finds, and returns
+// the rows whose token match picks and held personal data, scrubbed
+func scrubRows(ctx context.Context, tx *sql.Tx, query string, match func(*queue.Token) bool) ([]scrubbed, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []scrubbed
+	for rows.Next() {
+		var key string
+		var data []byte
+		if err := rows.Scan(&key, &data); err != nil {
+			return nil, err
+		}
+		t, err := decode(data)
+		if err != nil {
+			return nil, err
+		}
+		phone := t.Phone
+		if match(t) && t.Scrub() {
+			out = append(out, scrubbed{key: key, token: t, phone: phone})
+		}
+	}
+	return out, rows.Err()
+}
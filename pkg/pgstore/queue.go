@@ -0,0 +1,167 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+var (
+	_ manager.Queue    = (*Store)(nil)
+	_ manager.IDSource = (*Store)(nil)
+	_ manager.Ranker   = (*Store)(nil)
+)
+
+// queueOrder is the order waiting tokens are prepared in, as queue.Before
+// with the ID breaking ties between instances, whose sequences overlap
+const queueOrder = "priority, placed_at, tie_break, seq, id"
+
+// upsert writes t's latest state, leaving the queued and archived flags to
+// the query's own columns
+const upsert = `INSERT INTO orders (id, number, item, station, status, priority, placed_at, phone, token, queued, archived, tie_break, seq)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+ON CONFLICT (id) DO UPDATE SET
+    number = EXCLUDED.number, item = EXCLUDED.item, station = EXCLUDED.station,
+    status = EXCLUDED.status, priority = EXCLUDED.priority, phone = EXCLUDED.phone,
+    token = EXCLUDED.token, tie_break = EXCLUDED.tie_break, seq = EXCLUDED.seq, updated_at = now()`
+
+// execer is a *sql.DB or *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// write stores t with each of the query's extra columns and clauses
+func write(ctx context.Context, db execer, query string, t *queue.Token, queued, archived bool) (sql.Result, error) {
+	data, err := json.Marshal(t.Stored())
+	if err != nil {
+		return nil, err
+	}
+	return db.ExecContext(ctx, query, t.ID, t.Number, t.Item, t.Station, t.Status, t.Priority, t.Timestamp,
+		t.Phone, string(data), queued, archived, t.TieBreak, int64(t.Seq))
+}
+
+// decode turns a row's token JSON into a token
+func decode(data []byte) (*queue.Token, error) {
+	var t queue.Token
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("decode stored token: %w", err)
+	}
+	return &t, nil
+}
+
+// one returns the token a single-row query finds, or nil
+func one(row *sql.Row) (*queue.Token, error) {
+	var data []byte
+	if err := row.Scan(&data); errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+func (s *Store) Push(ctx context.Context, t *queue.Token) error {
+	_, err := write(ctx, s.db, upsert+", queued = true", t, true, false)
+	return err
+}
+
+func (s *Store) Pop(ctx context.Context) (*queue.Token, error) {
+	var t *queue.Token
+	err := s.tx(ctx, func(tx *sql.Tx) error {
+		var err error
+		row := tx.QueryRowContext(ctx, "SELECT token FROM orders WHERE queued ORDER BY "+queueOrder+" LIMIT 1 FOR UPDATE SKIP LOCKED")
+		if t, err = one(row); err != nil || t == nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE orders SET queued = false, updated_at = now() WHERE id = $1", t.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*queue.Token, error) {
+	return one(s.db.QueryRowContext(ctx, "SELECT token FROM orders WHERE id = $1 AND queued", id))
+}
+
+func (s *Store) Remove(ctx context.Context, id string) (*queue.Token, error) {
+	return one(s.db.QueryRowContext(ctx,
+		"UPDATE orders SET queued = false, updated_at = now() WHERE id = $1 AND queued RETURNING token", id))
+}
+
+func (s *Store) Update(ctx context.Context, t *queue.Token) error {
+	data, err := json.Marshal(t.Stored())
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, `UPDATE orders SET
+    number = $2, item = $3, station = $4, status = $5, priority = $6, placed_at = $7, phone = $8,
+    token = $9, tie_break = $10, seq = $11, updated_at = now()
+WHERE id = $1 AND queued`, t.ID, t.Number, t.Item, t.Station, t.Status, t.Priority, t.Timestamp, t.Phone, string(data),
+		t.TieBreak, int64(t.Seq))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return manager.ErrNotQueued
+	}
+	return nil
+}
+
+// List returns the queued tokens in the order they will be prepared
+func (s *Store) List(ctx context.Context) ([]*queue.Token, error) {
+	return s.query(ctx, "SELECT token FROM orders WHERE queued ORDER BY "+queueOrder)
+}
+
+// query returns the tokens a query finds
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]*queue.Token, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tokens []*queue.Token
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		t, err := decode(data)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *Store) Len(ctx context.Context) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, "SELECT count(*) FROM orders WHERE queued").Scan(&n)
+	return n, err
+}
+
+// Ahead counts the queued tokens at t's station that are prepared before it
+func (s *Store) Ahead(ctx context.Context, t *queue.Token) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT count(*) FROM orders
+WHERE queued AND station = $1 AND (priority, placed_at, tie_break, seq, id) < ($2, $3, $4, $5, $6)`,
+		t.Station, t.Priority, t.Timestamp, t.TieBreak, int64(t.Seq), t.ID).Scan(&n)
+	return n, err
+}
+
+// NextID allocates an order ID from the shared sequence
+func (s *Store) NextID(ctx context.Context) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, "SELECT nextval('order_ids')").Scan(&n)
+	return n, err
+}
@@ -0,0 +1,319 @@
+// Package printer pushes tickets to networked ESC/POS receipt printers as
+// orders reach the kitchen, so each station gets a slip without anyone
+// pulling it.
+//
+// Each printer takes the tickets of the stations it is configured for, when
+// they enter the queue or when a cook claims them. Tickets for a printer
+// that is offline wait in its queue and go out in order once it answers
+// again.
+package printer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/outbound"
+	"awesomeProject/pkg/queue"
+	"awesomeProject/pkg/ticket"
+)
+
+// When a printer prints an order's ticket
+const (
+	// PrintCreated prints orders as they enter the queue: when placed, or
+	// later when released from a hold, the waitlist or the schedule, or
+	// when their course is fired
+	PrintCreated = "created"
+	PrintClaimed = "claimed" // A cook started on the order
+)
+
+// defaultPort is the raw printing port ESC/POS printers listen on
+const defaultPort = "9100"
+
+// Config sets up the printers and how tickets are retried
+type Config struct {
+	Printers []PrinterConfig `json:"printers"`
+	Language string          `json:"language"` // Of the ticket labels; English when empty
+	Timeout  config.Duration `json:"timeout"`  // Per connection, ticket included
+
+	// Tickets that fail are retried after RetryBackoff, doubling each time
+	// up to MaxBackoff, for as long as they stay queued. QueueSize bounds
+	// the tickets each printer holds; new ones are dropped past it.
+	RetryBackoff config.Duration `json:"retryBackoff"`
+	MaxBackoff   config.Duration `json:"maxBackoff"`
+	QueueSize    int             `json:"queueSize"`
+}
+
+// PrinterConfig configures one printer
+type PrinterConfig struct {
+	Name    string `json:"name"`    // For logs; the address when empty
+	Address string `json:"address"` // host:port, the port 9100 when left out
+
+	// Stations lists the stations whose tickets it prints, "" standing for
+	// the default station; it prints every station's when empty
+	Stations []string `json:"stations"`
+	Print    string   `json:"print"` // PrintCreated, the default, or PrintClaimed
+	Width    int      `json:"width"` // Characters per line; the ticket default when zero
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		Timeout:      config.Duration(5 * time.Second),
+		RetryBackoff: config.Duration(time.Second),
+		MaxBackoff:   config.Duration(30 * time.Second),
+		QueueSize:    100,
+	}
+}
+
+// Validate checks the printers
+func (c Config) Validate() error {
+	seen := make(map[string]bool)
+	for _, p := range c.Printers {
+		if p.Address == "" {
+			return errors.New("printers need an address")
+		}
+		name := p.name()
+		if seen[name] {
+			return fmt.Errorf("printer %q is configured twice", name)
+		}
+		seen[name] = true
+		switch p.Print {
+		case "", PrintCreated, PrintClaimed:
+		default:
+			return fmt.Errorf("printer %q: print must be %s or %s, got %q", name, PrintCreated, PrintClaimed, p.Print)
+		}
+		if p.Width != 0 && (p.Width < ticket.MinWidth || p.Width > ticket.MaxWidth) {
+			return fmt.Errorf("printer %q: width must be from %d to %d", name, ticket.MinWidth, ticket.MaxWidth)
+		}
+	}
+	return nil
+}
+
+func (p PrinterConfig) name() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Address
+}
+
+// address returns the printer's address with the port filled in
+func (p PrinterConfig) address() string {
+	if _, _, err := net.SplitHostPort(p.Address); err != nil {
+		return net.JoinHostPort(p.Address, defaultPort)
+	}
+	return p.Address
+}
+
+// prints reports whether the printer takes the tickets of station
+func (p PrinterConfig) prints(station string) bool {
+	if len(p.Stations) == 0 {
+		return true
+	}
+	for _, s := range p.Stations {
+		if s == station {
+			return true
+		}
+	}
+	return false
+}
+
+// Driver sends tickets to the configured printers
+type Driver struct {
+	cfg      Config
+	printers []*printer
+}
+
+// New builds a Driver from cfg, which must be valid, and starts a sender for
+// each printer. Call Attach to start receiving events and Close to stop.
+func New(cfg Config) *Driver {
+	def := DefaultConfig()
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = def.Timeout
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = def.RetryBackoff
+	}
+	if cfg.MaxBackoff < cfg.RetryBackoff {
+		cfg.MaxBackoff = max(def.MaxBackoff, cfg.RetryBackoff)
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = def.QueueSize
+	}
+	d := &Driver{cfg: cfg}
+	for _, pc := range cfg.Printers {
+		if pc.Print == "" {
+			pc.Print = PrintCreated
+		}
+		if pc.Width == 0 {
+			pc.Width = ticket.DefaultWidth
+		}
+		p := &printer{
+			cfg:  pc,
+			d:    d,
+			wake: make(chan struct{}, 1),
+			stop: make(chan struct{}),
+			done: make(chan struct{}),
+		}
+		go p.run()
+		d.printers = append(d.printers, p)
+	}
+	return d
+}
+
+// Attach subscribes the driver to the manager's events, or a bus relaying them
+func (d *Driver) Attach(p manager.Publisher) {
+	p.Subscribe(d.handle)
+}
+
+// Close stops the senders, waiting for any ticket being sent. Tickets still
+// queued are dropped.
+func (d *Driver) Close() error {
+	for _, p := range d.printers {
+		close(p.stop)
+	}
+	for _, p := range d.printers {
+		<-p.done
+		p.mu.Lock()
+		if n := len(p.pending); n > 0 {
+			log.Printf("printer %s: dropping %d unprinted tickets at shutdown", p.cfg.name(), n)
+		}
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+// trigger returns what e counts as for the printers, or "" for nothing
+func trigger(e manager.Event) string {
+	switch e.Type {
+	case manager.EventCreated, manager.EventReleased, manager.EventFired:
+		if e.Token.Status == queue.StatusPreparing {
+			return PrintCreated
+		}
+	case manager.EventClaimed:
+		return PrintClaimed
+	}
+	return ""
+}
+
+func (d *Driver) handle(e manager.Event) {
+	on := trigger(e)
+	if on == "" {
+		return
+	}
+	for _, p := range d.printers {
+		if p.cfg.Print == on && p.cfg.prints(e.Token.Station) {
+			lines := ticket.Lines(e.Token, d.cfg.Language, p.cfg.Width)
+			p.enqueue(job{orderID: e.Token.ID, data: ticket.ESCPOS(lines, p.cfg.Width)})
+		}
+	}
+}
+
+// job is one ticket to print
+type job struct {
+	orderID string
+	data    []byte
+}
+
+// printer is the queue and sender for one printer
+type printer struct {
+	cfg  PrinterConfig
+	d    *Driver
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+
+	mu      sync.Mutex
+	pending []job // Oldest first
+}
+
+// enqueue adds a ticket without blocking the manager
+func (p *printer) enqueue(j job) {
+	p.mu.Lock()
+	if len(p.pending) >= p.d.cfg.QueueSize {
+		p.mu.Unlock()
+		log.Printf("printer %s: queue full, dropping ticket for order %s", p.cfg.name(), j.orderID)
+		return
+	}
+	p.pending = append(p.pending, j)
+	p.mu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the oldest queued ticket
+func (p *printer) next() (job, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.pending) == 0 {
+		return job{}, false
+	}
+	return p.pending[0], true
+}
+
+// sent drops the oldest queued ticket once it is printed
+func (p *printer) sent() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = p.pending[1:]
+}
+
+func (p *printer) run() {
+	defer close(p.done)
+	failures := 0
+	for {
+		j, ok := p.next()
+		if !ok {
+			select {
+			case <-p.wake:
+				continue
+			case <-p.stop:
+				return
+			}
+		}
+		if err := p.send(j.data); err != nil {
+			failures++
+			wait := outbound.Backoff(time.Duration(p.d.cfg.RetryBackoff), time.Duration(p.d.cfg.MaxBackoff), failures)
+			if failures == 1 {
+				log.Printf("printer %s: ticket for order %s failed, retrying until it answers: %v", p.cfg.name(), j.orderID, err)
+			}
+			select {
+			case <-time.After(wait):
+			case <-p.stop:
+				return
+			}
+			continue
+		}
+		if failures > 0 {
+			log.Printf("printer %s: back after %d failed attempts", p.cfg.name(), failures)
+			failures = 0
+		}
+		p.sent()
+	}
+}
+
+// send writes one ticket to the printer
+func (p *printer) send(data []byte) error {
+	timeout := time.Duration(p.d.cfg.Timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.cfg.address())
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(data); err != nil {
+		conn.Close()
+		return err
+	}
+	return conn.Close()
+}
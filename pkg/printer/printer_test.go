@@ -0,0 +1,142 @@
+package printer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+)
+
+// fake is a printer's raw port, passing on each ticket it gets
+type fake struct {
+	ln      net.Listener
+	tickets chan []byte
+}
+
+func listen(t *testing.T, addr string) *fake {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fake{ln: ln, tickets: make(chan []byte, 10)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := io.ReadAll(conn)
+			conn.Close()
+			f.tickets <- data
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fake) next(t *testing.T) []byte {
+	t.Helper()
+	select {
+	case data := <-f.tickets:
+		return data
+	case <-time.After(2 * time.Second):
+		t.Fatal("no ticket")
+		return nil
+	}
+}
+
+func (f *fake) none(t *testing.T) {
+	t.Helper()
+	select {
+	case data := <-f.tickets:
+		t.Fatalf("unexpected ticket %q", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func testConfig(printers ...PrinterConfig) Config {
+	cfg := DefaultConfig()
+	cfg.Printers = printers
+	cfg.RetryBackoff = config.Duration(10 * time.Millisecond)
+	cfg.MaxBackoff = config.Duration(20 * time.Millisecond)
+	return cfg
+}
+
+func TestPrintsPerStation(t *testing.T) {
+	ctx := context.Background()
+	grill, bar := listen(t, "127.0.0.1:0"), listen(t, "127.0.0.1:0")
+	cfg := testConfig(
+		PrinterConfig{Name: "grill", Address: grill.ln.Addr().String(), Stations: []string{"grill"}},
+		PrinterConfig{Name: "bar", Address: bar.ln.Addr().String(), Stations: []string{"bar"}, Print: PrintClaimed},
+	)
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	d := New(cfg)
+	defer d.Close()
+	om := manager.New(manager.DefaultConfig())
+	d.Attach(om)
+
+	om.PlaceOrder(ctx, manager.NewOrder{Item: "burger", Priority: 1, Station: "grill", Notes: "no onion"})
+	if data := grill.next(t); !bytes.Contains(data, []byte("1 x burger")) || !bytes.Contains(data, []byte("no onion")) {
+		t.Errorf("grill ticket = %q", data)
+	}
+	// The bar prints when a drink is started, not when it is ordered
+	om.PlaceOrder(ctx, manager.NewOrder{Item: "mojito", Priority: 1, Station: "bar"})
+	bar.none(t)
+	if _, err := om.ClaimStationOrder(ctx, "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if data := bar.next(t); !bytes.Contains(data, []byte("mojito")) {
+		t.Errorf("bar ticket = %q", data)
+	}
+	grill.none(t)
+}
+
+func TestRetriesOfflinePrinter(t *testing.T) {
+	ctx := context.Background()
+	// Find a free port, then leave nothing listening on it
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	d := New(testConfig(PrinterConfig{Address: addr}))
+	defer d.Close()
+	om := manager.New(manager.DefaultConfig())
+	d.Attach(om)
+	om.PlaceOrder(ctx, manager.NewOrder{Item: "soup", Priority: 1})
+	om.PlaceOrder(ctx, manager.NewOrder{Item: "salad", Priority: 1})
+	time.Sleep(50 * time.Millisecond)
+
+	back := listen(t, addr)
+	if first, second := back.next(t), back.next(t); !bytes.Contains(first, []byte("soup")) || !bytes.Contains(second, []byte("salad")) {
+		t.Errorf("tickets out of order: %q, %q", first, second)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, p := range []PrinterConfig{
+		{},
+		{Address: "10.0.0.5", Print: "prepared"},
+		{Address: "10.0.0.5", Width: 8},
+	} {
+		if err := testConfig(p).Validate(); err == nil {
+			t.Errorf("%+v: no error", p)
+		}
+	}
+	if err := testConfig(PrinterConfig{Address: "10.0.0.5"}, PrinterConfig{Address: "10.0.0.5"}).Validate(); err == nil {
+		t.Error("duplicate printers: no error")
+	}
+	if got := (PrinterConfig{Address: "10.0.0.5"}).address(); got != "10.0.0.5:9100" {
+		t.Errorf("address = %s", got)
+	}
+}
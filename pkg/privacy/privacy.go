@@ -0,0 +1,177 @@
+// Package privacy erases customers' personal data: their phone numbers,
+// device tokens, addresses and the notes that often name them. Once orders
+// pass the retention period it is scrubbed from them on a schedule, and a
+// customer may have theirs erased at any time, from the orders held, the
+// day archive, the event log, the audit log and whatever other stores keep
+// orders, such as the database, the write-ahead log and the spill and
+// dead-letter files, alike. Backups taken before keep it until they are
+// pruned.
+package privacy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"awesomeProject/pkg/archive"
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/eventlog"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// Config sets how long personal data is kept
+type Config struct {
+	// RetentionDays is how many days after an order is placed its
+	// personal data is scrubbed; zero keeps it
+	RetentionDays int `json:"retentionDays"`
+
+	// Interval is how often orders past the retention period are looked
+	// for
+	Interval config.Duration `json:"interval"`
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{Interval: config.Duration(time.Hour)}
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	if c.RetentionDays < 0 {
+		return fmt.Errorf("privacy retentionDays must not be negative")
+	}
+	if c.RetentionDays > 0 && c.Interval <= 0 {
+		return fmt.Errorf("privacy interval must be positive")
+	}
+	return nil
+}
+
+// Result is what a purge erased
+type Result struct {
+	Orders       []string `json:"orders"`       // IDs of the orders, held or archived, that had personal data
+	Events       int      `json:"events"`       // Event log records rewritten
+	AuditEntries int      `json:"auditEntries"` // Audit entries whose parameters held personal data
+	Records      int      `json:"records"`      // Records rewritten in the other stores
+}
+
+// Store is somewhere else orders are kept, such as the database behind the
+// manager or a file of events waiting to go out. Scrub erases the personal
+// data of the orders match picks and reports how many records it changed.
+type Store interface {
+	Scrub(match func(*queue.Token) bool) (int, error)
+}
+
+// Purger erases personal data from the manager's orders, whichever of the
+// archive, event log and audit log are kept, and the other stores given
+type Purger struct {
+	cfg     Config
+	om      *manager.OrderManager
+	archive *archive.Store
+	events  *eventlog.Log
+	audit   *audit.Log
+	stores  []Store
+	mu      sync.Mutex // One purge at a time, as each rewrites files
+}
+
+// New returns a Purger over om and the stores given; nil stores are not kept
+func New(cfg Config, om *manager.OrderManager, store *archive.Store, events *eventlog.Log, trail *audit.Log, stores ...Store) *Purger {
+	return &Purger{cfg: cfg, om: om, archive: store, events: events, audit: trail, stores: stores}
+}
+
+// Run scrubs orders as they pass the retention period until ctx is done. It
+// returns at once when personal data is kept.
+func (p *Purger) Run(ctx context.Context) {
+	if p.cfg.RetentionDays <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(p.cfg.Interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			res, err := p.Expire(ctx, p.om.Now())
+			if err != nil {
+				log.Printf("privacy: %v", err)
+			} else if len(res.Orders) > 0 || res.AuditEntries > 0 {
+				log.Printf("privacy: scrubbed %d orders past %d days", len(res.Orders), p.cfg.RetentionDays)
+			}
+		}
+	}
+}
+
+// Expire scrubs the personal data of orders placed more than the retention
+// period before now, and of audit entries as old
+func (p *Purger) Expire(ctx context.Context, now time.Time) (Result, error) {
+	cutoff := now.AddDate(0, 0, -p.cfg.RetentionDays)
+	return p.purge(ctx,
+		func(t *queue.Token) bool { return t.Timestamp.Before(cutoff) },
+		func(e audit.Entry, _ map[string]bool) bool { return e.At.Before(cutoff) })
+}
+
+// Customer erases the personal data of every order customer, a phone
+// number or device token, placed, along with the audit entries for them
+func (p *Purger) Customer(ctx context.Context, customer string) (Result, error) {
+	if customer == "" {
+		return Result{}, fmt.Errorf("no customer given")
+	}
+	return p.purge(ctx,
+		func(t *queue.Token) bool { return t.OrderedBy(customer) },
+		func(e audit.Entry, ids map[string]bool) bool { return ids[e.OrderID] })
+}
+
+// purge scrubs the orders match picks from the manager and the archive,
+// then every event log record and other stores' record of those orders and
+// the audit entries entry picks given their IDs
+func (p *Purger) purge(ctx context.Context, match func(*queue.Token) bool, entry func(audit.Entry, map[string]bool) bool) (Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var res Result
+	ids, err := p.om.Purge(ctx, match)
+	res.Orders = ids
+	if err != nil {
+		return res, err
+	}
+	if p.archive != nil {
+		archived, err := p.archive.Scrub(match)
+		res.Orders = append(res.Orders, archived...)
+		if err != nil {
+			return res, err
+		}
+	}
+	slices.Sort(res.Orders)
+	res.Orders = slices.Compact(res.Orders)
+	if res.Orders == nil {
+		res.Orders = []string{}
+	}
+
+	picked := make(map[string]bool, len(res.Orders))
+	for _, id := range res.Orders {
+		picked[id] = true
+	}
+	held := func(t *queue.Token) bool { return picked[t.ID] || match(t) }
+	if p.events != nil {
+		if res.Events, err = p.events.Scrub(held); err != nil {
+			return res, err
+		}
+	}
+	for _, s := range p.stores {
+		n, err := s.Scrub(held)
+		res.Records += n
+		if err != nil {
+			return res, err
+		}
+	}
+	if p.audit != nil {
+		if res.AuditEntries, err = p.audit.Scrub(func(e audit.Entry) bool { return entry(e, picked) }); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
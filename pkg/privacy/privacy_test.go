@@ -0,0 +1,138 @@
+package privacy
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/archive"
+	"awesomeProject/pkg/audit"
+	"awesomeProject/pkg/clock"
+	"awesomeProject/pkg/eventlog"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// held is a store keeping copies of orders, as the spill file does
+type held []*queue.Token
+
+func (h held) Scrub(match func(*queue.Token) bool) (int, error) {
+	n := 0
+	for _, t := range h {
+		if match(t) && t.Scrub() {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	store, err := archive.Open(archive.Config{Dir: filepath.Join(dir, "archive")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, "events.jsonl")
+	events, _, err := eventlog.Open(eventlog.Config{Path: logPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+	trail, err := audit.Open(audit.Config{Path: filepath.Join(dir, "audit.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trail.Close()
+	om := manager.New(manager.DefaultConfig(), manager.WithArchiver(store), manager.WithClock(clock.NewFake(start)))
+	events.Attach(om)
+
+	// One of the customer's orders is archived by a day close, the other
+	// still waiting
+	archived, err := om.PlaceOrder(ctx, manager.NewOrder{Item: "cake", Phone: "+15550100", Notes: "for Sam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := om.PrepareOrderByID(ctx, archived.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := om.CloseDay(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waiting, err := om.PlaceOrder(ctx, manager.NewOrder{Item: "tea", Phone: "+15550100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := om.PlaceOrder(ctx, manager.NewOrder{Item: "tea", Phone: "+15550199", Notes: "for Jo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{archived.ID, other.ID} {
+		if err := trail.Record(audit.Entry{At: start, Actor: "ana", Action: audit.ActionModify, OrderID: id, Detail: map[string]string{"notes": "for someone"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A copy kept without the phone number is still picked by its ID
+	copies := held{{ID: archived.ID, Item: "cake", Notes: "for Sam"}, {ID: other.ID, Item: "tea", Phone: "+15550199"}}
+	p := New(DefaultConfig(), om, store, events, trail, copies)
+	if _, err := p.Customer(ctx, ""); err == nil {
+		t.Error("purged without a customer")
+	}
+	res, err := p.Customer(ctx, "+15550100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Orders, []string{archived.ID, waiting.ID}) || res.Events < 4 || res.AuditEntries != 1 || res.Records != 1 {
+		t.Fatalf("result = %+v", res)
+	}
+	if copies[0].Notes != "" || copies[1].Phone == "" {
+		t.Errorf("copies = %+v, %+v", copies[0], copies[1])
+	}
+	if got, _ := om.GetOrder(ctx, waiting.ID); got.Phone != "" {
+		t.Errorf("waiting order = %+v", got)
+	}
+	if records, err := eventlog.ReadFile(logPath); err != nil {
+		t.Errorf("event log no longer verifies: %v", err)
+	} else if tokens, _ := eventlog.Rebuild(records); len(tokens) != 2 {
+		t.Errorf("rebuilt %d orders", len(tokens))
+	}
+	for _, path := range []string{logPath, filepath.Join(dir, "audit.jsonl")} {
+		data, _ := os.ReadFile(path)
+		if bytes.Contains(data, []byte("+15550100")) || bytes.Contains(data, []byte("for Sam")) {
+			t.Errorf("%s still holds the customer's data", filepath.Base(path))
+		}
+		if !bytes.Contains(data, []byte("for Jo")) && !bytes.Contains(data, []byte("for someone")) {
+			t.Errorf("%s lost another customer's data", filepath.Base(path))
+		}
+	}
+	orders, err := store.Orders(start.Add(-time.Hour), start.Add(time.Hour))
+	if err != nil || len(orders) != 1 || orders[0].Phone != "" || orders[0].Notes != "" || orders[0].Item != "cake" {
+		t.Errorf("archived = %+v, %v", orders, err)
+	}
+
+	// The log is still appended to and verifies
+	if _, err := om.PrepareOrderByID(ctx, waiting.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eventlog.ReadFile(logPath); err != nil {
+		t.Errorf("after appending: %v", err)
+	}
+
+	// Past the retention period everyone's data goes
+	p = New(Config{RetentionDays: 1, Interval: DefaultConfig().Interval}, om, store, events, trail)
+	if res, err = p.Expire(ctx, start.Add(12*time.Hour)); err != nil || len(res.Orders) != 0 {
+		t.Errorf("within the period = %+v, %v", res, err)
+	}
+	if res, err = p.Expire(ctx, start.Add(48*time.Hour)); err != nil || !slices.Equal(res.Orders, []string{other.ID}) || res.AuditEntries != 1 {
+		t.Errorf("past the period = %+v, %v", res, err)
+	}
+	if got, _ := om.GetOrder(ctx, other.ID); got.Phone != "" || got.Notes != "" {
+		t.Errorf("expired order = %+v", got)
+	}
+}
@@ -0,0 +1,236 @@
+package qrcode
+
+// matrix is a symbol being drawn. Function modules (finders, timing,
+// alignment and format areas) are fixed; data and masking skip them.
+type matrix struct {
+	size     int
+	dark     [][]bool
+	function [][]bool
+}
+
+func newMatrix(v int) *matrix {
+	size := 17 + 4*v
+	m := &matrix{size: size, dark: make([][]bool, size), function: make([][]bool, size)}
+	for y := range m.dark {
+		m.dark[y] = make([]bool, size)
+		m.function[y] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		m.set(6, i, i%2 == 0)
+		m.set(i, 6, i%2 == 0)
+	}
+	m.finder(3, 3)
+	m.finder(size-4, 3)
+	m.finder(3, size-4)
+	if v > 1 {
+		// Versions 2 to 6 have one alignment pattern, near the bottom right
+		c := size - 7
+		for dy := -2; dy <= 2; dy++ {
+			for dx := -2; dx <= 2; dx++ {
+				m.set(c+dx, c+dy, max(abs(dx), abs(dy)) != 1)
+			}
+		}
+	}
+	// Reserve the format areas; drawFormat fills them
+	m.drawFormat(0)
+	return m
+}
+
+// finder draws a finder pattern centred on x, y with its light separator
+func (m *matrix) finder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= m.size || y >= m.size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			m.set(x, y, d != 2 && d != 4)
+		}
+	}
+}
+
+// set fixes a function module
+func (m *matrix) set(x, y int, dark bool) {
+	m.dark[y][x] = dark
+	m.function[y][x] = true
+}
+
+// placeData writes codewords in the zigzag order, two columns at a time from
+// the bottom right, skipping the vertical timing pattern. Modules left over
+// are the zero remainder bits.
+func (m *matrix) placeData(codewords []byte) {
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < m.size; vert++ {
+			y := vert
+			if upward {
+				y = m.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if m.function[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+				m.dark[y][x] = codewords[i/8]>>(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules selected by mask
+func (m *matrix) applyMask(mask int) {
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if !m.function[y][x] && masked(mask, x, y) {
+				m.dark[y][x] = !m.dark[y][x]
+			}
+		}
+	}
+}
+
+func masked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// formatBits returns the 15 format bits for level M and mask: the five data
+// bits, their BCH(15,5) check bits, XORed with the standard mask pattern
+func formatBits(mask int) int {
+	const levelM = 0b00
+	data := levelM<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// drawFormat writes both copies of the format bits and the dark module
+func (m *matrix) drawFormat(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		m.set(8, i, bit(i))
+	}
+	m.set(8, 7, bit(6))
+	m.set(8, 8, bit(7))
+	m.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		m.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		m.set(m.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.set(8, m.size-15+i, bit(i))
+	}
+	m.set(8, m.size-8, true)
+}
+
+// penalty scores how hard the symbol is to read; the mask with the lowest
+// score is used
+func (m *matrix) penalty() int {
+	p, dark := 0, 0
+	line := make([]bool, m.size)
+	for _, vertical := range []bool{false, true} {
+		for a := 0; a < m.size; a++ {
+			for b := range line {
+				if vertical {
+					line[b] = m.dark[b][a]
+				} else {
+					line[b] = m.dark[a][b]
+				}
+			}
+			p += linePenalty(line)
+		}
+	}
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if m.dark[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				c := m.dark[y][x]
+				if c == m.dark[y-1][x] && c == m.dark[y][x-1] && c == m.dark[y-1][x-1] {
+					p += 3
+				}
+			}
+		}
+	}
+	total := m.size * m.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return p + 10*k
+}
+
+// linePenalty scores runs of five or more and finder-like patterns in one
+// row or column, with the light quiet zone beyond its ends
+func linePenalty(line []bool) int {
+	p := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			p += 3 + run - 5
+		}
+		run = 1
+	}
+	finder := []bool{true, false, true, true, true, false, true}
+	at := func(i int) bool { return i >= 0 && i < len(line) && line[i] }
+	for i := 0; i+len(finder) <= len(line); i++ {
+		match := true
+		for j, f := range finder {
+			if line[i+j] != f {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		light := func(from int) bool {
+			for j := from; j < from+4; j++ {
+				if at(j) {
+					return false
+				}
+			}
+			return true
+		}
+		if light(i-4) || light(i+len(finder)) {
+			p += 40
+		}
+	}
+	return p
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
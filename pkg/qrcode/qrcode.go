@@ -0,0 +1,148 @@
+// Package qrcode encodes short byte strings as QR codes, for the pickup
+// codes printed on receipts. It covers what receipts need and no more: byte
+// mode, error correction level M and versions 1 to 6, which hold up to 106
+// bytes.
+package qrcode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// ErrTooLong is returned for data that does not fit in a version 6 code
+var ErrTooLong = errors.New("qrcode: data too long")
+
+// Code is an encoded QR symbol
+type Code struct {
+	Size    int      // Modules per side
+	modules [][]bool // Dark modules, indexed [y][x]
+}
+
+// Dark reports whether the module at column x, row y is dark
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// version describes the error correction level M layout of one version
+type version struct {
+	blocks   int // Error correction blocks, all the same size here
+	data     int // Data codewords per block
+	ecPerBlk int // Error correction codewords per block
+}
+
+var versions = [...]version{
+	1: {1, 16, 10},
+	2: {1, 28, 16},
+	3: {1, 44, 26},
+	4: {2, 32, 18},
+	5: {2, 43, 24},
+	6: {4, 27, 16},
+}
+
+// Encode returns the smallest code holding data
+func Encode(data []byte) (*Code, error) {
+	for v := 1; v < len(versions); v++ {
+		// Mode indicator and 8-bit length come before the data
+		if 4+8+8*len(data) <= 8*versions[v].blocks*versions[v].data {
+			return encode(data, v), nil
+		}
+	}
+	return nil, ErrTooLong
+}
+
+func encode(data []byte, v int) *Code {
+	spec := versions[v]
+	capacity := spec.blocks * spec.data
+
+	var bits bitBuffer
+	bits.append(0b0100, 4) // Byte mode
+	bits.append(uint32(len(data)), 8)
+	for _, b := range data {
+		bits.append(uint32(b), 8)
+	}
+	bits.append(0, min(4, capacity*8-len(bits))) // Terminator
+	for len(bits)%8 != 0 {
+		bits.append(0, 1)
+	}
+	codewords := bits.bytes()
+	for pad := byte(0xEC); len(codewords) < capacity; pad ^= 0xEC ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+
+	// Split into blocks, add error correction, and interleave
+	gen := generator(spec.ecPerBlk)
+	dataBlocks := make([][]byte, spec.blocks)
+	ecBlocks := make([][]byte, spec.blocks)
+	for i := range dataBlocks {
+		dataBlocks[i] = codewords[i*spec.data : (i+1)*spec.data]
+		ecBlocks[i] = remainder(dataBlocks[i], gen)
+	}
+	var final []byte
+	for i := 0; i < spec.data; i++ {
+		for _, b := range dataBlocks {
+			final = append(final, b[i])
+		}
+	}
+	for i := 0; i < spec.ecPerBlk; i++ {
+		for _, b := range ecBlocks {
+			final = append(final, b[i])
+		}
+	}
+
+	m := newMatrix(v)
+	m.placeData(final)
+	best, bestPenalty := -1, 0
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.drawFormat(mask)
+		if p := m.penalty(); best < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		m.applyMask(mask) // Masking is its own inverse
+	}
+	m.applyMask(best)
+	m.drawFormat(best)
+	return &Code{Size: m.size, modules: m.dark}
+}
+
+// PNG writes c as a black on white PNG with scale pixels per module and the
+// four-module quiet zone readers expect
+func (c *Code) PNG(w io.Writer, scale int) error {
+	scale = max(scale, 1)
+	const quiet = 4
+	side := (c.Size + 2*quiet) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex((x+quiet)*scale+dx, (y+quiet)*scale+dy, 1)
+				}
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// bitBuffer is a sequence of bits, one per element
+type bitBuffer []byte
+
+func (b *bitBuffer) append(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, byte(v>>i&1))
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	out := make([]byte, len(b)/8)
+	for i, bit := range b {
+		out[i/8] |= bit << (7 - i%8)
+	}
+	return out
+}
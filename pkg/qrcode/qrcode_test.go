@@ -0,0 +1,164 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestFormatBits(t *testing.T) {
+	// From the format information table in ISO/IEC 18004, level M
+	want := []int{
+		0b101010000010010, 0b101000100100101, 0b101111001111100, 0b101101101001011,
+		0b100010111111001, 0b100000011001110, 0b100111110010111, 0b100101010100000,
+	}
+	for mask, w := range want {
+		if got := formatBits(mask); got != w {
+			t.Errorf("mask %d: format bits %015b, want %015b", mask, got, w)
+		}
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	// The degree 10 generator used by version 1-M, as listed in the standard
+	// as exponents of α: 251 67 46 61 118 70 64 94 32 45
+	want := []int{251, 67, 46, 61, 118, 70, 64, 94, 32, 45}
+	for i, c := range generator(10) {
+		if int(gfLog[c]) != want[i] {
+			t.Fatalf("generator(10) = %v", generator(10))
+		}
+	}
+}
+
+// TestRoundTrip reads symbols back the way a scanner would: format bits,
+// unmasking, codeword order, error correction check and the byte segment
+func TestRoundTrip(t *testing.T) {
+	for _, data := range []string{"", "7:K4PX", "hello, world", strings.Repeat("x", 40), strings.Repeat("y", 106)} {
+		c, err := Encode([]byte(data))
+		if err != nil {
+			t.Fatalf("%q: %v", data, err)
+		}
+		got, err := read(c)
+		if err != nil {
+			t.Fatalf("%q (size %d): %v", data, c.Size, err)
+		}
+		if got != data {
+			t.Fatalf("read %q, want %q", got, data)
+		}
+	}
+	if _, err := Encode(make([]byte, 107)); err != ErrTooLong {
+		t.Fatalf("107 bytes: err = %v", err)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	c, _ := Encode([]byte("12:ABCD"))
+	var buf bytes.Buffer
+	if err := c.PNG(&buf, 3); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if side := (c.Size + 8) * 3; img.Bounds().Dx() != side {
+		t.Fatalf("width %d, want %d", img.Bounds().Dx(), side)
+	}
+	// Top left finder corner, after the quiet zone
+	if r, _, _, _ := img.At(12, 12).RGBA(); r != 0 {
+		t.Fatal("finder corner is not dark")
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+		t.Fatal("quiet zone is not light")
+	}
+}
+
+func read(c *Code) (string, error) {
+	v := (c.Size - 17) / 4
+	spec := versions[v]
+	m := newMatrix(v) // For the function module layout
+	var bits int
+	for i := 0; i <= 5; i++ {
+		bits |= b2i(c.Dark(8, i)) << i
+	}
+	bits |= b2i(c.Dark(8, 7))<<6 | b2i(c.Dark(8, 8))<<7 | b2i(c.Dark(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		bits |= b2i(c.Dark(14-i, 8)) << i
+	}
+	mask := -1
+	for candidate := 0; candidate < 8; candidate++ {
+		if formatBits(candidate) == bits {
+			mask = candidate
+		}
+	}
+	if mask < 0 {
+		return "", fmt.Errorf("format bits %015b match no mask", bits)
+	}
+
+	var stream []byte
+	var cur byte
+	n := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if m.function[y][x] {
+					continue
+				}
+				cur = cur<<1 | byte(b2i(c.Dark(x, y) != masked(mask, x, y)))
+				if n++; n%8 == 0 {
+					stream = append(stream, cur)
+					cur = 0
+				}
+			}
+		}
+	}
+
+	total := spec.data + spec.ecPerBlk
+	var data []byte
+	for b := 0; b < spec.blocks; b++ {
+		block := make([]byte, 0, total)
+		for i := 0; i < spec.data; i++ {
+			block = append(block, stream[i*spec.blocks+b])
+		}
+		for i := 0; i < spec.ecPerBlk; i++ {
+			block = append(block, stream[spec.blocks*spec.data+i*spec.blocks+b])
+		}
+		for i := 0; i < spec.ecPerBlk; i++ {
+			var s byte
+			for _, cw := range block {
+				s = gfMul(s, gfExp[i]) ^ cw
+			}
+			if s != 0 {
+				return "", fmt.Errorf("block %d syndrome %d is %d", b, i, s)
+			}
+		}
+		data = append(data, block[:spec.data]...)
+	}
+	if data[0]>>4 != 0b0100 {
+		return "", fmt.Errorf("mode %04b", data[0]>>4)
+	}
+	length := int(data[0]&0xF)<<4 | int(data[1]>>4)
+	out := make([]byte, length)
+	for i := range out {
+		out[i] = data[1+i]<<4 | data[2+i]>>4
+	}
+	return string(out), nil
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
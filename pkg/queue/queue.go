@@ -0,0 +1,652 @@
+// Package queue holds order tokens in a priority queue.
+package queue
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Token statuses
+const (
+	StatusScheduled       = "scheduled"        // Pre-order waiting for its release time
+	StatusWaitlisted      = "waitlisted"       // Placed while its station was full
+	StatusAwaitingPayment = "awaiting_payment" // Held until paid, when payment is required
+	StatusBlocked         = "blocked"          // Parked while its item is unavailable
+	StatusOnHold          = "on_hold"          // Later course held until the table fires it
+	StatusPreparing       = "preparing"
+	StatusInProgress      = "in_progress" // Claimed by a cook and out of the queue
+	StatusPrepared        = "prepared"
+	StatusCancelled       = "cancelled"
+	StatusPickedUp        = "picked_up"
+	StatusExpired         = "expired" // Prepared but not picked up in time
+	StatusVoided          = "voided"  // Completed or cancelled order taken off the books by a manager
+)
+
+// Statuses lists every token status in lifecycle order
+var Statuses = []string{
+	StatusScheduled, StatusOnHold, StatusAwaitingPayment, StatusWaitlisted, StatusBlocked, StatusPreparing, StatusInProgress, StatusPrepared,
+	StatusPickedUp, StatusExpired, StatusCancelled, StatusVoided,
+}
+
+// RushPriority is the priority of rushed orders. It sorts ahead of every
+// priority an order can be placed with.
+const RushPriority = -1
+
+// Order types
+const (
+	OrderDineIn   = "dine_in"
+	OrderTakeaway = "takeaway"
+	OrderDelivery = "delivery"
+)
+
+// OrderTypes lists every order type
+var OrderTypes = []string{OrderDineIn, OrderTakeaway, OrderDelivery}
+
+// ValidOrderType reports whether s is one of the order types
+func ValidOrderType(s string) bool {
+	for _, ot := range OrderTypes {
+		if s == ot {
+			return true
+		}
+	}
+	return false
+}
+
+// Channels orders are placed through
+const (
+	ChannelKiosk    = "kiosk"    // A self-service kiosk
+	ChannelCounter  = "counter"  // The counter till
+	ChannelWeb      = "web"      // The restaurant's website or app
+	ChannelPhone    = "phone"    // Taken over the phone
+	ChannelDelivery = "delivery" // A delivery platform
+)
+
+// Channels lists every channel
+var Channels = []string{ChannelKiosk, ChannelCounter, ChannelWeb, ChannelPhone, ChannelDelivery}
+
+// ValidChannel reports whether s is one of the channels
+func ValidChannel(s string) bool {
+	return slices.Contains(Channels, s)
+}
+
+// Payment statuses
+const (
+	PaymentUnpaid   = "unpaid"
+	PaymentPaid     = "paid"
+	PaymentRefunded = "refunded"
+)
+
+// PaymentStatuses lists every payment status
+var PaymentStatuses = []string{PaymentUnpaid, PaymentPaid, PaymentRefunded}
+
+// ValidPayment reports whether s is one of the payment statuses
+func ValidPayment(s string) bool {
+	for _, ps := range PaymentStatuses {
+		if s == ps {
+			return true
+		}
+	}
+	return false
+}
+
+// Allergy flags name allergens an order must be kept free of
+const (
+	FlagNuts      = "nuts"
+	FlagPeanuts   = "peanuts"
+	FlagGluten    = "gluten"
+	FlagDairy     = "dairy"
+	FlagEggs      = "eggs"
+	FlagFish      = "fish"
+	FlagShellfish = "shellfish"
+	FlagSoy       = "soy"
+	FlagSesame    = "sesame"
+)
+
+// Dietary flags
+const (
+	FlagVegetarian = "vegetarian"
+	FlagVegan      = "vegan"
+	FlagHalal      = "halal"
+	FlagKosher     = "kosher"
+)
+
+// FlagAllergy is not set on orders; filters use it to match any allergy flag
+const FlagAllergy = "allergy"
+
+// AllergyFlags and DietaryFlags list every order flag
+var (
+	AllergyFlags = []string{FlagNuts, FlagPeanuts, FlagGluten, FlagDairy, FlagEggs, FlagFish, FlagShellfish, FlagSoy, FlagSesame}
+	DietaryFlags = []string{FlagVegetarian, FlagVegan, FlagHalal, FlagKosher}
+)
+
+// ValidFlag reports whether s is one of the allergy or dietary flags
+func ValidFlag(s string) bool {
+	return IsAllergyFlag(s) || slices.Contains(DietaryFlags, s)
+}
+
+// IsAllergyFlag reports whether s is one of the allergy flags
+func IsAllergyFlag(s string) bool {
+	return slices.Contains(AllergyFlags, s)
+}
+
+// HasFlag reports whether t carries flag, or any allergy flag for FlagAllergy
+func (t *Token) HasFlag(flag string) bool {
+	if flag == FlagAllergy {
+		return slices.ContainsFunc(t.Flags, IsAllergyFlag)
+	}
+	return slices.Contains(t.Flags, flag)
+}
+
+// ValidStatus reports whether s is one of the token statuses
+func ValidStatus(s string) bool {
+	for _, st := range Statuses {
+		if s == st {
+			return true
+		}
+	}
+	return false
+}
+
+// Token represents an order with priority
+type Token struct {
+	ID        string      `json:"id"`               // Opaque; allocated by the manager's ID generator
+	Number    int         `json:"number,omitempty"` // Daily token number called to the customer; restarts after each day close
+	Item      string      `json:"item"`
+	Priority  int         `json:"priority"`  // Lower values indicate higher priority
+	Status    string      `json:"status"`    // One of the Status constants
+	Timestamp time.Time   `json:"timestamp"` // Time of order, used to resolve ties in priority
+	Quantity  int         `json:"quantity"`
+	Station   string      `json:"station,omitempty"`   // Kitchen station preparing the order; empty for the default
+	OrderType string      `json:"orderType,omitempty"` // One of the order types, empty when not given
+	Table     int         `json:"table,omitempty"`     // Table to serve dine-in orders at
+	Notes     string      `json:"notes,omitempty"`
+	Flags     []string    `json:"flags,omitempty"` // Allergy and dietary flags the kitchen must heed
+	Edits     []Edit      `json:"edits,omitempty"` // Changes made after the order was placed
+	Rules     []RuleMatch `json:"rules,omitempty"` // Priority rules that matched when the order was placed
+
+	// Attachments are files uploaded with the order, such as a cake design;
+	// the files themselves are kept in the attachment store
+	Attachments []Attachment `json:"attachments,omitempty"`
+	RushedBy    string       `json:"rushedBy,omitempty"`
+
+	// DuplicateOf is the earlier identical order from the same customer,
+	// when this one may be a double tap
+	DuplicateOf string `json:"duplicateOf,omitempty"`
+
+	// RemakeOf is the order this one remakes after it came back, and
+	// RemakeReason why; the original lists its remakes in Remakes
+	RemakeOf     string   `json:"remakeOf,omitempty"`
+	RemakeReason string   `json:"remakeReason,omitempty"`
+	Remakes      []string `json:"remakes,omitempty"`
+
+	// ImportedFrom is the order's ID in the system it was imported from
+	ImportedFrom string `json:"importedFrom,omitempty"`
+
+	// Unavailable marks an order not yet started whose item has run out
+	Unavailable bool `json:"unavailable,omitempty"`
+
+	// SLA is the wait-time target the order was placed under, when its
+	// priority has one
+	SLA *SLA `json:"sla,omitempty"`
+
+	// PromisedBy is when the order was promised ready, such as a delivery
+	// pickup slot. The manager raises its priority as the time nears and
+	// sets AtRisk while the queue projects it ready after then.
+	PromisedBy *time.Time `json:"promisedBy,omitempty"`
+	AtRisk     bool       `json:"atRisk,omitempty"`
+
+	// Void records why a manager voided the order, and whether it was
+	// refunded, once it is voided
+	Void *Void `json:"void,omitempty"`
+
+	// Total is what the order costs, when its item is priced in the
+	// order's currency
+	Total *Total `json:"total,omitempty"`
+
+	// EstimatedReadyAt is the projected ready time of a preparing order. The
+	// manager works it out afresh for each copy it hands out.
+	EstimatedReadyAt *time.Time `json:"estimatedReadyAt,omitempty"`
+
+	// Position is a waiting order's place in its station's queue, 1 for the
+	// next one up, in the order the manager's strategy would prepare them,
+	// and Ahead the number of orders before it. Like EstimatedReadyAt they
+	// are worked out for each copy handed out, and left unset while the
+	// order's station is paused.
+	Position int  `json:"position,omitempty"`
+	Ahead    *int `json:"ahead,omitempty"`
+
+	// Seq numbers orders in the order the manager placed them, and
+	// TieBreak keys them by its tie-break policy; between orders of one
+	// priority and timestamp the lower TieBreak, then the lower Seq, goes
+	// first
+	Seq      uint64 `json:"seq,omitempty"`
+	TieBreak int64  `json:"tieBreak,omitempty"`
+
+	// Version identifies the state of the order a copy was taken from, as
+	// Digest returns it. Clone sets it on every copy; clients send it back
+	// to change only the order they saw.
+	Version string `json:"version,omitempty"`
+
+	ReadyAt     *time.Time `json:"readyAt,omitempty"`   // Requested ready time for pre-orders
+	ReleaseAt   *time.Time `json:"releaseAt,omitempty"` // When a pre-order enters the queue
+	RushedAt    *time.Time `json:"rushedAt,omitempty"`  // When a manager moved the order to the front
+	ClaimedAt   *time.Time `json:"claimedAt,omitempty"` // When a cook started on the order
+	PreparedAt  *time.Time `json:"preparedAt,omitempty"`
+	PickedUpAt  *time.Time `json:"pickedUpAt,omitempty"`
+	ExpiredAt   *time.Time `json:"expiredAt,omitempty"`
+	CancelledAt *time.Time `json:"cancelledAt,omitempty"`
+
+	Payment    string     `json:"payment"`              // One of the payment statuses
+	PaymentRef string     `json:"paymentRef,omitempty"` // Provider's reference for the payment
+	PaidAt     *time.Time `json:"paidAt,omitempty"`
+	RefundedAt *time.Time `json:"refundedAt,omitempty"`
+
+	// PickupCode is printed on the customer's receipt and checked when the
+	// order is collected. It is left out of the token's JSON, so no view of
+	// an order gives it away; stores write it through Stored, and
+	// UnmarshalJSON reads it back.
+	PickupCode string `json:"-"`
+
+	// Contact details for the ready notification, set when the customer opts in
+	Phone       string `json:"phone,omitempty"`
+	DeviceToken string `json:"deviceToken,omitempty"`
+
+	// Client is where the order was placed from, such as the address of
+	// the kiosk or phone, for the per-customer quota
+	Client string `json:"client,omitempty"`
+
+	// Channel is one of the channels the order was placed through, empty
+	// when it is not known
+	Channel string `json:"channel,omitempty"`
+
+	// Platform names the delivery platform an order came through and
+	// ExternalID is that platform's ID for it, which it is told when the
+	// order is ready
+	Platform   string `json:"platform,omitempty"`
+	ExternalID string `json:"externalId,omitempty"`
+
+	// Group links the orders of one table or check. With NotifyGroup set on
+	// any of them the manager also announces when the whole group is ready.
+	Group       string `json:"group,omitempty"`
+	NotifyGroup bool   `json:"notifyGroup,omitempty"`
+
+	// Course orders a group's dishes: 1 for starters, 2 for mains and so
+	// on. Orders of a later course are held until it is fired.
+	Course int `json:"course,omitempty"`
+
+	index int // Index in the heap
+}
+
+// Edit records one field changed on an order
+type Edit struct {
+	Field string    `json:"field"`
+	From  string    `json:"from"`
+	To    string    `json:"to"`
+	At    time.Time `json:"at"`
+	By    string    `json:"by,omitempty"` // Who made the change, when known
+}
+
+// RuleMatch records a priority rule that matched an order as it was placed
+// and the priority it moved the order between
+type RuleMatch struct {
+	Rule string `json:"rule"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+// Attachment describes a file attached to an order
+type Attachment struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"` // File name as uploaded
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"` // Bytes
+	At          time.Time `json:"at"`
+}
+
+// SLA records an order's wait-time target and whether it was missed
+type SLA struct {
+	Priority   int        `json:"priority"`             // Priority class the target is set for
+	DueAt      time.Time  `json:"dueAt"`                // When the order should be prepared by
+	BreachedAt *time.Time `json:"breachedAt,omitempty"` // When it was found not prepared in time
+}
+
+// Void records a completed or cancelled order being voided, for reconciling
+// with the till
+type Void struct {
+	Reason string    `json:"reason"` // Reason code, one of those configured
+	Note   string    `json:"note,omitempty"`
+	By     string    `json:"by"`     // Manager who voided it
+	From   string    `json:"from"`   // Status the order had been closed with
+	Refund bool      `json:"refund"` // The payment was refunded with it
+	At     time.Time `json:"at"`
+}
+
+// Total is an order's price in minor units of Currency, such as cents
+type Total struct {
+	Currency    string  `json:"currency"`              // ISO 4217 code
+	Subtotal    int64   `json:"subtotal"`              // Item price times quantity, as the menu lists it
+	Tax         int64   `json:"tax"`                   // Tax at TaxRate; part of Subtotal when TaxIncluded
+	Total       int64   `json:"total"`                 // What the customer pays
+	TaxRate     float64 `json:"taxRate"`               // Percent
+	TaxIncluded bool    `json:"taxIncluded,omitempty"` // Menu prices include the tax
+}
+
+// Met reports whether the order was prepared in time, and false while it is
+// still being made
+func (s *SLA) Met(t *Token) bool {
+	return s.BreachedAt == nil && t.PreparedAt != nil && !t.PreparedAt.After(s.DueAt)
+}
+
+// Clone returns a copy of t that shares no memory with it, with its Version
+// set
+func (t *Token) Clone() *Token {
+	c := t.Copy()
+	c.Version = t.Digest()
+	return c
+}
+
+// Copy returns a copy of t that shares no memory with it, keeping its
+// Version as it is, for copies of tokens that already carry theirs
+func (t *Token) Copy() *Token {
+	c := *t
+	c.Edits = append([]Edit(nil), t.Edits...)
+	c.Flags = append([]string(nil), t.Flags...)
+	c.Rules = append([]RuleMatch(nil), t.Rules...)
+	c.Attachments = append([]Attachment(nil), t.Attachments...)
+	c.Remakes = append([]string(nil), t.Remakes...)
+	c.SLA = clonePtr(t.SLA)
+	if c.SLA != nil {
+		c.SLA.BreachedAt = clonePtr(t.SLA.BreachedAt)
+	}
+	c.Void = clonePtr(t.Void)
+	c.Total = clonePtr(t.Total)
+	c.Ahead = clonePtr(t.Ahead)
+	for _, at := range []**time.Time{
+		&c.PromisedBy, &c.EstimatedReadyAt, &c.ReadyAt, &c.ReleaseAt, &c.RushedAt, &c.ClaimedAt,
+		&c.PreparedAt, &c.PickedUpAt, &c.ExpiredAt, &c.CancelledAt, &c.PaidAt, &c.RefundedAt,
+	} {
+		*at = clonePtr(*at)
+	}
+	return &c
+}
+
+// clonePtr returns a pointer to a copy of what p points to, or nil
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// Digest hashes the state of the order: it changes whenever the order does
+// and is the same for every copy, wherever it was read from. The estimates
+// worked out for each copy are left out.
+func (t *Token) Digest() string {
+	c := *t
+	c.EstimatedReadyAt, c.Position, c.Ahead, c.Version = nil, 0, nil, ""
+	data, err := json.Marshal(&c)
+	if err != nil {
+		panic(err) // Tokens hold nothing that cannot be encoded
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Scrub erases the customer's personal data from t: their contact details,
+// where the order was placed from and its notes, which often name them,
+// along with edits of the notes. It reports whether t held any.
+func (t *Token) Scrub() bool {
+	found := t.Phone != "" || t.DeviceToken != "" || t.Client != "" || t.Notes != ""
+	t.Phone, t.DeviceToken, t.Client, t.Notes = "", "", "", ""
+	for i, e := range t.Edits {
+		if e.Field == "notes" && (e.From != "" || e.To != "") {
+			found = true
+			t.Edits[i].From, t.Edits[i].To = "", ""
+		}
+	}
+	return found
+}
+
+// OrderedBy reports whether customer, a phone number or device token, is
+// the one t notifies
+func (t *Token) OrderedBy(customer string) bool {
+	return customer != "" && (t.Phone == customer || t.DeviceToken == customer)
+}
+
+// UnmarshalJSON also reads tokens recorded when IDs were numbers, as older
+// event logs, snapshots and archives hold them, and the pickup code Stored
+// writes
+func (t *Token) UnmarshalJSON(data []byte) error {
+	type plain Token
+	v := struct {
+		*plain
+		ID          idText `json:"id"`
+		DuplicateOf idText `json:"duplicateOf"`
+		PickupCode  string `json:"pickupCode"`
+	}{plain: (*plain)(t)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	t.ID, t.DuplicateOf, t.PickupCode = string(v.ID), string(v.DuplicateOf), v.PickupCode
+	return nil
+}
+
+// Stored returns t as stores write it: its JSON with the pickup code, which
+// the token's own JSON leaves out. The order placement response carries it
+// too, as the customer's receipt needs the code.
+func (t *Token) Stored() any {
+	return struct {
+		*Token
+		PickupCode string `json:"pickupCode,omitempty"`
+	}{t, t.PickupCode}
+}
+
+// idText is an ID given as a JSON string or number
+type idText string
+
+func (s *idText) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] != '"' && string(data) != "null" {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return err
+		}
+		*s = idText(n)
+		return nil
+	}
+	return json.Unmarshal(data, (*string)(s))
+}
+
+// PriorityQueue implements a priority queue for Tokens. It satisfies
+// heap.Interface, but PushToken, PopToken, Remove and Fix keep the heap
+// themselves, without the interface calls container/heap makes for every
+// comparison, and are the way to use it at scale.
+type PriorityQueue []*Token
+
+// NewPriorityQueue returns an empty queue with room for n tokens, so filling
+// it to n does not grow it along the way
+func NewPriorityQueue(n int) PriorityQueue {
+	return make(PriorityQueue, 0, n)
+}
+
+// Len, Less, and Swap methods to satisfy the heap.Interface
+func (pq PriorityQueue) Len() int { return len(pq) }
+func (pq PriorityQueue) Less(i, j int) bool {
+	return Before(pq[i], pq[j])
+}
+func (pq PriorityQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+	pq[i].index, pq[j].index = i, j
+}
+
+// Push and Pop methods for heap
+func (pq *PriorityQueue) Push(x interface{}) {
+	n := len(*pq)
+	token := x.(*Token)
+	token.index = n
+	*pq = append(*pq, token)
+}
+
+func (pq *PriorityQueue) Pop() interface{} {
+	old := *pq
+	n := len(old)
+	token := old[n-1]
+	old[n-1] = nil // Avoid memory leak
+	token.index = -1
+	*pq = old[0 : n-1]
+	return token
+}
+
+// minShrink is the capacity below which a draining queue keeps its backing
+// array rather than handing memory back
+const minShrink = 1024
+
+// PushToken adds t to the queue
+func (pq *PriorityQueue) PushToken(t *Token) {
+	t.index = len(*pq)
+	*pq = append(*pq, t)
+	pq.up(t.index)
+}
+
+// PopToken removes and returns the token prepared first, or nil when the
+// queue is empty
+func (pq *PriorityQueue) PopToken() *Token {
+	if len(*pq) == 0 {
+		return nil
+	}
+	return pq.removeAt(0)
+}
+
+// Remove takes t out of the queue wherever it is
+func (pq *PriorityQueue) Remove(t *Token) {
+	pq.removeAt(t.index)
+}
+
+// Fix restores the ordering after t's priority or timestamp changed
+func (pq *PriorityQueue) Fix(t *Token) {
+	if !pq.down(t.index, len(*pq)) {
+		pq.up(t.index)
+	}
+}
+
+// Replace puts t in old's place and restores the ordering, leaving the heap
+// as Fix would had old been changed to t in place
+func (pq *PriorityQueue) Replace(old, t *Token) {
+	i := old.index
+	(*pq)[i], t.index, old.index = t, i, -1
+	pq.Fix(t)
+}
+
+// removeAt takes out the token at i by moving the last token into its place.
+// Once a queue that grew large has drained to a quarter of its capacity,
+// its backing array is halved, so a rush does not pin its peak memory.
+func (pq *PriorityQueue) removeAt(i int) *Token {
+	q := *pq
+	n := len(q) - 1
+	if i != n {
+		q.Swap(i, n)
+		if !q.down(i, n) {
+			q.up(i)
+		}
+	}
+	t := q[n]
+	q[n] = nil // Avoid memory leak
+	t.index = -1
+	q = q[:n]
+	if cap(q) > minShrink && n < cap(q)/4 {
+		q = append(make(PriorityQueue, 0, cap(q)/2), q...)
+	}
+	*pq = q
+	return t
+}
+
+// up moves the token at j towards the root until its parent comes first
+func (pq PriorityQueue) up(j int) {
+	for j > 0 {
+		i := (j - 1) / 2
+		if !Before(pq[j], pq[i]) {
+			break
+		}
+		pq.Swap(i, j)
+		j = i
+	}
+}
+
+// down moves the token at i0 towards the leaves of the first n tokens until
+// its children come after it, reporting whether it moved
+func (pq PriorityQueue) down(i0, n int) bool {
+	i := i0
+	for {
+		j := 2*i + 1
+		if j >= n || j < 0 {
+			break
+		}
+		if r := j + 1; r < n && Before(pq[r], pq[j]) {
+			j = r
+		}
+		if !Before(pq[j], pq[i]) {
+			break
+		}
+		pq.Swap(i, j)
+		i = j
+	}
+	return i > i0
+}
+
+// Ahead counts the queued tokens that come before t and satisfy match. Only
+// the part of the heap ahead of t is visited, since no descendant of a token
+// that does not come before t does, so the cost grows with the count rather
+// than the queue.
+func (pq PriorityQueue) Ahead(t *Token, match func(*Token) bool) int {
+	n := 0
+	var buf [64]int // Enough for most walks without allocating
+	stack := append(buf[:0], 0)
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if i >= len(pq) || !Before(pq[i], t) {
+			continue
+		}
+		if match(pq[i]) {
+			n++
+		}
+		stack = append(stack, 2*i+1, 2*i+2)
+	}
+	return n
+}
+
+// Before reports whether a is prepared ahead of b: by priority, then by
+// timestamp, then by tie-break key and the order they were placed in
+func Before(a, b *Token) bool {
+	switch {
+	case a.Priority != b.Priority:
+		return a.Priority < b.Priority
+	case !a.Timestamp.Equal(b.Timestamp):
+		return a.Timestamp.Before(b.Timestamp)
+	case a.TieBreak != b.TieBreak:
+		return a.TieBreak < b.TieBreak
+	}
+	return a.Seq < b.Seq
+}
+
+// Verify checks the heap ordering and the index stored in every token,
+// describing the first violation with the tokens involved
+func (pq PriorityQueue) Verify() error {
+	for i, t := range pq {
+		if t.index != i {
+			return fmt.Errorf("token %s at position %d has index %d", t.ID, i, t.index)
+		}
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(pq) && pq.Less(child, i) {
+				c := pq[child]
+				return fmt.Errorf("token %s (priority %d, %s) at position %d is ahead of its parent %s (priority %d, %s)",
+					c.ID, c.Priority, c.Timestamp.Format(time.RFC3339Nano), child, t.ID, t.Priority, t.Timestamp.Format(time.RFC3339Nano))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,377 @@
+package queue
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPopOrder(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return base.Add(time.Duration(sec) * time.Second) }
+
+	tests := []struct {
+		name   string
+		tokens []*Token
+		want   []string
+	}{
+		{
+			name:   "empty",
+			tokens: nil,
+			want:   nil,
+		},
+		{
+			name: "lower priority value first",
+			tokens: []*Token{
+				{ID: "1", Priority: 3, Timestamp: at(0)},
+				{ID: "2", Priority: 1, Timestamp: at(1)},
+				{ID: "3", Priority: 2, Timestamp: at(2)},
+			},
+			want: []string{"2", "3", "1"},
+		},
+		{
+			name: "ties broken by timestamp",
+			tokens: []*Token{
+				{ID: "1", Priority: 1, Timestamp: at(5)},
+				{ID: "2", Priority: 1, Timestamp: at(1)},
+				{ID: "3", Priority: 1, Timestamp: at(3)},
+			},
+			want: []string{"2", "3", "1"},
+		},
+		{
+			name: "same instant broken by tie-break key, then sequence",
+			tokens: []*Token{
+				{ID: "1", Priority: 1, Timestamp: at(0), Seq: 1, TieBreak: 2},
+				{ID: "2", Priority: 1, Timestamp: at(0), Seq: 3},
+				{ID: "3", Priority: 1, Timestamp: at(0), Seq: 2},
+				{ID: "4", Priority: 1, Timestamp: at(0), Seq: 4, TieBreak: 1},
+			},
+			want: []string{"3", "2", "4", "1"},
+		},
+		{
+			name: "priority beats earlier timestamp",
+			tokens: []*Token{
+				{ID: "1", Priority: 2, Timestamp: at(0)},
+				{ID: "2", Priority: 1, Timestamp: at(9)},
+			},
+			want: []string{"2", "1"},
+		},
+		{
+			name: "negative priorities",
+			tokens: []*Token{
+				{ID: "1", Priority: 0, Timestamp: at(0)},
+				{ID: "2", Priority: -1, Timestamp: at(1)},
+			},
+			want: []string{"2", "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pq := make(PriorityQueue, 0)
+			heap.Init(&pq)
+			for _, tok := range tt.tokens {
+				heap.Push(&pq, tok)
+				if err := pq.Verify(); err != nil {
+					t.Fatalf("after push %s: %v", tok.ID, err)
+				}
+			}
+
+			var got []string
+			for pq.Len() > 0 {
+				tok := heap.Pop(&pq).(*Token)
+				if tok.index != -1 {
+					t.Errorf("popped token %s still has index %d", tok.ID, tok.index)
+				}
+				if err := pq.Verify(); err != nil {
+					t.Fatalf("after pop %s: %v", tok.ID, err)
+				}
+				got = append(got, tok.ID)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("popped %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("popped %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyDetectsCorruption(t *testing.T) {
+	pq := PriorityQueue{
+		{ID: "1", Priority: 5, index: 0},
+		{ID: "2", Priority: 1, index: 1},
+	}
+	if err := pq.Verify(); err == nil {
+		t.Error("expected heap order violation")
+	}
+
+	pq = PriorityQueue{{ID: "1", Priority: 1, index: 3}}
+	if err := pq.Verify(); err == nil {
+		t.Error("expected index mismatch")
+	}
+}
+
+func TestClone(t *testing.T) {
+	orig := &Token{ID: "1", Item: "tea", Priority: 2}
+	c := orig.Clone()
+	c.Item = "coffee"
+	if orig.Item != "tea" {
+		t.Errorf("clone shares memory with original")
+	}
+	if c.Version != orig.Digest() {
+		t.Errorf("clone version = %q, want %q", c.Version, orig.Digest())
+	}
+}
+
+func TestDigest(t *testing.T) {
+	now := time.Now()
+	tok := &Token{ID: "1", Item: "tea", Priority: 2, Timestamp: now}
+	before := tok.Digest()
+
+	// The estimates carried by each copy are not part of the order
+	tok.EstimatedReadyAt, tok.Position = &now, 3
+	if tok.Digest() != before {
+		t.Error("estimates changed the digest")
+	}
+	var decoded Token
+	data, _ := json.Marshal(tok)
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Digest() != before {
+		t.Errorf("digest after a round trip = %q, %v, want %q", decoded.Digest(), err, before)
+	}
+	tok.Quantity = 2
+	if tok.Digest() == before {
+		t.Error("digest unchanged after a change")
+	}
+}
+
+func TestScrub(t *testing.T) {
+	tok := &Token{ID: "1", Item: "cake", Phone: "+15550100", Client: "10.0.0.9", Notes: "for Sam",
+		Edits: []Edit{{Field: "notes", From: "Sam", To: "for Sam"}, {Field: "quantity", From: "1", To: "2"}}}
+	if !tok.OrderedBy("+15550100") || tok.OrderedBy("") {
+		t.Errorf("ordered by = %v, %v", tok.OrderedBy("+15550100"), tok.OrderedBy(""))
+	}
+	if !tok.Scrub() || tok.Phone != "" || tok.Client != "" || tok.Notes != "" || tok.Edits[0].To != "" || tok.Edits[1].To != "2" || tok.Item != "cake" {
+		t.Errorf("scrubbed = %+v", tok)
+	}
+	if tok.Scrub() {
+		t.Error("nothing left to scrub, yet Scrub found some")
+	}
+}
+
+func TestAhead(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var pq PriorityQueue
+	var tokens []*Token
+	for i := 0; i < 200; i++ {
+		// Few distinct priorities and timestamps, so ties are common
+		tok := &Token{ID: strconv.Itoa(i), Priority: i*7%5 - 1, Timestamp: base.Add(time.Duration(i*13%17) * time.Second), Station: []string{"grill", "bar"}[i%2]}
+		heap.Push(&pq, tok)
+		tokens = append(tokens, tok)
+	}
+	for _, tok := range tokens {
+		want := 0
+		for _, o := range tokens {
+			if o.Station == tok.Station && Before(o, tok) {
+				want++
+			}
+		}
+		if got := pq.Ahead(tok, func(o *Token) bool { return o.Station == tok.Station }); got != want {
+			t.Fatalf("token %s: %d ahead, want %d", tok.ID, got, want)
+		}
+	}
+	if n := pq.Ahead(&Token{Priority: -5}, func(*Token) bool { return true }); n != 0 {
+		t.Fatalf("%d ahead of the front", n)
+	}
+}
+
+func TestUnmarshalNumericIDs(t *testing.T) {
+	for data, want := range map[string][2]string{
+		`{"id": 12, "item": "tea", "duplicateOf": 7}`:            {"12", "7"},
+		`{"id": "01J9Z3K8QX", "item": "tea", "duplicateOf": ""}`: {"01J9Z3K8QX", ""},
+		`{"id": "4", "item": "tea"}`:                             {"4", ""},
+	} {
+		var tok Token
+		if err := json.Unmarshal([]byte(data), &tok); err != nil {
+			t.Fatalf("%s: %v", data, err)
+		}
+		if tok.ID != want[0] || tok.DuplicateOf != want[1] || tok.Item != "tea" {
+			t.Errorf("%s: decoded %+v", data, tok)
+		}
+	}
+	var tok Token
+	if err := json.Unmarshal([]byte(`{"id": true}`), &tok); err == nil {
+		t.Error("boolean ID accepted")
+	}
+}
+
+func TestStoredPickupCode(t *testing.T) {
+	tok := &Token{ID: "1", Item: "tea", PickupCode: "K7QM"}
+	plain, err := json.Marshal(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(plain), "K7QM") {
+		t.Errorf("token JSON holds the pickup code: %s", plain)
+	}
+	stored, err := json.Marshal(tok.Stored())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Token
+	if err := json.Unmarshal(stored, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.PickupCode != "K7QM" || back.ID != "1" || back.Item != "tea" {
+		t.Errorf("stored %s read back as %+v", stored, back)
+	}
+}
+
+func TestHeapOperations(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	pq := NewPriorityQueue(0)
+	var queued []*Token
+	for i := 0; i < 5000; i++ {
+		switch op := rng.IntN(10); {
+		case op < 5 || len(queued) == 0:
+			tok := &Token{ID: strconv.Itoa(i), Priority: rng.IntN(5), Timestamp: base.Add(time.Duration(rng.IntN(100)) * time.Second)}
+			pq.PushToken(tok)
+			queued = append(queued, tok)
+		case op < 7:
+			k := rng.IntN(len(queued))
+			pq.Remove(queued[k])
+			if queued[k].index != -1 {
+				t.Fatalf("removed token %s kept index %d", queued[k].ID, queued[k].index)
+			}
+			queued = append(queued[:k], queued[k+1:]...)
+		case op < 9:
+			tok := queued[rng.IntN(len(queued))]
+			tok.Priority = rng.IntN(5) - 1
+			pq.Fix(tok)
+		default:
+			tok := pq.PopToken()
+			for _, o := range queued {
+				if Before(o, tok) {
+					t.Fatalf("popped %s ahead of %s", tok.ID, o.ID)
+				}
+			}
+			queued = slices.DeleteFunc(queued, func(o *Token) bool { return o == tok })
+		}
+		if err := pq.Verify(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+	if pq.Len() != len(queued) {
+		t.Fatalf("queue holds %d tokens, want %d", pq.Len(), len(queued))
+	}
+
+	for pq.Len() > 0 {
+		pq.PopToken()
+	}
+	if pq.PopToken() != nil {
+		t.Fatal("popped a token from an empty queue")
+	}
+	big := NewPriorityQueue(1 << 16)
+	for i := 0; i < 1<<16; i++ {
+		big.PushToken(&Token{ID: strconv.Itoa(i), Priority: i % 7})
+	}
+	for big.Len() > 10 {
+		big.PopToken()
+	}
+	if cap(big) > minShrink*2 {
+		t.Errorf("drained queue kept capacity %d", cap(big))
+	}
+}
+
+// benchmarkSizes are the queue lengths the heap benchmarks run at
+var benchmarkSizes = []int{10_000, 100_000, 1_000_000}
+
+// benchTokens returns n tokens with a spread of priorities and times
+func benchTokens(n int, seed uint64) []*Token {
+	rng := rand.New(rand.NewPCG(seed, 0))
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tokens := make([]*Token, n)
+	for i := range tokens {
+		tokens[i] = &Token{ID: strconv.Itoa(i), Priority: rng.IntN(10), Timestamp: base.Add(time.Duration(rng.IntN(86400)) * time.Second)}
+	}
+	return tokens
+}
+
+// benchQueue returns a queue holding tokens
+func benchQueue(tokens []*Token) PriorityQueue {
+	pq := NewPriorityQueue(len(tokens))
+	for _, tok := range tokens {
+		pq.PushToken(tok)
+	}
+	return pq
+}
+
+func BenchmarkPush(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			pq := benchQueue(benchTokens(n, 1))
+			extra := benchTokens(b.N, 2)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := range b.N {
+				pq.PushToken(extra[i])
+			}
+		})
+	}
+}
+
+func BenchmarkPop(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			pq := benchQueue(benchTokens(n+b.N, 1))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				pq.PopToken()
+			}
+		})
+	}
+}
+
+func BenchmarkRemove(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			tokens := benchTokens(n+b.N, 1)
+			pq := benchQueue(tokens)
+			rand.New(rand.NewPCG(3, 0)).Shuffle(len(tokens), func(i, j int) { tokens[i], tokens[j] = tokens[j], tokens[i] })
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := range b.N {
+				pq.Remove(tokens[i])
+			}
+		})
+	}
+}
+
+func BenchmarkFix(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			tokens := benchTokens(n, 1)
+			pq := benchQueue(tokens)
+			rng := rand.New(rand.NewPCG(3, 0))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				tok := tokens[rng.IntN(n)]
+				tok.Priority = rng.IntN(10)
+				pq.Fix(tok)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+// Package redisqueue keeps the waiting order queue in Redis so that several
+// server instances behind a load balancer share one queue.
+//
+// Waiting tokens live in a sorted set scored by priority and then timestamp,
+// with their JSON in a hash beside it; order IDs come from a counter in the
+// same keyspace. Every change touching both keys runs as a Lua script, so a
+// token is popped by exactly one instance. Scheduled, prepared and closed
+// orders stay with the instance that handled them.
+package redisqueue
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/config"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// Config holds the Redis connection settings
+type Config struct {
+	Addr     string          `json:"addr"` // host:port
+	Password string          `json:"password"`
+	DB       int             `json:"db"`
+	Key      string          `json:"key"` // Prefix of the keys used; instances sharing a queue use the same one
+	Timeout  config.Duration `json:"timeout"`
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		Addr:    "localhost:6379",
+		Key:     "orders",
+		Timeout: config.Duration(5 * time.Second),
+	}
+}
+
+// Queue is a manager.Queue stored in Redis
+type Queue struct {
+	client *client
+
+	// The keys share a hash tag so scripts can use them on Redis Cluster
+	queueKey  string // Sorted set of waiting token IDs
+	tokensKey string // Hash of token ID to token JSON
+	seqKey    string // Order ID counter
+}
+
+var (
+	_ manager.Queue    = (*Queue)(nil)
+	_ manager.IDSource = (*Queue)(nil)
+)
+
+// Open connects to Redis and checks that it answers
+func Open(cfg Config) (*Queue, error) {
+	q := &Queue{
+		client:    &client{cfg: cfg},
+		queueKey:  fmt.Sprintf("{%s}:queue", cfg.Key),
+		tokensKey: fmt.Sprintf("{%s}:tokens", cfg.Key),
+		seqKey:    fmt.Sprintf("{%s}:seq", cfg.Key),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout))
+	defer cancel()
+	if _, err := q.client.do(ctx, "PING"); err != nil {
+		return nil, fmt.Errorf("connect to redis at %s: %w", cfg.Addr, err)
+	}
+	return q, nil
+}
+
+// Close releases the connections
+func (q *Queue) Close() error {
+	return q.client.close()
+}
+
+// score orders tokens by priority, then by timestamp in milliseconds. Both fit
+// a float64 exactly for priorities up to several hundred; ties within one
+// millisecond fall back to Redis' ordering of the IDs as strings, as the
+// score has no room left for the manager's tie-break key.
+func score(t *queue.Token) string {
+	s := float64(t.Priority)*1e13 + float64(t.Timestamp.UnixMilli())
+	return strconv.FormatFloat(s, 'f', -1, 64)
+}
+
+// script is a Lua script run by hash, loaded on first use
+type script struct {
+	src string
+	sha string
+}
+
+func newScript(src string) *script {
+	sum := sha1.Sum([]byte(src))
+	return &script{src: src, sha: hex.EncodeToString(sum[:])}
+}
+
+var (
+	pushScript = newScript(`
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[3])
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+return 1`)
+
+	popScript = newScript(`
+local ids = redis.call('ZRANGE', KEYS[1], 0, 0)
+if #ids == 0 then return false end
+redis.call('ZREM', KEYS[1], ids[1])
+local t = redis.call('HGET', KEYS[2], ids[1])
+redis.call('HDEL', KEYS[2], ids[1])
+return t`)
+
+	removeScript = newScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then return false end
+local t = redis.call('HGET', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+return t`)
+
+	updateScript = newScript(`
+if not redis.call('ZSCORE', KEYS[1], ARGV[1]) then return 0 end
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[3])
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+return 1`)
+
+	listScript = newScript(`
+local ids = redis.call('ZRANGE', KEYS[1], 0, -1)
+local out = {}
+for i, id in ipairs(ids) do out[i] = redis.call('HGET', KEYS[2], id) end
+return out`)
+)
+
+// eval runs s against the queue's keys
+func (q *Queue) eval(ctx context.Context, s *script, args ...string) (any, error) {
+	cmd := append([]string{"EVALSHA", s.sha, "2", q.queueKey, q.tokensKey}, args...)
+	reply, err := q.client.do(ctx, cmd...)
+	var re redisError
+	if errors.As(err, &re) && strings.HasPrefix(string(re), "NOSCRIPT") {
+		cmd[0], cmd[1] = "EVAL", s.src
+		reply, err = q.client.do(ctx, cmd...)
+	}
+	return reply, err
+}
+
+// write runs a script taking a token's ID, score and JSON
+func (q *Queue) write(ctx context.Context, s *script, t *queue.Token) (any, error) {
+	data, err := json.Marshal(t.Stored())
+	if err != nil {
+		return nil, err
+	}
+	return q.eval(ctx, s, t.ID, score(t), string(data))
+}
+
+// decode turns a bulk reply holding token JSON into a token, or nil
+func decode(reply any) (*queue.Token, error) {
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, nil
+	}
+	var t queue.Token
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("decode queued token: %w", err)
+	}
+	return &t, nil
+}
+
+func (q *Queue) Push(ctx context.Context, t *queue.Token) error {
+	_, err := q.write(ctx, pushScript, t)
+	return err
+}
+
+func (q *Queue) Pop(ctx context.Context) (*queue.Token, error) {
+	reply, err := q.eval(ctx, popScript)
+	if err != nil {
+		return nil, err
+	}
+	return decode(reply)
+}
+
+func (q *Queue) Get(ctx context.Context, id string) (*queue.Token, error) {
+	reply, err := q.client.do(ctx, "HGET", q.tokensKey, id)
+	if err != nil {
+		return nil, err
+	}
+	return decode(reply)
+}
+
+func (q *Queue) Remove(ctx context.Context, id string) (*queue.Token, error) {
+	reply, err := q.eval(ctx, removeScript, id)
+	if err != nil {
+		return nil, err
+	}
+	return decode(reply)
+}
+
+func (q *Queue) Update(ctx context.Context, t *queue.Token) error {
+	reply, err := q.write(ctx, updateScript, t)
+	if err != nil {
+		return err
+	}
+	if n, _ := reply.(int64); n == 0 {
+		return manager.ErrNotQueued
+	}
+	return nil
+}
+
+// List returns the queued tokens in the order they will be prepared
+func (q *Queue) List(ctx context.Context) ([]*queue.Token, error) {
+	reply, err := q.eval(ctx, listScript)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]any)
+	tokens := make([]*queue.Token, 0, len(items))
+	for _, item := range items {
+		t, err := decode(item)
+		if err != nil {
+			return nil, err
+		}
+		if t != nil {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
+
+func (q *Queue) Len(ctx context.Context) (int, error) {
+	reply, err := q.client.do(ctx, "ZCARD", q.queueKey)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := reply.(int64)
+	return int(n), nil
+}
+
+// NextID allocates an order ID from the shared counter
+func (q *Queue) NextID(ctx context.Context) (int, error) {
+	reply, err := q.client.do(ctx, "INCR", q.seqKey)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := reply.(int64)
+	return int(n), nil
+}
@@ -0,0 +1,104 @@
+package redisqueue
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"+OK\r\n", "OK"},
+		{":42\r\n", "42"},
+		{"$5\r\nhello\r\n", "[104 101 108 108 111]"},
+		{"$-1\r\n", "<nil>"},
+		{"*3\r\n:1\r\n$-1\r\n$2\r\nhi\r\n", "[1 <nil> [104 105]]"},
+		{"*0\r\n", "[]"},
+	}
+	for _, tt := range tests {
+		got, err := readReply(bufio.NewReader(strings.NewReader(tt.in)))
+		if err != nil {
+			t.Fatalf("readReply(%q): %v", tt.in, err)
+		}
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("readReply(%q) = %v, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	_, err := readReply(bufio.NewReader(strings.NewReader("-NOSCRIPT No matching script\r\n")))
+	if re, ok := err.(redisError); !ok || !strings.HasPrefix(string(re), "NOSCRIPT") {
+		t.Fatalf("error reply = %v", err)
+	}
+}
+
+func TestScoreOrdersByPriorityThenTime(t *testing.T) {
+	now := time.Now()
+	a := &queue.Token{Priority: 1, Timestamp: now.Add(time.Hour)}
+	b := &queue.Token{Priority: 2, Timestamp: now}
+	c := &queue.Token{Priority: 2, Timestamp: now.Add(time.Millisecond)}
+	var sa, sb, sc float64
+	fmt.Sscan(score(a), &sa)
+	fmt.Sscan(score(b), &sb)
+	fmt.Sscan(score(c), &sc)
+	if !(sa < sb && sb < sc) {
+		t.Fatalf("scores %v %v %v are not in preparation order", sa, sb, sc)
+	}
+}
+
+// TestSharedQueue runs two managers against a real Redis named by REDIS_ADDR
+func TestSharedQueue(t *testing.T) {
+	ctx := context.Background()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+	cfg := DefaultConfig()
+	cfg.Addr = addr
+	cfg.Key = fmt.Sprintf("test-%d", time.Now().UnixNano())
+	open := func() *manager.OrderManager {
+		q, err := Open(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			q.client.do(context.Background(), "DEL", q.queueKey, q.tokensKey, q.seqKey)
+			q.Close()
+		})
+		return manager.New(manager.DefaultConfig(), manager.WithQueue(q))
+	}
+	one, two := open(), open()
+
+	low, err := one.AddOrder(ctx, "low", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := two.AddOrder(ctx, "high", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if low.ID == high.ID {
+		t.Fatalf("both instances allocated ID %s", low.ID)
+	}
+
+	got, err := one.PrepareOrder(ctx)
+	if err != nil || got.ID != high.ID {
+		t.Fatalf("first instance prepared %+v, %v, want order %s", got, err, high.ID)
+	}
+	got, err = two.PrepareOrder(ctx)
+	if err != nil || got.ID != low.ID {
+		t.Fatalf("second instance prepared %+v, %v, want order %s", got, err, low.ID)
+	}
+	if _, err := one.PrepareOrder(ctx); err != manager.ErrQueueEmpty {
+		t.Fatalf("drained queue error = %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package redisqueue
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxIdle bounds the connections kept open between commands
+const maxIdle = 8
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// conn is one connection speaking RESP
+type conn struct {
+	nc net.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+}
+
+// client is a minimal Redis client with a small connection pool
+type client struct {
+	cfg  Config
+	mu   sync.Mutex
+	idle []*conn
+}
+
+// do sends one command and returns its reply: a string for simple replies,
+// int64, []byte or nil for bulk strings, []any for arrays. Error replies are
+// returned as redisError. The command gives up after the configured timeout
+// or when ctx is done, whichever comes first.
+func (c *client) do(ctx context.Context, args ...string) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	deadline := c.deadline(ctx)
+	cn, err := c.get(ctx, deadline)
+	if err != nil {
+		return nil, err
+	}
+	// Cut the command short when ctx is done; the connection is then
+	// mid-reply and cannot be reused
+	stop := context.AfterFunc(ctx, func() { cn.nc.SetDeadline(time.Now()) })
+	reply, err := cn.do(deadline, args...)
+	if !stop() {
+		cn.nc.Close()
+		return nil, ctx.Err()
+	}
+	var re redisError
+	if err != nil && !errors.As(err, &re) {
+		cn.nc.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// deadline returns when a command started now must finish: after the
+// configured timeout, or at ctx's deadline when that is sooner
+func (c *client) deadline(ctx context.Context) time.Time {
+	var deadline time.Time
+	if c.cfg.Timeout > 0 {
+		deadline = time.Now().Add(time.Duration(c.cfg.Timeout))
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	return deadline
+}
+
+func (c *client) get(ctx context.Context, deadline time.Time) (*conn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+	return c.dial(ctx, deadline)
+}
+
+func (c *client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.idle) >= maxIdle {
+		cn.nc.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+func (c *client) dial(ctx context.Context, deadline time.Time) (*conn, error) {
+	d := net.Dialer{Deadline: deadline}
+	nc, err := d.DialContext(ctx, "tcp", c.cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{nc: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	if c.cfg.Password != "" {
+		if _, err := cn.do(deadline, "AUTH", c.cfg.Password); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if c.cfg.DB != 0 {
+		if _, err := cn.do(deadline, "SELECT", strconv.Itoa(c.cfg.DB)); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// close drops every idle connection
+func (c *client) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cn := range c.idle {
+		cn.nc.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+func (cn *conn) do(deadline time.Time, args ...string) (any, error) {
+	cn.nc.SetDeadline(deadline)
+	fmt.Fprintf(cn.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(cn.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(cn.r)
+}
+
+// readReply parses one RESP2 reply
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			// An error inside an array is a value, not a failure of the reply
+			item, err := readReply(r)
+			var re redisError
+			if err != nil && !errors.As(err, &re) {
+				return nil, err
+			}
+			if err != nil {
+				item = err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
@@ -0,0 +1,362 @@
+// Package reports sends owners summaries of trading on a schedule: at the
+// end of each day or week, the orders taken, revenue where items are priced,
+// wait times, the top items and how often wait-time targets were met.
+//
+// Each schedule says when to send, as a cron expression in local time, and
+// who to: owners are emailed through the notifications' mail server, and a
+// webhook is posted the report as JSON. A report covers the day or week
+// ending as it is sent, taking the orders of closed days from the archive.
+package reports
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"awesomeProject/pkg/analytics"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/money"
+	"awesomeProject/pkg/notify"
+	"awesomeProject/pkg/queue"
+)
+
+// Periods a report can cover
+const (
+	PeriodDay  = "day"
+	PeriodWeek = "week"
+)
+
+// Periods lists every period
+var Periods = []string{PeriodDay, PeriodWeek}
+
+// SignatureHeader carries the webhook body's HMAC-SHA256 as "sha256=<hex>"
+const SignatureHeader = "X-Signature"
+
+// EventReport is the event of every webhook post
+const EventReport = "report"
+
+// Schedule is one report to send
+type Schedule struct {
+	Name   string `json:"name"`   // Shown in the subject; defaults to the period
+	Period string `json:"period"` // One of the periods
+	Cron   string `json:"cron"`   // minute hour day-of-month month day-of-week, like "30 23 * * *"
+
+	// Emails are the owners' addresses, mailed when the notifications have
+	// a mail server
+	Emails []string `json:"emails"`
+
+	// Webhook is posted the report as JSON, signed with Secret when it is
+	// set
+	Webhook string `json:"webhook"`
+	Secret  string `json:"secret"`
+}
+
+// name returns the schedule's name, or one made from its period
+func (s Schedule) name() string {
+	switch {
+	case s.Name != "":
+		return s.Name
+	case s.Period == PeriodDay:
+		return "daily"
+	}
+	return s.Period + "ly"
+}
+
+// span is the time a report sent at covers
+func (s Schedule) span(at time.Time) (time.Time, time.Time) {
+	if s.Period == PeriodWeek {
+		return at.AddDate(0, 0, -7), at
+	}
+	return at.AddDate(0, 0, -1), at
+}
+
+// Config lists the schedules; with none nothing is sent
+type Config struct {
+	Schedules []Schedule `json:"schedules"`
+	TopItems  int        `json:"topItems"` // Items listed in the text, most ordered first
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{TopItems: 5}
+}
+
+// Validate checks the schedules
+func (c Config) Validate() error {
+	names := make(map[string]bool)
+	for _, s := range c.Schedules {
+		switch {
+		case !slices.Contains(Periods, s.Period):
+			return fmt.Errorf("report %s: unknown period %q, want one of %v", s.name(), s.Period, Periods)
+		case len(s.Emails) == 0 && s.Webhook == "":
+			return fmt.Errorf("report %s: needs emails or a webhook to send to", s.name())
+		case names[s.name()]:
+			return fmt.Errorf("report %s: name used twice", s.name())
+		}
+		if _, err := parseCron(s.Cron); err != nil {
+			return fmt.Errorf("report %s: %w", s.name(), err)
+		}
+		names[s.name()] = true
+	}
+	if c.TopItems < 0 {
+		return fmt.Errorf("reports topItems must not be negative")
+	}
+	return nil
+}
+
+// History holds orders the manager no longer keeps in memory, as the
+// archive does those of closed days
+type History interface {
+	Orders(from, to time.Time) ([]*queue.Token, error)
+}
+
+// sendTimeout bounds each mail and webhook post
+const sendTimeout = 30 * time.Second
+
+// Scheduler sends the reports of a manager's orders
+type Scheduler struct {
+	cfg     Config
+	crons   []cron
+	om      *manager.OrderManager
+	history History         // Optional
+	mail    notify.Provider // Optional; mails the schedules' Emails
+	client  *http.Client
+}
+
+// New sends the reports cfg schedules on om's orders and those in history,
+// when it is not nil, mailing through mail when it is not nil and posting
+// webhooks through hc, or http.DefaultClient when it is nil. Call Run to
+// send on schedule.
+func New(cfg Config, om *manager.OrderManager, history History, mail notify.Provider, hc *http.Client) (*Scheduler, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	s := &Scheduler{cfg: cfg, om: om, history: history, mail: mail, client: hc}
+	for _, sch := range cfg.Schedules {
+		c, _ := parseCron(sch.Cron)
+		s.crons = append(s.crons, c)
+	}
+	return s, nil
+}
+
+// Run sends each report when its schedule comes round, until ctx is
+// cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	if len(s.crons) == 0 {
+		return
+	}
+	now := time.Now()
+	due := make([]time.Time, len(s.crons))
+	for i, c := range s.crons {
+		due[i] = c.next(now)
+	}
+	for {
+		first := -1
+		for i, at := range due {
+			if !at.IsZero() && (first < 0 || at.Before(due[first])) {
+				first = i
+			}
+		}
+		if first < 0 {
+			return // No schedule ever runs again
+		}
+		timer := time.NewTimer(time.Until(due[first]))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		at := due[first]
+		for i := range due {
+			if due[i].Equal(at) {
+				sch := s.cfg.Schedules[i]
+				if err := s.send(ctx, sch, at); err != nil {
+					log.Printf("reports: %s: %v", sch.name(), err)
+				}
+				due[i] = s.crons[i].next(at)
+			}
+		}
+	}
+}
+
+// Send sends the report called name as of now, as its schedule would
+func (s *Scheduler) Send(ctx context.Context, name string, now time.Time) error {
+	for _, sch := range s.cfg.Schedules {
+		if sch.name() == name {
+			return s.send(ctx, sch, now)
+		}
+	}
+	return fmt.Errorf("no report named %q", name)
+}
+
+// Build reports on the orders placed in the period of sch ending at
+func (s *Scheduler) Build(ctx context.Context, sch Schedule, at time.Time) (analytics.Report, error) {
+	from, to := sch.span(at)
+	live, _, err := s.om.QueryOrders(ctx, manager.OrderFilter{From: from, To: to})
+	if err != nil {
+		return analytics.Report{}, err
+	}
+	orders := live
+	if s.history != nil {
+		archived, err := s.history.Orders(from, to)
+		if err != nil {
+			return analytics.Report{}, err
+		}
+		// Of an order in both, such as one replayed by the event log, the
+		// manager's copy is the newer
+		seen := make(map[string]bool, len(live))
+		for _, t := range live {
+			seen[t.ID] = true
+		}
+		for _, t := range archived {
+			if !seen[t.ID] {
+				orders = append(orders, t)
+			}
+		}
+	}
+	return analytics.Compute(orders, from, to, time.Local), nil
+}
+
+// Delivery is the JSON body posted to a schedule's webhook
+type Delivery struct {
+	Event  string           `json:"event"` // EventReport
+	Name   string           `json:"name"`
+	Period string           `json:"period"`
+	Report analytics.Report `json:"report"`
+	Text   string           `json:"text"` // As mailed
+}
+
+// send builds sch's report as of at and delivers it everywhere sch says,
+// returning the first error once every delivery has been tried
+func (s *Scheduler) send(ctx context.Context, sch Schedule, at time.Time) error {
+	r, err := s.Build(ctx, sch, at)
+	if err != nil {
+		return err
+	}
+	subject := Subject(sch.name(), r)
+	text := Text(r, s.cfg.TopItems)
+	log.Printf("reports: sending %s", subject)
+
+	var errs []error
+	if sch.Webhook != "" {
+		d := Delivery{Event: EventReport, Name: sch.name(), Period: sch.Period, Report: r, Text: text}
+		if err := s.post(ctx, sch, d); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		}
+	}
+	if s.mail != nil {
+		for _, to := range sch.Emails {
+			ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+			if err := s.mail.Send(ctx, notify.Message{To: to, Subject: subject, Body: text}); err != nil {
+				errs = append(errs, fmt.Errorf("mail to %s: %w", to, err))
+			}
+			cancel()
+		}
+	} else if len(sch.Emails) > 0 {
+		errs = append(errs, fmt.Errorf("no mail server configured for %d addresses", len(sch.Emails)))
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// post sends d to sch's webhook
+func (s *Scheduler) post(ctx context.Context, sch Schedule, d Delivery) error {
+	body, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sch.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sch.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(sch.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Subject is the mail subject of the report named name
+func Subject(name string, r analytics.Report) string {
+	return fmt.Sprintf("%s report, %s to %s", capitalize(name),
+		r.From.Local().Format("Mon 2 Jan 15:04"), r.To.Local().Format("Mon 2 Jan 15:04"))
+}
+
+// Text lays r out as a plain-text summary listing up to top items
+func Text(r analytics.Report, top int) string {
+	var b strings.Builder
+	line := func(format string, args ...any) { fmt.Fprintf(&b, format+"\n", args...) }
+	line("Orders: %d", r.Orders)
+	line("  prepared %d, picked up %d, expired %d, cancelled %d, voided %d, still open %d",
+		r.Prepared, r.PickedUp, r.Expired, r.Cancelled, r.Voided, r.Pending)
+	if r.Remakes > 0 {
+		line("  remade %d", r.Remakes)
+	}
+	for _, rv := range r.Revenue {
+		line("Revenue: %s over %d orders, tax %s", money.Format(rv.Total, rv.Currency), rv.Orders, money.Format(rv.Tax, rv.Currency))
+		if rv.Refunded != 0 {
+			line("  refunded %s", money.Format(rv.Refunded, rv.Currency))
+		}
+	}
+	if r.Prepared > 0 {
+		line("Preparation time: %s on average, %s at the 95th percentile", seconds(r.AvgPrepSeconds), seconds(r.P95PrepSeconds))
+	}
+	if r.PeakHour != "" {
+		line("Busiest hour: %s:00", r.PeakHour)
+	}
+	if n := min(top, len(r.Items)); n > 0 {
+		line("Top items:")
+		for i, ic := range r.Items[:n] {
+			line("  %d. %s: %d orders, %d sold", i+1, ic.Item, ic.Orders, ic.Quantity)
+		}
+	}
+	if len(r.SLA) > 0 {
+		line("Wait-time targets:")
+		for _, c := range r.SLA {
+			line("  priority %d: %.1f%% met (%d of %d)", c.Priority, c.Attainment, c.Met, c.Orders)
+		}
+	}
+	return b.String()
+}
+
+// seconds writes a number of seconds as a duration like 4m30s
+func seconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(time.Second).String()
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,174 @@
+package reports
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/clock"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/notify"
+	"awesomeProject/pkg/queue"
+)
+
+// mails records the messages sent through it
+type mails struct {
+	mu   sync.Mutex
+	sent []notify.Message
+}
+
+func (m *mails) Name() string { return "test" }
+
+func (m *mails) Send(_ context.Context, msg notify.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// archived is a History of fixed orders
+type archived []*queue.Token
+
+func (a archived) Orders(from, to time.Time) ([]*queue.Token, error) {
+	var out []*queue.Token
+	for _, t := range a {
+		if !t.Timestamp.Before(from) && t.Timestamp.Before(to) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func TestCron(t *testing.T) {
+	at := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC) // A Friday
+	for _, tt := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"30 23 * * *", time.Date(2024, time.March, 1, 23, 30, 0, 0, time.UTC)},
+		{"0 12 * * *", time.Date(2024, time.March, 2, 12, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.March, 1, 12, 15, 0, 0, time.UTC)},
+		{"0 8 * * 1", time.Date(2024, time.March, 4, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * 7", time.Date(2024, time.March, 3, 8, 0, 0, 0, time.UTC)},
+		{"0 6 1 * 1-5", time.Date(2024, time.March, 4, 6, 0, 0, 0, time.UTC)}, // Either day field
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		c, err := parseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		if got := c.next(at); !got.Equal(tt.want) {
+			t.Errorf("next %q = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("parseCron(%q) accepted", spec)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Now().Add(-2 * time.Hour))
+	mcfg := manager.DefaultConfig()
+	mcfg.Currency = "EUR"
+	mcfg.ItemPrices = map[string]float64{"latte": 3.5}
+	om := manager.New(mcfg, manager.WithClock(clk))
+	for _, item := range []string{"latte", "latte", "soup"} {
+		if _, err := om.PlaceOrder(ctx, manager.NewOrder{Item: item, Priority: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clk.Advance(4 * time.Minute)
+	if _, err := om.PrepareOrder(ctx); err != nil {
+		t.Fatal(err)
+	}
+	old := archived{
+		{ID: "a1", Item: "latte", Quantity: 1, Status: queue.StatusPickedUp, Timestamp: time.Now().AddDate(0, 0, -3),
+			Total: &queue.Total{Currency: "EUR", Subtotal: 350, Total: 350}},
+		{ID: "a2", Item: "cake", Quantity: 1, Status: queue.StatusPickedUp, Timestamp: time.Now().AddDate(0, 0, -9)},
+	}
+
+	var mu sync.Mutex
+	var posted []Delivery
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(body)
+		if r.Header.Get(SignatureHeader) != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("bad signature %q", r.Header.Get(SignatureHeader))
+		}
+		var d Delivery
+		if err := json.Unmarshal(body, &d); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		posted = append(posted, d)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	cfg := DefaultConfig()
+	cfg.Schedules = []Schedule{
+		{Period: PeriodDay, Cron: "30 23 * * *", Emails: []string{"owner@example.com"}},
+		{Period: PeriodWeek, Cron: "0 8 * * 1", Webhook: hook.URL, Secret: "s3cret"},
+	}
+	mail := &mails{}
+	s, err := New(cfg, om, old, mail, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Send(ctx, "daily", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(mail.sent) != 1 || mail.sent[0].To != "owner@example.com" || !strings.HasPrefix(mail.sent[0].Subject, "Daily report, ") {
+		t.Fatalf("mailed %+v", mail.sent)
+	}
+	for _, want := range []string{"Orders: 3\n", "Revenue: 7.00 EUR over 2 orders", "  1. latte: 2 orders, 2 sold"} {
+		if !strings.Contains(mail.sent[0].Body, want) {
+			t.Errorf("no %q in\n%s", want, mail.sent[0].Body)
+		}
+	}
+
+	if err := s.Send(ctx, "weekly", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 1 || posted[0].Event != EventReport || posted[0].Period != PeriodWeek || posted[0].Report.Orders != 4 ||
+		len(posted[0].Report.Revenue) != 1 || posted[0].Report.Revenue[0].Total != 1050 {
+		t.Errorf("posted %+v", posted)
+	}
+	if err := s.Send(ctx, "monthly", time.Now()); err == nil {
+		t.Error("unknown report sent")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, s := range []Schedule{
+		{Period: "month", Cron: "0 0 1 * *", Webhook: "http://x"},
+		{Period: PeriodDay, Cron: "0 0 1 * *"},
+		{Period: PeriodDay, Cron: "daily", Webhook: "http://x"},
+	} {
+		cfg := DefaultConfig()
+		cfg.Schedules = []Schedule{s}
+		if cfg.Validate() == nil {
+			t.Errorf("%+v accepted", s)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.Schedules = []Schedule{{Period: PeriodDay, Cron: "0 0 * * *", Webhook: "http://x"}, {Period: PeriodDay, Cron: "0 1 * * *", Webhook: "http://y"}}
+	if cfg.Validate() == nil {
+		t.Error("two schedules named daily accepted")
+	}
+}
@@ -0,0 +1,266 @@
+// Package ticket lays orders out as tickets for receipt printers: the token
+// number, item and quantity, notes, allergy and dietary flags, the total
+// and times, wrapped to the paper's width. Tickets are written as plain text, which
+// any printer prints as it comes, or with the ESC/POS commands for a
+// double-size token number, bold alerts and the paper cut.
+package ticket
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"awesomeProject/pkg/i18n"
+	"awesomeProject/pkg/money"
+	"awesomeProject/pkg/queue"
+)
+
+// Line styles
+const (
+	Plain  = ""
+	Title  = "title"  // Token number: centred, double size on ESC/POS printers
+	Center = "center" // Centred
+	Bold   = "bold"
+	Rule   = "rule" // Separator across the paper
+)
+
+// Ticket widths in characters; 42 fits 80 mm paper in the printers' usual
+// font, 32 fits 58 mm
+const (
+	DefaultWidth = 42
+	MinWidth     = 24
+	MaxWidth     = 64
+)
+
+// Line is one line of a ticket, already wrapped to its width
+type Line struct {
+	Text  string
+	Style string
+}
+
+// Lines lays out the ticket for t in lang, wrapped to width
+func Lines(t *queue.Token, lang string, width int) []Line {
+	var lines []Line
+	add := func(style, text string) {
+		// Order text must not reach the printer as commands
+		text = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return ' '
+			}
+			return r
+		}, text)
+		for _, l := range wrapText(text, width) {
+			lines = append(lines, Line{Text: l, Style: style})
+		}
+	}
+	if t.Number != 0 {
+		add(Title, i18n.Sprintf(lang, "Token %d", t.Number))
+		add(Center, i18n.Sprintf(lang, "Order %s", t.ID))
+	} else {
+		add(Title, i18n.Sprintf(lang, "Order %s", t.ID))
+	}
+	var where []string
+	if t.OrderType != "" {
+		where = append(where, i18n.T(lang, strings.ReplaceAll(t.OrderType, "_", " ")))
+	}
+	if t.Table != 0 {
+		where = append(where, i18n.Sprintf(lang, "table %d", t.Table))
+	}
+	if t.Course != 0 {
+		where = append(where, i18n.Sprintf(lang, "course %d", t.Course))
+	}
+	if len(where) > 0 {
+		add(Center, strings.Join(where, " - "))
+	}
+	if t.Station != "" {
+		add(Center, t.Station)
+	}
+	if t.RushedAt != nil {
+		add(Bold, i18n.T(lang, "RUSH"))
+	}
+	if t.RemakeOf != "" {
+		add(Bold, i18n.Sprintf(lang, "REMAKE: %s", t.RemakeReason))
+	}
+
+	lines = append(lines, Line{Style: Rule})
+	add(Bold, fmt.Sprintf("%d x %s", max(t.Quantity, 1), t.Item))
+	if t.Notes != "" {
+		add(Plain, i18n.T(lang, "Notes: ")+t.Notes)
+	}
+	var allergens, diet []string
+	for _, f := range t.Flags {
+		if queue.IsAllergyFlag(f) {
+			allergens = append(allergens, f)
+		} else {
+			diet = append(diet, f)
+		}
+	}
+	if len(allergens) > 0 {
+		add(Bold, i18n.T(lang, "ALLERGY: ")+strings.Join(allergens, ", "))
+	}
+	if len(diet) > 0 {
+		add(Plain, strings.Join(diet, ", "))
+	}
+
+	if total := t.Total; total != nil {
+		lines = append(lines, Line{Style: Rule})
+		amount := func(style, label string, v int64) {
+			add(style, fmt.Sprintf("%-12s %s", label, money.Format(v, total.Currency)))
+		}
+		rate := strconv.FormatFloat(total.TaxRate, 'f', -1, 64)
+		if total.TaxIncluded {
+			amount(Bold, i18n.T(lang, "Total"), total.Total)
+			if total.Tax != 0 {
+				add(Plain, i18n.Sprintf(lang, "incl. %s%% tax: %s", rate, money.Format(total.Tax, total.Currency)))
+			}
+		} else {
+			amount(Plain, i18n.T(lang, "Subtotal"), total.Subtotal)
+			if total.Tax != 0 {
+				amount(Plain, i18n.Sprintf(lang, "Tax %s%%", rate), total.Tax)
+			}
+			amount(Bold, i18n.T(lang, "Total"), total.Total)
+		}
+	}
+
+	lines = append(lines, Line{Style: Rule})
+	stamp := func(label string, at *time.Time) {
+		if at != nil {
+			add(Plain, fmt.Sprintf("%-12s %s", i18n.T(lang, label), at.Local().Format("2006-01-02 15:04")))
+		}
+	}
+	stamp("Ordered", &t.Timestamp)
+	stamp("Ready by", t.ReadyAt)
+	stamp("Started", t.ClaimedAt)
+	stamp("Prepared", t.PreparedAt)
+	if t.PickupCode != "" {
+		add(Plain, fmt.Sprintf("%-12s %s", i18n.T(lang, "Pickup code"), t.PickupCode))
+	}
+	return lines
+}
+
+// wrapText breaks s into lines of at most width characters at spaces,
+// splitting words longer than a line
+func wrapText(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		for utf8.RuneCountInString(word) > width {
+			if line != "" {
+				lines, line = append(lines, line), ""
+			}
+			r := []rune(word)
+			lines, word = append(lines, string(r[:width])), string(r[width:])
+		}
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+			line += " " + word
+		default:
+			lines, line = append(lines, line), word
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// center pads s on the left to centre it in width
+func center(s string, width int) string {
+	if pad := (width - utf8.RuneCountInString(s)) / 2; pad > 0 {
+		return strings.Repeat(" ", pad) + s
+	}
+	return s
+}
+
+// Text returns the ticket as plain text, the title in capitals
+func Text(lines []Line, width int) []byte {
+	var buf bytes.Buffer
+	for _, l := range lines {
+		switch l.Style {
+		case Title:
+			buf.WriteString(center(strings.ToUpper(l.Text), width))
+		case Center:
+			buf.WriteString(center(l.Text, width))
+		case Rule:
+			buf.WriteString(strings.Repeat("-", width))
+		default:
+			buf.WriteString(l.Text)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// ESC/POS commands
+var (
+	escInit       = []byte{0x1b, '@'}    // Reset the printer
+	escCodePage   = []byte{0x1b, 't', 0} // Code page 437
+	escAlignLeft  = []byte{0x1b, 'a', 0}
+	escAlignCent  = []byte{0x1b, 'a', 1}
+	escBoldOn     = []byte{0x1b, 'E', 1}
+	escBoldOff    = []byte{0x1b, 'E', 0}
+	escDoubleSize = []byte{0x1d, '!', 0x11} // Double width and height
+	escNormalSize = []byte{0x1d, '!', 0}
+	escFeedCut    = []byte{0x1d, 'V', 'B', 3} // Feed three lines and cut, leaving a tab
+)
+
+// ESCPOS returns the ticket for an ESC/POS printer: the title centred at
+// double size, bold lines in bold, and the paper cut at the end. Text is
+// sent in code page 437, other characters as '?'.
+func ESCPOS(lines []Line, width int) []byte {
+	var buf bytes.Buffer
+	buf.Write(escInit)
+	buf.Write(escCodePage)
+	for _, l := range lines {
+		switch l.Style {
+		case Title:
+			buf.Write(escAlignCent)
+			buf.Write(escDoubleSize)
+			writeCP437(&buf, l.Text)
+			buf.Write(escNormalSize)
+			buf.Write(escAlignLeft)
+		case Center:
+			buf.Write(escAlignCent)
+			writeCP437(&buf, l.Text)
+			buf.Write(escAlignLeft)
+		case Bold:
+			buf.Write(escBoldOn)
+			writeCP437(&buf, l.Text)
+			buf.Write(escBoldOff)
+		case Rule:
+			buf.WriteString(strings.Repeat("-", width))
+		default:
+			writeCP437(&buf, l.Text)
+		}
+		buf.WriteByte('\n')
+	}
+	buf.Write(escFeedCut)
+	return buf.Bytes()
+}
+
+// cp437 maps the accented letters of the catalog languages to code page 437
+var cp437 = map[rune]byte{
+	'á': 0xa0, 'é': 0x82, 'í': 0xa1, 'ó': 0xa2, 'ú': 0xa3, 'ñ': 0xa4, 'Ñ': 0xa5, 'ü': 0x81, 'Ü': 0x9a,
+	'É': 0x90, 'ç': 0x87, 'Ç': 0x80, 'à': 0x85, 'è': 0x8a, 'ì': 0x8d, 'ò': 0x95, 'ù': 0x97,
+	'â': 0x83, 'ê': 0x88, 'î': 0x8c, 'ô': 0x93, 'û': 0x96, 'ä': 0x84, 'Ä': 0x8e, 'ö': 0x94, 'Ö': 0x99,
+	'ë': 0x89, 'ï': 0x8b, 'ÿ': 0x98, 'ß': 0xe1, '¿': 0xa8, '¡': 0xad, '°': 0xf8,
+}
+
+// writeCP437 writes s, free of control characters, in code page 437
+func writeCP437(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		if b, ok := cp437[r]; ok {
+			buf.WriteByte(b)
+		} else if r < utf8.RuneSelf {
+			buf.WriteByte(byte(r))
+		} else {
+			buf.WriteByte('?')
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package ticket
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/queue"
+)
+
+func TestWrapText(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want []string
+	}{
+		{"", []string{""}},
+		{"extra  cheese on the side", []string{"extra", "cheese on", "the side"}},
+		{"supercalifragilistic", []string{"supercali", "fragilist", "ic"}},
+	} {
+		if got := wrapText(tt.in, 9); !slices.Equal(got, tt.want) {
+			t.Errorf("wrapText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestESCPOS(t *testing.T) {
+	tok := &queue.Token{ID: "7", Number: 3, Item: "crème brûlée", Quantity: 1, Notes: "cut\x1d V\x00", Timestamp: time.Now()}
+	data := ESCPOS(Lines(tok, "", DefaultWidth), DefaultWidth)
+	if !bytes.Contains(data, []byte("1 x cr\x8ame br\x96l\x82e")) {
+		t.Errorf("item not in code page 437: %q", data)
+	}
+	if bytes.Count(data, escFeedCut) != 1 || !bytes.HasSuffix(data, escFeedCut) {
+		t.Errorf("notes reached the printer as a command: %q", data)
+	}
+}
+
+func TestTotal(t *testing.T) {
+	tok := &queue.Token{ID: "7", Item: "latte", Quantity: 2, Timestamp: time.Now(),
+		Total: &queue.Total{Currency: "USD", Subtotal: 900, Tax: 80, Total: 980, TaxRate: 8.875}}
+	var text []string
+	for _, l := range Lines(tok, "", DefaultWidth) {
+		text = append(text, l.Text)
+	}
+	for _, want := range []string{"Subtotal 9.00 USD", "Tax 8.875% 0.80 USD", "Total 9.80 USD"} {
+		if !slices.Contains(text, want) {
+			t.Errorf("no %q in %q", want, text)
+		}
+	}
+
+	tok.Total = &queue.Total{Currency: "EUR", Subtotal: 900, Tax: 150, Total: 900, TaxRate: 20, TaxIncluded: true}
+	text = text[:0]
+	for _, l := range Lines(tok, "es", DefaultWidth) {
+		text = append(text, l.Text)
+	}
+	if !slices.Contains(text, "Total 9.00 EUR") || !slices.Contains(text, "IVA incluido 20%: 1.50 EUR") {
+		t.Errorf("tax-inclusive total = %q", text)
+	}
+}
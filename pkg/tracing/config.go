@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the exporter settings
+type Config struct {
+	Endpoint      string            // OTLP/HTTP traces URL, such as http://collector:4318/v1/traces
+	Headers       map[string]string // Sent with every export, for collector authentication
+	ServiceName   string            // Reported as service.name
+	SampleRatio   float64           // Share of new traces recorded; traces joined from callers follow their sampled flag
+	BatchSize     int               // Spans per export request
+	FlushInterval time.Duration     // Longest a finished span waits before export
+}
+
+func (c Config) withDefaults() Config {
+	if c.ServiceName == "" {
+		c.ServiceName = "restaurant-token"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 256
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = 5 * time.Second
+	}
+	return c
+}
+
+// ConfigFromEnv reads the standard OpenTelemetry environment variables:
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT (to which
+// /v1/traces is added), OTEL_EXPORTER_OTLP_HEADERS or the traces variant,
+// OTEL_SERVICE_NAME, and OTEL_TRACES_SAMPLER with OTEL_TRACES_SAMPLER_ARG
+// (always_on, always_off, traceidratio and their parentbased_ forms). The
+// boolean is false when no endpoint is set or OTEL_TRACES_EXPORTER is none,
+// meaning tracing is off.
+func ConfigFromEnv() (Config, bool, error) {
+	cfg := Config{ServiceName: os.Getenv("OTEL_SERVICE_NAME"), SampleRatio: 1}
+	if os.Getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return cfg, false, nil
+	}
+	cfg.Endpoint = os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if cfg.Endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			cfg.Endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+	if cfg.Endpoint == "" {
+		return cfg, false, nil
+	}
+	if _, err := url.ParseRequestURI(cfg.Endpoint); err != nil {
+		return cfg, false, fmt.Errorf("OTLP endpoint %q: %w", cfg.Endpoint, err)
+	}
+
+	headers := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS")
+	if headers == "" {
+		headers = os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")
+	}
+	for _, kv := range strings.Split(headers, ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return cfg, false, fmt.Errorf("OTLP header %q: want key=value", kv)
+		}
+		if cfg.Headers == nil {
+			cfg.Headers = make(map[string]string)
+		}
+		// Values may be percent-encoded
+		if dv, err := url.QueryUnescape(v); err == nil {
+			v = dv
+		}
+		cfg.Headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	switch sampler := os.Getenv("OTEL_TRACES_SAMPLER"); strings.TrimPrefix(sampler, "parentbased_") {
+	case "", "always_on":
+	case "always_off":
+		cfg.SampleRatio = 0
+	case "traceidratio":
+		if arg := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); arg != "" {
+			ratio, err := strconv.ParseFloat(arg, 64)
+			if err != nil || ratio < 0 || ratio > 1 {
+				return cfg, false, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG %q: want a ratio between 0 and 1", arg)
+			}
+			cfg.SampleRatio = ratio
+		}
+	default:
+		return cfg, false, fmt.Errorf("unsupported OTEL_TRACES_SAMPLER %q", sampler)
+	}
+	return cfg, true, nil
+}
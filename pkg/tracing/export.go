@@ -0,0 +1,272 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// queueSize bounds the finished spans waiting for export; spans finished
+// while it is full are dropped rather than slowing requests down
+const queueSize = 4096
+
+type attribute struct {
+	key   string
+	value any
+}
+
+// exporter batches finished spans and posts them to the collector as
+// OTLP/HTTP JSON
+type exporter struct {
+	cfg    Config
+	client *http.Client
+	spans  chan *Span
+	flush  chan chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+	mu     sync.Mutex
+	lost   int // Spans dropped by add since the last warning
+}
+
+func newExporter(cfg Config) *exporter {
+	e := &exporter{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		spans:  make(chan *Span, queueSize),
+		flush:  make(chan chan struct{}),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *exporter) add(s *Span) {
+	select {
+	case e.spans <- s:
+	default:
+		e.mu.Lock()
+		e.lost++
+		e.mu.Unlock()
+	}
+}
+
+// run collects spans into batches, exporting each when it is full or the
+// flush interval passes
+func (e *exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.cfg.FlushInterval)
+	defer ticker.Stop()
+	var batch []*Span
+	send := func() {
+		e.mu.Lock()
+		lost := e.lost
+		e.lost = 0
+		e.mu.Unlock()
+		if lost > 0 {
+			log.Printf("tracing: dropped %d spans, export queue full", lost)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			log.Printf("tracing: export %d spans: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case s := <-e.spans:
+			batch = append(batch, s)
+			if len(batch) >= e.cfg.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ack := <-e.flush:
+			e.drain(&batch)
+			send()
+			close(ack)
+		case <-e.stop:
+			e.drain(&batch)
+			send()
+			return
+		}
+	}
+}
+
+// drain moves every queued span into batch
+func (e *exporter) drain(batch *[]*Span) {
+	for {
+		select {
+		case s := <-e.spans:
+			*batch = append(*batch, s)
+		default:
+			return
+		}
+	}
+}
+
+// shutdown exports what is queued and stops run
+func (e *exporter) shutdown(ctx context.Context) error {
+	e.once.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush exports the spans finished so far, waiting until the export is done
+// or ctx ends
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	ack := make(chan struct{})
+	select {
+	case t.exp.flush <- ack:
+	case <-t.exp.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *exporter) export(batch []*Span) error {
+	body, err := json.Marshal(otlpRequest(e.cfg.ServiceName, batch))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("collector answered %s", res.Status)
+	}
+	return nil
+}
+
+// The OTLP/HTTP JSON encoding of an export request. IDs are hex and
+// timestamps are nanosecond strings, as the protocol's JSON mapping requires.
+type (
+	otlpExport struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID      string          `json:"traceId"`
+		SpanID       string          `json:"spanId"`
+		ParentSpanID string          `json:"parentSpanId,omitempty"`
+		Name         string          `json:"name"`
+		Kind         int             `json:"kind"`
+		Start        string          `json:"startTimeUnixNano"`
+		End          string          `json:"endTimeUnixNano"`
+		Attributes   []otlpAttribute `json:"attributes,omitempty"`
+		Status       otlpStatus      `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 2 for error, otherwise unset
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		String *string  `json:"stringValue,omitempty"`
+		Bool   *bool    `json:"boolValue,omitempty"`
+		Int    *string  `json:"intValue,omitempty"`
+		Double *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+func otlpRequest(service string, batch []*Span) otlpExport {
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		o := otlpSpan{
+			TraceID: hex.EncodeToString(s.sc.TraceID[:]),
+			SpanID:  hex.EncodeToString(s.sc.SpanID[:]),
+			Name:    s.name,
+			Kind:    s.kind,
+			Start:   strconv.FormatInt(s.start.UnixNano(), 10),
+			End:     strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != (SpanID{}) {
+			o.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, a := range s.attrs {
+			o.Attributes = append(o.Attributes, otlpAttr(a.key, a.value))
+		}
+		if s.errMsg != "" {
+			o.Status = otlpStatus{Code: 2, Message: s.errMsg}
+		}
+		spans[i] = o
+	}
+	return otlpExport{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{otlpAttr("service.name", service)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "awesomeProject/pkg/tracing"}, Spans: spans}},
+	}}}
+}
+
+func otlpAttr(key string, value any) otlpAttribute {
+	var v otlpValue
+	switch x := value.(type) {
+	case string:
+		v.String = &x
+	case bool:
+		v.Bool = &x
+	case int:
+		s := strconv.Itoa(x)
+		v.Int = &s
+	case int64:
+		s := strconv.FormatInt(x, 10)
+		v.Int = &s
+	case float64:
+		v.Double = &x
+	default:
+		s := fmt.Sprint(x)
+		v.String = &s
+	}
+	return otlpAttribute{Key: key, Value: v}
+}
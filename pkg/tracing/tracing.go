@@ -0,0 +1,233 @@
+// Package tracing records spans for requests and the work done serving them,
+// and exports them to an OpenTelemetry collector over OTLP/HTTP. Trace
+// context arrives and leaves in W3C traceparent headers, so spans join traces
+// begun by the ordering platform around the service.
+//
+// A nil *Tracer and a nil *Span are valid and do nothing, so instrumented
+// code needs no checks when tracing is off.
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span kinds, numbered as in OTLP
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// TraceID and SpanID identify traces and spans
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+// SpanContext is the part of a span that crosses process boundaries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether sc has non-zero IDs
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Tracer starts spans and hands finished ones to its exporter
+type Tracer struct {
+	cfg Config
+	exp *exporter
+}
+
+// New returns a Tracer exporting to cfg.Endpoint. Call Shutdown to flush the
+// spans still buffered.
+func New(cfg Config) *Tracer {
+	cfg = cfg.withDefaults()
+	return &Tracer{cfg: cfg, exp: newExporter(cfg)}
+}
+
+// Shutdown exports buffered spans and stops the exporter. It gives up when
+// ctx ends.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	return t.exp.shutdown(ctx)
+}
+
+// Span is one timed operation. Its methods are for the goroutine that
+// started it.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	parent SpanID
+	name   string
+	kind   int
+	start  time.Time
+	end    time.Time
+	attrs  []attribute
+	errMsg string // Set when the operation failed
+	once   sync.Once
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Start begins a span named name as a child of the span in ctx, or of a
+// remote parent stored by ContextWithRemote, or as the root of a new trace. The
+// returned context carries the new span.
+func (t *Tracer) Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	parent := SpanContextFrom(ctx)
+	if parent.IsValid() {
+		s.sc.TraceID, s.sc.Sampled, s.parent = parent.TraceID, parent.Sampled, parent.SpanID
+	} else {
+		s.sc.TraceID = newTraceID()
+		s.sc.Sampled = rand.Float64() < t.cfg.SampleRatio
+	}
+	s.sc.SpanID = newSpanID()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Start begins an internal span under the span in ctx, using that span's
+// tracer. It returns a nil span when ctx holds no span.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent, _ := ctx.Value(spanKey{}).(*Span)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, KindInternal)
+}
+
+// SpanContextFrom returns the context of the span in ctx, or the remote
+// parent stored by ContextWithRemote, or the zero SpanContext
+func SpanContextFrom(ctx context.Context) SpanContext {
+	if s, _ := ctx.Value(spanKey{}).(*Span); s != nil {
+		return s.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// ContextWithRemote stores sc, received from another service, as the parent
+// of spans started from the returned context
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Context returns the span's IDs
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttr records a string, bool, integer or float attribute
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil || !s.sc.Sampled {
+		return
+	}
+	s.attrs = append(s.attrs, attribute{key, value})
+}
+
+// RecordError marks the span as failed with err; nil is ignored
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.errMsg = err.Error()
+}
+
+// End finishes the span and queues it for export if it is sampled. Later
+// calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() {
+		s.end = time.Now()
+		if s.sc.Sampled {
+			s.tracer.exp.add(s)
+		}
+	})
+}
+
+// Finish records err, if any, and ends the span
+func (s *Span) Finish(err error) {
+	s.RecordError(err)
+	s.End()
+}
+
+// traceparentHeader carries the W3C trace context: version, trace ID, parent
+// span ID and flags
+const traceparentHeader = "traceparent"
+
+// Inject writes the trace context of ctx into h as a traceparent header
+func Inject(ctx context.Context, h http.Header) {
+	sc := SpanContextFrom(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	h.Set(traceparentHeader, fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags))
+}
+
+// Extract reads a traceparent header from h
+func Extract(h http.Header) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(h.Get(traceparentHeader)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	var flags [1]byte
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) || !decodeHex(flags[:], parts[3]) {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		for i := range id {
+			id[i] = byte(rand.Uint32())
+		}
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		for i := range id {
+			id[i] = byte(rand.Uint32())
+		}
+	}
+	return id
+}
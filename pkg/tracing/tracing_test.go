@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector is a fake OTLP endpoint keeping the spans posted to it
+type collector struct {
+	*httptest.Server
+	mu      sync.Mutex
+	spans   []otlpSpan
+	service string
+	auth    string
+}
+
+func newCollector(t *testing.T) *collector {
+	c := &collector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req otlpExport
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode export: %v", err)
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.auth = r.Header.Get("Authorization")
+		for _, rs := range req.ResourceSpans {
+			c.service = *rs.Resource.Attributes[0].Value.String
+			for _, ss := range rs.ScopeSpans {
+				c.spans = append(c.spans, ss.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestPropagation(t *testing.T) {
+	h := http.Header{}
+	h.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	sc, ok := Extract(h)
+	if !ok || !sc.Sampled {
+		t.Fatalf("Extract = %+v, %v", sc, ok)
+	}
+	out := http.Header{}
+	Inject(ContextWithRemote(context.Background(), sc), out)
+	if got := out.Get("traceparent"); got != h.Get("traceparent") {
+		t.Fatalf("Inject = %q, want %q", got, h.Get("traceparent"))
+	}
+
+	for _, bad := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01", // Zero trace ID
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",    // No flags
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", // Upper case
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", // Invalid version
+	} {
+		h.Set("traceparent", bad)
+		if sc, ok := Extract(h); ok {
+			t.Errorf("Extract(%q) = %+v, want rejected", bad, sc)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	c := newCollector(t)
+	tr := New(Config{Endpoint: c.URL, Headers: map[string]string{"Authorization": "Bearer k"}, ServiceName: "kitchen", SampleRatio: 0})
+
+	h := http.Header{}
+	h.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	remote, _ := Extract(h)
+	ctx, root := tr.Start(ContextWithRemote(context.Background(), remote), "GET /v1/orders", KindServer)
+	root.SetAttr("http.response.status_code", 200)
+	_, child := Start(ctx, "OrderManager.QueryOrders")
+	child.Finish(errors.New("queue unavailable"))
+	root.End()
+	root.End() // Ending twice exports once
+
+	// Unsampled new traces are not exported
+	_, skipped := tr.Start(context.Background(), "GET /healthz", KindServer)
+	skipped.End()
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tr.Flush(flushCtx); err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.spans) != 2 || c.service != "kitchen" || c.auth != "Bearer k" {
+		t.Fatalf("collector got %d spans from %q with auth %q", len(c.spans), c.service, c.auth)
+	}
+	got, parent := c.spans[0], c.spans[1]
+	if got.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || got.ParentSpanID != parent.SpanID || got.Status.Code != 2 {
+		t.Errorf("child span = %+v", got)
+	}
+	if parent.ParentSpanID != "00f067aa0ba902b7" || parent.Kind != KindServer || *parent.Attributes[0].Value.Int != "200" {
+		t.Errorf("server span = %+v", parent)
+	}
+	if err := tr.Shutdown(flushCtx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	var tr *Tracer
+	ctx, span := tr.Start(context.Background(), "op", KindInternal)
+	span.SetAttr("k", "v")
+	span.Finish(errors.New("ignored"))
+	if _, child := Start(ctx, "child"); child != nil {
+		t.Fatal("child span without a tracer")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	if _, on, err := ConfigFromEnv(); on || err != nil {
+		t.Fatalf("no endpoint: on = %v, err = %v", on, err)
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318/")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "api-key=a%20b, x-team=kitchen")
+	t.Setenv("OTEL_TRACES_SAMPLER", "parentbased_traceidratio")
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "0.25")
+	cfg, on, err := ConfigFromEnv()
+	if err != nil || !on {
+		t.Fatalf("on = %v, err = %v", on, err)
+	}
+	if cfg.Endpoint != "http://collector:4318/v1/traces" || cfg.Headers["api-key"] != "a b" || cfg.Headers["x-team"] != "kitchen" || cfg.SampleRatio != 0.25 {
+		t.Fatalf("config = %+v", cfg)
+	}
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "2")
+	if _, _, err := ConfigFromEnv(); err == nil {
+		t.Fatal("ratio above 1 accepted")
+	}
+}
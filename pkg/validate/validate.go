@@ -0,0 +1,198 @@
+// Package validate checks order input and reports problems per field.
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"awesomeProject/pkg/queue"
+)
+
+// FieldError describes a problem with one input field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+
+	malformed bool   // The value could not be parsed at all
+	format    string // Message before formatting, for translation
+	args      []interface{}
+}
+
+// Errors collects field errors; the zero value is ready to use
+type Errors []FieldError
+
+// Malformed records a value that could not be parsed
+func (e *Errors) Malformed(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...), malformed: true, format: format, args: args})
+}
+
+// Add records a value that parsed but breaks a rule
+func (e *Errors) Add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...), format: format, args: args})
+}
+
+// HasMalformed reports whether any value could not be parsed
+func (e Errors) HasMalformed() bool {
+	for _, fe := range e {
+		if fe.malformed {
+			return true
+		}
+	}
+	return false
+}
+
+// Translate returns a copy of e with each message formatted from
+// translate's version of its format
+func (e Errors) Translate(translate func(format string) string) Errors {
+	out := make(Errors, len(e))
+	for i, fe := range e {
+		out[i] = fe
+		if fe.format != "" {
+			out[i].Message = fmt.Sprintf(translate(fe.format), fe.args...)
+		}
+	}
+	return out
+}
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Rules bounds the values accepted for an order
+type Rules struct {
+	MaxItemLength int `json:"maxItemLength"`
+	MinPriority   int `json:"minPriority"`
+	MaxPriority   int `json:"maxPriority"`
+	MaxQuantity   int `json:"maxQuantity"`
+	MaxNotes      int `json:"maxNotesLength"`
+}
+
+// DefaultRules returns the rules used when nothing is configured
+func DefaultRules() Rules {
+	return Rules{
+		MaxItemLength: 100,
+		MinPriority:   0,
+		MaxPriority:   10,
+		MaxQuantity:   100,
+		MaxNotes:      500,
+	}
+}
+
+// Item checks that an item name is present and not too long
+func (r Rules) Item(errs *Errors, v string) {
+	switch n := utf8.RuneCountInString(strings.TrimSpace(v)); {
+	case n == 0:
+		errs.Add("item", "must not be empty")
+	case n > r.MaxItemLength:
+		errs.Add("item", "must be at most %d characters", r.MaxItemLength)
+	}
+}
+
+// Priority checks that a priority is within the configured range
+func (r Rules) Priority(errs *Errors, v int) {
+	if v < r.MinPriority || v > r.MaxPriority {
+		errs.Add("priority", "must be between %d and %d", r.MinPriority, r.MaxPriority)
+	}
+}
+
+// Quantity checks that a quantity is positive and not absurd
+func (r Rules) Quantity(errs *Errors, v int) {
+	switch {
+	case v < 1:
+		errs.Add("quantity", "must be greater than 0")
+	case v > r.MaxQuantity:
+		errs.Add("quantity", "must be at most %d", r.MaxQuantity)
+	}
+}
+
+// Notes checks that free-text notes are not too long
+func (r Rules) Notes(errs *Errors, v string) {
+	if utf8.RuneCountInString(v) > r.MaxNotes {
+		errs.Add("notes", "must be at most %d characters", r.MaxNotes)
+	}
+}
+
+// Flags checks that each flag is one of the queue allergy or dietary flags
+func (r Rules) Flags(errs *Errors, flags []string) {
+	for _, f := range flags {
+		if !queue.ValidFlag(f) {
+			errs.Add("flags", "unknown flag %q, want one of %s", f,
+				strings.Join(append(append([]string(nil), queue.AllergyFlags...), queue.DietaryFlags...), ", "))
+			return
+		}
+	}
+}
+
+// OrderType checks that an order type is one of the queue order types
+func (r Rules) OrderType(errs *Errors, v string) {
+	if v != "" && !queue.ValidOrderType(v) {
+		errs.Add("orderType", "must be one of %s", strings.Join(queue.OrderTypes, ", "))
+	}
+}
+
+// Channel checks that a channel is one of the queue channels
+func (r Rules) Channel(errs *Errors, v string) {
+	if v != "" && !queue.ValidChannel(v) {
+		errs.Add("channel", "must be one of %s", strings.Join(queue.Channels, ", "))
+	}
+}
+
+// maxReference bounds delivery platform names, their order IDs and group IDs
+const maxReference = 100
+
+// Delivery checks that a delivery platform and its order ID come together and
+// are not too long
+func (r Rules) Delivery(errs *Errors, platform, externalID string) {
+	switch {
+	case platform == "" && externalID != "":
+		errs.Add("platform", "is required with %s", "externalId")
+	case platform != "" && externalID == "":
+		errs.Add("externalId", "is required with %s", "platform")
+	}
+	if len(platform) > maxReference {
+		errs.Add("platform", "must be at most %d characters", maxReference)
+	}
+	if len(externalID) > maxReference {
+		errs.Add("externalId", "must be at most %d characters", maxReference)
+	}
+}
+
+// Group checks that a group ID is not too long and that the combined ready
+// notification is only asked for with a group
+func (r Rules) Group(errs *Errors, group string, notify bool) {
+	switch {
+	case notify && group == "":
+		errs.Add("group", "is required with %s", "notifyGroup")
+	case len(group) > maxReference:
+		errs.Add("group", "must be at most %d characters", maxReference)
+	}
+}
+
+// maxCourse bounds course numbers
+const maxCourse = 9
+
+// Course checks that a course number is in range and that courses after the
+// first come with the group they are fired under
+func (r Rules) Course(errs *Errors, course int, group string) {
+	switch {
+	case course < 1 || course > maxCourse:
+		errs.Add("course", "must be between %d and %d", 1, maxCourse)
+	case course > 1 && group == "":
+		errs.Add("group", "is required with %s", "course")
+	}
+}
+
+// Table checks that a table number is positive and only given for dine-in
+func (r Rules) Table(errs *Errors, table int, orderType string) {
+	switch {
+	case table < 1:
+		errs.Add("table", "must be greater than 0")
+	case orderType != "" && orderType != queue.OrderDineIn:
+		errs.Add("table", "only applies to %s orders", queue.OrderDineIn)
+	}
+}
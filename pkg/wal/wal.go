@@ -0,0 +1,356 @@
+// Package wal keeps a write-ahead log of the in-memory order queue, so a
+// crash loses none of the orders waiting to be prepared. Every push, pop,
+// remove and fix of the heap is appended to the log before the manager makes
+// it, and replaying the log on startup rebuilds the heap exactly as it was,
+// down to the position of every token.
+//
+// The log grows with every change, so once enough have been appended the
+// manager compacts it: the file is replaced by one push for each token
+// waiting, in heap order, which pushed onto an empty heap rebuilds it as it
+// stands. A crash partway through an append leaves at most a torn last
+// line, which is dropped.
+package wal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"awesomeProject/pkg/fault"
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// Config selects the log file; an empty Path disables the log
+type Config struct {
+	Path string `json:"path"`
+	Sync bool   `json:"sync"` // fsync after every record, surviving power loss too
+
+	// CompactAfter is how many records are appended before the log is
+	// compacted
+	CompactAfter int `json:"compactAfter"`
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{CompactAfter: 10000}
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	if c.Path != "" && c.CompactAfter < 1 {
+		return errors.New("wal compactAfter must be at least 1")
+	}
+	return nil
+}
+
+// Record is one line of the log: a change to the heap. Pushes and fixes
+// carry the token as it was after the change; pops and removes only its ID.
+type Record struct {
+	Op    string       `json:"op"`
+	ID    string       `json:"id,omitempty"`
+	Token *queue.Token `json:"token,omitempty"`
+}
+
+// MarshalJSON writes the record's token as stores do, pickup code included
+func (r Record) MarshalJSON() ([]byte, error) {
+	type plain Record
+	v := struct {
+		plain
+		Token any `json:"token,omitempty"`
+	}{plain: plain(r)}
+	if r.Token != nil {
+		v.Token = r.Token.Stored()
+	}
+	return json.Marshal(v)
+}
+
+// ErrDiverged reports a log that does not describe a heap the changes could
+// have been made to, such as a pop of a token that was not first
+var ErrDiverged = errors.New("write-ahead log does not replay")
+
+// Log appends the changes to the heap to a file
+type Log struct {
+	cfg     Config
+	mu      sync.Mutex
+	f       *os.File
+	heap    []*queue.Token // Replayed by Open, until Attach hands it over
+	records int            // Appended since the file was last compacted
+	stale   bool           // An append or compaction failed, so the file may describe another heap
+	err     error          // Last failed append or compaction, cleared by the next success
+}
+
+// Open replays the log at cfg.Path, if any, and opens it for appending. A
+// torn last record, left by a crash partway through writing it, is cut off.
+func Open(cfg Config) (*Log, error) {
+	data, err := os.ReadFile(cfg.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	heap, n, good, err := Replay(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.Path, err)
+	}
+	if good < len(data) {
+		log.Printf("wal: dropping a torn record at the end of %s", cfg.Path)
+		if err := os.Truncate(cfg.Path, int64(good)); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{cfg: cfg, f: f, heap: heap, records: n}, nil
+}
+
+// Replay applies the records in data to an empty heap and returns it in heap
+// order, along with the number of records and the length of data they take
+// up. A last line without its newline is torn and left out.
+func Replay(data []byte) (heap []*queue.Token, records, good int, err error) {
+	pq := queue.NewPriorityQueue(0)
+	byID := make(map[string]*queue.Token)
+	for line := 1; good < len(data); line++ {
+		end := bytes.IndexByte(data[good:], '\n')
+		if end < 0 {
+			break
+		}
+		raw := data[good : good+end]
+		good += end + 1
+		if len(raw) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return nil, 0, 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := apply(&pq, byID, rec); err != nil {
+			return nil, 0, 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		records++
+	}
+	return pq, records, good, nil
+}
+
+// apply makes the change rec records, as the manager's queue made it
+func apply(pq *queue.PriorityQueue, byID map[string]*queue.Token, rec Record) error {
+	switch rec.Op {
+	case manager.HeapPush:
+		if rec.Token == nil {
+			return fmt.Errorf("%w: push without a token", ErrDiverged)
+		}
+		if old, ok := byID[rec.Token.ID]; ok {
+			pq.Remove(old)
+		}
+		pq.PushToken(rec.Token)
+		byID[rec.Token.ID] = rec.Token
+	case manager.HeapPop:
+		if pq.Len() == 0 || (*pq)[0].ID != rec.ID {
+			return fmt.Errorf("%w: pop of %s, which is not first", ErrDiverged, rec.ID)
+		}
+		delete(byID, pq.PopToken().ID)
+	case manager.HeapRemove:
+		t, ok := byID[rec.ID]
+		if !ok {
+			return fmt.Errorf("%w: remove of %s, which is not queued", ErrDiverged, rec.ID)
+		}
+		pq.Remove(t)
+		delete(byID, rec.ID)
+	case manager.HeapFix:
+		if rec.Token == nil {
+			return fmt.Errorf("%w: fix without a token", ErrDiverged)
+		}
+		old, ok := byID[rec.Token.ID]
+		if !ok {
+			return fmt.Errorf("%w: fix of %s, which is not queued", ErrDiverged, rec.Token.ID)
+		}
+		pq.Replace(old, rec.Token)
+		byID[rec.Token.ID] = rec.Token
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrDiverged, rec.Op)
+	}
+	return nil
+}
+
+// Attach hands the replayed heap to om, which records its changes in the
+// log from then on. Call it once the event log, if any, has been replayed.
+func (l *Log) Attach(om *manager.OrderManager) (manager.QueueRebuild, error) {
+	l.mu.Lock()
+	heap := l.heap
+	l.heap = nil
+	l.mu.Unlock()
+	return om.RestoreQueue(heap, l)
+}
+
+// Append writes the change op is about to make to t
+func (l *Log) Append(op string, t *queue.Token) error {
+	rec := Record{Op: op}
+	switch op {
+	case manager.HeapPush, manager.HeapFix:
+		rec.Token = t
+	default:
+		rec.ID = t.ID
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stale {
+		return l.err
+	}
+	if err := fault.Write(fault.StoreWAL); err != nil {
+		return fmt.Errorf("append %s %s: %w", op, t.ID, err)
+	}
+	// A failed write may leave part of the record, and a failed sync all of
+	// it, for a change that is not made; either way the file is stale
+	// until compacted
+	if _, err := l.f.Write(append(line, '\n')); err != nil {
+		l.stale, l.err = true, fmt.Errorf("append %s %s: %w", op, t.ID, err)
+		return l.err
+	}
+	if l.cfg.Sync {
+		if err := l.f.Sync(); err != nil {
+			l.stale, l.err = true, fmt.Errorf("sync %s %s: %w", op, t.ID, err)
+			return l.err
+		}
+	}
+	l.records++
+	l.err = nil
+	return nil
+}
+
+// Due reports whether CompactAfter records have been appended since the
+// last compaction, or a compaction failed and is to be tried again
+func (l *Log) Due() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stale || l.records >= l.cfg.CompactAfter
+}
+
+// Compact replaces the file with a push of each token of heap, in heap
+// order, and reopens it for appending. After an append or compaction
+// fails, appends are refused until a compaction succeeds, as the file may
+// no longer describe the heap they change.
+func (l *Log) Compact(heap []*queue.Token) error {
+	var buf bytes.Buffer
+	for _, t := range heap {
+		line, err := json.Marshal(Record{Op: manager.HeapPush, Token: t})
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.rewrite(buf.Bytes()); err != nil {
+		l.stale, l.err = true, fmt.Errorf("compact: %w", err)
+		return l.err
+	}
+	l.records, l.stale, l.err = 0, false, nil
+	return nil
+}
+
+// Scrub erases the personal data of the tokens match picks from every record
+// that carries one, rewriting the file if any did; a torn last record is
+// kept as it is. It reports how many records it changed. A failed rewrite
+// leaves the log stale, as a failed compaction does.
+func (l *Log) Scrub(match func(*queue.Token) bool) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	data, err := os.ReadFile(l.cfg.Path)
+	if err != nil {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	n, good := 0, 0
+	for good < len(data) {
+		end := bytes.IndexByte(data[good:], '\n')
+		if end < 0 {
+			break
+		}
+		raw := data[good : good+end]
+		good += end + 1
+		var rec Record
+		if len(raw) > 0 && json.Unmarshal(raw, &rec) == nil && rec.Token != nil && match(rec.Token) && rec.Token.Scrub() {
+			if raw, err = json.Marshal(rec); err != nil {
+				return 0, err
+			}
+			n++
+		}
+		buf.Write(raw)
+		buf.WriteByte('\n')
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	buf.Write(data[good:])
+	if err := l.rewrite(buf.Bytes()); err != nil {
+		l.stale, l.err = true, fmt.Errorf("scrub: %w", err)
+		return 0, l.err
+	}
+	return n, nil
+}
+
+// rewrite replaces the file with data, so a crash leaves either the old log
+// or the new one; mu must be held
+func (l *Log) rewrite(data []byte) error {
+	tmp := l.cfg.Path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, l.cfg.Path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	f, err = os.OpenFile(l.cfg.Path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("reopen: %w", err)
+	}
+	l.f.Close()
+	l.f = f
+	return nil
+}
+
+// Check reports whether the log is still writable: it fails if the file has
+// gone or the last append or compaction failed
+func (l *Log) Check(context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	_, err := l.f.Stat()
+	return err
+}
+
+// Close flushes and closes the file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.f.Sync(); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,253 @@
+package wal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"awesomeProject/pkg/manager"
+	"awesomeProject/pkg/queue"
+)
+
+// spy keeps the heap each compaction is given
+type spy struct {
+	*Log
+	heap []string
+}
+
+func (s *spy) Compact(heap []*queue.Token) error {
+	s.heap = ids(heap)
+	return s.Log.Compact(heap)
+}
+
+func ids(tokens []*queue.Token) []string {
+	out := make([]string, len(tokens))
+	for i, t := range tokens {
+		out[i] = t.ID
+	}
+	return out
+}
+
+func replayFile(t *testing.T, path string) []*queue.Token {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heap, _, _, err := Replay(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return heap
+}
+
+func TestReplayRebuildsHeap(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "queue.wal")
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	journal := &spy{Log: l}
+	om := manager.New(manager.DefaultConfig())
+	if _, err := om.RestoreQueue(nil, journal); err != nil {
+		t.Fatal(err)
+	}
+
+	r := rand.New(rand.NewPCG(1, 2))
+	var placed []string
+	for range 300 {
+		switch n := r.IntN(10); {
+		case n < 5:
+			tok, err := om.AddOrder(ctx, "soup", r.IntN(5))
+			if err != nil {
+				t.Fatal(err)
+			}
+			placed = append(placed, tok.ID)
+		case n < 7:
+			if _, err := om.PrepareOrder(ctx); err != nil && !errors.Is(err, manager.ErrQueueEmpty) {
+				t.Fatal(err)
+			}
+		case n < 8 && len(placed) > 0:
+			om.CancelOrder(ctx, placed[r.IntN(len(placed))], "")
+		case len(placed) > 0:
+			p := r.IntN(5)
+			om.ModifyOrder(ctx, placed[r.IntN(len(placed))], manager.OrderChanges{Priority: &p})
+		}
+	}
+	replayed := ids(replayFile(t, cfg.Path))
+
+	// Rebuilding pushes the heap in heap order, which leaves it as it was
+	// and hands it to Compact
+	if _, err := om.RebuildQueue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(journal.heap) == 0 || !slices.Equal(replayed, journal.heap) {
+		t.Fatalf("replayed heap %v, want %v", replayed, journal.heap)
+	}
+	if compacted := ids(replayFile(t, cfg.Path)); !slices.Equal(compacted, journal.heap) {
+		t.Errorf("compacted heap %v, want %v", compacted, journal.heap)
+	}
+
+	// A server started afresh prepares the orders in the same order
+	again, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	restarted := manager.New(manager.DefaultConfig())
+	rb, err := again.Attach(restarted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rb.Queued != len(journal.heap) {
+		t.Errorf("queued %d, want %d", rb.Queued, len(journal.heap))
+	}
+	for range journal.heap {
+		want, err := om.PrepareOrder(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := restarted.PrepareOrder(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != want.ID || got.Priority != want.Priority || got.PickupCode != want.PickupCode {
+			t.Fatalf("restarted prepared %s (priority %d, code %q), want %s (priority %d, code %q)",
+				got.ID, got.Priority, got.PickupCode, want.ID, want.Priority, want.PickupCode)
+		}
+	}
+	if tok, err := restarted.AddOrder(ctx, "tea", 1); err != nil || slices.Contains(placed, tok.ID) {
+		t.Errorf("order placed after restarting: %v, %v", tok, err)
+	}
+}
+
+func TestOpenDropsTornRecord(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "queue.wal")
+	good := `{"op":"push","token":{"id":"1","item":"soup","priority":1,"status":"preparing","timestamp":"2026-01-02T12:00:00Z"}}` + "\n"
+	if err := os.WriteFile(cfg.Path, []byte(good+`{"op":"push","tok`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if len(l.heap) != 1 || l.heap[0].ID != "1" {
+		t.Errorf("heap = %v", l.heap)
+	}
+	if data, _ := os.ReadFile(cfg.Path); string(data) != good {
+		t.Errorf("file after open = %q", data)
+	}
+}
+
+func TestReplayDiverged(t *testing.T) {
+	for _, data := range []string{
+		`{"op":"pop","id":"1"}` + "\n",
+		`{"op":"remove","id":"1"}` + "\n",
+		`{"op":"fix","token":{"id":"1"}}` + "\n",
+		`{"op":"shuffle"}` + "\n",
+	} {
+		if _, _, _, err := Replay([]byte(data)); !errors.Is(err, ErrDiverged) {
+			t.Errorf("%s: %v", data, err)
+		}
+	}
+}
+
+func TestRestoreQueueReconciles(t *testing.T) {
+	ctx := context.Background()
+	om := manager.New(manager.DefaultConfig())
+	for _, item := range []string{"soup", "tea", "cake"} {
+		if _, err := om.AddOrder(ctx, item, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := om.PrepareOrder(ctx); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := om.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The log saw order 1 still waiting and never saw order 3
+	cfg := DefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "queue.wal")
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	first := snap.Prepared[0].Clone()
+	first.Status, first.PreparedAt = queue.StatusPreparing, nil
+	l.heap = []*queue.Token{first, snap.Waiting[0]}
+
+	restarted := manager.New(manager.DefaultConfig())
+	if err := restarted.RestoreSnapshot(snap); err != nil {
+		t.Fatal(err)
+	}
+	rb, err := l.Attach(restarted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rb.Queued != 2 || !slices.Equal(rb.Restored, []string{"3"}) || !slices.Equal(rb.Dropped, []string{"1"}) {
+		t.Errorf("rebuild = %+v", rb)
+	}
+	if heap := ids(replayFile(t, cfg.Path)); !slices.Equal(heap, []string{"2", "3"}) {
+		t.Errorf("journaled heap = %v", heap)
+	}
+}
+
+func TestScrub(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "queue.wal")
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	soup := &queue.Token{ID: "1", Item: "soup", Priority: 1, Phone: "+15550100", Notes: "for Ann", PickupCode: "1234",
+		Timestamp: time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)}
+	tea := &queue.Token{ID: "2", Item: "tea", Priority: 1, Phone: "+15550199", Timestamp: soup.Timestamp.Add(time.Minute)}
+	for _, rec := range []struct {
+		op string
+		t  *queue.Token
+	}{{manager.HeapPush, soup}, {manager.HeapPush, tea}, {manager.HeapFix, soup}, {manager.HeapPop, soup}} {
+		if err := l.Append(rec.op, rec.t); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := l.Scrub(func(t *queue.Token) bool { return t.Phone == "+15550100" })
+	if err != nil || n != 2 {
+		t.Fatalf("scrub = %d, %v", n, err)
+	}
+	if data, _ := os.ReadFile(cfg.Path); bytes.Contains(data, []byte("+15550100")) || bytes.Contains(data, []byte("for Ann")) ||
+		!bytes.Contains(data, []byte("+15550199")) {
+		t.Errorf("file after scrub = %s", data)
+	}
+	if data, _ := os.ReadFile(cfg.Path); !bytes.Contains(data, []byte(`"pickupCode":"1234"`)) {
+		t.Errorf("scrub dropped the pickup code: %s", data)
+	}
+	if heap := replayFile(t, cfg.Path); len(heap) != 1 || heap[0].ID != "2" {
+		t.Errorf("heap after scrub = %v", ids(heap))
+	}
+	if n, err := l.Scrub(func(*queue.Token) bool { return true }); err != nil || n != 1 {
+		t.Errorf("second scrub = %d, %v", n, err)
+	}
+	if err := l.Append(manager.HeapPop, tea); err != nil {
+		t.Fatal(err)
+	}
+	if heap := replayFile(t, cfg.Path); len(heap) != 0 {
+		t.Errorf("heap after appending to the scrubbed log = %v", ids(heap))
+	}
+}